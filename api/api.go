@@ -3,16 +3,16 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
-	"log"
+	"log/slog"
 	"net"
 	"net/http"
 	"net/url"
-	"os"
 	"strings"
 	"time"
 
@@ -27,10 +27,23 @@ type URL struct {
 	URL         url.URL
 	ContentType string
 	Settings    config.Settings
+	// Client makes the request, DefaultClient if nil.
+	Client *Client
+
+	ctx        context.Context
+	header     http.Header
+	onDownload func(Progress)
+	onUpload   func(Progress)
 }
 
 var (
-	Debug            bool
+	// Debug logs everything down to LevelTrace.
+	//
+	// Deprecated: Set LogLevel or Client.LogLevel to LevelTrace.
+	Debug bool
+	// DebugOnErrors logs failed requests.
+	//
+	// Deprecated: Set LogLevel or Client.LogLevel to slog.LevelError.
 	DebugOnErrors    bool
 	DefaultCacheSize = 8192
 
@@ -53,29 +66,6 @@ var (
 	}
 )
 
-func dbg(v ...interface{}) {
-	if Debug {
-		log.Println(v...)
-	}
-}
-
-func dbgerr(v ...interface{}) {
-	if DebugOnErrors && !Debug {
-		log.Println(v...)
-	}
-}
-
-func init() {
-	if os.Getenv("IRON_API_DEBUG") != "" {
-		Debug = true
-		dbg("debugging of api enabled")
-	}
-	if os.Getenv("IRON_API_DEBUG_ON_ERRORS") != "" {
-		DebugOnErrors = true
-		dbg("debugging of api on errors enabled")
-	}
-}
-
 func Action(cs config.Settings, prefix string, suffix ...string) *URL {
 	parts := append([]string{prefix}, suffix...)
 	return ActionEndpoint(cs, strings.Join(parts, "/"))
@@ -121,7 +111,39 @@ func (u *URL) SetContentType(t string) *URL {
 	return u
 }
 
+// Header adds a header to the request, e.g. a correlation id. It is set
+// after the client's and the default ones, replacing them.
+func (u *URL) Header(key, value string) *URL {
+	if u.header == nil {
+		u.header = http.Header{}
+	}
+	u.header.Add(key, value)
+	return u
+}
+
+// WithContext makes the request with ctx, which cancels it and its
+// retries when done.
+func (u *URL) WithContext(ctx context.Context) *URL {
+	u.ctx = ctx
+	return u
+}
+
+func (u *URL) context() context.Context {
+	if u.ctx != nil {
+		return u.ctx
+	}
+	return context.Background()
+}
+
+func (u *URL) client() *Client {
+	if u.Client != nil {
+		return u.Client
+	}
+	return DefaultClient
+}
+
 func (u *URL) Req(method string, in, out interface{}) error {
+	c := u.client()
 	var body io.ReadSeeker
 	switch in := in.(type) {
 	case io.ReadSeeker:
@@ -133,9 +155,9 @@ func (u *URL) Req(method string, in, out interface{}) error {
 		}
 		data, err := json.Marshal(in)
 		if err != nil {
-			return err
+			return u.wrap(method, err)
 		}
-		dbg("request body:", in)
+		c.log(LevelTrace, "request body", "method", method, "url", redactedURL(&u.URL), "body", string(data))
 		body = bytes.NewReader(data)
 	}
 
@@ -143,20 +165,20 @@ func (u *URL) Req(method string, in, out interface{}) error {
 	if response != nil && response.Body != nil {
 		defer response.Body.Close()
 	}
-
 	if err != nil {
-		dbg("ERROR!", err, err.Error())
-		body := "<empty>"
-		if response != nil && response.Body != nil {
-			binary, _ := ioutil.ReadAll(response.Body)
-			body = string(binary)
+		return u.wrap(method, err)
+	}
+
+	if c.enabled(LevelTrace) {
+		data, err := ioutil.ReadAll(response.Body)
+		if err != nil {
+			return u.wrap(method, err)
 		}
-		dbgerr("ERROR!", err, err.Error(), "Request:", body, " Response:", body)
-		return err
+		c.log(LevelTrace, "response body", "method", method, "url", redactedURL(&u.URL), "body", string(data))
+		response.Body = ioutil.NopCloser(bytes.NewReader(data))
 	}
-	dbg("response:", response)
 	if out != nil {
-		return json.NewDecoder(response.Body).Decode(out)
+		return u.wrap(method, json.NewDecoder(response.Body).Decode(out))
 	}
 
 	// throw it away
@@ -170,16 +192,38 @@ func (u *URL) Request(method string, body io.Reader) (response *http.Response, e
 	if body != nil {
 		byts, err = ioutil.ReadAll(body)
 		if err != nil {
-			return nil, err
+			return nil, u.wrap(method, err)
 		}
 	}
-	return u.req(method, bytes.NewReader(byts))
+	response, err = u.req(method, bytes.NewReader(byts))
+	return response, u.wrap(method, err)
 }
 
 var MaxRequestRetries = 5
 
 func (u *URL) req(method string, body io.ReadSeeker) (response *http.Response, err error) {
-	request, err := http.NewRequest(method, u.URL.String(), nil)
+	c := u.client()
+	ctx := u.context()
+	reqCtx := ctx
+	if c.Retry.Deadline > 0 {
+		// the deadline bounds the tries and the backoffs between them, ctx,
+		// but not the reading of the returned body, so the requests get a
+		// context that is cancelled with ctx only until then
+		var cancel, cancelTries context.CancelFunc
+		reqCtx, cancel = context.WithCancel(reqCtx)
+		ctx, cancelTries = context.WithTimeout(reqCtx, c.Retry.Deadline)
+		stop := context.AfterFunc(ctx, cancel)
+		defer func() {
+			stop()
+			cancelTries()
+			if err != nil {
+				cancel()
+			} else {
+				response.Body = &cancelOnClose{response.Body, cancel}
+			}
+		}()
+	}
+	request, err := http.NewRequestWithContext(reqCtx, method, u.URL.String(), nil)
 	if err != nil {
 		return nil, err
 	}
@@ -190,6 +234,9 @@ func (u *URL) req(method string, body io.ReadSeeker) (response *http.Response, e
 	}); ok {
 		request.ContentLength = int64(s.Len())
 	}
+	if c.MaxBodySize > 0 && request.ContentLength > c.MaxBodySize {
+		return nil, &TooLargeError{Limit: "body size", Size: request.ContentLength, Max: c.MaxBodySize}
+	}
 	request.Header.Set("Authorization", "OAuth "+u.Settings.Token)
 	request.Header.Set("Accept", "application/json")
 	request.Header.Set("Accept-Encoding", "gzip/deflate")
@@ -200,6 +247,22 @@ func (u *URL) req(method string, body io.ReadSeeker) (response *http.Response, e
 	} else if body != nil {
 		request.Header.Set("Content-Type", "application/json")
 	}
+	for _, h := range []http.Header{c.Header, u.header} {
+		for k, v := range h {
+			request.Header[http.CanonicalHeaderKey(k)] = append([]string(nil), v...)
+		}
+	}
+
+	if c.UploadRate > 0 && body != nil {
+		body = newThrottledBody(ctx, body, &c.up, c.UploadRate)
+	}
+	if u.onUpload != nil && body != nil {
+		total := request.ContentLength
+		if total <= 0 {
+			total = -1
+		}
+		body = newProgressBody(body, total, u.onUpload)
+	}
 
 	if rc, ok := body.(io.ReadCloser); ok { // stdlib doesn't have ReadSeekCloser :(
 		request.Body = rc
@@ -207,25 +270,97 @@ func (u *URL) req(method string, body io.ReadSeeker) (response *http.Response, e
 		request.Body = ioutil.NopCloser(body)
 	}
 
-	dbg("URL:", request.URL.String())
-	dbg("request:", fmt.Sprintf("%#v\n", request))
+	c.log(LevelTrace, "request headers", "method", method, "url", redactedURL(request.URL), "header", redactedHeader(request.Header))
 
-	for tries := 0; tries < MaxRequestRetries; tries++ {
+	var cached *cachedResponse
+	if c.ResponseCache != nil && method == "GET" {
+		cached = c.ResponseCache.prepare(request)
+	}
+
+	start, tries, hit := time.Now(), 0, false
+	defer func() {
+		stats := RequestStats{
+			Method:   method,
+			Host:     request.URL.Host,
+			Path:     request.URL.Path,
+			Tries:    tries,
+			Cached:   hit,
+			Duration: time.Since(start),
+			Err:      err,
+		}
+		if response != nil {
+			stats.StatusCode = response.StatusCode
+		} else if e, ok := err.(HTTPResponseError); ok {
+			stats.StatusCode = e.StatusCode()
+		}
+		c.logRequest(request.URL, stats)
+		if c.Metrics != nil {
+			c.Metrics.ObserveRequest(stats)
+		}
+	}()
+
+	// failing over changes only the address, the Host header and the TLS
+	// server name stay those of u
+	hosts := c.hosts(u)
+	request.URL.Host, request.Host = hosts[0], u.URL.Host
+	for tries < c.Retry.maxRetries() {
+		tries++
 		body.Seek(0, 0) // set back to beginning for retries
-		response, err = HttpClient.Do(request)
+		var release func()
+		if release, err = c.acquire(ctx, hosts[0]); err != nil {
+			return nil, err
+		}
+		sent := time.Now()
+		response, err = c.send(request, body)
+		if err != nil {
+			release()
+		} else {
+			response.Body = &releaseOnClose{response.Body, release}
+			c.observeSkew(response, sent)
+		}
+		if len(hosts) > 1 && tries < c.Retry.maxRetries() && hostFailed(ctx, request, response, err) {
+			failed := hosts[0]
+			c.markHost(failed, false)
+			hosts = append(hosts[1:], failed)
+			request.URL.Host = hosts[0]
+			if response != nil && response.Body != nil {
+				response.Body.Close()
+			}
+			c.log(slog.LevelWarn, "failing over", "method", method, "url", redactedURL(request.URL), "from", failed, "try", tries, "err", err)
+			continue
+		}
 		if err != nil {
 			if response != nil && response.Body != nil {
 				response.Body.Close() // make sure to close since we won't return it
 			}
 			if err == io.EOF {
+				c.log(slog.LevelWarn, "retrying request", "method", method, "url", redactedURL(request.URL), "try", tries, "err", err)
 				continue
 			}
+			if tries < c.Retry.maxRetries() && retryable(request, err) {
+				c.log(slog.LevelWarn, "retrying request", "method", method, "url", redactedURL(request.URL), "try", tries, "err", err)
+				if pause(ctx, c.Retry.backoff(tries-1)) {
+					continue
+				}
+			}
 			return nil, err
 		}
 
-		if response.StatusCode == http.StatusServiceUnavailable {
-			delay := (tries + 1) * 10 // smooth out delays from 0-2
-			time.Sleep(time.Duration(delay*delay) * time.Millisecond)
+		if response.StatusCode == http.StatusServiceUnavailable || gatewayFailed(response) && idempotent(request) {
+			if tries >= c.Retry.maxRetries() {
+				break // out of tries, the failure is returned as is
+			}
+			backoff := c.Retry.backoff(tries - 1)
+			if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < backoff {
+				break // no time left to try again
+			}
+			c.log(slog.LevelWarn, "retrying request", "method", method, "url", redactedURL(request.URL), "try", tries, "status", response.StatusCode)
+			response.Body.Close() // gives back its slot while backing off
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
 			continue
 		}
 
@@ -236,10 +371,22 @@ func (u *URL) req(method string, body io.ReadSeeker) (response *http.Response, e
 		return nil, err
 	}
 
+	if c.ResponseCache != nil && method == "GET" {
+		if response, hit, err = c.ResponseCache.update(request, response, cached); err != nil {
+			return nil, err
+		}
+	}
+
 	if err = ResponseAsError(response); err != nil {
 		return nil, err
 	}
 
+	if c.DownloadRate > 0 {
+		response.Body = &throttledResponse{throttledReader{response.Body, reqCtx, &c.down, c.DownloadRate}, response.Body}
+	}
+	if u.onDownload != nil {
+		response.Body = &progressReader{ReadCloser: response.Body, fn: u.onDownload, p: Progress{Total: response.ContentLength}}
+	}
 	return response, nil
 }
 
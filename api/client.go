@@ -0,0 +1,192 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/iron-io/iron_go3/config"
+)
+
+// A Client holds what requests to the iron.io APIs share: the HTTP
+// transport, the retry policy, a logger and a metrics sink. URLs made with
+// a Client's Action methods are requested with it, URLs made with the
+// package level functions (or a nil *Client) use DefaultClient.
+//
+// A Client must not be changed once it is in use.
+type Client struct {
+	// HTTPClient makes the requests, HttpClient if nil.
+	HTTPClient *http.Client
+	// Retry decides how requests are retried.
+	Retry RetryPolicy
+	// Logger receives the client's log records, if nil they are written to
+	// stderr.
+	Logger *slog.Logger
+	// LogLevel is the lowest level logged, the package's LogLevel if nil.
+	LogLevel slog.Leveler
+	// Metrics, if set, is told about every request.
+	Metrics Metrics
+	// Header holds headers added to every request, after the default ones,
+	// replacing them. A URL's Header replaces them in turn.
+	Header http.Header
+	// HostCooldown is how long requests avoid a host of Settings.Hosts,
+	// or the main Host, after it failed, 30 seconds by default.
+	HostCooldown time.Duration
+	// HedgeAfter, if set, hedges GET requests: when one hasn't got a
+	// response within HedgeAfter it is sent again, the first response is
+	// used and the other request cancelled. Set it around the p95 latency
+	// to cut the tail latency of reads for a few percent more requests.
+	HedgeAfter time.Duration
+	// ResponseCache, if set, makes GET requests conditional on the
+	// responses it keeps.
+	ResponseCache *ResponseCache
+	// MaxBodySize, if set, is the most bytes a request body may have.
+	// Larger requests fail with a *TooLargeError without being sent.
+	MaxBodySize int64
+	// MaxConcurrent and MaxConcurrentPerHost, if set, cap the requests in
+	// flight, in all and to each host. Requests over them wait for one to
+	// finish, its response body to be closed, or for their context to be
+	// done. A hedged request counts once.
+	MaxConcurrent        int
+	MaxConcurrentPerHost int
+	// MaxClockSkew is how far the local clock may be off the servers',
+	// see ClockSkew, before the client warns, 5 seconds by default.
+	MaxClockSkew time.Duration
+	// OnClockSkew, if set, is called with the skew when it grows over
+	// MaxClockSkew.
+	OnClockSkew func(skew time.Duration)
+	// UploadRate and DownloadRate, if set, cap the bytes per second the
+	// client sends in request bodies and reads from response bodies, over
+	// all its requests, so bulk transfers leave bandwidth to the rest of
+	// the network.
+	UploadRate   int64
+	DownloadRate int64
+
+	health  hostHealth
+	limiter limiter
+	skew    clockSkew
+	up      rateLimiter
+	down    rateLimiter
+}
+
+// DefaultClient is the Client used for URLs not made by a Client. Its zero
+// fields fall back to the package level HttpClient and MaxRequestRetries.
+var DefaultClient = &Client{}
+
+// A RetryPolicy decides how often and when failed requests are retried.
+// Requests that failed with io.EOF or 503 Service Unavailable are retried,
+// and idempotent ones, see IdempotencyKeyHeader, that failed in transit,
+// e.g. with a connection reset, or with 502 Bad Gateway or 504 Gateway
+// Timeout.
+type RetryPolicy struct {
+	// MaxRetries is the number of attempts made, MaxRequestRetries if 0.
+	MaxRetries int
+	// Backoff returns how long to wait before the try (counting from 0)
+	// after a retried failure. It defaults to a few milliseconds growing quadratically.
+	Backoff func(try int) time.Duration
+	// Deadline, if set, is the time a request may take over all its tries
+	// and the backoffs between them, rather than each try's. The request is
+	// cancelled when it runs out, and not retried when the backoff would
+	// outlast it, so a call takes at most about Deadline until it gets a
+	// response. Reading the response body isn't bounded by it, use the
+	// URL's context for that.
+	Deadline time.Duration
+}
+
+func (p RetryPolicy) maxRetries() int {
+	if p.MaxRetries > 0 {
+		return p.MaxRetries
+	}
+	return MaxRequestRetries
+}
+
+func (p RetryPolicy) backoff(try int) time.Duration {
+	if p.Backoff != nil {
+		return p.Backoff(try)
+	}
+	delay := (try + 1) * 10 // smooth out delays from 0-2
+	return time.Duration(delay*delay) * time.Millisecond
+}
+
+// pause waits for d before a retry, or until ctx is done. It returns false
+// if ctx is done first, or would be according to its deadline.
+func pause(ctx context.Context, d time.Duration) bool {
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < d {
+		return false
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// Metrics is told about each request a Client makes, after its last try.
+type Metrics interface {
+	ObserveRequest(RequestStats)
+}
+
+// RequestStats describe a finished request.
+type RequestStats struct {
+	Method string
+	Host   string
+	Path   string
+	// StatusCode is 0 if no response was received.
+	StatusCode int
+	// Tries is the number of attempts made.
+	Tries    int
+	Duration time.Duration
+	Err      error
+	// Cached tells the response came from the Client's ResponseCache,
+	// the server answering 304 Not Modified.
+	Cached bool
+}
+
+// Action is like the package level Action, but the URL is requested with c.
+func (c *Client) Action(cs config.Settings, prefix string, suffix ...string) *URL {
+	parts := append([]string{prefix}, suffix...)
+	return c.ActionEndpoint(cs, strings.Join(parts, "/"))
+}
+
+// RootAction is like the package level RootAction, but the URL is
+// requested with c.
+func (c *Client) RootAction(cs config.Settings, prefix string, suffix ...string) *URL {
+	parts := append([]string{prefix}, suffix...)
+	return c.RootActionEndpoint(cs, strings.Join(parts, "/"))
+}
+
+// ActionEndpoint is like the package level ActionEndpoint, but the URL is
+// requested with c.
+func (c *Client) ActionEndpoint(cs config.Settings, endpoint string) *URL {
+	u := ActionEndpoint(cs, endpoint)
+	u.Client = c
+	return u
+}
+
+// RootActionEndpoint is like the package level RootActionEndpoint, but the
+// URL is requested with c.
+func (c *Client) RootActionEndpoint(cs config.Settings, endpoint string) *URL {
+	u := RootActionEndpoint(cs, endpoint)
+	u.Client = c
+	return u
+}
+
+// VersionAction is like the package level VersionAction, but the URL is
+// requested with c.
+func (c *Client) VersionAction(cs config.Settings) *URL {
+	u := VersionAction(cs)
+	u.Client = c
+	return u
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return HttpClient
+}
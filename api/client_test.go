@@ -0,0 +1,143 @@
+package api
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iron-io/iron_go3/config"
+	. "github.com/jeffh/go.bdd"
+)
+
+type recordedStats []RequestStats
+
+func (r *recordedStats) ObserveRequest(s RequestStats) { *r = append(*r, s) }
+
+func testSettings(url string) config.Settings {
+	host, port, _ := net.SplitHostPort(strings.TrimPrefix(url, "http://"))
+	p, _ := strconv.Atoi(port)
+	return config.Settings{
+		Token:      "token",
+		ProjectId:  "project",
+		Host:       host,
+		Port:       uint16(p),
+		Scheme:     "http",
+		ApiVersion: "3",
+	}
+}
+
+func TestClient(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("api clients", func() {
+		hits := 0
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits++
+			if r.URL.Path == "/3/projects/project/busy" {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				w.Write([]byte(`{"msg":"busy"}`))
+				return
+			}
+			w.Write([]byte(`{"msg":"ok"}`))
+		}))
+		defer srv.Close()
+		s := testSettings(srv.URL)
+
+		It("makes requests with its HTTP client", func() {
+			used := false
+			hc := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+				used = true
+				return http.DefaultTransport.RoundTrip(r)
+			})}
+			c := &Client{HTTPClient: hc}
+			var out DefaultResponseBody
+			Expect(c.Action(s, "ok").Req("GET", nil, &out), ToBeNil)
+			Expect(out.Msg, ToEqual, "ok")
+			Expect(used, ToBeTrue)
+		})
+
+		It("follows its retry policy", func() {
+			hits = 0
+			var backoffs []int
+			c := &Client{Retry: RetryPolicy{MaxRetries: 3, Backoff: func(try int) time.Duration {
+				backoffs = append(backoffs, try)
+				return 0
+			}}}
+			err := c.Action(s, "busy").Req("GET", nil, nil)
+			Expect(err, ToNotBeNil)
+			Expect(hits, ToEqual, 3)
+			Expect(backoffs, ToDeepEqual, []int{0, 1})
+		})
+
+		It("fits the tries in its retry deadline", func() {
+			c := &Client{Retry: RetryPolicy{MaxRetries: 100, Deadline: 200 * time.Millisecond, Backoff: func(int) time.Duration {
+				return 50 * time.Millisecond
+			}}}
+			start := time.Now()
+			err := c.Action(s, "busy").Req("GET", nil, nil)
+			Expect(StatusCode(err), ToEqual, http.StatusServiceUnavailable)
+			Expect(time.Since(start) < 300*time.Millisecond, ToBeTrue)
+
+			var out DefaultResponseBody
+			Expect(c.Action(s, "ok").Req("GET", nil, &out), ToBeNil)
+			Expect(out.Msg, ToEqual, "ok")
+		})
+
+		It("doesn't cut slow bodies at its retry deadline", func() {
+			slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`{"msg": `))
+				w.(http.Flusher).Flush()
+				time.Sleep(200 * time.Millisecond)
+				w.Write([]byte(`"slow"}`))
+			}))
+			defer slow.Close()
+			c := &Client{Retry: RetryPolicy{Deadline: 100 * time.Millisecond}}
+			var out DefaultResponseBody
+			Expect(c.Action(testSettings(slow.URL), "slow").Req("GET", nil, &out), ToBeNil)
+			Expect(out.Msg, ToEqual, "slow")
+		})
+
+		It("reports requests to its metrics", func() {
+			var stats recordedStats
+			c := &Client{Metrics: &stats, Retry: RetryPolicy{MaxRetries: 2, Backoff: func(int) time.Duration { return 0 }}}
+			c.Action(s, "ok").Req("GET", nil, nil)
+			c.Action(s, "busy").Req("POST", nil, nil)
+
+			Expect(len(stats), ToEqual, 2)
+			Expect(stats[0].Method, ToEqual, "GET")
+			Expect(stats[0].Path, ToEqual, "/3/projects/project/ok")
+			Expect(stats[0].StatusCode, ToEqual, 200)
+			Expect(stats[0].Tries, ToEqual, 1)
+			Expect(stats[1].StatusCode, ToEqual, 503)
+			Expect(stats[1].Tries, ToEqual, 2)
+			Expect(stats[1].Err, ToNotBeNil)
+		})
+
+		It("adds the client's and the request's headers", func() {
+			var header http.Header
+			hc := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+				header = r.Header
+				return http.DefaultTransport.RoundTrip(r)
+			})}
+			c := &Client{HTTPClient: hc, Header: http.Header{"X-Tenant": {"acme"}, "X-Trace": {"client"}}}
+			Expect(c.Action(s, "ok").Header("x-trace", "request").Req("GET", nil, nil), ToBeNil)
+			Expect(header.Get("X-Tenant"), ToEqual, "acme")
+			Expect(header["X-Trace"], ToDeepEqual, []string{"request"})
+			Expect(header.Get("Authorization"), ToEqual, "OAuth token")
+		})
+
+		It("leaves package level URLs to the default client", func() {
+			Expect(Action(s, "ok").client(), ToEqual, DefaultClient)
+			var c *Client
+			Expect(c.Action(s, "ok").client(), ToEqual, DefaultClient)
+		})
+	})
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
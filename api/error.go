@@ -0,0 +1,219 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// An Error is returned for a failed request. It wraps the cause, a
+// transport error, a JSON error or an HTTPResponseError, so it can be
+// inspected with errors.Is and errors.As.
+type Error struct {
+	Method string
+	URL    string
+	Err    error
+}
+
+func (e *Error) Error() string {
+	return e.Method + " " + endpoint(e.URL) + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// Temporary tells whether the request failed for a reason expected to go
+// away by itself: the server being overloaded, unavailable or behind an
+// unhealthy load balancer, or a network failure or timeout.
+func (e *Error) Temporary() bool {
+	switch StatusCode(e.Err) {
+	case 0:
+		return transient(e.Err)
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// Retryable tells whether the request may be retried as is: it failed for
+// a Temporary reason, and either it is idempotent or it surely wasn't
+// processed, being refused before it was read or never sent.
+func (e *Error) Retryable() bool {
+	if !e.Temporary() {
+		return false
+	}
+	switch e.Method {
+	case "GET", "HEAD", "PUT", "DELETE":
+		return true
+	}
+	switch StatusCode(e.Err) {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return true
+	}
+	var op *net.OpError
+	return errors.As(e.Err, &op) && op.Op == "dial"
+}
+
+// IdempotencyKeyHeader is the header of requests that are safe to repeat
+// although their method isn't, the server applying them once per key. Set
+// it with URL.Header to have such requests retried like GETs when they
+// fail in transit.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// idempotent tells whether request may be sent again without changing its
+// effect.
+func idempotent(request *http.Request) bool {
+	switch request.Method {
+	case "GET", "HEAD", "PUT", "DELETE", "OPTIONS":
+		return true
+	}
+	return request.Header.Get(IdempotencyKeyHeader) != ""
+}
+
+// retryable tells whether request, which failed with the transport error
+// err, may be tried again: err is transient and the request idempotent or
+// never sent.
+func retryable(request *http.Request, err error) bool {
+	if request.Context().Err() != nil || !transient(err) {
+		return false
+	}
+	var op *net.OpError
+	return idempotent(request) || errors.As(err, &op) && op.Op == "dial"
+}
+
+// gatewayFailed tells whether a load balancer answered res for a server
+// that failed or didn't answer in time.
+func gatewayFailed(res *http.Response) bool {
+	return res.StatusCode == http.StatusBadGateway || res.StatusCode == http.StatusGatewayTimeout
+}
+
+// transient tells whether the transport error err, e.g. a connection reset
+// or broken pipe, is expected to go away.
+func transient(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	// the HTTP/2 error types are unexported
+	if strings.Contains(err.Error(), "http2: server sent GOAWAY") {
+		return true
+	}
+	var dns *net.DNSError
+	if errors.As(err, &dns) {
+		return dns.IsTemporary || dns.IsTimeout
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		return true
+	}
+	var op *net.OpError
+	return errors.As(err, &op)
+}
+
+// A RetryableError classifies the failure of a request for retry loops.
+// Error and TooLargeError implement it, so every error of a request does,
+// or wraps one that does; see IsRetryable and IsTemporary.
+type RetryableError interface {
+	error
+	// Retryable tells whether the request may be retried as is.
+	Retryable() bool
+	// Temporary tells whether the cause is expected to go away by itself.
+	Temporary() bool
+}
+
+// IsRetryable tells whether the request err was returned for may be
+// retried as is, false if err doesn't wrap a RetryableError.
+func IsRetryable(err error) bool {
+	var re RetryableError
+	return errors.As(err, &re) && re.Retryable()
+}
+
+// IsTemporary tells whether the cause of err is expected to go away by
+// itself, false if err doesn't wrap a RetryableError.
+func IsTemporary(err error) bool {
+	var re RetryableError
+	return errors.As(err, &re) && re.Temporary()
+}
+
+// StatusCode returns the status of the response the request failed with,
+// 0 if it failed before one was received. With it Error implements
+// HTTPResponseError.
+func (e *Error) StatusCode() int { return StatusCode(e.Err) }
+
+// StatusCode returns the HTTP status of the response err was caused by, 0
+// if there is none.
+func StatusCode(err error) int {
+	var e resErr
+	if errors.As(err, &e) {
+		return e.statusCode
+	}
+	var he HTTPResponseError
+	if errors.As(err, &he) {
+		return he.StatusCode()
+	}
+	return 0
+}
+
+func (u *URL) wrap(method string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if ue, ok := err.(*url.Error); ok {
+		err = ue.Err // drop the method and URL repeated by net/http
+	}
+	return &Error{Method: method, URL: u.URL.String(), Err: err}
+}
+
+// endpoint shortens rawurl to the part after the project, e.g.
+// queues/jobs/messages, for error messages.
+func endpoint(rawurl string) string {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return rawurl
+	}
+	path := u.Path
+	if i := strings.Index(path, "/projects/"); i >= 0 {
+		path = path[i+len("/projects/"):]
+		if j := strings.Index(path, "/"); j >= 0 {
+			path = path[j+1:]
+		}
+	} else {
+		path = strings.TrimPrefix(path, "/")
+	}
+	return path
+}
+
+// ErrTooLarge matches, with errors.Is, the TooLargeErrors of requests
+// refused before being sent.
+var ErrTooLarge = errors.New("request too large")
+
+// A TooLargeError is returned for a request that exceeds a limit of the
+// client, or of the API it is made to, before any of it is sent.
+type TooLargeError struct {
+	// Limit names the limit exceeded, e.g. "body size" or "message size".
+	Limit string
+	Size  int64
+	Max   int64
+}
+
+func (e *TooLargeError) Error() string {
+	return fmt.Sprintf("%s %d exceeds the limit of %d", e.Limit, e.Size, e.Max)
+}
+
+func (e *TooLargeError) Is(target error) bool { return target == ErrTooLarge }
+
+// Retryable and Temporary return false, the request being too large to
+// ever succeed.
+func (e *TooLargeError) Retryable() bool { return false }
+func (e *TooLargeError) Temporary() bool { return false }
+
+// StatusCode returns 413 Request Entity Too Large, the status the server
+// refuses such requests with, so they are handled as refused, e.g. not
+// retried.
+func (e *TooLargeError) StatusCode() int { return http.StatusRequestEntityTooLarge }
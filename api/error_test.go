@@ -0,0 +1,127 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestErrors(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("request errors", func() {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/3/projects/project/queues/missing":
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte(`{"msg":"Queue not found"}`))
+			default:
+				w.Write([]byte(`not json`))
+			}
+		}))
+		defer srv.Close()
+		s := testSettings(srv.URL)
+
+		It("wrap error responses with the request", func() {
+			err := Action(s, "queues", "missing").Req("GET", nil, nil)
+			Expect(err.Error(), ToEqual, "GET queues/missing: 404 Not Found: Queue not found")
+			Expect(StatusCode(err), ToEqual, http.StatusNotFound)
+
+			var e *Error
+			Expect(errors.As(err, &e), ToBeTrue)
+			Expect(e.Method, ToEqual, "GET")
+			Expect(e.URL, ToEqual, srv.URL+"/3/projects/project/queues/missing")
+
+			var he HTTPResponseError
+			Expect(errors.As(errors.Unwrap(err), &he), ToBeTrue)
+			Expect(he.StatusCode(), ToEqual, http.StatusNotFound)
+		})
+
+		It("wrap undecodable responses", func() {
+			var out struct{}
+			err := Action(s, "queues", "q").Req("GET", nil, &out)
+			var syntax *json.SyntaxError
+			Expect(errors.As(err, &syntax), ToBeTrue)
+			Expect(StatusCode(err), ToEqual, 0)
+		})
+
+		It("wrap transport errors", func() {
+			closed := httptest.NewServer(http.NotFoundHandler())
+			closed.Close()
+			once := &Client{Retry: RetryPolicy{MaxRetries: 1}}
+			err := once.Action(testSettings(closed.URL), "queues", "q").Req("GET", nil, nil)
+			var op *net.OpError
+			Expect(errors.As(err, &op), ToBeTrue)
+			Expect(StatusCode(err), ToEqual, 0)
+		})
+
+		It("refuse bodies over the client's limit", func() {
+			c := &Client{MaxBodySize: 10}
+			err := c.Action(s, "queues", "q").Req("POST", map[string]string{"body": "too large"}, nil)
+			Expect(err.Error(), ToEqual, "POST queues/q: body size 20 exceeds the limit of 10")
+			Expect(errors.Is(err, ErrTooLarge), ToBeTrue)
+			Expect(StatusCode(err), ToEqual, http.StatusRequestEntityTooLarge)
+		})
+	})
+}
+
+func TestRetryableErrors(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("error classification", func() {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/3/projects/project/queues/busy":
+				w.WriteHeader(http.StatusServiceUnavailable)
+			case "/3/projects/project/queues/gateway":
+				w.WriteHeader(http.StatusGatewayTimeout)
+			case "/3/projects/project/queues/missing":
+				w.WriteHeader(http.StatusNotFound)
+			default:
+				w.Write([]byte(`not json`))
+			}
+			w.Write([]byte(`{"msg":"failed"}`))
+		}))
+		defer srv.Close()
+		s := testSettings(srv.URL)
+		c := &Client{Retry: RetryPolicy{MaxRetries: 1}}
+		classify := func(err error) [2]bool { return [2]bool{IsRetryable(err), IsTemporary(err)} }
+
+		It("retries unavailable servers", func() {
+			Expect(classify(c.Action(s, "queues", "busy").Req("POST", nil, nil)), ToEqual, [2]bool{true, true})
+		})
+
+		It("doesn't retry requests that may have been processed", func() {
+			Expect(classify(c.Action(s, "queues", "gateway").Req("GET", nil, nil)), ToEqual, [2]bool{true, true})
+			Expect(classify(c.Action(s, "queues", "gateway").Req("POST", nil, nil)), ToEqual, [2]bool{false, true})
+		})
+
+		It("doesn't retry refused requests", func() {
+			Expect(classify(c.Action(s, "queues", "missing").Req("GET", nil, nil)), ToEqual, [2]bool{false, false})
+			var out struct{}
+			Expect(classify(c.Action(s, "queues", "q").Req("GET", nil, &out)), ToEqual, [2]bool{false, false})
+			big := &Client{MaxBodySize: 1}
+			Expect(classify(big.Action(s, "queues", "q").Req("POST", nil, nil)), ToEqual, [2]bool{false, false})
+		})
+
+		It("retries requests that couldn't be sent", func() {
+			closed := httptest.NewServer(http.NotFoundHandler())
+			closed.Close()
+			err := c.Action(testSettings(closed.URL), "queues", "q").Req("POST", nil, nil)
+			Expect(classify(err), ToEqual, [2]bool{true, true})
+		})
+
+		It("doesn't retry cancelled requests", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			err := c.Action(s, "queues", "q").WithContext(ctx).Req("GET", nil, nil)
+			Expect(classify(err), ToEqual, [2]bool{false, false})
+		})
+	})
+}
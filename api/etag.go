@@ -0,0 +1,198 @@
+package api
+
+import (
+	"bytes"
+	"container/list"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+)
+
+// A ResponseCache keeps the responses to GET requests that carry an ETag
+// or Last-Modified header, and makes the next requests for the same URL
+// conditional. When the server answers 304 Not Modified the kept response
+// is returned instead, saving the transfer and decoding of unchanged
+// resources, e.g. when polling queue info or code listings. Set it as a
+// Client's ResponseCache. It is safe for concurrent use.
+type ResponseCache struct {
+	// MaxEntries is the most responses kept, the least recently used are
+	// dropped first, 1000 by default.
+	MaxEntries int
+	// MaxBodySize is the largest response body kept, in bytes, 1 MiB by
+	// default. Larger responses, e.g. downloads, are streamed through
+	// without being buffered.
+	MaxBodySize int64
+
+	hits, misses atomic.Int64
+
+	mu      sync.Mutex
+	lru     *list.List
+	entries map[string]*list.Element
+}
+
+// ResponseCacheStats counts the conditional requests of a ResponseCache.
+type ResponseCacheStats struct {
+	// Hits are the requests answered 304 Not Modified, served from the
+	// cache, Misses those that got a new response.
+	Hits   int64
+	Misses int64
+	// Entries is the number of responses kept.
+	Entries int
+}
+
+type cachedResponse struct {
+	key          string
+	etag         string
+	lastModified string
+	status       int
+	header       http.Header
+	body         []byte
+}
+
+// NewResponseCache returns a ResponseCache keeping up to maxEntries
+// responses.
+func NewResponseCache(maxEntries int) *ResponseCache {
+	return &ResponseCache{MaxEntries: maxEntries}
+}
+
+// Stats returns the cache's counters.
+func (rc *ResponseCache) Stats() ResponseCacheStats {
+	rc.mu.Lock()
+	n := len(rc.entries)
+	rc.mu.Unlock()
+	return ResponseCacheStats{Hits: rc.hits.Load(), Misses: rc.misses.Load(), Entries: n}
+}
+
+// cacheKey is the key of request. It leaves out the host, which may change
+// on failover, and has the token so projects sharing a client don't see
+// each other's responses.
+func cacheKey(request *http.Request) string {
+	return request.Header.Get("Authorization") + " " + request.URL.RequestURI()
+}
+
+// prepare makes request conditional if a response to it is kept, and
+// returns that response.
+func (rc *ResponseCache) prepare(request *http.Request) *cachedResponse {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	e, ok := rc.entries[cacheKey(request)]
+	if !ok {
+		return nil
+	}
+	rc.lru.MoveToFront(e)
+	cached := e.Value.(*cachedResponse)
+	if cached.etag != "" {
+		request.Header.Set("If-None-Match", cached.etag)
+	}
+	if cached.lastModified != "" {
+		request.Header.Set("If-Modified-Since", cached.lastModified)
+	}
+	return cached
+}
+
+// update returns the response to use for request, the cached one if the
+// server answered res with 304 Not Modified, and keeps new responses that
+// can be validated. It reports whether the cached response was used.
+func (rc *ResponseCache) update(request *http.Request, res *http.Response, cached *cachedResponse) (*http.Response, bool, error) {
+	if res.StatusCode == http.StatusNotModified && cached != nil {
+		rc.hits.Add(1)
+		io.Copy(io.Discard, res.Body)
+		res.Body.Close()
+		return cached.response(request), true, nil
+	}
+	rc.misses.Add(1)
+
+	etag, lastModified := res.Header.Get("ETag"), res.Header.Get("Last-Modified")
+	if res.StatusCode != http.StatusOK || etag == "" && lastModified == "" {
+		return res, false, nil
+	}
+	key, limit := cacheKey(request), rc.maxBodySize()
+	if res.ContentLength > limit {
+		rc.remove(key)
+		return res, false, nil
+	}
+	body, err := io.ReadAll(io.LimitReader(res.Body, limit+1))
+	if err != nil {
+		res.Body.Close()
+		return nil, false, err
+	}
+	if int64(len(body)) > limit {
+		// too large to keep, hand back what was read and the rest
+		rc.remove(key)
+		res.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), res.Body), res.Body}
+		return res, false, nil
+	}
+	res.Body.Close()
+	res.Body = io.NopCloser(bytes.NewReader(body))
+	rc.put(&cachedResponse{
+		key:          key,
+		etag:         etag,
+		lastModified: lastModified,
+		status:       res.StatusCode,
+		header:       res.Header.Clone(),
+		body:         body,
+	})
+	return res, false, nil
+}
+
+func (rc *ResponseCache) put(cached *cachedResponse) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.entries == nil {
+		rc.lru, rc.entries = list.New(), map[string]*list.Element{}
+	}
+	if e, ok := rc.entries[cached.key]; ok {
+		e.Value = cached
+		rc.lru.MoveToFront(e)
+		return
+	}
+	rc.entries[cached.key] = rc.lru.PushFront(cached)
+	for rc.lru.Len() > rc.maxEntries() {
+		oldest := rc.lru.Back()
+		rc.lru.Remove(oldest)
+		delete(rc.entries, oldest.Value.(*cachedResponse).key)
+	}
+}
+
+// remove drops the response kept for key, if any.
+func (rc *ResponseCache) remove(key string) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if e, ok := rc.entries[key]; ok {
+		rc.lru.Remove(e)
+		delete(rc.entries, key)
+	}
+}
+
+func (rc *ResponseCache) maxBodySize() int64 {
+	if rc.MaxBodySize > 0 {
+		return rc.MaxBodySize
+	}
+	return 1 << 20
+}
+
+func (rc *ResponseCache) maxEntries() int {
+	if rc.MaxEntries > 0 {
+		return rc.MaxEntries
+	}
+	return 1000
+}
+
+func (c *cachedResponse) response(request *http.Request) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", c.status, http.StatusText(c.status)),
+		StatusCode:    c.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        c.header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(c.body)),
+		ContentLength: int64(len(c.body)),
+		Request:       request,
+	}
+}
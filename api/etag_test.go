@@ -0,0 +1,88 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestResponseCache(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("response caches", func() {
+		var version, sent int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			v := atomic.LoadInt32(&version)
+			etag := fmt.Sprintf(`"v%d"`, v)
+			w.Header().Set("ETag", etag)
+			if r.Header.Get("If-None-Match") == etag {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			atomic.AddInt32(&sent, 1)
+			fmt.Fprintf(w, `{"msg": "version %d"}`, v)
+		}))
+		defer srv.Close()
+		s := testSettings(srv.URL)
+
+		It("serves unchanged responses from the cache", func() {
+			var metrics recordedStats
+			c := &Client{ResponseCache: NewResponseCache(10), Metrics: &metrics}
+			get := func() string {
+				var out DefaultResponseBody
+				Expect(c.Action(s, "queues", "q").Req("GET", nil, &out), ToBeNil)
+				return out.Msg
+			}
+
+			Expect(get(), ToEqual, "version 0")
+			Expect(get(), ToEqual, "version 0")
+			Expect(atomic.LoadInt32(&sent), ToEqual, int32(1))
+			Expect(metrics[1].Cached, ToBeTrue)
+			Expect(metrics[1].StatusCode, ToEqual, http.StatusOK)
+
+			atomic.StoreInt32(&version, 1)
+			Expect(get(), ToEqual, "version 1")
+			Expect(c.ResponseCache.Stats(), ToEqual, ResponseCacheStats{Hits: 1, Misses: 2, Entries: 1})
+		})
+
+		It("keeps the most recently used responses", func() {
+			rc := NewResponseCache(1)
+			c := &Client{ResponseCache: rc}
+			Expect(c.Action(s, "queues", "a").Req("GET", nil, nil), ToBeNil)
+			Expect(c.Action(s, "queues", "b").Req("GET", nil, nil), ToBeNil)
+			Expect(rc.Stats().Entries, ToEqual, 1)
+		})
+
+		It("streams responses too large to keep", func() {
+			body := `{"msg": "` + strings.Repeat("x", 100) + `"}`
+			large := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("ETag", `"v0"`)
+				if r.URL.Query().Get("chunked") == "" {
+					w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+				}
+				w.Write([]byte(body))
+				w.(http.Flusher).Flush()
+			}))
+			defer large.Close()
+			rc := &ResponseCache{MaxBodySize: 50}
+			c := &Client{ResponseCache: rc}
+
+			for _, chunked := range []string{"", "1"} {
+				res, err := c.Action(testSettings(large.URL), "codes", "c", "download").QueryAdd("chunked", "%s", chunked).Request("GET", nil)
+				Expect(err, ToBeNil)
+				got, err := io.ReadAll(res.Body)
+				res.Body.Close()
+				Expect(err, ToBeNil)
+				Expect(string(got), ToEqual, body)
+			}
+			Expect(rc.Stats().Entries, ToEqual, 0)
+		})
+	})
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"time"
+)
+
+// send makes one try of request, whose body is body, hedged if it is a GET
+// and the client hedges, see HedgeAfter.
+func (c *Client) send(request *http.Request, body io.ReadSeeker) (*http.Response, error) {
+	if c.HedgeAfter <= 0 || request.Method != "GET" {
+		return c.hostClient(request).Do(request)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	return c.hedge(request, data)
+}
+
+type hedged struct {
+	i   int
+	res *http.Response
+	err error
+}
+
+// hedge sends request, and sends it again if no response came within
+// HedgeAfter. The first response wins, the other request is cancelled. If
+// a request fails while the other is in flight, the other's outcome is
+// returned.
+func (c *Client) hedge(request *http.Request, data []byte) (*http.Response, error) {
+	results := make(chan hedged, 2)
+	var cancels []context.CancelFunc
+	launch := func() {
+		ctx, cancel := context.WithCancel(request.Context())
+		cancels = append(cancels, cancel)
+		r := request.Clone(ctx)
+		r.Body = io.NopCloser(bytes.NewReader(data))
+		i := len(cancels) - 1
+		go func() {
+			res, err := c.hostClient(r).Do(r)
+			results <- hedged{i, res, err}
+		}()
+	}
+
+	launch()
+	timer := time.NewTimer(c.HedgeAfter)
+	defer timer.Stop()
+	received := 0
+	for {
+		select {
+		case <-timer.C:
+			c.log(LevelTrace, "hedging request", "method", request.Method, "url", redactedURL(request.URL))
+			launch()
+		case r := <-results:
+			received++
+			if r.err != nil && received < len(cancels) {
+				continue // the other may still succeed
+			}
+			for i, cancel := range cancels {
+				if i != r.i {
+					cancel()
+				}
+			}
+			if r.err != nil {
+				cancels[r.i]()
+			} else {
+				r.res.Body = &cancelOnClose{r.res.Body, cancels[r.i]}
+			}
+			// close the response of the loser, if it comes
+			go func(n int) {
+				for ; n > 0; n-- {
+					if l := <-results; l.res != nil {
+						l.res.Body.Close()
+					}
+				}
+			}(len(cancels) - received)
+			return r.res, r.err
+		}
+	}
+}
+
+// cancelOnClose releases the context of a hedged request once its response
+// was read.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestHedging(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("hedged requests", func() {
+		var hits int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// the first request is slow, the hedge fast
+			if atomic.AddInt32(&hits, 1) == 1 {
+				select {
+				case <-r.Context().Done():
+					return
+				case <-time.After(2 * time.Second):
+				}
+			}
+			w.Write([]byte(`{"msg":"` + r.Method + `"}`))
+		}))
+		defer srv.Close()
+		s := testSettings(srv.URL)
+		c := &Client{HedgeAfter: 20 * time.Millisecond}
+
+		It("takes the first response of a GET", func() {
+			atomic.StoreInt32(&hits, 0)
+			start := time.Now()
+			var out DefaultResponseBody
+			Expect(c.Action(s, "queues").Req("GET", nil, &out), ToBeNil)
+			Expect(out.Msg, ToEqual, "GET")
+			Expect(time.Since(start) < time.Second, ToBeTrue)
+			Expect(atomic.LoadInt32(&hits), ToEqual, int32(2))
+		})
+
+		It("doesn't hedge other requests", func() {
+			atomic.StoreInt32(&hits, 0)
+			c := &Client{HedgeAfter: 20 * time.Millisecond}
+			var out DefaultResponseBody
+			u := c.Action(s, "queues")
+			done := make(chan error, 1)
+			go func() { done <- u.Req("POST", nil, &out) }()
+			time.Sleep(100 * time.Millisecond)
+			Expect(atomic.LoadInt32(&hits), ToEqual, int32(1))
+			srv.CloseClientConnections()
+			<-done
+		})
+	})
+}
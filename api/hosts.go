@@ -0,0 +1,198 @@
+package api
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iron-io/iron_go3/config"
+)
+
+// hostHealth remembers the hosts that failed, so requests skip them for a
+// while.
+type hostHealth struct {
+	mu      sync.Mutex
+	down    map[string]time.Time // until when
+	clients map[hostClientKey]*http.Client
+}
+
+type hostClientKey struct {
+	client     *http.Client
+	serverName string
+}
+
+// hosts returns the addresses to send a request to u to, in the order to
+// try them: u's host, then the Settings' Hosts, those that failed recently
+// last.
+func (c *Client) hosts(u *URL) []string {
+	if u.Settings.Hosts == "" {
+		return []string{u.URL.Host}
+	}
+	all := append([]string{u.URL.Host}, hostAddrs(u.Settings)...)
+	now := time.Now()
+	c.health.mu.Lock()
+	defer c.health.mu.Unlock()
+	var up, down []string
+	for _, h := range all {
+		if until, ok := c.health.down[h]; ok && now.Before(until) {
+			down = append(down, h)
+		} else {
+			up = append(up, h)
+		}
+	}
+	return append(up, down...)
+}
+
+// markHost records whether the host at addr is up.
+func (c *Client) markHost(addr string, up bool) {
+	c.health.mu.Lock()
+	defer c.health.mu.Unlock()
+	if up {
+		delete(c.health.down, addr)
+		return
+	}
+	if c.health.down == nil {
+		c.health.down = map[string]time.Time{}
+	}
+	c.health.down[addr] = time.Now().Add(c.hostCooldown())
+}
+
+func (c *Client) hostCooldown() time.Duration {
+	if c.HostCooldown > 0 {
+		return c.HostCooldown
+	}
+	return 30 * time.Second
+}
+
+// CheckHosts requests /version from Host and each of the Hosts of cs, and
+// marks them up or down for the requests the client fails over. It returns
+// the errors of the hosts that failed, by address.
+func (c *Client) CheckHosts(ctx context.Context, cs config.Settings) map[string]error {
+	failed := map[string]error{}
+	for _, addr := range append([]string{hostAddr(cs, cs.Host)}, hostAddrs(cs)...) {
+		err := c.checkHost(ctx, cs.Scheme, addr)
+		c.markHost(addr, err == nil)
+		if err != nil {
+			failed[addr] = err
+		}
+	}
+	return failed
+}
+
+// WatchHosts runs CheckHosts every interval until ctx is done, so hosts
+// that failed are used again as soon as they recover and hosts that fail
+// are skipped before requests hit them.
+func (c *Client) WatchHosts(ctx context.Context, cs config.Settings, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		c.CheckHosts(ctx, cs)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (c *Client) checkHost(ctx context.Context, scheme, addr string) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", scheme+"://"+addr+"/version", nil)
+	if err != nil {
+		return err
+	}
+	res, err := c.httpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode >= 500 {
+		return fmt.Errorf("GET /version: %s", res.Status)
+	}
+	return nil
+}
+
+// hostAddrs returns the addresses of the Hosts of cs.
+func hostAddrs(cs config.Settings) []string {
+	var addrs []string
+	for _, h := range strings.Split(cs.Hosts, ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			addrs = append(addrs, hostAddr(cs, h))
+		}
+	}
+	return addrs
+}
+
+// hostAddr returns host with the port of cs, unless it has one.
+func hostAddr(cs config.Settings, host string) string {
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host
+	}
+	return net.JoinHostPort(host, strconv.Itoa(int(cs.Port)))
+}
+
+// hostFailed tells whether a request that got res or err should be tried
+// on another host: when the host couldn't be reached, or when an
+// idempotent request failed in transit or at a load balancer.
+func hostFailed(ctx context.Context, request *http.Request, res *http.Response, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if err != nil {
+		var op *net.OpError
+		var dns *net.DNSError
+		if errors.As(err, &op) && op.Op == "dial" || errors.As(err, &dns) {
+			return true
+		}
+		return idempotent(request)
+	}
+	return idempotent(request) && gatewayFailed(res)
+}
+
+// hostClient returns the http.Client to send request with. Requests failed
+// over to another address are sent with a copy of the client's transport
+// that asks for and verifies the certificate of request's Host rather than
+// of the address.
+func (c *Client) hostClient(request *http.Request) *http.Client {
+	client := c.httpClient()
+	if request.URL.Scheme != "https" || request.Host == "" || request.Host == request.URL.Host {
+		return client
+	}
+	transport := client.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	t, ok := transport.(*http.Transport)
+	if !ok {
+		return client
+	}
+	serverName := request.Host
+	if host, _, err := net.SplitHostPort(serverName); err == nil {
+		serverName = host
+	}
+
+	key := hostClientKey{client, serverName}
+	c.health.mu.Lock()
+	defer c.health.mu.Unlock()
+	if alt, ok := c.health.clients[key]; ok {
+		return alt
+	}
+	t = t.Clone()
+	if t.TLSClientConfig == nil {
+		t.TLSClientConfig = &tls.Config{}
+	}
+	t.TLSClientConfig.ServerName = serverName
+	alt := *client
+	alt.Transport = t
+	if c.health.clients == nil {
+		c.health.clients = map[hostClientKey]*http.Client{}
+	}
+	c.health.clients[key] = &alt
+	return &alt
+}
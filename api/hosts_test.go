@@ -0,0 +1,136 @@
+package api
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/iron-io/iron_go3/config"
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestHostFailover(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("host failover", func() {
+		var gateway int32 // respond 502 from the main host
+		var mainHits, altHits int32
+		var altHost atomic.Value
+		main := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&mainHits, 1)
+			if atomic.LoadInt32(&gateway) == 1 {
+				w.WriteHeader(http.StatusBadGateway)
+				w.Write([]byte(`{"msg":"bad gateway"}`))
+				return
+			}
+			w.Write([]byte(`{"msg":"main"}`))
+		}))
+		defer main.Close()
+		alt := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&altHits, 1)
+			altHost.Store(r.Host)
+			w.Write([]byte(`{"msg":"alt"}`))
+		}))
+		defer alt.Close()
+
+		// an address nothing listens on
+		l, _ := net.Listen("tcp", "127.0.0.1:0")
+		dead := l.Addr().String()
+		l.Close()
+
+		get := func(c *Client, s config.Settings, method string) (string, error) {
+			var out DefaultResponseBody
+			err := c.Action(s, "queues").Req(method, nil, &out)
+			return out.Msg, err
+		}
+
+		It("fails over when the host can't be reached", func() {
+			c := &Client{}
+			s := testSettings("http://" + dead)
+			s.Hosts = strings.TrimPrefix(alt.URL, "http://")
+
+			msg, err := get(c, s, "POST")
+			Expect(err, ToBeNil)
+			Expect(msg, ToEqual, "alt")
+			Expect(altHost.Load(), ToEqual, dead)
+
+			u := c.Action(s, "queues")
+			Expect(c.hosts(u)[0], ToEqual, s.Hosts)
+		})
+
+		It("fails over idempotent requests when a gateway fails", func() {
+			atomic.StoreInt32(&gateway, 1)
+			defer atomic.StoreInt32(&gateway, 0)
+			c := &Client{}
+			s := testSettings(main.URL)
+			s.Hosts = strings.TrimPrefix(alt.URL, "http://")
+
+			msg, err := get(c, s, "GET")
+			Expect(err, ToBeNil)
+			Expect(msg, ToEqual, "alt")
+
+			_, err = get(&Client{}, s, "POST")
+			Expect(StatusCode(err), ToEqual, http.StatusBadGateway)
+
+			var out DefaultResponseBody
+			err = (&Client{}).Action(s, "queues").Header(IdempotencyKeyHeader, "k1").Req("POST", nil, &out)
+			Expect(err, ToBeNil)
+			Expect(out.Msg, ToEqual, "alt")
+		})
+
+		It("keeps the TLS server name when failing over", func() {
+			var serverName atomic.Value
+			tlsAlt := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`{"msg":"alt"}`))
+			}))
+			tlsAlt.TLS = &tls.Config{GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+				serverName.Store(hello.ServerName)
+				return nil, nil
+			}}
+			tlsAlt.StartTLS()
+			defer tlsAlt.Close()
+
+			_, port, _ := net.SplitHostPort(dead)
+			s := testSettings("http://localhost:" + port)
+			s.Scheme = "https"
+			s.Hosts = strings.TrimPrefix(tlsAlt.URL, "https://")
+			c := &Client{HTTPClient: &http.Client{Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+			}}}
+
+			msg, err := get(c, s, "GET")
+			Expect(err, ToBeNil)
+			Expect(msg, ToEqual, "alt")
+			Expect(serverName.Load(), ToEqual, "localhost")
+		})
+
+		It("goes back to the main host once it is healthy", func() {
+			c := &Client{HostCooldown: time.Hour}
+			s := testSettings(main.URL)
+			s.Hosts = strings.TrimPrefix(alt.URL, "http://")
+			c.markHost(c.Action(s, "queues").URL.Host, false)
+
+			msg, _ := get(c, s, "GET")
+			Expect(msg, ToEqual, "alt")
+
+			Expect(len(c.CheckHosts(context.Background(), s)), ToEqual, 0)
+			msg, _ = get(c, s, "GET")
+			Expect(msg, ToEqual, "main")
+		})
+
+		It("reports the hosts that fail checks", func() {
+			c := &Client{}
+			s := testSettings(main.URL)
+			s.Hosts = dead
+			failed := c.CheckHosts(context.Background(), s)
+			Expect(len(failed), ToEqual, 1)
+			Expect(failed[dead] != nil, ToBeTrue)
+		})
+	})
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+// limiter holds the slots of the requests a Client has in flight, see
+// MaxConcurrent and MaxConcurrentPerHost.
+type limiter struct {
+	mu    sync.Mutex
+	all   chan struct{}
+	hosts map[string]chan struct{}
+}
+
+// slots returns the semaphores of the requests to host, nil for the limits
+// that aren't set.
+func (c *Client) slots(host string) (all, perHost chan struct{}) {
+	c.limiter.mu.Lock()
+	defer c.limiter.mu.Unlock()
+	if c.MaxConcurrent > 0 {
+		if c.limiter.all == nil {
+			c.limiter.all = make(chan struct{}, c.MaxConcurrent)
+		}
+		all = c.limiter.all
+	}
+	if c.MaxConcurrentPerHost > 0 {
+		if c.limiter.hosts == nil {
+			c.limiter.hosts = map[string]chan struct{}{}
+		}
+		perHost = c.limiter.hosts[host]
+		if perHost == nil {
+			perHost = make(chan struct{}, c.MaxConcurrentPerHost)
+			c.limiter.hosts[host] = perHost
+		}
+	}
+	return all, perHost
+}
+
+// acquire waits for a slot to send a request to host, or for ctx to be
+// done, and returns the func releasing the slot. The host's slot is taken
+// first, so requests queued for a busy host don't hold the slots of others.
+func (c *Client) acquire(ctx context.Context, host string) (release func(), err error) {
+	if c.MaxConcurrent <= 0 && c.MaxConcurrentPerHost <= 0 {
+		return func() {}, nil
+	}
+	all, perHost := c.slots(host)
+	var held []chan struct{}
+	free := func() {
+		for _, s := range held {
+			<-s
+		}
+	}
+	for _, s := range []chan struct{}{perHost, all} {
+		if s == nil {
+			continue
+		}
+		select {
+		case s <- struct{}{}:
+			held = append(held, s)
+		default:
+			c.log(LevelTrace, "waiting for a request slot", "host", host)
+			select {
+			case s <- struct{}{}:
+				held = append(held, s)
+			case <-ctx.Done():
+				free()
+				return nil, ctx.Err()
+			}
+		}
+	}
+	var once sync.Once
+	return func() { once.Do(free) }, nil
+}
+
+// releaseOnClose gives back the slot of a request once its response was
+// read.
+type releaseOnClose struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *releaseOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
+}
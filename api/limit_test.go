@@ -0,0 +1,104 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestConcurrencyLimits(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("concurrency limits", func() {
+		var inFlight, most int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			n := atomic.AddInt32(&inFlight, 1)
+			defer atomic.AddInt32(&inFlight, -1)
+			for {
+				m := atomic.LoadInt32(&most)
+				if n <= m || atomic.CompareAndSwapInt32(&most, m, n) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+			w.Write([]byte(`{}`))
+		}))
+		defer srv.Close()
+		s := testSettings(srv.URL)
+
+		run := func(c *Client, n int) {
+			atomic.StoreInt32(&most, 0)
+			var wg sync.WaitGroup
+			for i := 0; i < n; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					Expect(c.Action(s, "queues").Req("GET", nil, nil), ToBeNil)
+				}()
+			}
+			wg.Wait()
+		}
+
+		It("caps the requests in flight", func() {
+			run(&Client{MaxConcurrent: 3}, 12)
+			Expect(atomic.LoadInt32(&most), ToEqual, int32(3))
+		})
+
+		It("caps the requests in flight per host", func() {
+			run(&Client{MaxConcurrent: 5, MaxConcurrentPerHost: 2}, 12)
+			Expect(atomic.LoadInt32(&most), ToEqual, int32(2))
+		})
+
+		It("stops waiting when the context is done", func() {
+			c := &Client{MaxConcurrent: 1}
+			release, err := c.acquire(context.Background(), "host")
+			Expect(err, ToBeNil)
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+			defer cancel()
+			err = c.Action(s, "queues").WithContext(ctx).Req("GET", nil, nil)
+			Expect(err != nil, ToBeTrue)
+			Expect(ctx.Err(), ToEqual, context.DeadlineExceeded)
+
+			release()
+			Expect(c.Action(s, "queues").Req("GET", nil, nil), ToBeNil)
+		})
+
+		unavailable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"msg":"Service Unavailable"}`))
+		}))
+		defer unavailable.Close()
+		down := testSettings(unavailable.URL)
+		slow := RetryPolicy{MaxRetries: 2, Backoff: func(int) time.Duration { return time.Second }}
+
+		It("gives back the slot when cancelled while backing off", func() {
+			c := &Client{MaxConcurrent: 1, Retry: slow}
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(30*time.Millisecond, cancel)
+			err := c.Action(down, "queues").WithContext(ctx).Req("GET", nil, nil)
+			Expect(errors.Is(err, context.Canceled), ToBeTrue)
+
+			wait, stop := context.WithTimeout(context.Background(), 20*time.Millisecond)
+			defer stop()
+			release, err := c.acquire(wait, unavailable.Listener.Addr().String())
+			Expect(err, ToBeNil)
+			release()
+		})
+
+		It("doesn't back off after the last try", func() {
+			c := &Client{MaxConcurrent: 1, Retry: RetryPolicy{MaxRetries: 1, Backoff: slow.Backoff}}
+			start := time.Now()
+			err := c.Action(down, "queues").Req("GET", nil, nil)
+			Expect(err != nil, ToBeTrue)
+			Expect(time.Since(start) < 500*time.Millisecond, ToBeTrue)
+			Expect(c.Action(down, "queues").Req("GET", nil, nil) != nil, ToBeTrue)
+		})
+	})
+}
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Levels beyond slog's. LevelTrace adds request and response bodies and
+// headers to what LevelDebug logs, LevelOff logs nothing.
+const (
+	LevelTrace = slog.LevelDebug - 4
+	LevelOff   = slog.LevelError + 4
+)
+
+// LogLevel is the level of clients that don't set their own. It starts out
+// as IRON_API_LOG_LEVEL, see ParseLevel, or LevelTrace if IRON_API_DEBUG
+// is set and slog.LevelError if IRON_API_DEBUG_ON_ERRORS is. It is
+// LevelOff otherwise.
+var LogLevel = new(slog.LevelVar)
+
+// defaultLogger writes to stderr, leaving the filtering to the client's
+// level.
+var defaultLogger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+	Level:       LevelTrace,
+	ReplaceAttr: traceName,
+}))
+
+func init() {
+	LogLevel.Set(LevelOff)
+	switch {
+	case os.Getenv("IRON_API_LOG_LEVEL") != "":
+		l, err := ParseLevel(os.Getenv("IRON_API_LOG_LEVEL"))
+		if err != nil {
+			defaultLogger.Warn("ignoring IRON_API_LOG_LEVEL", "err", err)
+			return
+		}
+		LogLevel.Set(l)
+	case os.Getenv("IRON_API_DEBUG") != "":
+		LogLevel.Set(LevelTrace)
+	case os.Getenv("IRON_API_DEBUG_ON_ERRORS") != "":
+		LogLevel.Set(slog.LevelError)
+	}
+}
+
+// ParseLevel parses "trace", "off" and the level names slog knows, like
+// "debug" or "warn+2", ignoring case.
+func ParseLevel(s string) (slog.Level, error) {
+	switch strings.ToLower(s) {
+	case "trace":
+		return LevelTrace, nil
+	case "off", "none":
+		return LevelOff, nil
+	}
+	var l slog.Level
+	err := l.UnmarshalText([]byte(s))
+	return l, err
+}
+
+func traceName(groups []string, a slog.Attr) slog.Attr {
+	if a.Key == slog.LevelKey && len(groups) == 0 && a.Value.Any() == LevelTrace {
+		a.Value = slog.StringValue("TRACE")
+	}
+	return a
+}
+
+func (c *Client) level() slog.Level {
+	if c.LogLevel != nil {
+		return c.LogLevel.Level()
+	}
+	switch {
+	case Debug:
+		return LevelTrace
+	case DebugOnErrors:
+		return min(LogLevel.Level(), slog.LevelError)
+	}
+	return LogLevel.Level()
+}
+
+func (c *Client) enabled(l slog.Level) bool {
+	return l >= c.level()
+}
+
+func (c *Client) log(l slog.Level, msg string, args ...interface{}) {
+	if !c.enabled(l) {
+		return
+	}
+	logger := c.Logger
+	if logger == nil {
+		logger = defaultLogger
+	}
+	logger.Log(context.Background(), l, msg, args...)
+}
+
+// logRequest logs a finished request at slog.LevelDebug, or at
+// slog.LevelError if it failed.
+func (c *Client) logRequest(u *url.URL, stats RequestStats) {
+	args := []interface{}{
+		"method", stats.Method,
+		"url", redactedURL(u),
+		"status", stats.StatusCode,
+		"tries", stats.Tries,
+		"duration", stats.Duration,
+	}
+	if stats.Err != nil {
+		c.log(slog.LevelError, "request failed", append(args, "err", stats.Err)...)
+	} else {
+		c.log(slog.LevelDebug, "request", args...)
+	}
+}
+
+// redactedHeader returns h without credentials.
+func redactedHeader(h http.Header) http.Header {
+	h = h.Clone()
+	if h.Get("Authorization") != "" {
+		h.Set("Authorization", "OAuth [redacted]")
+	}
+	return h
+}
+
+// redactedURL returns u without credentials, webhook URLs carry the token
+// in their query.
+func redactedURL(u *url.URL) string {
+	q := u.Query()
+	if q.Get("oauth") == "" {
+		return u.Redacted()
+	}
+	q.Set("oauth", "redacted")
+	r := *u
+	r.RawQuery = q.Encode()
+	return r.Redacted()
+}
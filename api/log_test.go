@@ -0,0 +1,104 @@
+package api
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestLogging(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("client logging", func() {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/3/projects/project/missing" {
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte(`{"msg":"Queue not found"}`))
+				return
+			}
+			w.Write([]byte(`{"msg":"secret response"}`))
+		}))
+		defer srv.Close()
+		s := testSettings(srv.URL)
+
+		logged := func(level slog.Level, endpoint string) string {
+			var buf bytes.Buffer
+			c := &Client{
+				Logger:   slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: LevelTrace})),
+				LogLevel: level,
+			}
+			c.Action(s, endpoint).Req("POST", map[string]string{"body": "secret request"}, nil)
+			return buf.String()
+		}
+
+		It("logs nothing when off", func() {
+			Expect(logged(LevelOff, "missing"), ToEqual, "")
+		})
+
+		It("logs only failures at the error level", func() {
+			Expect(logged(slog.LevelError, "ok"), ToEqual, "")
+			out := logged(slog.LevelError, "missing")
+			Expect(strings.Contains(out, `msg="request failed"`), ToBeTrue)
+			Expect(strings.Contains(out, "status=404"), ToBeTrue)
+		})
+
+		It("logs requests without bodies at the debug level", func() {
+			out := logged(slog.LevelDebug, "ok")
+			Expect(strings.Contains(out, "msg=request"), ToBeTrue)
+			Expect(strings.Contains(out, "tries=1"), ToBeTrue)
+			Expect(strings.Contains(out, "secret"), ToEqual, false)
+		})
+
+		It("logs bodies at the trace level", func() {
+			out := logged(LevelTrace, "ok")
+			Expect(strings.Contains(out, "secret request"), ToBeTrue)
+			Expect(strings.Contains(out, "secret response"), ToBeTrue)
+		})
+
+		It("still decodes traced responses", func() {
+			var out DefaultResponseBody
+			c := &Client{Logger: slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil)), LogLevel: LevelTrace}
+			Expect(c.Action(s, "ok").Req("GET", nil, &out), ToBeNil)
+			Expect(out.Msg, ToEqual, "secret response")
+		})
+
+		It("redacts the token", func() {
+			out := logged(LevelTrace, "ok")
+			Expect(strings.Contains(out, "OAuth [redacted]"), ToBeTrue)
+			Expect(strings.Contains(out, "OAuth token"), ToEqual, false)
+
+			u := Action(s, "tasks", "webhook").QueryAdd("oauth", "%s", "token")
+			Expect(strings.Contains(redactedURL(&u.URL), "token"), ToEqual, false)
+		})
+
+		It("falls back to the package level", func() {
+			defer LogLevel.Set(LogLevel.Level())
+			LogLevel.Set(slog.LevelInfo)
+			Expect((&Client{}).level(), ToEqual, slog.LevelInfo)
+			Expect((&Client{LogLevel: LevelTrace}).level(), ToEqual, LevelTrace)
+		})
+	})
+
+	Describe("parsing levels", func() {
+		It("knows trace and off", func() {
+			l, err := ParseLevel("TRACE")
+			Expect(err, ToBeNil)
+			Expect(l, ToEqual, LevelTrace)
+			l, _ = ParseLevel("off")
+			Expect(l, ToEqual, LevelOff)
+		})
+
+		It("knows slog's levels", func() {
+			l, err := ParseLevel("warn")
+			Expect(err, ToBeNil)
+			Expect(l, ToEqual, slog.LevelWarn)
+			_, err = ParseLevel("loud")
+			Expect(err, ToNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,192 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/textproto"
+	"os"
+	"strings"
+	"sync"
+)
+
+// A Part is a part of a multipart/form-data request, a form field or a
+// file, see MultipartReq.
+type Part struct {
+	// Name is the name of the form field.
+	Name string
+	// Filename, if set, makes the part a file.
+	Filename string
+	// ContentType is the type of the content, application/octet-stream
+	// for files by default.
+	ContentType string
+	// Size is the length of what Write writes, 0 if unknown. When the
+	// sizes of all parts are known the request has a Content-Length,
+	// otherwise it is chunked.
+	Size int64
+	// Write writes the content of the part. It is called again for each
+	// retry of the request.
+	Write func(w io.Writer) error
+}
+
+// FieldPart returns a form field with value.
+func FieldPart(name, value string) Part {
+	return Part{Name: name, Size: int64(len(value)), Write: func(w io.Writer) error {
+		_, err := io.WriteString(w, value)
+		return err
+	}}
+}
+
+// JSONPart returns a form field with v encoded as JSON.
+func JSONPart(name string, v interface{}) (Part, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return Part{}, err
+	}
+	return Part{Name: name, ContentType: "application/json", Size: int64(len(data)), Write: func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	}}, nil
+}
+
+// FilePart returns a file with the content of the file at path, which is
+// streamed rather than read into memory.
+func FilePart(name, filename, path string) (Part, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return Part{}, err
+	}
+	return Part{Name: name, Filename: filename, Size: fi.Size(), Write: func(w io.Writer) error {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(w, f)
+		return err
+	}}, nil
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func (p Part) header() textproto.MIMEHeader {
+	h := textproto.MIMEHeader{}
+	disposition := fmt.Sprintf(`form-data; name="%s"`, quoteEscaper.Replace(p.Name))
+	if p.Filename != "" {
+		disposition += fmt.Sprintf(`; filename="%s"`, quoteEscaper.Replace(p.Filename))
+	}
+	h.Set("Content-Disposition", disposition)
+	switch {
+	case p.ContentType != "":
+		h.Set("Content-Type", p.ContentType)
+	case p.Filename != "":
+		h.Set("Content-Type", "application/octet-stream")
+	}
+	return h
+}
+
+// MultipartReq is like Req, but sends parts as multipart/form-data. The
+// parts are streamed as the request is sent, not buffered in memory.
+func (u *URL) MultipartReq(method string, parts []Part, out interface{}) error {
+	b := newMultipartBody(parts)
+	defer b.Close()
+	return u.SetContentType("multipart/form-data; boundary="+b.boundary).Req(method, b, out)
+}
+
+// multipartBody streams the parts of a multipart request through a pipe.
+// Seeking to its start restarts the stream, for retries.
+type multipartBody struct {
+	parts    []Part
+	boundary string
+	size     int64
+
+	mu sync.Mutex
+	r  *io.PipeReader
+}
+
+func newMultipartBody(parts []Part) *multipartBody {
+	b := &multipartBody{parts: parts, boundary: multipart.NewWriter(io.Discard).Boundary()}
+	b.size = b.length()
+	return b
+}
+
+// length returns the length of the body, -1 if the size of a part isn't
+// known.
+func (b *multipartBody) length() int64 {
+	var framing countingWriter
+	mw := multipart.NewWriter(&framing)
+	mw.SetBoundary(b.boundary)
+	var total int64
+	for _, p := range b.parts {
+		if p.Size <= 0 {
+			return -1
+		}
+		mw.CreatePart(p.header())
+		total += p.Size
+	}
+	mw.Close()
+	return total + int64(framing)
+}
+
+// Len is the length of the body, -1 if unknown, for the Content-Length.
+func (b *multipartBody) Len() int { return int(b.size) }
+
+func (b *multipartBody) Read(p []byte) (int, error) {
+	b.mu.Lock()
+	if b.r == nil {
+		r, w := io.Pipe()
+		b.r = r
+		go func() { w.CloseWithError(b.write(w)) }()
+	}
+	r := b.r
+	b.mu.Unlock()
+	return r.Read(p)
+}
+
+// Seek only seeks to the start.
+func (b *multipartBody) Seek(offset int64, whence int) (int64, error) {
+	if offset != 0 || whence != io.SeekStart {
+		return 0, errors.New("api: multipart bodies only seek to their start")
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.r != nil {
+		b.r.Close() // stops the writing goroutine
+		b.r = nil
+	}
+	return 0, nil
+}
+
+// Close stops the stream, it is safe to call several times.
+func (b *multipartBody) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.r != nil {
+		return b.r.Close()
+	}
+	return nil
+}
+
+func (b *multipartBody) write(w io.Writer) error {
+	mw := multipart.NewWriter(w)
+	mw.SetBoundary(b.boundary)
+	for _, p := range b.parts {
+		pw, err := mw.CreatePart(p.header())
+		if err != nil {
+			return err
+		}
+		if err := p.Write(pw); err != nil {
+			return fmt.Errorf("writing part %s: %w", p.Name, err)
+		}
+	}
+	return mw.Close()
+}
+
+type countingWriter int64
+
+func (n *countingWriter) Write(p []byte) (int, error) {
+	*n += countingWriter(len(p))
+	return len(p), nil
+}
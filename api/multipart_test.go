@@ -0,0 +1,82 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestMultipartReq(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("multipart requests", func() {
+		var hits int32
+		var length int64
+		var fields map[string]string
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// the first request is turned away, so the body is streamed twice
+			if atomic.AddInt32(&hits, 1) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			length = r.ContentLength
+			mr, err := r.MultipartReader()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			fields = map[string]string{}
+			for {
+				p, err := mr.NextPart()
+				if err == io.EOF {
+					break
+				}
+				data, _ := io.ReadAll(p)
+				fields[p.FormName()+":"+p.FileName()+":"+p.Header.Get("Content-Type")] = string(data)
+			}
+			w.Write([]byte(`{"msg":"uploaded"}`))
+		}))
+		defer srv.Close()
+		s := testSettings(srv.URL)
+		c := &Client{Retry: RetryPolicy{Backoff: func(int) time.Duration { return 0 }}}
+
+		path := filepath.Join(t.TempDir(), "payload.bin")
+		os.WriteFile(path, []byte("binary\x00data"), 0600)
+
+		It("streams fields and files", func() {
+			atomic.StoreInt32(&hits, 0)
+			data, err := JSONPart("data", map[string]string{"name": "hello"})
+			Expect(err, ToBeNil)
+			file, err := FilePart("file", "payload.bin", path)
+			Expect(err, ToBeNil)
+			var out DefaultResponseBody
+			Expect(c.Action(s, "codes").MultipartReq("POST", []Part{data, FieldPart("note", "hi"), file}, &out), ToBeNil)
+			Expect(out.Msg, ToEqual, "uploaded")
+			Expect(atomic.LoadInt32(&hits), ToEqual, int32(2))
+			Expect(fields, ToDeepEqual, map[string]string{
+				"data::application/json": `{"name":"hello"}`,
+				"note::":                 "hi",
+				"file:payload.bin:application/octet-stream": "binary\x00data",
+			})
+			Expect(length > 0, ToBeTrue)
+		})
+
+		It("is chunked when a size isn't known", func() {
+			atomic.StoreInt32(&hits, 0)
+			gen := Part{Name: "file", Filename: "gen.txt", Write: func(w io.Writer) error {
+				_, err := io.WriteString(w, "generated")
+				return err
+			}}
+			Expect(c.Action(s, "codes").MultipartReq("POST", []Part{gen}, nil), ToBeNil)
+			Expect(fields["file:gen.txt:application/octet-stream"], ToEqual, "generated")
+			Expect(length, ToEqual, int64(-1))
+		})
+	})
+}
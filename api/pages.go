@@ -0,0 +1,33 @@
+package api
+
+import "iter"
+
+// Pages returns an iterator over the items of a listing API paginated by
+// page number, counting from 0. fetch gets a page of up to perPage items,
+// and the iteration stops after a shorter page, or with fetch's error.
+//
+//	for code, err := range api.Pages(100, func(page int) ([]worker.CodeInfo, error) {
+//		return w.CodePackageList(page, 100)
+//	}) {
+//		...
+//	}
+func Pages[T any](perPage int, fetch func(page int) ([]T, error)) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for page := 0; ; page++ {
+			items, err := fetch(page)
+			if err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+			for _, item := range items {
+				if !yield(item, nil) {
+					return
+				}
+			}
+			if len(items) < perPage {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestPages(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("paginated listings", func() {
+		items := []int{0, 1, 2, 3, 4, 5, 6}
+		var fetched []int
+		fetch := func(page int) ([]int, error) {
+			fetched = append(fetched, page)
+			if page == 9 {
+				return nil, errors.New("gone")
+			}
+			return items[min(page*3, len(items)):min(page*3+3, len(items))], nil
+		}
+
+		It("fetches pages until a short one", func() {
+			fetched = nil
+			var got []int
+			for item, err := range Pages(3, fetch) {
+				Expect(err, ToBeNil)
+				got = append(got, item)
+			}
+			Expect(got, ToDeepEqual, items)
+			Expect(fetched, ToDeepEqual, []int{0, 1, 2})
+		})
+
+		It("stops fetching when the loop breaks", func() {
+			fetched = nil
+			for item := range Pages(3, fetch) {
+				if item == 1 {
+					break
+				}
+			}
+			Expect(fetched, ToDeepEqual, []int{0})
+		})
+
+		It("ends with the error of a fetch", func() {
+			var errs []error
+			for _, err := range Pages(3, func(page int) ([]int, error) { return fetch(9) }) {
+				errs = append(errs, err)
+			}
+			Expect(len(errs), ToEqual, 1)
+			Expect(errs[0].Error(), ToEqual, "gone")
+		})
+	})
+}
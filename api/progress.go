@@ -0,0 +1,85 @@
+package api
+
+import "io"
+
+// Progress tells how much of a transfer is done, see URL.OnDownload and
+// URL.OnUpload.
+type Progress struct {
+	// Bytes is the number of bytes transferred so far.
+	Bytes int64
+	// Total is the size of the transfer, -1 if unknown.
+	Total int64
+	// Done is set for the last report, once the transfer is complete.
+	Done bool
+}
+
+// OnDownload makes the request call fn as the response body is read, after
+// each read and once more when it is complete. A UI can show the progress
+// of large downloads, such as code packages or task logs, with it, and tell
+// a download stalled when fn isn't called for a while.
+func (u *URL) OnDownload(fn func(Progress)) *URL {
+	u.onDownload = fn
+	return u
+}
+
+// progressReader reports the bytes read from a body.
+type progressReader struct {
+	io.ReadCloser
+	fn   func(Progress)
+	p    Progress
+	done bool
+}
+
+func (r *progressReader) Read(b []byte) (int, error) {
+	n, err := r.ReadCloser.Read(b)
+	r.p.Bytes += int64(n)
+	if err == io.EOF && !r.done {
+		r.done, r.p.Done = true, true
+		r.fn(r.p)
+	} else if n > 0 {
+		r.fn(r.p)
+	}
+	return n, err
+}
+
+// OnUpload makes the request call fn as its body is sent, after each read
+// of it and once more when it was read completely. When the request is
+// retried the upload starts over, and so do the reports.
+//
+// The iron.io APIs take uploads, such as code packages, in one request, so
+// they can't be resumed; stream them, with MultipartReq, and set a Retry
+// policy instead.
+func (u *URL) OnUpload(fn func(Progress)) *URL {
+	u.onUpload = fn
+	return u
+}
+
+// progressBody reports the bytes read from a request body.
+type progressBody struct {
+	io.ReadSeeker
+	progressReader
+}
+
+func newProgressBody(body io.ReadSeeker, total int64, fn func(Progress)) *progressBody {
+	b := &progressBody{ReadSeeker: body}
+	b.progressReader = progressReader{ReadCloser: io.NopCloser(body), fn: fn, p: Progress{Total: total}}
+	return b
+}
+
+func (b *progressBody) Read(p []byte) (int, error) { return b.progressReader.Read(p) }
+
+func (b *progressBody) Seek(offset int64, whence int) (int64, error) {
+	n, err := b.ReadSeeker.Seek(offset, whence)
+	if err == nil {
+		b.p.Bytes, b.p.Done, b.done = n, false, false
+	}
+	return n, err
+}
+
+// Close closes the body if it is an io.Closer, like a multipart body.
+func (b *progressBody) Close() error {
+	if c, ok := b.ReadSeeker.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
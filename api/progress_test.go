@@ -0,0 +1,62 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestProgress(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("transfer progress", func() {
+		var hits int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.Copy(io.Discard, r.Body)
+			// the first upload is turned away, so it starts over
+			if r.Method == "POST" && atomic.AddInt32(&hits, 1) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Header().Set("Content-Length", "100000")
+			w.Write([]byte(strings.Repeat("x", 100000)))
+		}))
+		defer srv.Close()
+		s := testSettings(srv.URL)
+		c := &Client{Retry: RetryPolicy{Backoff: func(int) time.Duration { return 0 }}}
+
+		It("reports downloads", func() {
+			var reports []Progress
+			res, err := c.Action(s, "codes", "c", "download").OnDownload(func(p Progress) { reports = append(reports, p) }).Request("GET", nil)
+			Expect(err, ToBeNil)
+			io.Copy(io.Discard, res.Body)
+			res.Body.Close()
+			Expect(len(reports) > 1, ToBeTrue)
+			Expect(reports[0].Total, ToEqual, int64(100000))
+			Expect(reports[len(reports)-1], ToEqual, Progress{Bytes: 100000, Total: 100000, Done: true})
+		})
+
+		It("reports uploads, starting over on retries", func() {
+			var reports []Progress
+			body := strings.Repeat("y", 50000)
+			res, err := c.Action(s, "codes").OnUpload(func(p Progress) { reports = append(reports, p) }).Request("POST", strings.NewReader(body))
+			Expect(err, ToBeNil)
+			res.Body.Close()
+			done := 0
+			for _, p := range reports {
+				Expect(p.Total, ToEqual, int64(50000))
+				if p.Done {
+					done++
+					Expect(p.Bytes, ToEqual, int64(50000))
+				}
+			}
+			Expect(done, ToEqual, 2)
+		})
+	})
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestTransientRetries(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("transient failures", func() {
+		s := testSettings("http://iron.test:80")
+		// failing returns a client whose first try fails with err, or
+		// status if err is nil, and counts the tries
+		failing := func(err error, status int) (*Client, *int) {
+			tries := 0
+			hc := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+				tries++
+				if tries == 1 && err != nil {
+					return nil, err
+				}
+				if tries == 1 {
+					status := status
+					return &http.Response{StatusCode: status, Status: http.StatusText(status), Body: io.NopCloser(strings.NewReader(`{"msg":"failed"}`)), Request: r}, nil
+				}
+				return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(`{"msg":"ok"}`)), Request: r}, nil
+			})}
+			return &Client{HTTPClient: hc, Retry: RetryPolicy{Backoff: func(int) time.Duration { return 0 }}}, &tries
+		}
+
+		failures := map[string]func() (*Client, *int){
+			"connection resets": func() (*Client, *int) {
+				return failing(&net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}, 0)
+			},
+			"broken pipes": func() (*Client, *int) {
+				return failing(&net.OpError{Op: "write", Net: "tcp", Err: os.NewSyscallError("write", syscall.EPIPE)}, 0)
+			},
+			"GOAWAY frames": func() (*Client, *int) {
+				return failing(errors.New("http2: server sent GOAWAY and closed the connection; LastStreamID=1, ErrCode=NO_ERROR, debug=\"\""), 0)
+			},
+			"temporary DNS failures": func() (*Client, *int) {
+				return failing(&net.DNSError{Err: "server misbehaving", Name: "iron.test", IsTemporary: true}, 0)
+			},
+			"bad gateways": func() (*Client, *int) { return failing(nil, http.StatusBadGateway) },
+			"gateway timeouts": func() (*Client, *int) { return failing(nil, http.StatusGatewayTimeout) },
+		}
+
+		for name, fail := range failures {
+			It("retries idempotent requests after "+name, func() {
+				c, tries := fail()
+				var out DefaultResponseBody
+				Expect(c.Action(s, "queues").Req("GET", nil, &out), ToBeNil)
+				Expect(out.Msg, ToEqual, "ok")
+				Expect(*tries, ToEqual, 2)
+			})
+
+			It("doesn't retry other requests after "+name, func() {
+				c, tries := fail()
+				Expect(c.Action(s, "queues").Req("POST", nil, nil), ToNotBeNil)
+				Expect(*tries, ToEqual, 1)
+			})
+
+			It("retries requests with idempotency keys after "+name, func() {
+				c, tries := fail()
+				Expect(c.Action(s, "queues").Header(IdempotencyKeyHeader, "k1").Req("POST", nil, nil), ToBeNil)
+				Expect(*tries, ToEqual, 2)
+			})
+		}
+
+		It("doesn't retry permanent failures", func() {
+			c, tries := failing(&net.DNSError{Err: "no such host", Name: "iron.test", IsNotFound: true}, 0)
+			Expect(c.Action(s, "queues").Req("GET", nil, nil), ToNotBeNil)
+			Expect(*tries, ToEqual, 1)
+		})
+	})
+}
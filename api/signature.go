@@ -0,0 +1,185 @@
+package api
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Requests to webhook endpoints, e.g. push queue subscribers and task
+// callbacks, can be signed with a secret shared with their handler, so it
+// can tell they come from a party knowing the secret.
+//
+// Requests sent by the client side, e.g. relays or tests, carry an HMAC of
+// their body in the SignatureHeader, see SignRequest. Iron's own requests
+// can't be signed that way, their endpoint's URL is signed instead, see
+// SignURL. RequireSignature accepts the former, RequireSignatureWith can
+// accept both.
+const (
+	// SignatureHeader holds "t=<unix time>,v1=<hex HMAC-SHA256>" of the
+	// time, a dot and the body.
+	SignatureHeader = "Iron-Signature"
+	// SignatureParam is the query parameter of signed URLs, the hex
+	// HMAC-SHA256 of the URL's path and other parameters.
+	SignatureParam = "iron_signature"
+	// ExpiresParam is the query parameter of signed URLs holding the unix
+	// time they expire at. It is covered by the signature.
+	ExpiresParam = "iron_expires"
+)
+
+// SignatureTolerance is how old signed requests may be.
+const SignatureTolerance = 5 * time.Minute
+
+// maxSignedBody limits the bodies RequireSignature reads.
+const maxSignedBody = 10 << 20
+
+// ErrBadSignature is returned for requests whose signature is missing or
+// doesn't verify.
+var ErrBadSignature = errors.New("missing or invalid signature")
+
+// Sign returns the SignatureHeader value of body sent at t.
+func Sign(secret, body []byte, t time.Time) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	return "t=" + ts + ",v1=" + hex.EncodeToString(mac(secret, ts+"."+string(body)))
+}
+
+// SignRequest sets the SignatureHeader of req, whose body is body.
+func SignRequest(req *http.Request, secret, body []byte) {
+	req.Header.Set(SignatureHeader, Sign(secret, body, time.Now()))
+}
+
+// SignURL returns rawURL with a SignatureParam covering its path and query,
+// e.g. to use as a push queue subscriber or task callback URL. The URL
+// expires after ttl, which must cover the life of the subscription or of
+// the task: a signed URL can't be revoked otherwise.
+func SignURL(rawURL string, secret []byte, ttl time.Duration) (string, error) {
+	if ttl <= 0 {
+		return "", errors.New("api: signing a URL without an expiry")
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	q.Set(ExpiresParam, strconv.FormatInt(time.Now().Add(ttl).Unix(), 10))
+	sig := hex.EncodeToString(mac(secret, signedURL(u.EscapedPath(), q)))
+	q.Set(SignatureParam, sig)
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// VerifySignature checks header, a SignatureHeader value, against body. It
+// refuses signatures older than SignatureTolerance, or as far in the
+// future.
+func VerifySignature(secret, body []byte, header string, now time.Time) error {
+	var ts, sig string
+	for _, part := range strings.Split(header, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch k {
+		case "t":
+			ts = v
+		case "v1":
+			sig = v
+		}
+	}
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || sig == "" {
+		return ErrBadSignature
+	}
+	if d := now.Sub(time.Unix(unix, 0)); d > SignatureTolerance || d < -SignatureTolerance {
+		return fmt.Errorf("%w: signed %v ago", ErrBadSignature, d.Round(time.Second))
+	}
+	if !validMAC(secret, ts+"."+string(body), sig) {
+		return ErrBadSignature
+	}
+	return nil
+}
+
+// VerifyURL checks the SignatureParam of a request's URL, and that the
+// URL didn't expire by now.
+func VerifyURL(secret []byte, u *url.URL, now time.Time) error {
+	q := u.Query()
+	sig := q.Get(SignatureParam)
+	expires, err := strconv.ParseInt(q.Get(ExpiresParam), 10, 64)
+	if sig == "" || err != nil || !validMAC(secret, signedURL(u.EscapedPath(), q), sig) {
+		return ErrBadSignature
+	}
+	if at := time.Unix(expires, 0); now.After(at) {
+		return fmt.Errorf("%w: expired %v ago", ErrBadSignature, now.Sub(at).Round(time.Second))
+	}
+	return nil
+}
+
+// SignatureOptions are the options of RequireSignatureWith.
+type SignatureOptions struct {
+	// AcceptURL accepts requests to URLs signed with SignURL as well, e.g.
+	// for Iron's task callbacks and pushes, which can't sign their bodies.
+	// Anyone holding such a URL can post to it until it expires, whatever
+	// the body.
+	AcceptURL bool
+}
+
+// RequireSignature returns a handler passing requests signed with secret
+// by SignRequest on to h, and refusing others with 401 Unauthorized:
+//
+//	http.Handle("/hook", api.RequireSignature(secret, hook))
+func RequireSignature(secret []byte, h http.Handler) http.Handler {
+	return RequireSignatureWith(secret, SignatureOptions{}, h)
+}
+
+// RequireSignatureWith is RequireSignature with options, e.g. to accept
+// the pushes of a subscriber URL signed with SignURL:
+//
+//	opts := api.SignatureOptions{AcceptURL: true}
+//	http.Handle("/alerts", api.RequireSignatureWith(secret, opts, mq.AlertHandler(onAlert)))
+func RequireSignatureWith(secret []byte, opts SignatureOptions, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if opts.AcceptURL && r.URL.Query().Has(SignatureParam) {
+			if err := VerifyURL(secret, r.URL, time.Now()); err != nil {
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxSignedBody))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := VerifySignature(secret, body, r.Header.Get(SignatureHeader), time.Now()); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		h.ServeHTTP(w, r)
+	})
+}
+
+// signedURL is what the signature of a URL covers, with the signature
+// parameter left empty so it keeps its place.
+func signedURL(path string, q url.Values) string {
+	q.Set(SignatureParam, "")
+	return path + "?" + q.Encode()
+}
+
+func mac(secret []byte, data string) []byte {
+	h := hmac.New(sha256.New, secret)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func validMAC(secret []byte, data, sig string) bool {
+	want, err := hex.DecodeString(sig)
+	return err == nil && hmac.Equal(mac(secret, data), want)
+}
@@ -0,0 +1,125 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestSignature(t *testing.T) {
+	defer PrintSpecReport()
+
+	secret := []byte("secret")
+	body := []byte(`{"task_id":"1"}`)
+
+	Describe("request signatures", func() {
+		now := time.Now()
+		header := Sign(secret, body, now)
+
+		It("verify with the body and secret they were made with", func() {
+			Expect(VerifySignature(secret, body, header, now.Add(time.Minute)), ToBeNil)
+		})
+
+		It("refuse other bodies and secrets", func() {
+			err := VerifySignature(secret, []byte(`{"task_id":"2"}`), header, now)
+			Expect(errors.Is(err, ErrBadSignature), ToBeTrue)
+			err = VerifySignature([]byte("other"), body, header, now)
+			Expect(errors.Is(err, ErrBadSignature), ToBeTrue)
+			err = VerifySignature(secret, body, "", now)
+			Expect(errors.Is(err, ErrBadSignature), ToBeTrue)
+		})
+
+		It("refuse stale signatures", func() {
+			err := VerifySignature(secret, body, header, now.Add(SignatureTolerance+time.Minute))
+			Expect(errors.Is(err, ErrBadSignature), ToBeTrue)
+		})
+	})
+
+	Describe("URL signatures", func() {
+		signed, err := SignURL("https://example.com/hooks/done?id=1&b=2", secret, time.Hour)
+		Expect(err, ToBeNil)
+		u, _ := url.Parse(signed)
+		now := time.Now()
+
+		It("keep the URL's path and parameters", func() {
+			Expect(u.Path, ToEqual, "/hooks/done")
+			Expect(u.Query().Get("id"), ToEqual, "1")
+			Expect(u.Query().Get(SignatureParam) != "", ToBeTrue)
+			Expect(VerifyURL(secret, u, now), ToBeNil)
+		})
+
+		It("refuse URLs changed after signing", func() {
+			changed := *u
+			changed.Path = "/hooks/other"
+			Expect(errors.Is(VerifyURL(secret, &changed, now), ErrBadSignature), ToBeTrue)
+
+			changed = *u
+			changed.RawQuery = strings.Replace(u.RawQuery, "id=1", "id=2", 1)
+			Expect(errors.Is(VerifyURL(secret, &changed, now), ErrBadSignature), ToBeTrue)
+
+			Expect(errors.Is(VerifyURL([]byte("other"), u, now), ErrBadSignature), ToBeTrue)
+		})
+
+		It("expire", func() {
+			Expect(errors.Is(VerifyURL(secret, u, now.Add(2*time.Hour)), ErrBadSignature), ToBeTrue)
+
+			changed := *u
+			q := u.Query()
+			q.Set(ExpiresParam, "99999999999")
+			changed.RawQuery = q.Encode()
+			Expect(errors.Is(VerifyURL(secret, &changed, now), ErrBadSignature), ToBeTrue)
+
+			_, err := SignURL("/hook", secret, 0)
+			Expect(err, ToNotBeNil)
+		})
+	})
+
+	Describe("RequireSignature", func() {
+		var got string
+		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			b, _ := io.ReadAll(r.Body)
+			got = string(b)
+		})
+		h := RequireSignatureWith(secret, SignatureOptions{AcceptURL: true}, next)
+		serve := func(req *http.Request) int {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			return rec.Code
+		}
+
+		It("passes requests with a signed body on, body intact", func() {
+			got = ""
+			req := httptest.NewRequest("POST", "/hook", strings.NewReader(string(body)))
+			SignRequest(req, secret, body)
+			Expect(serve(req), ToEqual, http.StatusOK)
+			Expect(got, ToEqual, string(body))
+		})
+
+		It("passes requests to signed URLs on", func() {
+			signed, err := SignURL("/hook?queue=jobs", secret, time.Minute)
+			Expect(err, ToBeNil)
+			Expect(serve(httptest.NewRequest("POST", signed, strings.NewReader("x"))), ToEqual, http.StatusOK)
+		})
+
+		It("refuses requests to signed URLs unless asked to", func() {
+			signed, err := SignURL("/hook?queue=jobs", secret, time.Minute)
+			Expect(err, ToBeNil)
+			rec := httptest.NewRecorder()
+			RequireSignature(secret, next).ServeHTTP(rec, httptest.NewRequest("POST", signed, strings.NewReader("x")))
+			Expect(rec.Code, ToEqual, http.StatusUnauthorized)
+		})
+
+		It("refuses unsigned requests", func() {
+			Expect(serve(httptest.NewRequest("POST", "/hook", strings.NewReader(string(body)))), ToEqual, http.StatusUnauthorized)
+			req := httptest.NewRequest("POST", "/hook?"+SignatureParam+"=00", strings.NewReader(string(body)))
+			Expect(serve(req), ToEqual, http.StatusUnauthorized)
+		})
+	})
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// clockSkew is the skew measured from the Date headers of responses.
+type clockSkew struct {
+	mu       sync.Mutex
+	skew     time.Duration
+	measured bool
+	warned   bool
+}
+
+// ClockSkew returns how far the servers' clocks are ahead of the local
+// one, negative if they are behind, as measured from the Date header of
+// the last response. The header has a resolution of a second, so is the
+// skew. ok is false before a response with a Date was received.
+//
+// The delays, timeouts and expirations of messages, tasks and cache items
+// are counted by the server, so a skewed local clock makes the times the
+// client computes from them wrong.
+func (c *Client) ClockSkew() (skew time.Duration, ok bool) {
+	c.skew.mu.Lock()
+	defer c.skew.mu.Unlock()
+	return c.skew.skew, c.skew.measured
+}
+
+// observeSkew measures the skew from res, to a request sent at sent. When
+// it grows over MaxClockSkew, it is logged as a warning and OnClockSkew is
+// called, once until it is back under.
+func (c *Client) observeSkew(res *http.Response, sent time.Time) {
+	date, err := http.ParseTime(res.Header.Get("Date"))
+	if err != nil {
+		return
+	}
+	// the server's time is somewhere in the second of date, and the
+	// response left at about the middle of the round trip
+	now := time.Now()
+	local := sent.Add(now.Sub(sent) / 2)
+	skew := date.Add(500 * time.Millisecond).Sub(local).Round(time.Second)
+
+	c.skew.mu.Lock()
+	c.skew.skew, c.skew.measured = skew, true
+	over := skew > c.maxClockSkew() || skew < -c.maxClockSkew()
+	warn := over && !c.skew.warned
+	c.skew.warned = over
+	c.skew.mu.Unlock()
+
+	if warn {
+		c.log(slog.LevelWarn, "local clock is skewed", "host", res.Request.URL.Host, "skew", skew)
+		if c.OnClockSkew != nil {
+			c.OnClockSkew(skew)
+		}
+	}
+}
+
+func (c *Client) maxClockSkew() time.Duration {
+	if c.MaxClockSkew > 0 {
+		return c.MaxClockSkew
+	}
+	return 5 * time.Second
+}
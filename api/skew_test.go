@@ -0,0 +1,55 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestClockSkew(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("clock skew", func() {
+		var ahead atomic.Int64
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			now := time.Now().Add(time.Duration(ahead.Load()))
+			w.Header().Set("Date", now.UTC().Format(http.TimeFormat))
+			w.Write([]byte(`{}`))
+		}))
+		defer srv.Close()
+		s := testSettings(srv.URL)
+
+		It("is measured from responses", func() {
+			c := &Client{}
+			_, ok := c.ClockSkew()
+			Expect(ok, ToEqual, false)
+			Expect(c.Action(s, "queues").Req("GET", nil, nil), ToBeNil)
+			skew, ok := c.ClockSkew()
+			Expect(ok, ToBeTrue)
+			Expect(skew <= time.Second && skew >= -time.Second, ToBeTrue)
+		})
+
+		It("is reported once it grows over the limit", func() {
+			var skews []time.Duration
+			c := &Client{OnClockSkew: func(d time.Duration) { skews = append(skews, d) }}
+			ahead.Store(int64(time.Minute))
+			defer ahead.Store(0)
+			for i := 0; i < 3; i++ {
+				Expect(c.Action(s, "queues").Req("GET", nil, nil), ToBeNil)
+			}
+			Expect(len(skews), ToEqual, 1)
+			Expect(skews[0] >= 59*time.Second && skews[0] <= 61*time.Second, ToBeTrue)
+
+			ahead.Store(0)
+			Expect(c.Action(s, "queues").Req("GET", nil, nil), ToBeNil)
+			ahead.Store(int64(-time.Minute))
+			Expect(c.Action(s, "queues").Req("GET", nil, nil), ToBeNil)
+			Expect(len(skews), ToEqual, 2)
+			Expect(skews[1] < 0, ToBeTrue)
+		})
+	})
+}
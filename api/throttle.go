@@ -0,0 +1,88 @@
+package api
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// rateLimiter paces one direction of a Client's transfers, see UploadRate
+// and DownloadRate. It is shared by all requests of the client.
+type rateLimiter struct {
+	mu sync.Mutex
+	// next is when the bytes let through so far are paid for.
+	next time.Time
+}
+
+// wait lets n bytes through at rate bytes per second: it waits until they
+// are paid for, or ctx is done. Time not spent transferring isn't saved up,
+// so idle periods don't allow bursts.
+func (l *rateLimiter) wait(ctx context.Context, rate int64, n int) error {
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	l.next = l.next.Add(time.Duration(int64(n) * int64(time.Second) / rate))
+	d := l.next.Sub(now)
+	l.mu.Unlock()
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// throttledReader reads at most rate bytes per second from r, with the
+// other readers of l.
+type throttledReader struct {
+	io.Reader
+	ctx  context.Context
+	l    *rateLimiter
+	rate int64
+}
+
+func (r *throttledReader) Read(p []byte) (int, error) {
+	// small reads keep the pace smooth
+	if int64(len(p)) > r.rate/10+1 {
+		p = p[:r.rate/10+1]
+	}
+	n, err := r.Reader.Read(p)
+	if n > 0 {
+		if werr := r.l.wait(r.ctx, r.rate, n); werr != nil && err == nil {
+			err = werr
+		}
+	}
+	return n, err
+}
+
+// throttledBody throttles a request body, see UploadRate.
+type throttledBody struct {
+	io.ReadSeeker
+	throttledReader
+}
+
+func newThrottledBody(ctx context.Context, body io.ReadSeeker, l *rateLimiter, rate int64) *throttledBody {
+	return &throttledBody{body, throttledReader{body, ctx, l, rate}}
+}
+
+func (b *throttledBody) Read(p []byte) (int, error) { return b.throttledReader.Read(p) }
+
+// Close closes the body if it is an io.Closer, like a multipart body.
+func (b *throttledBody) Close() error {
+	if c, ok := b.ReadSeeker.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// throttledResponse throttles a response body, see DownloadRate.
+type throttledResponse struct {
+	throttledReader
+	io.Closer
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestBandwidthLimits(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("bandwidth limits", func() {
+		var received int64
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			received, _ = io.Copy(io.Discard, r.Body)
+			w.Write([]byte(strings.Repeat("x", 30000)))
+		}))
+		defer srv.Close()
+		s := testSettings(srv.URL)
+
+		It("paces uploads", func() {
+			c := &Client{UploadRate: 100000}
+			start := time.Now()
+			res, err := c.Action(s, "codes").Request("POST", strings.NewReader(strings.Repeat("y", 30000)))
+			Expect(err, ToBeNil)
+			res.Body.Close()
+			Expect(received, ToEqual, int64(30000))
+			Expect(time.Since(start) >= 250*time.Millisecond, ToBeTrue)
+		})
+
+		It("paces downloads", func() {
+			c := &Client{DownloadRate: 100000}
+			start := time.Now()
+			res, err := c.Action(s, "codes").Request("GET", nil)
+			Expect(err, ToBeNil)
+			n, _ := io.Copy(io.Discard, res.Body)
+			res.Body.Close()
+			Expect(n, ToEqual, int64(30000))
+			Expect(time.Since(start) >= 250*time.Millisecond, ToBeTrue)
+		})
+
+		It("leaves transfers alone by default", func() {
+			start := time.Now()
+			res, err := (&Client{}).Action(s, "codes").Request("GET", nil)
+			Expect(err, ToBeNil)
+			io.Copy(io.Discard, res.Body)
+			res.Body.Close()
+			Expect(time.Since(start) < 250*time.Millisecond, ToBeTrue)
+		})
+	})
+}
@@ -0,0 +1,96 @@
+package cache
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Getter is implemented by Cache, Local and Namespace, so code reading
+// items can depend on it rather than on one of them.
+type Getter interface {
+	Get(key string) (interface{}, error)
+}
+
+// Setter is implemented by Cache, Local and Namespace.
+type Setter interface {
+	Set(key string, value interface{}, ttl ...int) error
+}
+
+// KV is implemented by Cache, Local and Namespace, e.g. to swap a Local in
+// front of a Cache, or a fake in tests.
+type KV interface {
+	Getter
+	Setter
+	Delete(key string) error
+}
+
+// API is the item operations of a Cache, for code that takes a cache to
+// depend on, so it can be tested with a fake, e.g. one of the mocks
+// package.
+type API interface {
+	KV
+	Put(key string, item *Item) error
+	GetItem(key string) (Item, error)
+	CompareAndSwap(key string, item *Item) error
+	Increment(key string, amount int64) (interface{}, error)
+}
+
+// Store is the minimal byte-oriented cache interface most Go caching
+// libraries build on, implemented by Bytes. Get returns ErrKeyNotFound for
+// keys that aren't cached, adapters to interfaces expecting another miss
+// error translate it:
+//
+//	func (s autocertCache) Get(ctx context.Context, key string) ([]byte, error) {
+//		data, err := s.Store.Get(ctx, key)
+//		if err == cache.ErrKeyNotFound {
+//			return nil, autocert.ErrCacheMiss
+//		}
+//		return data, err
+//	}
+type Store interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, key string) error
+}
+
+// Bytes stores byte slices in a Cache, base64 encoded so binary values
+// survive the trip through JSON, it implements Store. The contexts are
+// ignored.
+type Bytes struct {
+	Cache *Cache
+}
+
+// Get gets the value at key, ErrKeyNotFound if it isn't cached.
+func (b Bytes) Get(ctx context.Context, key string) ([]byte, error) {
+	item, err := b.Cache.GetItem(key)
+	if err != nil {
+		return nil, err
+	}
+	str, ok := item.Value.(string)
+	if !ok {
+		return nil, fmt.Errorf("cache: decoding %s: not a string", key)
+	}
+	value, err := base64.StdEncoding.DecodeString(str)
+	if err != nil {
+		return nil, fmt.Errorf("cache: decoding %s: %w", key, err)
+	}
+	return value, nil
+}
+
+// Set stores value at key, a ttl of 0 uses the server's default
+// expiration.
+func (b Bytes) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return b.Cache.Put(key, &Item{Value: base64.StdEncoding.EncodeToString(value), Expiration: ttl})
+}
+
+// Delete removes key from the cache, keys that aren't cached are ignored.
+func (b Bytes) Delete(ctx context.Context, key string) error {
+	err := b.Cache.Delete(key)
+	if isStatus(err, http.StatusNotFound) {
+		return nil
+	}
+	return err
+}
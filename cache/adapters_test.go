@@ -0,0 +1,43 @@
+package cache_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/iron-io/iron_go3/cache"
+	. "github.com/jeffh/go.bdd"
+)
+
+var (
+	_ cache.KV    = &cache.Cache{}
+	_ cache.KV    = &cache.Local{}
+	_ cache.KV    = &cache.Namespace{}
+	_ cache.Store = cache.Bytes{}
+)
+
+func TestBytes(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("byte stores", func() {
+		c, _, done := testCache("bytes")
+		defer done()
+		var s cache.Store = cache.Bytes{Cache: c}
+		ctx := context.Background()
+
+		It("round trips binary values", func() {
+			value := []byte{0, 1, 0xfe, 0xff, '"'}
+			Expect(s.Set(ctx, "k", value, time.Hour), ToBeNil)
+			got, err := s.Get(ctx, "k")
+			Expect(err, ToBeNil)
+			Expect(got, ToDeepEqual, value)
+		})
+
+		It("reports misses", func() {
+			Expect(s.Delete(ctx, "k"), ToBeNil)
+			_, err := s.Get(ctx, "k")
+			Expect(err, ToEqual, cache.ErrKeyNotFound)
+			Expect(s.Delete(ctx, "k"), ToBeNil)
+		})
+	})
+}
@@ -0,0 +1,33 @@
+package cache
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// AliasStore keeps aliases in a Cache, it implements the worker package's
+// AliasStore so all producers see the code package a deploy made live.
+// Aliases are kept for MaxExpiration, IronCache doesn't keep items longer,
+// so set them again within that time, e.g. by deploying.
+type AliasStore struct {
+	Cache *Cache
+}
+
+func (s AliasStore) Get(alias string) (string, error) {
+	v, err := s.Cache.Get(alias)
+	if isStatus(err, http.StatusNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	codeName, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("cache: alias %s is a %T, not a code name", alias, v)
+	}
+	return codeName, nil
+}
+
+func (s AliasStore) Set(alias, codeName string) error {
+	return s.Cache.SetFor(alias, codeName, MaxExpiration)
+}
@@ -0,0 +1,32 @@
+package cache_test
+
+import (
+	"testing"
+
+	"github.com/iron-io/iron_go3/cache"
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestAliasStore(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("alias stores", func() {
+		c, _, done := testCache("aliases")
+		defer done()
+		s := cache.AliasStore{Cache: c}
+
+		It("returns nothing for unset aliases", func() {
+			codeName, err := s.Get("resize")
+			Expect(err, ToBeNil)
+			Expect(codeName, ToEqual, "")
+		})
+
+		It("points aliases at code names", func() {
+			Expect(s.Set("resize", "resize-blue"), ToBeNil)
+			Expect(s.Set("resize", "resize-green"), ToBeNil)
+			codeName, err := s.Get("resize")
+			Expect(err, ToBeNil)
+			Expect(codeName, ToEqual, "resize-green")
+		})
+	})
+}
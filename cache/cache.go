@@ -3,6 +3,7 @@ package cache
 
 import (
 	"bytes"
+	"encoding/base64"
 	"encoding/gob"
 	"encoding/json"
 	"fmt"
@@ -20,6 +21,19 @@ var (
 type Cache struct {
 	Settings config.Settings
 	Name     string
+	// Client makes the requests, api.DefaultClient if nil.
+	Client *api.Client
+	// RequireConfirmation makes Clear and Destroy refuse to run unless the
+	// cache's name is passed to them as confirmation.
+	RequireConfirmation bool
+	// TrackKeys records the keys written with Put in index items, see
+	// KeysIndex, so they can be listed with ListKeys and swept with
+	// DeletePrefix and ExpirePrefix. Every write also reads one of 16 index
+	// items, and writing a new key rewrites that item whole, so writes cost
+	// one or two more requests and slow down as the index grows. Writers
+	// racing on an index item retry. As an index item has to fit in a
+	// cache item, it suits caches of some thousand keys, not unbounded ones.
+	TrackKeys bool
 }
 
 type Item struct {
@@ -30,10 +44,18 @@ type Item struct {
 	// Number of seconds until expiration. The zero value defaults to 7 days,
 	// maximum is 30 days.
 	Expiration time.Duration
+	// ExpiresAt is an absolute alternative to Expiration, it is used if set.
+	ExpiresAt time.Time
 	// Caches item only if the key is currently cached.
 	Replace bool
 	// Caches item only if the key isn't currently cached.
 	Add bool
+	// Caches item only if the cached item's cas token is still Cas, see
+	// Gets and CompareAndSwap.
+	Cas uint64
+	// CreatedAt is when the item was put, as read with GetItem. Put
+	// ignores it.
+	CreatedAt time.Time
 }
 
 // New returns a struct ready to make requests with.
@@ -43,19 +65,13 @@ func New(cacheName string) *Cache {
 }
 
 func (c *Cache) caches(suffix ...string) *api.URL {
-	return api.Action(c.Settings, "caches", suffix...)
+	return c.Client.Action(c.Settings, "caches", suffix...)
 }
 
+// ListCaches returns a page of the project's caches, see the ListCaches
+// function.
 func (c *Cache) ListCaches(page, perPage int) (caches []*Cache, err error) {
-	out := []struct {
-		Project_id string
-		Name       string
-	}{}
-
-	err = c.caches().
-		QueryAdd("page", "%d", page).
-		QueryAdd("per_page", "%d", perPage).
-		Req("GET", nil, &out)
+	out, err := listCaches(c.Client, c.Settings, page, perPage)
 	if err != nil {
 		return
 	}
@@ -65,6 +81,7 @@ func (c *Cache) ListCaches(page, perPage int) (caches []*Cache, err error) {
 		caches = append(caches, &Cache{
 			Settings: c.Settings,
 			Name:     item.Name,
+			Client:   c.Client,
 		})
 	}
 
@@ -73,29 +90,34 @@ func (c *Cache) ListCaches(page, perPage int) (caches []*Cache, err error) {
 
 func (c *Cache) ServerVersion() (version string, err error) {
 	out := map[string]string{}
-	err = api.VersionAction(c.Settings).Req("GET", nil, &out)
+	err = c.Client.VersionAction(c.Settings).Req("GET", nil, &out)
 	if err != nil {
 		return
 	}
 	return out["version"], nil
 }
 
-func (c *Cache) Clear() (err error) {
-	return c.caches(c.Name, "clear").Req("POST", nil, nil)
-}
-
 // Put adds an Item to the cache, overwriting any existing key of the same name.
 func (c *Cache) Put(key string, item *Item) (err error) {
+	expiresIn, err := item.expiresIn(time.Now())
+	if err != nil {
+		return err
+	}
+	if err := c.track(key); err != nil {
+		return err
+	}
 	in := struct {
 		Value     interface{} `json:"value"`
 		ExpiresIn int         `json:"expires_in,omitempty"`
 		Replace   bool        `json:"replace,omitempty"`
 		Add       bool        `json:"add,omitempty"`
+		Cas       uint64      `json:"cas,omitempty"`
 	}{
 		Value:     item.Value,
-		ExpiresIn: int(item.Expiration.Seconds()),
+		ExpiresIn: expiresIn,
 		Replace:   item.Replace,
 		Add:       item.Add,
+		Cas:       item.Cas,
 	}
 
 	return c.caches(c.Name, "items", key).Req("PUT", &in, nil)
@@ -134,25 +156,34 @@ func (c *Cache) Set(key string, value interface{}, ttl ...int) (err error) {
 	}
 	return
 }
-func (c *Cache) Add(key string, value ...interface{}) (err error) {
+
+// Add is Set, but only if key isn't already cached. ErrKeyExists is
+// returned otherwise.
+func (c *Cache) Add(key string, value interface{}, ttl ...int) (err error) {
 	str, err := anyToString(value)
 	if err == nil {
-		err = c.Put(key, &Item{
-			Value: str, Expiration: time.Duration(123) * time.Second, Add: true,
-		})
+		err = c.AddItem(key, &Item{Value: str, Expiration: ttlDuration(ttl)})
 	}
 	return
 }
-func (c *Cache) Replace(key string, value ...interface{}) (err error) {
+
+// Replace is Set, but only if key is already cached. ErrKeyNotFound is
+// returned otherwise.
+func (c *Cache) Replace(key string, value interface{}, ttl ...int) (err error) {
 	str, err := anyToString(value)
 	if err == nil {
-		err = c.Put(key, &Item{
-			Value: str, Expiration: time.Duration(123) * time.Second, Replace: true,
-		})
+		err = c.ReplaceItem(key, &Item{Value: str, Expiration: ttlDuration(ttl)})
 	}
 	return
 }
 
+func ttlDuration(ttl []int) time.Duration {
+	if len(ttl) > 0 {
+		return time.Duration(ttl[0]) * time.Second
+	}
+	return 0
+}
+
 // Increment increments the corresponding item's value.
 func (c *Cache) Increment(key string, amount int64) (value interface{}, err error) {
 	in := map[string]int64{"amount": amount}
@@ -169,13 +200,20 @@ func (c *Cache) Increment(key string, amount int64) (value interface{}, err erro
 
 // Get gets an item from the cache.
 func (c *Cache) Get(key string) (value interface{}, err error) {
+	value, _, err = c.get(key)
+	return
+}
+
+// get gets an item's value and the time it expires at.
+func (c *Cache) get(key string) (value interface{}, expires time.Time, err error) {
 	out := struct {
-		Cache string      `json:"cache"`
-		Key   string      `json:"key"`
-		Value interface{} `json:"value"`
+		Cache   string      `json:"cache"`
+		Key     string      `json:"key"`
+		Value   interface{} `json:"value"`
+		Expires time.Time   `json:"expires"`
 	}{}
 	if err = c.caches(c.Name, "items", key).Req("GET", nil, &out); err == nil {
-		value = out.Value
+		value, expires = out.Value, out.Expires
 	}
 	return
 }
@@ -188,6 +226,13 @@ func (c *Cache) GetMeta(key string) (value map[string]interface{}, err error) {
 
 // Delete removes an item from the cache.
 func (c *Cache) Delete(key string) (err error) {
+	if err := c.deleteItem(key); err != nil {
+		return err
+	}
+	return c.untrack(key)
+}
+
+func (c *Cache) deleteItem(key string) error {
 	return c.caches(c.Name, "items", key).Req("DELETE", nil, nil)
 }
 
@@ -199,7 +244,7 @@ type Codec struct {
 func (cd Codec) Put(c *Cache, key string, item *Item) (err error) {
 	bytes, err := cd.Marshal(item.Object)
 	if err != nil {
-		return
+		return fmt.Errorf("cache: encoding %s: %w", key, err)
 	}
 
 	item.Value = string(bytes)
@@ -213,23 +258,42 @@ func (cd Codec) Get(c *Cache, key string, object interface{}) (err error) {
 		return
 	}
 
-	err = cd.Unmarshal([]byte(value.(string)), object)
+	data, err := valueBytes(value)
+	if err == nil {
+		err = cd.Unmarshal(data, object)
+	}
 	if err != nil {
-		return
+		return fmt.Errorf("cache: decoding %s: %w", key, err)
 	}
+	return nil
+}
 
-	return
+// valueBytes returns the stored form of a value read from the cache, numbers
+// come back from the server as numbers rather than strings.
+func valueBytes(value interface{}) ([]byte, error) {
+	if s, ok := value.(string); ok {
+		return []byte(s), nil
+	}
+	return json.Marshal(value)
 }
 
+// gob output is binary, it is base64 encoded to survive the trip through
+// JSON to the server.
 func gobMarshal(v interface{}) ([]byte, error) {
 	writer := bytes.Buffer{}
 	enc := gob.NewEncoder(&writer)
 	err := enc.Encode(v)
-	return writer.Bytes(), err
+	if err != nil {
+		return nil, err
+	}
+	return []byte(base64.StdEncoding.EncodeToString(writer.Bytes())), nil
 }
 
 func gobUnmarshal(marshalled []byte, v interface{}) error {
-	reader := bytes.NewBuffer(marshalled)
-	dec := gob.NewDecoder(reader)
+	data, err := base64.StdEncoding.DecodeString(string(marshalled))
+	if err != nil {
+		return err
+	}
+	dec := gob.NewDecoder(bytes.NewReader(data))
 	return dec.Decode(v)
 }
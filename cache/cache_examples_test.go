@@ -41,8 +41,8 @@ func Example2Incrementing() {
 	// Output:
 	// <nil>
 	// 52 <nil>
-	// 400 Bad Request: Cannot increment or decrement non-numeric value
-	// 400 Bad Request: Cannot increment or decrement non-numeric value
+	// POST caches/test_cache/items/string_item/increment: 400 Bad Request: Cannot increment or decrement non-numeric value
+	// POST caches/test_cache/items/complex_item/increment: 400 Bad Request: Cannot increment or decrement non-numeric value
 }
 
 func Example3Decrementing() {
@@ -58,8 +58,8 @@ func Example3Decrementing() {
 	// Output:
 	// <nil>
 	// 42 <nil>
-	// 400 Bad Request: Cannot increment or decrement non-numeric value
-	// 400 Bad Request: Cannot increment or decrement non-numeric value
+	// POST caches/test_cache/items/string_item/increment: 400 Bad Request: Cannot increment or decrement non-numeric value
+	// POST caches/test_cache/items/complex_item/increment: 400 Bad Request: Cannot increment or decrement non-numeric value
 }
 
 func Example4RetrievingData() {
@@ -97,5 +97,5 @@ func Example5DeletingData() {
 
 	p(c.Get("string_item"))
 	// Output:
-	// <nil> 404 Not Found: The resource, project, or endpoint being requested doesn't exist.
+	// <nil> GET caches/test_cache/items/string_item: 404 Not Found: The resource, project, or endpoint being requested doesn't exist.
 }
@@ -0,0 +1,98 @@
+package cache
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/iron-io/iron_go3/api"
+)
+
+var (
+	// ErrKeyExists is returned by AddItem when the key is already cached.
+	ErrKeyExists = errors.New("cache: key already exists")
+	// ErrKeyNotFound is returned by ReplaceItem and CompareAndSwap when the
+	// key isn't cached.
+	ErrKeyNotFound = errors.New("cache: key not found")
+	// ErrCasMismatch is returned by CompareAndSwap when the item changed
+	// since its cas token was read.
+	ErrCasMismatch = errors.New("cache: item was modified")
+)
+
+// AddItem puts item at key only if the key isn't cached yet, otherwise
+// ErrKeyExists is returned.
+func (c *Cache) AddItem(key string, item *Item) error {
+	in := *item
+	in.Add, in.Replace, in.Cas = true, false, 0
+	return putError(c.Put(key, &in))
+}
+
+// ReplaceItem puts item at key only if the key is already cached, otherwise
+// ErrKeyNotFound is returned.
+func (c *Cache) ReplaceItem(key string, item *Item) error {
+	in := *item
+	in.Add, in.Replace, in.Cas = false, true, 0
+	return putError(c.Put(key, &in))
+}
+
+// CompareAndSwap puts item at key only if the cached item's cas token still
+// matches item.Cas, as read with Gets. ErrCasMismatch is returned if it was
+// modified in the meantime, ErrKeyNotFound if it is gone.
+func (c *Cache) CompareAndSwap(key string, item *Item) error {
+	if item.Cas == 0 {
+		return errors.New("cache: CompareAndSwap needs the item's cas token")
+	}
+	in := *item
+	in.Add, in.Replace = false, false
+	return putError(c.Put(key, &in))
+}
+
+// Gets gets an item from the cache along with its cas token, for use with
+// CompareAndSwap.
+func (c *Cache) Gets(key string) (value interface{}, cas uint64, err error) {
+	out := struct {
+		Value interface{} `json:"value"`
+		Cas   uint64      `json:"cas"`
+	}{}
+	err = c.caches(c.Name, "items", key).Req("GET", nil, &out)
+	if isStatus(err, http.StatusNotFound) {
+		return nil, 0, ErrKeyNotFound
+	}
+	return out.Value, out.Cas, err
+}
+
+// The messages IronCache refuses conditional puts with.
+const (
+	msgKeyExists   = "Key already exists"
+	msgKeyNotFound = "Key not found"
+	msgCasMismatch = "CAS mismatch"
+)
+
+// putError turns the server's refusals of a conditional put into the
+// matching sentinel error. Other failures, e.g. a missing cache, are
+// returned as is.
+func putError(err error) error {
+	code := api.StatusCode(err)
+	var e *api.Error
+	if code == 0 || !errors.As(err, &e) {
+		return err
+	}
+	// the server's error reads "<status>: <msg>", its message may end in a
+	// period
+	_, msg, _ := strings.Cut(e.Err.Error(), ": ")
+	msg = strings.TrimSuffix(msg, ".")
+
+	switch {
+	case code == http.StatusNotFound && msg == msgKeyNotFound:
+		return ErrKeyNotFound
+	case code == http.StatusConflict && msg == msgCasMismatch:
+		return ErrCasMismatch
+	case code == http.StatusConflict && msg == msgKeyExists:
+		return ErrKeyExists
+	}
+	return err
+}
+
+func isStatus(err error, code int) bool {
+	return api.StatusCode(err) == code
+}
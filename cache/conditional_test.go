@@ -0,0 +1,95 @@
+package cache_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/iron-io/iron_go3/cache"
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestConditionalPuts(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("conditional puts", func() {
+		c, _, done := testCache("conditional")
+		defer done()
+
+		It("adds only missing keys", func() {
+			Expect(c.Add("a", "first"), ToBeNil)
+			Expect(c.Add("a", "second"), ToEqual, cache.ErrKeyExists)
+			v, err := c.Get("a")
+			Expect(err, ToBeNil)
+			Expect(v, ToEqual, "first")
+		})
+
+		It("replaces only existing keys", func() {
+			Expect(c.Replace("r", "value"), ToEqual, cache.ErrKeyNotFound)
+			Expect(c.Set("r", "old"), ToBeNil)
+			Expect(c.Replace("r", "new"), ToBeNil)
+			v, _ := c.Get("r")
+			Expect(v, ToEqual, "new")
+		})
+
+		It("swaps only unmodified items", func() {
+			Expect(c.Set("s", "one"), ToBeNil)
+			v, cas, err := c.Gets("s")
+			Expect(err, ToBeNil)
+			Expect(v, ToEqual, "one")
+
+			Expect(c.CompareAndSwap("s", &cache.Item{Value: "two", Cas: cas}), ToBeNil)
+			Expect(c.CompareAndSwap("s", &cache.Item{Value: "three", Cas: cas}), ToEqual, cache.ErrCasMismatch)
+			v, _ = c.Get("s")
+			Expect(v, ToEqual, "two")
+		})
+
+		It("reports missing items", func() {
+			_, _, err := c.Gets("missing")
+			Expect(err, ToEqual, cache.ErrKeyNotFound)
+			Expect(c.CompareAndSwap("missing", &cache.Item{Value: "x", Cas: 1}), ToEqual, cache.ErrKeyNotFound)
+		})
+	})
+}
+
+func TestConditionalPutErrors(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("conditional put errors", func() {
+		var status int
+		var body string
+		c, done := serveCache("errors", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, body, status)
+		}))
+		defer done()
+
+		put := func(code int, msg string) error {
+			status, body = code, msg
+			return c.AddItem("cas-key", &cache.Item{Value: "v"})
+		}
+
+		It("maps the server's refusals", func() {
+			Expect(put(http.StatusConflict, `{"msg":"Key already exists."}`), ToEqual, cache.ErrKeyExists)
+			Expect(put(http.StatusNotFound, `{"msg":"Key not found."}`), ToEqual, cache.ErrKeyNotFound)
+			Expect(put(http.StatusConflict, `{"msg":"CAS mismatch."}`), ToEqual, cache.ErrCasMismatch)
+		})
+
+		It("passes other failures through", func() {
+			for _, tc := range []struct {
+				code int
+				body string
+			}{
+				{http.StatusNotFound, `{"msg":"Cache not found."}`},
+				{http.StatusNotFound, `{"msg":"Project does not exist"}`},
+				{http.StatusConflict, `{"msg":"Cache is being modified."}`},
+				{http.StatusBadRequest, `{"msg":"Key already exists."}`},
+				{http.StatusPreconditionFailed, `{"msg":"Precondition failed."}`},
+			} {
+				err := put(tc.code, tc.body)
+				Expect(err, ToNotBeNil)
+				mapped := errors.Is(err, cache.ErrKeyExists) || errors.Is(err, cache.ErrKeyNotFound) || errors.Is(err, cache.ErrCasMismatch)
+				Expect(mapped, ToEqual, false)
+			}
+		})
+	})
+}
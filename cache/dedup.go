@@ -0,0 +1,34 @@
+package cache
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// DedupStore keeps keys in a Cache, it implements the mq package's
+// DedupStore so IronCache can deduplicate messages across consumers. The
+// contexts are ignored.
+type DedupStore struct {
+	Cache *Cache
+}
+
+func (s DedupStore) Add(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	err := s.Cache.AddItem(key, &Item{Value: 1, Expiration: ttl})
+	if err == ErrKeyExists {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s DedupStore) Set(ctx context.Context, key string, ttl time.Duration) error {
+	return s.Cache.Put(key, &Item{Value: 1, Expiration: ttl})
+}
+
+func (s DedupStore) Delete(ctx context.Context, key string) error {
+	err := s.Cache.Delete(key)
+	if isStatus(err, http.StatusNotFound) {
+		return nil
+	}
+	return err
+}
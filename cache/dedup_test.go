@@ -0,0 +1,43 @@
+package cache_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/iron-io/iron_go3/cache"
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestDedupStore(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("dedup stores", func() {
+		c, _, done := testCache("dedup")
+		defer done()
+		s := cache.DedupStore{Cache: c}
+		ctx := context.Background()
+
+		It("adds keys once", func() {
+			ok, err := s.Add(ctx, "k", time.Minute)
+			Expect(err, ToBeNil)
+			Expect(ok, ToBeTrue)
+			ok, err = s.Add(ctx, "k", time.Minute)
+			Expect(err, ToBeNil)
+			Expect(ok, ToEqual, false)
+		})
+
+		It("deletes keys", func() {
+			Expect(s.Delete(ctx, "k"), ToBeNil)
+			Expect(s.Delete(ctx, "k"), ToBeNil)
+			ok, _ := s.Add(ctx, "k", time.Minute)
+			Expect(ok, ToBeTrue)
+		})
+
+		It("sets keys", func() {
+			Expect(s.Set(ctx, "other", time.Minute), ToBeNil)
+			ok, _ := s.Add(ctx, "other", time.Minute)
+			Expect(ok, ToEqual, false)
+		})
+	})
+}
@@ -0,0 +1,33 @@
+package cache
+
+import "errors"
+
+// ErrNotConfirmed is returned by Clear and Destroy when RequireConfirmation
+// is set and the cache's name wasn't passed as confirmation.
+var ErrNotConfirmed = errors.New("cache: destructive call not confirmed, pass the cache name to confirm")
+
+// Clear removes all items from the cache.
+func (c *Cache) Clear(confirm ...string) (err error) {
+	if err = c.confirmed(confirm); err != nil {
+		return
+	}
+	return c.caches(c.Name, "clear").Req("POST", nil, nil)
+}
+
+// Destroy deletes the cache along with all of its items.
+func (c *Cache) Destroy(confirm ...string) (err error) {
+	if err = c.confirmed(confirm); err != nil {
+		return
+	}
+	return c.caches(c.Name).Req("DELETE", nil, nil)
+}
+
+func (c *Cache) confirmed(confirm []string) error {
+	if !c.RequireConfirmation {
+		return nil
+	}
+	if len(confirm) == 0 || confirm[0] != c.Name {
+		return ErrNotConfirmed
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package cache_test
+
+import (
+	"testing"
+
+	"github.com/iron-io/iron_go3/cache"
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestDestroy(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("clearing and destroying caches", func() {
+		c, _, done := testCache("doomed")
+		defer done()
+
+		It("clears items", func() {
+			Expect(c.Set("k", "v"), ToBeNil)
+			Expect(c.Clear(), ToBeNil)
+			_, err := c.Get("k")
+			Expect(err, ToNotBeNil)
+		})
+
+		It("destroys the cache", func() {
+			Expect(c.Set("k", "v"), ToBeNil)
+			Expect(c.Destroy(), ToBeNil)
+			caches, _ := cache.ListAll(c.Settings)
+			Expect(len(caches), ToEqual, 0)
+		})
+
+		It("requires confirmation when asked to", func() {
+			c.RequireConfirmation = true
+			defer func() { c.RequireConfirmation = false }()
+			Expect(c.Set("k", "v"), ToBeNil)
+
+			Expect(c.Clear(), ToEqual, cache.ErrNotConfirmed)
+			Expect(c.Destroy("other"), ToEqual, cache.ErrNotConfirmed)
+			v, _ := c.Get("k")
+			Expect(v, ToEqual, "v")
+
+			Expect(c.Destroy("doomed"), ToBeNil)
+			_, err := c.Get("k")
+			Expect(err, ToNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,60 @@
+package cache
+
+import (
+	"fmt"
+	"time"
+)
+
+// MaxExpiration is the longest an item can be kept in the cache.
+const MaxExpiration = 30 * 24 * time.Hour
+
+// SetFor is Set with the expiration given as a duration.
+func (c *Cache) SetFor(key string, value interface{}, ttl time.Duration) (err error) {
+	str, err := anyToString(value)
+	if err == nil {
+		err = c.Put(key, &Item{Value: str, Expiration: ttl})
+	}
+	return
+}
+
+// SetUntil is Set with the item expiring at deadline.
+func (c *Cache) SetUntil(key string, value interface{}, deadline time.Time) (err error) {
+	str, err := anyToString(value)
+	if err == nil {
+		err = c.Put(key, &Item{Value: str, ExpiresAt: deadline})
+	}
+	return
+}
+
+// Expiry returns the time at which the item at key expires.
+func (c *Cache) Expiry(key string) (expires time.Time, err error) {
+	out := struct {
+		Expires time.Time `json:"expires"`
+	}{}
+	err = c.caches(c.Name, "items", key).Req("GET", nil, &out)
+	return out.Expires, err
+}
+
+// expiresIn returns the expires_in seconds to send for item, 0 for the
+// server default. Partial seconds are rounded up so short expirations don't
+// turn into the default.
+func (item *Item) expiresIn(now time.Time) (int, error) {
+	ttl := item.Expiration
+	if !item.ExpiresAt.IsZero() {
+		ttl = item.ExpiresAt.Sub(now)
+		if ttl <= 0 {
+			return 0, fmt.Errorf("cache: expiration %s is in the past", item.ExpiresAt.Format(time.RFC3339))
+		}
+	}
+	switch {
+	case ttl < 0:
+		return 0, fmt.Errorf("cache: negative expiration %s", ttl)
+	case ttl > MaxExpiration:
+		return 0, fmt.Errorf("cache: expiration %s exceeds the maximum of %s", ttl, MaxExpiration)
+	}
+	seconds := ttl / time.Second
+	if ttl%time.Second != 0 {
+		seconds++
+	}
+	return int(seconds), nil
+}
@@ -0,0 +1,49 @@
+package cache_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/iron-io/iron_go3/cache"
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestExpiry(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("item expiration", func() {
+		c, _, done := testCache("expiry")
+		defer done()
+
+		It("accepts durations", func() {
+			before := time.Now()
+			Expect(c.SetFor("d", "v", time.Hour), ToBeNil)
+			expires, err := c.Expiry("d")
+			Expect(err, ToBeNil)
+			Expect(expires.After(before.Add(time.Hour-time.Second)), ToBeTrue)
+			Expect(expires.Before(time.Now().Add(time.Hour+time.Second)), ToBeTrue)
+		})
+
+		It("accepts deadlines", func() {
+			deadline := time.Now().Add(10 * time.Minute)
+			Expect(c.SetUntil("t", "v", deadline), ToBeNil)
+			expires, err := c.Expiry("t")
+			Expect(err, ToBeNil)
+			Expect(expires.Sub(deadline) < 2*time.Second, ToBeTrue)
+			Expect(deadline.Sub(expires) < 2*time.Second, ToBeTrue)
+		})
+
+		It("rounds partial seconds up", func() {
+			Expect(c.SetFor("ms", "v", 10*time.Millisecond), ToBeNil)
+			expires, _ := c.Expiry("ms")
+			Expect(expires.Before(time.Now().Add(2*time.Second)), ToBeTrue)
+		})
+
+		It("rejects expirations the server doesn't allow", func() {
+			Expect(c.SetFor("long", "v", cache.MaxExpiration+time.Hour), ToNotBeNil)
+			Expect(c.SetFor("neg", "v", -time.Second), ToNotBeNil)
+			Expect(c.SetUntil("past", "v", time.Now().Add(-time.Minute)), ToNotBeNil)
+			Expect(c.SetFor("max", "v", cache.MaxExpiration), ToBeNil)
+		})
+	})
+}
@@ -0,0 +1,174 @@
+package cache_test
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iron-io/iron_go3/cache"
+	"github.com/iron-io/iron_go3/config"
+)
+
+// fakeCache is an in memory stand in for the IronCache items API.
+type fakeCache struct {
+	mu    sync.Mutex
+	items map[string]fakeItem
+	cas   uint64
+}
+
+type fakeItem struct {
+	value   interface{}
+	cas     uint64
+	created time.Time
+	expires time.Time
+}
+
+func (f *fakeCache) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.URL.Path == "/1/projects/project/caches" {
+		f.list(w, r)
+		return
+	}
+
+	// /1/projects/project/caches/{cache}/items/{key}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/1/projects/project/caches/"), "/")
+	if (len(parts) == 1 && r.Method == "DELETE") || (len(parts) == 2 && parts[1] == "clear" && r.Method == "POST") {
+		f.clear(parts[0])
+		w.Write([]byte(`{"msg":"Deleted."}`))
+		return
+	}
+	if len(parts) < 3 || parts[1] != "items" {
+		http.Error(w, `{"msg":"not found"}`, http.StatusNotFound)
+		return
+	}
+	key := parts[0] + "/" + parts[2]
+	item, found := f.items[key]
+	if found && time.Now().After(item.expires) {
+		delete(f.items, key)
+		found = false
+	}
+
+	switch r.Method {
+	case "GET":
+		if !found {
+			http.Error(w, `{"msg":"Key not found"}`, http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"cache": parts[0], "key": parts[2], "value": item.value,
+			"cas": item.cas, "created": item.created, "expires": item.expires,
+		})
+	case "PUT":
+		var in struct {
+			Value     interface{} `json:"value"`
+			ExpiresIn int         `json:"expires_in"`
+			Add       bool        `json:"add"`
+			Replace   bool        `json:"replace"`
+			Cas       uint64      `json:"cas"`
+		}
+		json.NewDecoder(r.Body).Decode(&in)
+		switch {
+		case in.Add && found:
+			http.Error(w, `{"msg":"Key already exists."}`, http.StatusConflict)
+			return
+		case (in.Replace || in.Cas != 0) && !found:
+			http.Error(w, `{"msg":"Key not found."}`, http.StatusNotFound)
+			return
+		case in.Cas != 0 && in.Cas != item.cas:
+			http.Error(w, `{"msg":"CAS mismatch."}`, http.StatusConflict)
+			return
+		}
+		if in.ExpiresIn == 0 {
+			in.ExpiresIn = 7 * 24 * 3600
+		}
+		f.cas++
+		now := time.Now()
+		f.items[key] = fakeItem{value: in.Value, cas: f.cas, created: now, expires: now.Add(time.Duration(in.ExpiresIn) * time.Second)}
+		w.Write([]byte(`{"msg":"Stored."}`))
+	case "DELETE":
+		if !found {
+			http.Error(w, `{"msg":"Key not found"}`, http.StatusNotFound)
+			return
+		}
+		delete(f.items, key)
+		w.Write([]byte(`{"msg":"Deleted."}`))
+	case "POST":
+		var in struct {
+			Amount int64 `json:"amount"`
+		}
+		json.NewDecoder(r.Body).Decode(&in)
+		if !found {
+			http.Error(w, `{"msg":"Key not found"}`, http.StatusNotFound)
+			return
+		}
+		n, ok := item.value.(float64)
+		if !ok {
+			http.Error(w, `{"msg":"Cannot increment or decrement non-numeric value"}`, http.StatusBadRequest)
+			return
+		}
+		f.cas++
+		item.value, item.cas = n+float64(in.Amount), f.cas
+		f.items[key] = item
+		json.NewEncoder(w).Encode(map[string]interface{}{"msg": "Added", "value": item.value})
+	}
+}
+
+func (f *fakeCache) clear(name string) {
+	for key := range f.items {
+		if strings.HasPrefix(key, name+"/") {
+			delete(f.items, key)
+		}
+	}
+}
+
+func (f *fakeCache) list(w http.ResponseWriter, r *http.Request) {
+	seen := map[string]bool{}
+	var names []string
+	for key := range f.items {
+		name := strings.SplitN(key, "/", 2)[0]
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+	out := []map[string]string{}
+	for i := page * perPage; i < len(names) && i < (page+1)*perPage; i++ {
+		out = append(out, map[string]string{"project_id": "project", "name": names[i]})
+	}
+	json.NewEncoder(w).Encode(out)
+}
+
+// testCache returns a Cache talking to a fresh fakeCache.
+func testCache(name string) (*cache.Cache, *fakeCache, func()) {
+	f := &fakeCache{items: map[string]fakeItem{}}
+	c, done := serveCache(name, f)
+	return c, f, done
+}
+
+// serveCache returns a Cache talking to h.
+func serveCache(name string, h http.Handler) (*cache.Cache, func()) {
+	srv := httptest.NewServer(h)
+	host, port, _ := net.SplitHostPort(strings.TrimPrefix(srv.URL, "http://"))
+	p, _ := strconv.Atoi(port)
+	c := &cache.Cache{Name: name, Settings: config.Settings{
+		Token:      "token",
+		ProjectId:  "project",
+		Host:       host,
+		Port:       uint16(p),
+		Scheme:     "http",
+		ApiVersion: "1",
+	}}
+	return c, srv.Close
+}
@@ -0,0 +1,37 @@
+package cache
+
+import (
+	"net/http"
+	"time"
+)
+
+// GetItem gets an item from the cache along with its metadata: its cas
+// token, when it was put and when it expires, e.g. to refresh items ahead
+// of their expiry. ErrKeyNotFound is returned if the key isn't cached.
+// Putting the item back keeps its expiry, and only succeeds if it wasn't
+// modified in the meantime, see CompareAndSwap.
+func (c *Cache) GetItem(key string) (Item, error) {
+	out := struct {
+		Value   interface{} `json:"value"`
+		Cas     uint64      `json:"cas"`
+		Created time.Time   `json:"created"`
+		Expires time.Time   `json:"expires"`
+	}{}
+	err := c.caches(c.Name, "items", key).Req("GET", nil, &out)
+	if isStatus(err, http.StatusNotFound) {
+		return Item{}, ErrKeyNotFound
+	}
+	if err != nil {
+		return Item{}, err
+	}
+	return Item{Value: out.Value, Cas: out.Cas, CreatedAt: out.Created, ExpiresAt: out.Expires}, nil
+}
+
+// TTL returns how long until the item expires, as of now, or 0 if its
+// expiry isn't known.
+func (item Item) TTL(now time.Time) time.Duration {
+	if item.ExpiresAt.IsZero() {
+		return 0
+	}
+	return max(item.ExpiresAt.Sub(now), 0)
+}
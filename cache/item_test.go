@@ -0,0 +1,47 @@
+package cache_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/iron-io/iron_go3/cache"
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestGetItem(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("item metadata", func() {
+		c, _, done := testCache("items")
+		defer done()
+
+		It("is read along with the value", func() {
+			before := time.Now()
+			Expect(c.SetFor("k", "v", time.Hour), ToBeNil)
+			item, err := c.GetItem("k")
+			Expect(err, ToBeNil)
+			Expect(item.Value, ToEqual, "v")
+			Expect(item.Cas != 0, ToBeTrue)
+			Expect(item.CreatedAt.Before(before.Add(-time.Second)), ToEqual, false)
+			Expect(item.CreatedAt.After(time.Now()), ToEqual, false)
+			ttl := item.TTL(time.Now())
+			Expect(ttl > time.Hour-2*time.Second && ttl <= time.Hour+time.Second, ToBeTrue)
+			Expect(item.TTL(item.ExpiresAt.Add(time.Minute)), ToEqual, time.Duration(0))
+		})
+
+		It("can be put back unless it was modified", func() {
+			item, err := c.GetItem("k")
+			Expect(err, ToBeNil)
+			item.Value = "refreshed"
+			Expect(c.Put("k", &item), ToBeNil)
+			Expect(c.CompareAndSwap("k", &item), ToEqual, cache.ErrCasMismatch)
+			v, _ := c.Get("k")
+			Expect(v, ToEqual, "refreshed")
+		})
+
+		It("reports missing keys", func() {
+			_, err := c.GetItem("missing")
+			Expect(err, ToEqual, cache.ErrKeyNotFound)
+		})
+	})
+}
@@ -0,0 +1,61 @@
+package cache
+
+import (
+	"iter"
+
+	"github.com/iron-io/iron_go3/api"
+	"github.com/iron-io/iron_go3/config"
+)
+
+// MaxPerPage is the largest page ListCaches can return.
+const MaxPerPage = 100
+
+// CacheSummary describes a cache as returned by ListCaches.
+type CacheSummary struct {
+	ProjectId string `json:"project_id"`
+	Name      string `json:"name"`
+}
+
+// ListCaches returns page (counting from 0) of the project's caches, with
+// perPage caches per page up to MaxPerPage.
+func ListCaches(s config.Settings, page, perPage int) ([]CacheSummary, error) {
+	return listCaches(nil, s, page, perPage)
+}
+
+func listCaches(client *api.Client, s config.Settings, page, perPage int) ([]CacheSummary, error) {
+	var out []CacheSummary
+	err := client.Action(s, "caches").
+		QueryAdd("page", "%d", page).
+		QueryAdd("per_page", "%d", perPage).
+		Req("GET", nil, &out)
+	return out, err
+}
+
+// ListAll returns all of the project's caches, fetching as many pages as
+// needed.
+func ListAll(s config.Settings) ([]CacheSummary, error) {
+	var all []CacheSummary
+	for c, err := range AllCaches(s) {
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, c)
+	}
+	return all, nil
+}
+
+// AllCaches returns an iterator over the project's caches, fetching pages
+// as needed.
+func AllCaches(s config.Settings) iter.Seq2[CacheSummary, error] {
+	return api.Pages(MaxPerPage, func(page int) ([]CacheSummary, error) {
+		return ListCaches(s, page, MaxPerPage)
+	})
+}
+
+// AllCaches iterates over the caches of c's project, requested with
+// c.Client, see the AllCaches function.
+func (c *Cache) AllCaches() iter.Seq2[*Cache, error] {
+	return api.Pages(MaxPerPage, func(page int) ([]*Cache, error) {
+		return c.ListCaches(page, MaxPerPage)
+	})
+}
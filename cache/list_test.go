@@ -0,0 +1,65 @@
+package cache_test
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/iron-io/iron_go3/api"
+	"github.com/iron-io/iron_go3/cache"
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestListCaches(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("listing caches", func() {
+		c, _, done := testCache("c000")
+		defer done()
+		for i := 0; i < 150; i++ {
+			c.Name = fmt.Sprintf("c%03d", i)
+			c.Set("k", "v")
+		}
+
+		It("returns a page", func() {
+			caches, err := cache.ListCaches(c.Settings, 1, 10)
+			Expect(err, ToBeNil)
+			Expect(len(caches), ToEqual, 10)
+			Expect(caches[0], ToEqual, cache.CacheSummary{ProjectId: "project", Name: "c010"})
+		})
+
+		It("returns every page", func() {
+			caches, err := cache.ListAll(c.Settings)
+			Expect(err, ToBeNil)
+			Expect(len(caches), ToEqual, 150)
+			Expect(caches[149].Name, ToEqual, "c149")
+		})
+
+		It("keeps the method returning caches", func() {
+			caches, err := c.ListCaches(0, 2)
+			Expect(err, ToBeNil)
+			Expect(len(caches), ToEqual, 2)
+			Expect(caches[1].Name, ToEqual, "c001")
+			Expect(caches[1].Settings, ToEqual, c.Settings)
+		})
+
+		It("iterates over the caches with its client", func() {
+			var requests requestCounter
+			c := *c
+			c.Client = &api.Client{Metrics: &requests}
+			n := 0
+			for cc, err := range c.AllCaches() {
+				Expect(err, ToBeNil)
+				Expect(cc.Client, ToEqual, c.Client)
+				n++
+			}
+			Expect(n, ToEqual, 150)
+			Expect(int(requests.Load()), ToEqual, 2)
+		})
+	})
+}
+
+// requestCounter counts the requests of a client.
+type requestCounter struct{ atomic.Int64 }
+
+func (r *requestCounter) ObserveRequest(api.RequestStats) { r.Add(1) }
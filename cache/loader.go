@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ErrNoValue is returned by a Loader's Load for keys that have no value,
+// see Loader.NegativeTTL.
+var ErrNoValue = errors.New("cache: no value")
+
+// negativeValue is stored in place of the values of keys that have none.
+const negativeValue = "\x00cache:no-value"
+
+// A Loader reads values of type T through a cache, loading the missing
+// ones with Load and storing them for TTL. Concurrent Gets in this process
+// for the same key share one read of the cache, one Load and one Put, so a
+// traffic spike on a missing key doesn't turn into a stampede:
+//
+//	users := cache.NewLoader(c, cache.JSON, time.Hour, func(key string) (User, error) {
+//		u, err := db.User(key)
+//		if err == sql.ErrNoRows {
+//			return u, cache.ErrNoValue
+//		}
+//		return u, err
+//	})
+//	users.NegativeTTL = time.Minute
+//	u, err := users.Get("42")
+type Loader[T any] struct {
+	Cache *Cache
+	Codec Codec
+	// TTL is how long loaded values are stored, the server's default
+	// expiration if 0.
+	TTL  time.Duration
+	Load func(key string) (T, error)
+	// NegativeTTL, if set, is how long a key Load returned ErrNoValue for
+	// is remembered to have none, so Get returns ErrNoValue without calling
+	// Load again.
+	NegativeTTL time.Duration
+
+	flights flightGroup
+}
+
+// NewLoader returns a Loader of c loading missing values with load and
+// storing them encoded with codec for ttl.
+func NewLoader[T any](c *Cache, codec Codec, ttl time.Duration, load func(key string) (T, error)) *Loader[T] {
+	return &Loader[T]{Cache: c, Codec: codec, TTL: ttl, Load: load}
+}
+
+// Get gets the value at key from the cache, or loads and stores it if it
+// isn't cached. If storing the loaded value fails, it is returned along
+// with the error.
+func (l *Loader[T]) Get(key string) (T, error) {
+	shared, err := l.flights.do(key, func() (interface{}, error) {
+		return l.get(key)
+	})
+	v, _ := shared.(T)
+	return v, err
+}
+
+func (l *Loader[T]) get(key string) (T, error) {
+	var v T
+	value, err := l.Cache.Get(key)
+	switch {
+	case err == nil && value == negativeValue:
+		return v, ErrNoValue
+	case err == nil:
+		data, err := valueBytes(value)
+		if err == nil {
+			err = l.Codec.Unmarshal(data, &v)
+		}
+		if err != nil {
+			return v, fmt.Errorf("cache: decoding %s: %w", key, err)
+		}
+		return v, nil
+	case !isStatus(err, http.StatusNotFound):
+		return v, err
+	}
+
+	v, err = l.Load(key)
+	if errors.Is(err, ErrNoValue) && l.NegativeTTL > 0 {
+		if perr := l.Cache.Put(key, &Item{Value: negativeValue, Expiration: l.NegativeTTL}); perr != nil {
+			return v, errors.Join(err, perr)
+		}
+	}
+	if err != nil {
+		return v, err
+	}
+	return v, l.Codec.Put(l.Cache, key, &Item{Object: v, Expiration: l.TTL})
+}
@@ -0,0 +1,77 @@
+package cache_test
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/iron-io/iron_go3/cache"
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestLoader(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("loaders", func() {
+		c, _, done := testCache("loader")
+		defer done()
+		var loads atomic.Int32
+		l := cache.NewLoader(c, cache.JSON, time.Hour, func(key string) (int, error) {
+			loads.Add(1)
+			time.Sleep(50 * time.Millisecond)
+			switch key {
+			case "missing":
+				return 0, cache.ErrNoValue
+			case "broken":
+				return 0, errors.New("db down")
+			}
+			return len(key), nil
+		})
+		l.NegativeTTL = time.Minute
+
+		It("shares one load between concurrent callers", func() {
+			var wg sync.WaitGroup
+			got := make([]int, 10)
+			for i := range got {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					got[i], _ = l.Get("four")
+				}(i)
+			}
+			wg.Wait()
+			Expect(int(loads.Load()), ToEqual, 1)
+			for _, v := range got {
+				Expect(v, ToEqual, 4)
+			}
+			v, err := cache.GetAs[int](c, cache.JSON, "four")
+			Expect(err, ToBeNil)
+			Expect(v, ToEqual, 4)
+		})
+
+		It("reads stored values without loading them", func() {
+			v, err := l.Get("four")
+			Expect(err, ToBeNil)
+			Expect(v, ToEqual, 4)
+			Expect(int(loads.Load()), ToEqual, 1)
+		})
+
+		It("remembers keys without values", func() {
+			_, err := l.Get("missing")
+			Expect(err, ToEqual, cache.ErrNoValue)
+			_, err = l.Get("missing")
+			Expect(err, ToEqual, cache.ErrNoValue)
+			Expect(int(loads.Load()), ToEqual, 2)
+		})
+
+		It("doesn't store failed loads", func() {
+			_, err := l.Get("broken")
+			Expect(err.Error(), ToEqual, "db down")
+			_, err = l.Get("broken")
+			Expect(err.Error(), ToEqual, "db down")
+			Expect(int(loads.Load()), ToEqual, 4)
+		})
+	})
+}
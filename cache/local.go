@@ -0,0 +1,171 @@
+package cache
+
+import (
+	"container/list"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// Local keeps recently used items of a cache in process memory in front of
+// it. Gets are served from memory while fresh and read through to the cache
+// otherwise, Sets and Deletes write through to both.
+//
+// Other processes' writes are only seen once the local copy is older than
+// TTL, so TTL bounds how stale reads can be. Items are never served from
+// memory past their expiration in the cache.
+type Local struct {
+	Cache *Cache
+	// TTL is how long items are served from memory.
+	TTL time.Duration
+	// MaxEntries is how many items are kept in memory, least recently used
+	// items are evicted first. 0 means no limit.
+	MaxEntries int
+	// Bypass sends all calls straight to the cache, e.g. while debugging.
+	Bypass bool
+
+	mu    sync.Mutex
+	lru   *list.List
+	items map[string]*list.Element
+}
+
+type localEntry struct {
+	key     string
+	value   interface{}
+	expires time.Time
+}
+
+// NewLocal returns a Local keeping up to maxEntries items of c in memory for
+// ttl.
+func NewLocal(c *Cache, maxEntries int, ttl time.Duration) *Local {
+	return &Local{Cache: c, MaxEntries: maxEntries, TTL: ttl}
+}
+
+// Get gets an item from memory, or from the cache if it isn't fresh there.
+func (l *Local) Get(key string) (value interface{}, err error) {
+	if l.Bypass {
+		return l.Cache.Get(key)
+	}
+	if value, ok := l.lookup(key); ok {
+		return value, nil
+	}
+	var expires time.Time
+	if value, expires, err = l.Cache.get(key); err == nil {
+		l.store(key, value, expires)
+	}
+	return
+}
+
+// Set sets an item in the cache and in memory, see Cache.Set.
+func (l *Local) Set(key string, value interface{}, ttl ...int) error {
+	if err := l.Cache.Set(key, value, ttl...); err != nil {
+		l.Invalidate(key)
+		return err
+	}
+	if !l.Bypass {
+		if value, err := localValue(value); err == nil {
+			var expires time.Time
+			if ttl := ttlDuration(ttl); ttl > 0 {
+				expires = time.Now().Add(ttl)
+			}
+			l.store(key, value, expires)
+		} else {
+			l.Invalidate(key)
+		}
+	}
+	return nil
+}
+
+// Increment increments an item in the cache, dropping it from memory.
+func (l *Local) Increment(key string, amount int64) (interface{}, error) {
+	l.Invalidate(key)
+	return l.Cache.Increment(key, amount)
+}
+
+// Delete removes an item from the cache and from memory.
+func (l *Local) Delete(key string) error {
+	l.Invalidate(key)
+	return l.Cache.Delete(key)
+}
+
+// Invalidate drops key from memory, so the next Get reads it from the cache.
+func (l *Local) Invalidate(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if e, ok := l.items[key]; ok {
+		l.lru.Remove(e)
+		delete(l.items, key)
+	}
+}
+
+// Purge drops all items from memory.
+func (l *Local) Purge() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lru, l.items = nil, nil
+}
+
+// Len returns the number of items in memory, including stale ones.
+func (l *Local) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.items)
+}
+
+func (l *Local) lookup(key string) (interface{}, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e, ok := l.items[key]
+	if !ok {
+		return nil, false
+	}
+	entry := e.Value.(*localEntry)
+	if !time.Now().Before(entry.expires) {
+		l.lru.Remove(e)
+		delete(l.items, key)
+		return nil, false
+	}
+	l.lru.MoveToFront(e)
+	return entry.value, true
+}
+
+// store keeps value in memory for TTL, or until expires if that is sooner.
+// A zero expires means the item's expiration isn't known.
+func (l *Local) store(key string, value interface{}, expires time.Time) {
+	deadline := time.Now().Add(l.TTL)
+	if !expires.IsZero() && expires.Before(deadline) {
+		deadline = expires
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.items == nil {
+		l.lru, l.items = list.New(), map[string]*list.Element{}
+	}
+	if e, ok := l.items[key]; ok {
+		e.Value = &localEntry{key, value, deadline}
+		l.lru.MoveToFront(e)
+		return
+	}
+	l.items[key] = l.lru.PushFront(&localEntry{key, value, deadline})
+	for l.MaxEntries > 0 && l.lru.Len() > l.MaxEntries {
+		oldest := l.lru.Back()
+		l.lru.Remove(oldest)
+		delete(l.items, oldest.Value.(*localEntry).key)
+	}
+}
+
+// localValue returns value as Get would return it after a Set, so reads
+// from memory look the same as reads from the cache.
+func localValue(value interface{}) (interface{}, error) {
+	str, err := anyToString(value)
+	if err != nil {
+		return nil, err
+	}
+	b, err := json.Marshal(str)
+	if err != nil {
+		return nil, err
+	}
+	var v interface{}
+	err = json.Unmarshal(b, &v)
+	return v, err
+}
@@ -0,0 +1,99 @@
+package cache_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/iron-io/iron_go3/cache"
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestLocal(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("local cache layer", func() {
+		c, _, done := testCache("local")
+		defer done()
+
+		It("serves fresh items from memory", func() {
+			l := cache.NewLocal(c, 10, time.Minute)
+			Expect(l.Set("k", "v"), ToBeNil)
+			Expect(c.Set("k", "changed"), ToBeNil)
+			v, err := l.Get("k")
+			Expect(err, ToBeNil)
+			Expect(v, ToEqual, "v")
+
+			l.Invalidate("k")
+			v, _ = l.Get("k")
+			Expect(v, ToEqual, "changed")
+		})
+
+		It("stores values as the cache returns them", func() {
+			l := cache.NewLocal(c, 10, time.Minute)
+			Expect(l.Set("n", 3), ToBeNil)
+			local, _ := l.Get("n")
+			remote, _ := c.Get("n")
+			Expect(local, ToEqual, remote)
+		})
+
+		It("reads stale items from the cache", func() {
+			l := cache.NewLocal(c, 10, 10*time.Millisecond)
+			Expect(l.Set("s", "old"), ToBeNil)
+			Expect(c.Set("s", "new"), ToBeNil)
+			time.Sleep(20 * time.Millisecond)
+			v, _ := l.Get("s")
+			Expect(v, ToEqual, "new")
+		})
+
+		It("doesn't serve items past their expiration", func() {
+			l := cache.NewLocal(c, 10, time.Minute)
+			Expect(l.Set("short", "v", 1), ToBeNil)
+			v, err := l.Get("short")
+			Expect(err, ToBeNil)
+			Expect(v, ToEqual, "v")
+			time.Sleep(1100 * time.Millisecond)
+			_, err = l.Get("short")
+			Expect(err, ToNotBeNil)
+
+			Expect(c.Set("read", "v", 1), ToBeNil)
+			l.Get("read")
+			time.Sleep(1100 * time.Millisecond)
+			_, err = l.Get("read")
+			Expect(err, ToNotBeNil)
+		})
+
+		It("evicts the least recently used items", func() {
+			l := cache.NewLocal(c, 2, time.Minute)
+			l.Set("a", "1")
+			l.Set("b", "2")
+			l.Get("a")
+			l.Set("c", "3")
+			Expect(l.Len(), ToEqual, 2)
+
+			c.Set("a", "remote")
+			c.Set("b", "remote")
+			a, _ := l.Get("a")
+			b, _ := l.Get("b")
+			Expect(a, ToEqual, "1")
+			Expect(b, ToEqual, "remote")
+		})
+
+		It("can be bypassed", func() {
+			l := cache.NewLocal(c, 10, time.Minute)
+			l.Bypass = true
+			Expect(l.Set("by", "v"), ToBeNil)
+			Expect(l.Len(), ToEqual, 0)
+			c.Set("by", "remote")
+			v, _ := l.Get("by")
+			Expect(v, ToEqual, "remote")
+		})
+
+		It("deletes from both", func() {
+			l := cache.NewLocal(c, 10, time.Minute)
+			l.Set("d", "v")
+			Expect(l.Delete("d"), ToBeNil)
+			_, err := l.Get("d")
+			Expect(err, ToNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,254 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrLocked is returned by TryAcquire when the lock is held by someone else.
+	ErrLocked = errors.New("cache: lock is held")
+	// ErrLockLost is returned by Release, and reported by Lost, when the lock
+	// expired or was taken over before it was released.
+	ErrLockLost = errors.New("cache: lock was lost")
+)
+
+// lockReleased is the value of a lock being released, see Release.
+const lockReleased = "released"
+
+// A Lock is a lease on a cache key, held by at most one holder at a time
+// across all processes using the cache.
+//
+// The lease expires after its ttl unless renewed. A held Lock renews itself
+// every third of its ttl until Release. If renewing fails until the lease
+// has run out, or the key was taken over, the Lock is lost: Lost is closed
+// and the holder must stop relying on it.
+//
+// Each acquisition gets a fencing token larger than those of all earlier
+// acquisitions of the key, which can be passed along to the resources the
+// lock protects so they can reject writes from stale holders. The tokens
+// only start over once the key wasn't locked for MaxExpiration.
+type Lock struct {
+	c     *Cache
+	key   string
+	owner string
+	ttl   time.Duration
+	token uint64
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+	lost     chan struct{}
+
+	mu      sync.Mutex
+	cas     uint64
+	expires time.Time
+	lostErr error
+}
+
+// Acquire acquires the lock at key, waiting until it is free or ctx is
+// done. The lease is held for ttl and renewed automatically, see Lock.
+func (c *Cache) Acquire(ctx context.Context, key string, ttl time.Duration) (*Lock, error) {
+	wait := 50 * time.Millisecond
+	for {
+		l, err := c.TryAcquire(key, ttl)
+		if err != ErrLocked {
+			return l, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+		if wait *= 2; wait > time.Second {
+			wait = time.Second
+		}
+	}
+}
+
+// TryAcquire acquires the lock at key if it is free, otherwise it returns
+// ErrLocked.
+func (c *Cache) TryAcquire(key string, ttl time.Duration) (*Lock, error) {
+	if ttl < time.Second {
+		return nil, fmt.Errorf("cache: lock ttl %s is shorter than a second", ttl)
+	}
+	owner, err := lockOwner()
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	err = c.AddItem(key, &Item{Value: owner, Expiration: ttl})
+	if err == ErrKeyExists {
+		return nil, ErrLocked
+	} else if err != nil {
+		return nil, err
+	}
+
+	l := &Lock{
+		c: c, key: key, owner: owner, ttl: ttl, expires: start.Add(ttl),
+		stop: make(chan struct{}), done: make(chan struct{}), lost: make(chan struct{}),
+	}
+	if l.token, err = c.fence(key); err == nil {
+		_, err = l.held()
+	}
+	if err != nil {
+		c.Delete(key)
+		return nil, err
+	}
+	go l.renew()
+	return l, nil
+}
+
+// Token returns the lock's fencing token.
+func (l *Lock) Token() uint64 { return l.token }
+
+// Lost is closed when the lock is lost before being released.
+func (l *Lock) Lost() <-chan struct{} { return l.lost }
+
+// Release stops renewing the lock and frees it. ErrLockLost is returned if
+// the lock was already lost or released.
+//
+// Deletes can't be made conditional, so the lock is first swapped for a
+// tombstone, which fails if it changed hands, and then deleted. The
+// tombstone keeps the key taken for the lock's ttl, so the delete can only
+// free someone else's lock if it arrives later than that.
+func (l *Lock) Release() error {
+	l.stopOnce.Do(func() { close(l.stop) })
+	<-l.done
+
+	if err := l.lostError(); err != nil {
+		return err
+	}
+	if held, err := l.held(); err != nil {
+		return err
+	} else if !held {
+		return ErrLockLost
+	}
+	l.mu.Lock()
+	cas := l.cas
+	l.mu.Unlock()
+
+	err := l.c.CompareAndSwap(l.key, &Item{Value: lockReleased, Expiration: l.ttl, Cas: cas})
+	if err == ErrCasMismatch || err == ErrKeyNotFound {
+		return ErrLockLost
+	} else if err != nil {
+		return err
+	}
+	if err := l.c.Delete(l.key); err != nil && !isStatus(err, http.StatusNotFound) {
+		return err
+	}
+	return nil
+}
+
+func (l *Lock) renew() {
+	defer close(l.done)
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+		}
+
+		start := time.Now()
+		err := l.extend()
+		l.mu.Lock()
+		if err == nil {
+			l.expires = start.Add(l.ttl)
+		} else if err == ErrLockLost || !time.Now().Before(l.expires) {
+			l.lostErr = ErrLockLost
+		}
+		lost := l.lostErr != nil
+		l.mu.Unlock()
+		if lost {
+			close(l.lost)
+			return
+		}
+	}
+}
+
+// extend renews the lease if the lock is still held.
+func (l *Lock) extend() error {
+	if held, err := l.held(); err != nil {
+		return err
+	} else if !held {
+		return ErrLockLost
+	}
+	l.mu.Lock()
+	cas := l.cas
+	l.mu.Unlock()
+
+	err := l.c.CompareAndSwap(l.key, &Item{Value: l.owner, Expiration: l.ttl, Cas: cas})
+	if err == ErrCasMismatch || err == ErrKeyNotFound {
+		return ErrLockLost
+	}
+	return err
+}
+
+// held reads the lock's key, remembering its cas token.
+func (l *Lock) held() (bool, error) {
+	value, cas, err := l.c.Gets(l.key)
+	if err == ErrKeyNotFound {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	l.mu.Lock()
+	l.cas = cas
+	l.mu.Unlock()
+	return value == l.owner, nil
+}
+
+func (l *Lock) lostError() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.lostErr
+}
+
+// fence returns the next fencing token for key, kept in a counter item next
+// to it. Each token renews the counter's expiration, as Increment keeps the
+// expiration an item was put with, so the counter only starts over if no
+// lock was taken at key for MaxExpiration.
+func (c *Cache) fence(key string) (uint64, error) {
+	key += ".fence"
+	for try := 0; try < 10; try++ {
+		value, cas, err := c.Gets(key)
+		if err == ErrKeyNotFound {
+			err = c.AddItem(key, &Item{Value: 1, Expiration: MaxExpiration})
+			if err == ErrKeyExists {
+				continue
+			}
+			return 1, err
+		} else if err != nil {
+			return 0, err
+		}
+		n, ok := value.(float64)
+		if !ok {
+			return 0, fmt.Errorf("cache: fencing counter %s holds %v, not a number", key, value)
+		}
+		err = c.CompareAndSwap(key, &Item{Value: uint64(n) + 1, Expiration: MaxExpiration, Cas: cas})
+		if err == ErrCasMismatch || err == ErrKeyNotFound {
+			continue
+		} else if err != nil {
+			return 0, err
+		}
+		return uint64(n) + 1, nil
+	}
+	return 0, fmt.Errorf("cache: fencing counter %s kept changing", key)
+}
+
+func lockOwner() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b[:]), nil
+}
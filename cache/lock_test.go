@@ -0,0 +1,138 @@
+package cache_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/iron-io/iron_go3/cache"
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestLock(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("cache locks", func() {
+		c, f, done := testCache("locks")
+		defer done()
+
+		It("is held by one holder at a time", func() {
+			l, err := c.TryAcquire("job", time.Minute)
+			Expect(err, ToBeNil)
+			_, err = c.TryAcquire("job", time.Minute)
+			Expect(err, ToEqual, cache.ErrLocked)
+
+			Expect(l.Release(), ToBeNil)
+			Expect(l.Release(), ToEqual, cache.ErrLockLost)
+
+			l2, err := c.TryAcquire("job", time.Minute)
+			Expect(err, ToBeNil)
+			Expect(l2.Token() > l.Token(), ToBeTrue)
+			l2.Release()
+		})
+
+		It("waits for the lock to be released", func() {
+			l, _ := c.TryAcquire("wait", time.Minute)
+			go func() {
+				time.Sleep(100 * time.Millisecond)
+				l.Release()
+			}()
+			l2, err := c.Acquire(context.Background(), "wait", time.Minute)
+			Expect(err, ToBeNil)
+			Expect(l2, ToNotBeNil)
+			l2.Release()
+
+			l3, _ := c.TryAcquire("wait", time.Minute)
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			_, err = c.Acquire(ctx, "wait", time.Minute)
+			Expect(err, ToEqual, context.DeadlineExceeded)
+			l3.Release()
+		})
+
+		It("renews the lease", func() {
+			if testing.Short() {
+				return
+			}
+			l, err := c.TryAcquire("renew", time.Second)
+			Expect(err, ToBeNil)
+			time.Sleep(1500 * time.Millisecond)
+			_, err = c.TryAcquire("renew", time.Second)
+			Expect(err, ToEqual, cache.ErrLocked)
+			Expect(l.Release(), ToBeNil)
+		})
+
+		It("reports losing the lock", func() {
+			l, err := c.TryAcquire("lose", time.Second)
+			Expect(err, ToBeNil)
+			c.Delete("lose")
+			select {
+			case <-l.Lost():
+			case <-time.After(2 * time.Second):
+			}
+			Expect(l.Release(), ToEqual, cache.ErrLockLost)
+		})
+
+		It("renews the fencing counter with every token", func() {
+			l, err := c.TryAcquire("fenced", time.Minute)
+			Expect(err, ToBeNil)
+			Expect(l.Release(), ToBeNil)
+			// the counter is about to run out since it was created
+			f.mu.Lock()
+			item := f.items["locks/fenced.fence"]
+			item.expires = time.Now().Add(50 * time.Millisecond)
+			f.items["locks/fenced.fence"] = item
+			f.mu.Unlock()
+
+			l2, err := c.TryAcquire("fenced", time.Minute)
+			Expect(err, ToBeNil)
+			Expect(l2.Release(), ToBeNil)
+			time.Sleep(100 * time.Millisecond)
+			l3, err := c.TryAcquire("fenced", time.Minute)
+			Expect(err, ToBeNil)
+			Expect(l3.Token() > l2.Token(), ToBeTrue)
+			Expect(l2.Token() > l.Token(), ToBeTrue)
+			l3.Release()
+		})
+
+		It("reports releasing an expired lock", func() {
+			l, err := c.TryAcquire("expired", time.Minute)
+			Expect(err, ToBeNil)
+			f.mu.Lock()
+			item := f.items["locks/expired"]
+			item.expires = time.Now().Add(-time.Second)
+			f.items["locks/expired"] = item
+			f.mu.Unlock()
+			Expect(l.Release(), ToEqual, cache.ErrLockLost)
+		})
+
+		It("doesn't free a lock taken over while releasing", func() {
+			// the lock changes hands right after Release checked it
+			f := &fakeCache{items: map[string]fakeItem{}}
+			var steal atomic.Bool
+			c, done := serveCache("locks", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				f.ServeHTTP(w, r)
+				if r.Method == "GET" && strings.HasSuffix(r.URL.Path, "/items/stolen") && steal.CompareAndSwap(true, false) {
+					f.mu.Lock()
+					f.cas++
+					item := f.items["locks/stolen"]
+					item.value, item.cas = "thief", f.cas
+					f.items["locks/stolen"] = item
+					f.mu.Unlock()
+				}
+			}))
+			defer done()
+
+			l, err := c.TryAcquire("stolen", time.Minute)
+			Expect(err, ToBeNil)
+			steal.Store(true)
+			Expect(l.Release(), ToEqual, cache.ErrLockLost)
+			v, err := c.Get("stolen")
+			Expect(err, ToBeNil)
+			Expect(v, ToEqual, "thief")
+		})
+	})
+}
@@ -0,0 +1,70 @@
+package cache
+
+import (
+	"net/http"
+	"sync"
+)
+
+// MultiConcurrency is how many requests MultiGet and MultiPut have in flight
+// at once. IronCache has no batch calls, so each key is its own request.
+var MultiConcurrency = 8
+
+// MultiGet gets the items at keys. Keys that aren't cached are left out of
+// values, keys that couldn't be read are in errs.
+func (c *Cache) MultiGet(keys []string) (values map[string]interface{}, errs map[string]error) {
+	values, errs = map[string]interface{}{}, map[string]error{}
+	var mu sync.Mutex
+	c.each(keys, func(key string) {
+		value, err := c.Get(key)
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case err == nil:
+			values[key] = value
+		case !isStatus(err, http.StatusNotFound):
+			errs[key] = err
+		}
+	})
+	return values, errs
+}
+
+// MultiPut puts each of items at its key. Keys that couldn't be put are
+// returned with their error, nil if all were put.
+func (c *Cache) MultiPut(items map[string]*Item) map[string]error {
+	keys := make([]string, 0, len(items))
+	for key := range items {
+		keys = append(keys, key)
+	}
+	var errs map[string]error
+	var mu sync.Mutex
+	c.each(keys, func(key string) {
+		if err := c.Put(key, items[key]); err != nil {
+			mu.Lock()
+			if errs == nil {
+				errs = map[string]error{}
+			}
+			errs[key] = err
+			mu.Unlock()
+		}
+	})
+	return errs
+}
+
+// each calls fn for every key, at most MultiConcurrency at a time.
+func (c *Cache) each(keys []string, fn func(key string)) {
+	n := MultiConcurrency
+	if n < 1 {
+		n = 1
+	}
+	sem := make(chan struct{}, n)
+	var wg sync.WaitGroup
+	for _, key := range keys {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(key string) {
+			defer func() { <-sem; wg.Done() }()
+			fn(key)
+		}(key)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,45 @@
+package cache_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/iron-io/iron_go3/cache"
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestMulti(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("batch gets and puts", func() {
+		c, _, done := testCache("multi")
+		defer done()
+
+		It("puts and gets many items", func() {
+			items := map[string]*cache.Item{}
+			keys := []string{"missing"}
+			for i := 0; i < 20; i++ {
+				key := fmt.Sprint("k", i)
+				items[key] = &cache.Item{Value: fmt.Sprint("v", i)}
+				keys = append(keys, key)
+			}
+			Expect(c.MultiPut(items), ToBeNil)
+
+			values, errs := c.MultiGet(keys)
+			Expect(len(errs), ToEqual, 0)
+			Expect(len(values), ToEqual, 20)
+			Expect(values["k7"], ToEqual, "v7")
+			_, found := values["missing"]
+			Expect(found, ToEqual, false)
+		})
+
+		It("reports failed puts per key", func() {
+			errs := c.MultiPut(map[string]*cache.Item{
+				"ok":  {Value: "v"},
+				"bad": {Value: "v", Expiration: -1},
+			})
+			Expect(len(errs), ToEqual, 1)
+			Expect(errs["bad"], ToNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,96 @@
+package cache
+
+import (
+	"strings"
+	"time"
+)
+
+// A Namespace prefixes the keys of all its operations, so tenants can
+// share a cache without their keys colliding. The keys it writes are
+// tracked so they can be listed and cleared: in the cache's index if it
+// has TrackKeys set, in index items of its own (Prefix + "__keys__")
+// otherwise. See Cache.TrackKeys for what that costs.
+type Namespace struct {
+	Cache  *Cache
+	Prefix string
+}
+
+// Namespaced returns a Namespace of c with prefix, e.g. "tenant-42:".
+func Namespaced(c *Cache, prefix string) *Namespace {
+	return &Namespace{Cache: c, Prefix: prefix}
+}
+
+func (n *Namespace) registry() keyRegistry {
+	return keyRegistry{c: n.Cache, key: n.Prefix + "__keys__"}
+}
+
+// Put adds an Item to the namespace, see Cache.Put.
+func (n *Namespace) Put(key string, item *Item) error {
+	if !n.Cache.TrackKeys {
+		if err := n.registry().add(key); err != nil {
+			return err
+		}
+	}
+	return n.Cache.Put(n.Prefix+key, item)
+}
+
+// Set sets an item in the namespace, see Cache.Set.
+func (n *Namespace) Set(key string, value interface{}, ttl ...int) error {
+	str, err := anyToString(value)
+	if err != nil {
+		return err
+	}
+	return n.Put(key, &Item{Value: str, Expiration: ttlDuration(ttl)})
+}
+
+// SetFor is Set with the expiration given as a duration.
+func (n *Namespace) SetFor(key string, value interface{}, ttl time.Duration) error {
+	str, err := anyToString(value)
+	if err != nil {
+		return err
+	}
+	return n.Put(key, &Item{Value: str, Expiration: ttl})
+}
+
+// Get gets an item from the namespace.
+func (n *Namespace) Get(key string) (interface{}, error) {
+	return n.Cache.Get(n.Prefix + key)
+}
+
+// Increment increments an item in the namespace.
+func (n *Namespace) Increment(key string, amount int64) (interface{}, error) {
+	return n.Cache.Increment(n.Prefix+key, amount)
+}
+
+// Delete removes an item from the namespace.
+func (n *Namespace) Delete(key string) error {
+	if err := n.Cache.Delete(n.Prefix + key); err != nil || n.Cache.TrackKeys {
+		return err
+	}
+	return n.registry().remove(key)
+}
+
+// Keys returns the keys written to the namespace, without the prefix. Keys
+// that expired are still listed until the namespace is cleared.
+func (n *Namespace) Keys() ([]string, error) {
+	if !n.Cache.TrackKeys {
+		return n.registry().keys()
+	}
+	keys, err := n.Cache.ListKeys(n.Prefix)
+	for i, key := range keys {
+		keys[i] = strings.TrimPrefix(key, n.Prefix)
+	}
+	return keys, err
+}
+
+// ClearNamespace deletes all items written to the namespace.
+func (n *Namespace) ClearNamespace() error {
+	if n.Cache.TrackKeys {
+		return n.Cache.DeletePrefix(n.Prefix)
+	}
+	keys, err := n.Keys()
+	if err != nil {
+		return err
+	}
+	return n.registry().sweep(n.Prefix, keys)
+}
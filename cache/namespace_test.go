@@ -0,0 +1,55 @@
+package cache_test
+
+import (
+	"testing"
+
+	"github.com/iron-io/iron_go3/cache"
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestNamespace(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("namespaced caches", func() {
+		c, _, done := testCache("shared")
+		defer done()
+		a := cache.Namespaced(c, "a:")
+		b := cache.Namespaced(c, "b:")
+
+		It("keeps tenants' keys apart", func() {
+			Expect(a.Set("k", "from a"), ToBeNil)
+			Expect(b.Set("k", "from b"), ToBeNil)
+			v, _ := a.Get("k")
+			Expect(v, ToEqual, "from a")
+			v, _ = c.Get("b:k")
+			Expect(v, ToEqual, "from b")
+		})
+
+		It("tracks written keys", func() {
+			Expect(a.Set("j", "v"), ToBeNil)
+			Expect(a.Set("j", "again"), ToBeNil)
+			keys, err := a.Keys()
+			Expect(err, ToBeNil)
+			Expect(keys, ToDeepEqual, []string{"j", "k"})
+
+			Expect(a.Delete("j"), ToBeNil)
+			keys, _ = a.Keys()
+			Expect(keys, ToDeepEqual, []string{"k"})
+		})
+
+		It("clears only its own namespace", func() {
+			Expect(a.Set("x", "v"), ToBeNil)
+			Expect(a.ClearNamespace(), ToBeNil)
+
+			_, err := a.Get("k")
+			Expect(err, ToNotBeNil)
+			_, err = a.Get("x")
+			Expect(err, ToNotBeNil)
+			keys, _ := a.Keys()
+			Expect(len(keys), ToEqual, 0)
+
+			v, _ := b.Get("k")
+			Expect(v, ToEqual, "from b")
+		})
+	})
+}
@@ -0,0 +1,218 @@
+package cache
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// registryRetries is how often a registry update is retried when it races
+// with another writer.
+const registryRetries = 10
+
+// registryShards is the number of index items a registry spreads its keys
+// over, so writers of different keys rarely race and each write only
+// rewrites a share of the keys.
+const registryShards = 16
+
+// A keyRegistry tracks the keys written under a prefix in index items, as
+// IronCache can't list the keys of a cache. A key is registered in the
+// index item key + "." + its shard, e.g. "__keys__.3".
+type keyRegistry struct {
+	c   *Cache
+	key string
+}
+
+type registryItem struct {
+	Value   interface{} `json:"value"`
+	Cas     uint64      `json:"cas"`
+	Expires time.Time   `json:"expires"`
+}
+
+// shard returns the index item key is registered in.
+func (r keyRegistry) shard(key string) string {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return r.key + "." + strconv.Itoa(int(h.Sum32()%registryShards))
+}
+
+func (r keyRegistry) shards() []string {
+	shards := make([]string, registryShards)
+	for i := range shards {
+		shards[i] = r.key + "." + strconv.Itoa(i)
+	}
+	return shards
+}
+
+// read returns the keys registered in shard, the shard's cas token, 0 if
+// there is no such shard yet, and when it expires.
+func (r keyRegistry) read(shard string) (keys map[string]bool, cas uint64, expires time.Time, err error) {
+	var out registryItem
+	err = r.c.caches(r.c.Name, "items", shard).Req("GET", nil, &out)
+	if isStatus(err, http.StatusNotFound) {
+		return map[string]bool{}, 0, time.Time{}, nil
+	} else if err != nil {
+		return nil, 0, time.Time{}, err
+	}
+
+	var list []string
+	str, _ := out.Value.(string)
+	if err = json.Unmarshal([]byte(str), &list); err != nil {
+		return nil, 0, time.Time{}, err
+	}
+	keys = make(map[string]bool, len(list))
+	for _, key := range list {
+		keys[key] = true
+	}
+	return keys, out.Cas, out.Expires, nil
+}
+
+// update applies fn to the keys registered in shard and writes them back
+// if fn reports a change, or the shard is past half its lifetime.
+func (r keyRegistry) update(shard string, fn func(keys map[string]bool) bool) (err error) {
+	for i := 0; i < registryRetries; i++ {
+		var keys map[string]bool
+		var cas uint64
+		var expires time.Time
+		if keys, cas, expires, err = r.read(shard); err != nil {
+			return err
+		}
+		if !fn(keys) && (cas == 0 || time.Until(expires) > MaxExpiration/2) {
+			return nil
+		}
+
+		list := make([]string, 0, len(keys))
+		for key := range keys {
+			list = append(list, key)
+		}
+		sort.Strings(list)
+		b, _ := json.Marshal(list)
+		item := &Item{Value: string(b), Expiration: MaxExpiration, Cas: cas}
+		if cas == 0 {
+			err = r.c.AddItem(shard, item)
+		} else {
+			err = r.c.CompareAndSwap(shard, item)
+		}
+		switch err {
+		case ErrKeyExists, ErrCasMismatch, ErrKeyNotFound:
+			continue
+		}
+		return err
+	}
+	return err
+}
+
+func (r keyRegistry) add(key string) error {
+	return r.update(r.shard(key), func(keys map[string]bool) bool {
+		if keys[key] {
+			return false
+		}
+		keys[key] = true
+		return true
+	})
+}
+
+func (r keyRegistry) remove(key string) error {
+	return r.update(r.shard(key), func(keys map[string]bool) bool {
+		if !keys[key] {
+			return false
+		}
+		delete(keys, key)
+		return true
+	})
+}
+
+// keys returns the registered keys in order.
+func (r keyRegistry) keys() ([]string, error) {
+	var mu sync.Mutex
+	var firstErr error
+	var list []string
+	r.c.each(r.shards(), func(shard string) {
+		keys, _, _, err := r.read(shard)
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		for key := range keys {
+			list = append(list, key)
+		}
+	})
+	sort.Strings(list)
+	return list, firstErr
+}
+
+// removeAll untracks keys.
+func (r keyRegistry) removeAll(keys []string) error {
+	byShard := map[string][]string{}
+	for _, key := range keys {
+		shard := r.shard(key)
+		byShard[shard] = append(byShard[shard], key)
+	}
+	shards := make([]string, 0, len(byShard))
+	for shard := range byShard {
+		shards = append(shards, shard)
+	}
+
+	var mu sync.Mutex
+	var firstErr error
+	r.c.each(shards, func(shard string) {
+		err := r.update(shard, func(registered map[string]bool) bool {
+			changed := false
+			for _, key := range byShard[shard] {
+				if registered[key] {
+					delete(registered, key)
+					changed = true
+				}
+			}
+			return changed
+		})
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	})
+	return firstErr
+}
+
+// sweep deletes the items of keys, which are registered without prefix,
+// and untracks those that are gone, keeping what is left for another try
+// and keys written in the meantime.
+func (r keyRegistry) sweep(prefix string, keys []string) error {
+	var mu sync.Mutex
+	var firstErr error
+	var deleted []string
+	r.c.each(keys, func(key string) {
+		err := r.c.deleteItem(prefix + key)
+		mu.Lock()
+		defer mu.Unlock()
+		if err == nil || isStatus(err, http.StatusNotFound) {
+			deleted = append(deleted, key)
+		} else if firstErr == nil {
+			firstErr = err
+		}
+	})
+
+	err := r.removeAll(deleted)
+	if firstErr != nil {
+		return firstErr
+	}
+	return err
+}
+
+// withPrefix returns the keys starting with prefix.
+func withPrefix(keys []string, prefix string) []string {
+	var out []string
+	for _, key := range keys {
+		if strings.HasPrefix(key, prefix) {
+			out = append(out, key)
+		}
+	}
+	return out
+}
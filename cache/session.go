@@ -0,0 +1,203 @@
+package cache
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// A SessionStore keeps net/http sessions in a cache, with only a random
+// session id stored in the client's cookie. Its methods mirror the Store
+// interface of github.com/gorilla/sessions.
+//
+//	store := cache.NewSessionStore(cache.New("sessions"), key)
+//	sess, err := store.Get(r, "app")
+//	sess.Values["user"] = "ada"
+//	err = store.Save(r, w, sess)
+type SessionStore struct {
+	Cache *Cache
+	// Options are the defaults for new sessions.
+	Options SessionOptions
+
+	aead cipher.AEAD
+}
+
+// SessionOptions configure a session's cookie and how long it is kept.
+type SessionOptions struct {
+	Path     string
+	Domain   string
+	Secure   bool
+	HttpOnly bool
+	SameSite http.SameSite
+	// MaxAge is how long the session lives in the cache and the cookie in
+	// seconds. Saving a session with a negative MaxAge deletes it.
+	MaxAge int
+}
+
+// A Session holds the values stored for a client.
+type Session struct {
+	ID      string
+	Values  map[string]interface{}
+	Options SessionOptions
+	IsNew   bool
+
+	name  string
+	store *SessionStore
+}
+
+// Name returns the name of the session's cookie.
+func (s *Session) Name() string { return s.name }
+
+// Save saves the session, see SessionStore.Save.
+func (s *Session) Save(r *http.Request, w http.ResponseWriter) error {
+	return s.store.Save(r, w, s)
+}
+
+// NewSessionStore returns a store keeping sessions in c for a day. If key
+// is set, session values are encrypted with it using AES-GCM before being
+// cached, it must be 16, 24 or 32 bytes long.
+func NewSessionStore(c *Cache, key []byte) (*SessionStore, error) {
+	s := &SessionStore{
+		Cache:   c,
+		Options: SessionOptions{Path: "/", HttpOnly: true, MaxAge: 24 * 60 * 60},
+	}
+	if key != nil {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		if s.aead, err = cipher.NewGCM(block); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Get returns the session named name for the request, see New.
+func (s *SessionStore) Get(r *http.Request, name string) (*Session, error) {
+	return s.New(r, name)
+}
+
+// New loads the session named name whose id is in the request's cookie, or
+// returns a new session if there is none or it expired. The new session is
+// returned along with any error loading the old one.
+func (s *SessionStore) New(r *http.Request, name string) (*Session, error) {
+	sess := &Session{
+		Values:  map[string]interface{}{},
+		Options: s.Options,
+		IsNew:   true,
+		name:    name,
+		store:   s,
+	}
+	cookie, err := r.Cookie(name)
+	if err != nil || !validSessionID(cookie.Value) {
+		return sess, nil
+	}
+
+	value, err := s.Cache.Get(cookie.Value)
+	if isStatus(err, http.StatusNotFound) {
+		return sess, nil
+	} else if err != nil {
+		return sess, err
+	}
+	str, _ := value.(string)
+	if err := s.decode(cookie.Value, str, &sess.Values); err != nil {
+		return sess, err
+	}
+	sess.ID, sess.IsNew = cookie.Value, false
+	return sess, nil
+}
+
+// Save stores the session's values in the cache and sets its cookie on the
+// response. A session with a negative MaxAge is deleted instead.
+func (s *SessionStore) Save(r *http.Request, w http.ResponseWriter, sess *Session) error {
+	if sess.Options.MaxAge < 0 {
+		if sess.ID != "" {
+			if err := s.Cache.Delete(sess.ID); err != nil && !isStatus(err, http.StatusNotFound) {
+				return err
+			}
+		}
+		http.SetCookie(w, s.cookie(sess, ""))
+		return nil
+	}
+
+	if sess.ID == "" {
+		var b [32]byte
+		if _, err := rand.Read(b[:]); err != nil {
+			return err
+		}
+		sess.ID = hex.EncodeToString(b[:])
+	}
+	value, err := s.encode(sess.ID, sess.Values)
+	if err != nil {
+		return err
+	}
+	err = s.Cache.Put(sess.ID, &Item{Value: value, Expiration: time.Duration(sess.Options.MaxAge) * time.Second})
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, s.cookie(sess, sess.ID))
+	return nil
+}
+
+// validSessionID keeps clients from reading other keys of the cache by
+// putting them in their cookie.
+func validSessionID(id string) bool {
+	b, err := hex.DecodeString(id)
+	return err == nil && len(b) == 32
+}
+
+func (s *SessionStore) cookie(sess *Session, value string) *http.Cookie {
+	c := &http.Cookie{
+		Name:     sess.name,
+		Value:    value,
+		Path:     sess.Options.Path,
+		Domain:   sess.Options.Domain,
+		Secure:   sess.Options.Secure,
+		HttpOnly: sess.Options.HttpOnly,
+		SameSite: sess.Options.SameSite,
+		MaxAge:   sess.Options.MaxAge,
+	}
+	if c.MaxAge > 0 {
+		c.Expires = time.Now().Add(time.Duration(c.MaxAge) * time.Second)
+	} else if c.MaxAge < 0 {
+		c.Expires = time.Unix(1, 0)
+	}
+	return c
+}
+
+func (s *SessionStore) encode(id string, values map[string]interface{}) (string, error) {
+	b, err := json.Marshal(values)
+	if err != nil || s.aead == nil {
+		return string(b), err
+	}
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(s.aead.Seal(nonce, nonce, b, []byte(id))), nil
+}
+
+func (s *SessionStore) decode(id, value string, values *map[string]interface{}) error {
+	b := []byte(value)
+	if s.aead != nil {
+		sealed, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return err
+		}
+		if len(sealed) < s.aead.NonceSize() {
+			return errors.New("cache: session data too short")
+		}
+		nonce := sealed[:s.aead.NonceSize()]
+		if b, err = s.aead.Open(nil, nonce, sealed[len(nonce):], []byte(id)); err != nil {
+			return err
+		}
+	}
+	return json.Unmarshal(b, values)
+}
@@ -0,0 +1,89 @@
+package cache_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/iron-io/iron_go3/cache"
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestSessionStore(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("cache session store", func() {
+		c, _, done := testCache("sessions")
+		defer done()
+
+		// roundTrip saves a value in a new session and returns the request a
+		// client would send next.
+		roundTrip := func(store *cache.SessionStore) *http.Request {
+			sess, err := store.Get(httptest.NewRequest("GET", "/", nil), "app")
+			Expect(err, ToBeNil)
+			Expect(sess.IsNew, ToBeTrue)
+			sess.Values["user"] = "ada"
+			rec := httptest.NewRecorder()
+			Expect(sess.Save(nil, rec), ToBeNil)
+
+			r := httptest.NewRequest("GET", "/", nil)
+			for _, cookie := range rec.Result().Cookies() {
+				r.AddCookie(cookie)
+			}
+			return r
+		}
+
+		It("loads saved sessions", func() {
+			store, err := cache.NewSessionStore(c, nil)
+			Expect(err, ToBeNil)
+			r := roundTrip(store)
+
+			sess, err := store.Get(r, "app")
+			Expect(err, ToBeNil)
+			Expect(sess.IsNew, ToEqual, false)
+			Expect(sess.Values["user"], ToEqual, "ada")
+		})
+
+		It("encrypts session values", func() {
+			store, err := cache.NewSessionStore(c, []byte("0123456789abcdef"))
+			Expect(err, ToBeNil)
+			r := roundTrip(store)
+
+			cookie, _ := r.Cookie("app")
+			raw, _ := c.Get(cookie.Value)
+			Expect(raw == `{"user":"ada"}`, ToEqual, false)
+
+			sess, err := store.Get(r, "app")
+			Expect(err, ToBeNil)
+			Expect(sess.Values["user"], ToEqual, "ada")
+
+			other, _ := cache.NewSessionStore(c, []byte("fedcba9876543210"))
+			_, err = other.Get(r, "app")
+			Expect(err, ToNotBeNil)
+		})
+
+		It("deletes sessions with a negative MaxAge", func() {
+			store, _ := cache.NewSessionStore(c, nil)
+			r := roundTrip(store)
+			sess, _ := store.Get(r, "app")
+			sess.Options.MaxAge = -1
+			rec := httptest.NewRecorder()
+			Expect(store.Save(r, rec, sess), ToBeNil)
+			Expect(rec.Result().Cookies()[0].MaxAge < 0, ToBeTrue)
+
+			sess, err := store.Get(r, "app")
+			Expect(err, ToBeNil)
+			Expect(sess.IsNew, ToBeTrue)
+		})
+
+		It("ignores cookies that aren't session ids", func() {
+			Expect(c.Set("secret", "value"), ToBeNil)
+			store, _ := cache.NewSessionStore(c, nil)
+			r := httptest.NewRequest("GET", "/", nil)
+			r.AddCookie(&http.Cookie{Name: "app", Value: "secret"})
+			sess, err := store.Get(r, "app")
+			Expect(err, ToBeNil)
+			Expect(sess.IsNew, ToBeTrue)
+		})
+	})
+}
@@ -0,0 +1,45 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+)
+
+var errComputePanicked = errors.New("cache: compute function panicked")
+
+// flightGroup runs a function once per key at a time, callers asking for a
+// key that is in flight wait for and share its result.
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flight
+}
+
+type flight struct {
+	done chan struct{}
+	val  interface{}
+	err  error
+}
+
+func (g *flightGroup) do(key string, fn func() (interface{}, error)) (interface{}, error) {
+	g.mu.Lock()
+	if f, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		<-f.done
+		return f.val, f.err
+	}
+	if g.calls == nil {
+		g.calls = map[string]*flight{}
+	}
+	f := &flight{done: make(chan struct{}), err: errComputePanicked}
+	g.calls[key] = f
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(f.done)
+	}()
+	f.val, f.err = fn()
+	return f.val, f.err
+}
@@ -0,0 +1,89 @@
+package cache
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// KeysIndex prefixes the keys of the index items of a cache with
+// TrackKeys set. Keys starting with it aren't tracked.
+const KeysIndex = "__keys__"
+
+func (c *Cache) registry() keyRegistry {
+	return keyRegistry{c: c, key: KeysIndex}
+}
+
+func (c *Cache) track(key string) error {
+	if !c.TrackKeys || strings.HasPrefix(key, KeysIndex) {
+		return nil
+	}
+	return c.registry().add(key)
+}
+
+func (c *Cache) untrack(key string) error {
+	if !c.TrackKeys || strings.HasPrefix(key, KeysIndex) {
+		return nil
+	}
+	return c.registry().remove(key)
+}
+
+// ListKeys returns the keys starting with prefix written to the cache
+// while TrackKeys was set, in order. Keys that expired, or were deleted
+// without TrackKeys, are still listed until they are swept.
+func (c *Cache) ListKeys(prefix string) ([]string, error) {
+	keys, err := c.registry().keys()
+	if err != nil {
+		return nil, err
+	}
+	return withPrefix(keys, prefix), nil
+}
+
+// DeletePrefix deletes the tracked items whose key starts with prefix,
+// e.g. those of a tenant being offboarded, or of a test. See ListKeys.
+func (c *Cache) DeletePrefix(prefix string) error {
+	keys, err := c.ListKeys(prefix)
+	if err != nil {
+		return err
+	}
+	return c.registry().sweep("", keys)
+}
+
+// ExpirePrefix sets the tracked items whose key starts with prefix to
+// expire in ttl, e.g. to let a tenant's items age out instead of deleting
+// them at once. Items written meanwhile keep their new expiry. See
+// ListKeys.
+func (c *Cache) ExpirePrefix(prefix string, ttl time.Duration) error {
+	keys, err := c.ListKeys(prefix)
+	if err != nil {
+		return err
+	}
+
+	var mu sync.Mutex
+	var firstErr error
+	var gone []string
+	c.each(keys, func(key string) {
+		item, err := c.GetItem(key)
+		if err == nil {
+			item.Expiration, item.ExpiresAt = ttl, time.Time{}
+			err = c.CompareAndSwap(key, &item)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		switch err {
+		case nil, ErrCasMismatch:
+		case ErrKeyNotFound:
+			gone = append(gone, key)
+		default:
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	})
+
+	err = c.registry().removeAll(gone)
+	if firstErr != nil {
+		return firstErr
+	}
+	return err
+}
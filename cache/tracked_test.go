@@ -0,0 +1,87 @@
+package cache_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iron-io/iron_go3/cache"
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestTrackedKeys(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("tracked keys", func() {
+		c, _, done := testCache("tracked")
+		defer done()
+		c.TrackKeys = true
+
+		It("lists the keys written", func() {
+			Expect(c.Set("tenant-1:a", "v"), ToBeNil)
+			Expect(c.Set("tenant-1:b", "v"), ToBeNil)
+			Expect(c.Set("tenant-2:a", "v"), ToBeNil)
+			Expect(c.Set("tenant-2:b", "v"), ToBeNil)
+			keys, err := c.ListKeys("tenant-1:")
+			Expect(err, ToBeNil)
+			Expect(keys, ToDeepEqual, []string{"tenant-1:a", "tenant-1:b"})
+
+			Expect(c.Delete("tenant-2:b"), ToBeNil)
+			keys, _ = c.ListKeys("")
+			Expect(keys, ToDeepEqual, []string{"tenant-1:a", "tenant-1:b", "tenant-2:a"})
+		})
+
+		It("deletes the items of a prefix", func() {
+			Expect(c.DeletePrefix("tenant-1:"), ToBeNil)
+			_, err := c.Get("tenant-1:a")
+			Expect(err, ToNotBeNil)
+			v, _ := c.Get("tenant-2:a")
+			Expect(v, ToEqual, "v")
+			keys, _ := c.ListKeys("")
+			Expect(keys, ToDeepEqual, []string{"tenant-2:a"})
+		})
+
+		It("expires the items of a prefix", func() {
+			Expect(c.ExpirePrefix("tenant-2:", time.Minute), ToBeNil)
+			item, err := c.GetItem("tenant-2:a")
+			Expect(err, ToBeNil)
+			Expect(item.Value, ToEqual, "v")
+			Expect(item.TTL(time.Now()) <= time.Minute, ToBeTrue)
+		})
+
+		It("tracks namespaces in its own index", func() {
+			n := cache.Namespaced(c, "tenant-3:")
+			Expect(n.Set("a", "v"), ToBeNil)
+			keys, _ := c.ListKeys("tenant-3:")
+			Expect(keys, ToDeepEqual, []string{"tenant-3:a"})
+			keys, _ = n.Keys()
+			Expect(keys, ToDeepEqual, []string{"a"})
+			Expect(n.ClearNamespace(), ToBeNil)
+			keys, _ = c.ListKeys("tenant-3:")
+			Expect(len(keys), ToEqual, 0)
+		})
+
+		It("keeps the keys of concurrent writers", func() {
+			var wg sync.WaitGroup
+			errs := make(chan error, 50)
+			for i := 0; i < 50; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					errs <- c.Set(fmt.Sprintf("tenant-4:%02d", i), "v")
+				}(i)
+			}
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				Expect(err, ToBeNil)
+			}
+			keys, err := c.ListKeys("tenant-4:")
+			Expect(err, ToBeNil)
+			Expect(len(keys), ToEqual, 50)
+			Expect(keys[0], ToEqual, "tenant-4:00")
+			Expect(keys[49], ToEqual, "tenant-4:49")
+		})
+	})
+}
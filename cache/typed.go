@@ -0,0 +1,78 @@
+package cache
+
+import (
+	"net/http"
+	"time"
+)
+
+// Typed reads and writes values of type T in a cache, encoded with a Codec.
+//
+//	users := cache.NewTyped[User](c, cache.JSON)
+//	err := users.Set("u:42", User{Name: "Ada"}, time.Hour)
+//	u, err := users.Get("u:42")
+type Typed[T any] struct {
+	Cache *Cache
+	Codec Codec
+}
+
+// NewTyped returns a Typed for c, encoding values with codec.
+func NewTyped[T any](c *Cache, codec Codec) *Typed[T] {
+	return &Typed[T]{Cache: c, Codec: codec}
+}
+
+// Get gets the value stored at key.
+func (t *Typed[T]) Get(key string) (T, error) {
+	return GetAs[T](t.Cache, t.Codec, key)
+}
+
+// Set stores value at key, a ttl of 0 uses the server's default expiration.
+func (t *Typed[T]) Set(key string, value T, ttl time.Duration) error {
+	return SetAs(t.Cache, t.Codec, key, value, ttl)
+}
+
+// Delete removes key from the cache.
+func (t *Typed[T]) Delete(key string) error {
+	return t.Cache.Delete(key)
+}
+
+// GetAs gets the value at key from c and decodes it into a T with codec.
+func GetAs[T any](c *Cache, codec Codec, key string) (T, error) {
+	var v T
+	err := codec.Get(c, key, &v)
+	return v, err
+}
+
+// SetAs encodes value with codec and stores it at key in c.
+func SetAs[T any](c *Cache, codec Codec, key string, value T, ttl time.Duration) error {
+	return codec.Put(c, key, &Item{Object: value, Expiration: ttl})
+}
+
+// GetOrCompute gets the value at key, or if it isn't cached computes it
+// with compute and stores it with ttl. See the GetOrCompute function.
+func (t *Typed[T]) GetOrCompute(key string, ttl time.Duration, compute func() (T, error)) (T, error) {
+	return GetOrCompute(t.Cache, t.Codec, key, ttl, compute)
+}
+
+var computes flightGroup
+
+// GetOrCompute gets the value at key from c, or if it isn't cached computes
+// it with compute and stores it with ttl.
+//
+// Concurrent calls in this process for the same key share a single compute.
+// If storing the computed value fails, it is returned along with the error.
+func GetOrCompute[T any](c *Cache, codec Codec, key string, ttl time.Duration, compute func() (T, error)) (T, error) {
+	v, err := GetAs[T](c, codec, key)
+	if !isStatus(err, http.StatusNotFound) {
+		return v, err
+	}
+
+	shared, err := computes.do(c.Settings.ProjectId+"/"+c.Name+"/"+key, func() (interface{}, error) {
+		v, err := compute()
+		if err != nil {
+			return v, err
+		}
+		return v, SetAs(c, codec, key, v, ttl)
+	})
+	v, _ = shared.(T)
+	return v, err
+}
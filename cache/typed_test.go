@@ -0,0 +1,105 @@
+package cache_test
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/iron-io/iron_go3/cache"
+	. "github.com/jeffh/go.bdd"
+)
+
+type user struct {
+	Name  string
+	Roles []string
+}
+
+func TestTyped(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("typed cache values", func() {
+		c, _, done := testCache("typed")
+		defer done()
+
+		It("round trips structs with both codecs", func() {
+			for _, codec := range []cache.Codec{cache.JSON, cache.Gob} {
+				users := cache.NewTyped[user](c, codec)
+				in := user{Name: "Ada", Roles: []string{"admin", "ops"}}
+				Expect(users.Set("u", in, time.Minute), ToBeNil)
+
+				out, err := users.Get("u")
+				Expect(err, ToBeNil)
+				Expect(out, ToDeepEqual, in)
+			}
+		})
+
+		It("reads numbers stored with Set", func() {
+			Expect(c.Set("n", 42), ToBeNil)
+			n, err := cache.GetAs[int](c, cache.JSON, "n")
+			Expect(err, ToBeNil)
+			Expect(n, ToEqual, 42)
+		})
+
+		It("returns the error for missing keys", func() {
+			_, err := cache.GetAs[user](c, cache.JSON, "missing")
+			Expect(err, ToNotBeNil)
+		})
+	})
+}
+
+func TestGetOrCompute(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("GetOrCompute", func() {
+		c, _, done := testCache("memo")
+		defer done()
+		users := cache.NewTyped[user](c, cache.JSON)
+
+		It("computes and stores missing values", func() {
+			calls := 0
+			compute := func() (user, error) {
+				calls++
+				return user{Name: "Grace"}, nil
+			}
+			u, err := users.GetOrCompute("g", time.Minute, compute)
+			Expect(err, ToBeNil)
+			Expect(u.Name, ToEqual, "Grace")
+			u, err = users.GetOrCompute("g", time.Minute, compute)
+			Expect(err, ToBeNil)
+			Expect(u.Name, ToEqual, "Grace")
+			Expect(calls, ToEqual, 1)
+		})
+
+		It("doesn't store failed computes", func() {
+			_, err := users.GetOrCompute("f", time.Minute, func() (user, error) {
+				return user{}, errors.New("lookup failed")
+			})
+			Expect(err, ToNotBeNil)
+			_, err = users.Get("f")
+			Expect(err, ToNotBeNil)
+		})
+
+		It("shares concurrent computes", func() {
+			var calls int32
+			release := make(chan struct{})
+			var wg sync.WaitGroup
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					users.GetOrCompute("c", time.Minute, func() (user, error) {
+						atomic.AddInt32(&calls, 1)
+						<-release
+						return user{Name: "Linus"}, nil
+					})
+				}()
+			}
+			time.Sleep(50 * time.Millisecond)
+			close(release)
+			wg.Wait()
+			Expect(atomic.LoadInt32(&calls), ToEqual, int32(1))
+		})
+	})
+}
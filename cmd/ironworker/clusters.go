@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/iron-io/iron_go3/worker"
+)
+
+func listClusters(w *worker.Worker, args []string) error {
+	fs := flags("clusters")
+	fs.Parse(args)
+
+	clusters, err := w.ClusterList()
+	if err != nil {
+		return err
+	}
+
+	tw := table()
+	fmt.Fprintln(tw, "ID\tNAME\tMACHINES\tRUNNING\tQUEUED")
+	for _, c := range clusters {
+		stats, err := w.ClusterStats(c.Id)
+		if err != nil {
+			return fmt.Errorf("getting stats of %s: %w", c.Id, err)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\n", c.Id, c.Name, stats.Machines, stats.RunningTasks, stats.QueuedTasks)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/iron-io/iron_go3/worker"
+)
+
+func upload(w *worker.Worker, args []string) error {
+	fs := flags("upload")
+	name := fs.String("name", "", "code package name (required)")
+	zip := fs.String("zip", "", "zip file with the worker's code")
+	image := fs.String("image", "", "docker image to run")
+	command := fs.String("command", "", "command to run the worker")
+	configFile := fs.String("config", "", "file whose contents are passed to every task as its config")
+	maxConcurrency := fs.Int("max-concurrency", 0, "maximum number of concurrent tasks, 0 for no limit")
+	retries := fs.Int("retries", -1, "number of times to retry failed tasks")
+	retriesDelay := fs.Int("retries-delay", -1, "seconds to wait before retrying a failed task")
+	priority := fs.Int("priority", 0, "default priority of tasks")
+	cluster := fs.String("cluster", "", "default cluster of tasks")
+	env := envFlag{}
+	fs.Var(env, "e", "environment variable KEY=VALUE, may be repeated")
+	fs.Parse(args)
+
+	if *name == "" {
+		fs.Usage()
+		return errors.New("-name is required")
+	}
+
+	code := worker.Code{
+		Name:            *name,
+		Image:           *image,
+		Command:         *command,
+		MaxConcurrency:  *maxConcurrency,
+		DefaultPriority: *priority,
+		DefaultCluster:  *cluster,
+		EnvVars:         env,
+	}
+	if *configFile != "" {
+		b, err := ioutil.ReadFile(*configFile)
+		if err != nil {
+			return err
+		}
+		code.Config = string(b)
+	}
+	if *retries >= 0 {
+		code.Retries = retries
+	}
+	if *retriesDelay >= 0 {
+		code.RetriesDelay = retriesDelay
+	}
+
+	out, err := w.CodePackageZipUpload(*zip, code)
+	if err != nil {
+		return err
+	}
+	fmt.Println("uploaded", out.Name, out.Id)
+	return nil
+}
+
+func listCodes(w *worker.Worker, args []string) error {
+	fs := flags("codes")
+	page := fs.Int("page", 0, "page to list")
+	perPage := fs.Int("per-page", 30, "code packages per page")
+	fs.Parse(args)
+
+	codes, err := w.CodePackageList(*page, *perPage)
+	if err != nil {
+		return err
+	}
+
+	tw := table()
+	fmt.Fprintln(tw, "ID\tNAME\tREV\tUPDATED")
+	for _, c := range codes {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\n", c.Id, c.Name, c.Rev, c.LatestChange.Format("2006-01-02 15:04:05"))
+	}
+	return tw.Flush()
+}
+
+func pauseCodes(w *worker.Worker, args []string) error {
+	fs := flags("pause")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("expected at least one code name")
+	}
+
+	for _, name := range fs.Args() {
+		if err := w.PauseCode(name); err != nil {
+			return fmt.Errorf("pausing %s: %w", name, err)
+		}
+		fmt.Println("paused", name)
+	}
+	return nil
+}
+
+func resumeCodes(w *worker.Worker, args []string) error {
+	fs := flags("resume")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("expected at least one code name")
+	}
+
+	for _, name := range fs.Args() {
+		if err := w.ResumeCode(name); err != nil {
+			return fmt.Errorf("resuming %s: %w", name, err)
+		}
+		fmt.Println("resumed", name)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+// Command ironworker manages IronWorker code packages, tasks and schedules
+// from the command line. It reads credentials the same way the library does,
+// see http://dev.iron.io/worker/reference/configuration/
+//
+// Usage:
+//
+//	ironworker [-env name] <command> [flags] [args]
+//
+// Run ironworker help for the list of commands.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/iron-io/iron_go3/config"
+	"github.com/iron-io/iron_go3/worker"
+)
+
+type command struct {
+	usage string
+	help  string
+	run   func(w *worker.Worker, args []string) error
+}
+
+var commands map[string]command
+
+func init() {
+	// set up in init, the commands refer back to this map for their usage
+	commands = map[string]command{
+		"upload":           {"upload [flags] -name NAME", "upload a new revision of a code package", upload},
+		"codes":            {"codes", "list code packages", listCodes},
+		"pause":            {"pause CODE_NAME...", "stop running the queued tasks of code packages", pauseCodes},
+		"resume":           {"resume CODE_NAME...", "run the queued tasks of paused code packages again", resumeCodes},
+		"queue":            {"queue [flags] CODE_NAME", "queue a task", queueTask},
+		"info":             {"info TASK_ID", "show a task", taskInfo},
+		"log":              {"log [-f] TASK_ID", "print the log of a task", taskLog},
+		"tasks":            {"tasks [flags]", "list tasks", listTasks},
+		"cancel":           {"cancel TASK_ID...", "cancel tasks", cancelTasks},
+		"schedule":         {"schedule [flags] CODE_NAME", "schedule a task", scheduleTask},
+		"schedules":        {"schedules", "list schedules", listSchedules},
+		"unschedule":       {"unschedule SCHEDULE_ID...", "cancel schedules", cancelSchedules},
+		"clusters":         {"clusters", "list clusters with their machines and tasks", listClusters},
+		"export-schedules": {"export-schedules [-yaml]", "print the active schedules as a schedule file", exportSchedules},
+		"import-schedules": {"import-schedules [-prune] FILE", "create or replace the schedules of a schedule file", importSchedules},
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: ironworker [-env name] <command> [flags] [args]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "commands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	tw := tabwriter.NewWriter(os.Stderr, 0, 8, 2, ' ', 0)
+	for _, name := range names {
+		fmt.Fprintf(tw, "  %s\t%s\n", name, commands[name].help)
+	}
+	tw.Flush()
+}
+
+func main() {
+	env := flag.String("env", "", "environment to use from iron.json")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() < 1 || flag.Arg(0) == "help" {
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[flag.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "ironworker: unknown command %q\n\n", flag.Arg(0))
+		usage()
+		os.Exit(2)
+	}
+
+	w, err := newWorker(*env)
+	if err != nil {
+		fatal(err)
+	}
+	if err := cmd.run(w, flag.Args()[1:]); err != nil {
+		fatal(err)
+	}
+}
+
+// newWorker turns the config package's panics about missing credentials
+// into an error.
+func newWorker(env string) (w *worker.Worker, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	return &worker.Worker{Settings: config.ConfigWithEnv("iron_worker", env)}, nil
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "ironworker:", err)
+	os.Exit(1)
+}
+
+// flags returns a flag set for a command that prints the command's usage.
+func flags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: ironworker", commands[name].usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// envFlag collects repeated -e KEY=VALUE flags.
+type envFlag map[string]string
+
+func (e envFlag) String() string { return "" }
+
+func (e envFlag) Set(s string) error {
+	kv := strings.SplitN(s, "=", 2)
+	if len(kv) != 2 || kv[0] == "" {
+		return fmt.Errorf("expected KEY=VALUE, got %q", s)
+	}
+	e[kv[0]] = kv[1]
+	return nil
+}
+
+func table() *tabwriter.Writer {
+	return tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/iron-io/iron_go3/irontest"
+	"github.com/iron-io/iron_go3/worker"
+	. "github.com/jeffh/go.bdd"
+)
+
+// output runs the command run with args and returns what it printed.
+func output(w *worker.Worker, run func(*worker.Worker, []string) error, args ...string) (string, error) {
+	r, pw, err := os.Pipe()
+	if err != nil {
+		return "", err
+	}
+	stdout := os.Stdout
+	os.Stdout = pw
+	err = run(w, args)
+	os.Stdout = stdout
+	pw.Close()
+	out, _ := io.ReadAll(r)
+	return string(out), err
+}
+
+func TestFlags(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("command line flags", func() {
+		It("collects repeated env vars", func() {
+			env := envFlag{}
+			Expect(env.Set("A=1"), ToBeNil)
+			Expect(env.Set("B=x=y"), ToBeNil)
+			Expect(env.Set("C="), ToBeNil)
+			Expect(map[string]string(env), ToDeepEqual, map[string]string{"A": "1", "B": "x=y", "C": ""})
+			Expect(env.Set("D"), ToNotBeNil)
+			Expect(env.Set("=1"), ToNotBeNil)
+		})
+
+		It("documents every command", func() {
+			for name, cmd := range commands {
+				Expect(strings.HasPrefix(cmd.usage, name), ToBeTrue)
+				Expect(cmd.help != "", ToBeTrue)
+				Expect(flags(name).Name(), ToEqual, name)
+			}
+		})
+	})
+}
+
+func TestCommands(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("commands", func() {
+		srv := irontest.NewServer()
+		defer srv.Close()
+		w := &worker.Worker{Settings: srv.Settings("iron_worker")}
+
+		It("uploads code packages", func() {
+			out, err := output(w, upload, "-name", "hello", "-image", "iron/hello", "-max-concurrency", "3", "-retries", "2", "-e", "A=1")
+			Expect(err, ToBeNil)
+			Expect(strings.HasPrefix(out, "uploaded hello "), ToBeTrue)
+			code, ok := srv.Worker.Code("hello")
+			Expect(ok, ToBeTrue)
+			Expect(code.Image, ToEqual, "iron/hello")
+			Expect(code.MaxConcurrency, ToEqual, 3)
+			Expect(*code.Retries, ToEqual, 2)
+			Expect(code.RetriesDelay == nil, ToBeTrue)
+			Expect(code.EnvVars, ToDeepEqual, map[string]string{"A": "1"})
+
+			_, err = output(w, upload, "-image", "iron/hello")
+			Expect(err, ToNotBeNil)
+		})
+
+		It("lists code packages", func() {
+			out, err := output(w, listCodes)
+			Expect(err, ToBeNil)
+			lines := strings.Split(strings.TrimSpace(out), "\n")
+			Expect(len(lines), ToEqual, 2)
+			Expect(strings.Fields(lines[1])[1], ToEqual, "hello")
+		})
+
+		It("queues tasks", func() {
+			out, err := output(w, queueTask, "-payload", `{"n":1}`, "-priority", "2", "-label", "nightly", "-timeout", "1m", "hello")
+			Expect(err, ToBeNil)
+			id := strings.TrimSpace(out)
+			task, ok := srv.Worker.Task(id)
+			Expect(ok, ToBeTrue)
+			Expect(task.Payload, ToEqual, `{"n":1}`)
+			Expect(task.Priority, ToEqual, 2)
+			Expect(task.Label, ToEqual, "nightly")
+			Expect(task.Timeout, ToEqual, 60)
+
+			_, err = output(w, queueTask)
+			Expect(err, ToNotBeNil)
+		})
+
+		It("shows and cancels tasks", func() {
+			ids, err := w.TaskQueue(worker.Task{CodeName: "hello"})
+			Expect(err, ToBeNil)
+			out, err := output(w, taskInfo, ids[0])
+			Expect(err, ToBeNil)
+			Expect(strings.Contains(out, "\nstatus    queued\n"), ToBeTrue)
+
+			out, err = output(w, cancelTasks, ids[0])
+			Expect(err, ToBeNil)
+			Expect(out, ToEqual, "cancelled "+ids[0]+"\n")
+			task, _ := srv.Worker.Task(ids[0])
+			Expect(task.Status, ToEqual, irontest.StatusCancelled)
+
+			_, err = output(w, cancelTasks, ids[0])
+			Expect(err, ToNotBeNil)
+		})
+
+		It("lists tasks by status", func() {
+			out, err := output(w, listTasks, "-code", "hello", "-status", "cancelled")
+			Expect(err, ToBeNil)
+			lines := strings.Split(strings.TrimSpace(out), "\n")
+			Expect(len(lines), ToEqual, 2)
+			Expect(strings.Fields(lines[1])[2], ToEqual, "cancelled")
+		})
+
+		It("prints the log of finished tasks", func() {
+			ids, err := w.TaskQueue(worker.Task{CodeName: "hello"})
+			Expect(err, ToBeNil)
+			Expect(srv.Worker.Finish(ids[0], irontest.StatusComplete, "", "hello\n"), ToBeNil)
+			out, err := output(w, taskLog, ids[0])
+			Expect(err, ToBeNil)
+			Expect(out, ToEqual, "hello\n")
+			out, err = output(w, taskLog, "-f", ids[0])
+			Expect(err, ToBeNil)
+			Expect(out, ToEqual, "hello\n")
+		})
+
+		It("pauses and resumes code packages", func() {
+			out, err := output(w, pauseCodes, "hello")
+			Expect(err, ToBeNil)
+			Expect(out, ToEqual, "paused hello\n")
+			code, _ := srv.Worker.Code("hello")
+			Expect(code.Paused, ToBeTrue)
+
+			out, err = output(w, resumeCodes, "hello")
+			Expect(err, ToBeNil)
+			Expect(out, ToEqual, "resumed hello\n")
+
+			_, err = output(w, pauseCodes, "missing")
+			Expect(err, ToNotBeNil)
+		})
+
+		It("schedules tasks", func() {
+			_, err := output(w, scheduleTask, "-cron", "*/10 * * * *", "-payload", "{}", "hello")
+			Expect(err, ToBeNil)
+			out, err := output(w, listSchedules)
+			Expect(err, ToBeNil)
+			Expect(strings.Contains(out, "hello"), ToBeTrue)
+		})
+	})
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/iron-io/iron_go3/worker"
+)
+
+func scheduleTask(w *worker.Worker, args []string) error {
+	fs := flags("schedule")
+	name := fs.String("name", "", "schedule name, defaults to the code name")
+	payloadFile := fs.String("payload-file", "", "file to send as the payload of each task")
+	payload := fs.String("payload", "", "payload to send, ignored if -payload-file is set")
+	cron := fs.String("cron", "", "cron expression, must fire at a fixed interval")
+	runEvery := fs.Duration("run-every", 0, "time between runs")
+	startAt := fs.String("start-at", "", "RFC3339 time of the first run")
+	endAt := fs.String("end-at", "", "RFC3339 time after which the schedule stops")
+	runTimes := fs.Int("run-times", 0, "number of times to run, 0 for no limit")
+	priority := fs.Int("priority", 0, "task priority, 0-2")
+	cluster := fs.String("cluster", "", "cluster to run the tasks on")
+	label := fs.String("label", "", "label for the tasks")
+	dryRun := fs.Bool("n", false, "print the next runs of -cron and exit")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("expected a code name")
+	}
+
+	s := worker.Schedule{
+		CodeName: fs.Arg(0),
+		Name:     *name,
+		Payload:  *payload,
+		Priority: priority,
+		Cluster:  *cluster,
+		Label:    *label,
+	}
+	if s.Name == "" {
+		s.Name = s.CodeName
+	}
+	if *payloadFile != "" {
+		b, err := ioutil.ReadFile(*payloadFile)
+		if err != nil {
+			return err
+		}
+		s.Payload = string(b)
+	}
+
+	if *cron != "" {
+		c, err := worker.ParseCron(*cron)
+		if err != nil {
+			return err
+		}
+		if *dryRun {
+			for _, t := range c.NextRuns(5) {
+				fmt.Println(t.Format(time.RFC3339))
+			}
+			return nil
+		}
+		if err := s.SetCron(*cron); err != nil {
+			return err
+		}
+	}
+	if *runEvery > 0 {
+		seconds := int(runEvery.Seconds())
+		s.RunEvery = &seconds
+	}
+	if *startAt != "" {
+		t, err := time.Parse(time.RFC3339, *startAt)
+		if err != nil {
+			return err
+		}
+		s.StartAt = &t
+	}
+	if *endAt != "" {
+		t, err := time.Parse(time.RFC3339, *endAt)
+		if err != nil {
+			return err
+		}
+		s.EndAt = &t
+	}
+	if *runTimes > 0 {
+		s.RunTimes = runTimes
+	}
+
+	ids, err := w.Schedule(s)
+	if err != nil {
+		return err
+	}
+	fmt.Println(ids[0])
+	return nil
+}
+
+func listSchedules(w *worker.Worker, args []string) error {
+	fs := flags("schedules")
+	fs.Parse(args)
+
+	schedules, err := w.ScheduleList()
+	if err != nil {
+		return err
+	}
+
+	tw := table()
+	fmt.Fprintln(tw, "ID\tCODE\tSTATUS\tRUNS\tNEXT")
+	for _, s := range schedules {
+		next := "-"
+		if !s.NextStart.IsZero() {
+			next = s.NextStart.Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\n", s.Id, s.CodeName, s.Status, s.RunCount, next)
+	}
+	return tw.Flush()
+}
+
+func cancelSchedules(w *worker.Worker, args []string) error {
+	fs := flags("unschedule")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("expected at least one schedule id")
+	}
+
+	for _, id := range fs.Args() {
+		if err := w.ScheduleCancel(id); err != nil {
+			return fmt.Errorf("cancelling %s: %w", id, err)
+		}
+		fmt.Println("cancelled", id)
+	}
+	return nil
+}
+
+func exportSchedules(w *worker.Worker, args []string) error {
+	fs := flags("export-schedules")
+	asYAML := fs.Bool("yaml", false, "print YAML instead of JSON")
+	fs.Parse(args)
+
+	format := "json"
+	if *asYAML {
+		format = "yaml"
+	}
+	return w.ScheduleExport(os.Stdout, format)
+}
+
+func importSchedules(w *worker.Worker, args []string) error {
+	fs := flags("import-schedules")
+	prune := fs.Bool("prune", false, "cancel the active schedules missing from the file")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("expected a schedule file")
+	}
+
+	res, err := w.ScheduleImport(fs.Arg(0), *prune)
+	for _, change := range []struct {
+		what  string
+		names []string
+	}{{"created", res.Created}, {"replaced", res.Replaced}, {"unchanged", res.Unchanged}, {"cancelled", res.Cancelled}} {
+		for _, name := range change.names {
+			fmt.Println(change.what, name)
+		}
+	}
+	return err
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/iron-io/iron_go3/api"
+	"github.com/iron-io/iron_go3/worker"
+)
+
+func queueTask(w *worker.Worker, args []string) error {
+	fs := flags("queue")
+	payloadFile := fs.String("payload-file", "", "file to send as the task's payload")
+	payload := fs.String("payload", "", "payload to send, ignored if -payload-file is set")
+	priority := fs.Int("priority", 0, "task priority, 0-2")
+	timeout := fs.Duration("timeout", 0, "maximum run time of the task")
+	delay := fs.Duration("delay", 0, "time to wait before running the task")
+	cluster := fs.String("cluster", "", "cluster to run the task on")
+	label := fs.String("label", "", "label for the task")
+	wait := fs.Bool("wait", false, "wait for the task to finish and print its log")
+	env := envFlag{}
+	fs.Var(env, "e", "environment variable KEY=VALUE, may be repeated")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("expected a code name")
+	}
+
+	task := worker.Task{
+		CodeName: fs.Arg(0),
+		Payload:  *payload,
+		Priority: *priority,
+		Cluster:  *cluster,
+		Label:    *label,
+		EnvVars:  env,
+	}
+	if *payloadFile != "" {
+		b, err := ioutil.ReadFile(*payloadFile)
+		if err != nil {
+			return err
+		}
+		task.Payload = string(b)
+	}
+	if *timeout > 0 {
+		task.Timeout = timeout
+	}
+	if *delay > 0 {
+		task.Delay = delay
+	}
+
+	ids, err := w.TaskQueue(task)
+	if err != nil {
+		return err
+	}
+	fmt.Println(ids[0])
+
+	if !*wait {
+		return nil
+	}
+	return followLog(w, ids[0])
+}
+
+func taskInfo(w *worker.Worker, args []string) error {
+	fs := flags("info")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("expected a task id")
+	}
+
+	t, err := w.TaskInfo(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	tw := table()
+	fmt.Fprintf(tw, "id\t%s\n", t.Id)
+	fmt.Fprintf(tw, "code\t%s (rev %s)\n", t.CodeName, t.CodeRev)
+	fmt.Fprintf(tw, "status\t%s\n", t.Status)
+	if t.Msg != "" {
+		fmt.Fprintf(tw, "msg\t%s\n", t.Msg)
+	}
+	fmt.Fprintf(tw, "created\t%s\n", t.CreatedAt.Format(time.RFC3339))
+	if !t.StartTime.IsZero() {
+		fmt.Fprintf(tw, "started\t%s\n", t.StartTime.Format(time.RFC3339))
+	}
+	if !t.EndTime.IsZero() {
+		fmt.Fprintf(tw, "ended\t%s\n", t.EndTime.Format(time.RFC3339))
+	}
+	fmt.Fprintf(tw, "duration\t%s\n", time.Duration(t.Duration)*time.Millisecond)
+	return tw.Flush()
+}
+
+func taskLog(w *worker.Worker, args []string) error {
+	fs := flags("log")
+	follow := fs.Bool("f", false, "keep printing the log until the task finishes")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("expected a task id")
+	}
+
+	if *follow {
+		return followLog(w, fs.Arg(0))
+	}
+	return w.TaskLogTo(fs.Arg(0), os.Stdout, nil)
+}
+
+// followLog prints the task's log as it grows until the task is finished.
+func followLog(w *worker.Worker, taskId string) error {
+	var printed int
+	for {
+		info, err := w.TaskInfo(taskId)
+		if err != nil {
+			return err
+		}
+
+		log, err := w.TaskLog(taskId)
+		if api.StatusCode(err) == 404 {
+			log, err = nil, nil // no output yet
+		}
+		if err != nil {
+			return err
+		}
+		if len(log) > printed {
+			os.Stdout.Write(log[printed:])
+			printed = len(log)
+		}
+
+		if info.Status.IsTerminal() {
+			if info.Status != worker.StatusComplete {
+				return fmt.Errorf("task %s finished with status %s %s", taskId, info.Status, info.Msg)
+			}
+			return nil
+		}
+		time.Sleep(2 * time.Second)
+	}
+}
+
+func listTasks(w *worker.Worker, args []string) error {
+	fs := flags("tasks")
+	code := fs.String("code", "", "only list tasks of this code package")
+	label := fs.String("label", "", "only list tasks with this label")
+	status := fs.String("status", "", "comma separated statuses to list, e.g. running,queued")
+	page := fs.Int("page", 0, "page to list")
+	perPage := fs.Int("per-page", 30, "tasks per page")
+	fs.Parse(args)
+
+	params := worker.TaskListParams{CodeName: *code, Label: *label, Page: *page, PerPage: *perPage}
+	if *status != "" {
+		for _, s := range strings.Split(*status, ",") {
+			params.Statuses = append(params.Statuses, worker.TaskStatus(strings.TrimSpace(s)))
+		}
+	}
+
+	tasks, err := w.FilteredTaskList(params)
+	if err != nil {
+		return err
+	}
+
+	tw := table()
+	fmt.Fprintln(tw, "ID\tCODE\tSTATUS\tCREATED\tDURATION")
+	for _, t := range tasks {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", t.Id, t.CodeName, t.Status,
+			t.CreatedAt.Format("2006-01-02 15:04:05"), time.Duration(t.Duration)*time.Millisecond)
+	}
+	return tw.Flush()
+}
+
+func cancelTasks(w *worker.Worker, args []string) error {
+	fs := flags("cancel")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("expected at least one task id")
+	}
+
+	for _, id := range fs.Args() {
+		if err := w.TaskCancel(id); err != nil {
+			return fmt.Errorf("cancelling %s: %w", id, err)
+		}
+		fmt.Println("cancelled", id)
+	}
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"io/ioutil"
 	"log"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -22,10 +23,21 @@ type Settings struct {
 	Port       uint16 `json:"port,omitempty"`
 	ApiVersion string `json:"api_version,omitempty"`
 	UserAgent  string `json:"user_agent,omitempty"`
+	// Hosts lists alternates of Host, separated by commas, e.g. other load
+	// balancer addresses or regional endpoints, requests fail over to when
+	// Host fails. They may carry a port, Port is used otherwise. It is a
+	// string, not a slice, so Settings stay comparable.
+	Hosts string `json:"hosts,omitempty"`
 }
 
 var (
-	debug     = false
+	// LogLevel is the lowest level the package logs at, slog.LevelDebug if
+	// IRON_CONFIG_DEBUG is set. By default nothing is logged.
+	LogLevel = new(slog.LevelVar)
+	// Logger receives the package's log records, if nil they are written
+	// to stderr.
+	Logger *slog.Logger
+
 	goVersion = runtime.Version()
 	Presets   = map[string]Settings{
 		"worker": Settings{
@@ -52,10 +64,22 @@ var (
 	}
 )
 
-func dbg(v ...interface{}) {
-	if debug {
-		log.Println(v...)
+// levelOff is above any level logged.
+const levelOff = slog.LevelError + 4
+
+func init() {
+	LogLevel.Set(levelOff)
+}
+
+func dbg(msg string, args ...interface{}) {
+	if LogLevel.Level() > slog.LevelDebug {
+		return
 	}
+	logger := Logger
+	if logger == nil {
+		logger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	}
+	logger.Debug(msg, args...)
 }
 
 // ManualConfig gathers configuration from env variables, json config files
@@ -90,8 +114,7 @@ func ConfigWithEnv(fullProduct, env string) (settings Settings) {
 
 func config(fullProduct, env string, configuration *Settings) Settings {
 	if os.Getenv("IRON_CONFIG_DEBUG") != "" {
-		debug = true
-		dbg("debugging of config enabled")
+		LogLevel.Set(slog.LevelDebug)
 	}
 	pair := strings.SplitN(fullProduct, "_", 2)
 	if len(pair) != 2 {
@@ -165,19 +188,23 @@ func (s *Settings) manualConfig(settings *Settings) {
 func (s *Settings) commonEnv(prefix string) {
 	if token := os.Getenv(prefix + "TOKEN"); token != "" {
 		s.Token = token
-		dbg("env has TOKEN:", s.Token)
+		dbg("setting from env", "token", "[redacted]")
 	}
 	if pid := os.Getenv(prefix + "PROJECT_ID"); pid != "" {
 		s.ProjectId = pid
-		dbg("env has PROJECT_ID:", s.ProjectId)
+		dbg("setting from env", "project_id", s.ProjectId)
 	}
 	if host := os.Getenv(prefix + "HOST"); host != "" {
 		s.Host = host
-		dbg("env has HOST:", s.Host)
+		dbg("setting from env", "host", s.Host)
+	}
+	if hosts := os.Getenv(prefix + "HOSTS"); hosts != "" {
+		s.Hosts = hosts
+		dbg("setting from env", "hosts", s.Hosts)
 	}
 	if scheme := os.Getenv(prefix + "SCHEME"); scheme != "" {
 		s.Scheme = scheme
-		dbg("env has SCHEME:", s.Scheme)
+		dbg("setting from env", "scheme", s.Scheme)
 	}
 	if port := os.Getenv(prefix + "PORT"); port != "" {
 		n, err := strconv.ParseUint(port, 10, 16)
@@ -185,11 +212,11 @@ func (s *Settings) commonEnv(prefix string) {
 			panic(err)
 		}
 		s.Port = uint16(n)
-		dbg("env has PORT:", s.Port)
+		dbg("setting from env", "port", s.Port)
 	}
 	if vers := os.Getenv(prefix + "API_VERSION"); vers != "" {
 		s.ApiVersion = vers
-		dbg("env has API_VERSION:", s.ApiVersion)
+		dbg("setting from env", "api_version", s.ApiVersion)
 	}
 }
 
@@ -197,7 +224,7 @@ func (s *Settings) commonEnv(prefix string) {
 func (s *Settings) UseConfigFile(family, product, path, env string) {
 	content, err := ioutil.ReadFile(path)
 	if err != nil {
-		dbg("tried to", err, ": skipping")
+		dbg("skipping config file", "err", err)
 		return
 	}
 
@@ -207,7 +234,7 @@ func (s *Settings) UseConfigFile(family, product, path, env string) {
 		panic("Invalid JSON in " + path + ": " + err.Error())
 	}
 
-	dbg("config in", path, "found")
+	dbg("found config file", "path", path)
 
 	if env != "" {
 		envdata, ok := data[env].(map[string]interface{})
@@ -229,31 +256,43 @@ func (s *Settings) UseConfigFile(family, product, path, env string) {
 func (s *Settings) UseConfigMap(data map[string]interface{}) {
 	if token, found := data["token"]; found {
 		s.Token = token.(string)
-		dbg("config has token:", s.Token)
+		dbg("setting from config file", "token", "[redacted]")
 	}
 	if projectId, found := data["project_id"]; found {
 		s.ProjectId = projectId.(string)
-		dbg("config has project_id:", s.ProjectId)
+		dbg("setting from config file", "project_id", s.ProjectId)
 	}
 	if host, found := data["host"]; found {
 		s.Host = host.(string)
-		dbg("config has host:", s.Host)
+		dbg("setting from config file", "host", s.Host)
+	}
+	if hosts, found := data["hosts"]; found {
+		// a list, or a string like the env var
+		if list, ok := hosts.([]interface{}); ok {
+			names := make([]string, len(list))
+			for i, h := range list {
+				names[i] = h.(string)
+			}
+			hosts = strings.Join(names, ",")
+		}
+		s.Hosts = hosts.(string)
+		dbg("setting from config file", "hosts", s.Hosts)
 	}
 	if prot, found := data["scheme"]; found {
 		s.Scheme = prot.(string)
-		dbg("config has scheme:", s.Scheme)
+		dbg("setting from config file", "scheme", s.Scheme)
 	}
 	if port, found := data["port"]; found {
 		s.Port = uint16(port.(float64))
-		dbg("config has port:", s.Port)
+		dbg("setting from config file", "port", s.Port)
 	}
 	if vers, found := data["api_version"]; found {
 		s.ApiVersion = vers.(string)
-		dbg("config has api_version:", s.ApiVersion)
+		dbg("setting from config file", "api_version", s.ApiVersion)
 	}
 	if agent, found := data["user_agent"]; found {
 		s.UserAgent = agent.(string)
-		dbg("config has user_agent:", s.UserAgent)
+		dbg("setting from config file", "user_agent", s.UserAgent)
 	}
 }
 
@@ -268,6 +307,9 @@ func (s *Settings) UseSettings(settings *Settings) {
 	if settings.Host != "" {
 		s.Host = settings.Host
 	}
+	if settings.Hosts != "" {
+		s.Hosts = settings.Hosts
+	}
 	if settings.Scheme != "" {
 		s.Scheme = settings.Scheme
 	}
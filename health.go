@@ -0,0 +1,121 @@
+package iron
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/iron-io/iron_go3/config"
+)
+
+// ServiceHealth is the outcome of checking one service.
+type ServiceHealth struct {
+	// Product is e.g. "iron_mq".
+	Product string
+	Host    string
+	// Version is what the service reported, empty if it couldn't be reached.
+	Version string
+	// Latency is how long the check took.
+	Latency time.Duration
+	// Err is nil if the service was reached and accepted the credentials.
+	Err error
+}
+
+// OK reports whether the service is usable.
+func (h ServiceHealth) OK() bool { return h.Err == nil }
+
+// Health is the outcome of HealthCheck.
+type Health struct {
+	MQ     ServiceHealth
+	Worker ServiceHealth
+	Cache  ServiceHealth
+}
+
+// OK reports whether all services are usable.
+func (h Health) OK() bool { return h.Err() == nil }
+
+// Err joins the errors of the services that aren't usable, nil if all are.
+func (h Health) Err() error {
+	var errs []error
+	for _, s := range []ServiceHealth{h.MQ, h.Worker, h.Cache} {
+		if s.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", s.Product, s.Err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// healthEndpoints are cheap listings each service only serves with valid
+// credentials.
+var healthEndpoints = map[string]string{
+	"iron_mq":     "queues",
+	"iron_worker": "codes",
+	"iron_cache":  "caches",
+}
+
+// HealthCheck checks that MQ, Worker and Cache can be reached with
+// settings, see New, and accept its credentials. The services are checked
+// in parallel, each with one unauthenticated version request and one
+// small authenticated listing.
+func HealthCheck(ctx context.Context, settings *config.Settings) Health {
+	return New(settings).HealthCheck(ctx)
+}
+
+// HealthCheck is like the package level HealthCheck, but makes its
+// requests with c.
+func (c *Client) HealthCheck(ctx context.Context) Health {
+	var h Health
+	var wg sync.WaitGroup
+	for product, out := range map[string]*ServiceHealth{
+		"iron_mq":     &h.MQ,
+		"iron_worker": &h.Worker,
+		"iron_cache":  &h.Cache,
+	} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			*out = c.checkService(ctx, product)
+		}()
+	}
+	wg.Wait()
+	return h
+}
+
+func (c *Client) checkService(ctx context.Context, product string) (h ServiceHealth) {
+	s, err := c.healthSettings(product)
+	h = ServiceHealth{Product: product, Host: s.Host, Err: err}
+	if err != nil {
+		return h
+	}
+	start := time.Now()
+	defer func() { h.Latency = time.Since(start) }()
+
+	var version struct {
+		Version string `json:"version"`
+	}
+	if h.Err = c.API.VersionAction(s).WithContext(ctx).Req("GET", nil, &version); h.Err != nil {
+		return h
+	}
+	h.Version = version.Version
+
+	h.Err = c.API.Action(s, healthEndpoints[product]).
+		QueryAdd("per_page", "%d", 1).
+		WithContext(ctx).
+		Req("GET", nil, nil)
+	return h
+}
+
+// healthSettings returns c's settings for product, or the reason the
+// config package panics with when they are incomplete, e.g. without a
+// token, so a misconfigured service is reported rather than crashing the
+// check.
+func (c *Client) healthSettings(product string) (s config.Settings, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("invalid settings: %v", r)
+		}
+	}()
+	return c.Settings(product), nil
+}
@@ -0,0 +1,65 @@
+package iron_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/iron-io/iron_go3"
+	"github.com/iron-io/iron_go3/api"
+	"github.com/iron-io/iron_go3/irontest"
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestHealthCheck(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("health checks", func() {
+		srv := irontest.NewServer()
+		defer srv.Close()
+		settings := srv.Settings("iron_mq")
+		settings.ApiVersion = "" // each service keeps its own
+
+		It("reports healthy services", func() {
+			h := iron.HealthCheck(context.Background(), &settings)
+			Expect(h.OK(), ToBeTrue)
+			Expect(h.Err(), ToBeNil)
+			Expect(h.MQ.Version, ToEqual, "irontest")
+			Expect(h.Worker.Product, ToEqual, "iron_worker")
+			Expect(h.Cache.Latency > 0, ToBeTrue)
+		})
+
+		It("reports bad credentials", func() {
+			bad := settings
+			bad.Token = "wrong"
+			h := iron.HealthCheck(context.Background(), &bad)
+			Expect(h.OK(), ToEqual, false)
+			Expect(h.MQ.Version, ToEqual, "irontest")
+			Expect(api.StatusCode(h.Worker.Err), ToEqual, http.StatusUnauthorized)
+			Expect(h.Err(), ToNotBeNil)
+		})
+
+		It("reports missing credentials", func() {
+			for _, env := range []string{"IRON_TOKEN", "IRON_PROJECT_ID", "IRON_MQ_TOKEN", "IRON_MQ_PROJECT_ID",
+				"IRON_WORKER_TOKEN", "IRON_WORKER_PROJECT_ID", "IRON_CACHE_TOKEN", "IRON_CACHE_PROJECT_ID"} {
+				t.Setenv(env, "")
+			}
+			t.Setenv("HOME", t.TempDir())
+			empty := settings
+			empty.Token, empty.ProjectId = "", ""
+			h := iron.HealthCheck(context.Background(), &empty)
+			Expect(h.OK(), ToEqual, false)
+			Expect(h.MQ.Err, ToNotBeNil)
+			Expect(h.Worker.Err, ToNotBeNil)
+			Expect(h.Cache.Err, ToNotBeNil)
+		})
+
+		It("gives up when the context is done", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			h := iron.HealthCheck(ctx, &settings)
+			Expect(h.Cache.Err, ToNotBeNil)
+			Expect(h.Cache.Version, ToEqual, "")
+		})
+	})
+}
@@ -0,0 +1,87 @@
+// Package iron hands out IronMQ, IronWorker and IronCache clients that
+// share one api.Client, so they use the same HTTP transport, retry policy,
+// logger and metrics.
+//
+//	c := iron.New(nil, iron.WithLogger(logger))
+//	q := c.Queue("jobs")
+//	w := c.Worker()
+//	users := c.Cache("users")
+package iron
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/iron-io/iron_go3/api"
+	"github.com/iron-io/iron_go3/cache"
+	"github.com/iron-io/iron_go3/config"
+	"github.com/iron-io/iron_go3/mq"
+	"github.com/iron-io/iron_go3/worker"
+)
+
+// A Client makes clients for the iron.io services.
+type Client struct {
+	// API is shared by all clients handed out.
+	API *api.Client
+
+	settings *config.Settings
+}
+
+// An Option configures the api.Client of New.
+type Option func(*api.Client)
+
+// WithHTTPClient makes requests with hc.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *api.Client) { c.HTTPClient = hc }
+}
+
+// WithRetryPolicy retries requests according to p.
+func WithRetryPolicy(p api.RetryPolicy) Option {
+	return func(c *api.Client) { c.Retry = p }
+}
+
+// WithLogger sends log records to l.
+func WithLogger(l *slog.Logger) Option {
+	return func(c *api.Client) { c.Logger = l }
+}
+
+// WithLogLevel logs at level and above, see api.LevelTrace.
+func WithLogLevel(level slog.Leveler) Option {
+	return func(c *api.Client) { c.LogLevel = level }
+}
+
+// WithMetrics reports every request to m.
+func WithMetrics(m api.Metrics) Option {
+	return func(c *api.Client) { c.Metrics = m }
+}
+
+// New returns a Client configured with opts. Each service's settings are
+// read from the environment and config files like config.Config does,
+// overridden by the set fields of settings, which may be nil.
+func New(settings *config.Settings, opts ...Option) *Client {
+	c := &Client{API: &api.Client{}, settings: settings}
+	for _, opt := range opts {
+		opt(c.API)
+	}
+	return c
+}
+
+// Settings returns the settings used for product, e.g. "iron_mq".
+func (c *Client) Settings(product string) config.Settings {
+	return config.ManualConfig(product, c.settings)
+}
+
+// Queue returns the queue called name.
+func (c *Client) Queue(name string) mq.Queue {
+	return mq.Queue{Settings: c.Settings("iron_mq"), Name: name, Client: c.API}
+}
+
+// Worker returns an IronWorker client.
+func (c *Client) Worker() *worker.Worker {
+	return &worker.Worker{Settings: c.Settings("iron_worker"), Client: c.API}
+}
+
+// Cache returns the cache called name.
+func (c *Client) Cache(name string) *cache.Cache {
+	return &cache.Cache{Settings: c.Settings("iron_cache"), Name: name, Client: c.API}
+}
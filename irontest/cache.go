@@ -0,0 +1,202 @@
+package irontest
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Cache is the IronCache fake.
+type Cache struct {
+	mu     sync.Mutex
+	caches map[string]map[string]*cacheItem
+	cas    uint64
+}
+
+type cacheItem struct {
+	value   interface{}
+	cas     uint64
+	created time.Time
+	expires time.Time
+}
+
+func newCache() *Cache {
+	return &Cache{caches: map[string]map[string]*cacheItem{}}
+}
+
+// Get returns the value stored under key in the named cache, false if
+// there is none or it expired.
+func (c *Cache) Get(cache, key string) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	item := c.item(cache, key)
+	if item == nil {
+		return nil, false
+	}
+	return item.value, true
+}
+
+// Keys returns the sorted keys of the unexpired items in the named cache.
+func (c *Cache) Keys(cache string) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var keys []string
+	for key := range c.caches[cache] {
+		if c.item(cache, key) != nil {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// item returns the item under key, dropping it if it expired.
+func (c *Cache) item(cache, key string) *cacheItem {
+	item, ok := c.caches[cache][key]
+	if !ok {
+		return nil
+	}
+	if time.Now().After(item.expires) {
+		delete(c.caches[cache], key)
+		return nil
+	}
+	return item
+}
+
+func (c *Cache) serve(w http.ResponseWriter, r *http.Request, parts []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if parts[0] != "caches" {
+		fail(w, http.StatusNotFound, "Not found")
+		return
+	}
+	parts = parts[1:]
+	switch {
+	case len(parts) == 0 && r.Method == "GET":
+		c.list(w, r)
+	case len(parts) == 1 && r.Method == "GET":
+		items, ok := c.caches[parts[0]]
+		if !ok {
+			fail(w, http.StatusNotFound, "Cache not found")
+			return
+		}
+		reply(w, map[string]interface{}{"project_id": ProjectId, "name": parts[0], "size": len(items)})
+	case len(parts) == 1 && r.Method == "DELETE":
+		delete(c.caches, parts[0])
+		reply(w, map[string]string{"msg": "Deleted."})
+	case len(parts) == 2 && parts[1] == "clear" && r.Method == "POST":
+		if _, ok := c.caches[parts[0]]; ok {
+			c.caches[parts[0]] = map[string]*cacheItem{}
+		}
+		reply(w, map[string]string{"msg": "Deleted."})
+	case len(parts) == 3 && parts[1] == "items":
+		c.serveItem(w, r, parts[0], parts[2])
+	case len(parts) == 4 && parts[1] == "items" && parts[3] == "increment" && r.Method == "POST":
+		c.increment(w, r, parts[0], parts[2])
+	default:
+		fail(w, http.StatusNotFound, "Not found")
+	}
+}
+
+func (c *Cache) list(w http.ResponseWriter, r *http.Request) {
+	names := make([]string, 0, len(c.caches))
+	for name := range c.caches {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	out := []map[string]string{}
+	for _, name := range page(r, names) {
+		out = append(out, map[string]string{"project_id": ProjectId, "name": name})
+	}
+	reply(w, out)
+}
+
+func (c *Cache) serveItem(w http.ResponseWriter, r *http.Request, cache, key string) {
+	item := c.item(cache, key)
+
+	switch r.Method {
+	case "GET":
+		if item == nil {
+			fail(w, http.StatusNotFound, "Key not found.")
+			return
+		}
+		reply(w, map[string]interface{}{
+			"cache": cache, "key": key, "value": item.value,
+			"cas": item.cas, "created": item.created, "expires": item.expires,
+		})
+
+	case "PUT":
+		var in struct {
+			Value     interface{} `json:"value"`
+			ExpiresIn int         `json:"expires_in"`
+			Add       bool        `json:"add"`
+			Replace   bool        `json:"replace"`
+			Cas       uint64      `json:"cas"`
+		}
+		if !decode(w, r, &in) {
+			return
+		}
+		switch {
+		case in.Add && item != nil:
+			fail(w, http.StatusConflict, "Key already exists.")
+			return
+		case (in.Replace || in.Cas != 0) && item == nil:
+			fail(w, http.StatusNotFound, "Key not found.")
+			return
+		case in.Cas != 0 && in.Cas != item.cas:
+			fail(w, http.StatusConflict, "CAS mismatch.")
+			return
+		}
+		if in.ExpiresIn == 0 {
+			in.ExpiresIn = 7 * 24 * 3600
+		}
+		if c.caches[cache] == nil {
+			c.caches[cache] = map[string]*cacheItem{}
+		}
+		c.cas++
+		now := time.Now()
+		c.caches[cache][key] = &cacheItem{
+			value:   in.Value,
+			cas:     c.cas,
+			created: now,
+			expires: now.Add(time.Duration(in.ExpiresIn) * time.Second),
+		}
+		reply(w, map[string]string{"msg": "Stored."})
+
+	case "DELETE":
+		if item == nil {
+			fail(w, http.StatusNotFound, "Key not found.")
+			return
+		}
+		delete(c.caches[cache], key)
+		reply(w, map[string]string{"msg": "Deleted."})
+
+	default:
+		fail(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+func (c *Cache) increment(w http.ResponseWriter, r *http.Request, cache, key string) {
+	var in struct {
+		Amount int64 `json:"amount"`
+	}
+	if !decode(w, r, &in) {
+		return
+	}
+	item := c.item(cache, key)
+	if item == nil {
+		fail(w, http.StatusNotFound, "Key not found.")
+		return
+	}
+	n, ok := item.value.(float64)
+	if !ok {
+		fail(w, http.StatusBadRequest, "Cannot increment or decrement non-numeric value")
+		return
+	}
+	c.cas++
+	item.value, item.cas = n+float64(in.Amount), c.cas
+	reply(w, map[string]interface{}{"msg": "Added", "value": item.value})
+}
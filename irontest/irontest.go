@@ -0,0 +1,147 @@
+// Package irontest provides an in memory fake of the IronMQ, IronWorker and
+// IronCache REST APIs for hermetic tests.
+//
+//	srv := irontest.NewServer()
+//	defer srv.Close()
+//	q := mq.Queue{Settings: srv.Settings("iron_mq"), Name: "jobs"}
+//
+// The fakes follow the wire format the client packages speak, including
+// message reservations, task status transitions and cache cas tokens, but
+// only keep state in memory and serve a single project.
+package irontest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/iron-io/iron_go3/config"
+)
+
+const (
+	// Token is the OAuth token the fake accepts.
+	Token = "irontest-token"
+	// ProjectId is the project the fake serves.
+	ProjectId = "irontest-project"
+)
+
+// A Server fakes the three APIs on one listener, telling them apart by
+// their API version: 3 for IronMQ, 2 for IronWorker and 1 for IronCache.
+type Server struct {
+	*httptest.Server
+
+	MQ     *MQ
+	Worker *Worker
+	Cache  *Cache
+
+	requests int64
+}
+
+// NewServer starts a Server, it must be closed with Close.
+func NewServer() *Server {
+	s := &Server{MQ: newMQ(), Worker: newWorker(), Cache: newCache()}
+	s.Server = httptest.NewServer(s)
+	return s
+}
+
+// Settings returns settings pointing product ("iron_mq", "iron_worker" or
+// "iron_cache") at the server.
+func (s *Server) Settings(product string) config.Settings {
+	host, port, _ := net.SplitHostPort(strings.TrimPrefix(s.URL, "http://"))
+	p, _ := strconv.Atoi(port)
+	settings := config.Settings{
+		Token:     Token,
+		ProjectId: ProjectId,
+		Host:      host,
+		Port:      uint16(p),
+		Scheme:    "http",
+		UserAgent: "irontest",
+	}
+	switch product {
+	case "iron_mq", "mq":
+		settings.ApiVersion = "3"
+	case "iron_worker", "worker":
+		settings.ApiVersion = "2"
+	case "iron_cache", "cache":
+		settings.ApiVersion = "1"
+	default:
+		panic("irontest: unknown product " + product)
+	}
+	return settings
+}
+
+// Requests returns the number of requests served so far.
+func (s *Server) Requests() int {
+	return int(atomic.LoadInt64(&s.requests))
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	atomic.AddInt64(&s.requests, 1)
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.URL.Path == "/version" {
+		reply(w, map[string]string{"version": "irontest"})
+		return
+	}
+	// like the API, take the token from the oauth parameter too, as in
+	// webhook URLs
+	if r.Header.Get("Authorization") != "OAuth "+Token && r.URL.Query().Get("oauth") != Token {
+		fail(w, http.StatusUnauthorized, "Invalid token")
+		return
+	}
+
+	// /{version}/projects/{project}/{resource...}
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) < 2 {
+		fail(w, http.StatusNotFound, "Not found")
+		return
+	}
+	version, parts := parts[0], parts[1:]
+	if parts[0] == "projects" {
+		if len(parts) < 3 || parts[1] != ProjectId {
+			fail(w, http.StatusNotFound, "Project not found")
+			return
+		}
+		parts = parts[2:]
+	}
+
+	switch version {
+	case "3":
+		s.MQ.serve(w, r, parts)
+	case "2":
+		s.Worker.serve(w, r, parts)
+	case "1":
+		s.Cache.serve(w, r, parts)
+	default:
+		fail(w, http.StatusNotFound, "Not found")
+	}
+}
+
+func reply(w http.ResponseWriter, v interface{}) {
+	json.NewEncoder(w).Encode(v)
+}
+
+func fail(w http.ResponseWriter, code int, format string, v ...interface{}) {
+	w.WriteHeader(code)
+	reply(w, map[string]string{"msg": fmt.Sprintf(format, v...)})
+}
+
+func decode(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil && err.Error() != "EOF" {
+		fail(w, http.StatusBadRequest, "Invalid JSON: %v", err)
+		return false
+	}
+	return true
+}
+
+func queryInt(r *http.Request, key string, def int) int {
+	if n, err := strconv.Atoi(r.URL.Query().Get(key)); err == nil {
+		return n
+	}
+	return def
+}
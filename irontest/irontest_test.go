@@ -0,0 +1,236 @@
+package irontest_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/iron-io/iron_go3/api"
+	"github.com/iron-io/iron_go3/cache"
+	"github.com/iron-io/iron_go3/irontest"
+	"github.com/iron-io/iron_go3/mq"
+	"github.com/iron-io/iron_go3/worker"
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestServer(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("the server", func() {
+		srv := irontest.NewServer()
+		defer srv.Close()
+
+		It("rejects unknown tokens", func() {
+			s := srv.Settings("iron_mq")
+			s.Token = "wrong"
+			_, err := mq.Queue{Settings: s, Name: "q"}.Info()
+			Expect(api.StatusCode(err), ToEqual, http.StatusUnauthorized)
+		})
+
+		It("rejects other projects", func() {
+			s := srv.Settings("iron_cache")
+			s.ProjectId = "other"
+			_, err := (&cache.Cache{Settings: s, Name: "c"}).Get("k")
+			Expect(api.StatusCode(err), ToEqual, http.StatusNotFound)
+		})
+
+		It("counts requests", func() {
+			n := srv.Requests()
+			mq.Queue{Settings: srv.Settings("iron_mq"), Name: "q"}.Info()
+			Expect(srv.Requests(), ToEqual, n+1)
+		})
+	})
+}
+
+func TestMQ(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("the mq fake", func() {
+		srv := irontest.NewServer()
+		defer srv.Close()
+		q := mq.Queue{Settings: srv.Settings("iron_mq"), Name: "jobs"}
+
+		It("creates queues on push", func() {
+			_, err := q.PushStrings("a", "b", "c")
+			Expect(err, ToBeNil)
+			info, err := q.Info()
+			Expect(err, ToBeNil)
+			Expect(info.Size, ToEqual, 3)
+			Expect(srv.MQ.Messages("jobs"), ToDeepEqual, []string{"a", "b", "c"})
+		})
+
+		It("hides reserved messages until they are released", func() {
+			msg, err := q.Reserve()
+			Expect(err, ToBeNil)
+			Expect(msg.Body, ToEqual, "a")
+			Expect(msg.ReservationId == "", ToEqual, false)
+
+			msgs, err := q.ReserveN(10)
+			Expect(err, ToBeNil)
+			Expect(len(msgs), ToEqual, 2)
+
+			msgs, err = q.ReserveN(10)
+			Expect(err, ToBeNil)
+			Expect(len(msgs), ToEqual, 0)
+
+			Expect(msg.Release(0), ToBeNil)
+			again, err := q.Reserve()
+			Expect(err, ToBeNil)
+			Expect(again.Id, ToEqual, msg.Id)
+			Expect(again.ReservedCount, ToEqual, 2)
+		})
+
+		It("requires the reservation to delete a reserved message", func() {
+			q.Clear()
+			q.PushString("d")
+			msg, _ := q.Reserve()
+			Expect(q.DeleteMessage(msg.Id, "bogus") == nil, ToEqual, false)
+			Expect(msg.Delete(), ToBeNil)
+			Expect(len(srv.MQ.Messages("jobs")), ToEqual, 0)
+		})
+
+		It("makes timed out reservations visible again", func() {
+			q.PushString("e")
+			msgs, err := q.ReserveWith(mq.ReserveOptions{Timeout: time.Second})
+			Expect(err, ToBeNil)
+			Expect(len(msgs), ToEqual, 1)
+			time.Sleep(1100 * time.Millisecond)
+			msgs, err = q.PopWith(mq.PopOptions{})
+			Expect(err, ToBeNil)
+			Expect(len(msgs), ToEqual, 1)
+			Expect(msgs[0].Body, ToEqual, "e")
+		})
+
+		It("returns ErrQueueNotFound for unknown queues", func() {
+			_, err := mq.Queue{Settings: q.Settings, Name: "nope"}.Info()
+			Expect(mq.ErrQueueNotFound(err), ToBeTrue)
+		})
+	})
+}
+
+func TestWorker(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("the worker fake", func() {
+		srv := irontest.NewServer()
+		defer srv.Close()
+		w := &worker.Worker{Settings: srv.Settings("iron_worker")}
+
+		It("uploads code revisions", func() {
+			_, err := w.CodePackageUpload(worker.Code{Name: "hello", Image: "iron/hello"})
+			Expect(err, ToBeNil)
+			_, err = w.CodePackageUpload(worker.Code{Name: "hello", Image: "iron/hello:2"})
+			Expect(err, ToBeNil)
+			code, ok := srv.Worker.Code("hello")
+			Expect(ok, ToBeTrue)
+			Expect(code.Rev, ToEqual, 2)
+			Expect(code.Image, ToEqual, "iron/hello:2")
+		})
+
+		It("moves tasks through their statuses", func() {
+			ids, err := w.TaskQueue(worker.Task{CodeName: "hello", Payload: "{}"})
+			Expect(err, ToBeNil)
+			info, err := w.TaskInfo(ids[0])
+			Expect(err, ToBeNil)
+			Expect(info.Status, ToEqual, worker.TaskStatus(irontest.StatusQueued))
+
+			_, err = w.TaskLog(ids[0])
+			Expect(api.StatusCode(err), ToEqual, http.StatusNotFound)
+
+			Expect(srv.Worker.Start(ids[0]), ToBeNil)
+			Expect(srv.Worker.Finish(ids[0], irontest.StatusComplete, "", "hi"), ToBeNil)
+			info, _ = w.TaskInfo(ids[0])
+			Expect(info.Status, ToEqual, worker.TaskStatus(irontest.StatusComplete))
+			log, err := w.TaskLog(ids[0])
+			Expect(err, ToBeNil)
+			Expect(string(log), ToEqual, "hi")
+		})
+
+		It("runs handlers", func() {
+			srv.Worker.Handle("fails", func(payload string) (string, error) {
+				return "boom\n", errors.New("failed on " + payload)
+			})
+			ids, err := w.TaskQueue(worker.Task{CodeName: "fails", Payload: "x"})
+			Expect(err, ToBeNil)
+			var task irontest.Task
+			for i := 0; i < 100; i++ {
+				if task, _ = srv.Worker.Task(ids[0]); task.Status == irontest.StatusError {
+					break
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+			Expect(task.Status, ToEqual, irontest.StatusError)
+			Expect(task.Msg, ToEqual, "failed on x")
+		})
+
+		It("cancels tasks", func() {
+			ids, _ := w.TaskQueue(worker.Task{CodeName: "hello"})
+			Expect(w.TaskCancel(ids[0]), ToBeNil)
+			task, _ := srv.Worker.Task(ids[0])
+			Expect(task.Status, ToEqual, irontest.StatusCancelled)
+			Expect(w.TaskCancel(ids[0]) == nil, ToEqual, false)
+		})
+
+		It("filters task lists", func() {
+			tasks, err := w.FilteredTaskList(worker.TaskListParams{
+				CodeName: "hello",
+				Statuses: []worker.TaskStatus{worker.TaskStatus(irontest.StatusCancelled)},
+			})
+			Expect(err, ToBeNil)
+			Expect(len(tasks), ToEqual, 1)
+		})
+
+		It("keeps schedules", func() {
+			every := 60
+			ids, err := w.Schedule(worker.Schedule{CodeName: "hello", RunEvery: &every})
+			Expect(err, ToBeNil)
+			Expect(w.ScheduleCancel(ids[0]), ToBeNil)
+			info, _ := w.ScheduleInfo(ids[0])
+			Expect(info.Status, ToEqual, "cancelled")
+		})
+	})
+}
+
+func TestCache(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("the cache fake", func() {
+		srv := irontest.NewServer()
+		defer srv.Close()
+		c := &cache.Cache{Settings: srv.Settings("iron_cache"), Name: "users"}
+
+		It("stores items", func() {
+			Expect(c.Set("alice", "admin"), ToBeNil)
+			v, err := c.Get("alice")
+			Expect(err, ToBeNil)
+			Expect(v, ToEqual, "admin")
+			Expect(srv.Cache.Keys("users"), ToDeepEqual, []string{"alice"})
+		})
+
+		It("honors conditional puts", func() {
+			Expect(c.Add("alice", "x"), ToEqual, cache.ErrKeyExists)
+			Expect(c.Replace("bob", "x"), ToEqual, cache.ErrKeyNotFound)
+			_, cas, err := c.Gets("alice")
+			Expect(err, ToBeNil)
+			Expect(c.CompareAndSwap("alice", &cache.Item{Value: "user", Cas: cas}), ToBeNil)
+			Expect(c.CompareAndSwap("alice", &cache.Item{Value: "user", Cas: cas}), ToEqual, cache.ErrCasMismatch)
+		})
+
+		It("increments numbers", func() {
+			c.Set("n", 1)
+			v, err := c.Increment("n", 2)
+			Expect(err, ToBeNil)
+			Expect(v, ToEqual, 3.0)
+		})
+
+		It("lists and destroys caches", func() {
+			caches, err := cache.ListAll(c.Settings)
+			Expect(err, ToBeNil)
+			Expect(caches, ToDeepEqual, []cache.CacheSummary{{ProjectId: irontest.ProjectId, Name: "users"}})
+			Expect(c.Destroy(), ToBeNil)
+			_, ok := srv.Cache.Get("users", "alice")
+			Expect(ok, ToEqual, false)
+		})
+	})
+}
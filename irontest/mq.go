@@ -0,0 +1,624 @@
+package irontest
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MQ is the IronMQ fake. Reserved messages are invisible until they are
+// deleted, released, or their reservation times out.
+type MQ struct {
+	mu     sync.Mutex
+	queues map[string]*fakeQueue
+	nextId int64
+}
+
+type fakeQueue struct {
+	info     QueueInfo
+	messages []*fakeMessage
+}
+
+// QueueInfo is the fake's view of a queue.
+type QueueInfo struct {
+	Name              string                   `json:"name"`
+	ProjectId         string                   `json:"project_id"`
+	Size              int                      `json:"size"`
+	TotalMessages     int                      `json:"total_messages"`
+	MessageExpiration int                      `json:"message_expiration"`
+	MessageTimeout    int                      `json:"message_timeout"`
+	Type              string                   `json:"type,omitempty"`
+	Push              *PushInfo                `json:"push,omitempty"`
+	Alerts            []map[string]interface{} `json:"alerts,omitempty"`
+}
+
+// PushInfo is the push configuration of a queue.
+type PushInfo struct {
+	RetriesDelay int                      `json:"retries_delay,omitempty"`
+	Retries      int                      `json:"retries,omitempty"`
+	Subscribers  []map[string]interface{} `json:"subscribers,omitempty"`
+	ErrorQueue   string                   `json:"error_queue,omitempty"`
+}
+
+type fakeMessage struct {
+	Id            string    `json:"id"`
+	Body          string    `json:"body"`
+	ReservedCount int       `json:"reserved_count,omitempty"`
+	ReservationId string    `json:"reservation_id,omitempty"`
+	ReservedUntil time.Time `json:"reserved_until,omitempty"`
+
+	availableAt time.Time
+	pushes      []*pushStatus
+}
+
+// pushStatus is the delivery of a message of a push queue to a subscriber.
+type pushStatus struct {
+	Name       string `json:"name"`
+	URL        string `json:"url"`
+	Tries      int    `json:"tries"`
+	StatusCode int    `json:"status_code,omitempty"`
+	Status     string `json:"status"`
+}
+
+func newMQ() *MQ {
+	return &MQ{queues: map[string]*fakeQueue{}}
+}
+
+// Messages returns the bodies of the messages on queue, reserved or not,
+// in the order they were pushed.
+func (m *MQ) Messages(queue string) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var bodies []string
+	if q, ok := m.queues[queue]; ok {
+		for _, msg := range q.messages {
+			bodies = append(bodies, msg.Body)
+		}
+	}
+	return bodies
+}
+
+// Info returns the fake's view of queue, false if it doesn't exist.
+func (m *MQ) Info(queue string) (QueueInfo, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	q, ok := m.queues[queue]
+	if !ok {
+		return QueueInfo{}, false
+	}
+	return q.snapshot(), true
+}
+
+func (q *fakeQueue) snapshot() QueueInfo {
+	info := q.info
+	info.Size = len(q.messages)
+	return info
+}
+
+func (m *MQ) queue(name string, create bool) *fakeQueue {
+	q, ok := m.queues[name]
+	if !ok && create {
+		q = &fakeQueue{info: QueueInfo{Name: name, ProjectId: ProjectId, Type: "pull", MessageTimeout: 60, MessageExpiration: 604800}}
+		m.queues[name] = q
+	}
+	return q
+}
+
+func (m *MQ) id() string {
+	m.nextId++
+	return fmt.Sprintf("%019d", m.nextId)
+}
+
+func (m *MQ) serve(w http.ResponseWriter, r *http.Request, parts []string) {
+	if parts[0] != "queues" {
+		fail(w, http.StatusNotFound, "Not found")
+		return
+	}
+	if len(parts) == 1 {
+		if r.Method != "GET" {
+			fail(w, http.StatusMethodNotAllowed, "Method not allowed")
+			return
+		}
+		m.listQueues(w, r)
+		return
+	}
+	name := parts[1]
+
+	// long polls wait without holding the lock
+	if len(parts) == 3 && parts[2] == "reservations" && r.Method == "POST" {
+		m.reserve(w, r, name)
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	switch {
+	case len(parts) == 2:
+		m.serveQueue(w, r, name)
+	case parts[2] == "messages":
+		q := m.queue(name, r.Method == "POST" && len(parts) == 3)
+		if q == nil {
+			fail(w, http.StatusNotFound, "Queue not found")
+			return
+		}
+		m.serveMessages(w, r, q, parts[3:])
+	case parts[2] == "subscribers" && len(parts) == 3:
+		m.serveSubscribers(w, r, name)
+	default:
+		fail(w, http.StatusNotFound, "Not found")
+	}
+}
+
+func (m *MQ) listQueues(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	prefix, prev := r.URL.Query().Get("prefix"), r.URL.Query().Get("previous")
+	perPage := queryInt(r, "per_page", 30)
+	var names []string
+	for name := range m.queues {
+		if strings.HasPrefix(name, prefix) && name > prev {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	if len(names) > perPage {
+		names = names[:perPage]
+	}
+	queues := make([]map[string]string, len(names))
+	for i, name := range names {
+		queues[i] = map[string]string{"name": name, "project_id": ProjectId}
+	}
+	reply(w, map[string]interface{}{"queues": queues})
+}
+
+func (m *MQ) serveQueue(w http.ResponseWriter, r *http.Request, name string) {
+	var in struct {
+		Queue QueueInfo `json:"queue"`
+	}
+	switch r.Method {
+	case "GET":
+		q := m.queue(name, false)
+		if q == nil {
+			fail(w, http.StatusNotFound, "Queue not found")
+			return
+		}
+		reply(w, map[string]interface{}{"queue": q.snapshot()})
+	case "PUT", "PATCH":
+		if !decode(w, r, &in) {
+			return
+		}
+		q := m.queue(name, r.Method == "PUT")
+		if q == nil {
+			fail(w, http.StatusNotFound, "Queue not found")
+			return
+		}
+		if in.Queue.Type != "" && in.Queue.Type != q.info.Type && len(q.messages)+q.info.TotalMessages > 0 {
+			fail(w, http.StatusBadRequest, "Queue type cannot be changed")
+			return
+		}
+		q.update(in.Queue)
+		reply(w, map[string]interface{}{"queue": q.snapshot()})
+	case "DELETE":
+		if m.queue(name, false) == nil {
+			fail(w, http.StatusNotFound, "Queue not found")
+			return
+		}
+		delete(m.queues, name)
+		reply(w, map[string]string{"msg": "Deleted"})
+	default:
+		fail(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+func (q *fakeQueue) update(in QueueInfo) {
+	if in.Type != "" {
+		q.info.Type = in.Type
+	}
+	if in.MessageTimeout > 0 {
+		q.info.MessageTimeout = in.MessageTimeout
+	}
+	if in.MessageExpiration > 0 {
+		q.info.MessageExpiration = in.MessageExpiration
+	}
+	if in.Push != nil {
+		if q.info.Push == nil {
+			q.info.Push = &PushInfo{}
+		}
+		if in.Push.Retries > 0 {
+			q.info.Push.Retries = in.Push.Retries
+		}
+		if in.Push.RetriesDelay > 0 {
+			q.info.Push.RetriesDelay = in.Push.RetriesDelay
+		}
+		if in.Push.ErrorQueue != "" {
+			q.info.Push.ErrorQueue = in.Push.ErrorQueue
+		}
+		if in.Push.Subscribers != nil {
+			q.info.Push.Subscribers = in.Push.Subscribers
+		}
+	}
+	if in.Alerts != nil {
+		q.info.Alerts = in.Alerts
+	}
+}
+
+func (m *MQ) serveMessages(w http.ResponseWriter, r *http.Request, q *fakeQueue, parts []string) {
+	now := time.Now()
+	switch {
+	case len(parts) == 0 && r.Method == "POST":
+		var in struct {
+			Messages []struct {
+				Body  string `json:"body"`
+				Delay int64  `json:"delay"`
+			} `json:"messages"`
+		}
+		if !decode(w, r, &in) {
+			return
+		}
+		if len(in.Messages) == 0 {
+			fail(w, http.StatusBadRequest, "No messages")
+			return
+		}
+		ids := make([]string, len(in.Messages))
+		for i, msg := range in.Messages {
+			ids[i] = m.id()
+			fm := &fakeMessage{
+				Id:          ids[i],
+				Body:        msg.Body,
+				availableAt: now.Add(time.Duration(msg.Delay) * time.Second),
+			}
+			q.messages = append(q.messages, fm)
+			m.push(q, fm)
+		}
+		q.info.TotalMessages += len(ids)
+		reply(w, map[string]interface{}{"ids": ids, "msg": "Messages put on queue."})
+
+	case len(parts) == 0 && r.Method == "GET":
+		n := queryInt(r, "n", 1)
+		var peeked []fakeMessage
+		for _, msg := range q.messages {
+			if len(peeked) < n && msg.available(now) {
+				peeked = append(peeked, fakeMessage{Id: msg.Id, Body: msg.Body, ReservedCount: msg.ReservedCount})
+			}
+		}
+		reply(w, map[string]interface{}{"messages": peeked})
+
+	case len(parts) == 0 && r.Method == "DELETE":
+		var in struct {
+			Ids []struct {
+				Id            string `json:"id"`
+				ReservationId string `json:"reservation_id"`
+			} `json:"ids"`
+		}
+		if !decode(w, r, &in) {
+			return
+		}
+		if len(in.Ids) == 0 {
+			q.messages = nil
+			reply(w, map[string]string{"msg": "Cleared"})
+			return
+		}
+		for _, id := range in.Ids {
+			if code, msg := q.checkReservation(id.Id, id.ReservationId, now); code != 0 {
+				fail(w, code, "%s", msg)
+				return
+			}
+		}
+		for _, id := range in.Ids {
+			q.remove(id.Id)
+		}
+		reply(w, map[string]string{"msg": "Deleted"})
+
+	case len(parts) == 1 && r.Method == "GET":
+		if msg := q.find(parts[0]); msg != nil {
+			reply(w, map[string]interface{}{"message": msg})
+		} else {
+			fail(w, http.StatusNotFound, "Message not found")
+		}
+
+	case len(parts) == 1 && r.Method == "DELETE":
+		var in struct {
+			ReservationId string `json:"reservation_id"`
+		}
+		if !decode(w, r, &in) {
+			return
+		}
+		if code, msg := q.checkReservation(parts[0], in.ReservationId, now); code != 0 {
+			fail(w, code, "%s", msg)
+			return
+		}
+		q.remove(parts[0])
+		reply(w, map[string]string{"msg": "Deleted"})
+
+	case len(parts) == 2 && r.Method == "POST" && parts[1] == "touch":
+		var in struct {
+			ReservationId string `json:"reservation_id"`
+			Timeout       int    `json:"timeout"`
+		}
+		if !decode(w, r, &in) {
+			return
+		}
+		if in.ReservationId == "" {
+			fail(w, http.StatusForbidden, "Message not reserved")
+			return
+		}
+		if code, msg := q.checkReservation(parts[0], in.ReservationId, now); code != 0 {
+			fail(w, code, "%s", msg)
+			return
+		}
+		msg := q.find(parts[0])
+		if in.Timeout <= 0 {
+			in.Timeout = q.info.MessageTimeout
+		}
+		msg.ReservationId = m.id()
+		msg.ReservedUntil = now.Add(time.Duration(in.Timeout) * time.Second)
+		reply(w, map[string]string{"reservation_id": msg.ReservationId, "msg": "Touched"})
+
+	case len(parts) == 2 && r.Method == "POST" && parts[1] == "release":
+		var in struct {
+			ReservationId string `json:"reservation_id"`
+			Delay         int64  `json:"delay"`
+		}
+		if !decode(w, r, &in) {
+			return
+		}
+		if in.ReservationId == "" {
+			fail(w, http.StatusForbidden, "Message not reserved")
+			return
+		}
+		if code, msg := q.checkReservation(parts[0], in.ReservationId, now); code != 0 {
+			fail(w, code, "%s", msg)
+			return
+		}
+		msg := q.find(parts[0])
+		msg.ReservationId, msg.ReservedUntil = "", time.Time{}
+		msg.availableAt = now.Add(time.Duration(in.Delay) * time.Second)
+		reply(w, map[string]string{"msg": "Released"})
+
+	case len(parts) == 2 && r.Method == "GET" && parts[1] == "subscribers":
+		msg := q.find(parts[0])
+		if msg == nil {
+			fail(w, http.StatusNotFound, "Message not found")
+			return
+		}
+		subs := make([]pushStatus, len(msg.pushes))
+		for i, p := range msg.pushes {
+			subs[i] = *p
+		}
+		reply(w, map[string]interface{}{"subscribers": subs})
+
+	default:
+		fail(w, http.StatusNotFound, "Not found")
+	}
+}
+
+func (msg *fakeMessage) available(now time.Time) bool {
+	return !now.Before(msg.availableAt) && !now.Before(msg.ReservedUntil)
+}
+
+func (q *fakeQueue) find(id string) *fakeMessage {
+	for _, msg := range q.messages {
+		if msg.Id == id {
+			return msg
+		}
+	}
+	return nil
+}
+
+func (q *fakeQueue) remove(id string) {
+	for i, msg := range q.messages {
+		if msg.Id == id {
+			q.messages = append(q.messages[:i], q.messages[i+1:]...)
+			return
+		}
+	}
+}
+
+// checkReservation returns the error status and message for acting on
+// message id with reservationId, 0 if it is allowed.
+func (q *fakeQueue) checkReservation(id, reservationId string, now time.Time) (int, string) {
+	msg := q.find(id)
+	if msg == nil {
+		return http.StatusNotFound, "Message not found"
+	}
+	reserved := now.Before(msg.ReservedUntil)
+	switch {
+	case reservationId == "" && reserved:
+		return http.StatusForbidden, "Message is reserved"
+	case reservationId == "":
+		return 0, ""
+	case msg.ReservationId != reservationId:
+		return http.StatusForbidden, "Reservation does not match"
+	case !reserved:
+		return http.StatusForbidden, "Reservation has timed out"
+	}
+	return 0, ""
+}
+
+func (m *MQ) reserve(w http.ResponseWriter, r *http.Request, name string) {
+	var in struct {
+		N       int  `json:"n"`
+		Timeout int  `json:"timeout"`
+		Wait    int  `json:"wait"`
+		Delete  bool `json:"delete"`
+	}
+	if !decode(w, r, &in) {
+		return
+	}
+	if in.N <= 0 {
+		in.N = 1
+	}
+	if in.N > 100 {
+		fail(w, http.StatusBadRequest, "n must be at most 100")
+		return
+	}
+	if in.Wait > 30 {
+		in.Wait = 30
+	}
+
+	deadline := time.Now().Add(time.Duration(in.Wait) * time.Second)
+	for {
+		m.mu.Lock()
+		q := m.queue(name, false)
+		if q == nil {
+			m.mu.Unlock()
+			fail(w, http.StatusNotFound, "Queue not found")
+			return
+		}
+		reserved := m.reserveLocked(q, in.N, in.Timeout, in.Delete)
+		m.mu.Unlock()
+
+		if len(reserved) > 0 || !time.Now().Before(deadline) {
+			reply(w, map[string]interface{}{"messages": reserved})
+			return
+		}
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func (m *MQ) reserveLocked(q *fakeQueue, n, timeout int, del bool) []fakeMessage {
+	now := time.Now()
+	if timeout <= 0 {
+		timeout = q.info.MessageTimeout
+	}
+	var reserved []fakeMessage
+	for _, msg := range q.messages {
+		if len(reserved) == n {
+			break
+		}
+		if !msg.available(now) {
+			continue
+		}
+		msg.ReservedCount++
+		msg.ReservationId = m.id()
+		msg.ReservedUntil = now.Add(time.Duration(timeout) * time.Second)
+		reserved = append(reserved, *msg)
+	}
+	if del {
+		for _, msg := range reserved {
+			q.remove(msg.Id)
+		}
+	}
+	return reserved
+}
+
+func (m *MQ) serveSubscribers(w http.ResponseWriter, r *http.Request, name string) {
+	q := m.queue(name, false)
+	if q == nil {
+		fail(w, http.StatusNotFound, "Queue not found")
+		return
+	}
+	var in struct {
+		Subscribers []map[string]interface{} `json:"subscribers"`
+	}
+	if !decode(w, r, &in) {
+		return
+	}
+	if q.info.Push == nil {
+		q.info.Push = &PushInfo{}
+	}
+	subs := q.info.Push.Subscribers
+	switch r.Method {
+	case "POST":
+		subs = append(subs, in.Subscribers...)
+	case "PUT":
+		subs = in.Subscribers
+	case "DELETE":
+		var kept []map[string]interface{}
+		for _, sub := range subs {
+			removed := false
+			for _, del := range in.Subscribers {
+				removed = removed || sub["name"] == del["name"]
+			}
+			if !removed {
+				kept = append(kept, sub)
+			}
+		}
+		subs = kept
+	default:
+		fail(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	q.info.Push.Subscribers = subs
+	reply(w, map[string]string{"msg": "Updated"})
+}
+
+// push delivers msg to the subscribers of q, if it is a push queue: all of
+// them for a multicast queue, the first for a unicast one. Failed
+// deliveries are retried per the queue's push settings, as long as the
+// queue exists.
+func (m *MQ) push(q *fakeQueue, msg *fakeMessage) {
+	if q.info.Push == nil || q.info.Type != "multicast" && q.info.Type != "unicast" {
+		return
+	}
+	subs := q.info.Push.Subscribers
+	if q.info.Type == "unicast" && len(subs) > 1 {
+		subs = subs[:1]
+	}
+	retries, delay := 3, 60*time.Second
+	if q.info.Push.Retries > 0 {
+		retries = q.info.Push.Retries
+	}
+	if q.info.Push.RetriesDelay > 0 {
+		delay = time.Duration(q.info.Push.RetriesDelay) * time.Second
+	}
+	for _, sub := range subs {
+		name, _ := sub["name"].(string)
+		url, _ := sub["url"].(string)
+		headers, _ := sub["headers"].(map[string]interface{})
+		p := &pushStatus{Name: name, URL: url, Status: "queued"}
+		msg.pushes = append(msg.pushes, p)
+		go m.deliver(q, msg, p, headers, retries, delay)
+	}
+}
+
+func (m *MQ) deliver(q *fakeQueue, msg *fakeMessage, p *pushStatus, headers map[string]interface{}, retries int, delay time.Duration) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	for try := 0; ; try++ {
+		code := 0
+		req, err := http.NewRequest("POST", p.URL, strings.NewReader(msg.Body))
+		if err == nil {
+			for k, v := range headers {
+				req.Header.Set(k, fmt.Sprint(v))
+			}
+			req.Header.Set("Iron-Message-Id", msg.Id)
+			req.Header.Set("Iron-Subscriber-Name", p.Name)
+			if res, err := client.Do(req); err == nil {
+				res.Body.Close()
+				code = res.StatusCode
+			}
+		}
+
+		m.mu.Lock()
+		p.Tries, p.StatusCode = try+1, code
+		switch {
+		case code >= 200 && code < 300:
+			p.Status = "complete"
+		case try >= retries:
+			p.Status = "error"
+		default:
+			p.Status = "retrying"
+		}
+		done := p.Status != "retrying"
+		m.mu.Unlock()
+		if done {
+			return
+		}
+
+		time.Sleep(delay)
+		m.mu.Lock()
+		exists := m.queues[q.info.Name] == q
+		m.mu.Unlock()
+		if !exists {
+			return
+		}
+	}
+}
@@ -0,0 +1,630 @@
+package irontest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Task statuses, as reported by the API.
+const (
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusComplete  = "complete"
+	StatusError     = "error"
+	StatusCancelled = "cancelled"
+	StatusKilled    = "killed"
+	StatusTimeout   = "timeout"
+)
+
+// Worker is the IronWorker fake. Queued tasks stay queued until a test
+// moves them along with Start and Finish, or a handler registered with
+// Handle runs them.
+type Worker struct {
+	mu        sync.Mutex
+	codes     map[string]*Code
+	tasks     map[string]*Task
+	schedules map[string]*Schedule
+	clusters  map[string]*Cluster
+	handlers  map[string]func(payload string) (log string, err error)
+	nextId    int64
+}
+
+// Code is the fake's view of a code package.
+type Code struct {
+	Id              string            `json:"id"`
+	Name            string            `json:"name"`
+	ProjectId       string            `json:"project_id"`
+	Rev             int               `json:"rev"`
+	Image           string            `json:"image,omitempty"`
+	Command         string            `json:"command,omitempty"`
+	Stack           string            `json:"stack,omitempty"`
+	Runtime         string            `json:"runtime,omitempty"`
+	Config          string            `json:"config,omitempty"`
+	MaxConcurrency  int               `json:"max_concurrency,omitempty"`
+	Retries         *int              `json:"retries,omitempty"`
+	RetriesDelay    *int              `json:"retries_delay,omitempty"`
+	DefaultPriority int               `json:"default_priority,omitempty"`
+	DefaultCluster  string            `json:"default_cluster,omitempty"`
+	EnvVars         map[string]string `json:"env_vars,omitempty"`
+	CreatedAt       time.Time         `json:"created_at"`
+	UpdatedAt       time.Time         `json:"updated_at"`
+	LatestChange    time.Time         `json:"latest_change"`
+	// Zip is the uploaded package, if any.
+	Zip []byte `json:"-"`
+	// Paused codes' tasks stay queued, their handlers don't run them.
+	Paused bool `json:"-"`
+}
+
+// Cluster is the fake's view of a cluster. Its tasks are those naming its
+// id or name as their cluster.
+type Cluster struct {
+	Id        string `json:"id"`
+	Name      string `json:"name"`
+	Memory    int64  `json:"memory,omitempty"`
+	DiskSpace int64  `json:"disk_space,omitempty"`
+	CpuShare  *int32 `json:"cpu_share,omitempty"`
+	// Machines is the number of machines the cluster reports, 1 when
+	// created.
+	Machines int `json:"-"`
+}
+
+// Task is the fake's view of a task.
+type Task struct {
+	Id         string    `json:"id"`
+	CodeId     string    `json:"code_id"`
+	CodeName   string    `json:"code_name"`
+	ProjectId  string    `json:"project_id"`
+	Payload    string    `json:"payload"`
+	Priority   int       `json:"priority"`
+	Cluster    string    `json:"cluster,omitempty"`
+	Label      string    `json:"label,omitempty"`
+	Callback   string    `json:"callback,omitempty"`
+	Timeout    int       `json:"timeout"`
+	ScheduleId string    `json:"schedule_id,omitempty"`
+	Status     string    `json:"status"`
+	Msg        string    `json:"msg,omitempty"`
+	Percent    int       `json:"percent,omitempty"`
+	Duration   int       `json:"duration"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	StartTime  time.Time `json:"start_time"`
+	EndTime    time.Time `json:"end_time"`
+	// Log is served once the task finished.
+	Log string `json:"-"`
+	// EnvVars are the task's own env vars, handlers don't get them.
+	EnvVars map[string]string `json:"env_vars,omitempty"`
+}
+
+// Schedule is the fake's view of a schedule.
+type Schedule struct {
+	Id             string     `json:"id"`
+	CodeName       string     `json:"code_name"`
+	Name           string     `json:"name"`
+	ProjectId      string     `json:"project_id"`
+	Payload        string     `json:"payload"`
+	Priority       int        `json:"priority"`
+	Cluster        string     `json:"cluster,omitempty"`
+	Label          string     `json:"label,omitempty"`
+	RunEvery       int        `json:"run_every,omitempty"`
+	RunTimes       int        `json:"run_times,omitempty"`
+	Timeout        int        `json:"timeout,omitempty"`
+	MaxConcurrency int        `json:"max_concurrency,omitempty"`
+	StartAt        *time.Time `json:"start_at,omitempty"`
+	EndAt          *time.Time `json:"end_at,omitempty"`
+	Status         string     `json:"status"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+func newWorker() *Worker {
+	return &Worker{
+		codes:     map[string]*Code{},
+		tasks:     map[string]*Task{},
+		schedules: map[string]*Schedule{},
+		clusters:  map[string]*Cluster{},
+		handlers:  map[string]func(string) (string, error){},
+	}
+}
+
+// Handle runs tasks of codeName with fn as soon as they are queued. Tasks
+// complete with fn's log, or fail with its error as their message.
+func (w *Worker) Handle(codeName string, fn func(payload string) (log string, err error)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.handlers[codeName] = fn
+}
+
+// Task returns a copy of the task, false if there is none.
+func (w *Worker) Task(id string) (Task, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	t, ok := w.tasks[id]
+	if !ok {
+		return Task{}, false
+	}
+	return *t, true
+}
+
+// Tasks returns copies of the tasks queued for codeName, oldest first, or
+// of all tasks if codeName is empty.
+func (w *Worker) Tasks(codeName string) []Task {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.list(codeName, nil)
+}
+
+// Code returns a copy of the code package called name, false if there is
+// none.
+func (w *Worker) Code(name string) (Code, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, c := range w.codes {
+		if c.Name == name {
+			return *c, true
+		}
+	}
+	return Code{}, false
+}
+
+// Start moves a queued task to running.
+func (w *Worker) Start(id string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	t, ok := w.tasks[id]
+	if !ok {
+		return fmt.Errorf("irontest: no task %s", id)
+	}
+	if t.Status != StatusQueued {
+		return fmt.Errorf("irontest: task %s is %s, not queued", id, t.Status)
+	}
+	now := time.Now()
+	t.Status, t.StartTime, t.UpdatedAt = StatusRunning, now, now
+	return nil
+}
+
+// Finish ends a queued or running task with status, e.g. StatusComplete or
+// StatusError, msg and log.
+func (w *Worker) Finish(id, status, msg, log string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	t, ok := w.tasks[id]
+	if !ok {
+		return fmt.Errorf("irontest: no task %s", id)
+	}
+	if t.Status != StatusQueued && t.Status != StatusRunning {
+		return fmt.Errorf("irontest: task %s already finished as %s", id, t.Status)
+	}
+	now := time.Now()
+	if t.StartTime.IsZero() {
+		t.StartTime = now
+	}
+	t.Status, t.Msg, t.Log = status, msg, log
+	t.EndTime, t.UpdatedAt = now, now
+	t.Duration = int(t.EndTime.Sub(t.StartTime) / time.Millisecond)
+	return nil
+}
+
+// SetMachines sets the number of machines the cluster id reports.
+func (w *Worker) SetMachines(id string, machines int) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	c, ok := w.clusters[id]
+	if !ok {
+		return fmt.Errorf("irontest: no cluster %s", id)
+	}
+	c.Machines = machines
+	return nil
+}
+
+func (w *Worker) id() string {
+	w.nextId++
+	return fmt.Sprintf("%024x", w.nextId)
+}
+
+func (w *Worker) list(codeName string, statuses map[string]bool) []Task {
+	var tasks []Task
+	for _, t := range w.tasks {
+		if (codeName == "" || t.CodeName == codeName) && (len(statuses) == 0 || statuses[t.Status]) {
+			tasks = append(tasks, *t)
+		}
+	}
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].Id < tasks[j].Id })
+	return tasks
+}
+
+func (w *Worker) serve(rw http.ResponseWriter, r *http.Request, parts []string) {
+	if parts[0] == "tasks" && len(parts) == 2 && parts[1] == "webhook" {
+		w.webhook(rw, r)
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	switch parts[0] {
+	case "codes":
+		w.serveCodes(rw, r, parts[1:])
+	case "tasks":
+		w.serveTasks(rw, r, parts[1:])
+	case "schedules":
+		w.serveSchedules(rw, r, parts[1:])
+	case "clusters":
+		w.serveClusters(rw, r, parts[1:])
+	default:
+		fail(rw, http.StatusNotFound, "Not found")
+	}
+}
+
+func (w *Worker) serveCodes(rw http.ResponseWriter, r *http.Request, parts []string) {
+	switch {
+	case len(parts) == 0 && r.Method == "GET":
+		codes := make([]*Code, 0, len(w.codes))
+		for _, c := range w.codes {
+			codes = append(codes, c)
+		}
+		sort.Slice(codes, func(i, j int) bool { return codes[i].Name < codes[j].Name })
+		reply(rw, map[string]interface{}{"codes": page(r, codes)})
+
+	case len(parts) == 0 && r.Method == "POST":
+		w.upload(rw, r)
+
+	case len(parts) >= 1:
+		c, ok := w.codes[parts[0]]
+		if !ok {
+			fail(rw, http.StatusNotFound, "Code not found")
+			return
+		}
+		switch {
+		case len(parts) == 1 && r.Method == "GET":
+			reply(rw, c)
+		case len(parts) == 1 && r.Method == "DELETE":
+			delete(w.codes, c.Id)
+			reply(rw, map[string]string{"msg": "Deleted"})
+		case len(parts) == 2 && parts[1] == "stats" && r.Method == "GET":
+			w.codeStats(rw, c)
+		case len(parts) == 2 && parts[1] == "download" && r.Method == "GET":
+			rw.Header().Set("Content-Type", "application/zip")
+			rw.Write(c.Zip)
+		case len(parts) == 2 && parts[1] == "pause_task_queue" && r.Method == "POST":
+			c.Paused = true
+			reply(rw, map[string]string{"msg": "Paused"})
+		case len(parts) == 2 && parts[1] == "resume_task_queue" && r.Method == "POST":
+			c.Paused = false
+			for _, t := range w.tasks {
+				if t.CodeName == c.Name && t.Status == StatusQueued {
+					w.dispatch(t)
+				}
+			}
+			reply(rw, map[string]string{"msg": "Resumed"})
+		default:
+			fail(rw, http.StatusNotFound, "Not found")
+		}
+
+	default:
+		fail(rw, http.StatusNotFound, "Not found")
+	}
+}
+
+// upload creates a code package or a new revision of it from the
+// multipart form the worker package sends.
+func (w *Worker) upload(rw http.ResponseWriter, r *http.Request) {
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		fail(rw, http.StatusBadRequest, "Invalid upload: %v", err)
+		return
+	}
+	var in Code
+	if err := json.Unmarshal([]byte(r.FormValue("data")), &in); err != nil || in.Name == "" {
+		fail(rw, http.StatusBadRequest, "Invalid code data")
+		return
+	}
+	if f, _, err := r.FormFile("file"); err == nil {
+		in.Zip, _ = ioutil.ReadAll(f)
+		f.Close()
+	}
+
+	now := time.Now()
+	var c *Code
+	for _, existing := range w.codes {
+		if existing.Name == in.Name {
+			c = existing
+		}
+	}
+	if c == nil {
+		c = &Code{Id: w.id(), ProjectId: ProjectId, CreatedAt: now}
+		w.codes[c.Id] = c
+	}
+	id, rev, created := c.Id, c.Rev+1, c.CreatedAt
+	*c = in
+	c.Id, c.Rev, c.ProjectId, c.CreatedAt = id, rev, ProjectId, created
+	c.UpdatedAt, c.LatestChange = now, now
+	reply(rw, map[string]interface{}{"id": c.Id, "name": c.Name, "rev": c.Rev, "msg": "Upload successful."})
+}
+
+func (w *Worker) codeStats(rw http.ResponseWriter, c *Code) {
+	counts := map[string]int{}
+	for _, t := range w.tasks {
+		if t.CodeName == c.Name {
+			counts[t.Status]++
+		}
+	}
+	reply(rw, counts)
+}
+
+func (w *Worker) serveTasks(rw http.ResponseWriter, r *http.Request, parts []string) {
+	switch {
+	case len(parts) == 0 && r.Method == "GET":
+		statuses := map[string]bool{}
+		for _, s := range []string{StatusQueued, StatusRunning, StatusComplete, StatusError, StatusCancelled, StatusKilled, StatusTimeout} {
+			if r.URL.Query().Get(s) == "true" {
+				statuses[s] = true
+			}
+		}
+		tasks := w.list(r.URL.Query().Get("code_name"), statuses)
+		if label := r.URL.Query().Get("label"); label != "" {
+			var labelled []Task
+			for _, t := range tasks {
+				if t.Label == label {
+					labelled = append(labelled, t)
+				}
+			}
+			tasks = labelled
+		}
+		reply(rw, map[string]interface{}{"tasks": page(r, tasks)})
+
+	case len(parts) == 0 && r.Method == "POST":
+		var in struct {
+			Tasks []struct {
+				Task
+				// The worker package sends seconds as floats.
+				Timeout float64 `json:"timeout"`
+			} `json:"tasks"`
+		}
+		if !decode(rw, r, &in) {
+			return
+		}
+		ids := make([]map[string]string, len(in.Tasks))
+		for i, t := range in.Tasks {
+			t.Task.Timeout = int(t.Timeout)
+			ids[i] = map[string]string{"id": w.queue(t.Task)}
+		}
+		reply(rw, map[string]interface{}{"tasks": ids, "msg": "Queued up"})
+
+	case len(parts) >= 1:
+		t, ok := w.tasks[parts[0]]
+		if !ok {
+			fail(rw, http.StatusNotFound, "Task not found")
+			return
+		}
+		w.serveTask(rw, r, t, parts[1:])
+
+	default:
+		fail(rw, http.StatusNotFound, "Not found")
+	}
+}
+
+func (w *Worker) serveTask(rw http.ResponseWriter, r *http.Request, t *Task, parts []string) {
+	switch {
+	case len(parts) == 0 && r.Method == "GET":
+		reply(rw, t)
+	case len(parts) == 1 && parts[0] == "log" && r.Method == "GET":
+		if t.Status == StatusQueued || t.Status == StatusRunning {
+			fail(rw, http.StatusNotFound, "Log not available yet")
+			return
+		}
+		rw.Header().Set("Content-Type", "text/plain")
+		rw.Write([]byte(t.Log))
+	case len(parts) == 1 && parts[0] == "cancel" && r.Method == "POST":
+		if t.Status != StatusQueued && t.Status != StatusRunning {
+			fail(rw, http.StatusBadRequest, "Task is already %s", t.Status)
+			return
+		}
+		now := time.Now()
+		t.Status, t.EndTime, t.UpdatedAt = StatusCancelled, now, now
+		reply(rw, map[string]string{"msg": "Cancelled"})
+	case len(parts) == 1 && parts[0] == "progress" && r.Method == "POST":
+		var in struct {
+			Percent int    `json:"percent"`
+			Msg     string `json:"msg"`
+		}
+		if !decode(rw, r, &in) {
+			return
+		}
+		t.Percent, t.Msg, t.UpdatedAt = in.Percent, in.Msg, time.Now()
+		reply(rw, map[string]string{"msg": "Progress set"})
+	default:
+		fail(rw, http.StatusNotFound, "Not found")
+	}
+}
+
+// queue adds t as a new task and runs its handler, if any.
+func (w *Worker) queue(t Task) string {
+	now := time.Now()
+	t.Id, t.ProjectId, t.Status = w.id(), ProjectId, StatusQueued
+	t.CreatedAt, t.UpdatedAt = now, now
+	t.StartTime, t.EndTime, t.Duration, t.Msg, t.Percent = time.Time{}, time.Time{}, 0, "", 0
+	if t.Timeout <= 0 {
+		t.Timeout = 3600
+	}
+	for _, c := range w.codes {
+		if c.Name == t.CodeName {
+			t.CodeId = c.Id
+			if t.Cluster == "" {
+				t.Cluster = c.DefaultCluster
+			}
+		}
+	}
+	w.tasks[t.Id] = &t
+	w.dispatch(&t)
+	return t.Id
+}
+
+// dispatch runs the queued t with its code's handler, if any, unless the
+// code is paused.
+func (w *Worker) dispatch(t *Task) {
+	if c, ok := w.codes[t.CodeId]; ok && c.Paused {
+		return
+	}
+	if fn, ok := w.handlers[t.CodeName]; ok {
+		go w.run(t.Id, t.Payload, fn)
+	}
+}
+
+func (w *Worker) run(id, payload string, fn func(string) (string, error)) {
+	if w.Start(id) != nil {
+		return // cancelled meanwhile
+	}
+	log, err := fn(payload)
+	if err != nil {
+		w.Finish(id, StatusError, err.Error(), log)
+	} else {
+		w.Finish(id, StatusComplete, "", log)
+	}
+}
+
+func (w *Worker) webhook(rw http.ResponseWriter, r *http.Request) {
+	codeName := r.URL.Query().Get("code_name")
+	if r.Method != "POST" || codeName == "" {
+		fail(rw, http.StatusBadRequest, "code_name is required")
+		return
+	}
+	body, _ := ioutil.ReadAll(r.Body)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	id := w.queue(Task{CodeName: codeName, Payload: string(body)})
+	reply(rw, map[string]string{"id": id, "msg": "Queued up"})
+}
+
+func (w *Worker) serveSchedules(rw http.ResponseWriter, r *http.Request, parts []string) {
+	switch {
+	case len(parts) == 0 && r.Method == "GET":
+		schedules := make([]*Schedule, 0, len(w.schedules))
+		for _, s := range w.schedules {
+			schedules = append(schedules, s)
+		}
+		sort.Slice(schedules, func(i, j int) bool { return schedules[i].Id < schedules[j].Id })
+		reply(rw, map[string]interface{}{"schedules": page(r, schedules)})
+
+	case len(parts) == 0 && r.Method == "POST":
+		var in struct {
+			Schedules []Schedule `json:"schedules"`
+		}
+		if !decode(rw, r, &in) {
+			return
+		}
+		ids := make([]map[string]string, len(in.Schedules))
+		now := time.Now()
+		for i, s := range in.Schedules {
+			s.Id, s.ProjectId, s.Status = w.id(), ProjectId, "scheduled"
+			s.CreatedAt, s.UpdatedAt = now, now
+			// like the API, fill in the defaults
+			if s.StartAt == nil {
+				start := now.UTC().Truncate(time.Second)
+				s.StartAt = &start
+			}
+			if s.Timeout == 0 {
+				s.Timeout = 3600
+			}
+			w.schedules[s.Id] = &s
+			ids[i] = map[string]string{"id": s.Id}
+		}
+		reply(rw, map[string]interface{}{"schedules": ids, "msg": "Scheduled"})
+
+	case len(parts) >= 1:
+		s, ok := w.schedules[parts[0]]
+		if !ok {
+			fail(rw, http.StatusNotFound, "Schedule not found")
+			return
+		}
+		switch {
+		case len(parts) == 1 && r.Method == "GET":
+			reply(rw, s)
+		case len(parts) == 2 && parts[1] == "cancel" && r.Method == "POST":
+			s.Status, s.UpdatedAt = "cancelled", time.Now()
+			reply(rw, map[string]string{"msg": "Cancelled"})
+		default:
+			fail(rw, http.StatusNotFound, "Not found")
+		}
+
+	default:
+		fail(rw, http.StatusNotFound, "Not found")
+	}
+}
+
+// page returns the page of items asked for with the page and per_page
+// query parameters, 30 per page by default.
+func page[T any](r *http.Request, items []T) []T {
+	p, perPage := queryInt(r, "page", 0), queryInt(r, "per_page", 30)
+	if perPage < 1 {
+		perPage = 1
+	}
+	start := p * perPage
+	if start >= len(items) {
+		return []T{}
+	}
+	end := start + perPage
+	if end > len(items) {
+		end = len(items)
+	}
+	return items[start:end]
+}
+
+func (w *Worker) serveClusters(rw http.ResponseWriter, r *http.Request, parts []string) {
+	switch {
+	case len(parts) == 0 && r.Method == "GET":
+		clusters := make([]*Cluster, 0, len(w.clusters))
+		for _, c := range w.clusters {
+			clusters = append(clusters, c)
+		}
+		sort.Slice(clusters, func(i, j int) bool { return clusters[i].Id < clusters[j].Id })
+		reply(rw, map[string]interface{}{"clusters": clusters})
+
+	case len(parts) == 0 && r.Method == "POST":
+		var c Cluster
+		if !decode(rw, r, &c) {
+			return
+		}
+		c.Id, c.Machines = w.id(), 1
+		w.clusters[c.Id] = &c
+		reply(rw, map[string]interface{}{"cluster": c})
+
+	case len(parts) >= 1:
+		c, ok := w.clusters[parts[0]]
+		if !ok {
+			fail(rw, http.StatusNotFound, "Cluster not found")
+			return
+		}
+		switch {
+		case len(parts) == 1 && r.Method == "GET":
+			reply(rw, map[string]interface{}{"cluster": c})
+		case len(parts) == 1 && r.Method == "DELETE":
+			delete(w.clusters, c.Id)
+			reply(rw, map[string]string{"msg": "Deleted"})
+		case len(parts) == 2 && parts[1] == "stats" && r.Method == "GET":
+			stats := map[string]int{"machines": c.Machines}
+			for _, t := range w.tasks {
+				if t.Cluster != c.Id && t.Cluster != c.Name {
+					continue
+				}
+				switch t.Status {
+				case StatusRunning:
+					stats["running_tasks"]++
+				case StatusQueued:
+					stats["queued_tasks"]++
+				}
+			}
+			reply(rw, stats)
+		case len(parts) == 2 && parts[1] == "credentials" && r.Method == "GET":
+			reply(rw, map[string]string{"token": Token})
+		default:
+			fail(rw, http.StatusNotFound, "Not found")
+		}
+
+	default:
+		fail(rw, http.StatusNotFound, "Not found")
+	}
+}
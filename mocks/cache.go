@@ -0,0 +1,71 @@
+package mocks
+
+import "github.com/iron-io/iron_go3/cache"
+
+// Cache is a mock cache.API.
+type Cache struct {
+	calls
+	GetFunc            func(key string) (interface{}, error)
+	SetFunc            func(key string, value interface{}, ttl ...int) error
+	DeleteFunc         func(key string) error
+	PutFunc            func(key string, item *cache.Item) error
+	GetItemFunc        func(key string) (cache.Item, error)
+	CompareAndSwapFunc func(key string, item *cache.Item) error
+	IncrementFunc      func(key string, amount int64) (interface{}, error)
+}
+
+func (m *Cache) Get(key string) (interface{}, error) {
+	m.record("Get", key)
+	if m.GetFunc != nil {
+		return m.GetFunc(key)
+	}
+	return nil, nil
+}
+
+func (m *Cache) Set(key string, value interface{}, ttl ...int) error {
+	m.record("Set", key, value, ttl)
+	if m.SetFunc != nil {
+		return m.SetFunc(key, value, ttl...)
+	}
+	return nil
+}
+
+func (m *Cache) Delete(key string) error {
+	m.record("Delete", key)
+	if m.DeleteFunc != nil {
+		return m.DeleteFunc(key)
+	}
+	return nil
+}
+
+func (m *Cache) Put(key string, item *cache.Item) error {
+	m.record("Put", key, item)
+	if m.PutFunc != nil {
+		return m.PutFunc(key, item)
+	}
+	return nil
+}
+
+func (m *Cache) GetItem(key string) (cache.Item, error) {
+	m.record("GetItem", key)
+	if m.GetItemFunc != nil {
+		return m.GetItemFunc(key)
+	}
+	return cache.Item{}, nil
+}
+
+func (m *Cache) CompareAndSwap(key string, item *cache.Item) error {
+	m.record("CompareAndSwap", key, item)
+	if m.CompareAndSwapFunc != nil {
+		return m.CompareAndSwapFunc(key, item)
+	}
+	return nil
+}
+
+func (m *Cache) Increment(key string, amount int64) (interface{}, error) {
+	m.record("Increment", key, amount)
+	if m.IncrementFunc != nil {
+		return m.IncrementFunc(key, amount)
+	}
+	return nil, nil
+}
@@ -0,0 +1,106 @@
+// Package mocks has mocks of the interfaces of the mq, worker and cache
+// packages, for the tests of code depending on them:
+//
+//	q := &mocks.Queue{
+//		PushStringFunc: func(body string) (string, error) { return "id-1", nil },
+//	}
+//	err := notify(q, order) // takes an mq.QueueAPI
+//	calls := q.Calls()      // [{PushString [...]}]
+//
+// Mocks record their calls, and return what their Func fields return, or
+// zero values for methods whose Func is nil. They are safe for concurrent
+// use. They are kept in step with the interfaces, so the build breaks here
+// rather than in downstream tests when the interfaces change.
+package mocks
+
+import (
+	"context"
+	"sync"
+
+	"github.com/iron-io/iron_go3/cache"
+	"github.com/iron-io/iron_go3/mq"
+	"github.com/iron-io/iron_go3/worker"
+)
+
+var (
+	_ mq.QueueAPI    = (*Queue)(nil)
+	_ mq.ConsumerAPI = (*Consumer)(nil)
+	_ worker.API     = (*Worker)(nil)
+	_ cache.API      = (*Cache)(nil)
+
+	// the real types implement the interfaces too
+	_ mq.QueueAPI    = mq.Queue{}
+	_ mq.ConsumerAPI = (*mq.Consumer)(nil)
+	_ worker.API     = (*worker.Worker)(nil)
+	_ cache.API      = (*cache.Cache)(nil)
+)
+
+// A Call is a method call a mock received.
+type Call struct {
+	Method string
+	Args   []interface{}
+}
+
+// calls records the calls of a mock.
+type calls struct {
+	mu    sync.Mutex
+	calls []Call
+}
+
+func (c *calls) record(method string, args ...interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, Call{Method: method, Args: args})
+}
+
+// Calls returns the calls received, in order.
+func (c *calls) Calls() []Call {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Call(nil), c.calls...)
+}
+
+// Count returns how many calls of method were received.
+func (c *calls) Count(method string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for _, call := range c.calls {
+		if call.Method == method {
+			n++
+		}
+	}
+	return n
+}
+
+// Consumer is a mock mq.ConsumerAPI.
+type Consumer struct {
+	calls
+	RunFunc     func(ctx context.Context) error
+	RunOnceFunc func(ctx context.Context, n int) (int, error)
+	DrainFunc   func(ctx context.Context, opts mq.DrainOptions) (mq.DrainResult, error)
+}
+
+func (m *Consumer) Run(ctx context.Context) error {
+	m.record("Run", ctx)
+	if m.RunFunc != nil {
+		return m.RunFunc(ctx)
+	}
+	return nil
+}
+
+func (m *Consumer) RunOnce(ctx context.Context, n int) (int, error) {
+	m.record("RunOnce", ctx, n)
+	if m.RunOnceFunc != nil {
+		return m.RunOnceFunc(ctx, n)
+	}
+	return 0, nil
+}
+
+func (m *Consumer) Drain(ctx context.Context, opts mq.DrainOptions) (mq.DrainResult, error) {
+	m.record("Drain", ctx, opts)
+	if m.DrainFunc != nil {
+		return m.DrainFunc(ctx, opts)
+	}
+	return mq.DrainResult{}, nil
+}
@@ -0,0 +1,41 @@
+package mocks
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/iron-io/iron_go3/cache"
+	"github.com/iron-io/iron_go3/mq"
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestMocks(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("mocks", func() {
+		It("record calls and return what their funcs return", func() {
+			var q mq.QueueAPI = &Queue{
+				PushStringFunc: func(body string) (string, error) { return "id-" + body, nil },
+			}
+			id, err := q.PushString("1")
+			Expect(err, ToBeNil)
+			Expect(id, ToEqual, "id-1")
+			Expect(q.ReleaseMessage("id-1", "r", 5), ToBeNil)
+			Expect(q.(*Queue).Calls(), ToDeepEqual, []Call{
+				{Method: "PushString", Args: []interface{}{"1"}},
+				{Method: "ReleaseMessage", Args: []interface{}{"id-1", "r", int64(5)}},
+			})
+		})
+
+		It("count calls by method", func() {
+			c := &Cache{GetFunc: func(key string) (interface{}, error) { return nil, cache.ErrKeyNotFound }}
+			var api cache.API = c
+			_, err := api.Get("a")
+			Expect(errors.Is(err, cache.ErrKeyNotFound), ToBeTrue)
+			api.Get("b")
+			api.Delete("a")
+			Expect(c.Count("Get"), ToEqual, 2)
+			Expect(c.Count("Put"), ToEqual, 0)
+		})
+	})
+}
@@ -0,0 +1,116 @@
+package mocks
+
+import "github.com/iron-io/iron_go3/mq"
+
+// Queue is a mock mq.QueueAPI.
+type Queue struct {
+	calls
+	InfoFunc                   func() (mq.QueueInfo, error)
+	PushStringFunc             func(body string) (string, error)
+	PushStringsFunc            func(bodies ...string) ([]string, error)
+	PushWithFunc               func(opts mq.PushOptions, bodies ...string) ([]string, error)
+	PushMessagesFunc           func(msgs ...mq.Message) ([]string, error)
+	ReserveWithFunc            func(opts mq.ReserveOptions) ([]mq.Message, error)
+	PeekNFunc                  func(n int) ([]mq.Message, error)
+	DeleteMessageFunc          func(msgId, reservationId string) error
+	DeleteReservedMessagesFunc func(messages []mq.Message) error
+	TouchMessageForFunc        func(msgId, reservationId string, timeout int) (string, error)
+	ReleaseMessageFunc         func(msgId, reservationId string, delay int64) error
+	ClearFunc                  func() error
+}
+
+func (m *Queue) Info() (mq.QueueInfo, error) {
+	m.record("Info")
+	if m.InfoFunc != nil {
+		return m.InfoFunc()
+	}
+	return mq.QueueInfo{}, nil
+}
+
+func (m *Queue) PushString(body string) (string, error) {
+	m.record("PushString", body)
+	if m.PushStringFunc != nil {
+		return m.PushStringFunc(body)
+	}
+	return "", nil
+}
+
+func (m *Queue) PushStrings(bodies ...string) ([]string, error) {
+	m.record("PushStrings", bodies)
+	if m.PushStringsFunc != nil {
+		return m.PushStringsFunc(bodies...)
+	}
+	return nil, nil
+}
+
+func (m *Queue) PushWith(opts mq.PushOptions, bodies ...string) ([]string, error) {
+	m.record("PushWith", opts, bodies)
+	if m.PushWithFunc != nil {
+		return m.PushWithFunc(opts, bodies...)
+	}
+	return nil, nil
+}
+
+func (m *Queue) PushMessages(msgs ...mq.Message) ([]string, error) {
+	m.record("PushMessages", msgs)
+	if m.PushMessagesFunc != nil {
+		return m.PushMessagesFunc(msgs...)
+	}
+	return nil, nil
+}
+
+func (m *Queue) ReserveWith(opts mq.ReserveOptions) ([]mq.Message, error) {
+	m.record("ReserveWith", opts)
+	if m.ReserveWithFunc != nil {
+		return m.ReserveWithFunc(opts)
+	}
+	return nil, nil
+}
+
+func (m *Queue) PeekN(n int) ([]mq.Message, error) {
+	m.record("PeekN", n)
+	if m.PeekNFunc != nil {
+		return m.PeekNFunc(n)
+	}
+	return nil, nil
+}
+
+func (m *Queue) DeleteMessage(msgId, reservationId string) error {
+	m.record("DeleteMessage", msgId, reservationId)
+	if m.DeleteMessageFunc != nil {
+		return m.DeleteMessageFunc(msgId, reservationId)
+	}
+	return nil
+}
+
+func (m *Queue) DeleteReservedMessages(messages []mq.Message) error {
+	m.record("DeleteReservedMessages", messages)
+	if m.DeleteReservedMessagesFunc != nil {
+		return m.DeleteReservedMessagesFunc(messages)
+	}
+	return nil
+}
+
+func (m *Queue) TouchMessageFor(msgId, reservationId string, timeout int) (string, error) {
+	m.record("TouchMessageFor", msgId, reservationId, timeout)
+	if m.TouchMessageForFunc != nil {
+		return m.TouchMessageForFunc(msgId, reservationId, timeout)
+	}
+	return "", nil
+}
+
+func (m *Queue) ReleaseMessage(msgId, reservationId string, delay int64) error {
+	m.record("ReleaseMessage", msgId, reservationId, delay)
+	if m.ReleaseMessageFunc != nil {
+		return m.ReleaseMessageFunc(msgId, reservationId, delay)
+	}
+	return nil
+}
+
+func (m *Queue) Clear() error {
+	m.record("Clear")
+	if m.ClearFunc != nil {
+		return m.ClearFunc()
+	}
+	return nil
+}
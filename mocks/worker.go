@@ -0,0 +1,98 @@
+package mocks
+
+import "github.com/iron-io/iron_go3/worker"
+
+// Worker is a mock worker.API.
+type Worker struct {
+	calls
+	TaskQueueFunc         func(tasks ...worker.Task) ([]string, error)
+	TaskInfoFunc          func(taskId string) (worker.TaskInfo, error)
+	TaskLogFunc           func(taskId string) ([]byte, error)
+	TaskResultFunc        func(taskId string, out interface{}) error
+	TaskCancelFunc        func(taskId string) error
+	FilteredTaskListFunc  func(params worker.TaskListParams) ([]worker.TaskInfo, error)
+	ScheduleFunc          func(schedules ...worker.Schedule) ([]string, error)
+	ScheduleInfoFunc      func(scheduleId string) (worker.ScheduleInfo, error)
+	ScheduleCancelFunc    func(scheduleId string) error
+	CodePackageByNameFunc func(name string) (worker.CodeInfo, error)
+}
+
+func (m *Worker) TaskQueue(tasks ...worker.Task) ([]string, error) {
+	m.record("TaskQueue", tasks)
+	if m.TaskQueueFunc != nil {
+		return m.TaskQueueFunc(tasks...)
+	}
+	return nil, nil
+}
+
+func (m *Worker) TaskInfo(taskId string) (worker.TaskInfo, error) {
+	m.record("TaskInfo", taskId)
+	if m.TaskInfoFunc != nil {
+		return m.TaskInfoFunc(taskId)
+	}
+	return worker.TaskInfo{}, nil
+}
+
+func (m *Worker) TaskLog(taskId string) ([]byte, error) {
+	m.record("TaskLog", taskId)
+	if m.TaskLogFunc != nil {
+		return m.TaskLogFunc(taskId)
+	}
+	return nil, nil
+}
+
+func (m *Worker) TaskResult(taskId string, out interface{}) error {
+	m.record("TaskResult", taskId, out)
+	if m.TaskResultFunc != nil {
+		return m.TaskResultFunc(taskId, out)
+	}
+	return nil
+}
+
+func (m *Worker) TaskCancel(taskId string) error {
+	m.record("TaskCancel", taskId)
+	if m.TaskCancelFunc != nil {
+		return m.TaskCancelFunc(taskId)
+	}
+	return nil
+}
+
+func (m *Worker) FilteredTaskList(params worker.TaskListParams) ([]worker.TaskInfo, error) {
+	m.record("FilteredTaskList", params)
+	if m.FilteredTaskListFunc != nil {
+		return m.FilteredTaskListFunc(params)
+	}
+	return nil, nil
+}
+
+func (m *Worker) Schedule(schedules ...worker.Schedule) ([]string, error) {
+	m.record("Schedule", schedules)
+	if m.ScheduleFunc != nil {
+		return m.ScheduleFunc(schedules...)
+	}
+	return nil, nil
+}
+
+func (m *Worker) ScheduleInfo(scheduleId string) (worker.ScheduleInfo, error) {
+	m.record("ScheduleInfo", scheduleId)
+	if m.ScheduleInfoFunc != nil {
+		return m.ScheduleInfoFunc(scheduleId)
+	}
+	return worker.ScheduleInfo{}, nil
+}
+
+func (m *Worker) ScheduleCancel(scheduleId string) error {
+	m.record("ScheduleCancel", scheduleId)
+	if m.ScheduleCancelFunc != nil {
+		return m.ScheduleCancelFunc(scheduleId)
+	}
+	return nil
+}
+
+func (m *Worker) CodePackageByName(name string) (worker.CodeInfo, error) {
+	m.record("CodePackageByName", name)
+	if m.CodePackageByNameFunc != nil {
+		return m.CodePackageByNameFunc(name)
+	}
+	return worker.CodeInfo{}, nil
+}
@@ -0,0 +1,108 @@
+package mq
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/iron-io/iron_go3/api"
+)
+
+// An AlertEvent is the message IronMQ posts to an alert's queue when the
+// alert triggers. Making that queue a push queue with an AlertHandler as
+// subscriber delivers the alerts over HTTP.
+type AlertEvent struct {
+	AlertId string `json:"alert_id"`
+	// Type and Direction are those of the Alert, "fixed" or "progressive"
+	// and "asc" or "desc".
+	Type      string `json:"alert_type"`
+	Direction string `json:"alert_direction"`
+	Trigger   int    `json:"alert_trigger"`
+	// Queue is the queue whose size triggered the alert.
+	Queue     string    `json:"source_queue"`
+	QueueSize int       `json:"queue_size"`
+	ProjectId string    `json:"project_id,omitempty"`
+	Details   string    `json:"details,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Rising tells whether the alert triggered on a growing queue.
+func (e AlertEvent) Rising() bool { return e.Direction == "asc" }
+
+// ParseAlert parses the body of an alert message.
+func ParseAlert(body []byte) (AlertEvent, error) {
+	var e AlertEvent
+	if err := json.Unmarshal(body, &e); err != nil {
+		return AlertEvent{}, fmt.Errorf("mq: parsing alert: %w", err)
+	}
+	if e.Queue == "" || e.Type == "" || e.Direction == "" {
+		return AlertEvent{}, errors.New("mq: not an alert: missing source_queue, alert_type or alert_direction")
+	}
+	return e, nil
+}
+
+// Alert parses the message's body as an alert, see ParseAlert.
+func (m Message) Alert() (AlertEvent, error) {
+	return ParseAlert([]byte(m.Body))
+}
+
+// maxAlertBody limits the bodies AlertHandler reads, alerts are small.
+const maxAlertBody = 64 << 10
+
+// AlertHandler returns an http.Handler calling fn with the alerts posted
+// to it, e.g. to scale workers:
+//
+//	http.Handle("/alerts", mq.AlertHandler(func(ctx context.Context, a mq.AlertEvent) error {
+//		if a.Rising() {
+//			return scaleUp(a.Queue, a.QueueSize)
+//		}
+//		return scaleDown(a.Queue, a.QueueSize)
+//	}))
+//
+// Requests that aren't alerts get 400 Bad Request. If fn fails the handler
+// responds 500 Internal Server Error, so IronMQ retries the push. To refuse
+// requests that don't come from IronMQ, see AlertOptions.Secret.
+func AlertHandler(fn func(ctx context.Context, e AlertEvent) error) http.Handler {
+	return AlertHandlerWith(AlertOptions{}, fn)
+}
+
+// AlertOptions are the options of AlertHandlerWith.
+type AlertOptions struct {
+	// Secret, if set, refuses pushes to URLs that weren't signed with it by
+	// api.SignURL. Sign the subscriber URL for as long as it's subscribed.
+	Secret []byte
+}
+
+// AlertHandlerWith is AlertHandler with options.
+func AlertHandlerWith(opts AlertOptions, fn func(ctx context.Context, e AlertEvent) error) http.Handler {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "alerts must be POSTed", http.StatusMethodNotAllowed)
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxAlertBody))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		e, err := ParseAlert(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := fn(r.Context(), e); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	if opts.Secret != nil {
+		return api.RequireSignatureWith(opts.Secret, api.SignatureOptions{AcceptURL: true}, h)
+	}
+	return h
+}
@@ -0,0 +1,79 @@
+package mq
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iron-io/iron_go3/api"
+	. "github.com/jeffh/go.bdd"
+)
+
+const alertBody = `{"alert_direction":"asc","alert_id":"5eee546df4a4140419b6bcfe","alert_trigger":200,"alert_type":"fixed",` +
+	`"created_at":"2020-06-20T18:30:05Z","details":"Queue 'jobs' has reached 200 messages","project_id":"p","queue_size":201,"source_queue":"jobs"}`
+
+func TestAlertHandler(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("alert handlers", func() {
+		var got []AlertEvent
+		var fail error
+		h := AlertHandler(func(ctx context.Context, e AlertEvent) error {
+			got = append(got, e)
+			return fail
+		})
+		post := func(method, body string) int {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(method, "/alerts", strings.NewReader(body)))
+			return w.Code
+		}
+
+		It("parses alerts", func() {
+			Expect(post("POST", alertBody), ToEqual, http.StatusOK)
+			Expect(len(got), ToEqual, 1)
+			e := got[0]
+			Expect(e.Queue, ToEqual, "jobs")
+			Expect(e.QueueSize, ToEqual, 201)
+			Expect(e.Trigger, ToEqual, 200)
+			Expect(e.Type, ToEqual, "fixed")
+			Expect(e.Rising(), ToBeTrue)
+			Expect(e.CreatedAt.Year(), ToEqual, 2020)
+		})
+
+		It("refuses other requests", func() {
+			Expect(post("GET", ""), ToEqual, http.StatusMethodNotAllowed)
+			Expect(post("POST", "{"), ToEqual, http.StatusBadRequest)
+			Expect(post("POST", `{"body":"not an alert"}`), ToEqual, http.StatusBadRequest)
+			Expect(len(got), ToEqual, 1)
+		})
+
+		It("fails when the callback does", func() {
+			fail = errors.New("scaling failed")
+			Expect(post("POST", alertBody), ToEqual, http.StatusInternalServerError)
+		})
+
+		It("refuses pushes to URLs not signed with its secret", func() {
+			secret := []byte("secret")
+			signed := AlertHandlerWith(AlertOptions{Secret: secret}, func(ctx context.Context, e AlertEvent) error { return nil })
+			w := httptest.NewRecorder()
+			signed.ServeHTTP(w, httptest.NewRequest("POST", "/alerts", strings.NewReader(alertBody)))
+			Expect(w.Code, ToEqual, http.StatusUnauthorized)
+
+			u, err := api.SignURL("/alerts", secret, time.Hour)
+			Expect(err, ToBeNil)
+			w = httptest.NewRecorder()
+			signed.ServeHTTP(w, httptest.NewRequest("POST", u, strings.NewReader(alertBody)))
+			Expect(w.Code, ToEqual, http.StatusOK)
+		})
+
+		It("parses alert messages", func() {
+			e, err := Message{Body: alertBody}.Alert()
+			Expect(err, ToBeNil)
+			Expect(e.AlertId, ToEqual, "5eee546df4a4140419b6bcfe")
+		})
+	})
+}
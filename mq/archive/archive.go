@@ -0,0 +1,191 @@
+// Package archive copies the messages of a queue to an object store, for
+// audit and replay.
+//
+//	a := archive.New(mq.ConfigNew("orders-audit", &settings), archive.Dir("/var/archive"))
+//	go a.Run(ctx)
+//
+// The Archiver consumes the queue it is given, so to archive messages that
+// are processed elsewhere, archive a queue they are also pushed to, e.g. a
+// subscriber of a push queue.
+//
+// Messages are written in batches, one object of JSON lines per batch,
+// under keys partitioned by the hour they were archived in:
+//
+//	<prefix>/2006/01/02/15/20060102T150405.000000000Z-<random>.jsonl
+//
+// A Replayer pushes archived messages back into a queue.
+package archive
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/iron-io/iron_go3/mq"
+)
+
+// A Sink stores archived objects. Keys are slash separated paths.
+type Sink interface {
+	// Put stores data under key, replacing any object there.
+	Put(ctx context.Context, key string, data []byte) error
+}
+
+// A Record is an archived message, one line of an archived object.
+type Record struct {
+	Queue         string    `json:"queue"`
+	Id            string    `json:"id"`
+	Body          string    `json:"body"`
+	ReservedCount int       `json:"reserved_count,omitempty"`
+	ArchivedAt    time.Time `json:"archived_at"`
+}
+
+// An Archiver consumes a queue and writes its messages to Sink. Messages
+// are only deleted from the queue once their object is stored.
+type Archiver struct {
+	Queue mq.Queue
+	Sink  Sink
+	// Prefix starts the keys of objects, the queue's name by default.
+	Prefix string
+	// BatchSize is the most messages in an object, mq.MaxReserve by
+	// default.
+	BatchSize int
+	// Concurrency is how many objects are written at once, 1 by default.
+	Concurrency int
+	// OnError is told about failed writes and reservations.
+	OnError func(error)
+	// OnArchive, if set, is told about every object stored.
+	OnArchive func(key string, records int)
+}
+
+// New returns an Archiver of q writing to sink.
+func New(q mq.Queue, sink Sink) *Archiver {
+	return &Archiver{Queue: q, Sink: sink}
+}
+
+// Run archives until ctx is done, see mq.Consumer.Run.
+func (a *Archiver) Run(ctx context.Context) error {
+	c := mq.NewBatchConsumer(a.Queue, a, a.BatchSize)
+	c.Concurrency = a.Concurrency
+	c.OnError = a.OnError
+	return c.Run(ctx)
+}
+
+// HandleBatch writes ds to one object, so an Archiver can be the
+// BatchHandler of a consumer configured otherwise.
+func (a *Archiver) HandleBatch(ctx context.Context, ds []*mq.Delivery) error {
+	now := time.Now().UTC()
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, d := range ds {
+		r := Record{Queue: a.Queue.Name, Id: d.Id, Body: d.Body, ReservedCount: d.ReservedCount, ArchivedAt: now}
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	id, err := objectId()
+	if err != nil {
+		return err
+	}
+	key := Partition(a.prefix(), now) + now.Format("20060102T150405.000000000Z") + "-" + id + ".jsonl"
+	if err := a.Sink.Put(ctx, key, buf.Bytes()); err != nil {
+		err = fmt.Errorf("archive: storing %s: %w", key, err)
+		if a.OnError != nil {
+			a.OnError(err)
+		}
+		return err
+	}
+	if a.OnArchive != nil {
+		a.OnArchive(key, len(ds))
+	}
+	return nil
+}
+
+// Partition returns the key prefix of the objects archived under prefix
+// in the hour of t, with a trailing slash.
+func Partition(prefix string, t time.Time) string {
+	return prefix + t.UTC().Format("/2006/01/02/15/")
+}
+
+func (a *Archiver) prefix() string {
+	if a.Prefix != "" {
+		return a.Prefix
+	}
+	return a.Queue.Name
+}
+
+func objectId() (string, error) {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b[:]), nil
+}
+
+// Dir is a Sink storing objects as files under a directory.
+type Dir string
+
+// Put writes data to the file at key under d, creating directories as
+// needed. Files are written whole or not at all.
+func (d Dir) Put(ctx context.Context, key string, data []byte) error {
+	path := filepath.Join(string(d), filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), ".archive-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// List returns the keys of the files under d starting with prefix.
+func (d Dir) List(ctx context.Context, prefix string) ([]string, error) {
+	// walk the deepest directory the prefix names
+	root := filepath.Join(string(d), filepath.FromSlash(path.Dir(prefix+"x")))
+	var keys []string
+	err := filepath.WalkDir(root, func(p string, e fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) && p == root {
+				return fs.SkipAll
+			}
+			return err
+		}
+		if e.IsDir() || strings.HasPrefix(e.Name(), ".archive-") {
+			return nil
+		}
+		rel, err := filepath.Rel(string(d), p)
+		if err != nil {
+			return err
+		}
+		if key := filepath.ToSlash(rel); strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	sort.Strings(keys)
+	return keys, err
+}
+
+// Get reads the file at key under d.
+func (d Dir) Get(ctx context.Context, key string) ([]byte, error) {
+	return os.ReadFile(filepath.Join(string(d), filepath.FromSlash(key)))
+}
@@ -0,0 +1,114 @@
+package archive_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iron-io/iron_go3/irontest"
+	"github.com/iron-io/iron_go3/mq"
+	"github.com/iron-io/iron_go3/mq/archive"
+	. "github.com/jeffh/go.bdd"
+)
+
+// memSink stores objects in memory, failing while err is set.
+type memSink struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	err     error
+}
+
+func (s *memSink) Put(ctx context.Context, key string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.objects[key] = data
+	return nil
+}
+
+func records(t *testing.T, data []byte) []archive.Record {
+	var rs []archive.Record
+	sc := bufio.NewScanner(strings.NewReader(string(data)))
+	for sc.Scan() {
+		var r archive.Record
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+			t.Fatal(err)
+		}
+		rs = append(rs, r)
+	}
+	return rs
+}
+
+func TestArchiver(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("archiving", func() {
+		srv := irontest.NewServer()
+		defer srv.Close()
+
+		It("writes batches to hourly partitions and deletes them", func() {
+			q := mq.Queue{Settings: srv.Settings("iron_mq"), Name: "audit"}
+			_, err := q.PushStrings("a", "b", "c")
+			Expect(err, ToBeNil)
+
+			sink := &memSink{objects: map[string][]byte{}}
+			var archived int
+			a := archive.New(q, sink)
+			a.Prefix = "archive/audit"
+			a.OnArchive = func(key string, n int) { archived += n }
+			_, err = mq.NewBatchConsumer(q, a, 0).RunOnce(context.Background(), 10)
+			Expect(err, ToBeNil)
+			Expect(archived, ToEqual, 3)
+
+			Expect(len(sink.objects), ToEqual, 1)
+			for key, data := range sink.objects {
+				Expect(strings.HasPrefix(key, archive.Partition("archive/audit", time.Now())), ToBeTrue)
+				Expect(strings.HasSuffix(key, ".jsonl"), ToBeTrue)
+				rs := records(t, data)
+				Expect(len(rs), ToEqual, 3)
+				Expect(rs[0].Queue, ToEqual, "audit")
+				Expect(rs[0].Body, ToEqual, "a")
+				Expect(rs[2].Body, ToEqual, "c")
+			}
+			Expect(len(srv.MQ.Messages("audit")), ToEqual, 0)
+		})
+
+		It("keeps messages it couldn't store", func() {
+			q := mq.Queue{Settings: srv.Settings("iron_mq"), Name: "audit-failing"}
+			q.PushStrings("a", "b")
+
+			sink := &memSink{objects: map[string][]byte{}, err: errors.New("disk full")}
+			var errs []error
+			a := archive.New(q, sink)
+			a.OnError = func(err error) { errs = append(errs, err) }
+			_, err := mq.NewBatchConsumer(q, a, 0).RunOnce(context.Background(), 10)
+			Expect(err, ToBeNil)
+			Expect(len(errs), ToEqual, 1)
+			Expect(len(srv.MQ.Messages("audit-failing")), ToEqual, 2)
+		})
+
+		It("partitions by UTC hour", func() {
+			at := time.Date(2024, 3, 9, 17, 30, 0, 0, time.FixedZone("X", 3600))
+			Expect(archive.Partition("q", at), ToEqual, "q/2024/03/09/16/")
+		})
+
+		It("stores files under a directory", func() {
+			dir := archive.Dir(t.TempDir())
+			Expect(dir.Put(context.Background(), "q/2024/03/09/16/x.jsonl", []byte("{}\n")), ToBeNil)
+			data, err := os.ReadFile(filepath.Join(string(dir), "q", "2024", "03", "09", "16", "x.jsonl"))
+			Expect(err, ToBeNil)
+			Expect(string(data), ToEqual, "{}\n")
+			entries, _ := os.ReadDir(filepath.Join(string(dir), "q", "2024", "03", "09", "16"))
+			Expect(len(entries), ToEqual, 1)
+		})
+	})
+}
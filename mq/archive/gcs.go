@@ -0,0 +1,92 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// GCS is a Sink storing objects in a Google Cloud Storage bucket, through
+// its JSON API.
+type GCS struct {
+	Bucket string
+	// Token returns the OAuth 2.0 access token of requests, e.g. from
+	// golang.org/x/oauth2/google. Requests aren't authorized without one.
+	Token func(ctx context.Context) (string, error)
+	// Endpoint is the service's URL, https://storage.googleapis.com by
+	// default.
+	Endpoint string
+	// Client makes the requests, http.DefaultClient if nil.
+	Client *http.Client
+}
+
+// Put uploads data to key.
+func (g *GCS) Put(ctx context.Context, key string, data []byte) error {
+	q := url.Values{"uploadType": {"media"}, "name": {key}}
+	_, err := g.do(ctx, "POST", "/upload/storage/v1/b/"+url.PathEscape(g.Bucket)+"/o", q, data)
+	return err
+}
+
+// List returns the keys starting with prefix, following the pages of the
+// listing.
+func (g *GCS) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	q := url.Values{"prefix": {prefix}, "fields": {"items(name),nextPageToken"}}
+	for {
+		body, err := g.do(ctx, "GET", "/storage/v1/b/"+url.PathEscape(g.Bucket)+"/o", q, nil)
+		if err != nil {
+			return nil, err
+		}
+		var out struct {
+			Items []struct {
+				Name string `json:"name"`
+			} `json:"items"`
+			NextPageToken string `json:"nextPageToken"`
+		}
+		if err := json.Unmarshal(body, &out); err != nil {
+			return nil, err
+		}
+		for _, item := range out.Items {
+			keys = append(keys, item.Name)
+		}
+		if out.NextPageToken == "" {
+			break
+		}
+		q.Set("pageToken", out.NextPageToken)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// Get downloads the object at key.
+func (g *GCS) Get(ctx context.Context, key string) ([]byte, error) {
+	path := "/storage/v1/b/" + url.PathEscape(g.Bucket) + "/o/" + url.PathEscape(key)
+	return g.do(ctx, "GET", path, url.Values{"alt": {"media"}}, nil)
+}
+
+func (g *GCS) do(ctx context.Context, method, path string, query url.Values, body []byte) ([]byte, error) {
+	endpoint := g.Endpoint
+	if endpoint == "" {
+		endpoint = "https://storage.googleapis.com"
+	}
+	u := strings.TrimSuffix(endpoint, "/") + path + "?" + query.Encode()
+	req, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/octet-stream")
+	}
+	if g.Token != nil {
+		token, err := g.Token(ctx)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return send(g.Client, req)
+}
@@ -0,0 +1,181 @@
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/iron-io/iron_go3/mq"
+)
+
+// A Source reads archived objects back, see Replayer. Dir, S3 and GCS are
+// Sources as well as Sinks.
+type Source interface {
+	// List returns the keys starting with prefix, sorted.
+	List(ctx context.Context, prefix string) ([]string, error)
+	Get(ctx context.Context, key string) ([]byte, error)
+}
+
+// ReplayOfHeader is the envelope header of replayed messages, set to the
+// id of the archived message.
+const ReplayOfHeader = "replay-of"
+
+// ReplayOf returns the id of the archived message e replays, and whether
+// it is a replay.
+func ReplayOf(e mq.Envelope) (string, bool) {
+	id, ok := e.Headers[ReplayOfHeader]
+	return id, ok
+}
+
+// A Replayer pushes archived messages back into a queue, in the order they
+// were archived. Their bodies are pushed in envelopes, the archived ones if
+// they were enveloped, with ReplayOfHeader set.
+//
+//	r := archive.NewReplayer(archive.Dir("/var/archive"), "orders-audit", orders)
+//	r.From, r.To = incident.Add(-time.Hour), incident
+//	r.Rate = 50
+//	n, err := r.Run(ctx)
+type Replayer struct {
+	Source Source
+	// Prefix is the Prefix of the Archiver that wrote the messages.
+	Prefix string
+	Queue  mq.Queue
+	// From and To bound when the messages were archived, To excluded. Zero
+	// values leave the range open.
+	From, To time.Time
+	// Filter, if set, picks the records to replay.
+	Filter func(Record) bool
+	// Rate is the most messages pushed per second, unlimited if zero.
+	Rate float64
+}
+
+// NewReplayer returns a Replayer of the messages archived under prefix in
+// source, to q.
+func NewReplayer(source Source, prefix string, q mq.Queue) *Replayer {
+	return &Replayer{Source: source, Prefix: prefix, Queue: q}
+}
+
+// Run replays the messages and returns how many it pushed. It stops at the
+// first error, after which the messages replayed are the first ones of the
+// range, or when ctx is done.
+func (r *Replayer) Run(ctx context.Context) (int, error) {
+	keys, err := r.keys(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	start := time.Now()
+	pushed := 0
+	batch := make([]mq.Message, 0, r.batchSize())
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if r.Rate > 0 {
+			wait := time.Until(start.Add(time.Duration(float64(pushed) / r.Rate * float64(time.Second))))
+			t := time.NewTimer(wait)
+			defer t.Stop()
+			select {
+			case <-t.C:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		if _, err := r.Queue.PushMessages(batch...); err != nil {
+			return fmt.Errorf("archive: replaying to %s: %w", r.Queue.Name, err)
+		}
+		pushed += len(batch)
+		batch = batch[:0]
+		return nil
+	}
+
+	for _, key := range keys {
+		data, err := r.Source.Get(ctx, key)
+		if err != nil {
+			return pushed, fmt.Errorf("archive: reading %s: %w", key, err)
+		}
+		sc := bufio.NewScanner(bytes.NewReader(data))
+		sc.Buffer(nil, len(data)+1)
+		for sc.Scan() {
+			var rec Record
+			if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+				return pushed, fmt.Errorf("archive: reading %s: %w", key, err)
+			}
+			if !r.inRange(rec.ArchivedAt) || (r.Filter != nil && !r.Filter(rec)) {
+				continue
+			}
+			body, err := replayBody(rec)
+			if err != nil {
+				return pushed, err
+			}
+			if batch = append(batch, mq.Message{Body: body}); len(batch) == cap(batch) {
+				if err := flush(); err != nil {
+					return pushed, err
+				}
+			}
+		}
+		if err := sc.Err(); err != nil {
+			return pushed, fmt.Errorf("archive: reading %s: %w", key, err)
+		}
+	}
+	return pushed, flush()
+}
+
+// keys returns the keys of the objects that may hold messages in range,
+// listing the hourly partitions of a bounded range one by one.
+func (r *Replayer) keys(ctx context.Context) ([]string, error) {
+	if r.From.IsZero() {
+		keys, err := r.Source.List(ctx, r.Prefix+"/")
+		if err != nil {
+			return nil, fmt.Errorf("archive: listing %s: %w", r.Prefix, err)
+		}
+		return keys, nil
+	}
+	to := r.To
+	if to.IsZero() {
+		to = time.Now()
+	}
+	var keys []string
+	for h := r.From.UTC().Truncate(time.Hour); h.Before(to); h = h.Add(time.Hour) {
+		p := Partition(r.Prefix, h)
+		ks, err := r.Source.List(ctx, p)
+		if err != nil {
+			return nil, fmt.Errorf("archive: listing %s: %w", p, err)
+		}
+		keys = append(keys, ks...)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (r *Replayer) inRange(t time.Time) bool {
+	return (r.From.IsZero() || !t.Before(r.From)) && (r.To.IsZero() || t.Before(r.To))
+}
+
+// batchSize spreads the pushes of a limited rate over about ten requests a
+// second.
+func (r *Replayer) batchSize() int {
+	if r.Rate <= 0 {
+		return mq.MaxPush
+	}
+	return min(max(int(r.Rate/10), 1), mq.MaxPush)
+}
+
+// replayBody returns the body of rec in an envelope tagged as a replay.
+func replayBody(rec Record) (string, error) {
+	e, ok := mq.OpenEnvelope(rec.Body)
+	if !ok {
+		e.ProducedAt = time.Now().UTC()
+	}
+	headers := make(map[string]string, len(e.Headers)+1)
+	for k, v := range e.Headers {
+		headers[k] = v
+	}
+	headers[ReplayOfHeader] = rec.Id
+	e.Headers = headers
+	return e.Encode()
+}
@@ -0,0 +1,95 @@
+package archive_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iron-io/iron_go3/irontest"
+	"github.com/iron-io/iron_go3/mq"
+	"github.com/iron-io/iron_go3/mq/archive"
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestReplayer(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("replaying", func() {
+		srv := irontest.NewServer()
+		defer srv.Close()
+		ctx := context.Background()
+
+		// two hours of archived messages
+		dir := archive.Dir(t.TempDir())
+		hour := time.Date(2024, 3, 9, 16, 0, 0, 0, time.UTC)
+		put := func(at time.Time, name string, bodies ...string) {
+			var b strings.Builder
+			for i, body := range bodies {
+				r := archive.Record{Queue: "orders", Id: fmt.Sprintf("%s-%d", name, i), Body: body, ArchivedAt: at}
+				json.NewEncoder(&b).Encode(r)
+			}
+			key := archive.Partition("audit", at) + name + ".jsonl"
+			Expect(dir.Put(ctx, key, []byte(b.String())), ToBeNil)
+		}
+		put(hour.Add(10*time.Minute), "a", "a0", "a1")
+		put(hour.Add(70*time.Minute), "b", "b0", "b1", "skip")
+		enveloped, _ := mq.Envelope{ContentType: "text/plain", Headers: map[string]string{"k": "v"}, Payload: []byte("c0")}.Encode()
+		put(hour.Add(130*time.Minute), "c", enveloped)
+
+		payloads := func(queue string) []string {
+			var out []string
+			for _, body := range srv.MQ.Messages(queue) {
+				e, ok := mq.OpenEnvelope(body)
+				Expect(ok, ToBeTrue)
+				out = append(out, string(e.Payload))
+			}
+			return out
+		}
+
+		It("replays everything in order", func() {
+			r := archive.NewReplayer(dir, "audit", mq.Queue{Settings: srv.Settings("iron_mq"), Name: "replay-all"})
+			n, err := r.Run(ctx)
+			Expect(err, ToBeNil)
+			Expect(n, ToEqual, 6)
+			Expect(payloads("replay-all"), ToDeepEqual, []string{"a0", "a1", "b0", "b1", "skip", "c0"})
+		})
+
+		It("replays a time range through a filter", func() {
+			r := archive.NewReplayer(dir, "audit", mq.Queue{Settings: srv.Settings("iron_mq"), Name: "replay-range"})
+			r.From, r.To = hour.Add(30*time.Minute), hour.Add(2*time.Hour)
+			r.Filter = func(rec archive.Record) bool { return rec.Body != "skip" }
+			n, err := r.Run(ctx)
+			Expect(err, ToBeNil)
+			Expect(n, ToEqual, 2)
+			Expect(payloads("replay-range"), ToDeepEqual, []string{"b0", "b1"})
+		})
+
+		It("tags replays and keeps archived envelopes", func() {
+			r := archive.NewReplayer(dir, "audit", mq.Queue{Settings: srv.Settings("iron_mq"), Name: "replay-tagged"})
+			r.From = hour.Add(2 * time.Hour)
+			r.To = hour.Add(3 * time.Hour)
+			_, err := r.Run(ctx)
+			Expect(err, ToBeNil)
+			e, _ := mq.OpenEnvelope(srv.MQ.Messages("replay-tagged")[0])
+			id, ok := archive.ReplayOf(e)
+			Expect(ok, ToBeTrue)
+			Expect(id, ToEqual, "c-0")
+			Expect(e.ContentType, ToEqual, "text/plain")
+			Expect(e.Headers["k"], ToEqual, "v")
+		})
+
+		It("limits the rate", func() {
+			r := archive.NewReplayer(dir, "audit", mq.Queue{Settings: srv.Settings("iron_mq"), Name: "replay-slow"})
+			r.Rate = 20
+			start := time.Now()
+			n, err := r.Run(ctx)
+			Expect(err, ToBeNil)
+			Expect(n, ToEqual, 6)
+			// 3 batches of 2 at 20/s, the first going out right away
+			Expect(time.Since(start) >= 200*time.Millisecond, ToBeTrue)
+		})
+	})
+}
@@ -0,0 +1,212 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3 is a Sink storing objects in an Amazon S3 bucket, or any service
+// speaking its API, e.g. MinIO. Requests are signed with AWS Signature
+// Version 4.
+type S3 struct {
+	Bucket string
+	// Region is the bucket's region, e.g. "us-east-1".
+	Region string
+	// AccessKeyId and SecretAccessKey are the credentials, with
+	// SessionToken for temporary ones.
+	AccessKeyId     string
+	SecretAccessKey string
+	SessionToken    string
+	// Endpoint is the service's URL, https://s3.<Region>.amazonaws.com by
+	// default. Buckets are addressed by path.
+	Endpoint string
+	// Client makes the requests, http.DefaultClient if nil.
+	Client *http.Client
+}
+
+// Put uploads data to key.
+func (s *S3) Put(ctx context.Context, key string, data []byte) error {
+	_, err := s.do(ctx, "PUT", key, nil, data)
+	return err
+}
+
+// List returns the keys starting with prefix, with as many ListObjectsV2
+// requests as it takes.
+func (s *S3) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	q := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+	for {
+		body, err := s.do(ctx, "GET", "", q, nil)
+		if err != nil {
+			return nil, err
+		}
+		var out struct {
+			Contents []struct {
+				Key string
+			}
+			IsTruncated           bool
+			NextContinuationToken string
+		}
+		if err := xml.Unmarshal(body, &out); err != nil {
+			return nil, err
+		}
+		for _, c := range out.Contents {
+			keys = append(keys, c.Key)
+		}
+		if !out.IsTruncated {
+			break
+		}
+		q.Set("continuation-token", out.NextContinuationToken)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// Get downloads the object at key.
+func (s *S3) Get(ctx context.Context, key string) ([]byte, error) {
+	return s.do(ctx, "GET", key, nil, nil)
+}
+
+// do sends a signed request for key with query and body, and returns the
+// response body.
+func (s *S3) do(ctx context.Context, method, key string, query url.Values, body []byte) ([]byte, error) {
+	endpoint := s.Endpoint
+	if endpoint == "" {
+		endpoint = "https://s3." + s.Region + ".amazonaws.com"
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + s.Bucket + "/" + key
+	u.RawQuery = query.Encode()
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if s.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+	s.sign(req, sha256Hex(body), time.Now())
+
+	return send(s.Client, req)
+}
+
+// sign adds the Authorization header, signing all headers of req.
+func (s *S3) sign(req *http.Request, payloadHash string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonical := strings.Join([]string{
+		req.Method,
+		escapePath(req.URL.EscapedPath()),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := now.Format("20060102") + "/" + s.Region + "/s3/aws4_request"
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonical))
+
+	key := []byte("AWS4" + s.SecretAccessKey)
+	for _, part := range []string{now.Format("20060102"), s.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKeyId, scope, signedHeaders, hex.EncodeToString(hmacSHA256(key, toSign))))
+}
+
+// escapePath re-escapes an escaped path the way Signature Version 4 wants
+// it, only leaving unreserved characters and slashes as they are.
+func escapePath(p string) string {
+	unescaped, err := url.PathUnescape(p)
+	if err != nil {
+		unescaped = p
+	}
+	return strings.ReplaceAll(sigEscape(unescaped), "%2F", "/")
+}
+
+func canonicalQuery(q url.Values) string {
+	var pairs []string
+	for k, vs := range q {
+		for _, v := range vs {
+			pairs = append(pairs, sigEscape(k)+"="+sigEscape(v))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// sigEscape percent-encodes all but the unreserved characters of RFC 3986.
+func sigEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || strings.IndexByte("-_.~", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// send makes req with client, or http.DefaultClient, and returns the body
+// of a successful response.
+func send(client *http.Client, req *http.Request) ([]byte, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("%s %s: %s: %s", req.Method, req.URL.Redacted(), resp.Status, bytes.TrimSpace(body))
+	}
+	return body, nil
+}
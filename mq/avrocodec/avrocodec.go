@@ -0,0 +1,117 @@
+// Package avrocodec provides an mq.Codec for Avro.
+//
+// Payloads are Avro binary data without a header, the id of the schema
+// they were written with is recorded in the envelope's SchemaIdHeader.
+// Consumers reading with another schema resolve the writer schema through
+// a Registry, so producers and consumers can evolve their schemas
+// independently:
+//
+//	registry := avrocodec.RegistryFunc(func(id string) (avro.Schema, error) {
+//		return fetchSchema(id) // e.g. from a Confluent schema registry
+//	})
+//	codec := avrocodec.New(avro.MustParse(orderSchema), "orders-v2", registry)
+//	order, err := mq.DecodeAs[Order](d.Envelope, codec)
+package avrocodec
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/hamba/avro/v2"
+)
+
+// ContentType is the media type of Avro payloads.
+const ContentType = "application/avro"
+
+// SchemaIdHeader is the envelope header holding the id of the schema a
+// payload was written with.
+const SchemaIdHeader = "avro-schema-id"
+
+// A Registry looks up schemas by id.
+type Registry interface {
+	Schema(id string) (avro.Schema, error)
+}
+
+// RegistryFunc adapts a function to a Registry.
+type RegistryFunc func(id string) (avro.Schema, error)
+
+func (f RegistryFunc) Schema(id string) (avro.Schema, error) { return f(id) }
+
+// A Codec encodes values with an Avro schema. It is an mq.HeaderCodec.
+type Codec struct {
+	// Schema is the schema values are written with and read into.
+	Schema avro.Schema
+	// SchemaId identifies Schema, it is recorded in the envelopes of pushed
+	// values.
+	SchemaId string
+	// Registry looks up the schemas of payloads written with another id.
+	// Without it, such payloads can't be decoded.
+	Registry Registry
+
+	mu       sync.Mutex
+	resolved map[string]avro.Schema // by writer schema id
+}
+
+// New returns a Codec for schema, identified by id, looking up other
+// schemas in registry, which may be nil.
+func New(schema avro.Schema, id string, registry Registry) *Codec {
+	return &Codec{Schema: schema, SchemaId: id, Registry: registry}
+}
+
+func (c *Codec) ContentType() string { return ContentType }
+
+func (c *Codec) Marshal(v interface{}) ([]byte, error) {
+	return avro.Marshal(c.Schema, v)
+}
+
+// Unmarshal decodes data assuming it was written with Schema.
+func (c *Codec) Unmarshal(data []byte, v interface{}) error {
+	return avro.Unmarshal(c.Schema, data, v)
+}
+
+// Headers returns SchemaIdHeader set to SchemaId, if there is one.
+func (c *Codec) Headers() map[string]string {
+	if c.SchemaId == "" {
+		return nil
+	}
+	return map[string]string{SchemaIdHeader: c.SchemaId}
+}
+
+// UnmarshalHeaders decodes data written with the schema whose id is in
+// headers, resolving it against Schema.
+func (c *Codec) UnmarshalHeaders(data []byte, headers map[string]string, v interface{}) error {
+	schema, err := c.readerFor(headers[SchemaIdHeader])
+	if err != nil {
+		return err
+	}
+	return avro.Unmarshal(schema, data, v)
+}
+
+// readerFor returns the schema to read payloads written with schema id.
+func (c *Codec) readerFor(id string) (avro.Schema, error) {
+	if id == "" || id == c.SchemaId {
+		return c.Schema, nil
+	}
+	if c.Registry == nil {
+		return nil, fmt.Errorf("avrocodec: no registry to look up schema %q", id)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if s, ok := c.resolved[id]; ok {
+		return s, nil
+	}
+	writer, err := c.Registry.Schema(id)
+	if err != nil {
+		return nil, fmt.Errorf("avrocodec: looking up schema %q: %w", id, err)
+	}
+	s, err := avro.NewSchemaCompatibility().Resolve(c.Schema, writer)
+	if err != nil {
+		return nil, fmt.Errorf("avrocodec: schema %q is incompatible: %w", id, err)
+	}
+	if c.resolved == nil {
+		c.resolved = map[string]avro.Schema{}
+	}
+	c.resolved[id] = s
+	return s, nil
+}
@@ -0,0 +1,86 @@
+package avrocodec_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/hamba/avro/v2"
+	"github.com/iron-io/iron_go3/irontest"
+	"github.com/iron-io/iron_go3/mq"
+	"github.com/iron-io/iron_go3/mq/avrocodec"
+	. "github.com/jeffh/go.bdd"
+)
+
+type orderV1 struct {
+	Id   int64  `avro:"id"`
+	Item string `avro:"item"`
+}
+
+type orderV2 struct {
+	Id       int64  `avro:"id"`
+	Item     string `avro:"item"`
+	Quantity int    `avro:"quantity"`
+}
+
+var (
+	schemaV1 = avro.MustParse(`{"type": "record", "name": "Order", "fields": [
+		{"name": "id", "type": "long"},
+		{"name": "item", "type": "string"}
+	]}`)
+	schemaV2 = avro.MustParse(`{"type": "record", "name": "Order", "fields": [
+		{"name": "id", "type": "long"},
+		{"name": "item", "type": "string"},
+		{"name": "quantity", "type": "int", "default": 1}
+	]}`)
+)
+
+func TestAvro(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("avro payloads", func() {
+		srv := irontest.NewServer()
+		defer srv.Close()
+		q := mq.Queue{Settings: srv.Settings("iron_mq"), Name: "orders"}
+		p := mq.NewProducer(q, "p")
+		v1 := avrocodec.New(schemaV1, "1", nil)
+
+		lookups := 0
+		registry := avrocodec.RegistryFunc(func(id string) (avro.Schema, error) {
+			lookups++
+			if id == "1" {
+				return schemaV1, nil
+			}
+			return nil, errors.New("unknown schema")
+		})
+		v2 := avrocodec.New(schemaV2, "2", registry)
+
+		It("records the schema id", func() {
+			_, err := mq.PushAs(p, v1, orderV1{Id: 7, Item: "book"}, nil)
+			Expect(err, ToBeNil)
+			e, _ := mq.OpenEnvelope(srv.MQ.Messages("orders")[0])
+			Expect(e.ContentType, ToEqual, avrocodec.ContentType)
+			Expect(e.Headers[avrocodec.SchemaIdHeader], ToEqual, "1")
+
+			o, err := mq.DecodeAs[orderV1](e, v1)
+			Expect(err, ToBeNil)
+			Expect(o, ToEqual, orderV1{Id: 7, Item: "book"})
+		})
+
+		It("resolves writer schemas through the registry", func() {
+			e, _ := mq.OpenEnvelope(srv.MQ.Messages("orders")[0])
+			o, err := mq.DecodeAs[orderV2](e, v2)
+			Expect(err, ToBeNil)
+			Expect(o, ToEqual, orderV2{Id: 7, Item: "book", Quantity: 1})
+			mq.DecodeAs[orderV2](e, v2)
+			Expect(lookups, ToEqual, 1)
+		})
+
+		It("fails on unknown schemas", func() {
+			e := mq.Envelope{ContentType: avrocodec.ContentType, Headers: map[string]string{avrocodec.SchemaIdHeader: "9"}}
+			_, err := mq.DecodeAs[orderV2](e, v2)
+			Expect(err, ToNotBeNil)
+			_, err = mq.DecodeAs[orderV1](e, v1)
+			Expect(err, ToNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,107 @@
+package mq
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// A BatchHandler processes the deliveries of a reservation together, e.g.
+// to insert them into a database at once. A consumer using one hands it
+// whatever a reservation returned, up to the consumer's BatchSize.
+//
+// The handler may settle deliveries one by one. Those it doesn't settle
+// are all acked, with a single request, if it returns nil and all nacked
+// without delay if it returns an error or panics.
+type BatchHandler interface {
+	HandleBatch(ctx context.Context, ds []*Delivery) error
+}
+
+// BatchHandlerFunc adapts a function to a BatchHandler.
+type BatchHandlerFunc func(ctx context.Context, ds []*Delivery) error
+
+func (f BatchHandlerFunc) HandleBatch(ctx context.Context, ds []*Delivery) error { return f(ctx, ds) }
+
+// NewBatchConsumer returns a Consumer of q handing batches of up to size
+// deliveries to h.
+func NewBatchConsumer(q Queue, h BatchHandler, size int) *Consumer {
+	return &Consumer{Queue: q, Batch: h, BatchSize: size}
+}
+
+// deliverBatch hands msgs to the batch handler and settles the deliveries
+// it didn't.
+func (c *Consumer) deliverBatch(ctx context.Context, msgs []Message) {
+	ds := make([]*Delivery, 0, len(msgs))
+	for _, msg := range msgs {
+		if d := c.delivery(msg); d != nil {
+			ds = append(ds, d)
+		}
+	}
+	if len(ds) == 0 {
+		return
+	}
+
+	for _, d := range ds {
+		var done func()
+		ctx, done = c.Watchdog.track(ctx, d, c.timeout())
+		defer done()
+	}
+	start := time.Now()
+	err := handleBatch(ctx, c.Batch, ds)
+	if err != nil {
+		for _, d := range ds {
+			c.settle(d, err)
+		}
+	} else if err := c.ackAll(ds); err != nil {
+		c.report(err)
+	}
+	took := time.Since(start)
+	for _, d := range ds {
+		c.observe(d, took, err)
+	}
+}
+
+// ackAll acks the unsettled deliveries of ds with a single request.
+func (c *Consumer) ackAll(ds []*Delivery) error {
+	var acked []*Delivery
+	var msgs []Message
+	for _, d := range ds {
+		if atomic.CompareAndSwapInt32(&d.settled, 0, 1) {
+			d.outcome.Store(OutcomeAcked)
+			acked = append(acked, d)
+			msgs = append(msgs, d.messages()...)
+		}
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+	if err := c.Queue.DeleteReservedMessages(msgs); err != nil {
+		for _, d := range acked {
+			d.outcome.Store("")
+			atomic.StoreInt32(&d.settled, 0)
+		}
+		return fmt.Errorf("mq: acking %d messages: %w", len(msgs), err)
+	}
+	for _, d := range acked {
+		c.forget(d)
+	}
+	return nil
+}
+
+// handleBatch calls h, turning panics into errors.
+func handleBatch(ctx context.Context, h BatchHandler, ds []*Delivery) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("mq: batch handler panicked: %v", r)
+		}
+	}()
+	return h.HandleBatch(ctx, ds)
+}
+
+func (c *Consumer) batchSize() int {
+	if c.BatchSize > 0 {
+		return min(c.BatchSize, MaxReserve)
+	}
+	return MaxReserve
+}
@@ -0,0 +1,90 @@
+package mq
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iron-io/iron_go3/irontest"
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestBatch(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("batch consumers", func() {
+		srv := irontest.NewServer()
+		defer srv.Close()
+		q := Queue{Settings: srv.Settings("iron_mq"), Name: "rows"}
+
+		It("acks whole batches with one request", func() {
+			q.PushStrings("a", "b", "c")
+			var got []string
+			c := NewBatchConsumer(q, BatchHandlerFunc(func(ctx context.Context, ds []*Delivery) error {
+				for _, d := range ds {
+					got = append(got, d.Body)
+				}
+				return nil
+			}), 10)
+			before := srv.Requests()
+			n, err := c.RunOnce(context.Background(), 10)
+			Expect(err, ToBeNil)
+			Expect(n, ToEqual, 3)
+			Expect(got, ToDeepEqual, []string{"a", "b", "c"})
+			Expect(len(srv.MQ.Messages("rows")), ToEqual, 0)
+			Expect(srv.Requests()-before, ToEqual, 2) // reserve and delete
+		})
+
+		It("nacks whole batches the handler fails", func() {
+			q.PushStrings("a", "b")
+			c := NewBatchConsumer(q, BatchHandlerFunc(func(ctx context.Context, ds []*Delivery) error {
+				return errors.New("db down")
+			}), 10)
+			c.RunOnce(context.Background(), 10)
+			msgs, _ := q.ReserveWith(ReserveOptions{N: 10})
+			Expect(len(msgs), ToEqual, 2)
+			q.Clear()
+		})
+
+		It("lets the handler settle deliveries itself", func() {
+			q.PushStrings("good", "bad")
+			c := NewBatchConsumer(q, BatchHandlerFunc(func(ctx context.Context, ds []*Delivery) error {
+				for _, d := range ds {
+					if d.Body == "bad" {
+						d.Nack(time.Minute)
+					}
+				}
+				return nil
+			}), 10)
+			c.RunOnce(context.Background(), 10)
+			Expect(srv.MQ.Messages("rows"), ToDeepEqual, []string{"bad"})
+			msgs, _ := q.ReserveWith(ReserveOptions{})
+			Expect(len(msgs), ToEqual, 0)
+			q.Clear()
+		})
+
+		It("runs batches of at most BatchSize", func() {
+			for i := 0; i < 5; i++ {
+				q.PushString("m")
+			}
+			var mu sync.Mutex
+			var sizes []int
+			ctx, cancel := context.WithCancel(context.Background())
+			c := NewBatchConsumer(q, BatchHandlerFunc(func(ctx context.Context, ds []*Delivery) error {
+				mu.Lock()
+				defer mu.Unlock()
+				sizes = append(sizes, len(ds))
+				if len(srv.MQ.Messages("rows")) == len(ds) {
+					cancel()
+				}
+				return nil
+			}), 2)
+			c.Wait = 10 * time.Millisecond
+			c.Run(ctx)
+			Expect(sizes, ToDeepEqual, []int{2, 2, 1})
+			Expect(len(srv.MQ.Messages("rows")), ToEqual, 0)
+		})
+	})
+}
@@ -0,0 +1,203 @@
+package mq
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// The envelope headers of chunk messages, see Producer.Chunked. A chunk
+// carries the id of the message it is part of, its index from 0, and the
+// number of chunks of the message.
+const (
+	ChunkIdHeader    = "chunk-id"
+	ChunkIndexHeader = "chunk-index"
+	ChunkCountHeader = "chunk-count"
+)
+
+// pushChunks splits the encoded envelope e into chunk messages that fit
+// the queue's message size, and pushes them together, so consumers are
+// likely to reserve them together. It returns the id of the first chunk.
+func (p *Producer) pushChunks(opts PushOptions, e Envelope) (string, error) {
+	var buf [8]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return "", err
+	}
+	id := hex.EncodeToString(buf[:])
+	chunk := func(i, n int, payload []byte) (string, error) {
+		c := e
+		c.Headers = make(map[string]string, len(e.Headers)+3)
+		for k, v := range e.Headers {
+			c.Headers[k] = v
+		}
+		c.Headers[ChunkIdHeader] = id
+		c.Headers[ChunkIndexHeader] = strconv.Itoa(i)
+		c.Headers[ChunkCountHeader] = strconv.Itoa(n)
+		c.Payload = payload
+		return c.Encode()
+	}
+
+	// the payload is base64 encoded, 3 bytes in 4
+	empty, err := chunk(999999, 999999, nil)
+	if err != nil {
+		return "", err
+	}
+	room := (p.Queue.Limits.messageSize() - len(empty)) / 4 * 3
+	if room <= 0 {
+		return "", fmt.Errorf("mq: pushing to %s: the envelope's metadata leaves no room for chunks", p.Queue.Name)
+	}
+	n := (len(e.Payload) + room - 1) / room
+	msgs := make([]Message, n)
+	for i := range msgs {
+		body, err := chunk(i, n, e.Payload[i*room:min((i+1)*room, len(e.Payload))])
+		if err != nil {
+			return "", err
+		}
+		msgs[i] = Message{Body: body, Delay: int64(seconds(opts.Delay))}
+	}
+
+	var first string
+	for start := 0; start < n; start += p.Queue.Limits.batchSize() {
+		ids, err := p.Queue.PushMessages(msgs[start:min(start+p.Queue.Limits.batchSize(), n)]...)
+		if err != nil {
+			return "", fmt.Errorf("mq: pushing the chunks of %s to %s: %w", id, p.Queue.Name, err)
+		}
+		if first == "" && len(ids) > 0 {
+			first = ids[0]
+		}
+	}
+	return first, nil
+}
+
+// chunkGroups holds the chunks of the messages a Consumer is reassembling,
+// by chunk id.
+type chunkGroups struct {
+	mu     sync.Mutex
+	groups map[string]*chunkGroup
+}
+
+type chunkGroup struct {
+	parts []*Delivery
+	got   int
+	since time.Time
+}
+
+// assemble adds the chunk d to its message, and returns the message once
+// all its chunks arrived, nil until then. The chunks stay reserved
+// meanwhile. Messages whose first chunk arrived longer than the
+// reservation timeout ago are given up on, their reservations expired and
+// their chunks are delivered again.
+func (c *Consumer) assemble(d *Delivery) (*Delivery, error) {
+	id := d.Envelope.Headers[ChunkIdHeader]
+	i, ierr := strconv.Atoi(d.Envelope.Headers[ChunkIndexHeader])
+	n, nerr := strconv.Atoi(d.Envelope.Headers[ChunkCountHeader])
+	if ierr != nil || nerr != nil || i < 0 || i >= n {
+		return nil, fmt.Errorf("mq: %s is not a valid chunk of %s", d.Id, id)
+	}
+
+	g := &c.chunks
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := time.Now()
+	for k, group := range g.groups {
+		if now.Sub(group.since) > c.timeout() {
+			delete(g.groups, k)
+		}
+	}
+	if g.groups == nil {
+		g.groups = map[string]*chunkGroup{}
+	}
+	group, ok := g.groups[id]
+	if !ok {
+		group = &chunkGroup{parts: make([]*Delivery, n), since: now}
+		g.groups[id] = group
+	}
+	if len(group.parts) != n {
+		return nil, fmt.Errorf("mq: chunk %s of %s counts %d chunks, not %d", d.Id, id, n, len(group.parts))
+	}
+	if group.parts[i] == nil {
+		group.got++
+	}
+	group.parts[i] = d // a redelivered chunk has a new reservation
+	if group.got < n {
+		return nil, nil
+	}
+	delete(g.groups, id)
+
+	first := group.parts[0]
+	a := &Delivery{Message: first.Message, Envelope: first.Envelope, Enveloped: true, consumer: c, parts: group.parts}
+	a.Envelope.Headers = nil
+	for k, v := range first.Envelope.Headers {
+		if k == ChunkIdHeader || k == ChunkIndexHeader || k == ChunkCountHeader {
+			continue
+		}
+		if a.Envelope.Headers == nil {
+			a.Envelope.Headers = map[string]string{}
+		}
+		a.Envelope.Headers[k] = v
+	}
+	var payload []byte
+	for _, p := range group.parts {
+		payload = append(payload, p.Envelope.Payload...)
+	}
+	a.Envelope.Payload = payload
+	if body, err := a.Envelope.Encode(); err == nil {
+		a.Body = body
+	}
+	return a, nil
+}
+
+// messages returns the messages of d, its chunks if it was reassembled.
+func (d *Delivery) messages() []Message {
+	if d.parts == nil {
+		return []Message{d.Message}
+	}
+	msgs := make([]Message, len(d.parts))
+	for i, p := range d.parts {
+		msgs[i] = p.Message
+	}
+	return msgs
+}
+
+// delete deletes the messages of d.
+func (d *Delivery) delete() error {
+	if d.parts == nil {
+		return d.Delete()
+	}
+	msgs := d.messages()
+	for start := 0; start < len(msgs); start += MaxReserve {
+		if err := d.q.DeleteReservedMessages(msgs[start:min(start+MaxReserve, len(msgs))]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// release releases the messages of d after delay seconds.
+func (d *Delivery) release(delay int64) error {
+	var errs []error
+	for _, m := range d.messages() {
+		if err := m.Release(delay); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// bodies returns the bodies of the messages of d.
+func (d *Delivery) bodies() []string {
+	var bodies []string
+	for _, m := range d.messages() {
+		bodies = append(bodies, m.Body)
+	}
+	return bodies
+}
+
+// isChunk tells whether e is a chunk of a message.
+func isChunk(e Envelope) bool {
+	return e.Headers[ChunkIdHeader] != ""
+}
@@ -0,0 +1,81 @@
+package mq
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/iron-io/iron_go3/irontest"
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestChunks(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("chunked messages", func() {
+		srv := irontest.NewServer()
+		defer srv.Close()
+		q := Queue{Settings: srv.Settings("iron_mq"), Name: "large"}
+		p := NewProducer(q, "p1")
+		p.Chunked = true
+		p.Headers = map[string]string{"kind": "report"}
+		payload := bytes.Repeat([]byte("0123456789"), 20000)
+
+		var got []Envelope
+		handlerErr := error(nil)
+		c := NewConsumer(q, HandlerFunc(func(ctx context.Context, d *Delivery) error {
+			got = append(got, d.Envelope)
+			return handlerErr
+		}))
+
+		It("splits large payloads into messages that fit", func() {
+			_, err := NewProducer(q, "p2").Push(payload, nil)
+			Expect(err, ToNotBeNil)
+
+			_, err = p.Push(payload, nil)
+			Expect(err, ToBeNil)
+			bodies := srv.MQ.Messages("large")
+			Expect(len(bodies), ToEqual, 5)
+			for _, b := range bodies {
+				Expect(len(b) <= MaxMessageSize, ToBeTrue)
+			}
+		})
+
+		It("reassembles them", func() {
+			n, _ := c.RunOnce(context.Background(), 10)
+			Expect(n, ToEqual, 5)
+			Expect(len(got), ToEqual, 1)
+			Expect(bytes.Equal(got[0].Payload, payload), ToBeTrue)
+			Expect(got[0].Headers, ToDeepEqual, map[string]string{"kind": "report"})
+			Expect(len(srv.MQ.Messages("large")), ToEqual, 0)
+		})
+
+		It("reassembles chunks reserved one by one, out of order", func() {
+			got = nil
+			p.Push(payload, nil)
+			bodies := srv.MQ.Messages("large")
+			q.Clear()
+			for i := len(bodies) - 1; i >= 0; i-- {
+				q.PushString(bodies[i])
+			}
+			q.PushString("small")
+			for i := 0; i < len(bodies)+1; i++ {
+				c.RunOnce(context.Background(), 1)
+			}
+			Expect(len(got), ToEqual, 2)
+			Expect(bytes.Equal(got[0].Payload, payload), ToBeTrue)
+			Expect(string(got[1].Payload), ToEqual, "small")
+			Expect(len(srv.MQ.Messages("large")), ToEqual, 0)
+		})
+
+		It("releases all chunks when the handler fails", func() {
+			got, handlerErr = nil, errors.New("no")
+			p.Push(payload, nil)
+			c.RunOnce(context.Background(), 10)
+			Expect(len(got), ToEqual, 1)
+			msgs, _ := q.ReserveWith(ReserveOptions{N: 10})
+			Expect(len(msgs), ToEqual, 5)
+		})
+	})
+}
@@ -0,0 +1,354 @@
+package mq
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"strings"
+	"time"
+)
+
+// CloudEventsContentType is the media type of CloudEvents in structured
+// JSON mode.
+const CloudEventsContentType = "application/cloudevents+json"
+
+// An Event is a CloudEvents 1.0 event. Events are pushed in the structured
+// JSON mode, the message body is the event itself rather than an Envelope,
+// so they interoperate with any CloudEvents consumer.
+type Event struct {
+	// SpecVersion is "1.0", set by PushEvent if empty.
+	SpecVersion string
+	// Id identifies the event within Source, PushEvent generates one if
+	// empty.
+	Id string
+	// Source and Type are required, e.g. "/orders-api" and
+	// "com.example.order.created".
+	Source string
+	Type   string
+	// Subject is what the event is about within Source, e.g. an order id.
+	Subject string
+	// Time is when the event happened, PushEvent sets the current time if
+	// it is zero.
+	Time            time.Time
+	DataContentType string
+	DataSchema      string
+	// Data is the payload. JSON data, of no or a JSON DataContentType, is
+	// embedded as is, other data is base64 encoded.
+	Data []byte
+	// Extensions are the extension attributes, e.g. "traceparent".
+	Extensions map[string]interface{}
+}
+
+// NewEvent returns an event of typ from source with v encoded as its JSON
+// data.
+func NewEvent(source, typ string, v interface{}) (Event, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return Event{}, fmt.Errorf("mq: encoding %s event data: %w", typ, err)
+	}
+	return Event{Source: source, Type: typ, DataContentType: "application/json", Data: data}, nil
+}
+
+// DecodeData decodes the event's JSON data into v.
+func (e Event) DecodeData(v interface{}) error {
+	if !e.jsonData() {
+		return fmt.Errorf("mq: cannot decode %s event data as JSON", e.DataContentType)
+	}
+	return json.Unmarshal(e.Data, v)
+}
+
+func (e Event) jsonData() bool {
+	if e.DataContentType == "" {
+		return true
+	}
+	mt, _, err := mime.ParseMediaType(e.DataContentType)
+	return err == nil && (mt == "application/json" || mt == "text/json" || strings.HasSuffix(mt, "+json"))
+}
+
+// Validate checks that the required attributes are set.
+func (e Event) Validate() error {
+	var missing []string
+	for _, a := range []struct{ name, value string }{
+		{"specversion", e.SpecVersion}, {"id", e.Id}, {"source", e.Source}, {"type", e.Type},
+	} {
+		if a.value == "" {
+			missing = append(missing, a.name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("mq: event is missing %s", strings.Join(missing, ", "))
+	}
+	if e.SpecVersion != "1.0" {
+		return fmt.Errorf("mq: unsupported CloudEvents version %q", e.SpecVersion)
+	}
+	return nil
+}
+
+// eventAttributes are the attributes defined by the spec, the others are
+// extensions.
+var eventAttributes = map[string]bool{
+	"specversion": true, "id": true, "source": true, "type": true, "subject": true, "time": true,
+	"datacontenttype": true, "dataschema": true, "data": true, "data_base64": true,
+}
+
+type eventJSON struct {
+	SpecVersion     string          `json:"specversion"`
+	Id              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Subject         string          `json:"subject,omitempty"`
+	Time            *time.Time      `json:"time,omitempty"`
+	DataContentType string          `json:"datacontenttype,omitempty"`
+	DataSchema      string          `json:"dataschema,omitempty"`
+	Data            json.RawMessage `json:"data,omitempty"`
+	DataBase64      []byte          `json:"data_base64,omitempty"`
+}
+
+func (e Event) MarshalJSON() ([]byte, error) {
+	j := eventJSON{
+		SpecVersion:     e.SpecVersion,
+		Id:              e.Id,
+		Source:          e.Source,
+		Type:            e.Type,
+		Subject:         e.Subject,
+		DataContentType: e.DataContentType,
+		DataSchema:      e.DataSchema,
+	}
+	if !e.Time.IsZero() {
+		t := e.Time.UTC()
+		j.Time = &t
+	}
+	if len(e.Data) > 0 {
+		if e.jsonData() && json.Valid(e.Data) {
+			j.Data = e.Data
+		} else {
+			j.DataBase64 = e.Data
+		}
+	}
+	b, err := json.Marshal(j)
+	if err != nil || len(e.Extensions) == 0 {
+		return b, err
+	}
+
+	attrs := map[string]json.RawMessage{}
+	if err := json.Unmarshal(b, &attrs); err != nil {
+		return nil, err
+	}
+	for name, v := range e.Extensions {
+		if eventAttributes[name] {
+			return nil, fmt.Errorf("mq: extension %q shadows an event attribute", name)
+		}
+		if attrs[name], err = json.Marshal(v); err != nil {
+			return nil, fmt.Errorf("mq: encoding extension %q: %w", name, err)
+		}
+	}
+	return json.Marshal(attrs)
+}
+
+func (e *Event) UnmarshalJSON(b []byte) error {
+	var j eventJSON
+	if err := json.Unmarshal(b, &j); err != nil {
+		return err
+	}
+	*e = Event{
+		SpecVersion:     j.SpecVersion,
+		Id:              j.Id,
+		Source:          j.Source,
+		Type:            j.Type,
+		Subject:         j.Subject,
+		DataContentType: j.DataContentType,
+		DataSchema:      j.DataSchema,
+		Data:            j.DataBase64,
+	}
+	if j.Time != nil {
+		e.Time = *j.Time
+	}
+	if len(j.Data) > 0 {
+		var s string
+		if !e.jsonData() && json.Unmarshal(j.Data, &s) == nil {
+			e.Data = []byte(s) // e.g. text/plain data
+		} else {
+			e.Data = j.Data
+		}
+	}
+
+	var attrs map[string]interface{}
+	if err := json.Unmarshal(b, &attrs); err != nil {
+		return err
+	}
+	for name, v := range attrs {
+		if !eventAttributes[name] {
+			if e.Extensions == nil {
+				e.Extensions = map[string]interface{}{}
+			}
+			e.Extensions[name] = v
+		}
+	}
+	return nil
+}
+
+// ParseEvent parses a message body holding a CloudEvent in structured
+// JSON mode.
+func ParseEvent(body string) (Event, error) {
+	var e Event
+	if err := json.Unmarshal([]byte(body), &e); err != nil {
+		return Event{}, fmt.Errorf("mq: parsing event: %w", err)
+	}
+	if err := e.Validate(); err != nil {
+		return Event{}, err
+	}
+	return e, nil
+}
+
+// Event parses the message's body as a CloudEvent, see ParseEvent.
+func (m Message) Event() (Event, error) {
+	return ParseEvent(m.Body)
+}
+
+// PushEvent validates e, filling in SpecVersion, Id and Time if unset, and
+// enqueues it. It returns the message's id.
+func (q Queue) PushEvent(e Event) (id string, err error) {
+	return q.PushEventWith(PushOptions{}, e)
+}
+
+// PushEventWith is PushEvent with options.
+func (q Queue) PushEventWith(opts PushOptions, e Event) (id string, err error) {
+	if e.SpecVersion == "" {
+		e.SpecVersion = "1.0"
+	}
+	if e.Id == "" {
+		if e.Id, err = eventId(); err != nil {
+			return "", err
+		}
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	if err := e.Validate(); err != nil {
+		return "", err
+	}
+	body, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+	ids, err := q.PushWith(opts, string(body))
+	if err != nil {
+		return "", err
+	} else if len(ids) < 1 {
+		return "", fmt.Errorf("didn't receive message ID for pushing message to %s", q.Name)
+	}
+	return ids[0], nil
+}
+
+func eventId() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b[:]), nil
+}
+
+// An EventHandler processes deliveries holding CloudEvents.
+type EventHandler interface {
+	HandleEvent(ctx context.Context, d *Delivery, e Event) error
+}
+
+// EventHandlerFunc adapts a function to an EventHandler.
+type EventHandlerFunc func(ctx context.Context, d *Delivery, e Event) error
+
+func (f EventHandlerFunc) HandleEvent(ctx context.Context, d *Delivery, e Event) error {
+	return f(ctx, d, e)
+}
+
+// ErrNoRoute is reported for events no route of an EventMux matches.
+var ErrNoRoute = errors.New("mq: no route for event")
+
+// An EventMux is a Handler routing CloudEvents by type:
+//
+//	mux := mq.NewEventMux()
+//	mux.Route("com.example.order.created", created)
+//	mux.Route("com.example.order.*", other)
+//	c := mq.NewConsumer(q, mux)
+//
+// Routes ending in "*" match types by prefix, the longest matching route
+// wins. Deliveries that aren't valid events, or that no route matches and
+// there is no NotFound handler for, are terminated and reported to the
+// consumer's OnError.
+type EventMux struct {
+	routes map[string]EventHandler
+	// NotFound handles the events no route matches.
+	NotFound EventHandler
+}
+
+// NewEventMux returns an EventMux without routes.
+func NewEventMux() *EventMux {
+	return &EventMux{routes: map[string]EventHandler{}}
+}
+
+// Route sends events of type typ to h.
+func (m *EventMux) Route(typ string, h EventHandler) {
+	m.routes[typ] = h
+}
+
+// RouteFunc sends events of type typ to f.
+func (m *EventMux) RouteFunc(typ string, f func(ctx context.Context, d *Delivery, e Event) error) {
+	m.Route(typ, EventHandlerFunc(f))
+}
+
+func (m *EventMux) Handle(ctx context.Context, d *Delivery) error {
+	e, err := d.Event()
+	if err != nil {
+		return m.term(d, fmt.Errorf("mq: %s is not an event: %w", d.Id, err))
+	}
+	h := m.match(e.Type)
+	if h == nil {
+		return m.term(d, fmt.Errorf("%w of type %s from %s", ErrNoRoute, e.Type, e.Source))
+	}
+	return h.HandleEvent(ctx, d, e)
+}
+
+func (m *EventMux) match(typ string) EventHandler {
+	if h, ok := m.routes[typ]; ok {
+		return h
+	}
+	var best EventHandler
+	n := -1
+	for route, h := range m.routes {
+		if prefix, ok := strings.CutSuffix(route, "*"); ok && strings.HasPrefix(typ, prefix) && len(prefix) > n {
+			best, n = h, len(prefix)
+		}
+	}
+	if best == nil {
+		return m.NotFound
+	}
+	return best
+}
+
+func (m *EventMux) term(d *Delivery, err error) error {
+	if terr := d.Term(); terr != nil {
+		return terr
+	}
+	d.consumer.report(err)
+	return nil
+}
+
+// MatchEventType returns a Filter picking deliveries holding CloudEvents
+// of one of types.
+func MatchEventType(types ...string) func(*Delivery) bool {
+	return func(d *Delivery) bool {
+		e, err := d.Event()
+		if err != nil {
+			return false
+		}
+		for _, t := range types {
+			if e.Type == t {
+				return true
+			}
+		}
+		return false
+	}
+}
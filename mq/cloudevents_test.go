@@ -0,0 +1,123 @@
+package mq
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/iron-io/iron_go3/irontest"
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestCloudEvents(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("events", func() {
+		It("are encoded in structured mode", func() {
+			e := Event{
+				SpecVersion: "1.0", Id: "1", Source: "/orders", Type: "order.created",
+				Time:       time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+				Data:       []byte(`{"id":42}`),
+				Extensions: map[string]interface{}{"traceparent": "00-abc"},
+			}
+			b, err := json.Marshal(e)
+			Expect(err, ToBeNil)
+			Expect(string(b), ToEqual, `{"data":{"id":42},"id":"1","source":"/orders","specversion":"1.0","time":"2020-01-02T03:04:05Z","traceparent":"00-abc","type":"order.created"}`)
+
+			parsed, err := ParseEvent(string(b))
+			Expect(err, ToBeNil)
+			Expect(parsed, ToDeepEqual, e)
+		})
+
+		It("base64 encodes binary data", func() {
+			e := Event{SpecVersion: "1.0", Id: "1", Source: "s", Type: "t", DataContentType: "application/octet-stream", Data: []byte{0, 1}}
+			b, _ := json.Marshal(e)
+			Expect(string(b), ToEqual, `{"specversion":"1.0","id":"1","source":"s","type":"t","datacontenttype":"application/octet-stream","data_base64":"AAE="}`)
+			parsed, _ := ParseEvent(string(b))
+			Expect(parsed.Data, ToDeepEqual, []byte{0, 1})
+		})
+
+		It("reads text data", func() {
+			e, err := ParseEvent(`{"specversion":"1.0","id":"1","source":"s","type":"t","datacontenttype":"text/plain","data":"hi"}`)
+			Expect(err, ToBeNil)
+			Expect(string(e.Data), ToEqual, "hi")
+		})
+
+		It("requires the core attributes", func() {
+			_, err := ParseEvent(`{"specversion":"1.0","id":"1"}`)
+			Expect(err.Error(), ToEqual, "mq: event is missing source, type")
+			_, err = ParseEvent(`{"id":"1"}`)
+			Expect(err, ToNotBeNil)
+		})
+	})
+
+	Describe("pushing and routing events", func() {
+		srv := irontest.NewServer()
+		defer srv.Close()
+		q := Queue{Settings: srv.Settings("iron_mq"), Name: "events"}
+		dead := Queue{Settings: q.Settings, Name: "events-dead"}
+
+		It("fills in defaults", func() {
+			e, _ := NewEvent("/orders", "order.created", map[string]int{"id": 42})
+			e.Subject = "42"
+			_, err := q.PushEvent(e)
+			Expect(err, ToBeNil)
+
+			msgs, _ := q.PeekN(1)
+			got, err := msgs[0].Event()
+			Expect(err, ToBeNil)
+			Expect(got.SpecVersion, ToEqual, "1.0")
+			Expect(len(got.Id), ToEqual, 32)
+			Expect(got.Time.IsZero(), ToEqual, false)
+			Expect(got.Subject, ToEqual, "42")
+			var data struct{ Id int }
+			Expect(got.DecodeData(&data), ToBeNil)
+			Expect(data.Id, ToEqual, 42)
+
+			_, err = q.PushEvent(Event{Type: "t"})
+			Expect(err, ToNotBeNil)
+		})
+
+		It("routes by type", func() {
+			var routed []string
+			route := func(name string) EventHandlerFunc {
+				return func(ctx context.Context, d *Delivery, e Event) error {
+					routed = append(routed, name+":"+e.Type)
+					return nil
+				}
+			}
+			mux := NewEventMux()
+			mux.Route("order.created", route("created"))
+			mux.Route("order.*", route("order"))
+			mux.Route("order.line.*", route("line"))
+
+			var reported []error
+			c := NewConsumer(q, mux)
+			c.DeadLetter = &dead
+			c.OnError = func(err error) { reported = append(reported, err) }
+
+			q.PushEvent(Event{Source: "s", Type: "order.line.added"})
+			q.PushEvent(Event{Source: "s", Type: "order.paid"})
+			q.PushEvent(Event{Source: "s", Type: "user.created"})
+			q.PushString("not an event")
+			c.RunOnce(context.Background(), 10)
+
+			Expect(len(routed), ToEqual, 3)
+			Expect(slices.Contains(routed, "created:order.created"), ToBeTrue)
+			Expect(slices.Contains(routed, "line:order.line.added"), ToBeTrue)
+			Expect(slices.Contains(routed, "order:order.paid"), ToBeTrue)
+			Expect(len(reported), ToEqual, 2)
+			Expect(len(srv.MQ.Messages("events-dead")), ToEqual, 2)
+			Expect(errors.Is(reported[0], ErrNoRoute) || errors.Is(reported[1], ErrNoRoute), ToBeTrue)
+		})
+
+		It("filters by type", func() {
+			d := &Delivery{Message: Message{Body: `{"specversion":"1.0","id":"1","source":"s","type":"a"}`}}
+			Expect(MatchEventType("b", "a")(d), ToBeTrue)
+			Expect(MatchEventType("b")(d), ToEqual, false)
+		})
+	})
+}
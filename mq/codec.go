@@ -0,0 +1,104 @@
+package mq
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+)
+
+// A Codec encodes values into payloads of its content type. JSON is built
+// in, the protocodec and avrocodec packages provide protobuf and Avro.
+type Codec interface {
+	// ContentType is the media type of the payloads, recorded in the
+	// envelopes of pushed values.
+	ContentType() string
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// A HeaderCodec is a Codec that records headers in the envelopes of the
+// values it encodes and reads them back when decoding, e.g. the id of the
+// schema a payload was written with.
+type HeaderCodec interface {
+	Codec
+	// Headers are set on the envelopes of pushed values, taking precedence
+	// over other headers.
+	Headers() map[string]string
+	// UnmarshalHeaders is Unmarshal given the envelope's headers.
+	UnmarshalHeaders(data []byte, headers map[string]string, v interface{}) error
+}
+
+// JSON encodes values with encoding/json.
+var JSON Codec = jsonCodec{}
+
+type jsonCodec struct{}
+
+func (jsonCodec) ContentType() string                        { return "application/json" }
+func (jsonCodec) Marshal(v interface{}) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+// PushAs encodes value with codec and pushes it in an envelope of p, with
+// the codec's content type.
+//
+//	id, err := mq.PushAs(p, mq.JSON, Order{Id: 42}, nil)
+func PushAs[T any](p *Producer, codec Codec, value T, headers map[string]string) (id string, err error) {
+	return PushAsWith(p, PushOptions{}, codec, value, headers)
+}
+
+// PushAsWith is PushAs with options.
+func PushAsWith[T any](p *Producer, opts PushOptions, codec Codec, value T, headers map[string]string) (id string, err error) {
+	payload, err := codec.Marshal(value)
+	if err != nil {
+		return "", fmt.Errorf("mq: encoding %T for %s: %w", value, p.Queue.Name, err)
+	}
+	e := p.Envelope(payload, headers)
+	e.ContentType = codec.ContentType()
+	if hc, ok := codec.(HeaderCodec); ok {
+		if h := hc.Headers(); len(h) > 0 {
+			if e.Headers == nil {
+				e.Headers = make(map[string]string, len(h))
+			}
+			for k, v := range h {
+				e.Headers[k] = v
+			}
+		}
+	}
+	return p.push(opts, e)
+}
+
+// Decode decodes e's payload into v with codec. Envelopes of another
+// content type are refused, those without one are decoded anyway.
+func (e Envelope) Decode(codec Codec, v interface{}) error {
+	if e.ContentType != "" && !sameMediaType(e.ContentType, codec.ContentType()) {
+		return fmt.Errorf("mq: cannot decode %s payload as %s", e.ContentType, codec.ContentType())
+	}
+	var err error
+	if hc, ok := codec.(HeaderCodec); ok {
+		err = hc.UnmarshalHeaders(e.Payload, e.Headers, v)
+	} else {
+		err = codec.Unmarshal(e.Payload, v)
+	}
+	if err != nil {
+		return fmt.Errorf("mq: decoding %s payload: %w", codec.ContentType(), err)
+	}
+	return nil
+}
+
+// DecodeAs decodes e's payload into a T with codec, see Envelope.Decode.
+//
+//	order, err := mq.DecodeAs[Order](d.Envelope, mq.JSON)
+func DecodeAs[T any](e Envelope, codec Codec) (T, error) {
+	var v T
+	err := e.Decode(codec, &v)
+	return v, err
+}
+
+// sameMediaType compares media types, ignoring their parameters.
+func sameMediaType(a, b string) bool {
+	ma, _, erra := mime.ParseMediaType(a)
+	mb, _, errb := mime.ParseMediaType(b)
+	if erra != nil || errb != nil {
+		return a == b
+	}
+	return ma == mb
+}
@@ -0,0 +1,57 @@
+package mq
+
+import (
+	"testing"
+
+	"github.com/iron-io/iron_go3/irontest"
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestCodec(t *testing.T) {
+	defer PrintSpecReport()
+
+	type order struct {
+		Id    int      `json:"id"`
+		Items []string `json:"items"`
+	}
+
+	Describe("typed payloads", func() {
+		srv := irontest.NewServer()
+		defer srv.Close()
+		q := Queue{Settings: srv.Settings("iron_mq"), Name: "orders"}
+		p := NewProducer(q, "p")
+
+		It("round-trips values", func() {
+			_, err := PushAs(p, JSON, order{Id: 1, Items: []string{"a"}}, map[string]string{"k": "v"})
+			Expect(err, ToBeNil)
+
+			msgs, _ := q.PopWith(PopOptions{})
+			e, ok := msgs[0].Open()
+			Expect(ok, ToBeTrue)
+			Expect(e.ContentType, ToEqual, "application/json")
+			Expect(e.Headers["k"], ToEqual, "v")
+			o, err := DecodeAs[order](e, JSON)
+			Expect(err, ToBeNil)
+			Expect(o, ToDeepEqual, order{Id: 1, Items: []string{"a"}})
+		})
+
+		It("decodes raw bodies", func() {
+			o, err := DecodeAs[order](Envelope{Payload: []byte(`{"id": 2}`)}, JSON)
+			Expect(err, ToBeNil)
+			Expect(o.Id, ToEqual, 2)
+		})
+
+		It("refuses other content types", func() {
+			_, err := DecodeAs[order](Envelope{ContentType: "text/plain", Payload: []byte(`{}`)}, JSON)
+			Expect(err.Error(), ToEqual, "mq: cannot decode text/plain payload as application/json")
+			_, err = DecodeAs[order](Envelope{ContentType: "application/json; charset=utf-8", Payload: []byte(`{}`)}, JSON)
+			Expect(err, ToBeNil)
+		})
+
+		It("doesn't push values it can't encode", func() {
+			_, err := PushAs(p, JSON, func() {}, nil)
+			Expect(err, ToNotBeNil)
+			Expect(len(srv.MQ.Messages("orders")), ToEqual, 0)
+		})
+	})
+}
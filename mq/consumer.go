@@ -0,0 +1,510 @@
+package mq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/iron-io/iron_go3/api"
+)
+
+// ErrSettled is returned when a Delivery is acked, nacked or terminated a
+// second time.
+var ErrSettled = errors.New("mq: delivery already settled")
+
+// A Delivery is a reserved message handed to a Handler. The handler
+// settles it with exactly one of Ack, Nack or Term. Until then the message
+// stays reserved, and is delivered again once its reservation times out.
+type Delivery struct {
+	Message
+	// Envelope is the opened body, see OpenEnvelope.
+	Envelope Envelope
+	// Enveloped tells whether the body was an envelope.
+	Enveloped bool
+
+	consumer *Consumer
+	settled  int32
+	nacked   bool
+	held     bool         // saved to the consumer's Reservations
+	outcome  atomic.Value // string, see DeliveryStats
+	parts    []*Delivery  // the chunks of a reassembled message
+}
+
+// Ack deletes the message, it has been processed.
+func (d *Delivery) Ack() error {
+	return d.settle(func() error {
+		d.outcome.Store(OutcomeAcked)
+		return d.delete()
+	})
+}
+
+// Nack releases the message to be delivered again after delay.
+func (d *Delivery) Nack(delay time.Duration) error {
+	return d.settle(func() error {
+		d.nacked = true
+		d.outcome.Store(OutcomeNacked)
+		return d.release(int64(seconds(delay)))
+	})
+}
+
+// Term gives up on the message: it is pushed to the consumer's DeadLetter
+// queue, if there is one, and deleted.
+func (d *Delivery) Term() error {
+	return d.settle(func() error {
+		d.outcome.Store(OutcomeTerminated)
+		if dl := d.consumer.DeadLetter; dl != nil {
+			return d.move(*dl, PushOptions{})
+		}
+		return d.delete()
+	})
+}
+
+// move pushes the message's body, or its chunks, to q and deletes the
+// message.
+func (d *Delivery) move(q Queue, opts PushOptions) error {
+	bodies := d.bodies()
+	for start := 0; start < len(bodies); start += q.Limits.batchSize() {
+		if _, err := q.PushWith(opts, bodies[start:min(start+q.Limits.batchSize(), len(bodies))]...); err != nil {
+			return fmt.Errorf("mq: moving %s to %s: %w", d.Id, q.Name, err)
+		}
+	}
+	return d.delete()
+}
+
+// Settled tells whether Ack, Nack or Term succeeded.
+func (d *Delivery) Settled() bool {
+	return atomic.LoadInt32(&d.settled) == 1
+}
+
+func (d *Delivery) settle(fn func() error) error {
+	if !atomic.CompareAndSwapInt32(&d.settled, 0, 1) {
+		return ErrSettled
+	}
+	if err := fn(); err != nil {
+		d.nacked = false
+		d.outcome.Store("")
+		atomic.StoreInt32(&d.settled, 0)
+		return err
+	}
+	d.consumer.forget(d)
+	return nil
+}
+
+// A Handler processes deliveries. Deliveries it doesn't settle are acked
+// if it returns nil and nacked without delay if it returns an error or
+// panics.
+type Handler interface {
+	Handle(ctx context.Context, d *Delivery) error
+}
+
+// HandlerFunc adapts a function to a Handler.
+type HandlerFunc func(ctx context.Context, d *Delivery) error
+
+func (f HandlerFunc) Handle(ctx context.Context, d *Delivery) error { return f(ctx, d) }
+
+// A Consumer reserves messages from Queue and hands them to Handler, giving
+// at-least-once processing: a message is only deleted once it is acked.
+type Consumer struct {
+	Queue   Queue
+	Handler Handler
+	// DeadLetter receives terminated messages, which are dropped if nil.
+	DeadLetter *Queue
+	// Concurrency is how many deliveries are handled at once, 1 by default.
+	Concurrency int
+	// Wait is how long each reservation long polls, MaxWait by default.
+	Wait time.Duration
+	// Timeout is how long messages stay reserved, the queue's
+	// message_timeout by default.
+	Timeout time.Duration
+	// OnError is told about failed reservations and settlements, which are
+	// otherwise only logged by the api client.
+	OnError func(error)
+
+	// Filter, if set, picks the deliveries Handler gets, e.g. with
+	// MatchHeader. The others are moved to Reroute if it is set, and
+	// released otherwise, so consumers sharing the queue can pick them up.
+	Filter func(*Delivery) bool
+	// Reroute receives the messages Filter rejects.
+	Reroute *Queue
+	// RejectDelay is how long rejected messages are released for. Without
+	// one, a consumer alone on a queue reserves them again right away.
+	RejectDelay time.Duration
+
+	// Validator, if set, checks the payloads of deliveries before Handler
+	// gets them. Invalid messages are reported to OnError as a
+	// *ValidationError and moved to Invalid, or DeadLetter if Invalid is
+	// nil, or dropped if both are.
+	Validator Validator
+	// Invalid receives the messages Validator refuses, and those
+	// Transforms fail to decode.
+	Invalid *Queue
+	// Transforms decode enveloped deliveries, in reverse order, before
+	// Filter and Validator see them, see Transform. Deliveries that fail to
+	// decode are reported to OnError and settled like invalid ones.
+	Transforms []Transform
+
+	// Batch, if set, handles deliveries in batches instead of Handler. Each
+	// batch holds the messages of one reservation, and Concurrency is then
+	// how many batches are handled at once.
+	Batch BatchHandler
+	// BatchSize is the most deliveries in a batch, MaxReserve by default.
+	BatchSize int
+
+	// Watchdog, if set, watches for deliveries handled for too long.
+	Watchdog *Watchdog
+	// OnEvent, if set, is told when Run starts and stops, and when
+	// reservations fail and recover, see ConsumerEvent.
+	OnEvent func(ConsumerEvent)
+	// Reservations, if set, persists the reservations of the deliveries
+	// being handled, see Recover. The chunks of messages being reassembled
+	// aren't persisted.
+	Reservations ReservationStore
+	// Metrics, if set, is told about every delivery handled.
+	Metrics Metrics
+	// Prefetch, if set, is how many messages Run keeps reserved ahead of
+	// the handlers, so they don't wait on a reservation between
+	// deliveries. The reservations of waiting messages are touched before
+	// they time out, and released when Run returns. Without a Timeout, Run
+	// reads the queue's message_timeout when it starts. It is ignored in
+	// batch mode.
+	Prefetch int
+
+	chunks chunkGroups
+}
+
+// NewConsumer returns a Consumer of q handing deliveries to h.
+func NewConsumer(q Queue, h Handler) *Consumer {
+	return &Consumer{Queue: q, Handler: h}
+}
+
+// Run consumes until ctx is done, then waits for the deliveries being
+// handled and returns. Failed reservations are retried with backoff,
+// except when the request itself is refused, e.g. for bad credentials.
+// With Reservations set, Run first recovers the reservations left over by
+// a previous run.
+func (c *Consumer) Run(ctx context.Context) (err error) {
+	c.emit(ConsumerEvent{Kind: ConsumerStarted})
+	defer func() { c.emit(ConsumerEvent{Kind: ConsumerStopped, Err: err}) }()
+	if _, err := c.Recover(); err != nil {
+		c.report(err)
+	}
+	return c.run(ctx)
+}
+
+func (c *Consumer) run(ctx context.Context) error {
+	if c.Prefetch > 0 && c.Batch == nil {
+		return c.runPrefetch(ctx)
+	}
+	slots := make(chan struct{}, c.concurrency())
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	var b reserveBackoff
+	for ctx.Err() == nil {
+		// wait for a free slot, then take all free ones
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil
+		}
+		n := 1
+	fill:
+		for c.Batch == nil && n < cap(slots) && n < MaxReserve {
+			select {
+			case slots <- struct{}{}:
+				n++
+			default:
+				break fill
+			}
+		}
+
+		size := n
+		if c.Batch != nil {
+			size = c.batchSize()
+		}
+		msgs, err := c.reserve(ctx, size)
+		used := len(msgs)
+		if c.Batch != nil {
+			used = min(used, 1) // a batch takes one slot
+		}
+		for i := used; i < n; i++ {
+			<-slots
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			if permanent(err) {
+				return err
+			}
+			c.failed(ctx, &b, err)
+			continue
+		}
+		c.recovered(&b)
+
+		if c.Batch != nil && len(msgs) > 0 {
+			wg.Add(1)
+			go func() {
+				defer func() { <-slots; wg.Done() }()
+				c.deliverBatch(ctx, msgs)
+			}()
+			continue
+		}
+		for i := range msgs {
+			wg.Add(1)
+			go func(msg Message) {
+				defer func() { <-slots; wg.Done() }()
+				c.deliver(ctx, msg)
+			}(msgs[i])
+		}
+	}
+	return nil
+}
+
+// reserveBackoff tracks the failed reservations of Run.
+type reserveBackoff struct {
+	delay    time.Duration
+	failures int
+	since    time.Time
+}
+
+// failed reports the failed reservation err and backs off.
+func (c *Consumer) failed(ctx context.Context, b *reserveBackoff, err error) {
+	c.report(err)
+	if b.failures++; b.failures == 1 {
+		b.since = time.Now()
+	}
+	b.delay = min(max(2*b.delay, 100*time.Millisecond), 30*time.Second)
+	c.emit(ConsumerEvent{Kind: ConsumerReserveError, Err: err, Failures: b.failures, Since: b.since})
+	c.emit(ConsumerEvent{Kind: ConsumerBackoff, Err: err, Failures: b.failures, Since: b.since, Backoff: b.delay})
+	sleep(ctx, b.delay)
+}
+
+// recovered resets b after a successful reservation.
+func (c *Consumer) recovered(b *reserveBackoff) {
+	if b.failures > 0 {
+		c.emit(ConsumerEvent{Kind: ConsumerResumed, Failures: b.failures, Since: b.since})
+	}
+	*b = reserveBackoff{}
+}
+
+// RunOnce reserves up to n messages, handles them and returns how many it
+// handled. It doesn't wait for messages unless the consumer's Wait is set.
+// In batch mode, the messages are handled as one batch.
+func (c *Consumer) RunOnce(ctx context.Context, n int) (int, error) {
+	msgs, err := c.Queue.reserveWith(ctx, ReserveOptions{N: n, Wait: c.Wait, Timeout: c.Timeout})
+	if err != nil && !ErrQueueNotFound(err) {
+		return 0, err
+	}
+	if c.Batch != nil {
+		if len(msgs) > 0 {
+			c.deliverBatch(ctx, msgs)
+		}
+		return len(msgs), nil
+	}
+	sem := make(chan struct{}, c.concurrency())
+	var wg sync.WaitGroup
+	for _, msg := range msgs {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(msg Message) {
+			defer func() { <-sem; wg.Done() }()
+			c.deliver(ctx, msg)
+		}(msg)
+	}
+	wg.Wait()
+	return len(msgs), nil
+}
+
+// ProcessOne reserves a message, hands it to h and deletes it if h
+// succeeded, or releases it if h failed, for scripts that don't need a
+// Consumer. It reports whether a message was handled, and returns the
+// handler's error, or the error settling the message.
+//
+//	for {
+//		ok, err := q.ProcessOne(ctx, h)
+//		if !ok {
+//			break
+//		}
+//		...
+//	}
+func (q Queue) ProcessOne(ctx context.Context, h Handler) (bool, error) {
+	c := NewConsumer(q, h)
+	var errs []error
+	c.OnError = func(err error) { errs = append(errs, err) }
+	msgs, err := q.reserveWith(ctx, ReserveOptions{})
+	if ErrQueueNotFound(err) {
+		return false, nil
+	}
+	if err != nil || len(msgs) == 0 {
+		return false, err
+	}
+	d := c.delivery(msgs[0])
+	if d == nil {
+		return false, errors.Join(errs...)
+	}
+	herr := handle(ctx, h, d)
+	c.settle(d, herr)
+	return true, errors.Join(append([]error{herr}, errs...)...)
+}
+
+func (c *Consumer) reserve(ctx context.Context, n int) ([]Message, error) {
+	wait := c.Wait
+	if wait == 0 {
+		wait = MaxWait
+	}
+	msgs, err := c.Queue.reserveWith(ctx, ReserveOptions{N: n, Wait: wait, Timeout: c.Timeout})
+	if ErrQueueNotFound(err) {
+		// nothing was pushed yet, poll for the queue as the server would
+		sleep(ctx, wait)
+		return nil, nil
+	}
+	return msgs, err
+}
+
+// deliver hands msg to the handler and settles it if the handler didn't.
+func (c *Consumer) deliver(ctx context.Context, msg Message) {
+	d := c.delivery(msg)
+	if d == nil {
+		return
+	}
+	ctx, done := c.Watchdog.track(ctx, d, c.timeout())
+	defer done()
+	start := time.Now()
+	err := handle(ctx, c.Handler, d)
+	c.settle(d, err)
+	c.observe(d, time.Since(start), err)
+}
+
+// delivery returns the Delivery of msg, or nil if Filter or Validator
+// turned it away.
+func (c *Consumer) delivery(msg Message) *Delivery {
+	d := &Delivery{Message: msg, consumer: c}
+	d.Envelope, d.Enveloped = msg.Open()
+	if d.Enveloped && isChunk(d.Envelope) {
+		a, err := c.assemble(d)
+		if err != nil {
+			c.report(err)
+			if err := c.invalid(d); err != nil {
+				c.report(err)
+			}
+			return nil
+		}
+		if a == nil {
+			return nil // wait for the other chunks
+		}
+		d = a
+	}
+	if d.Enveloped {
+		if err := decode(c.Transforms, &d.Envelope); err != nil {
+			c.report(fmt.Errorf("mq: decoding %s on %s: %w", d.Id, c.Queue.Name, err))
+			if err := c.invalid(d); err != nil {
+				c.report(err)
+			}
+			return nil
+		}
+	}
+
+	if c.Filter != nil && !c.Filter(d) {
+		if err := c.reject(d); err != nil {
+			c.report(err)
+		}
+		return nil
+	}
+
+	if err := validate(c.Validator, c.Queue, d.Id, d.Envelope.Payload); err != nil {
+		c.report(err)
+		if err := c.invalid(d); err != nil {
+			c.report(err)
+		}
+		return nil
+	}
+	if d.parts == nil {
+		c.hold(d)
+	}
+	return d
+}
+
+// settle acks d if the handler succeeded and nacks it otherwise, unless the
+// handler settled it.
+func (c *Consumer) settle(d *Delivery, herr error) {
+	if d.Settled() {
+		return
+	}
+	var err error
+	if herr != nil {
+		err = d.Nack(0)
+	} else {
+		err = d.Ack()
+	}
+	if err != nil && err != ErrSettled {
+		c.report(err)
+	}
+}
+
+// reject settles a delivery Filter didn't pick.
+func (c *Consumer) reject(d *Delivery) error {
+	if c.Reroute == nil {
+		return d.Nack(c.RejectDelay)
+	}
+	return d.settle(func() error { return d.move(*c.Reroute, PushOptions{}) })
+}
+
+// MatchHeader returns a Filter picking enveloped deliveries whose header
+// key is value.
+func MatchHeader(key, value string) func(*Delivery) bool {
+	return func(d *Delivery) bool {
+		v, ok := d.Envelope.Headers[key]
+		return ok && v == value
+	}
+}
+
+// handle calls h, turning panics into errors.
+func handle(ctx context.Context, h Handler, d *Delivery) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("mq: handler panicked: %v", r)
+		}
+	}()
+	return h.Handle(ctx, d)
+}
+
+// timeout is how long deliveries stay reserved.
+func (c *Consumer) timeout() time.Duration {
+	if c.Timeout > 0 {
+		return c.Timeout
+	}
+	return DefaultMessageTimeout
+}
+
+func (c *Consumer) concurrency() int {
+	if c.Concurrency > 0 {
+		return c.Concurrency
+	}
+	return 1
+}
+
+func (c *Consumer) report(err error) {
+	if c != nil && c.OnError != nil {
+		c.OnError(err)
+	}
+}
+
+// permanent tells whether err is a refusal that retrying won't fix.
+func permanent(err error) bool {
+	code := api.StatusCode(err)
+	return code >= 400 && code < 500 && code != http.StatusTooManyRequests
+}
+
+func sleep(ctx context.Context, d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-ctx.Done():
+	}
+}
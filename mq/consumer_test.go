@@ -0,0 +1,145 @@
+package mq
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iron-io/iron_go3/irontest"
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestConsumer(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("consumers", func() {
+		srv := irontest.NewServer()
+		defer srv.Close()
+		q := Queue{Settings: srv.Settings("iron_mq"), Name: "work"}
+		dead := Queue{Settings: q.Settings, Name: "work-dead"}
+
+		consume := func(h HandlerFunc) *Consumer {
+			c := NewConsumer(q, h)
+			c.DeadLetter = &dead
+			c.RunOnce(context.Background(), 10)
+			return c
+		}
+
+		It("acks deliveries the handler returns nil for", func() {
+			q.PushString("ok")
+			consume(func(ctx context.Context, d *Delivery) error { return nil })
+			Expect(len(srv.MQ.Messages("work")), ToEqual, 0)
+		})
+
+		It("nacks deliveries the handler fails", func() {
+			q.PushString("fail")
+			consume(func(ctx context.Context, d *Delivery) error { return errors.New("no") })
+			msgs, _ := q.ReserveWith(ReserveOptions{})
+			Expect(len(msgs), ToEqual, 1)
+			Expect(msgs[0].ReservedCount, ToEqual, 2)
+			msgs[0].Delete()
+		})
+
+		It("nacks deliveries the handler panics on", func() {
+			q.PushString("panic")
+			consume(func(ctx context.Context, d *Delivery) error { panic("boom") })
+			Expect(srv.MQ.Messages("work"), ToDeepEqual, []string{"panic"})
+			q.Clear()
+		})
+
+		It("nacks with a delay", func() {
+			q.PushString("later")
+			consume(func(ctx context.Context, d *Delivery) error { return d.Nack(time.Minute) })
+			msgs, _ := q.ReserveWith(ReserveOptions{})
+			Expect(len(msgs), ToEqual, 0)
+			q.Clear()
+		})
+
+		It("dead letters terminated deliveries", func() {
+			q.PushString("poison")
+			var second error
+			consume(func(ctx context.Context, d *Delivery) error {
+				err := d.Term()
+				second = d.Ack()
+				return err
+			})
+			Expect(second, ToEqual, ErrSettled)
+			Expect(len(srv.MQ.Messages("work")), ToEqual, 0)
+			Expect(srv.MQ.Messages("work-dead"), ToDeepEqual, []string{"poison"})
+		})
+
+		It("opens envelopes", func() {
+			NewProducer(q, "p").Push([]byte("hi"), nil)
+			var got Delivery
+			consume(func(ctx context.Context, d *Delivery) error {
+				got = *d
+				return nil
+			})
+			Expect(got.Enveloped, ToBeTrue)
+			Expect(string(got.Envelope.Payload), ToEqual, "hi")
+		})
+
+		It("runs until the context is done", func() {
+			var mu sync.Mutex
+			var bodies []string
+			c := NewConsumer(q, HandlerFunc(func(ctx context.Context, d *Delivery) error {
+				mu.Lock()
+				defer mu.Unlock()
+				bodies = append(bodies, d.Body)
+				return nil
+			}))
+			c.Concurrency = 3
+			c.Wait = time.Second
+			q.PushStrings("a", "b", "c", "d")
+
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan error)
+			go func() { done <- c.Run(ctx) }()
+			for i := 0; i < 100 && len(srv.MQ.Messages("work")) > 0; i++ {
+				time.Sleep(10 * time.Millisecond)
+			}
+			cancel()
+			Expect(<-done, ToBeNil)
+			mu.Lock()
+			Expect(len(bodies), ToEqual, 4)
+			mu.Unlock()
+		})
+
+		It("stops on refused requests", func() {
+			bad := q
+			bad.Settings.Token = "wrong"
+			err := NewConsumer(bad, HandlerFunc(func(ctx context.Context, d *Delivery) error { return nil })).Run(context.Background())
+			Expect(err, ToNotBeNil)
+		})
+
+		It("processes one message at a time", func() {
+			q.PushStrings("a", "b")
+			var seen []string
+			h := HandlerFunc(func(ctx context.Context, d *Delivery) error {
+				seen = append(seen, d.Body)
+				if d.Body == "b" {
+					return errors.New("no")
+				}
+				return nil
+			})
+			ok, err := q.ProcessOne(context.Background(), h)
+			Expect(ok, ToBeTrue)
+			Expect(err, ToBeNil)
+			ok, err = q.ProcessOne(context.Background(), h)
+			Expect(ok, ToBeTrue)
+			Expect(err.Error(), ToEqual, "no")
+			Expect(seen, ToDeepEqual, []string{"a", "b"})
+			Expect(srv.MQ.Messages("work"), ToDeepEqual, []string{"b"})
+			q.Clear()
+
+			ok, err = q.ProcessOne(context.Background(), h)
+			Expect(ok, ToEqual, false)
+			Expect(err, ToBeNil)
+			ok, err = Queue{Settings: q.Settings, Name: "missing"}.ProcessOne(context.Background(), h)
+			Expect(ok, ToEqual, false)
+			Expect(err, ToBeNil)
+		})
+	})
+}
@@ -0,0 +1,183 @@
+package mq
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DedupKeyHeader is the envelope header Idempotent deduplicates by, instead
+// of the message id, so producers can mark messages pushed twice.
+const DedupKeyHeader = "dedup-key"
+
+// A DedupStore remembers keys for a while. Idempotent uses it to record
+// the messages it processed. MemoryDedupStore, RedisDedupStore and the
+// cache package's DedupStore implement it.
+type DedupStore interface {
+	// Add stores key for ttl unless it is stored already, reporting
+	// whether it did so.
+	Add(ctx context.Context, key string, ttl time.Duration) (bool, error)
+	// Set stores key for ttl.
+	Set(ctx context.Context, key string, ttl time.Duration) error
+	// Delete removes key, which need not be stored.
+	Delete(ctx context.Context, key string) error
+}
+
+// Idempotent is a Handler skipping deliveries it processed before, giving
+// effectively-once processing on top of the at-least-once delivery of a
+// Consumer. A delivery is processed at most once within TTL, unless its
+// processing failed.
+//
+// A delivery's key is claimed in Store for Lease while it is processed, so
+// a duplicate arriving meanwhile is skipped too. If processing succeeds the
+// key is kept for TTL, otherwise it is deleted so redelivery processes the
+// message again. If the process dies while processing, the message is
+// redelivered once its reservation times out and processed again once the
+// lease expired, so Lease should not be longer than the reservation.
+type Idempotent struct {
+	Handler Handler
+	Store   DedupStore
+	// TTL is how long processed keys are remembered, 24 hours by default.
+	TTL time.Duration
+	// Lease is how long a key is claimed while processing, a minute by
+	// default.
+	Lease time.Duration
+	// Key returns the key to deduplicate by, by default the envelope's
+	// DedupKeyHeader or else the message id, prefixed by the queue name.
+	Key func(*Delivery) string
+}
+
+// NewIdempotent returns h, skipping deliveries store has seen.
+func NewIdempotent(h Handler, store DedupStore) *Idempotent {
+	return &Idempotent{Handler: h, Store: store}
+}
+
+func (h *Idempotent) Handle(ctx context.Context, d *Delivery) error {
+	key := h.key(d)
+	claimed, err := h.Store.Add(ctx, key, h.lease())
+	if err != nil {
+		return fmt.Errorf("mq: claiming %s: %w", key, err)
+	}
+	if !claimed {
+		return d.Ack() // a duplicate
+	}
+
+	err = h.Handler.Handle(ctx, d)
+	if err != nil || d.nacked {
+		if derr := h.Store.Delete(ctx, key); derr != nil {
+			d.consumer.report(fmt.Errorf("mq: releasing %s: %w", key, derr))
+		}
+		return err
+	}
+	if serr := h.Store.Set(ctx, key, h.ttl()); serr != nil {
+		d.consumer.report(fmt.Errorf("mq: recording %s: %w", key, serr))
+	}
+	return nil
+}
+
+func (h *Idempotent) key(d *Delivery) string {
+	if h.Key != nil {
+		return h.Key(d)
+	}
+	if k := d.Envelope.Headers[DedupKeyHeader]; k != "" {
+		return d.q.Name + ":" + k
+	}
+	return d.q.Name + ":" + d.Id
+}
+
+func (h *Idempotent) ttl() time.Duration {
+	if h.TTL > 0 {
+		return h.TTL
+	}
+	return 24 * time.Hour
+}
+
+func (h *Idempotent) lease() time.Duration {
+	if h.Lease > 0 {
+		return h.Lease
+	}
+	return time.Minute
+}
+
+// MemoryDedupStore is a DedupStore for a single process.
+type MemoryDedupStore struct {
+	mu   sync.Mutex
+	keys map[string]time.Time
+}
+
+// NewMemoryDedupStore returns an empty MemoryDedupStore.
+func NewMemoryDedupStore() *MemoryDedupStore {
+	return &MemoryDedupStore{keys: map[string]time.Time{}}
+}
+
+func (s *MemoryDedupStore) Add(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if exp, ok := s.keys[key]; ok && now.Before(exp) {
+		return false, nil
+	}
+	s.set(key, now.Add(ttl), now)
+	return true, nil
+}
+
+func (s *MemoryDedupStore) Set(ctx context.Context, key string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.set(key, now.Add(ttl), now)
+	return nil
+}
+
+func (s *MemoryDedupStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.keys, key)
+	return nil
+}
+
+// set stores key, dropping expired keys once the map doubled since the
+// last sweep.
+func (s *MemoryDedupStore) set(key string, exp, now time.Time) {
+	s.keys[key] = exp
+	if len(s.keys) >= 1024 && len(s.keys)&(len(s.keys)-1) == 0 {
+		for k, e := range s.keys {
+			if !now.Before(e) {
+				delete(s.keys, k)
+			}
+		}
+	}
+}
+
+// RedisDedupStore is a DedupStore keeping keys in Redis. It talks to Redis
+// through Do, so it works with any client, e.g. for go-redis:
+//
+//	store := &mq.RedisDedupStore{Do: func(ctx context.Context, args ...interface{}) (interface{}, error) {
+//		v, err := rdb.Do(ctx, args...).Result()
+//		if err == redis.Nil {
+//			return nil, nil
+//		}
+//		return v, err
+//	}}
+type RedisDedupStore struct {
+	// Do sends a command and returns its reply, nil for a nil reply.
+	Do func(ctx context.Context, args ...interface{}) (interface{}, error)
+	// Prefix is put in front of the keys.
+	Prefix string
+}
+
+func (s *RedisDedupStore) Add(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	reply, err := s.Do(ctx, "SET", s.Prefix+key, "1", "PX", ttl.Milliseconds(), "NX")
+	return reply != nil, err
+}
+
+func (s *RedisDedupStore) Set(ctx context.Context, key string, ttl time.Duration) error {
+	_, err := s.Do(ctx, "SET", s.Prefix+key, "1", "PX", ttl.Milliseconds())
+	return err
+}
+
+func (s *RedisDedupStore) Delete(ctx context.Context, key string) error {
+	_, err := s.Do(ctx, "DEL", s.Prefix+key)
+	return err
+}
@@ -0,0 +1,99 @@
+package mq
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/iron-io/iron_go3/irontest"
+	. "github.com/jeffh/go.bdd"
+)
+
+// fakeRedis answers the commands RedisDedupStore sends.
+func fakeRedis() func(ctx context.Context, args ...interface{}) (interface{}, error) {
+	keys := map[string]bool{}
+	return func(ctx context.Context, args ...interface{}) (interface{}, error) {
+		key := args[1].(string)
+		switch {
+		case args[0] == "DEL":
+			delete(keys, key)
+			return int64(1), nil
+		case len(args) == 6 && keys[key]: // NX
+			return nil, nil
+		}
+		keys[key] = true
+		return "OK", nil
+	}
+}
+
+func TestIdempotent(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("idempotent handlers", func() {
+		srv := irontest.NewServer()
+		defer srv.Close()
+		q := Queue{Settings: srv.Settings("iron_mq"), Name: "dedup"}
+
+		for name, store := range map[string]DedupStore{
+			"memory": NewMemoryDedupStore(),
+			"redis":  &RedisDedupStore{Do: fakeRedis(), Prefix: "x:"},
+		} {
+			calls := 0
+			fail := false
+			h := NewIdempotent(HandlerFunc(func(ctx context.Context, d *Delivery) error {
+				calls++
+				if fail {
+					return errors.New("failed")
+				}
+				return nil
+			}), store)
+			c := NewConsumer(q, h)
+			p := NewProducer(q, "p")
+
+			It("skips duplicates with "+name, func() {
+				calls = 0
+				p.Push([]byte("a"), map[string]string{DedupKeyHeader: name + "-1"})
+				p.Push([]byte("a"), map[string]string{DedupKeyHeader: name + "-1"})
+				c.RunOnce(context.Background(), 10)
+				Expect(calls, ToEqual, 1)
+				Expect(len(srv.MQ.Messages("dedup")), ToEqual, 0)
+			})
+
+			It("processes failed messages again with "+name, func() {
+				calls, fail = 0, true
+				p.Push([]byte("b"), map[string]string{DedupKeyHeader: name + "-2"})
+				c.RunOnce(context.Background(), 10)
+				fail = false
+				c.RunOnce(context.Background(), 10)
+				Expect(calls, ToEqual, 2)
+				Expect(len(srv.MQ.Messages("dedup")), ToEqual, 0)
+			})
+
+			It("deduplicates raw messages by id with "+name, func() {
+				calls = 0
+				q.PushString("c")
+				msgs, _ := q.ReserveWith(ReserveOptions{})
+				d := &Delivery{Message: msgs[0]}
+				Expect(h.Handle(context.Background(), d), ToBeNil)
+				d = &Delivery{Message: msgs[0]}
+				Expect(h.Handle(context.Background(), d), ToBeNil)
+				Expect(calls, ToEqual, 1)
+				Expect(d.Settled(), ToBeTrue)
+			})
+		}
+	})
+
+	Describe("the memory store", func() {
+		It("expires keys", func() {
+			s := NewMemoryDedupStore()
+			ok, _ := s.Add(context.Background(), "k", time.Millisecond)
+			Expect(ok, ToBeTrue)
+			ok, _ = s.Add(context.Background(), "k", time.Minute)
+			Expect(ok, ToEqual, false)
+			s.Set(context.Background(), "k", -time.Second)
+			ok, _ = s.Add(context.Background(), "k", time.Minute)
+			Expect(ok, ToBeTrue)
+		})
+	})
+}
@@ -0,0 +1,116 @@
+package mq
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DrainOptions configure Drain. Zero values take the defaults.
+type DrainOptions struct {
+	// EmptyPolls is how many polls in a row must find the queue empty
+	// before the drain ends, 3 by default.
+	EmptyPolls int
+	// Wait is how long each poll waits for messages, a second by default
+	// and at most MaxWait.
+	Wait time.Duration
+	// FailureDelay is how long the deliveries the handler fails are
+	// released for, a minute by default, so they don't keep the queue from
+	// ever looking empty.
+	FailureDelay time.Duration
+}
+
+// DrainResult tells what a drain did.
+type DrainResult struct {
+	// Handled counts the deliveries the handler succeeded with, Failed
+	// those it failed.
+	Handled  int
+	Failed   int
+	Duration time.Duration
+}
+
+// DrainTo hands the queue's messages to h until the queue is empty, see
+// Consumer.Drain.
+func (q Queue) DrainTo(ctx context.Context, h Handler, opts DrainOptions) (DrainResult, error) {
+	return NewConsumer(q, h).Drain(ctx, opts)
+}
+
+// Drain consumes like Run until EmptyPolls polls in a row found no
+// messages, e.g. for nightly catch-up jobs, and returns what it handled.
+// It returns early with the error of a failed reservation, or ctx's.
+// Batch consumers aren't supported, only Handler is used.
+func (c *Consumer) Drain(ctx context.Context, opts DrainOptions) (res DrainResult, err error) {
+	start := time.Now()
+	var mu sync.Mutex
+	sem := make(chan struct{}, c.concurrency())
+	var wg sync.WaitGroup
+	defer func() {
+		wg.Wait()
+		res.Duration = time.Since(start)
+	}()
+
+	for empty := 0; empty < opts.emptyPolls(); {
+		if err := ctx.Err(); err != nil {
+			return res, err
+		}
+		msgs, err := c.Queue.reserveWith(ctx, ReserveOptions{N: c.concurrency(), Wait: opts.wait(), Timeout: c.Timeout})
+		if err != nil && !ErrQueueNotFound(err) {
+			return res, err
+		}
+		if len(msgs) == 0 {
+			empty++
+			if ErrQueueNotFound(err) {
+				sleep(ctx, opts.wait())
+			}
+			continue
+		}
+		empty = 0
+		for _, msg := range msgs {
+			sem <- struct{}{}
+			wg.Add(1)
+			go func(msg Message) {
+				defer func() { <-sem; wg.Done() }()
+				d := c.delivery(msg)
+				if d == nil {
+					return
+				}
+				herr := handle(ctx, c.Handler, d)
+				if herr != nil && !d.Settled() {
+					if err := d.Nack(opts.failureDelay()); err != nil && err != ErrSettled {
+						c.report(err)
+					}
+				}
+				c.settle(d, herr)
+				mu.Lock()
+				if herr != nil {
+					res.Failed++
+				} else {
+					res.Handled++
+				}
+				mu.Unlock()
+			}(msg)
+		}
+	}
+	return res, nil
+}
+
+func (o DrainOptions) emptyPolls() int {
+	if o.EmptyPolls > 0 {
+		return o.EmptyPolls
+	}
+	return 3
+}
+
+func (o DrainOptions) failureDelay() time.Duration {
+	if o.FailureDelay > 0 {
+		return o.FailureDelay
+	}
+	return time.Minute
+}
+
+func (o DrainOptions) wait() time.Duration {
+	if o.Wait > 0 {
+		return min(o.Wait, MaxWait)
+	}
+	return time.Second
+}
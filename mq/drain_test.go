@@ -0,0 +1,60 @@
+package mq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/iron-io/iron_go3/irontest"
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestDrain(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("draining a queue", func() {
+		srv := irontest.NewServer()
+		defer srv.Close()
+		q := Queue{Settings: srv.Settings("iron_mq"), Name: "catchup"}
+		ctx := context.Background()
+		opts := DrainOptions{EmptyPolls: 2, Wait: 10 * time.Millisecond}
+
+		It("handles messages until the queue stays empty", func() {
+			for i := 0; i < 25; i++ {
+				q.PushString(fmt.Sprint(i))
+			}
+			var calls atomic.Int32
+			c := NewConsumer(q, HandlerFunc(func(ctx context.Context, d *Delivery) error {
+				calls.Add(1)
+				if d.Body == "7" {
+					return errors.New("no")
+				}
+				return nil
+			}))
+			c.Concurrency = 4
+			res, err := c.Drain(ctx, opts)
+			Expect(err, ToBeNil)
+			Expect(res.Handled, ToEqual, 24)
+			Expect(res.Failed, ToEqual, 1)
+			Expect(int(calls.Load()), ToEqual, 25)
+			Expect(res.Duration > 0, ToBeTrue)
+			Expect(srv.MQ.Messages("catchup"), ToDeepEqual, []string{"7"})
+			q.Clear()
+		})
+
+		It("ends on missing queues and done contexts", func() {
+			h := HandlerFunc(func(ctx context.Context, d *Delivery) error { return nil })
+			res, err := Queue{Settings: q.Settings, Name: "missing"}.DrainTo(ctx, h, opts)
+			Expect(err, ToBeNil)
+			Expect(res.Handled, ToEqual, 0)
+
+			done, cancel := context.WithCancel(ctx)
+			cancel()
+			_, err = q.DrainTo(done, h, opts)
+			Expect(err, ToEqual, context.Canceled)
+		})
+	})
+}
@@ -0,0 +1,164 @@
+package mq
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// envelopeVersion is written to the "iron_envelope" field of enveloped
+// bodies, OpenEnvelope only recognizes versions it knows.
+const envelopeVersion = 1
+
+// An Envelope wraps a payload with metadata into a message body, so
+// consumers can tell what it holds and where it came from. Bodies are
+// JSON objects with the payload base64 encoded.
+type Envelope struct {
+	// ContentType is the media type of Payload, e.g. "application/json".
+	ContentType string `json:"content_type,omitempty"`
+	// ContentEncoding is how Payload is encoded on top of its content
+	// type, e.g. "gzip".
+	ContentEncoding string            `json:"content_encoding,omitempty"`
+	Headers         map[string]string `json:"headers,omitempty"`
+	ProducedAt      time.Time         `json:"produced_at"`
+	ProducerId      string            `json:"producer_id,omitempty"`
+	Payload         []byte            `json:"payload"`
+}
+
+type envelopeBody struct {
+	Version int `json:"iron_envelope"`
+	Envelope
+}
+
+// Encode returns e as a message body.
+func (e Envelope) Encode() (string, error) {
+	b, err := json.Marshal(envelopeBody{Version: envelopeVersion, Envelope: e})
+	return string(b), err
+}
+
+// OpenEnvelope returns the envelope body was encoded from, and true. Any
+// other body, e.g. one pushed by a client that doesn't use envelopes, is
+// passed through as the Payload of an otherwise empty Envelope, and false.
+func OpenEnvelope(body string) (Envelope, bool) {
+	var b envelopeBody
+	if len(body) == 0 || body[0] != '{' ||
+		json.Unmarshal([]byte(body), &b) != nil || b.Version != envelopeVersion {
+		return Envelope{Payload: []byte(body)}, false
+	}
+	return b.Envelope, true
+}
+
+// Open returns the envelope of m's body, see OpenEnvelope.
+func (m Message) Open() (Envelope, bool) {
+	return OpenEnvelope(m.Body)
+}
+
+// ProducedAt returns when m was produced: the ProducedAt of its envelope,
+// or its CreatedAt. It is false if neither is known.
+func (m Message) ProducedAt() (time.Time, bool) {
+	if e, ok := m.Open(); ok && !e.ProducedAt.IsZero() {
+		return e.ProducedAt, true
+	}
+	return m.CreatedAt, !m.CreatedAt.IsZero()
+}
+
+// Age returns how long m had waited when it was reserved, or until now if
+// it wasn't, see ProducedAt. Ages from clocks ahead of this one are 0. It
+// is false if when m was produced isn't known.
+func (m Message) Age() (time.Duration, bool) {
+	produced, ok := m.ProducedAt()
+	if !ok {
+		return 0, false
+	}
+	at := m.reservedAt
+	if at.IsZero() {
+		at = time.Now()
+	}
+	return max(at.Sub(produced), 0), true
+}
+
+// A Producer pushes payloads in envelopes stamped with its metadata.
+type Producer struct {
+	Queue Queue
+	// Id identifies the producer to consumers.
+	Id string
+	// ContentType and ContentEncoding are set on every envelope.
+	ContentType     string
+	ContentEncoding string
+	// Headers are set on every envelope, headers passed to Push take
+	// precedence.
+	Headers map[string]string
+	// Validator, if set, checks payloads before they are pushed. Refused
+	// payloads aren't pushed, Push returns a *ValidationError instead.
+	Validator Validator
+	// Transforms are applied in order to envelopes before they are pushed,
+	// see Transform.
+	Transforms []Transform
+	// Chunked splits envelopes larger than the queue's message size into
+	// chunk messages, pushed together, which Consumers reassemble. Push
+	// then returns the id of the first chunk. Chunks are only reassembled
+	// when one consumer reserves all the chunks of a message within the
+	// reservation timeout, so chunked messages suit queues with a single
+	// consumer process.
+	Chunked bool
+}
+
+// NewProducer returns a Producer of envelopes with id on q.
+func NewProducer(q Queue, id string) *Producer {
+	return &Producer{Queue: q, Id: id}
+}
+
+// Envelope returns payload in an envelope stamped with p's metadata,
+// headers and the current time.
+func (p *Producer) Envelope(payload []byte, headers map[string]string) Envelope {
+	e := Envelope{
+		ContentType:     p.ContentType,
+		ContentEncoding: p.ContentEncoding,
+		ProducedAt:      time.Now().UTC(),
+		ProducerId:      p.Id,
+		Payload:         payload,
+	}
+	if len(p.Headers)+len(headers) > 0 {
+		e.Headers = make(map[string]string, len(p.Headers)+len(headers))
+		for k, v := range p.Headers {
+			e.Headers[k] = v
+		}
+		for k, v := range headers {
+			e.Headers[k] = v
+		}
+	}
+	return e
+}
+
+// Push enqueues payload in an envelope and returns the message's id.
+func (p *Producer) Push(payload []byte, headers map[string]string) (id string, err error) {
+	return p.PushWith(PushOptions{}, payload, headers)
+}
+
+// PushWith is Push with options.
+func (p *Producer) PushWith(opts PushOptions, payload []byte, headers map[string]string) (id string, err error) {
+	return p.push(opts, p.Envelope(payload, headers))
+}
+
+func (p *Producer) push(opts PushOptions, e Envelope) (id string, err error) {
+	if err := validate(p.Validator, p.Queue, "", e.Payload); err != nil {
+		return "", err
+	}
+	if err := encode(p.Transforms, &e); err != nil {
+		return "", fmt.Errorf("mq: encoding for %s: %w", p.Queue.Name, err)
+	}
+	body, err := e.Encode()
+	if err != nil {
+		return "", err
+	}
+	if p.Chunked && len(body) > p.Queue.Limits.messageSize() {
+		return p.pushChunks(opts, e)
+	}
+	ids, err := p.Queue.PushWith(opts, body)
+	if err != nil {
+		return "", err
+	} else if len(ids) < 1 {
+		return "", fmt.Errorf("didn't receive message ID for pushing message to %s", p.Queue.Name)
+	}
+	return ids[0], nil
+}
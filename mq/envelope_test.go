@@ -0,0 +1,90 @@
+package mq
+
+import (
+	"testing"
+	"time"
+
+	"github.com/iron-io/iron_go3/irontest"
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestEnvelope(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("envelopes", func() {
+		It("round trips", func() {
+			in := Envelope{
+				ContentType: "application/json",
+				Headers:     map[string]string{"trace": "abc"},
+				ProducedAt:  time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+				ProducerId:  "p1",
+				Payload:     []byte(`{"a":1}`),
+			}
+			body, err := in.Encode()
+			Expect(err, ToBeNil)
+			out, ok := OpenEnvelope(body)
+			Expect(ok, ToBeTrue)
+			Expect(out, ToDeepEqual, in)
+		})
+
+		It("passes raw bodies through", func() {
+			for _, body := range []string{"", "plain text", `{"a":1}`, `{"iron_envelope":99}`} {
+				e, ok := OpenEnvelope(body)
+				Expect(ok, ToEqual, false)
+				Expect(string(e.Payload), ToEqual, body)
+				Expect(e.ProducerId, ToEqual, "")
+			}
+		})
+
+		It("is pushed by producers", func() {
+			srv := irontest.NewServer()
+			defer srv.Close()
+			q := Queue{Settings: srv.Settings("iron_mq"), Name: "envelopes"}
+
+			p := NewProducer(q, "p1")
+			p.ContentType = "text/plain"
+			p.Headers = map[string]string{"a": "1", "b": "1"}
+			_, err := p.Push([]byte("hello"), map[string]string{"b": "2"})
+			Expect(err, ToBeNil)
+			q.PushString("raw")
+
+			msgs, err := q.PopWith(PopOptions{N: 2})
+			Expect(err, ToBeNil)
+			e, ok := msgs[0].Open()
+			Expect(ok, ToBeTrue)
+			Expect(string(e.Payload), ToEqual, "hello")
+			Expect(e.ContentType, ToEqual, "text/plain")
+			Expect(e.ProducerId, ToEqual, "p1")
+			Expect(e.Headers, ToDeepEqual, map[string]string{"a": "1", "b": "2"})
+			Expect(time.Since(e.ProducedAt) < time.Minute, ToBeTrue)
+
+			e, ok = msgs[1].Open()
+			Expect(ok, ToEqual, false)
+			Expect(string(e.Payload), ToEqual, "raw")
+		})
+
+		It("tells the age of messages when they were reserved", func() {
+			srv := irontest.NewServer()
+			defer srv.Close()
+			q := Queue{Settings: srv.Settings("iron_mq"), Name: "ages"}
+			body, _ := Envelope{ProducedAt: time.Now().Add(-time.Hour), Payload: []byte("old")}.Encode()
+			q.PushStrings(body, "raw")
+
+			msgs, err := q.ReserveWith(ReserveOptions{N: 2})
+			Expect(err, ToBeNil)
+			age, ok := msgs[0].Age()
+			Expect(ok, ToBeTrue)
+			Expect(age >= time.Hour && age < time.Hour+time.Minute, ToBeTrue)
+			time.Sleep(10 * time.Millisecond)
+			later, _ := msgs[0].Age()
+			Expect(later, ToEqual, age)
+			_, ok = msgs[1].Age()
+			Expect(ok, ToEqual, false)
+
+			msgs[1].CreatedAt = time.Now().Add(time.Minute)
+			age, ok = msgs[1].Age()
+			Expect(ok, ToBeTrue)
+			Expect(age, ToEqual, time.Duration(0))
+		})
+	})
+}
@@ -0,0 +1,62 @@
+package mq
+
+import "time"
+
+// Kinds of ConsumerEvent.
+const (
+	// ConsumerStarted is sent when Run starts.
+	ConsumerStarted = "started"
+	// ConsumerReserveError is sent for each failed reservation.
+	ConsumerReserveError = "reserve_error"
+	// ConsumerBackoff is sent when the consumer waits before reserving
+	// again, after a failed reservation.
+	ConsumerBackoff = "backoff"
+	// ConsumerResumed is sent for the first successful reservation after
+	// failed ones.
+	ConsumerResumed = "resumed"
+	// ConsumerStopped is sent when Run returns.
+	ConsumerStopped = "stopped"
+)
+
+// A ConsumerEvent tells about the lifecycle of a Consumer, so operators
+// can alert on silent degradation, e.g. a consumer that has been backing
+// off for ten minutes:
+//
+//	c.OnEvent = func(e mq.ConsumerEvent) {
+//		if e.Kind == mq.ConsumerBackoff && e.Degraded() > 10*time.Minute {
+//			page(e.Queue, e.Err)
+//		}
+//	}
+type ConsumerEvent struct {
+	// Kind is one of the ConsumerEvent kind constants.
+	Kind  string
+	Queue string
+	Time  time.Time
+	// Err is the error of a failed reservation, or the one Run returned.
+	Err error
+	// Failures is the number of reservations in a row that failed, up to
+	// this event.
+	Failures int
+	// Since is when the first of those failures happened.
+	Since time.Time
+	// Backoff is how long the consumer waits before reserving again.
+	Backoff time.Duration
+}
+
+// Degraded returns how long reservations have been failing, as of the
+// event.
+func (e ConsumerEvent) Degraded() time.Duration {
+	if e.Since.IsZero() {
+		return 0
+	}
+	return e.Time.Sub(e.Since)
+}
+
+func (c *Consumer) emit(e ConsumerEvent) {
+	if c.OnEvent == nil {
+		return
+	}
+	e.Queue = c.Queue.Name
+	e.Time = time.Now()
+	c.OnEvent(e)
+}
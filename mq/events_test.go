@@ -0,0 +1,81 @@
+package mq
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/iron-io/iron_go3/irontest"
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestConsumerEvents(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("consumer events", func() {
+		srv := irontest.NewServer()
+		defer srv.Close()
+
+		// fails requests while down is set
+		var down int32 = 1
+		flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.LoadInt32(&down) == 1 {
+				http.Error(w, `{"msg":"down"}`, http.StatusInternalServerError)
+				return
+			}
+			srv.ServeHTTP(w, r)
+		}))
+		defer flaky.Close()
+		s := srv.Settings("iron_mq")
+		host, port, _ := net.SplitHostPort(strings.TrimPrefix(flaky.URL, "http://"))
+		p, _ := strconv.Atoi(port)
+		s.Host, s.Port = host, uint16(p)
+
+		It("reports degradation and recovery", func() {
+			var mu sync.Mutex
+			var events []ConsumerEvent
+			ctx, cancel := context.WithCancel(context.Background())
+			c := NewConsumer(Queue{Settings: s, Name: "events"}, HandlerFunc(func(ctx context.Context, d *Delivery) error {
+				cancel()
+				return nil
+			}))
+			c.Wait = time.Second
+			c.OnEvent = func(e ConsumerEvent) {
+				mu.Lock()
+				defer mu.Unlock()
+				events = append(events, e)
+				if e.Kind == ConsumerBackoff && e.Failures == 2 {
+					atomic.StoreInt32(&down, 0)
+					Queue{Settings: srv.Settings("iron_mq"), Name: "events"}.PushString("hello")
+				}
+			}
+			Expect(c.Run(ctx), ToBeNil)
+
+			var kinds []string
+			for _, e := range events {
+				kinds = append(kinds, e.Kind)
+			}
+			Expect(kinds, ToDeepEqual, []string{
+				ConsumerStarted,
+				ConsumerReserveError, ConsumerBackoff,
+				ConsumerReserveError, ConsumerBackoff,
+				ConsumerResumed,
+				ConsumerStopped,
+			})
+			backoff := events[4]
+			Expect(backoff.Queue, ToEqual, "events")
+			Expect(backoff.Backoff, ToEqual, 200*time.Millisecond)
+			Expect(backoff.Err, ToNotBeNil)
+			Expect(backoff.Degraded() >= 100*time.Millisecond, ToBeTrue)
+			Expect(events[5].Failures, ToEqual, 2)
+			Expect(events[6].Err, ToBeNil)
+		})
+	})
+}
@@ -0,0 +1,230 @@
+package mq
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/iron-io/iron_go3/config"
+)
+
+// Kinds of FailoverEvent.
+const (
+	// FailoverSwitched is sent when requests move to a less preferred
+	// region because the active one failed.
+	FailoverSwitched = "failover"
+	// FailoverRestored is sent when requests move back to a more preferred
+	// region that recovered.
+	FailoverRestored = "failback"
+)
+
+// A FailoverEvent tells that a Failover changed its active region.
+type FailoverEvent struct {
+	// Kind is one of the FailoverEvent kind constants.
+	Kind  string
+	Queue string
+	Time  time.Time
+	// From and To are the indexes in Regions of the regions left and
+	// taken.
+	From, To int
+	// Err is the error that made the region fail, for FailoverSwitched.
+	Err error
+}
+
+// A Failover is a queue replicated in several regions, or clusters, in
+// order of preference. Requests go to the active region, the first healthy
+// one. When a request to it fails for reasons other than being refused,
+// e.g. a timeout or a 5xx, the region is marked down and the request is
+// retried on the next region, which becomes active. Run health-checks the
+// regions and moves requests back once a preferred one recovers.
+//
+// Reserved messages belong to the region they were reserved from, so they
+// are deleted, touched and released there as usual. Messages pushed to a
+// region stay there while it is down; run a Consumer per region to
+// process them once it recovers. A push that failed over may have reached
+// the failed region too, consumers should be idempotent.
+type Failover struct {
+	Regions []Queue
+	// CheckInterval is how often Run health-checks the regions, 10 seconds
+	// by default.
+	CheckInterval time.Duration
+	// OnEvent, if set, is told when the active region changes.
+	OnEvent func(FailoverEvent)
+
+	mu     sync.Mutex
+	active int
+	down   []bool
+}
+
+// NewFailover returns a Failover of the queue name in the regions of
+// settings, the most preferred first.
+func NewFailover(name string, settings ...config.Settings) *Failover {
+	f := &Failover{}
+	for _, s := range settings {
+		f.Regions = append(f.Regions, Queue{Settings: s, Name: name})
+	}
+	return f
+}
+
+// Active returns the queue requests go to.
+func (f *Failover) Active() Queue {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.Regions[f.active]
+}
+
+// PushMessages enqueues msgs in the active region, failing over if needed.
+func (f *Failover) PushMessages(msgs ...Message) (ids []string, err error) {
+	err = f.do(func(q Queue) error {
+		ids, err = q.PushMessages(msgs...)
+		return err
+	})
+	return ids, err
+}
+
+// PushWith enqueues messages with bodies in the active region, failing over
+// if needed.
+func (f *Failover) PushWith(opts PushOptions, bodies ...string) (ids []string, err error) {
+	err = f.do(func(q Queue) error {
+		ids, err = q.PushWith(opts, bodies...)
+		return err
+	})
+	return ids, err
+}
+
+// ReserveWith reserves messages from the active region, failing over if
+// needed.
+func (f *Failover) ReserveWith(opts ReserveOptions) (msgs []Message, err error) {
+	err = f.do(func(q Queue) error {
+		msgs, err = q.ReserveWith(opts)
+		return err
+	})
+	return msgs, err
+}
+
+// PopWith deletes messages from the active region and returns them,
+// failing over if needed.
+func (f *Failover) PopWith(opts PopOptions) ([]Message, error) {
+	return f.ReserveWith(ReserveOptions{N: opts.N, Wait: opts.Wait, Delete: true})
+}
+
+// do runs op on the active region, then on the next regions up, until one
+// doesn't fail. Regions that fail are marked down. If all are down, op is
+// tried on all of them regardless.
+func (f *Failover) do(op func(Queue) error) error {
+	var errs []error
+	for _, i := range f.candidates() {
+		err := op(f.Regions[i])
+		if !regionFailed(err) {
+			f.use(i, errors.Join(errs...))
+			return err
+		}
+		f.setDown(i, true)
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// candidates returns the regions to try, the active one first, then the
+// others that are up in order of preference, or all others if none is.
+func (f *Failover) candidates() []int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.init()
+	order := []int{f.active}
+	var down []int
+	for i := range f.Regions {
+		switch {
+		case i == f.active:
+		case f.down[i]:
+			down = append(down, i)
+		default:
+			order = append(order, i)
+		}
+	}
+	if len(order) == 1 {
+		order = append(order, down...)
+	}
+	return order
+}
+
+// use makes region i active, telling OnEvent if it wasn't.
+func (f *Failover) use(i int, err error) {
+	f.mu.Lock()
+	from := f.active
+	f.active = i
+	f.mu.Unlock()
+	if from == i || f.OnEvent == nil {
+		return
+	}
+	kind := FailoverSwitched
+	if i < from {
+		kind, err = FailoverRestored, nil
+	}
+	f.OnEvent(FailoverEvent{Kind: kind, Queue: f.Regions[i].Name, Time: time.Now(), From: from, To: i, Err: err})
+}
+
+func (f *Failover) setDown(i int, down bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.init()
+	f.down[i] = down
+}
+
+func (f *Failover) init() {
+	if f.down == nil {
+		f.down = make([]bool, len(f.Regions))
+	}
+}
+
+// Run health-checks the regions every CheckInterval until ctx is done.
+func (f *Failover) Run(ctx context.Context) error {
+	t := time.NewTicker(f.checkInterval())
+	defer t.Stop()
+	for {
+		f.Check()
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// Check health-checks the regions once, marking them up or down, and
+// makes the most preferred healthy region active.
+func (f *Failover) Check() {
+	healthy := -1
+	errs := make([]error, len(f.Regions))
+	for i := len(f.Regions) - 1; i >= 0; i-- {
+		_, err := f.Regions[i].Info()
+		failed := regionFailed(err)
+		f.setDown(i, failed)
+		if failed {
+			errs[i] = err
+		} else {
+			healthy = i
+		}
+	}
+	if healthy < 0 {
+		return
+	}
+	f.mu.Lock()
+	active := f.active
+	f.mu.Unlock()
+	f.use(healthy, errs[active])
+}
+
+func (f *Failover) checkInterval() time.Duration {
+	if f.CheckInterval > 0 {
+		return f.CheckInterval
+	}
+	return 10 * time.Second
+}
+
+// regionFailed tells whether err means the region is unavailable, rather
+// than the request being refused, e.g. for a missing queue.
+func regionFailed(err error) bool {
+	return err != nil && !permanent(err)
+}
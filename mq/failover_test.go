@@ -0,0 +1,86 @@
+package mq
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/iron-io/iron_go3/config"
+	"github.com/iron-io/iron_go3/irontest"
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestFailover(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("failover", func() {
+		primary, secondary := irontest.NewServer(), irontest.NewServer()
+		defer primary.Close()
+		defer secondary.Close()
+
+		// the primary fails requests while down is set
+		var down int32
+		flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.LoadInt32(&down) == 1 {
+				http.Error(w, `{"msg":"down"}`, http.StatusInternalServerError)
+				return
+			}
+			primary.ServeHTTP(w, r)
+		}))
+		defer flaky.Close()
+		s := primary.Settings("iron_mq")
+		host, port, _ := net.SplitHostPort(strings.TrimPrefix(flaky.URL, "http://"))
+		p, _ := strconv.Atoi(port)
+		s.Host, s.Port = host, uint16(p)
+
+		var events []FailoverEvent
+		f := NewFailover("orders", s, secondary.Settings("iron_mq"))
+		f.OnEvent = func(e FailoverEvent) { events = append(events, e) }
+
+		It("uses the primary while it is healthy", func() {
+			_, err := f.PushWith(PushOptions{}, "a")
+			Expect(err, ToBeNil)
+			Expect(len(primary.MQ.Messages("orders")), ToEqual, 1)
+			Expect(len(events), ToEqual, 0)
+		})
+
+		It("fails over when the primary fails", func() {
+			atomic.StoreInt32(&down, 1)
+			_, err := f.PushWith(PushOptions{}, "b")
+			Expect(err, ToBeNil)
+			Expect(secondary.MQ.Messages("orders"), ToDeepEqual, []string{"b"})
+			Expect(len(events), ToEqual, 1)
+			Expect(events[0].Kind, ToEqual, FailoverSwitched)
+			Expect(events[0].To, ToEqual, 1)
+			Expect(events[0].Err, ToNotBeNil)
+
+			msgs, err := f.ReserveWith(ReserveOptions{})
+			Expect(err, ToBeNil)
+			Expect(len(msgs), ToEqual, 1)
+			Expect(msgs[0].Delete(), ToBeNil)
+			Expect(len(secondary.MQ.Messages("orders")), ToEqual, 0)
+		})
+
+		It("fails back once the primary recovers", func() {
+			f.Check()
+			Expect(len(events), ToEqual, 1)
+			atomic.StoreInt32(&down, 0)
+			f.Check()
+			Expect(len(events), ToEqual, 2)
+			Expect(events[1].Kind, ToEqual, FailoverRestored)
+			Expect(events[1].From, ToEqual, 1)
+			Expect(f.Active().Settings.Port, ToEqual, s.Port)
+		})
+
+		It("passes refusals through", func() {
+			f := NewFailover("orders", s, config.Settings{Host: "unused.invalid"})
+			_, err := f.ReserveWith(ReserveOptions{N: MaxReserve + 1})
+			Expect(err, ToNotBeNil)
+			Expect(f.Active().Settings.Port, ToEqual, s.Port)
+		})
+	})
+}
@@ -0,0 +1,62 @@
+package mq
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/iron-io/iron_go3/irontest"
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestFilter(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("filtering consumers", func() {
+		srv := irontest.NewServer()
+		defer srv.Close()
+		q := Queue{Settings: srv.Settings("iron_mq"), Name: "shared"}
+		p := NewProducer(q, "p")
+
+		var handled []string
+		c := NewConsumer(q, HandlerFunc(func(ctx context.Context, d *Delivery) error {
+			handled = append(handled, string(d.Envelope.Payload))
+			return nil
+		}))
+		c.Filter = MatchHeader("version", "2")
+
+		It("releases deliveries it doesn't match", func() {
+			p.Push([]byte("v1"), map[string]string{"version": "1"})
+			p.Push([]byte("v2"), map[string]string{"version": "2"})
+			q.PushString("raw")
+			c.RunOnce(context.Background(), 10)
+			Expect(handled, ToDeepEqual, []string{"v2"})
+			Expect(len(srv.MQ.Messages("shared")), ToEqual, 2)
+
+			msgs, _ := q.PopWith(PopOptions{N: 10})
+			Expect(len(msgs), ToEqual, 2)
+		})
+
+		It("delays rejected deliveries", func() {
+			c.RejectDelay = time.Minute
+			q.PushString("raw")
+			c.RunOnce(context.Background(), 10)
+			msgs, _ := q.ReserveWith(ReserveOptions{})
+			Expect(len(msgs), ToEqual, 0)
+			q.Clear()
+		})
+
+		It("reroutes deliveries it doesn't match", func() {
+			handled = nil
+			c.Reroute = &Queue{Settings: q.Settings, Name: "shared-v1"}
+			p.Push([]byte("v1"), map[string]string{"version": "1"})
+			p.Push([]byte("v2"), map[string]string{"version": "2"})
+			c.RunOnce(context.Background(), 10)
+			Expect(handled, ToDeepEqual, []string{"v2"})
+			Expect(len(srv.MQ.Messages("shared")), ToEqual, 0)
+			Expect(len(srv.MQ.Messages("shared-v1")), ToEqual, 1)
+			e, _ := OpenEnvelope(srv.MQ.Messages("shared-v1")[0])
+			Expect(string(e.Payload), ToEqual, "v1")
+		})
+	})
+}
@@ -0,0 +1,29 @@
+package mq
+
+import "context"
+
+// QueueAPI is the message operations of a Queue, for code that takes a
+// queue to depend on, so it can be tested with a fake, e.g. one of the
+// mocks package.
+type QueueAPI interface {
+	Info() (QueueInfo, error)
+	PushString(body string) (string, error)
+	PushStrings(bodies ...string) ([]string, error)
+	PushWith(opts PushOptions, bodies ...string) ([]string, error)
+	PushMessages(msgs ...Message) ([]string, error)
+	ReserveWith(opts ReserveOptions) ([]Message, error)
+	PeekN(n int) ([]Message, error)
+	DeleteMessage(msgId, reservationId string) error
+	DeleteReservedMessages(messages []Message) error
+	TouchMessageFor(msgId, reservationId string, timeout int) (string, error)
+	ReleaseMessage(msgId, reservationId string, delay int64) error
+	Clear() error
+}
+
+// ConsumerAPI is implemented by Consumer, for code that starts consumers
+// to depend on.
+type ConsumerAPI interface {
+	Run(ctx context.Context) error
+	RunOnce(ctx context.Context, n int) (int, error)
+	Drain(ctx context.Context, opts DrainOptions) (DrainResult, error)
+}
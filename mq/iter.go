@@ -0,0 +1,66 @@
+package mq
+
+import (
+	"context"
+	"iter"
+
+	"github.com/iron-io/iron_go3/config"
+)
+
+// queuesPerPage is the largest page of queues the API returns.
+const queuesPerPage = 100
+
+// AllQueues returns an iterator over the queues of the project of s whose
+// name starts with prefix, fetching pages as needed:
+//
+//	for q, err := range mq.AllQueues(settings, "orders-") {
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+func AllQueues(s config.Settings, prefix string) iter.Seq2[Queue, error] {
+	return Queue{Settings: s}.AllQueues(prefix)
+}
+
+// AllQueues iterates over the queues of q's project, see the AllQueues
+// function.
+func (q Queue) AllQueues(prefix string) iter.Seq2[Queue, error] {
+	return func(yield func(Queue, error) bool) {
+		prev := ""
+		for {
+			queues, err := q.ListQueues(prefix, prev, queuesPerPage)
+			if err != nil {
+				yield(Queue{}, err)
+				return
+			}
+			for _, q := range queues {
+				if !yield(q, nil) {
+					return
+				}
+			}
+			if len(queues) < queuesPerPage {
+				return
+			}
+			prev = queues[len(queues)-1].Name
+		}
+	}
+}
+
+// PeekAll returns an iterator over the messages of the queue, without
+// reserving them. Like Search, it only sees the first MaxPeek visible
+// messages, which the API doesn't page past.
+func (q Queue) PeekAll(ctx context.Context) iter.Seq2[Message, error] {
+	return func(yield func(Message, error) bool) {
+		msgs, err := q.peek(ctx, MaxPeek)
+		if err != nil {
+			yield(Message{}, err)
+			return
+		}
+		for _, m := range msgs {
+			if !yield(m, nil) {
+				return
+			}
+		}
+	}
+}
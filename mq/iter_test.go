@@ -0,0 +1,49 @@
+package mq
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/iron-io/iron_go3/irontest"
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestIterators(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("iterating over listings", func() {
+		srv := irontest.NewServer()
+		defer srv.Close()
+		s := srv.Settings("iron_mq")
+		for i := 0; i < 150; i++ {
+			Queue{Settings: s, Name: fmt.Sprintf("q-%03d", i)}.PushString("m")
+		}
+		Queue{Settings: s, Name: "other"}.PushString("m")
+
+		It("ranges over all queues", func() {
+			var names []string
+			for q, err := range AllQueues(s, "q-") {
+				Expect(err, ToBeNil)
+				names = append(names, q.Name)
+			}
+			Expect(len(names), ToEqual, 150)
+			Expect(names[0], ToEqual, "q-000")
+			Expect(names[149], ToEqual, "q-149")
+		})
+
+		It("ranges over peeked messages", func() {
+			q := Queue{Settings: s, Name: "peeked"}
+			q.PushStrings("a", "b", "c")
+			var bodies []string
+			for m, err := range q.PeekAll(context.Background()) {
+				Expect(err, ToBeNil)
+				bodies = append(bodies, m.Body)
+				if len(bodies) == 2 {
+					break
+				}
+			}
+			Expect(bodies, ToDeepEqual, []string{"a", "b"})
+		})
+	})
+}
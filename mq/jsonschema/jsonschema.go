@@ -0,0 +1,496 @@
+// Package jsonschema validates JSON documents against a JSON Schema, for
+// use as an mq.Validator.
+//
+// It implements the validation keywords of draft 7 that need no external
+// resources: type, enum, const, the numeric, string, array and object
+// limits, properties, patternProperties, additionalProperties, items,
+// required, allOf, anyOf, oneOf and not. Annotations like format and
+// description are ignored. References ($ref) are not supported and make
+// Compile fail.
+package jsonschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// A Schema is a compiled JSON Schema.
+type Schema struct {
+	always *bool // set for the boolean schemas true and false
+
+	types []string
+	enum  []interface{}
+	cnst  *interface{}
+
+	minimum, maximum                   *float64
+	exclusiveMinimum, exclusiveMaximum *float64
+	multipleOf                         *float64
+
+	minLength, maxLength *int
+	pattern              *regexp.Regexp
+
+	items           *Schema
+	minItems        *int
+	maxItems        *int
+	uniqueItems     bool
+	properties      map[string]*Schema
+	patternProps    map[*regexp.Regexp]*Schema
+	additionalProps *Schema
+	required        []string
+	minProps        *int
+	maxProps        *int
+	allOf, anyOf    []*Schema
+	oneOf           []*Schema
+	not             *Schema
+}
+
+// An Error is a place where a document doesn't match its schema.
+type Error struct {
+	// Path points at the offending value, e.g. "/items/0/name", it is
+	// empty for the document itself.
+	Path    string
+	Message string
+}
+
+func (e Error) Error() string {
+	if e.Path == "" {
+		return e.Message
+	}
+	return e.Path + ": " + e.Message
+}
+
+// Errors are all the places where a document doesn't match its schema.
+type Errors []Error
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return "jsonschema: " + strings.Join(msgs, "; ")
+}
+
+// Compile parses a schema.
+func Compile(schema []byte) (*Schema, error) {
+	var raw interface{}
+	d := json.NewDecoder(bytes.NewReader(schema))
+	d.UseNumber()
+	if err := d.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("jsonschema: %w", err)
+	}
+	return compile(raw, "")
+}
+
+// MustCompile is like Compile but panics if the schema is invalid.
+func MustCompile(schema string) *Schema {
+	s, err := Compile([]byte(schema))
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+func compile(raw interface{}, path string) (*Schema, error) {
+	s := &Schema{}
+	switch v := raw.(type) {
+	case bool:
+		s.always = &v
+		return s, nil
+	case map[string]interface{}:
+		return s, s.compileObject(v, path)
+	}
+	return nil, fmt.Errorf("jsonschema: %s: schema must be an object or a boolean", pathOrRoot(path))
+}
+
+func (s *Schema) compileObject(m map[string]interface{}, path string) (err error) {
+	fail := func(key, format string, v ...interface{}) error {
+		return fmt.Errorf("jsonschema: %s/%s: %s", path, key, fmt.Sprintf(format, v...))
+	}
+	if _, ok := m["$ref"]; ok {
+		return fail("$ref", "references are not supported")
+	}
+
+	for key, v := range m {
+		switch key {
+		case "type":
+			switch t := v.(type) {
+			case string:
+				s.types = []string{t}
+			case []interface{}:
+				for _, e := range t {
+					name, ok := e.(string)
+					if !ok {
+						return fail(key, "must be a string or an array of strings")
+					}
+					s.types = append(s.types, name)
+				}
+			default:
+				return fail(key, "must be a string or an array of strings")
+			}
+		case "enum":
+			enum, ok := v.([]interface{})
+			if !ok {
+				return fail(key, "must be an array")
+			}
+			s.enum = enum
+		case "const":
+			c := v
+			s.cnst = &c
+		case "minimum", "maximum", "exclusiveMinimum", "exclusiveMaximum", "multipleOf":
+			n, ok := number(v)
+			if !ok {
+				return fail(key, "must be a number")
+			}
+			switch key {
+			case "minimum":
+				s.minimum = &n
+			case "maximum":
+				s.maximum = &n
+			case "exclusiveMinimum":
+				s.exclusiveMinimum = &n
+			case "exclusiveMaximum":
+				s.exclusiveMaximum = &n
+			case "multipleOf":
+				if n <= 0 {
+					return fail(key, "must be greater than 0")
+				}
+				s.multipleOf = &n
+			}
+		case "minLength", "maxLength", "minItems", "maxItems", "minProperties", "maxProperties":
+			n, ok := number(v)
+			if !ok || n < 0 || n != math.Trunc(n) {
+				return fail(key, "must be a non-negative integer")
+			}
+			i := int(n)
+			switch key {
+			case "minLength":
+				s.minLength = &i
+			case "maxLength":
+				s.maxLength = &i
+			case "minItems":
+				s.minItems = &i
+			case "maxItems":
+				s.maxItems = &i
+			case "minProperties":
+				s.minProps = &i
+			case "maxProperties":
+				s.maxProps = &i
+			}
+		case "pattern":
+			p, ok := v.(string)
+			if !ok {
+				return fail(key, "must be a string")
+			}
+			if s.pattern, err = regexp.Compile(p); err != nil {
+				return fail(key, "%v", err)
+			}
+		case "uniqueItems":
+			s.uniqueItems, _ = v.(bool)
+		case "required":
+			req, ok := v.([]interface{})
+			if !ok {
+				return fail(key, "must be an array of strings")
+			}
+			for _, r := range req {
+				name, ok := r.(string)
+				if !ok {
+					return fail(key, "must be an array of strings")
+				}
+				s.required = append(s.required, name)
+			}
+		case "items":
+			if _, ok := v.([]interface{}); ok {
+				return fail(key, "tuple validation is not supported")
+			}
+			if s.items, err = compile(v, path+"/items"); err != nil {
+				return err
+			}
+		case "additionalProperties":
+			if s.additionalProps, err = compile(v, path+"/additionalProperties"); err != nil {
+				return err
+			}
+		case "not":
+			if s.not, err = compile(v, path+"/not"); err != nil {
+				return err
+			}
+		case "properties", "patternProperties":
+			props, ok := v.(map[string]interface{})
+			if !ok {
+				return fail(key, "must be an object")
+			}
+			for name, raw := range props {
+				sub, err := compile(raw, path+"/"+key+"/"+name)
+				if err != nil {
+					return err
+				}
+				if key == "properties" {
+					if s.properties == nil {
+						s.properties = map[string]*Schema{}
+					}
+					s.properties[name] = sub
+					continue
+				}
+				re, err := regexp.Compile(name)
+				if err != nil {
+					return fail(key, "%v", err)
+				}
+				if s.patternProps == nil {
+					s.patternProps = map[*regexp.Regexp]*Schema{}
+				}
+				s.patternProps[re] = sub
+			}
+		case "allOf", "anyOf", "oneOf":
+			list, ok := v.([]interface{})
+			if !ok || len(list) == 0 {
+				return fail(key, "must be a non-empty array")
+			}
+			subs := make([]*Schema, len(list))
+			for i, raw := range list {
+				if subs[i], err = compile(raw, path+"/"+key+"/"+strconv.Itoa(i)); err != nil {
+					return err
+				}
+			}
+			switch key {
+			case "allOf":
+				s.allOf = subs
+			case "anyOf":
+				s.anyOf = subs
+			case "oneOf":
+				s.oneOf = subs
+			}
+		}
+	}
+	return nil
+}
+
+// Validate checks the JSON document data against s, returning Errors if it
+// doesn't match.
+func (s *Schema) Validate(data []byte) error {
+	var doc interface{}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	if err := d.Decode(&doc); err != nil {
+		return Errors{{Message: "invalid JSON: " + err.Error()}}
+	}
+	if errs := s.validate(doc, ""); len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (s *Schema) validate(v interface{}, path string) (errs Errors) {
+	add := func(format string, args ...interface{}) {
+		errs = append(errs, Error{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if s.always != nil {
+		if !*s.always {
+			add("no value is allowed")
+		}
+		return errs
+	}
+
+	if len(s.types) > 0 && !hasType(v, s.types) {
+		add("expected %s, got %s", strings.Join(s.types, " or "), typeOf(v))
+		return errs
+	}
+	if s.enum != nil {
+		found := false
+		for _, e := range s.enum {
+			found = found || equal(v, e)
+		}
+		if !found {
+			add("value is not one of the allowed values")
+		}
+	}
+	if s.cnst != nil && !equal(v, *s.cnst) {
+		add("value must be %v", *s.cnst)
+	}
+
+	switch v := v.(type) {
+	case json.Number:
+		n, _ := v.Float64()
+		switch {
+		case s.minimum != nil && n < *s.minimum:
+			add("must be at least %v", *s.minimum)
+		case s.maximum != nil && n > *s.maximum:
+			add("must be at most %v", *s.maximum)
+		case s.exclusiveMinimum != nil && n <= *s.exclusiveMinimum:
+			add("must be greater than %v", *s.exclusiveMinimum)
+		case s.exclusiveMaximum != nil && n >= *s.exclusiveMaximum:
+			add("must be less than %v", *s.exclusiveMaximum)
+		}
+		if s.multipleOf != nil {
+			if q := n / *s.multipleOf; q != math.Trunc(q) {
+				add("must be a multiple of %v", *s.multipleOf)
+			}
+		}
+
+	case string:
+		n := utf8.RuneCountInString(v)
+		if s.minLength != nil && n < *s.minLength {
+			add("must be at least %d characters long", *s.minLength)
+		}
+		if s.maxLength != nil && n > *s.maxLength {
+			add("must be at most %d characters long", *s.maxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(v) {
+			add("must match %s", s.pattern)
+		}
+
+	case []interface{}:
+		if s.minItems != nil && len(v) < *s.minItems {
+			add("must have at least %d items", *s.minItems)
+		}
+		if s.maxItems != nil && len(v) > *s.maxItems {
+			add("must have at most %d items", *s.maxItems)
+		}
+		if s.uniqueItems {
+			for i := range v {
+				for j := i + 1; j < len(v); j++ {
+					if equal(v[i], v[j]) {
+						add("items %d and %d are equal", i, j)
+					}
+				}
+			}
+		}
+		if s.items != nil {
+			for i, item := range v {
+				errs = append(errs, s.items.validate(item, path+"/"+strconv.Itoa(i))...)
+			}
+		}
+
+	case map[string]interface{}:
+		if s.minProps != nil && len(v) < *s.minProps {
+			add("must have at least %d properties", *s.minProps)
+		}
+		if s.maxProps != nil && len(v) > *s.maxProps {
+			add("must have at most %d properties", *s.maxProps)
+		}
+		for _, name := range s.required {
+			if _, ok := v[name]; !ok {
+				add("missing required property %q", name)
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			sub := path + "/" + escape(name)
+			matched := false
+			if ps, ok := s.properties[name]; ok {
+				matched = true
+				errs = append(errs, ps.validate(v[name], sub)...)
+			}
+			for re, ps := range s.patternProps {
+				if re.MatchString(name) {
+					matched = true
+					errs = append(errs, ps.validate(v[name], sub)...)
+				}
+			}
+			if !matched && s.additionalProps != nil {
+				errs = append(errs, s.additionalProps.validate(v[name], sub)...)
+			}
+		}
+	}
+
+	for _, sub := range s.allOf {
+		errs = append(errs, sub.validate(v, path)...)
+	}
+	if s.anyOf != nil {
+		ok := false
+		for _, sub := range s.anyOf {
+			ok = ok || len(sub.validate(v, path)) == 0
+		}
+		if !ok {
+			add("must match at least one schema of anyOf")
+		}
+	}
+	if s.oneOf != nil {
+		n := 0
+		for _, sub := range s.oneOf {
+			if len(sub.validate(v, path)) == 0 {
+				n++
+			}
+		}
+		if n != 1 {
+			add("must match exactly one schema of oneOf, matches %d", n)
+		}
+	}
+	if s.not != nil && len(s.not.validate(v, path)) == 0 {
+		add("must not match the schema of not")
+	}
+	return errs
+}
+
+func hasType(v interface{}, types []string) bool {
+	t := typeOf(v)
+	for _, want := range types {
+		if want == t || (want == "number" && t == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+func typeOf(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number:
+		if n, err := v.Float64(); err == nil && n == math.Trunc(n) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	}
+	return "object"
+}
+
+// equal compares JSON values, numbers by value.
+func equal(a, b interface{}) bool {
+	an, aok := a.(json.Number)
+	bn, bok := b.(json.Number)
+	if aok && bok {
+		af, _ := an.Float64()
+		bf, _ := bn.Float64()
+		return af == bf
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func number(v interface{}) (float64, bool) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return 0, false
+	}
+	f, err := n.Float64()
+	return f, err == nil
+}
+
+// escape escapes a property name for a JSON pointer.
+func escape(name string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(name)
+}
+
+func pathOrRoot(path string) string {
+	if path == "" {
+		return "/"
+	}
+	return path
+}
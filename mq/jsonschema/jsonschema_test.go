@@ -0,0 +1,77 @@
+package jsonschema_test
+
+import (
+	"testing"
+
+	"github.com/iron-io/iron_go3/mq/jsonschema"
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestSchema(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("validating", func() {
+		s := jsonschema.MustCompile(`{
+			"type": "object",
+			"required": ["id", "items"],
+			"additionalProperties": false,
+			"properties": {
+				"id": {"type": "integer", "minimum": 1},
+				"status": {"enum": ["new", "paid"]},
+				"email": {"type": "string", "pattern": "@"},
+				"items": {
+					"type": "array",
+					"minItems": 1,
+					"items": {"type": "object", "required": ["sku"]}
+				}
+			}
+		}`)
+
+		It("accepts matching documents", func() {
+			err := s.Validate([]byte(`{"id": 1, "status": "new", "items": [{"sku": "a"}]}`))
+			Expect(err, ToBeNil)
+		})
+
+		It("reports every mismatch with its path", func() {
+			err := s.Validate([]byte(`{"id": 0, "status": "lost", "items": [{}], "extra": 1}`))
+			errs, ok := err.(jsonschema.Errors)
+			Expect(ok, ToBeTrue)
+			var paths []string
+			for _, e := range errs {
+				paths = append(paths, e.Path)
+			}
+			Expect(paths, ToDeepEqual, []string{"/extra", "/id", "/items/0", "/status"})
+		})
+
+		It("checks types", func() {
+			Expect(s.Validate([]byte(`[]`)).Error(), ToEqual, "jsonschema: expected object, got array")
+			Expect(s.Validate([]byte(`{"id": 1.5, "items": [{"sku": 1}]}`)), ToNotBeNil)
+		})
+
+		It("rejects invalid JSON", func() {
+			Expect(s.Validate([]byte(`{`)), ToNotBeNil)
+		})
+
+		It("combines schemas", func() {
+			s := jsonschema.MustCompile(`{"oneOf": [{"type": "string"}, {"type": "number", "multipleOf": 2}]}`)
+			Expect(s.Validate([]byte(`"x"`)), ToBeNil)
+			Expect(s.Validate([]byte(`4`)), ToBeNil)
+			Expect(s.Validate([]byte(`3`)), ToNotBeNil)
+			Expect(s.Validate([]byte(`true`)), ToNotBeNil)
+		})
+	})
+
+	Describe("compiling", func() {
+		It("refuses references", func() {
+			_, err := jsonschema.Compile([]byte(`{"properties": {"a": {"$ref": "#/definitions/a"}}}`))
+			Expect(err.Error(), ToEqual, "jsonschema: /properties/a/$ref: references are not supported")
+		})
+
+		It("refuses malformed keywords", func() {
+			_, err := jsonschema.Compile([]byte(`{"minLength": -1}`))
+			Expect(err, ToNotBeNil)
+			_, err = jsonschema.Compile([]byte(`{"pattern": "("}`))
+			Expect(err, ToNotBeNil)
+		})
+	})
+}
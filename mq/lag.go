@@ -0,0 +1,144 @@
+package mq
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// A LagEstimate tells how fast a queue is processed and when it will be
+// empty at that pace.
+type LagEstimate struct {
+	Queue string
+	Time  time.Time
+	Size  int
+	// ArrivalRate and ProcessRate are the messages pushed and removed per
+	// second over the estimator's window.
+	ArrivalRate float64
+	ProcessRate float64
+	// TimeToDrain is how long until the queue is empty, 0 if it is, and
+	// Forever if it isn't shrinking.
+	TimeToDrain time.Duration
+}
+
+// Forever is the TimeToDrain of queues that aren't shrinking.
+const Forever = time.Duration(math.MaxInt64)
+
+// Draining tells whether the queue is shrinking, or empty.
+func (e LagEstimate) Draining() bool { return e.TimeToDrain != Forever }
+
+// A LagEstimator samples the size of a queue and the number of messages
+// ever pushed to it, and estimates from them how long the backlog takes
+// to clear. It counts the work of all consumers of the queue, wherever they
+// run.
+//
+//	lag := mq.NewLagEstimator(q)
+//	lag.OnEstimate = func(e mq.LagEstimate) {
+//		drainSeconds.Set(e.TimeToDrain.Seconds()) // e.g. a Prometheus gauge
+//	}
+//	go lag.Run(ctx)
+type LagEstimator struct {
+	Queue Queue
+	// Interval is how often Run samples, 10 seconds by default.
+	Interval time.Duration
+	// Window is how far back rates are averaged, 5 minutes by default.
+	Window time.Duration
+	// OnEstimate, if set, gets every estimate.
+	OnEstimate func(LagEstimate)
+	// OnError is told about failed samples.
+	OnError func(error)
+
+	mu      sync.Mutex
+	samples []lagSample
+	last    LagEstimate
+}
+
+type lagSample struct {
+	at          time.Time
+	size, total int
+}
+
+// NewLagEstimator returns a LagEstimator of q.
+func NewLagEstimator(q Queue) *LagEstimator {
+	return &LagEstimator{Queue: q}
+}
+
+// Run samples every Interval until ctx is done.
+func (l *LagEstimator) Run(ctx context.Context) error {
+	t := time.NewTicker(l.interval())
+	defer t.Stop()
+	for {
+		if _, err := l.Sample(); err != nil && l.OnError != nil {
+			l.OnError(err)
+		}
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// Sample takes a sample of the queue and returns the resulting estimate.
+func (l *LagEstimator) Sample() (LagEstimate, error) {
+	info, err := l.Queue.Info()
+	if err != nil {
+		return LagEstimate{}, err
+	}
+	e := l.add(lagSample{at: time.Now(), size: info.Size, total: info.TotalMessages})
+	if l.OnEstimate != nil {
+		l.OnEstimate(e)
+	}
+	return e, nil
+}
+
+// Estimate returns the latest estimate, the zero LagEstimate before the
+// first sample.
+func (l *LagEstimator) Estimate() LagEstimate {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.last
+}
+
+func (l *LagEstimator) add(s lagSample) LagEstimate {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.samples = append(l.samples, s)
+	// keep one sample older than the window, so it is always covered
+	cut := 0
+	for cut+1 < len(l.samples) && s.at.Sub(l.samples[cut+1].at) >= l.window() {
+		cut++
+	}
+	l.samples = l.samples[cut:]
+
+	e := LagEstimate{Queue: l.Queue.Name, Time: s.at, Size: s.size, TimeToDrain: Forever}
+	first := l.samples[0]
+	if secs := s.at.Sub(first.at).Seconds(); secs > 0 {
+		e.ArrivalRate = float64(s.total-first.total) / secs
+		e.ProcessRate = e.ArrivalRate - float64(s.size-first.size)/secs
+	}
+	switch drain := e.ProcessRate - e.ArrivalRate; {
+	case s.size == 0:
+		e.TimeToDrain = 0
+	case drain > 0:
+		e.TimeToDrain = time.Duration(float64(s.size) / drain * float64(time.Second))
+	}
+	l.last = e
+	return e
+}
+
+func (l *LagEstimator) interval() time.Duration {
+	if l.Interval > 0 {
+		return l.Interval
+	}
+	return 10 * time.Second
+}
+
+func (l *LagEstimator) window() time.Duration {
+	if l.Window > 0 {
+		return l.Window
+	}
+	return 5 * time.Minute
+}
@@ -0,0 +1,69 @@
+package mq
+
+import (
+	"testing"
+	"time"
+
+	"github.com/iron-io/iron_go3/irontest"
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestLagEstimator(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("lag estimation", func() {
+		start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+		at := func(s int) time.Time { return start.Add(time.Duration(s) * time.Second) }
+
+		It("estimates time to drain", func() {
+			l := &LagEstimator{Queue: Queue{Name: "jobs"}, Window: time.Minute}
+			e := l.add(lagSample{at: at(0), size: 1000, total: 5000})
+			Expect(e.Draining(), ToEqual, false)
+
+			// 10/s pushed, 30/s processed
+			e = l.add(lagSample{at: at(10), size: 800, total: 5100})
+			Expect(e.ArrivalRate, ToEqual, 10.0)
+			Expect(e.ProcessRate, ToEqual, 30.0)
+			Expect(e.TimeToDrain, ToEqual, 40*time.Second)
+			Expect(l.Estimate(), ToEqual, e)
+		})
+
+		It("averages over the window", func() {
+			l := &LagEstimator{Window: 20 * time.Second}
+			l.add(lagSample{at: at(0), size: 0, total: 0})
+			l.add(lagSample{at: at(10), size: 100, total: 100})
+			l.add(lagSample{at: at(20), size: 100, total: 200})
+			e := l.add(lagSample{at: at(30), size: 50, total: 250})
+			// from the sample at 10s
+			Expect(e.ArrivalRate, ToEqual, 7.5)
+			Expect(e.ProcessRate, ToEqual, 10.0)
+			Expect(e.TimeToDrain, ToEqual, 20*time.Second)
+			Expect(len(l.samples), ToEqual, 3)
+		})
+
+		It("tells growing queues never drain", func() {
+			l := &LagEstimator{}
+			l.add(lagSample{at: at(0), size: 10, total: 10})
+			e := l.add(lagSample{at: at(10), size: 20, total: 30})
+			Expect(e.TimeToDrain, ToEqual, Forever)
+			Expect(e.Draining(), ToEqual, false)
+			e = l.add(lagSample{at: at(20), size: 0, total: 30})
+			Expect(e.TimeToDrain, ToEqual, time.Duration(0))
+		})
+
+		It("samples queues", func() {
+			srv := irontest.NewServer()
+			defer srv.Close()
+			q := Queue{Settings: srv.Settings("iron_mq"), Name: "jobs"}
+			q.PushStrings("a", "b", "c")
+
+			var got []LagEstimate
+			l := NewLagEstimator(q)
+			l.OnEstimate = func(e LagEstimate) { got = append(got, e) }
+			e, err := l.Sample()
+			Expect(err, ToBeNil)
+			Expect(e.Size, ToEqual, 3)
+			Expect(len(got), ToEqual, 1)
+		})
+	})
+}
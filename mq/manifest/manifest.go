@@ -0,0 +1,261 @@
+// Package manifest applies a queue topology described in a YAML or JSON
+// file:
+//
+//	prefix: "{{.env}}-"
+//	prune: true
+//	defaults:
+//	  message_expiration: 604800
+//	queues:
+//	  - name: "{{.env}}-orders"
+//	    message_timeout: 120
+//	    alerts:
+//	      - {type: fixed, direction: asc, trigger: 1000, queue: "{{.env}}-alerts"}
+//	  - name: "{{.env}}-emails"
+//	    type: unicast
+//	    push:
+//	      subscribers:
+//	        - {name: mailer, url: "https://mailer.example.com/{{.env}}"}
+//	      error_queue: "{{.env}}-emails-errors"
+//	create_error_queues: true
+//
+// Manifests are text/template templates, executed with the variables
+// passed to Load or Parse before being parsed, so one manifest can describe
+// several environments. Queues take the fields of mq.QueueInfo, by their
+// JSON names.
+//
+//	m, err := manifest.Load("queues.yaml", map[string]string{"env": "staging"})
+//	plan, err := m.Plan(settings)
+//	fmt.Print(plan) // review, then
+//	plan, err = m.Apply(settings)
+package manifest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/iron-io/iron_go3/config"
+	"github.com/iron-io/iron_go3/mq"
+	"gopkg.in/yaml.v3"
+)
+
+// A Manifest describes the queues of a project.
+type Manifest struct {
+	Queues []mq.QueueInfo `json:"queues"`
+	// Prune deletes the project's queues that start with Prefix and aren't
+	// listed in Queues. Without a Prefix, it deletes all unlisted queues.
+	Prune  bool   `json:"prune,omitempty"`
+	Prefix string `json:"prefix,omitempty"`
+	// VerifyErrorQueues and CreateErrorQueues check, or create, the error
+	// queues of push queues that the manifest doesn't list, see
+	// mq.EnsureOptions. Listed error queues are applied before the queues
+	// using them, and created ones are never pruned.
+	VerifyErrorQueues bool `json:"verify_error_queues,omitempty"`
+	CreateErrorQueues bool `json:"create_error_queues,omitempty"`
+	// Defaults, if set, are the settings of the queues that leave them
+	// zero, instead of mq.Defaults.
+	Defaults *mq.QueueDefaults `json:"defaults,omitempty"`
+}
+
+// queue returns the queue called name in the project of settings.
+func (m *Manifest) queue(settings config.Settings, name string) mq.Queue {
+	return mq.Queue{Settings: settings, Name: name, Defaults: m.Defaults}
+}
+
+// options returns the options to ensure d with: error queues the manifest
+// lists are applied by it.
+func (m *Manifest) options(d mq.QueueInfo) mq.EnsureOptions {
+	if d.Push != nil && m.listed()[d.Push.ErrorQueue] {
+		return mq.EnsureOptions{}
+	}
+	return mq.EnsureOptions{VerifyErrorQueues: m.VerifyErrorQueues, CreateErrorQueues: m.CreateErrorQueues}
+}
+
+func (m *Manifest) listed() map[string]bool {
+	listed := map[string]bool{}
+	for _, q := range m.Queues {
+		listed[q.Name] = true
+	}
+	return listed
+}
+
+// Load reads the manifest at path, see Parse.
+func Load(path string, vars map[string]string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m, err := Parse(data, vars)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return m, nil
+}
+
+// Parse executes data as a template with vars and parses the result as
+// YAML, or JSON, which YAML includes. Variables missing from vars are
+// errors.
+func Parse(data []byte, vars map[string]string) (*Manifest, error) {
+	t, err := template.New("manifest").Option("missingkey=error").Parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("manifest: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, vars); err != nil {
+		return nil, fmt.Errorf("manifest: %w", err)
+	}
+
+	// decode through JSON so the fields take their JSON names
+	var raw interface{}
+	if err := yaml.Unmarshal(buf.Bytes(), &raw); err != nil {
+		return nil, fmt.Errorf("manifest: %w", err)
+	}
+	j, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("manifest: %w", err)
+	}
+	d := json.NewDecoder(bytes.NewReader(j))
+	d.DisallowUnknownFields()
+	var m Manifest
+	if err := d.Decode(&m); err != nil {
+		return nil, fmt.Errorf("manifest: %w", err)
+	}
+	return &m, m.validate()
+}
+
+func (m *Manifest) validate() error {
+	seen := map[string]bool{}
+	for i, q := range m.Queues {
+		switch {
+		case q.Name == "":
+			return fmt.Errorf("manifest: queue %d has no name", i)
+		case seen[q.Name]:
+			return fmt.Errorf("manifest: queue %s is listed twice", q.Name)
+		case m.Prune && !strings.HasPrefix(q.Name, m.Prefix):
+			return fmt.Errorf("manifest: queue %s doesn't start with the prefix %s", q.Name, m.Prefix)
+		}
+		seen[q.Name] = true
+	}
+	return nil
+}
+
+// A Plan lists the changes applying a manifest makes, or made.
+type Plan struct {
+	Changes []mq.Change
+}
+
+// Pending returns the changes that aren't ActionNone.
+func (p *Plan) Pending() []mq.Change {
+	var pending []mq.Change
+	for _, c := range p.Changes {
+		if c.Action != mq.ActionNone {
+			pending = append(pending, c)
+		}
+	}
+	return pending
+}
+
+// String formats the pending changes, one queue per line marked "+" for
+// creates, "~" for updates and "-" for deletes, with the drifted fields of
+// updates indented below it.
+func (p *Plan) String() string {
+	var b strings.Builder
+	for _, c := range p.Pending() {
+		fmt.Fprintf(&b, "%s %s\n", map[string]string{
+			mq.ActionCreate: "+", mq.ActionUpdate: "~", mq.ActionDelete: "-",
+		}[c.Action], c.Queue)
+		for _, d := range c.Diffs {
+			fmt.Fprintf(&b, "    %s\n", d)
+		}
+	}
+	if b.Len() == 0 {
+		return "no changes\n"
+	}
+	return b.String()
+}
+
+// Plan returns the changes Apply would make in the project of settings.
+func (m *Manifest) Plan(settings config.Settings) (*Plan, error) {
+	p := &Plan{}
+	for _, d := range mq.OrderErrorQueuesFirst(m.Queues) {
+		c, err := m.queue(settings, d.Name).PlanWith(m.options(d), d)
+		if err != nil {
+			return nil, fmt.Errorf("manifest: planning %s: %w", d.Name, err)
+		}
+		p.Changes = append(p.Changes, c...)
+	}
+	if !m.Prune {
+		return p, nil
+	}
+	stale, err := m.stale(settings)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range stale {
+		p.Changes = append(p.Changes, mq.Change{Queue: name, Action: mq.ActionDelete})
+	}
+	return p, nil
+}
+
+// Apply makes the project of settings match the manifest. It goes on after
+// a queue failed, returning the changes made and all errors.
+func (m *Manifest) Apply(settings config.Settings) (*Plan, error) {
+	p := &Plan{}
+	var errs []error
+	for _, d := range mq.OrderErrorQueuesFirst(m.Queues) {
+		c, err := m.queue(settings, d.Name).EnsureWith(m.options(d), d)
+		p.Changes = append(p.Changes, c...)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if m.Prune && len(errs) == 0 {
+		stale, err := m.stale(settings)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		for _, name := range stale {
+			if err := (mq.Queue{Settings: settings, Name: name}).Delete(); err != nil {
+				errs = append(errs, fmt.Errorf("manifest: deleting %s: %w", name, err))
+				continue
+			}
+			p.Changes = append(p.Changes, mq.Change{Queue: name, Action: mq.ActionDelete})
+		}
+	}
+	return p, errors.Join(errs...)
+}
+
+// stale returns the queues with the manifest's prefix it doesn't list,
+// nor uses as error queues.
+func (m *Manifest) stale(settings config.Settings) ([]string, error) {
+	listed := m.listed()
+	for _, q := range m.Queues {
+		if q.Push != nil && q.Push.ErrorQueue != "" {
+			listed[q.Push.ErrorQueue] = true
+		}
+	}
+	var stale []string
+	prev := ""
+	for {
+		queues, err := mq.ListQueues(settings, m.Prefix, prev, 100)
+		if err != nil {
+			return nil, fmt.Errorf("manifest: listing queues: %w", err)
+		}
+		for _, q := range queues {
+			if !listed[q.Name] {
+				stale = append(stale, q.Name)
+			}
+		}
+		if len(queues) < 100 {
+			break
+		}
+		prev = queues[len(queues)-1].Name
+	}
+	sort.Strings(stale)
+	return stale, nil
+}
@@ -0,0 +1,133 @@
+package manifest_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/iron-io/iron_go3/irontest"
+	"github.com/iron-io/iron_go3/mq"
+	"github.com/iron-io/iron_go3/mq/manifest"
+	. "github.com/jeffh/go.bdd"
+)
+
+const queuesYAML = `
+prefix: "{{.env}}-"
+prune: true
+queues:
+  - name: "{{.env}}-orders"
+    message_timeout: 120
+    alerts:
+      - {type: fixed, direction: asc, trigger: 1000, queue: "{{.env}}-alerts"}
+  - name: "{{.env}}-emails"
+    type: unicast
+    push:
+      subscribers:
+        - {name: mailer, url: "https://mailer.example.com/{{.env}}"}
+`
+
+func TestManifest(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("parsing", func() {
+		It("templates and parses YAML", func() {
+			m, err := manifest.Parse([]byte(queuesYAML), map[string]string{"env": "staging"})
+			Expect(err, ToBeNil)
+			Expect(m.Prefix, ToEqual, "staging-")
+			Expect(len(m.Queues), ToEqual, 2)
+			Expect(m.Queues[0].Name, ToEqual, "staging-orders")
+			Expect(m.Queues[0].MessageTimeout, ToEqual, 120)
+			Expect(m.Queues[0].Alerts[0].Trigger, ToEqual, 1000)
+			Expect(m.Queues[1].Push.Subscribers[0].URL, ToEqual, "https://mailer.example.com/staging")
+		})
+
+		It("parses JSON files", func() {
+			path := filepath.Join(t.TempDir(), "queues.json")
+			os.WriteFile(path, []byte(`{"queues": [{"name": "{{.env}}-jobs", "message_expiration": 3600}]}`), 0644)
+			m, err := manifest.Load(path, map[string]string{"env": "prod"})
+			Expect(err, ToBeNil)
+			Expect(m.Queues[0].Name, ToEqual, "prod-jobs")
+			Expect(m.Queues[0].MessageExpiration, ToEqual, 3600)
+		})
+
+		It("refuses bad manifests", func() {
+			_, err := manifest.Parse([]byte(queuesYAML), nil)
+			Expect(err, ToNotBeNil)
+			_, err = manifest.Parse([]byte(`queues: [{name: a, timeout: 1}]`), nil)
+			Expect(err, ToNotBeNil)
+			_, err = manifest.Parse([]byte(`queues: [{name: a}, {name: a}]`), nil)
+			Expect(err.Error(), ToEqual, "manifest: queue a is listed twice")
+			_, err = manifest.Parse([]byte(`{prune: true, prefix: "x-", queues: [{name: a}]}`), nil)
+			Expect(err.Error(), ToEqual, "manifest: queue a doesn't start with the prefix x-")
+		})
+	})
+
+	Describe("applying", func() {
+		srv := irontest.NewServer()
+		defer srv.Close()
+		s := srv.Settings("iron_mq")
+		m, _ := manifest.Parse([]byte(queuesYAML), map[string]string{"env": "staging"})
+
+		mq.Queue{Settings: s, Name: "staging-orders"}.Create(mq.QueueInfo{MessageTimeout: 60})
+		mq.Queue{Settings: s, Name: "staging-old"}.Create(mq.QueueInfo{})
+		mq.Queue{Settings: s, Name: "prod-orders"}.Create(mq.QueueInfo{})
+
+		It("plans without changing anything", func() {
+			p, err := m.Plan(s)
+			Expect(err, ToBeNil)
+			Expect(p.String(), ToEqual, "~ staging-orders\n"+
+				"    message_timeout: 60 -> 120\n"+
+				"    alerts: [] -> [{fixed 1000 asc staging-alerts 0}]\n"+
+				"+ staging-emails\n"+
+				"- staging-old\n")
+			_, ok := srv.MQ.Info("staging-emails")
+			Expect(ok, ToEqual, false)
+		})
+
+		It("applies the plan", func() {
+			p, err := m.Apply(s)
+			Expect(err, ToBeNil)
+			Expect(len(p.Pending()), ToEqual, 3)
+			_, ok := srv.MQ.Info("staging-old")
+			Expect(ok, ToEqual, false)
+			_, ok = srv.MQ.Info("prod-orders")
+			Expect(ok, ToBeTrue)
+			info, _ := srv.MQ.Info("staging-emails")
+			Expect(info.Type, ToEqual, "unicast")
+
+			p, err = m.Plan(s)
+			Expect(err, ToBeNil)
+			Expect(p.String(), ToEqual, "no changes\n")
+		})
+
+		It("creates error queues and keeps them", func() {
+			m, err := manifest.Parse([]byte(`
+prefix: "staging-"
+prune: true
+create_error_queues: true
+queues:
+  - name: staging-hooks
+    type: unicast
+    push:
+      subscribers: [{name: hook, url: "https://hooks.example.com"}]
+      error_queue: staging-hooks-errors
+`), nil)
+			Expect(err, ToBeNil)
+			p, err := m.Plan(s)
+			Expect(err, ToBeNil)
+			Expect(p.String(), ToEqual, "+ staging-hooks-errors\n"+
+				"+ staging-hooks\n"+
+				"- staging-emails\n"+
+				"- staging-orders\n")
+
+			_, err = m.Apply(s)
+			Expect(err, ToBeNil)
+			info, ok := srv.MQ.Info("staging-hooks-errors")
+			Expect(ok, ToBeTrue)
+			Expect(info.Type, ToEqual, "pull")
+			p, err = m.Plan(s)
+			Expect(err, ToBeNil)
+			Expect(p.String(), ToEqual, "no changes\n")
+		})
+	})
+}
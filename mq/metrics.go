@@ -0,0 +1,46 @@
+package mq
+
+import "time"
+
+// Outcomes of a delivery, see DeliveryStats.
+const (
+	OutcomeAcked      = "acked"
+	OutcomeNacked     = "nacked"
+	OutcomeTerminated = "terminated"
+	// OutcomeRetried is the outcome of deliveries moved to a retry tier by
+	// RetryQueues.
+	OutcomeRetried = "retried"
+	// OutcomeRequeued is the outcome of deliveries replaced by a corrected
+	// copy, see Delivery.Requeue.
+	OutcomeRequeued = "requeued"
+)
+
+// Metrics is told about each delivery a Consumer handled, once it was
+// settled.
+type Metrics interface {
+	ObserveDelivery(DeliveryStats)
+}
+
+// DeliveryStats describe a handled delivery.
+type DeliveryStats struct {
+	Queue string
+	// Outcome is one of the Outcome constants, or "" if settling the
+	// delivery failed.
+	Outcome string
+	// Duration is how long the handler took, the batch's for batches.
+	Duration time.Duration
+	// Age is how long the message had waited when it was reserved, 0 if
+	// that isn't known, see Message.Age.
+	Age time.Duration
+	// Err is the handler's error.
+	Err error
+}
+
+func (c *Consumer) observe(d *Delivery, took time.Duration, err error) {
+	if c.Metrics == nil {
+		return
+	}
+	outcome, _ := d.outcome.Load().(string)
+	age, _ := d.Age()
+	c.Metrics.ObserveDelivery(DeliveryStats{Queue: c.Queue.Name, Outcome: outcome, Duration: took, Age: age, Err: err})
+}
@@ -2,8 +2,11 @@
 package mq
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"net/http"
 	"time"
 
 	"github.com/iron-io/iron_go3/api"
@@ -21,6 +24,12 @@ type Timestamped struct {
 type Queue struct {
 	Settings config.Settings `json:"-"`
 	Name     string          `json:"name"`
+	// Client makes the requests, api.DefaultClient if nil.
+	Client *api.Client `json:"-"`
+	// Limits are checked before pushing.
+	Limits Limits `json:"-"`
+	// Defaults, if set, replace the package's Defaults for this queue.
+	Defaults *QueueDefaults `json:"-"`
 }
 
 // When used for create/update, Size and TotalMessages will be omitted.
@@ -68,7 +77,10 @@ type Message struct {
 	ReservedUntil time.Time `json:"reserved_until,omitempty"`
 	ReservedCount int       `json:"reserved_count,omitempty"`
 	ReservationId string    `json:"reservation_id,omitempty"`
-	q             Queue     // todo: shouldn't this be a pointer?
+	// CreatedAt is when the message was pushed, if the server tells.
+	CreatedAt  time.Time `json:"created_at,omitzero"`
+	q          Queue     // todo: shouldn't this be a pointer?
+	reservedAt time.Time
 }
 
 type Subscriber struct {
@@ -84,7 +96,15 @@ type Subscription struct {
 	RetriesDelay int
 }
 
+// ErrQueueNotFound reports whether err was caused by the queue not existing.
 func ErrQueueNotFound(err error) bool {
+	if api.StatusCode(err) != http.StatusNotFound {
+		return false
+	}
+	var e *api.Error
+	if errors.As(err, &e) {
+		err = e.Err
+	}
 	return err.Error() == "404 Not Found: Queue not found"
 }
 
@@ -116,8 +136,18 @@ func ConfigCreateQueue(queueInfo QueueInfo, settings *config.Settings) (QueueInf
 	if queueInfo.Name == "" {
 		return QueueInfo{}, errors.New("Name of queue is empty")
 	}
+	return Queue{Settings: config.ManualConfig("iron_mq", settings), Name: queueInfo.Name}.Create(queueInfo)
+}
 
-	url := api.Action(config.ManualConfig("iron_mq", settings), "queues", queueInfo.Name)
+// Create creates the queue, all fields of queueInfo are optional and its
+// Name is ignored. Queue type cannot be changed.
+//
+// Settings left zero are taken from the queue's defaults, see
+// QueueDefaults.
+func (q Queue) Create(queueInfo QueueInfo) (QueueInfo, error) {
+	queueInfo = q.defaults().apply(queueInfo, true)
+	queueInfo.Name = q.Name
+	url := q.queues(q.Name)
 
 	in := struct {
 		Queue QueueInfo `json:"queue"`
@@ -157,11 +187,16 @@ func FilterPage(prefix, prev string, perPage int) ([]Queue, error) {
 }
 
 func ListQueues(s config.Settings, prefix, prev string, perPage int) ([]Queue, error) {
+	return Queue{Settings: s}.ListQueues(prefix, prev, perPage)
+}
+
+// ListQueues lists the queues of q's project, see the ListQueues function.
+func (q Queue) ListQueues(prefix, prev string, perPage int) ([]Queue, error) {
 	var out struct {
 		Queues []Queue `json:"queues"`
 	}
 
-	url := api.Action(s, "queues")
+	url := q.queues()
 
 	if prev != "" {
 		url.QueryAdd("previous", "%v", prev)
@@ -179,13 +214,14 @@ func ListQueues(s config.Settings, prefix, prev string, perPage int) ([]Queue, e
 	}
 
 	for idx := range out.Queues {
-		out.Queues[idx].Settings = s
+		out.Queues[idx].Settings = q.Settings
+		out.Queues[idx].Client = q.Client
 	}
 
 	return out.Queues, nil
 }
 
-func (q Queue) queues(s ...string) *api.URL { return api.Action(q.Settings, "queues", s...) }
+func (q Queue) queues(s ...string) *api.URL { return q.Client.Action(q.Settings, "queues", s...) }
 
 func (q *Queue) UnmarshalJSON(data []byte) error {
 	var name struct {
@@ -251,13 +287,17 @@ func (q Queue) PushMessage(msg Message) (id string, err error) {
 	if err != nil {
 		return "", err
 	} else if len(ids) < 1 {
-		return "", errors.New("didn't receive message ID for pushing message")
+		return "", fmt.Errorf("didn't receive message ID for pushing message to %s", q.Name)
 	}
 	return ids[0], err
 }
 
-// PushMessages enqueues each message in order.
+// PushMessages enqueues each message in order. It fails with an
+// *api.TooLargeError if they exceed the queue's Limits.
 func (q Queue) PushMessages(msgs ...Message) (ids []string, err error) {
+	if err := q.Limits.check(q, msgs); err != nil {
+		return nil, err
+	}
 	in := struct {
 		Messages []Message `json:"messages"`
 	}{
@@ -280,19 +320,7 @@ func (q Queue) Peek() ([]Message, error) {
 
 // Peek with N, max 100.
 func (q Queue) PeekN(n int) ([]Message, error) {
-	var out struct {
-		Messages []Message `json:"messages"`
-	}
-
-	err := q.queues(q.Name, "messages").
-		QueryAdd("n", "%d", n).
-		Req("GET", nil, &out)
-
-	for i, _ := range out.Messages {
-		out.Messages[i].q = q
-	}
-
-	return out.Messages, err
+	return q.peek(context.Background(), n)
 }
 
 // Reserves a message from the queue.
@@ -311,7 +339,7 @@ func (q Queue) Reserve() (msg *Message, err error) {
 
 // ReserveN reserves multiple messages from the queue.
 func (q Queue) ReserveN(n int) ([]Message, error) {
-	return q.LongPoll(n, 60, 0, false)
+	return q.reserve(context.Background(), n, 60, 0, false)
 }
 
 // Get reserves a message from the queue.
@@ -326,9 +354,11 @@ func (q Queue) GetN(n int) ([]Message, error) {
 	return q.ReserveN(n)
 }
 
-// TODO deprecate for LongPoll?
+// GetNWithTimeout reserves n messages for timeout seconds.
+//
+// Deprecated: Use ReserveWith.
 func (q Queue) GetNWithTimeout(n, timeout int) ([]Message, error) {
-	return q.LongPoll(n, timeout, 0, false)
+	return q.reserve(context.Background(), n, timeout, 0, false)
 }
 
 // Pop will get and delete a message from the queue.
@@ -342,7 +372,7 @@ func (q Queue) Pop() (msg Message, err error) {
 
 // PopN is Pop for N.
 func (q Queue) PopN(n int) ([]Message, error) {
-	return q.LongPoll(n, 0, 0, true)
+	return q.reserve(context.Background(), n, 0, 0, true)
 }
 
 // LongPoll is the long form for Get, Pop, with all options available.
@@ -350,10 +380,16 @@ func (q Queue) PopN(n int) ([]Message, error) {
 // will poll for n messages up to wait seconds (max 30).
 // If delete is specified, then each message will be deleted instead
 // of being put back onto the queue.
+//
+// Deprecated: Use ReserveWith, whose named options can't be mixed up.
 func (q Queue) LongPoll(n, timeout, wait int, delete bool) ([]Message, error) {
+	return q.reserve(context.Background(), n, timeout, wait, delete)
+}
+
+func (q Queue) reserve(ctx context.Context, n, timeout, wait int, delete bool) ([]Message, error) {
 	in := struct {
 		N       int  `json:"n"`
-		Timeout int  `json:"timeout"`
+		Timeout int  `json:"timeout,omitempty"`
 		Wait    int  `json:"wait"`
 		Delete  bool `json:"delete"`
 	}{
@@ -366,10 +402,12 @@ func (q Queue) LongPoll(n, timeout, wait int, delete bool) ([]Message, error) {
 		Messages []Message `json:"messages"` // TODO don't think we need pointer here
 	}
 
-	err := q.queues(q.Name, "reservations").Req("POST", &in, &out)
+	err := q.queues(q.Name, "reservations").WithContext(ctx).Req("POST", &in, &out)
 
+	now := time.Now()
 	for i, _ := range out.Messages {
 		out.Messages[i].q = q
+		out.Messages[i].reservedAt = now
 	}
 
 	return out.Messages, err
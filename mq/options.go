@@ -0,0 +1,88 @@
+package mq
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Limits of the reservation API.
+const (
+	MaxReserve = 100
+	MaxWait    = 30 * time.Second
+)
+
+// ReserveOptions configure ReserveWith. Zero values take the defaults.
+type ReserveOptions struct {
+	// N is the number of messages to reserve, 1 by default and at most
+	// MaxReserve.
+	N int
+	// Wait is how long the server waits for N messages to arrive, at most
+	// MaxWait. By default it returns right away.
+	Wait time.Duration
+	// Timeout is how long the messages stay reserved, the queue's
+	// message_timeout by default.
+	Timeout time.Duration
+	// Delete deletes the messages instead of reserving them.
+	Delete bool
+}
+
+// PopOptions configure PopWith. Zero values take the defaults.
+type PopOptions struct {
+	// N is the number of messages to pop, 1 by default and at most
+	// MaxReserve.
+	N int
+	// Wait is how long the server waits for N messages to arrive, at most
+	// MaxWait. By default it returns right away.
+	Wait time.Duration
+}
+
+// PushOptions configure PushWith.
+type PushOptions struct {
+	// Delay is how long the messages stay invisible after being pushed.
+	Delay time.Duration
+}
+
+// ReserveWith reserves messages from the queue. Partial seconds of the
+// durations are rounded up.
+func (q Queue) ReserveWith(opts ReserveOptions) ([]Message, error) {
+	return q.reserveWith(context.Background(), opts)
+}
+
+func (q Queue) reserveWith(ctx context.Context, opts ReserveOptions) ([]Message, error) {
+	if opts.N == 0 {
+		opts.N = 1
+	}
+	if opts.N < 0 || opts.N > MaxReserve {
+		return nil, fmt.Errorf("mq: cannot reserve %d messages, must be between 1 and %d", opts.N, MaxReserve)
+	}
+	if opts.Wait < 0 || opts.Wait > MaxWait {
+		return nil, fmt.Errorf("mq: wait of %v must be between 0 and %v", opts.Wait, MaxWait)
+	}
+	if opts.Timeout < 0 {
+		return nil, fmt.Errorf("mq: negative reservation timeout %v", opts.Timeout)
+	}
+	return q.reserve(ctx, opts.N, seconds(opts.Timeout), seconds(opts.Wait), opts.Delete)
+}
+
+// PopWith deletes messages from the queue and returns them.
+func (q Queue) PopWith(opts PopOptions) ([]Message, error) {
+	return q.ReserveWith(ReserveOptions{N: opts.N, Wait: opts.Wait, Delete: true})
+}
+
+// PushWith enqueues messages with the specified bodies.
+func (q Queue) PushWith(opts PushOptions, bodies ...string) ([]string, error) {
+	if opts.Delay < 0 {
+		return nil, fmt.Errorf("mq: negative delay %v", opts.Delay)
+	}
+	msgs := make([]Message, len(bodies))
+	for i, body := range bodies {
+		msgs[i] = Message{Body: body, Delay: int64(seconds(opts.Delay))}
+	}
+	return q.PushMessages(msgs...)
+}
+
+// seconds rounds d up to whole seconds.
+func seconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
@@ -0,0 +1,59 @@
+package mq
+
+import (
+	"testing"
+	"time"
+
+	"github.com/iron-io/iron_go3/irontest"
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestOptions(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("options", func() {
+		srv := irontest.NewServer()
+		defer srv.Close()
+		q := Queue{Settings: srv.Settings("iron_mq"), Name: "options"}
+
+		It("pushes with a delay", func() {
+			_, err := q.PushWith(PushOptions{Delay: 1500 * time.Millisecond}, "later")
+			Expect(err, ToBeNil)
+			msgs, err := q.ReserveWith(ReserveOptions{})
+			Expect(err, ToBeNil)
+			Expect(len(msgs), ToEqual, 0)
+		})
+
+		It("waits for messages", func() {
+			msgs, err := q.ReserveWith(ReserveOptions{Wait: 3 * time.Second, Timeout: time.Second})
+			Expect(err, ToBeNil)
+			Expect(len(msgs), ToEqual, 1)
+			Expect(msgs[0].Body, ToEqual, "later")
+		})
+
+		It("pops messages", func() {
+			q.PushStrings("a", "b", "c")
+			msgs, err := q.PopWith(PopOptions{N: 2})
+			Expect(err, ToBeNil)
+			Expect(len(msgs), ToEqual, 2)
+			Expect(srv.MQ.Messages("options"), ToDeepEqual, []string{"later", "c"})
+		})
+
+		It("rejects bad options", func() {
+			_, err := q.ReserveWith(ReserveOptions{N: MaxReserve + 1})
+			Expect(err, ToNotBeNil)
+			_, err = q.ReserveWith(ReserveOptions{Wait: time.Minute})
+			Expect(err, ToNotBeNil)
+			_, err = q.PopWith(PopOptions{N: -1})
+			Expect(err, ToNotBeNil)
+			_, err = q.PushWith(PushOptions{Delay: -time.Second}, "x")
+			Expect(err, ToNotBeNil)
+		})
+
+		It("rounds up to seconds", func() {
+			Expect(seconds(0), ToEqual, 0)
+			Expect(seconds(time.Millisecond), ToEqual, 1)
+			Expect(seconds(2*time.Second), ToEqual, 2)
+		})
+	})
+}
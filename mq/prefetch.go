@@ -0,0 +1,212 @@
+package mq
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// A prefetchPool holds the messages reserved ahead of the handlers of a
+// Consumer, see Prefetch. ready counts the messages in the pool, space the
+// room left in it.
+type prefetchPool struct {
+	mu      sync.Mutex
+	entries []*prefetched
+	ready   chan struct{}
+	space   chan struct{}
+}
+
+type prefetched struct {
+	mu    sync.Mutex
+	msg   Message
+	at    time.Time // when the reservation was last made or touched
+	taken bool      // handed to a handler, or released
+	lost  bool      // the reservation couldn't be touched
+}
+
+// runPrefetch is run with a prefetch pool: a goroutine keeps the pool
+// full, Concurrency goroutines hand its messages to the handler, and
+// another touches the reservations of the messages waiting in it.
+func (c *Consumer) runPrefetch(ctx context.Context) error {
+	timeout, err := c.reservedFor()
+	if err != nil {
+		return err
+	}
+	p := &prefetchPool{ready: make(chan struct{}, c.Prefetch), space: make(chan struct{}, c.Prefetch)}
+	for i := 0; i < c.Prefetch; i++ {
+		p.space <- struct{}{}
+	}
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < c.concurrency(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				msg, ok := p.take(stop)
+				if !ok {
+					return
+				}
+				c.deliver(ctx, msg)
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		c.heartbeat(stop, p, timeout)
+	}()
+
+	err = c.prefetch(ctx, p)
+	close(stop)
+	wg.Wait()
+	p.releaseAll(c)
+	return err
+}
+
+// prefetch reserves messages into p as it has room, until ctx is done or
+// a reservation is refused.
+func (c *Consumer) prefetch(ctx context.Context, p *prefetchPool) error {
+	var b reserveBackoff
+	for ctx.Err() == nil {
+		select {
+		case <-p.space:
+		case <-ctx.Done():
+			return nil
+		}
+		n := 1
+	fill:
+		for n < cap(p.space) && n < MaxReserve {
+			select {
+			case <-p.space:
+				n++
+			default:
+				break fill
+			}
+		}
+
+		msgs, err := c.reserve(ctx, n)
+		for i := len(msgs); i < n; i++ {
+			p.space <- struct{}{}
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			if permanent(err) {
+				return err
+			}
+			c.failed(ctx, &b, err)
+			continue
+		}
+		c.recovered(&b)
+		p.put(msgs)
+	}
+	return nil
+}
+
+func (p *prefetchPool) put(msgs []Message) {
+	now := time.Now()
+	p.mu.Lock()
+	for _, msg := range msgs {
+		p.entries = append(p.entries, &prefetched{msg: msg, at: now})
+	}
+	p.mu.Unlock()
+	for range msgs {
+		p.ready <- struct{}{}
+	}
+}
+
+// take waits for a message of the pool, skipping those whose reservation
+// was lost. It returns false once stop is closed.
+func (p *prefetchPool) take(stop <-chan struct{}) (Message, bool) {
+	for {
+		select {
+		case <-stop:
+			return Message{}, false
+		default:
+		}
+		select {
+		case <-stop:
+			return Message{}, false
+		case <-p.ready:
+		}
+		p.mu.Lock()
+		e := p.entries[0]
+		p.entries = p.entries[1:]
+		p.mu.Unlock()
+		p.space <- struct{}{}
+
+		e.mu.Lock()
+		e.taken = true
+		msg, lost := e.msg, e.lost
+		e.mu.Unlock()
+		if !lost {
+			return msg, true
+		}
+	}
+}
+
+// reservedFor is how long prefetched messages stay reserved: Timeout, or
+// the queue's message_timeout when it's unset.
+func (c *Consumer) reservedFor() (time.Duration, error) {
+	if c.Timeout > 0 {
+		return c.Timeout, nil
+	}
+	info, err := c.Queue.Info()
+	if err != nil {
+		return 0, fmt.Errorf("mq: reading the message timeout of %s: %w", c.Queue.Name, err)
+	}
+	if info.MessageTimeout <= 0 {
+		return DefaultMessageTimeout, nil
+	}
+	return time.Duration(info.MessageTimeout) * time.Second, nil
+}
+
+// heartbeat touches the reservations of the messages waiting in p once
+// half their timeout passed, until stop is closed.
+func (c *Consumer) heartbeat(stop <-chan struct{}, p *prefetchPool, timeout time.Duration) {
+	t := time.NewTicker(timeout / 4)
+	defer t.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+		}
+		p.mu.Lock()
+		entries := append([]*prefetched(nil), p.entries...)
+		p.mu.Unlock()
+		for _, e := range entries {
+			e.mu.Lock()
+			if !e.taken && !e.lost && time.Since(e.at) > timeout/2 {
+				if err := e.msg.TouchFor(seconds(timeout)); err != nil {
+					e.lost = true
+					c.report(fmt.Errorf("mq: touching prefetched %s on %s: %w", e.msg.Id, c.Queue.Name, err))
+				} else {
+					e.at = time.Now()
+				}
+			}
+			e.mu.Unlock()
+		}
+	}
+}
+
+// releaseAll releases the messages left in p.
+func (p *prefetchPool) releaseAll(c *Consumer) {
+	p.mu.Lock()
+	entries := p.entries
+	p.entries = nil
+	p.mu.Unlock()
+	for _, e := range entries {
+		e.mu.Lock()
+		if !e.taken && !e.lost {
+			if err := e.msg.Release(0); err != nil {
+				c.report(fmt.Errorf("mq: releasing prefetched %s on %s: %w", e.msg.Id, c.Queue.Name, err))
+			}
+		}
+		e.taken = true
+		e.mu.Unlock()
+	}
+}
@@ -0,0 +1,124 @@
+package mq
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iron-io/iron_go3/irontest"
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestPrefetch(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("prefetching consumers", func() {
+		srv := irontest.NewServer()
+		defer srv.Close()
+		q := Queue{Settings: srv.Settings("iron_mq"), Name: "prefetch"}
+
+		It("keeps prefetched messages reserved while the handlers are busy", func() {
+			for i := 0; i < 3; i++ {
+				q.PushString(fmt.Sprint(i))
+			}
+			var mu sync.Mutex
+			var handled []string
+			var errs []error
+			c := NewConsumer(q, HandlerFunc(func(ctx context.Context, d *Delivery) error {
+				if d.Body == "0" {
+					// past the reservations of the prefetched messages
+					time.Sleep(750 * time.Millisecond)
+					d.Touch()
+					time.Sleep(750 * time.Millisecond)
+				}
+				mu.Lock()
+				handled = append(handled, d.Body)
+				mu.Unlock()
+				return nil
+			}))
+			c.Prefetch, c.Timeout, c.Wait = 2, time.Second, time.Second
+			c.OnError = func(err error) { mu.Lock(); errs = append(errs, err); mu.Unlock() }
+
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan error)
+			go func() { done <- c.Run(ctx) }()
+			for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+				mu.Lock()
+				n := len(handled)
+				mu.Unlock()
+				if n == 3 {
+					break
+				}
+			}
+			cancel()
+			Expect(<-done, ToBeNil)
+			Expect(handled, ToDeepEqual, []string{"0", "1", "2"})
+			Expect(len(errs), ToEqual, 0)
+			Expect(len(srv.MQ.Messages("prefetch")), ToEqual, 0)
+		})
+
+		It("keeps to the queue's message timeout without a Timeout", func() {
+			short := Queue{Settings: q.Settings, Name: "prefetch-short"}
+			_, err := short.Create(QueueInfo{MessageTimeout: 1})
+			Expect(err, ToBeNil)
+			for i := 0; i < 2; i++ {
+				short.PushString(fmt.Sprint(i))
+			}
+			var mu sync.Mutex
+			var handled []string
+			var errs []error
+			c := NewConsumer(short, HandlerFunc(func(ctx context.Context, d *Delivery) error {
+				if d.Body == "0" {
+					// past the reservation of the prefetched message
+					time.Sleep(750 * time.Millisecond)
+					d.Touch()
+					time.Sleep(750 * time.Millisecond)
+				}
+				mu.Lock()
+				handled = append(handled, d.Body)
+				mu.Unlock()
+				return nil
+			}))
+			c.Prefetch, c.Wait = 1, time.Second
+			c.OnError = func(err error) { mu.Lock(); errs = append(errs, err); mu.Unlock() }
+
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan error)
+			go func() { done <- c.Run(ctx) }()
+			for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+				mu.Lock()
+				n := len(handled)
+				mu.Unlock()
+				if n == 2 {
+					break
+				}
+			}
+			cancel()
+			Expect(<-done, ToBeNil)
+			Expect(handled, ToDeepEqual, []string{"0", "1"})
+			Expect(len(errs), ToEqual, 0)
+			Expect(len(srv.MQ.Messages("prefetch-short")), ToEqual, 0)
+		})
+
+		It("releases the prefetched messages when it stops", func() {
+			for i := 0; i < 4; i++ {
+				q.PushString(fmt.Sprint(i))
+			}
+			c := NewConsumer(q, HandlerFunc(func(ctx context.Context, d *Delivery) error {
+				<-ctx.Done()
+				time.Sleep(50 * time.Millisecond)
+				return nil
+			}))
+			c.Prefetch = 3
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			Expect(c.Run(ctx), ToBeNil)
+			Expect(len(srv.MQ.Messages("prefetch")), ToEqual, 3)
+			msgs, err := q.ReserveN(3)
+			Expect(err, ToBeNil)
+			Expect(len(msgs), ToEqual, 3)
+		})
+	})
+}
@@ -0,0 +1,67 @@
+// Package protocodec provides an mq.Codec for protocol buffers.
+//
+//	p := mq.NewProducer(q, "orders-api")
+//	id, err := mq.PushAs(p, protocodec.Protobuf, &pb.Order{Id: 42}, nil)
+//
+//	order, err := mq.DecodeAs[*pb.Order](d.Envelope, protocodec.Protobuf)
+//
+// Payloads are in the binary wire format, base64 encoded in the envelope
+// like any payload.
+package protocodec
+
+import (
+	"fmt"
+	"reflect"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// ContentType is the media type of protobuf payloads.
+const ContentType = "application/x-protobuf"
+
+// Protobuf encodes proto.Messages in the binary wire format.
+var Protobuf Codec
+
+// Codec is the type of Protobuf.
+type Codec struct{}
+
+func (Codec) ContentType() string { return ContentType }
+
+// Marshal encodes v, which must be a proto.Message.
+func (Codec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("protocodec: %T is not a proto.Message", v)
+	}
+	return proto.Marshal(m)
+}
+
+// Unmarshal decodes data into v, which must be a proto.Message or a
+// pointer to one, which is allocated if it is nil.
+func (Codec) Unmarshal(data []byte, v interface{}) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		m, ok = alloc(v)
+	}
+	if !ok {
+		return fmt.Errorf("protocodec: cannot decode into %T", v)
+	}
+	return proto.Unmarshal(data, m)
+}
+
+// alloc returns the message v points to, allocating it if needed, for v
+// of type **M like DecodeAs passes.
+func alloc(v interface{}) (proto.Message, bool) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Ptr {
+		return nil, false
+	}
+	if _, ok := rv.Elem().Interface().(proto.Message); !ok {
+		return nil, false
+	}
+	if rv.Elem().IsNil() {
+		rv.Elem().Set(reflect.New(rv.Type().Elem().Elem()))
+	}
+	m, ok := rv.Elem().Interface().(proto.Message)
+	return m, ok
+}
@@ -0,0 +1,45 @@
+package protocodec_test
+
+import (
+	"testing"
+
+	"github.com/iron-io/iron_go3/irontest"
+	"github.com/iron-io/iron_go3/mq"
+	"github.com/iron-io/iron_go3/mq/protocodec"
+	. "github.com/jeffh/go.bdd"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestProtobuf(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("protobuf payloads", func() {
+		srv := irontest.NewServer()
+		defer srv.Close()
+		q := mq.Queue{Settings: srv.Settings("iron_mq"), Name: "events"}
+		p := mq.NewProducer(q, "p")
+
+		It("round-trips messages", func() {
+			_, err := mq.PushAs(p, protocodec.Protobuf, wrapperspb.String("hello"), nil)
+			Expect(err, ToBeNil)
+
+			msgs, _ := q.PopWith(mq.PopOptions{})
+			e, _ := msgs[0].Open()
+			Expect(e.ContentType, ToEqual, protocodec.ContentType)
+			v, err := mq.DecodeAs[*wrapperspb.StringValue](e, protocodec.Protobuf)
+			Expect(err, ToBeNil)
+			Expect(v.GetValue(), ToEqual, "hello")
+
+			var s wrapperspb.StringValue
+			Expect(e.Decode(protocodec.Protobuf, &s), ToBeNil)
+			Expect(s.GetValue(), ToEqual, "hello")
+		})
+
+		It("refuses other types", func() {
+			_, err := mq.PushAs(p, protocodec.Protobuf, "hello", nil)
+			Expect(err, ToNotBeNil)
+			_, err = mq.DecodeAs[string](mq.Envelope{ContentType: protocodec.ContentType}, protocodec.Protobuf)
+			Expect(err, ToNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,332 @@
+package mq
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/iron-io/iron_go3/config"
+)
+
+// A FieldDiff is a queue setting whose actual value differs from the
+// desired one.
+type FieldDiff struct {
+	// Field is the setting's JSON name, e.g. "message_timeout" or
+	// "push.subscribers".
+	Field   string
+	Actual  interface{}
+	Desired interface{}
+}
+
+func (d FieldDiff) String() string {
+	return fmt.Sprintf("%s: %v -> %v", d.Field, d.Actual, d.Desired)
+}
+
+// Actions of a Change. Ensure doesn't delete queues, the manifest package
+// does when pruning.
+const (
+	ActionNone   = "none"
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+)
+
+// A Change is what Ensure did, or Plan would do, to a queue.
+type Change struct {
+	Queue string
+	// Action is one of the Action constants.
+	Action string
+	// Diffs are the drifted settings of an updated queue.
+	Diffs []FieldDiff
+}
+
+// Ensure makes the queue match desired: it creates the queue if it doesn't
+// exist, and otherwise updates the settings that drifted from desired.
+// Settings left zero in desired are not managed, whatever their value. The
+// Name of desired is ignored.
+//
+// Subscribers are compared by name, alerts as a whole; if they drifted,
+// all of them are replaced. Queue types cannot be changed once a queue has
+// messages, the server refuses such updates.
+func (q Queue) Ensure(desired QueueInfo) (Change, error) {
+	desired = q.defaults().apply(desired, false)
+	c, err := q.Plan(desired)
+	if err != nil {
+		return c, err
+	}
+	desired.Name, desired.Size, desired.TotalMessages = "", 0, 0
+	switch c.Action {
+	case ActionCreate:
+		_, err = q.Create(desired)
+	case ActionUpdate:
+		_, err = q.Update(desired)
+	}
+	if err != nil {
+		return c, fmt.Errorf("mq: %s %s: %w", c.Action, q.Name, err)
+	}
+	return c, nil
+}
+
+// Plan returns what Ensure would do, without changing anything.
+func (q Queue) Plan(desired QueueInfo) (Change, error) {
+	c := Change{Queue: q.Name, Action: ActionNone}
+	diffs, err := q.DiffAgainst(q.defaults().apply(desired, false))
+	if ErrQueueNotFound(err) {
+		c.Action = ActionCreate
+		return c, nil
+	}
+	if err != nil {
+		return c, err
+	}
+	if c.Diffs = diffs; len(diffs) > 0 {
+		c.Action = ActionUpdate
+	}
+	return c, nil
+}
+
+// QueueDefaults are the settings Create and Ensure give queues that leave
+// them zero, so the queues of a project share the same retention without
+// every caller setting it. Ensure also updates existing queues whose
+// settings differ from the defaults.
+type QueueDefaults struct {
+	MessageTimeout    int `json:"message_timeout,omitempty"`
+	MessageExpiration int `json:"message_expiration,omitempty"`
+	// Type is only given to created queues without push settings, the
+	// type of a queue can't change.
+	Type string `json:"type,omitempty"`
+}
+
+// Defaults are the QueueDefaults of the queues without their own. Set it
+// before using queues, it isn't safe to change concurrently.
+var Defaults QueueDefaults
+
+func (q Queue) defaults() QueueDefaults {
+	if q.Defaults != nil {
+		return *q.Defaults
+	}
+	return Defaults
+}
+
+// apply returns info with its zero settings set from d, its type too if
+// the queue is being created.
+func (d QueueDefaults) apply(info QueueInfo, create bool) QueueInfo {
+	if info.MessageTimeout == 0 {
+		info.MessageTimeout = d.MessageTimeout
+	}
+	if info.MessageExpiration == 0 {
+		info.MessageExpiration = d.MessageExpiration
+	}
+	if create && info.Type == "" && info.Push == nil {
+		info.Type = d.Type
+	}
+	return info
+}
+
+// DefaultErrorQueue holds the settings of the error queues EnsureWith
+// creates by default: a pull queue keeping failed messages for 30 days,
+// the longest the API allows.
+var DefaultErrorQueue = QueueInfo{Type: "pull", MessageExpiration: 30 * 24 * 60 * 60}
+
+// EnsureOptions configure EnsureWith and ApplyWith.
+type EnsureOptions struct {
+	// VerifyErrorQueues fails ensuring a push queue whose ErrorQueue
+	// doesn't exist, before the push queue is changed, rather than have
+	// failed messages lost until someone notices.
+	VerifyErrorQueues bool
+	// CreateErrorQueues creates the missing error queues instead, with the
+	// settings of ErrorQueue.
+	CreateErrorQueues bool
+	// ErrorQueue holds the settings of created error queues,
+	// DefaultErrorQueue if zero. Its Name is ignored.
+	ErrorQueue QueueInfo
+}
+
+func (o EnsureOptions) errorQueue() QueueInfo {
+	if reflect.DeepEqual(o.ErrorQueue, QueueInfo{}) {
+		return DefaultErrorQueue
+	}
+	return o.ErrorQueue
+}
+
+// EnsureWith is like Ensure, but also verifies or creates the error queue
+// of a push queue, per opts. It returns the changes made, the creation of
+// the error queue first.
+func (q Queue) EnsureWith(opts EnsureOptions, desired QueueInfo) ([]Change, error) {
+	var changes []Change
+	ec, err := q.planErrorQueue(opts, desired)
+	if err != nil {
+		return nil, err
+	}
+	if ec != nil {
+		eq := q
+		eq.Name = ec.Queue
+		if _, err := eq.Create(opts.errorQueue()); err != nil {
+			return nil, fmt.Errorf("mq: %s %s: %w", ec.Action, eq.Name, err)
+		}
+		changes = append(changes, *ec)
+	}
+	c, err := q.Ensure(desired)
+	if err != nil {
+		return changes, err
+	}
+	return append(changes, c), nil
+}
+
+// PlanWith returns what EnsureWith would do, without changing anything.
+func (q Queue) PlanWith(opts EnsureOptions, desired QueueInfo) ([]Change, error) {
+	var changes []Change
+	ec, err := q.planErrorQueue(opts, desired)
+	if err != nil {
+		return nil, err
+	}
+	if ec != nil {
+		changes = append(changes, *ec)
+	}
+	c, err := q.Plan(desired)
+	if err != nil {
+		return nil, err
+	}
+	return append(changes, c), nil
+}
+
+// planErrorQueue returns the creation of the error queue of desired if it
+// is missing and opts create it, nil if nothing needs to be done. It fails
+// if the error queue is missing and opts only verify it.
+func (q Queue) planErrorQueue(opts EnsureOptions, desired QueueInfo) (*Change, error) {
+	if !opts.VerifyErrorQueues && !opts.CreateErrorQueues || desired.Push == nil || desired.Push.ErrorQueue == "" {
+		return nil, nil
+	}
+	eq := q
+	eq.Name = desired.Push.ErrorQueue
+	_, err := eq.Info()
+	switch {
+	case err == nil:
+		return nil, nil
+	case !ErrQueueNotFound(err):
+		return nil, fmt.Errorf("mq: checking error queue %s of %s: %w", eq.Name, q.Name, err)
+	case !opts.CreateErrorQueues:
+		return nil, fmt.Errorf("mq: error queue %s of %s doesn't exist", eq.Name, q.Name)
+	}
+	return &Change{Queue: eq.Name, Action: ActionCreate}, nil
+}
+
+// Apply ensures each queue of desired, by Name, in the project of
+// settings. It goes on after a queue failed, returning the changes made
+// and all errors.
+func Apply(settings config.Settings, desired ...QueueInfo) ([]Change, error) {
+	return ApplyWith(settings, EnsureOptions{}, desired...)
+}
+
+// ApplyWith is like Apply, ensuring the queues with EnsureWith. The error
+// queues listed in desired are ensured first, with their own settings,
+// before the queues that use them.
+func ApplyWith(settings config.Settings, opts EnsureOptions, desired ...QueueInfo) ([]Change, error) {
+	var changes []Change
+	var errs []error
+	for _, d := range OrderErrorQueuesFirst(desired) {
+		if d.Name == "" {
+			errs = append(errs, errors.New("mq: cannot apply a queue without a name"))
+			continue
+		}
+		c, err := Queue{Settings: settings, Name: d.Name}.EnsureWith(opts, d)
+		changes = append(changes, c...)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return changes, errors.Join(errs...)
+}
+
+// OrderErrorQueuesFirst returns queues with those that are the error queue
+// of another moved to the front, keeping their order otherwise.
+func OrderErrorQueuesFirst(queues []QueueInfo) []QueueInfo {
+	errorQueues := map[string]bool{}
+	for _, q := range queues {
+		if q.Push != nil && q.Push.ErrorQueue != "" {
+			errorQueues[q.Push.ErrorQueue] = true
+		}
+	}
+	ordered := make([]QueueInfo, len(queues))
+	copy(ordered, queues)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return errorQueues[ordered[i].Name] && !errorQueues[ordered[j].Name]
+	})
+	return ordered
+}
+
+// DiffAgainst returns the settings of the queue that drifted from those
+// desired sets, see Ensure.
+func (q Queue) DiffAgainst(desired QueueInfo) ([]FieldDiff, error) {
+	actual, err := q.Info()
+	if err != nil {
+		return nil, err
+	}
+	return diffQueues(actual, desired, false), nil
+}
+
+// DiffQueues returns the settings that differ between a and b, as Actual
+// and Desired values, e.g. to compare a queue across projects. Name and
+// the message counts are ignored.
+func DiffQueues(a, b QueueInfo) []FieldDiff {
+	return diffQueues(a, b, true)
+}
+
+// diffQueues compares the settings of actual and desired, only those
+// desired sets unless all is true.
+func diffQueues(actual, desired QueueInfo, all bool) []FieldDiff {
+	var diffs []FieldDiff
+	diff := func(field string, set bool, a, d interface{}) {
+		if (all || set) && !reflect.DeepEqual(a, d) {
+			diffs = append(diffs, FieldDiff{Field: field, Actual: a, Desired: d})
+		}
+	}
+
+	diff("type", desired.Type != "", queueType(actual.Type), queueType(desired.Type))
+	diff("message_timeout", desired.MessageTimeout != 0, actual.MessageTimeout, desired.MessageTimeout)
+	diff("message_expiration", desired.MessageExpiration != 0, actual.MessageExpiration, desired.MessageExpiration)
+	if desired.Push != nil || all {
+		var a, d PushInfo
+		if actual.Push != nil {
+			a = *actual.Push
+		}
+		if desired.Push != nil {
+			d = *desired.Push
+		}
+		diff("push.retries", d.Retries != 0, a.Retries, d.Retries)
+		diff("push.retries_delay", d.RetriesDelay != 0, a.RetriesDelay, d.RetriesDelay)
+		diff("push.error_queue", d.ErrorQueue != "", a.ErrorQueue, d.ErrorQueue)
+		diff("push.subscribers", d.Subscribers != nil, sortedSubscribers(a.Subscribers), sortedSubscribers(d.Subscribers))
+	}
+	diff("alerts", desired.Alerts != nil, sortedAlerts(actual.Alerts), sortedAlerts(desired.Alerts))
+	return diffs
+}
+
+// queueType returns t, "pull" for the default.
+func queueType(t string) string {
+	if t == "" {
+		return "pull"
+	}
+	return t
+}
+
+func sortedSubscribers(subs []QueueSubscriber) []QueueSubscriber {
+	s := make([]QueueSubscriber, len(subs))
+	copy(s, subs)
+	for i := range s {
+		if len(s[i].Headers) == 0 {
+			s[i].Headers = nil
+		}
+	}
+	sort.Slice(s, func(i, j int) bool { return s[i].Name < s[j].Name })
+	return s
+}
+
+func sortedAlerts(alerts []Alert) []Alert {
+	s := make([]Alert, len(alerts))
+	copy(s, alerts)
+	sort.Slice(s, func(i, j int) bool {
+		return fmt.Sprint(s[i]) < fmt.Sprint(s[j])
+	})
+	return s
+}
@@ -0,0 +1,178 @@
+package mq
+
+import (
+	"testing"
+
+	"github.com/iron-io/iron_go3/irontest"
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestProvision(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("provisioning", func() {
+		srv := irontest.NewServer()
+		defer srv.Close()
+		s := srv.Settings("iron_mq")
+		q := Queue{Settings: s, Name: "orders"}
+
+		desired := QueueInfo{
+			MessageTimeout: 120,
+			Alerts:         []Alert{{Type: "fixed", Direction: "asc", Trigger: 100, Queue: "orders-alerts"}},
+		}
+
+		It("creates missing queues", func() {
+			c, err := q.Ensure(desired)
+			Expect(err, ToBeNil)
+			Expect(c.Action, ToEqual, ActionCreate)
+			info, _ := srv.MQ.Info("orders")
+			Expect(info.MessageTimeout, ToEqual, 120)
+		})
+
+		It("leaves matching queues alone", func() {
+			before := srv.Requests()
+			c, err := q.Ensure(desired)
+			Expect(err, ToBeNil)
+			Expect(c, ToDeepEqual, Change{Queue: "orders", Action: ActionNone})
+			Expect(srv.Requests()-before, ToEqual, 1)
+		})
+
+		It("reports and fixes drift", func() {
+			q.Update(QueueInfo{MessageTimeout: 30, MessageExpiration: 3600})
+			c, err := q.Plan(desired)
+			Expect(err, ToBeNil)
+			Expect(c.Action, ToEqual, ActionUpdate)
+			Expect(c.Diffs, ToDeepEqual, []FieldDiff{{Field: "message_timeout", Actual: 30, Desired: 120}})
+			Expect(c.Diffs[0].String(), ToEqual, "message_timeout: 30 -> 120")
+
+			c, err = q.Ensure(desired)
+			Expect(err, ToBeNil)
+			Expect(c.Action, ToEqual, ActionUpdate)
+			info, _ := q.Info()
+			Expect(info.MessageTimeout, ToEqual, 120)
+			Expect(info.MessageExpiration, ToEqual, 3600)
+			Expect(len(info.Alerts), ToEqual, 1)
+		})
+
+		It("compares subscribers by name", func() {
+			push := QueueInfo{Type: "multicast", Push: &PushInfo{Subscribers: []QueueSubscriber{
+				{Name: "b", URL: "http://b"}, {Name: "a", URL: "http://a"},
+			}}}
+			Queue{Settings: s, Name: "fanout"}.Ensure(push)
+			push.Push.Subscribers = []QueueSubscriber{{Name: "a", URL: "http://a"}, {Name: "b", URL: "http://b"}}
+			c, _ := Queue{Settings: s, Name: "fanout"}.Plan(push)
+			Expect(c.Action, ToEqual, ActionNone)
+			push.Push.Subscribers[1].URL = "http://c"
+			c, _ = Queue{Settings: s, Name: "fanout"}.Plan(push)
+			Expect(len(c.Diffs), ToEqual, 1)
+			Expect(c.Diffs[0].Field, ToEqual, "push.subscribers")
+		})
+
+		It("applies many queues", func() {
+			changes, err := Apply(s, QueueInfo{Name: "a"}, QueueInfo{}, QueueInfo{Name: "orders", MessageTimeout: 120})
+			Expect(err.Error(), ToEqual, "mq: cannot apply a queue without a name")
+			Expect(changes, ToDeepEqual, []Change{{Queue: "a", Action: ActionCreate}, {Queue: "orders", Action: ActionNone}})
+		})
+
+		It("verifies or creates error queues", func() {
+			push := QueueInfo{Type: "unicast", Push: &PushInfo{
+				Subscribers: []QueueSubscriber{{Name: "a", URL: "http://a"}},
+				ErrorQueue:  "hooks-errors",
+			}}
+			hooks := Queue{Settings: s, Name: "hooks"}
+			_, err := hooks.EnsureWith(EnsureOptions{VerifyErrorQueues: true}, push)
+			Expect(err.Error(), ToEqual, "mq: error queue hooks-errors of hooks doesn't exist")
+			_, ok := srv.MQ.Info("hooks")
+			Expect(ok, ToEqual, false)
+
+			changes, err := hooks.PlanWith(EnsureOptions{CreateErrorQueues: true}, push)
+			Expect(err, ToBeNil)
+			Expect(changes, ToDeepEqual, []Change{{Queue: "hooks-errors", Action: ActionCreate}, {Queue: "hooks", Action: ActionCreate}})
+
+			changes, err = hooks.EnsureWith(EnsureOptions{CreateErrorQueues: true}, push)
+			Expect(err, ToBeNil)
+			Expect(changes, ToDeepEqual, []Change{{Queue: "hooks-errors", Action: ActionCreate}, {Queue: "hooks", Action: ActionCreate}})
+			info, _ := srv.MQ.Info("hooks-errors")
+			Expect(info.Type, ToEqual, "pull")
+			Expect(info.MessageExpiration, ToEqual, DefaultErrorQueue.MessageExpiration)
+
+			changes, err = hooks.EnsureWith(EnsureOptions{VerifyErrorQueues: true}, push)
+			Expect(err, ToBeNil)
+			Expect(changes, ToDeepEqual, []Change{{Queue: "hooks", Action: ActionNone}})
+		})
+
+		It("gives queues the defaults they leave zero", func() {
+			defaults := &QueueDefaults{MessageTimeout: 90, MessageExpiration: 7200, Type: "pull"}
+			d := Queue{Settings: s, Name: "defaulted", Defaults: defaults}
+			_, err := d.Create(QueueInfo{MessageTimeout: 30})
+			Expect(err, ToBeNil)
+			info, _ := srv.MQ.Info("defaulted")
+			Expect(info.MessageTimeout, ToEqual, 30)
+			Expect(info.MessageExpiration, ToEqual, 7200)
+			Expect(info.Type, ToEqual, "pull")
+
+			c, err := d.Ensure(QueueInfo{})
+			Expect(err, ToBeNil)
+			Expect(c.Diffs, ToDeepEqual, []FieldDiff{{Field: "message_timeout", Actual: 30, Desired: 90}})
+			info, _ = srv.MQ.Info("defaulted")
+			Expect(info.MessageTimeout, ToEqual, 90)
+
+			Defaults = QueueDefaults{MessageExpiration: 600}
+			defer func() { Defaults = QueueDefaults{} }()
+			_, err = Queue{Settings: s, Name: "package-defaults"}.Ensure(QueueInfo{})
+			Expect(err, ToBeNil)
+			info, _ = srv.MQ.Info("package-defaults")
+			Expect(info.MessageExpiration, ToEqual, 600)
+		})
+
+		It("applies error queues before the queues using them", func() {
+			push := QueueInfo{Name: "mail", Type: "unicast", Push: &PushInfo{
+				Subscribers: []QueueSubscriber{{Name: "a", URL: "http://a"}},
+				ErrorQueue:  "mail-errors",
+			}}
+			changes, err := ApplyWith(s, EnsureOptions{VerifyErrorQueues: true}, push, QueueInfo{Name: "mail-errors", MessageExpiration: 3600})
+			Expect(err, ToBeNil)
+			Expect(changes, ToDeepEqual, []Change{{Queue: "mail-errors", Action: ActionCreate}, {Queue: "mail", Action: ActionCreate}})
+		})
+	})
+}
+
+func TestDiffQueues(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("diffing queues", func() {
+		staging := QueueInfo{Name: "staging-orders", Size: 10, MessageTimeout: 60, Type: "pull"}
+		prod := QueueInfo{Name: "prod-orders", Size: 9000, MessageTimeout: 120, MessageExpiration: 3600}
+
+		It("compares all settings", func() {
+			Expect(DiffQueues(staging, prod), ToDeepEqual, []FieldDiff{
+				{Field: "message_timeout", Actual: 60, Desired: 120},
+				{Field: "message_expiration", Actual: 0, Desired: 3600},
+			})
+		})
+
+		It("compares push settings", func() {
+			push := prod
+			push.Push = &PushInfo{Retries: 3, Subscribers: []QueueSubscriber{{Name: "a", URL: "http://a"}}}
+			diffs := DiffQueues(prod, push)
+			Expect(len(diffs), ToEqual, 2)
+			Expect(diffs[0].Field, ToEqual, "push.retries")
+			Expect(diffs[1].Field, ToEqual, "push.subscribers")
+			Expect(len(DiffQueues(push, push)), ToEqual, 0)
+		})
+
+		It("diffs a queue against desired settings", func() {
+			srv := irontest.NewServer()
+			defer srv.Close()
+			q := Queue{Settings: srv.Settings("iron_mq"), Name: "orders"}
+			q.Create(QueueInfo{MessageTimeout: 60, MessageExpiration: 3600})
+
+			diffs, err := q.DiffAgainst(QueueInfo{MessageTimeout: 90})
+			Expect(err, ToBeNil)
+			Expect(diffs, ToDeepEqual, []FieldDiff{{Field: "message_timeout", Actual: 60, Desired: 90}})
+
+			_, err = Queue{Settings: q.Settings, Name: "missing"}.DiffAgainst(QueueInfo{})
+			Expect(ErrQueueNotFound(err), ToBeTrue)
+		})
+	})
+}
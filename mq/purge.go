@@ -0,0 +1,112 @@
+package mq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DeleteWhereOptions configure DeleteWhere. Zero values take the defaults.
+type DeleteWhereOptions struct {
+	// MaxScanned is the most messages reserved, 1000 by default, bounding
+	// the work and the messages held back from consumers.
+	MaxScanned int
+	// DryRun deletes nothing, the matching messages are released with the
+	// others and returned for review.
+	DryRun bool
+	// Timeout is how long scanned messages stay reserved, 5 minutes by
+	// default. They are released when the scan ends, so it must outlast
+	// the scan, or messages reappear and are scanned twice.
+	Timeout time.Duration
+}
+
+// DeleteWhereResult tells what DeleteWhere did.
+type DeleteWhereResult struct {
+	Scanned int
+	// Matched are the messages match picked, deleted unless it was a dry
+	// run.
+	Matched []Message
+	Deleted int
+}
+
+// DeleteWhere reserves the queue's messages in batches and deletes those
+// match picks, e.g. to purge the poison payloads a bad deploy pushed:
+//
+//	res, err := q.DeleteWhere(ctx, mq.BodyMatches(regexp.MustCompile(`"version":\s*"1.4.0"`)), mq.DeleteWhereOptions{DryRun: true})
+//
+// The other messages stay reserved until the scan ends, so they aren't
+// scanned again, and are then released. The scan ends when the queue has
+// no more visible messages, after MaxScanned messages, or when ctx is done.
+// Scanned messages count as reserved once more, and consumers of the
+// queue don't get them during the scan.
+func (q Queue) DeleteWhere(ctx context.Context, match func(Message) bool, opts DeleteWhereOptions) (res DeleteWhereResult, err error) {
+	var kept []Message
+	defer func() {
+		if rerr := releaseAll(kept); rerr != nil {
+			err = errors.Join(err, rerr)
+		}
+	}()
+
+	for res.Scanned < opts.maxScanned() && ctx.Err() == nil {
+		n := min(opts.maxScanned()-res.Scanned, MaxReserve)
+		msgs, err := q.reserveWith(ctx, ReserveOptions{N: n, Timeout: opts.timeout()})
+		if ErrQueueNotFound(err) {
+			break
+		}
+		if err != nil {
+			return res, fmt.Errorf("mq: scanning %s: %w", q.Name, err)
+		}
+		if len(msgs) == 0 {
+			break
+		}
+		res.Scanned += len(msgs)
+
+		var matched []Message
+		for _, m := range msgs {
+			if match(m) {
+				matched = append(matched, m)
+			} else {
+				kept = append(kept, m)
+			}
+		}
+		res.Matched = append(res.Matched, matched...)
+		if opts.DryRun {
+			kept = append(kept, matched...)
+			continue
+		}
+		if len(matched) > 0 {
+			if err := q.DeleteReservedMessages(matched); err != nil {
+				kept = append(kept, matched...)
+				return res, fmt.Errorf("mq: deleting %d messages of %s: %w", len(matched), q.Name, err)
+			}
+			res.Deleted += len(matched)
+		}
+	}
+	return res, nil
+}
+
+// releaseAll releases msgs right away, going on after failures.
+func releaseAll(msgs []Message) error {
+	var errs []error
+	for _, m := range msgs {
+		if err := m.Release(0); err != nil {
+			errs = append(errs, fmt.Errorf("mq: releasing %s: %w", m.Id, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (o DeleteWhereOptions) maxScanned() int {
+	if o.MaxScanned > 0 {
+		return o.MaxScanned
+	}
+	return 1000
+}
+
+func (o DeleteWhereOptions) timeout() time.Duration {
+	if o.Timeout > 0 {
+		return o.Timeout
+	}
+	return 5 * time.Minute
+}
@@ -0,0 +1,65 @@
+package mq
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/iron-io/iron_go3/irontest"
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestDeleteWhere(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("deleting messages by predicate", func() {
+		srv := irontest.NewServer()
+		defer srv.Close()
+		q := Queue{Settings: srv.Settings("iron_mq"), Name: "purge"}
+		for i := 0; i < 250; i++ {
+			if i%10 == 0 {
+				q.PushString(fmt.Sprintf(`{"poison": %d}`, i))
+			} else {
+				q.PushString(fmt.Sprintf(`{"ok": %d}`, i))
+			}
+		}
+		poison := BodyMatches(regexp.MustCompile(`"poison"`))
+		ctx := context.Background()
+
+		It("only reports matches on dry runs", func() {
+			res, err := q.DeleteWhere(ctx, poison, DeleteWhereOptions{DryRun: true})
+			Expect(err, ToBeNil)
+			Expect(res.Scanned, ToEqual, 250)
+			Expect(len(res.Matched), ToEqual, 25)
+			Expect(res.Matched[0].Body, ToEqual, `{"poison": 0}`)
+			Expect(res.Deleted, ToEqual, 0)
+			Expect(len(srv.MQ.Messages("purge")), ToEqual, 250)
+			msgs, _ := q.ReserveWith(ReserveOptions{N: MaxReserve})
+			Expect(len(msgs), ToEqual, MaxReserve)
+			for _, m := range msgs {
+				m.Release(0)
+			}
+		})
+
+		It("stops after MaxScanned messages", func() {
+			res, err := q.DeleteWhere(ctx, poison, DeleteWhereOptions{MaxScanned: 50})
+			Expect(err, ToBeNil)
+			Expect(res.Scanned, ToEqual, 50)
+			Expect(res.Deleted, ToEqual, 5)
+			Expect(len(srv.MQ.Messages("purge")), ToEqual, 245)
+		})
+
+		It("deletes matches and releases the rest", func() {
+			res, err := q.DeleteWhere(ctx, poison, DeleteWhereOptions{})
+			Expect(err, ToBeNil)
+			Expect(res.Scanned, ToEqual, 245)
+			Expect(res.Deleted, ToEqual, 20)
+			Expect(len(srv.MQ.Messages("purge")), ToEqual, 225)
+			found, _ := q.Search(ctx, poison, 0)
+			Expect(len(found), ToEqual, 0)
+			msgs, _ := q.ReserveWith(ReserveOptions{N: MaxReserve})
+			Expect(len(msgs), ToEqual, MaxReserve)
+		})
+	})
+}
@@ -0,0 +1,72 @@
+package mq
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/iron-io/iron_go3/api"
+)
+
+// PushFromReader pushes the records of r, split by split, as message
+// bodies, e.g. a file of newline-delimited JSON. A nil split reads lines,
+// see bufio.ScanLines. Empty records are skipped.
+//
+// Records are pushed in batches of the queue's batch size, and r isn't
+// read further until the batch is pushed, so arbitrarily large inputs take
+// one batch of memory and go no faster than the server takes them.
+// PushFromReader stops at the first error and returns how many records
+// were pushed before it; they are the first ones of r, so a load can be
+// resumed by skipping them. Records longer than the queue's message size
+// stop it with a TooLargeError.
+func (q Queue) PushFromReader(r io.Reader, split bufio.SplitFunc) (int, error) {
+	limit := q.Limits.messageSize()
+	s := bufio.NewScanner(r)
+	// room for the longest message and its delimiter
+	s.Buffer(nil, limit+1)
+	if split != nil {
+		s.Split(split)
+	}
+	pushed := 0
+	batch := make([]Message, 0, q.Limits.batchSize())
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if _, err := q.PushMessages(batch...); err != nil {
+			return fmt.Errorf("mq: pushing records %d to %d to %s: %w", pushed+1, pushed+len(batch), q.Name, err)
+		}
+		pushed += len(batch)
+		batch = batch[:0]
+		return nil
+	}
+	for s.Scan() {
+		if len(s.Bytes()) == 0 {
+			continue
+		}
+		if n := len(s.Bytes()); n > limit {
+			if err := flush(); err != nil {
+				return pushed, err
+			}
+			return pushed, fmt.Errorf("mq: reading record %d for %s: %w", pushed+1, q.Name, &api.TooLargeError{Limit: "message size", Size: int64(n), Max: int64(limit)})
+		}
+		batch = append(batch, Message{Body: s.Text()})
+		if len(batch) == cap(batch) {
+			if err := flush(); err != nil {
+				return pushed, err
+			}
+		}
+	}
+	// push the records read before a read error too, so it can be resumed
+	if err := flush(); err != nil {
+		return pushed, err
+	}
+	if err := s.Err(); errors.Is(err, bufio.ErrTooLong) {
+		// the record's length is unknown, only that it didn't fit
+		return pushed, fmt.Errorf("mq: reading record %d for %s: %w", pushed+1, q.Name, &api.TooLargeError{Limit: "message size", Size: int64(limit + 1), Max: int64(limit)})
+	} else if err != nil {
+		return pushed, fmt.Errorf("mq: reading record %d for %s: %w", pushed+1, q.Name, err)
+	}
+	return pushed, nil
+}
@@ -0,0 +1,83 @@
+package mq
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/iron-io/iron_go3/api"
+	"github.com/iron-io/iron_go3/irontest"
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestPushFromReader(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("pushing from a reader", func() {
+		srv := irontest.NewServer()
+		defer srv.Close()
+
+		It("pushes lines in batches", func() {
+			var b strings.Builder
+			for i := 0; i < 250; i++ {
+				fmt.Fprintf(&b, "{\"n\":%d}\n", i)
+				if i == 10 {
+					b.WriteString("\n")
+				}
+			}
+			q := Queue{Settings: srv.Settings("iron_mq"), Name: "from-reader"}
+			before := srv.Requests()
+			n, err := q.PushFromReader(strings.NewReader(b.String()), nil)
+			Expect(err, ToBeNil)
+			Expect(n, ToEqual, 250)
+			Expect(srv.Requests()-before, ToEqual, 3)
+
+			bodies := srv.MQ.Messages("from-reader")
+			Expect(len(bodies), ToEqual, 250)
+			Expect(bodies[0], ToEqual, `{"n":0}`)
+			Expect(bodies[249], ToEqual, `{"n":249}`)
+		})
+
+		It("splits custom records", func() {
+			nul := func(data []byte, atEOF bool) (int, []byte, error) {
+				if i := bytes.IndexByte(data, 0); i >= 0 {
+					return i + 1, data[:i], nil
+				}
+				if atEOF && len(data) > 0 {
+					return len(data), data, nil
+				}
+				return 0, nil, nil
+			}
+			q := Queue{Settings: srv.Settings("iron_mq"), Name: "from-reader-nul"}
+			n, err := q.PushFromReader(strings.NewReader("a\nb\x00c\x00d"), nul)
+			Expect(err, ToBeNil)
+			Expect(n, ToEqual, 3)
+			Expect(srv.MQ.Messages("from-reader-nul"), ToDeepEqual, []string{"a\nb", "c", "d"})
+		})
+
+		It("reports how many records were pushed before a read error", func() {
+			long := strings.Repeat("x", bufio.MaxScanTokenSize+1)
+			q := Queue{Settings: srv.Settings("iron_mq"), Name: "from-reader-long"}
+			n, err := q.PushFromReader(strings.NewReader("a\nb\n"+long+"\n"), nil)
+			Expect(err, ToNotBeNil)
+			Expect(n, ToEqual, 2)
+			Expect(len(srv.MQ.Messages("from-reader-long")), ToEqual, 2)
+		})
+
+		It("refuses records longer than the message size", func() {
+			q := Queue{Settings: srv.Settings("iron_mq"), Name: "from-reader-size", Limits: Limits{MessageSize: 5}}
+			n, err := q.PushFromReader(strings.NewReader("12345\n123456\nabc\n"), nil)
+			Expect(errors.Is(err, api.ErrTooLarge), ToBeTrue)
+			Expect(n, ToEqual, 1)
+			Expect(srv.MQ.Messages("from-reader-size"), ToDeepEqual, []string{"12345"})
+
+			q.Name = "from-reader-size-long"
+			n, err = q.PushFromReader(strings.NewReader("ab\n"+strings.Repeat("x", 100)), nil)
+			Expect(errors.Is(err, api.ErrTooLarge), ToBeTrue)
+			Expect(n, ToEqual, 1)
+		})
+	})
+}
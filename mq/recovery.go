@@ -0,0 +1,185 @@
+package mq
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/iron-io/iron_go3/api"
+)
+
+// A ReservationStore persists the reservations of the deliveries a
+// Consumer is handling, so that after a crash the consumer's next Run can
+// release them right away, instead of their messages staying invisible
+// until the reservations time out.
+type ReservationStore interface {
+	// Save stores r, replacing any reservation of the same queue and
+	// message.
+	Save(r Reservation) error
+	// Remove deletes the reservation of r's queue and message.
+	Remove(r Reservation) error
+	// List returns the stored reservations of queue.
+	List(queue string) ([]Reservation, error)
+}
+
+// Recover releases the reservations Reservations holds for the consumer's
+// queue, left over by a consumer that didn't settle them, e.g. because its
+// process died. Run recovers before it starts reserving. Reservations that
+// timed out meanwhile are dropped silently. Recover returns how many
+// messages it released.
+func (c *Consumer) Recover() (int, error) {
+	if c.Reservations == nil {
+		return 0, nil
+	}
+	rs, err := c.Reservations.List(c.Queue.Name)
+	if err != nil {
+		return 0, fmt.Errorf("mq: listing reservations of %s: %w", c.Queue.Name, err)
+	}
+	released := 0
+	var errs []error
+	for _, r := range rs {
+		err := c.Queue.ReleaseMessage(r.MessageId, r.ReservationId, 0)
+		switch code := api.StatusCode(err); {
+		case err == nil:
+			released++
+		case code == http.StatusForbidden || code == http.StatusNotFound:
+			// timed out, or deleted
+		default:
+			errs = append(errs, fmt.Errorf("mq: releasing %s: %w", r.MessageId, err))
+			continue
+		}
+		if err := c.Reservations.Remove(r); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return released, errors.Join(errs...)
+}
+
+// hold saves the reservation of d before it is handled.
+func (c *Consumer) hold(d *Delivery) {
+	if c.Reservations == nil {
+		return
+	}
+	r := Reservation{Queue: c.Queue.Name, MessageId: d.Id, ReservationId: d.ReservationId, Since: time.Now()}
+	if err := c.Reservations.Save(r); err != nil {
+		c.report(fmt.Errorf("mq: saving reservation of %s: %w", d.Id, err))
+		return
+	}
+	d.held = true
+}
+
+// forget removes the reservation of the settled d.
+func (c *Consumer) forget(d *Delivery) {
+	if c == nil || c.Reservations == nil || !d.held {
+		return
+	}
+	if err := c.Reservations.Remove(Reservation{Queue: c.Queue.Name, MessageId: d.Id}); err != nil {
+		c.report(fmt.Errorf("mq: removing reservation of %s: %w", d.Id, err))
+	}
+}
+
+// FileReservations is a ReservationStore keeping reservations in a JSON
+// file, rewritten on every change. It is meant for one consumer process
+// handling a moderate rate of messages.
+type FileReservations struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+// NewFileReservations returns a FileReservations keeping reservations in
+// the file at path.
+func NewFileReservations(path string) *FileReservations {
+	return &FileReservations{Path: path}
+}
+
+type reservationKey struct{ queue, id string }
+
+func (f *FileReservations) Save(r Reservation) error {
+	return f.update(func(rs map[reservationKey]Reservation) {
+		rs[reservationKey{r.Queue, r.MessageId}] = r
+	})
+}
+
+func (f *FileReservations) Remove(r Reservation) error {
+	return f.update(func(rs map[reservationKey]Reservation) {
+		delete(rs, reservationKey{r.Queue, r.MessageId})
+	})
+}
+
+func (f *FileReservations) List(queue string) ([]Reservation, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	all, err := f.load()
+	if err != nil {
+		return nil, err
+	}
+	var rs []Reservation
+	for _, r := range all {
+		if r.Queue == queue {
+			rs = append(rs, r)
+		}
+	}
+	return rs, nil
+}
+
+func (f *FileReservations) update(fn func(map[reservationKey]Reservation)) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	all, err := f.load()
+	if err != nil {
+		return err
+	}
+	rs := make(map[reservationKey]Reservation, len(all))
+	for _, r := range all {
+		rs[reservationKey{r.Queue, r.MessageId}] = r
+	}
+	fn(rs)
+	all = all[:0]
+	for _, r := range rs {
+		all = append(all, r)
+	}
+	return f.store(all)
+}
+
+func (f *FileReservations) load() ([]Reservation, error) {
+	data, err := os.ReadFile(f.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var rs []Reservation
+	if err := json.Unmarshal(data, &rs); err != nil {
+		return nil, fmt.Errorf("mq: reading %s: %w", f.Path, err)
+	}
+	return rs, nil
+}
+
+// store replaces the file, so a crash leaves either version whole.
+func (f *FileReservations) store(rs []Reservation) error {
+	data, err := json.Marshal(rs)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(f.Path), filepath.Base(f.Path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.Path)
+}
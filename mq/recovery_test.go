@@ -0,0 +1,79 @@
+package mq
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/iron-io/iron_go3/irontest"
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestReservationRecovery(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("reservation recovery", func() {
+		srv := irontest.NewServer()
+		defer srv.Close()
+
+		It("keeps reservations in a file", func() {
+			f := NewFileReservations(filepath.Join(t.TempDir(), "reservations.json"))
+			Expect(f.Save(Reservation{Queue: "a", MessageId: "1", ReservationId: "r1"}), ToBeNil)
+			Expect(f.Save(Reservation{Queue: "a", MessageId: "1", ReservationId: "r2"}), ToBeNil)
+			Expect(f.Save(Reservation{Queue: "b", MessageId: "2", ReservationId: "r3"}), ToBeNil)
+
+			rs, err := NewFileReservations(f.Path).List("a")
+			Expect(err, ToBeNil)
+			Expect(len(rs), ToEqual, 1)
+			Expect(rs[0].ReservationId, ToEqual, "r2")
+
+			Expect(f.Remove(Reservation{Queue: "a", MessageId: "1"}), ToBeNil)
+			rs, _ = f.List("a")
+			Expect(len(rs), ToEqual, 0)
+			rs, _ = f.List("b")
+			Expect(len(rs), ToEqual, 1)
+		})
+
+		It("holds reservations while handling", func() {
+			q := Queue{Settings: srv.Settings("iron_mq"), Name: "recovery-held"}
+			q.PushString("hello")
+			store := NewFileReservations(filepath.Join(t.TempDir(), "reservations.json"))
+			var during []Reservation
+			c := NewConsumer(q, HandlerFunc(func(ctx context.Context, d *Delivery) error {
+				during, _ = store.List(q.Name)
+				return d.Touch()
+			}))
+			c.Reservations = store
+			_, err := c.RunOnce(context.Background(), 1)
+			Expect(err, ToBeNil)
+			Expect(len(during), ToEqual, 1)
+			after, _ := store.List(q.Name)
+			Expect(len(after), ToEqual, 0)
+			Expect(len(srv.MQ.Messages(q.Name)), ToEqual, 0)
+		})
+
+		It("releases reservations left over by a crash", func() {
+			q := Queue{Settings: srv.Settings("iron_mq"), Name: "recovery-crash"}
+			q.PushString("hello")
+			msgs, err := q.ReserveWith(ReserveOptions{Timeout: time.Hour})
+			Expect(err, ToBeNil)
+
+			store := NewFileReservations(filepath.Join(t.TempDir(), "reservations.json"))
+			store.Save(Reservation{Queue: q.Name, MessageId: msgs[0].Id, ReservationId: msgs[0].ReservationId})
+			store.Save(Reservation{Queue: q.Name, MessageId: "gone", ReservationId: "stale"})
+
+			c := NewConsumer(q, nil)
+			c.Reservations = store
+			n, err := c.Recover()
+			Expect(err, ToBeNil)
+			Expect(n, ToEqual, 1)
+			left, _ := store.List(q.Name)
+			Expect(len(left), ToEqual, 0)
+
+			msgs, err = q.ReserveWith(ReserveOptions{})
+			Expect(err, ToBeNil)
+			Expect(len(msgs), ToEqual, 1)
+		})
+	})
+}
@@ -0,0 +1,135 @@
+package mq
+
+import (
+	"strings"
+	"sync"
+)
+
+// A QueueEncoding is how the payloads of a queue are encoded.
+type QueueEncoding struct {
+	// Codec encodes the values pushed and decodes deliveries, JSON if nil.
+	Codec Codec
+	// Transforms and Validator are set on the producers and consumers a
+	// CodecRegistry makes.
+	Transforms []Transform
+	Validator  Validator
+}
+
+func (e QueueEncoding) codec() Codec {
+	if e.Codec != nil {
+		return e.Codec
+	}
+	return JSON
+}
+
+// A CodecRegistry maps queue names, or prefixes, to their encodings, so a
+// process handling many queues sets up each queue's codec, transforms and
+// validator once, instead of at every call site:
+//
+//	mq.Codecs.Register("orders", mq.QueueEncoding{Codec: protocodec.Protobuf})
+//	mq.Codecs.RegisterPrefix("audit-", mq.QueueEncoding{Transforms: []mq.Transform{mq.Gzip}})
+//
+//	id, err := mq.Codecs.Push(mq.Codecs.Producer(q, "api"), order, nil)
+//	c := mq.Codecs.Consumer(q, mq.HandlerFunc(func(ctx context.Context, d *mq.Delivery) error {
+//		order, err := mq.DecodeWith[Order](mq.Codecs, d)
+//		...
+//	}))
+//
+// The zero value is ready to use, and it is safe for concurrent use.
+type CodecRegistry struct {
+	// Default is the encoding of queues that aren't registered.
+	Default QueueEncoding
+
+	mu       sync.RWMutex
+	queues   map[string]QueueEncoding
+	prefixes map[string]QueueEncoding
+}
+
+// Codecs is the default CodecRegistry.
+var Codecs = &CodecRegistry{}
+
+// Register sets the encoding of the queue called name.
+func (r *CodecRegistry) Register(name string, e QueueEncoding) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.queues == nil {
+		r.queues = map[string]QueueEncoding{}
+	}
+	r.queues[name] = e
+}
+
+// RegisterPrefix sets the encoding of the queues whose name starts with
+// prefix and that aren't registered by name. The longest prefix wins.
+func (r *CodecRegistry) RegisterPrefix(prefix string, e QueueEncoding) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.prefixes == nil {
+		r.prefixes = map[string]QueueEncoding{}
+	}
+	r.prefixes[prefix] = e
+}
+
+// Lookup returns the encoding of the queue called name, and whether it was
+// registered. Queues that weren't get Default.
+func (r *CodecRegistry) Lookup(name string) (QueueEncoding, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if e, ok := r.queues[name]; ok {
+		return e, true
+	}
+	best, found := "", false
+	for p := range r.prefixes {
+		if strings.HasPrefix(name, p) && (!found || len(p) > len(best)) {
+			best, found = p, true
+		}
+	}
+	if found {
+		return r.prefixes[best], true
+	}
+	return r.Default, false
+}
+
+// Producer returns a Producer of envelopes with id on q, with the
+// transforms and validator of q's encoding.
+func (r *CodecRegistry) Producer(q Queue, id string) *Producer {
+	e, _ := r.Lookup(q.Name)
+	p := NewProducer(q, id)
+	p.Transforms, p.Validator = e.Transforms, e.Validator
+	return p
+}
+
+// Consumer returns a Consumer of q handing deliveries to h, with the
+// transforms and validator of q's encoding.
+func (r *CodecRegistry) Consumer(q Queue, h Handler) *Consumer {
+	e, _ := r.Lookup(q.Name)
+	c := NewConsumer(q, h)
+	c.Transforms, c.Validator = e.Transforms, e.Validator
+	return c
+}
+
+// Push encodes value with the codec of p's queue and pushes it, see
+// PushAs.
+func (r *CodecRegistry) Push(p *Producer, value interface{}, headers map[string]string) (id string, err error) {
+	return r.PushWith(p, PushOptions{}, value, headers)
+}
+
+// PushWith is Push with options.
+func (r *CodecRegistry) PushWith(p *Producer, opts PushOptions, value interface{}, headers map[string]string) (id string, err error) {
+	e, _ := r.Lookup(p.Queue.Name)
+	return PushAsWith(p, opts, e.codec(), value, headers)
+}
+
+// Decode decodes the payload of d into v with the codec of the queue d was
+// reserved from, see Envelope.Decode.
+func (r *CodecRegistry) Decode(d *Delivery, v interface{}) error {
+	e, _ := r.Lookup(d.q.Name)
+	return d.Envelope.Decode(e.codec(), v)
+}
+
+// DecodeWith decodes the payload of d into a T with the codec r has for
+// its queue.
+func DecodeWith[T any](r *CodecRegistry, d *Delivery) (T, error) {
+	var v T
+	err := r.Decode(d, &v)
+	return v, err
+}
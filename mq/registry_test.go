@@ -0,0 +1,65 @@
+package mq
+
+import (
+	"context"
+	"encoding/xml"
+	"testing"
+
+	"github.com/iron-io/iron_go3/irontest"
+	. "github.com/jeffh/go.bdd"
+)
+
+type xmlCodec struct{}
+
+func (xmlCodec) ContentType() string                        { return "application/xml" }
+func (xmlCodec) Marshal(v interface{}) ([]byte, error)      { return xml.Marshal(v) }
+func (xmlCodec) Unmarshal(data []byte, v interface{}) error { return xml.Unmarshal(data, v) }
+
+type registryOrder struct {
+	Id int `json:"id" xml:"id"`
+}
+
+func TestCodecRegistry(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("codec registries", func() {
+		r := &CodecRegistry{}
+		r.Register("legacy-orders", QueueEncoding{Codec: JSON})
+		r.RegisterPrefix("legacy-", QueueEncoding{Codec: xmlCodec{}})
+		r.RegisterPrefix("legacy-gz-", QueueEncoding{Codec: xmlCodec{}, Transforms: []Transform{Gzip}})
+
+		It("looks up names, then the longest prefix", func() {
+			e, ok := r.Lookup("legacy-orders")
+			Expect(ok, ToBeTrue)
+			Expect(e.codec().ContentType(), ToEqual, "application/json")
+			e, _ = r.Lookup("legacy-users")
+			Expect(e.codec().ContentType(), ToEqual, "application/xml")
+			e, _ = r.Lookup("legacy-gz-users")
+			Expect(len(e.Transforms), ToEqual, 1)
+			e, ok = r.Lookup("orders")
+			Expect(ok, ToEqual, false)
+			Expect(e.codec().ContentType(), ToEqual, "application/json")
+		})
+
+		It("pushes and decodes with the queue's encoding", func() {
+			srv := irontest.NewServer()
+			defer srv.Close()
+			q := Queue{Settings: srv.Settings("iron_mq"), Name: "legacy-gz-orders"}
+			_, err := r.Push(r.Producer(q, "p1"), registryOrder{Id: 7}, nil)
+			Expect(err, ToBeNil)
+			e, _ := OpenEnvelope(srv.MQ.Messages("legacy-gz-orders")[0])
+			Expect(e.ContentType, ToEqual, "application/xml")
+			Expect(e.ContentEncoding, ToEqual, "gzip")
+
+			var got registryOrder
+			var derr error
+			c := r.Consumer(q, HandlerFunc(func(ctx context.Context, d *Delivery) error {
+				got, derr = DecodeWith[registryOrder](r, d)
+				return derr
+			}))
+			c.RunOnce(context.Background(), 1)
+			Expect(derr, ToBeNil)
+			Expect(got.Id, ToEqual, 7)
+		})
+	})
+}
@@ -0,0 +1,82 @@
+package mq
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// AttemptsHeader is the envelope header counting how often a message was
+// requeued, see Requeue.
+const AttemptsHeader = "attempts"
+
+// Attempts returns how often the message of e was requeued, 0 if never.
+func (e Envelope) Attempts() int {
+	n, _ := strconv.Atoi(e.Headers[AttemptsHeader])
+	return n
+}
+
+// Requeue pushes a corrected copy of the reserved message m, with newBody
+// as its payload, available after delay, and deletes m. It returns the id
+// of the copy.
+//
+// If m is enveloped, the copy keeps its envelope, but for the payload and
+// the AttemptsHeader, which is incremented. newBody must be encoded like
+// the original payload, see ContentEncoding. The DedupKeyHeader is
+// dropped, so Idempotent doesn't skip the copy as a duplicate of m. Other
+// bodies are replaced by newBody as is, without a count of attempts.
+//
+// If deleting m fails, the copy was pushed nonetheless: its id is returned
+// with the error.
+func (m Message) Requeue(newBody string, delay time.Duration) (string, error) {
+	body := newBody
+	if e, ok := m.Open(); ok {
+		headers := make(map[string]string, len(e.Headers)+1)
+		for k, v := range e.Headers {
+			headers[k] = v
+		}
+		delete(headers, DedupKeyHeader)
+		headers[AttemptsHeader] = strconv.Itoa(e.Attempts() + 1)
+		e.Headers, e.Payload = headers, []byte(newBody)
+		var err error
+		if body, err = e.Encode(); err != nil {
+			return "", fmt.Errorf("mq: requeueing %s: %w", m.Id, err)
+		}
+	}
+	ids, err := m.q.PushWith(PushOptions{Delay: delay}, body)
+	if err != nil {
+		return "", fmt.Errorf("mq: requeueing %s: %w", m.Id, err)
+	}
+	if len(ids) == 0 {
+		return "", fmt.Errorf("mq: requeueing %s: no message id returned", m.Id)
+	}
+	if err := m.Delete(); err != nil {
+		return ids[0], fmt.Errorf("mq: deleting requeued %s: %w", m.Id, err)
+	}
+	return ids[0], nil
+}
+
+// Requeue settles d by requeueing a corrected copy of its message, see
+// Message.Requeue.
+func (d *Delivery) Requeue(newBody string, delay time.Duration) (string, error) {
+	if d.parts != nil {
+		return "", fmt.Errorf("mq: cannot requeue %s, it was reassembled from chunks", d.Id)
+	}
+	var id string
+	var deleteErr error
+	err := d.settle(func() error {
+		d.outcome.Store(OutcomeRequeued)
+		var err error
+		id, err = d.Message.Requeue(newBody, delay)
+		if id != "" {
+			// the copy was pushed, d mustn't be settled again
+			deleteErr = err
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return id, deleteErr
+}
@@ -0,0 +1,78 @@
+package mq
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/iron-io/iron_go3/irontest"
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestRequeue(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("requeueing", func() {
+		srv := irontest.NewServer()
+		defer srv.Close()
+		q := Queue{Settings: srv.Settings("iron_mq"), Name: "fixes"}
+
+		It("keeps the envelope and counts attempts", func() {
+			p := NewProducer(q, "p1")
+			p.ContentType = "application/json"
+			p.Push([]byte(`{"amount": "12"}`), map[string]string{"trace": "abc", DedupKeyHeader: "k1"})
+			msgs, _ := q.ReserveWith(ReserveOptions{})
+			before, _ := msgs[0].Open()
+
+			id, err := msgs[0].Requeue(`{"amount": 12}`, 0)
+			Expect(err, ToBeNil)
+			Expect(len(srv.MQ.Messages("fixes")), ToEqual, 1)
+
+			msgs, _ = q.ReserveWith(ReserveOptions{})
+			Expect(msgs[0].Id, ToEqual, id)
+			e, ok := msgs[0].Open()
+			Expect(ok, ToBeTrue)
+			Expect(string(e.Payload), ToEqual, `{"amount": 12}`)
+			Expect(e.ContentType, ToEqual, "application/json")
+			Expect(e.ProducerId, ToEqual, "p1")
+			Expect(e.ProducedAt.Equal(before.ProducedAt), ToBeTrue)
+			Expect(e.Headers, ToDeepEqual, map[string]string{"trace": "abc", AttemptsHeader: "1"})
+
+			msgs[0].Requeue(`{"amount": 13}`, 0)
+			msgs, _ = q.ReserveWith(ReserveOptions{})
+			e, _ = msgs[0].Open()
+			Expect(e.Attempts(), ToEqual, 2)
+			msgs[0].Delete()
+		})
+
+		It("replaces raw bodies", func() {
+			q.PushString("bad")
+			msgs, _ := q.ReserveWith(ReserveOptions{})
+			_, err := msgs[0].Requeue("good", time.Minute)
+			Expect(err, ToBeNil)
+			Expect(srv.MQ.Messages("fixes"), ToDeepEqual, []string{"good"})
+			msgs, _ = q.ReserveWith(ReserveOptions{})
+			Expect(len(msgs), ToEqual, 0)
+			q.Clear()
+		})
+
+		It("settles deliveries", func() {
+			q.PushString("bad")
+			var m recordedDeliveries
+			c := NewConsumer(q, HandlerFunc(func(ctx context.Context, d *Delivery) error {
+				_, err := d.Requeue("good", time.Minute)
+				return err
+			}))
+			c.Metrics = &m
+			c.RunOnce(context.Background(), 1)
+			Expect(srv.MQ.Messages("fixes"), ToDeepEqual, []string{"good"})
+			Expect(m.outcomes, ToDeepEqual, []string{OutcomeRequeued})
+		})
+	})
+}
+
+type recordedDeliveries struct{ outcomes []string }
+
+func (r *recordedDeliveries) ObserveDelivery(s DeliveryStats) {
+	r.outcomes = append(r.outcomes, s.Outcome)
+}
@@ -0,0 +1,153 @@
+package mq
+
+import (
+	"fmt"
+
+	"github.com/iron-io/iron_go3/api"
+)
+
+// Limits of the push API.
+const (
+	// MaxPush is the most messages a single push request takes.
+	MaxPush = 100
+	// MaxMessageSize is the most bytes a message body may have.
+	MaxMessageSize = 64 * 1024
+)
+
+// Limits are the sizes of the messages a queue takes. Pushes exceeding
+// them fail with an *api.TooLargeError before anything is sent, rather
+// than being refused by the server once uploaded. Zero values take the
+// API's limits; set them for servers configured otherwise.
+type Limits struct {
+	// MessageSize is the most bytes of a message body, MaxMessageSize by
+	// default.
+	MessageSize int
+	// BatchSize is the most messages of a push request, MaxPush by
+	// default.
+	BatchSize int
+}
+
+func (l Limits) messageSize() int {
+	if l.MessageSize > 0 {
+		return l.MessageSize
+	}
+	return MaxMessageSize
+}
+
+func (l Limits) batchSize() int {
+	if l.BatchSize > 0 {
+		return l.BatchSize
+	}
+	return MaxPush
+}
+
+// check returns the error of pushing msgs to q, if they exceed its limits.
+func (l Limits) check(q Queue, msgs []Message) error {
+	if n := len(msgs); n > l.batchSize() {
+		return fmt.Errorf("mq: pushing to %s: %w", q.Name, &api.TooLargeError{Limit: "batch size", Size: int64(n), Max: int64(l.batchSize())})
+	}
+	for i, m := range msgs {
+		if n := len(m.Body); n > l.messageSize() {
+			return fmt.Errorf("mq: pushing to %s: message %d: %w", q.Name, i, &api.TooLargeError{Limit: "message size", Size: int64(n), Max: int64(l.messageSize())})
+		}
+	}
+	return nil
+}
+
+// A PushResult is the outcome of pushing one message: its id if it was
+// pushed, the error it failed with otherwise.
+type PushResult struct {
+	Id  string
+	Err error
+}
+
+// A PartialError is returned when some messages of a push failed. Its
+// Results line up with the pushed messages.
+type PartialError struct {
+	Queue   string
+	Results []PushResult
+}
+
+func (e *PartialError) Error() string {
+	failed := e.Failed()
+	if len(failed) == 0 {
+		return fmt.Sprintf("mq: pushing to %s: no message failed", e.Queue)
+	}
+	return fmt.Sprintf("mq: pushing to %s: %d of %d messages failed, the first with: %v",
+		e.Queue, len(failed), len(e.Results), e.Results[failed[0]].Err)
+}
+
+// Unwrap returns the errors of the failed messages, for errors.Is and
+// errors.As.
+func (e *PartialError) Unwrap() []error {
+	var errs []error
+	for _, r := range e.Results {
+		if r.Err != nil {
+			errs = append(errs, r.Err)
+		}
+	}
+	return errs
+}
+
+// Failed returns the indexes of the messages that failed.
+func (e *PartialError) Failed() []int {
+	var failed []int
+	for i, r := range e.Results {
+		if r.Err != nil {
+			failed = append(failed, i)
+		}
+	}
+	return failed
+}
+
+// PushMessagesResults enqueues each message in order, in requests of up to
+// the queue's batch size, and returns the result of each. Messages larger
+// than the queue's Limits fail with an *api.TooLargeError without being
+// sent, the others are pushed. If some messages failed, it returns a
+// *PartialError too, so they can be retried:
+//
+//	results, err := q.PushMessagesResults(msgs...)
+//	var perr *mq.PartialError
+//	if errors.As(err, &perr) {
+//		for _, i := range perr.Failed() {
+//			retry = append(retry, msgs[i])
+//		}
+//	}
+func (q Queue) PushMessagesResults(msgs ...Message) ([]PushResult, error) {
+	results := make([]PushResult, len(msgs))
+	failed := false
+	var push []int // indexes of the messages within the limits
+	for i, m := range msgs {
+		if n, limit := len(m.Body), q.Limits.messageSize(); n > limit {
+			results[i].Err = fmt.Errorf("mq: pushing to %s: message %d: %w", q.Name, i, &api.TooLargeError{Limit: "message size", Size: int64(n), Max: int64(limit)})
+			failed = true
+			continue
+		}
+		push = append(push, i)
+	}
+
+	batch := q.Limits.batchSize()
+	for start := 0; start < len(push); start += batch {
+		idx := push[start:min(start+batch, len(push))]
+		part := make([]Message, len(idx))
+		for j, i := range idx {
+			part[j] = msgs[i]
+		}
+		ids, err := q.PushMessages(part...)
+		for j, i := range idx {
+			switch {
+			case err != nil:
+				results[i].Err = err
+			case j < len(ids):
+				results[i].Id = ids[j]
+			default:
+				results[i].Err = fmt.Errorf("didn't receive message ID for pushing message to %s", q.Name)
+			}
+			failed = failed || results[i].Err != nil
+		}
+	}
+	if failed {
+		return results, &PartialError{Queue: q.Name, Results: results}
+	}
+	return results, nil
+}
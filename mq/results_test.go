@@ -0,0 +1,127 @@
+package mq
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/iron-io/iron_go3/api"
+	"github.com/iron-io/iron_go3/irontest"
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestPushMessagesResults(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("per-message push results", func() {
+		srv := irontest.NewServer()
+		defer srv.Close()
+
+		// rejects the second push request
+		var pushes int32
+		picky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == "POST" && strings.HasSuffix(r.URL.Path, "/messages") && atomic.AddInt32(&pushes, 1) == 2 {
+				http.Error(w, `{"msg":"rejected"}`, http.StatusBadRequest)
+				return
+			}
+			srv.ServeHTTP(w, r)
+		}))
+		defer picky.Close()
+		s := srv.Settings("iron_mq")
+		host, port, _ := net.SplitHostPort(strings.TrimPrefix(picky.URL, "http://"))
+		p, _ := strconv.Atoi(port)
+		s.Host, s.Port = host, uint16(p)
+
+		msgs := func(n int) []Message {
+			m := make([]Message, n)
+			for i := range m {
+				m[i].Body = fmt.Sprint(i)
+			}
+			return m
+		}
+
+		It("returns an id per message", func() {
+			q := Queue{Settings: srv.Settings("iron_mq"), Name: "results-ok"}
+			results, err := q.PushMessagesResults(msgs(150)...)
+			Expect(err, ToBeNil)
+			Expect(len(results), ToEqual, 150)
+			for _, r := range results {
+				Expect(r.Err, ToBeNil)
+				Expect(r.Id != "", ToBeTrue)
+			}
+			Expect(len(srv.MQ.Messages("results-ok")), ToEqual, 150)
+		})
+
+		It("tells which messages failed", func() {
+			atomic.StoreInt32(&pushes, 0)
+			q := Queue{Settings: s, Name: "results-partial"}
+			results, err := q.PushMessagesResults(msgs(250)...)
+			var perr *PartialError
+			Expect(errors.As(err, &perr), ToBeTrue)
+			Expect(perr.Results, ToDeepEqual, results)
+
+			failed := perr.Failed()
+			Expect(len(failed), ToEqual, MaxPush)
+			Expect(failed[0], ToEqual, 100)
+			Expect(failed[len(failed)-1], ToEqual, 199)
+			Expect(results[99].Id != "", ToBeTrue)
+			Expect(results[200].Id != "", ToBeTrue)
+			Expect(api.StatusCode(err), ToEqual, http.StatusBadRequest)
+			Expect(len(srv.MQ.Messages("results-partial")), ToEqual, 150)
+		})
+	})
+}
+
+func TestLimits(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("push limits", func() {
+		srv := irontest.NewServer()
+		defer srv.Close()
+
+		It("refuses oversized messages before sending them", func() {
+			q := Queue{Settings: srv.Settings("iron_mq"), Name: "limits", Limits: Limits{MessageSize: 10}}
+			_, err := q.PushStrings("small", "far too large")
+			Expect(errors.Is(err, api.ErrTooLarge), ToBeTrue)
+			var tl *api.TooLargeError
+			Expect(errors.As(err, &tl), ToBeTrue)
+			Expect(*tl, ToEqual, api.TooLargeError{Limit: "message size", Size: 13, Max: 10})
+			Expect(api.StatusCode(err), ToEqual, http.StatusRequestEntityTooLarge)
+			Expect(len(srv.MQ.Messages("limits")), ToEqual, 0)
+		})
+
+		It("refuses oversized batches", func() {
+			q := Queue{Settings: srv.Settings("iron_mq"), Name: "limits"}
+			_, err := q.PushMessages(make([]Message, MaxPush+1)...)
+			var tl *api.TooLargeError
+			Expect(errors.As(err, &tl), ToBeTrue)
+			Expect(tl.Limit, ToEqual, "batch size")
+		})
+
+		It("fails only the oversized messages of a batch", func() {
+			q := Queue{Settings: srv.Settings("iron_mq"), Name: "limits-partial", Limits: Limits{MessageSize: 10}}
+			results, err := q.PushMessagesResults(Message{Body: "small"}, Message{Body: "far too large"}, Message{Body: "fine"})
+			var perr *PartialError
+			Expect(errors.As(err, &perr), ToBeTrue)
+			Expect(perr.Failed(), ToDeepEqual, []int{1})
+			Expect(errors.Is(results[1].Err, api.ErrTooLarge), ToBeTrue)
+			Expect(results[0].Id != "", ToBeTrue)
+			Expect(results[2].Id != "", ToBeTrue)
+			Expect(srv.MQ.Messages("limits-partial"), ToDeepEqual, []string{"small", "fine"})
+		})
+
+		It("splits pushes by the batch size", func() {
+			q := Queue{Settings: srv.Settings("iron_mq"), Name: "limits-split", Limits: Limits{BatchSize: 2}}
+			results, err := q.PushMessagesResults(make([]Message, 5)...)
+			Expect(err, ToBeNil)
+			Expect(len(results), ToEqual, 5)
+			Expect(len(srv.MQ.Messages("limits-split")), ToEqual, 5)
+		})
+	})
+}
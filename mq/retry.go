@@ -0,0 +1,159 @@
+package mq
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultRetryDelays are the delays of the tiers NewRetryQueues makes when
+// given none.
+var DefaultRetryDelays = []time.Duration{time.Minute, 5 * time.Minute, 30 * time.Minute}
+
+// A RetryTier is a queue failed messages wait on before they are handled
+// again.
+type RetryTier struct {
+	Queue Queue
+	Delay time.Duration
+}
+
+// RetryQueues route messages that failed on Primary through retry tiers of
+// growing delays, e.g. "jobs-retry-1m", "jobs-retry-5m" and
+// "jobs-retry-30m", and finally to DeadLetter, e.g. "jobs-dead". Each tier
+// is consumed like the primary queue, with the same handler, so a message
+// is handled at most len(Tiers)+1 times.
+type RetryQueues struct {
+	Primary    Queue
+	Tiers      []RetryTier
+	DeadLetter Queue
+}
+
+// NewRetryQueues returns retry tiers of primary with the given delays,
+// DefaultRetryDelays if none are given. The queues are named after primary
+// and the delay of the tier, so the delays must differ.
+func NewRetryQueues(primary Queue, delays ...time.Duration) *RetryQueues {
+	if len(delays) == 0 {
+		delays = DefaultRetryDelays
+	}
+	r := &RetryQueues{Primary: primary, DeadLetter: primary}
+	r.DeadLetter.Name = primary.Name + "-dead"
+	for _, delay := range delays {
+		tier := RetryTier{Queue: primary, Delay: delay}
+		tier.Queue.Name = primary.Name + "-retry-" + shortDuration(delay)
+		r.Tiers = append(r.Tiers, tier)
+	}
+	return r
+}
+
+// shortDuration formats d in its largest whole unit, e.g. "90s" or "2h".
+func shortDuration(d time.Duration) string {
+	switch {
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	case d%time.Minute == 0:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	}
+	return fmt.Sprintf("%ds", seconds(d))
+}
+
+// Queues returns the primary queue, the tiers' queues and the dead letter
+// queue.
+func (r *RetryQueues) Queues() []Queue {
+	queues := []Queue{r.Primary}
+	for _, tier := range r.Tiers {
+		queues = append(queues, tier.Queue)
+	}
+	return append(queues, r.DeadLetter)
+}
+
+// Provision creates the queues that don't exist yet.
+func (r *RetryQueues) Provision() error {
+	for _, q := range r.Queues() {
+		_, err := q.Info()
+		if ErrQueueNotFound(err) {
+			_, err = q.Create(QueueInfo{})
+		}
+		if err != nil {
+			return fmt.Errorf("mq: provisioning %s: %w", q.Name, err)
+		}
+	}
+	return nil
+}
+
+// Retry settles d by moving its message to the tier after the queue it
+// came from, delayed by the tier's delay, or to DeadLetter if there is no
+// further tier.
+func (r *RetryQueues) Retry(d *Delivery) error {
+	return d.settle(func() error {
+		d.outcome.Store(OutcomeRetried)
+		next, delay := r.next(d.q.Name)
+		return d.move(next, PushOptions{Delay: delay})
+	})
+}
+
+func (r *RetryQueues) next(from string) (Queue, time.Duration) {
+	i := 0
+	if from != r.Primary.Name {
+		for i < len(r.Tiers) && r.Tiers[i].Queue.Name != from {
+			i++
+		}
+		i++
+	}
+	if i >= len(r.Tiers) {
+		return r.DeadLetter, 0
+	}
+	return r.Tiers[i].Queue, r.Tiers[i].Delay
+}
+
+// Handler returns h, with deliveries it fails and doesn't settle retried
+// instead of nacked.
+func (r *RetryQueues) Handler(h Handler) Handler {
+	return HandlerFunc(func(ctx context.Context, d *Delivery) error {
+		err := handle(ctx, h, d)
+		if err == nil || d.Settled() {
+			return err
+		}
+		if rerr := r.Retry(d); rerr != nil {
+			return rerr
+		}
+		return nil
+	})
+}
+
+// Consumers returns consumers of the primary queue and the tiers, handling
+// deliveries with r.Handler(h) and terminating them to DeadLetter.
+func (r *RetryQueues) Consumers(h Handler) []*Consumer {
+	h = r.Handler(h)
+	queues := r.Queues()
+	consumers := make([]*Consumer, 0, len(queues)-1)
+	for _, q := range queues[:len(queues)-1] {
+		c := NewConsumer(q, h)
+		c.DeadLetter = &r.DeadLetter
+		consumers = append(consumers, c)
+	}
+	return consumers
+}
+
+// Run runs the Consumers of h until ctx is done. It returns the first
+// error a consumer stopped with.
+func (r *RetryQueues) Run(ctx context.Context, h Handler) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var once sync.Once
+	var first error
+	var wg sync.WaitGroup
+	for _, c := range r.Consumers(h) {
+		wg.Add(1)
+		go func(c *Consumer) {
+			defer wg.Done()
+			if err := c.Run(ctx); err != nil {
+				once.Do(func() { first = err })
+				cancel()
+			}
+		}(c)
+	}
+	wg.Wait()
+	return first
+}
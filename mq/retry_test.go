@@ -0,0 +1,85 @@
+package mq
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/iron-io/iron_go3/irontest"
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestRetryQueues(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("retry queues", func() {
+		srv := irontest.NewServer()
+		defer srv.Close()
+		q := Queue{Settings: srv.Settings("iron_mq"), Name: "orders"}
+
+		It("names the tiers after their delays", func() {
+			r := NewRetryQueues(q)
+			var names []string
+			for _, q := range r.Queues() {
+				names = append(names, q.Name)
+			}
+			Expect(names, ToDeepEqual, []string{"orders", "orders-retry-1m", "orders-retry-5m", "orders-retry-30m", "orders-dead"})
+			Expect(shortDuration(90*time.Second), ToEqual, "90s")
+			Expect(shortDuration(2*time.Hour), ToEqual, "2h")
+		})
+
+		It("provisions the queues", func() {
+			r := NewRetryQueues(q, time.Second)
+			Expect(r.Provision(), ToBeNil)
+			for _, q := range r.Queues() {
+				_, ok := srv.MQ.Info(q.Name)
+				Expect(ok, ToBeTrue)
+			}
+			Expect(r.Provision(), ToBeNil)
+		})
+
+		It("routes failures through the tiers to the dead letter queue", func() {
+			r := NewRetryQueues(q, time.Second, 2*time.Second)
+			attempts := 0
+			consumers := r.Consumers(HandlerFunc(func(ctx context.Context, d *Delivery) error {
+				attempts++
+				return errors.New("always fails")
+			}))
+			Expect(len(consumers), ToEqual, 3)
+			q.PushString("order")
+
+			consumers[0].RunOnce(context.Background(), 1)
+			Expect(srv.MQ.Messages("orders-retry-1s"), ToDeepEqual, []string{"order"})
+			n, _ := consumers[1].RunOnce(context.Background(), 1)
+			Expect(n, ToEqual, 0) // still delayed
+
+			for _, c := range consumers[1:] {
+				c.Wait = 3 * time.Second
+				n, _ = c.RunOnce(context.Background(), 1)
+				Expect(n, ToEqual, 1)
+			}
+			Expect(attempts, ToEqual, 3)
+			Expect(srv.MQ.Messages("orders-dead"), ToDeepEqual, []string{"order"})
+			for _, q := range r.Queues()[:3] {
+				Expect(len(srv.MQ.Messages(q.Name)), ToEqual, 0)
+			}
+		})
+
+		It("leaves successes and settled deliveries alone", func() {
+			r := NewRetryQueues(Queue{Settings: q.Settings, Name: "ok"})
+			h := r.Handler(HandlerFunc(func(ctx context.Context, d *Delivery) error {
+				if d.Body == "nack" {
+					d.Nack(0)
+					return errors.New("nacked")
+				}
+				return nil
+			}))
+			r.Primary.PushStrings("fine", "nack")
+			c := NewConsumer(r.Primary, h)
+			c.RunOnce(context.Background(), 2)
+			Expect(srv.MQ.Messages("ok"), ToDeepEqual, []string{"nack"})
+			Expect(len(srv.MQ.Messages("ok-retry-1m")), ToEqual, 0)
+		})
+	})
+}
@@ -0,0 +1,107 @@
+package mq
+
+import (
+	"context"
+	"regexp"
+)
+
+// MaxPeek is the most messages a peek returns.
+const MaxPeek = 100
+
+// Search returns up to limit messages of the queue matching match, without
+// reserving them, e.g. to find the message of an order during an incident:
+//
+//	msgs, err := q.Search(ctx, mq.BodyMatches(regexp.MustCompile(`"order_id":\s*12345\b`)), 10)
+//
+// The API only peeks at the first MaxPeek visible messages of a queue, so
+// that is all Search sees: reserved and delayed messages, and those
+// further back, aren't searched. A limit of 0 returns all matches.
+func (q Queue) Search(ctx context.Context, match func(Message) bool, limit int) ([]Message, error) {
+	msgs, err := q.peek(ctx, MaxPeek)
+	if err != nil {
+		return nil, err
+	}
+	var found []Message
+	for _, m := range msgs {
+		if match(m) {
+			found = append(found, m)
+			if len(found) == limit {
+				break
+			}
+		}
+	}
+	return found, nil
+}
+
+// BodyMatches returns a Search predicate matching the messages whose body
+// matches re.
+func BodyMatches(re *regexp.Regexp) func(Message) bool {
+	return func(m Message) bool { return re.MatchString(m.Body) }
+}
+
+func (q Queue) peek(ctx context.Context, n int) ([]Message, error) {
+	var out struct {
+		Messages []Message `json:"messages"`
+	}
+	err := q.queues(q.Name, "messages").WithContext(ctx).
+		QueryAdd("n", "%d", n).
+		Req("GET", nil, &out)
+	for i := range out.Messages {
+		out.Messages[i].q = q
+	}
+	return out.Messages, err
+}
+
+// A CountResult tells how many of a queue's messages CountWhere found
+// matching.
+type CountResult struct {
+	// Matched counts the matches among the Scanned messages.
+	Matched int
+	Scanned int
+	// Size is the number of messages in the queue when it was counted.
+	Size int
+}
+
+// Estimate extrapolates Matched to the whole queue, assuming the scanned
+// messages are representative of those further back.
+func (r CountResult) Estimate() int {
+	if r.Scanned == 0 || r.Scanned >= r.Size {
+		return r.Matched
+	}
+	return r.Matched * r.Size / r.Scanned
+}
+
+// CountWhere counts the messages of the queue matching match among up to
+// maxScan of them, without reserving them, e.g. to tell how much of a
+// backlog is of one tenant during an incident:
+//
+//	res, err := q.CountWhere(ctx, mq.BodyMatches(regexp.MustCompile(`"tenant":"acme"`)), 0)
+//	fmt.Printf("~%d of %d\n", res.Estimate(), res.Size)
+//
+// Like Search, it only sees the first MaxPeek visible messages, so a
+// maxScan of 0 or above MaxPeek scans MaxPeek messages, and counts of
+// larger queues are approximate, see Estimate.
+func (q Queue) CountWhere(ctx context.Context, match func(Message) bool, maxScan int) (CountResult, error) {
+	if maxScan <= 0 || maxScan > MaxPeek {
+		maxScan = MaxPeek
+	}
+	var res CountResult
+	var out struct {
+		QI QueueInfo `json:"queue"`
+	}
+	if err := q.queues(q.Name).WithContext(ctx).Req("GET", nil, &out); err != nil {
+		return res, err
+	}
+	res.Size = out.QI.Size
+	msgs, err := q.peek(ctx, maxScan)
+	if err != nil {
+		return res, err
+	}
+	res.Scanned = len(msgs)
+	for _, m := range msgs {
+		if match(m) {
+			res.Matched++
+		}
+	}
+	return res, nil
+}
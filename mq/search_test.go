@@ -0,0 +1,64 @@
+package mq
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/iron-io/iron_go3/irontest"
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestSearch(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("searching a queue", func() {
+		srv := irontest.NewServer()
+		defer srv.Close()
+		q := Queue{Settings: srv.Settings("iron_mq"), Name: "search"}
+		for i := 0; i < 20; i++ {
+			q.PushString(fmt.Sprintf(`{"order_id": %d}`, 12340+i))
+		}
+		ctx := context.Background()
+
+		It("finds messages by body", func() {
+			msgs, err := q.Search(ctx, BodyMatches(regexp.MustCompile(`"order_id":\s*12345\b`)), 10)
+			Expect(err, ToBeNil)
+			Expect(len(msgs), ToEqual, 1)
+			Expect(msgs[0].Body, ToEqual, `{"order_id": 12345}`)
+		})
+
+		It("stops at the limit", func() {
+			all := func(Message) bool { return true }
+			msgs, err := q.Search(ctx, all, 3)
+			Expect(err, ToBeNil)
+			Expect(len(msgs), ToEqual, 3)
+			msgs, err = q.Search(ctx, all, 0)
+			Expect(err, ToBeNil)
+			Expect(len(msgs), ToEqual, 20)
+		})
+
+		It("counts matches without reserving them", func() {
+			res, err := q.CountWhere(ctx, func(m Message) bool { return strings.HasSuffix(m.Body, "0}") }, 0)
+			Expect(err, ToBeNil)
+			Expect(res, ToEqual, CountResult{Matched: 2, Scanned: 20, Size: 20})
+			Expect(res.Estimate(), ToEqual, 2)
+
+			res, err = q.CountWhere(ctx, func(m Message) bool { return strings.HasSuffix(m.Body, "0}") }, 10)
+			Expect(err, ToBeNil)
+			Expect(res, ToEqual, CountResult{Matched: 1, Scanned: 10, Size: 20})
+			Expect(res.Estimate(), ToEqual, 2)
+		})
+
+		It("doesn't reserve what it finds", func() {
+			msgs, err := q.Search(ctx, func(m Message) bool { return strings.HasSuffix(m.Body, "0}") }, 0)
+			Expect(err, ToBeNil)
+			Expect(len(msgs), ToEqual, 2)
+			reserved, err := q.ReserveWith(ReserveOptions{N: 20})
+			Expect(err, ToBeNil)
+			Expect(len(reserved), ToEqual, 20)
+		})
+	})
+}
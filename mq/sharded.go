@@ -0,0 +1,167 @@
+package mq
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// A Sharded queue spreads a logical queue over physical queues named after
+// it with the shard's index, e.g. "jobs-0" to "jobs-7", to get past the
+// throughput of a single queue. Messages pushed with the same partition key
+// land on the same shard, so they keep their order relative to each other.
+//
+// Keys are assigned with a jump consistent hash, so going from n to n+1
+// shards moves only 1/(n+1) of the keys, and shrinking moves only the keys
+// of the removed shards. To rebalance, create the new shards, switch the
+// producers over and keep consuming the old layout until the shards it
+// drops are empty; consumers see the keys that moved on both shards while
+// that happens, so ordering per key only holds again once they are drained.
+type Sharded struct {
+	Shards []Queue
+
+	next uint32 // shard consumers start at, rotated to spread load
+}
+
+// NewSharded returns n shards of q, using q's Settings and Client.
+func NewSharded(q Queue, n int) *Sharded {
+	s := &Sharded{Shards: make([]Queue, n)}
+	for i := range s.Shards {
+		s.Shards[i] = q
+		s.Shards[i].Name = shardName(q.Name, i)
+	}
+	return s
+}
+
+// DiscoverShards returns the shards of q that exist on the server. They
+// must be numbered from 0 without gaps.
+func DiscoverShards(q Queue) (*Sharded, error) {
+	prefix := q.Name + "-"
+	found := map[int]Queue{}
+	prev := ""
+	for {
+		queues, err := q.ListQueues(prefix, prev, 100)
+		if err != nil {
+			return nil, err
+		}
+		for _, shard := range queues {
+			i, err := strconv.Atoi(strings.TrimPrefix(shard.Name, prefix))
+			if err == nil && i >= 0 && shard.Name == shardName(q.Name, i) {
+				found[i] = shard
+			}
+		}
+		if len(queues) < 100 {
+			break
+		}
+		prev = queues[len(queues)-1].Name
+	}
+
+	if len(found) == 0 {
+		return nil, fmt.Errorf("mq: no shards of %s found", q.Name)
+	}
+	indexes := make([]int, 0, len(found))
+	for i := range found {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+	s := &Sharded{Shards: make([]Queue, len(indexes))}
+	for n, i := range indexes {
+		if n != i {
+			return nil, fmt.Errorf("mq: shard %s is missing", shardName(q.Name, n))
+		}
+		s.Shards[i] = found[i]
+	}
+	return s, nil
+}
+
+func shardName(name string, i int) string {
+	return name + "-" + strconv.Itoa(i)
+}
+
+// Shard returns the shard messages with key are pushed to.
+func (s *Sharded) Shard(key string) Queue {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return s.Shards[jumpHash(h.Sum64(), len(s.Shards))]
+}
+
+// jumpHash is the jump consistent hash of Lamping and Veach, it maps key
+// to one of n buckets.
+func jumpHash(key uint64, n int) int {
+	var b, j int64 = -1, 0
+	for j < int64(n) {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+	return int(b)
+}
+
+// Push enqueues a message with body on the shard of key.
+func (s *Sharded) Push(key, body string) (id string, err error) {
+	return s.Shard(key).PushString(body)
+}
+
+// PushMessages enqueues msgs on the shard of key.
+func (s *Sharded) PushMessages(key string, msgs ...Message) (ids []string, err error) {
+	return s.Shard(key).PushMessages(msgs...)
+}
+
+// ReserveWith reserves up to opts.N messages, visiting the shards in turn
+// from a different one on every call. If none has messages and opts.Wait
+// is set, it long polls the first shard visited. The messages belong to
+// their shard, so they are deleted, touched and released as usual.
+func (s *Sharded) ReserveWith(opts ReserveOptions) ([]Message, error) {
+	if opts.N == 0 {
+		opts.N = 1
+	}
+	wait := opts.Wait
+	opts.Wait = 0
+
+	start := int(atomic.AddUint32(&s.next, 1)) % len(s.Shards)
+	var msgs []Message
+	for i := 0; i < len(s.Shards) && len(msgs) < opts.N; i++ {
+		o := opts
+		o.N -= len(msgs)
+		got, err := s.Shards[(start+i)%len(s.Shards)].ReserveWith(o)
+		msgs = append(msgs, got...)
+		if err != nil {
+			return msgs, err
+		}
+	}
+
+	if len(msgs) == 0 && wait > 0 {
+		opts.Wait = wait
+		return s.Shards[start].ReserveWith(opts)
+	}
+	return msgs, nil
+}
+
+// PopWith deletes messages from the shards and returns them, like
+// ReserveWith.
+func (s *Sharded) PopWith(opts PopOptions) ([]Message, error) {
+	return s.ReserveWith(ReserveOptions{N: opts.N, Wait: opts.Wait, Delete: true})
+}
+
+// Info sums up the sizes of the shards. The name of the result is that of
+// the logical queue.
+func (s *Sharded) Info() (QueueInfo, error) {
+	var total QueueInfo
+	for i, shard := range s.Shards {
+		info, err := shard.Info()
+		if err != nil {
+			return total, err
+		}
+		if i == 0 {
+			total = info
+			total.Name = strings.TrimSuffix(shard.Name, "-0")
+			continue
+		}
+		total.Size += info.Size
+		total.TotalMessages += info.TotalMessages
+	}
+	return total, nil
+}
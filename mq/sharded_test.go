@@ -0,0 +1,86 @@
+package mq
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/iron-io/iron_go3/irontest"
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestSharded(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("sharded queues", func() {
+		srv := irontest.NewServer()
+		defer srv.Close()
+		q := Queue{Settings: srv.Settings("iron_mq"), Name: "jobs"}
+		s := NewSharded(q, 4)
+
+		It("names the shards", func() {
+			Expect(s.Shards[0].Name, ToEqual, "jobs-0")
+			Expect(s.Shards[3].Name, ToEqual, "jobs-3")
+			Expect(s.Shards[3].Settings, ToEqual, q.Settings)
+		})
+
+		It("keeps keys on one shard", func() {
+			for i := 0; i < 20; i++ {
+				_, err := s.Push("user-1", fmt.Sprint(i))
+				Expect(err, ToBeNil)
+			}
+			Expect(len(srv.MQ.Messages(s.Shard("user-1").Name)), ToEqual, 20)
+		})
+
+		It("spreads keys over the shards", func() {
+			counts := map[string]int{}
+			for i := 0; i < 1000; i++ {
+				counts[s.Shard(fmt.Sprint("key", i)).Name]++
+			}
+			Expect(len(counts), ToEqual, 4)
+			for _, n := range counts {
+				Expect(n > 150, ToBeTrue)
+			}
+		})
+
+		It("moves few keys when growing", func() {
+			moved := 0
+			for i := 0; i < 1000; i++ {
+				key := uint64(i) * 0x9e3779b97f4a7c15
+				if jumpHash(key, 4) != jumpHash(key, 5) {
+					moved++
+				}
+			}
+			Expect(moved < 300, ToBeTrue)
+		})
+
+		It("reserves from all shards", func() {
+			for i := 0; i < 4; i++ {
+				s.Shards[i].PushString("x")
+			}
+			msgs, err := s.PopWith(PopOptions{N: 30})
+			Expect(err, ToBeNil)
+			Expect(len(msgs), ToEqual, 24)
+			info, err := s.Info()
+			Expect(err, ToBeNil)
+			Expect(info.Name, ToEqual, "jobs")
+			Expect(info.Size, ToEqual, 0)
+		})
+
+		It("discovers shards", func() {
+			Queue{Settings: q.Settings, Name: "jobs-archive"}.PushString("x")
+			found, err := DiscoverShards(q)
+			Expect(err, ToBeNil)
+			Expect(len(found.Shards), ToEqual, 4)
+			Expect(found.Shards[2].Name, ToEqual, "jobs-2")
+		})
+
+		It("reports missing shards", func() {
+			Queue{Settings: q.Settings, Name: "gaps-0"}.PushString("x")
+			Queue{Settings: q.Settings, Name: "gaps-2"}.PushString("x")
+			_, err := DiscoverShards(Queue{Settings: q.Settings, Name: "gaps"})
+			Expect(err, ToNotBeNil)
+			_, err = DiscoverShards(Queue{Settings: q.Settings, Name: "none"})
+			Expect(err, ToNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,137 @@
+package mq
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+)
+
+// SubscriberTestHeader is set on the test messages TestSubscriber pushes,
+// so subscribers can acknowledge them without processing them.
+const SubscriberTestHeader = "Iron-Subscriber-Test"
+
+// TestSubscriberOptions configure TestSubscriberWith. Zero values take the
+// defaults.
+type TestSubscriberOptions struct {
+	// ResolveDNS checks the subscriber's host resolves before pushing.
+	ResolveDNS bool
+	// Timeout is how long to wait for the delivery, a minute by default.
+	Timeout time.Duration
+}
+
+// A SubscriberTest is the outcome of a test delivery to a subscriber.
+type SubscriberTest struct {
+	// MessageId is the id of the test message.
+	MessageId string
+	// Status is the status of the delivery, e.g. "complete", or "retrying"
+	// if the subscriber failed.
+	Status string
+	// StatusCode is the status the subscriber responded with, 0 if it
+	// couldn't be reached.
+	StatusCode int
+	// Delivered is set if the subscriber accepted the message.
+	Delivered bool
+}
+
+// TestSubscriber checks sub, as TestSubscriberWith does with the default
+// options.
+func (q Queue) TestSubscriber(sub QueueSubscriber) (SubscriberTest, error) {
+	return q.TestSubscriberWith(TestSubscriberOptions{}, sub)
+}
+
+// TestSubscriberWith checks sub before it is added to q: it validates its
+// URL and pushes a test message to it, reporting how the first delivery
+// went. The message goes through a temporary unicast queue, named after q,
+// with sub as its only subscriber, so q's subscribers don't get it. It
+// carries SubscriberTestHeader, and is JSON with the name of q as "queue".
+func (q Queue) TestSubscriberWith(opts TestSubscriberOptions, sub QueueSubscriber) (SubscriberTest, error) {
+	var res SubscriberTest
+	if err := checkSubscriberURL(sub.URL, opts.ResolveDNS); err != nil {
+		return res, fmt.Errorf("mq: subscriber %s: %w", sub.Name, err)
+	}
+	if sub.Name == "" {
+		sub.Name = "test"
+	}
+	headers := map[string]string{SubscriberTestHeader: "true"}
+	for k, v := range sub.Headers {
+		headers[k] = v
+	}
+	sub.Headers = headers
+
+	var suffix [4]byte
+	if _, err := rand.Read(suffix[:]); err != nil {
+		return res, err
+	}
+	tq := q
+	tq.Name = q.Name + "-subscriber-test-" + hex.EncodeToString(suffix[:])
+	_, err := tq.Create(QueueInfo{Type: "unicast", Push: &PushInfo{Subscribers: []QueueSubscriber{sub}}})
+	if err != nil {
+		return res, fmt.Errorf("mq: creating test queue: %w", err)
+	}
+	defer tq.Delete()
+
+	body, _ := json.Marshal(map[string]interface{}{"queue": q.Name, "test": true})
+	if res.MessageId, err = tq.PushString(string(body)); err != nil {
+		return res, fmt.Errorf("mq: pushing test message: %w", err)
+	}
+
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = time.Minute
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	tick := time.NewTicker(100 * time.Millisecond)
+	defer tick.Stop()
+	for {
+		subs, err := tq.MessageSubscribers(res.MessageId)
+		if err != nil {
+			return res, fmt.Errorf("mq: checking test delivery: %w", err)
+		}
+		if len(subs) > 0 {
+			res.Status, res.StatusCode = subs[0].Status, subs[0].StatusCode
+			if res.Status != "queued" {
+				res.Delivered = res.StatusCode >= 200 && res.StatusCode < 300
+				return res, nil
+			}
+		}
+		select {
+		case <-tick.C:
+		case <-ctx.Done():
+			return res, fmt.Errorf("mq: test message to %s not delivered within %v", sub.URL, timeout)
+		}
+	}
+}
+
+// checkSubscriberURL validates the URL of a subscriber, an HTTP endpoint
+// or another queue, and resolves its host if resolve is set.
+func checkSubscriberURL(raw string, resolve bool) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	switch u.Scheme {
+	case "http", "https":
+	case "ironmq":
+		return nil
+	default:
+		return fmt.Errorf("unsupported URL scheme %q, expected http, https or ironmq", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return errors.New("URL has no host")
+	}
+	if resolve && net.ParseIP(u.Hostname()) == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if _, err := net.DefaultResolver.LookupHost(ctx, u.Hostname()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package mq
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/iron-io/iron_go3/irontest"
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestTestSubscriber(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("subscriber tests", func() {
+		srv := irontest.NewServer()
+		defer srv.Close()
+		q := Queue{Settings: srv.Settings("iron_mq"), Name: "orders"}
+
+		var marked atomic.Bool
+		ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			marked.Store(r.Header.Get(SubscriberTestHeader) == "true" && r.Header.Get("X-Token") == "secret")
+		}))
+		defer ok.Close()
+		broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer broken.Close()
+
+		It("reports delivered test messages", func() {
+			res, err := q.TestSubscriber(QueueSubscriber{Name: "ok", URL: ok.URL, Headers: map[string]string{"X-Token": "secret"}})
+			Expect(err, ToBeNil)
+			Expect(res.Delivered, ToBeTrue)
+			Expect(res.Status, ToEqual, "complete")
+			Expect(res.StatusCode, ToEqual, http.StatusOK)
+			Expect(marked.Load(), ToBeTrue)
+
+			queues, err := q.ListQueues("orders", "", 100)
+			Expect(err, ToBeNil)
+			Expect(len(queues), ToEqual, 0)
+		})
+
+		It("reports failed deliveries", func() {
+			res, err := q.TestSubscriber(QueueSubscriber{Name: "broken", URL: broken.URL})
+			Expect(err, ToBeNil)
+			Expect(res.Delivered, ToEqual, false)
+			Expect(res.Status, ToEqual, "retrying")
+			Expect(res.StatusCode, ToEqual, http.StatusInternalServerError)
+		})
+
+		It("refuses invalid URLs", func() {
+			_, err := q.TestSubscriber(QueueSubscriber{Name: "ftp", URL: "ftp://example.com/orders"})
+			Expect(err, ToNotBeNil)
+			_, err = q.TestSubscriber(QueueSubscriber{Name: "nohost", URL: "http:///orders"})
+			Expect(err, ToNotBeNil)
+		})
+
+		It("resolves hosts on request", func() {
+			_, err := q.TestSubscriberWith(TestSubscriberOptions{ResolveDNS: true}, QueueSubscriber{URL: "http://subscriber.invalid/orders"})
+			Expect(err, ToNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,208 @@
+package mq
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// A Transform changes envelopes on their way through a queue, so payload
+// policies, e.g. redacting, compressing or encrypting, live in one place
+// instead of in every producer and consumer. A Producer applies the Encode
+// of its Transforms in order before pushing, after validating the payload,
+// and a Consumer the Decode of its Transforms in reverse order to the
+// enveloped deliveries it reserves, before filtering and validating them.
+//
+//	key := ... // 32 bytes
+//	enc, err := mq.AESGCM(key)
+//	transforms := []mq.Transform{mq.RedactJSON("card.number"), mq.Gzip, enc}
+//	p.Transforms, c.Transforms = transforms, transforms
+type Transform interface {
+	Encode(e *Envelope) error
+	Decode(e *Envelope) error
+}
+
+// EncodeFunc adapts a function to a Transform that only changes envelopes
+// when they are pushed, e.g. to add metadata.
+type EncodeFunc func(e *Envelope) error
+
+func (f EncodeFunc) Encode(e *Envelope) error { return f(e) }
+func (f EncodeFunc) Decode(e *Envelope) error { return nil }
+
+// encode applies the Encode of transforms to e, in order.
+func encode(transforms []Transform, e *Envelope) error {
+	for _, t := range transforms {
+		if err := t.Encode(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decode applies the Decode of transforms to e, in reverse order.
+func decode(transforms []Transform, e *Envelope) error {
+	for i := len(transforms) - 1; i >= 0; i-- {
+		if err := transforms[i].Decode(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// OpenWith is Open, then decodes the envelope with transforms like a
+// Consumer does. Bodies that aren't envelopes are returned as Open does.
+func (m Message) OpenWith(transforms ...Transform) (Envelope, bool, error) {
+	e, ok := m.Open()
+	if !ok {
+		return e, false, nil
+	}
+	if err := decode(transforms, &e); err != nil {
+		return e, true, fmt.Errorf("mq: decoding %s: %w", m.Id, err)
+	}
+	return e, true, nil
+}
+
+// RedactJSON returns a Transform replacing fields of JSON object payloads
+// with "REDACTED" before they are pushed. Fields are named by their path,
+// e.g. "card.number", and missing ones are ignored. Payloads that aren't
+// JSON objects are refused, so they can't slip through unredacted.
+func RedactJSON(fields ...string) Transform {
+	return EncodeFunc(func(e *Envelope) error {
+		var v map[string]interface{}
+		if err := json.Unmarshal(e.Payload, &v); err != nil {
+			return fmt.Errorf("redacting payload: %w", err)
+		}
+		for _, f := range fields {
+			redact(v, strings.Split(f, "."))
+		}
+		payload, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("redacting payload: %w", err)
+		}
+		e.Payload = payload
+		return nil
+	})
+}
+
+func redact(v map[string]interface{}, path []string) {
+	field, ok := v[path[0]]
+	switch {
+	case !ok:
+	case len(path) == 1:
+		v[path[0]] = "REDACTED"
+	default:
+		if inner, ok := field.(map[string]interface{}); ok {
+			redact(inner, path[1:])
+		}
+	}
+}
+
+// Gzip compresses payloads, adding "gzip" to the ContentEncoding of their
+// envelopes.
+var Gzip Transform = gzipTransform{}
+
+type gzipTransform struct{}
+
+func (gzipTransform) Encode(e *Envelope) error {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(e.Payload); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	e.Payload = buf.Bytes()
+	pushEncoding(e, "gzip")
+	return nil
+}
+
+func (gzipTransform) Decode(e *Envelope) error {
+	if !popEncoding(e, "gzip") {
+		return nil
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(e.Payload))
+	if err != nil {
+		return fmt.Errorf("decompressing payload: %w", err)
+	}
+	payload, err := io.ReadAll(zr)
+	if err != nil {
+		return fmt.Errorf("decompressing payload: %w", err)
+	}
+	e.Payload = payload
+	return nil
+}
+
+// AESGCM returns a Transform encrypting payloads with AES-GCM under key,
+// of 16, 24 or 32 bytes, adding "aes-gcm" to the ContentEncoding of their
+// envelopes. The headers of envelopes aren't encrypted.
+func AESGCM(key []byte) (Transform, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("mq: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("mq: %w", err)
+	}
+	return aesGCM{aead}, nil
+}
+
+type aesGCM struct{ aead cipher.AEAD }
+
+func (t aesGCM) Encode(e *Envelope) error {
+	nonce := make([]byte, t.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	e.Payload = t.aead.Seal(nonce, nonce, e.Payload, nil)
+	pushEncoding(e, "aes-gcm")
+	return nil
+}
+
+func (t aesGCM) Decode(e *Envelope) error {
+	if !popEncoding(e, "aes-gcm") {
+		return nil
+	}
+	n := t.aead.NonceSize()
+	if len(e.Payload) < n {
+		return errors.New("decrypting payload: too short")
+	}
+	payload, err := t.aead.Open(nil, e.Payload[:n], e.Payload[n:], nil)
+	if err != nil {
+		return fmt.Errorf("decrypting payload: %w", err)
+	}
+	e.Payload = payload
+	return nil
+}
+
+// pushEncoding records that encoding was applied last to e's payload.
+func pushEncoding(e *Envelope, encoding string) {
+	if e.ContentEncoding == "" {
+		e.ContentEncoding = encoding
+	} else {
+		e.ContentEncoding += ", " + encoding
+	}
+}
+
+// popEncoding removes encoding from e's ContentEncoding if it was applied
+// last, reporting whether it was. Envelopes pushed without it are left
+// alone, e.g. those pushed before a producer started encrypting.
+func popEncoding(e *Envelope, encoding string) bool {
+	rest, last := "", e.ContentEncoding
+	if i := strings.LastIndex(e.ContentEncoding, ","); i >= 0 {
+		rest, last = e.ContentEncoding[:i], e.ContentEncoding[i+1:]
+	}
+	if strings.TrimSpace(last) != encoding {
+		return false
+	}
+	e.ContentEncoding = strings.TrimSpace(rest)
+	return true
+}
@@ -0,0 +1,89 @@
+package mq
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/iron-io/iron_go3/irontest"
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestTransforms(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("transforms", func() {
+		srv := irontest.NewServer()
+		defer srv.Close()
+		q := Queue{Settings: srv.Settings("iron_mq"), Name: "payments"}
+		invalid := Queue{Settings: q.Settings, Name: "payments-invalid"}
+		enc, err := AESGCM([]byte("0123456789abcdef0123456789abcdef"))
+		Expect(err, ToBeNil)
+		transforms := []Transform{
+			RedactJSON("card.number", "missing"),
+			EncodeFunc(func(e *Envelope) error {
+				e.Headers = map[string]string{"policy": "v1"}
+				return nil
+			}),
+			Gzip,
+			enc,
+		}
+		p := NewProducer(q, "p1")
+		p.Transforms = transforms
+
+		consume := func(transforms []Transform) (got []Envelope, errs []error) {
+			c := NewConsumer(q, HandlerFunc(func(ctx context.Context, d *Delivery) error {
+				got = append(got, d.Envelope)
+				return nil
+			}))
+			c.Transforms = transforms
+			c.Invalid = &invalid
+			c.OnError = func(err error) { errs = append(errs, err) }
+			c.RunOnce(context.Background(), 10)
+			return got, errs
+		}
+
+		It("encodes in order and decodes in reverse", func() {
+			_, err := p.Push([]byte(`{"card": {"number": "4111", "exp": "12/30"}, "amount": 12}`), nil)
+			Expect(err, ToBeNil)
+			body := srv.MQ.Messages("payments")[0]
+			Expect(strings.Contains(body, "REDACTED"), ToEqual, false)
+			e, _ := OpenEnvelope(body)
+			Expect(e.ContentEncoding, ToEqual, "gzip, aes-gcm")
+			Expect(e.Headers, ToDeepEqual, map[string]string{"policy": "v1"})
+
+			got, errs := consume(transforms)
+			Expect(len(errs), ToEqual, 0)
+			Expect(string(got[0].Payload), ToEqual, `{"amount":12,"card":{"exp":"12/30","number":"REDACTED"}}`)
+			Expect(got[0].ContentEncoding, ToEqual, "")
+		})
+
+		It("refuses payloads a transform can't encode", func() {
+			_, err := p.Push([]byte("not json"), nil)
+			Expect(strings.HasPrefix(err.Error(), "mq: encoding for payments: redacting payload"), ToBeTrue)
+			Expect(len(srv.MQ.Messages("payments")), ToEqual, 0)
+		})
+
+		It("leaves envelopes without the encodings alone", func() {
+			NewProducer(q, "p2").Push([]byte("plain"), nil)
+			got, _ := consume(transforms)
+			Expect(string(got[0].Payload), ToEqual, "plain")
+		})
+
+		It("settles deliveries that fail to decode as invalid", func() {
+			p.Push([]byte(`{}`), nil)
+			other, _ := AESGCM([]byte("fedcba9876543210fedcba9876543210"))
+			got, errs := consume([]Transform{Gzip, other})
+			Expect(len(got), ToEqual, 0)
+			Expect(len(errs), ToEqual, 1)
+			Expect(strings.Contains(errs[0].Error(), "decrypting payload"), ToBeTrue)
+			Expect(len(srv.MQ.Messages("payments-invalid")), ToEqual, 1)
+
+			msgs, _ := invalid.ReserveWith(ReserveOptions{})
+			e, ok, err := msgs[0].OpenWith(transforms...)
+			Expect(ok, ToBeTrue)
+			Expect(err, ToBeNil)
+			Expect(string(e.Payload), ToEqual, `{}`)
+		})
+	})
+}
@@ -0,0 +1,56 @@
+package mq
+
+import "fmt"
+
+// A Validator checks payloads before they are pushed by a Producer or
+// handled by a Consumer. The jsonschema package implements it for JSON
+// Schema.
+type Validator interface {
+	Validate(payload []byte) error
+}
+
+// ValidatorFunc adapts a function to a Validator.
+type ValidatorFunc func(payload []byte) error
+
+func (f ValidatorFunc) Validate(payload []byte) error { return f(payload) }
+
+// A ValidationError is returned for a payload a Validator refused.
+type ValidationError struct {
+	Queue string
+	// MessageId is the id of the refused message, empty when pushing.
+	MessageId string
+	// Err is the validator's error, e.g. jsonschema.Errors.
+	Err error
+}
+
+func (e *ValidationError) Error() string {
+	if e.MessageId == "" {
+		return fmt.Sprintf("mq: invalid payload for %s: %v", e.Queue, e.Err)
+	}
+	return fmt.Sprintf("mq: invalid payload of %s on %s: %v", e.MessageId, e.Queue, e.Err)
+}
+
+func (e *ValidationError) Unwrap() error { return e.Err }
+
+// validate runs v on payload, if there is a validator.
+func validate(v Validator, q Queue, id string, payload []byte) error {
+	if v == nil {
+		return nil
+	}
+	if err := v.Validate(payload); err != nil {
+		return &ValidationError{Queue: q.Name, MessageId: id, Err: err}
+	}
+	return nil
+}
+
+// invalid settles a delivery the consumer's Validator refused.
+func (c *Consumer) invalid(d *Delivery) error {
+	q := c.Invalid
+	if q == nil {
+		q = c.DeadLetter
+	}
+	if q == nil {
+		return d.settle(func() error { return d.delete() })
+	}
+	return d.settle(func() error { return d.move(*q, PushOptions{}) })
+}
@@ -0,0 +1,73 @@
+package mq
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/iron-io/iron_go3/irontest"
+	"github.com/iron-io/iron_go3/mq/jsonschema"
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestValidate(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("validating payloads", func() {
+		srv := irontest.NewServer()
+		defer srv.Close()
+		q := Queue{Settings: srv.Settings("iron_mq"), Name: "orders"}
+		schema := jsonschema.MustCompile(`{
+			"type": "object",
+			"required": ["id"],
+			"properties": {"id": {"type": "integer"}}
+		}`)
+
+		It("refuses to push invalid payloads", func() {
+			p := NewProducer(q, "p")
+			p.Validator = schema
+			_, err := p.Push([]byte(`{"id": "x"}`), nil)
+			var verr *ValidationError
+			Expect(errors.As(err, &verr), ToBeTrue)
+			Expect(verr.Queue, ToEqual, "orders")
+			var errs jsonschema.Errors
+			Expect(errors.As(err, &errs), ToBeTrue)
+			Expect(errs[0].Path, ToEqual, "/id")
+			Expect(len(srv.MQ.Messages("orders")), ToEqual, 0)
+
+			_, err = p.Push([]byte(`{"id": 1}`), nil)
+			Expect(err, ToBeNil)
+			q.Clear()
+		})
+
+		It("moves invalid deliveries to the invalid queue", func() {
+			var handled []string
+			var reported []error
+			c := NewConsumer(q, HandlerFunc(func(ctx context.Context, d *Delivery) error {
+				handled = append(handled, string(d.Envelope.Payload))
+				return nil
+			}))
+			c.Validator = schema
+			c.DeadLetter = &Queue{Settings: q.Settings, Name: "orders-dead"}
+			c.Invalid = &Queue{Settings: q.Settings, Name: "orders-invalid"}
+			c.OnError = func(err error) { reported = append(reported, err) }
+
+			q.PushStrings(`{"id": 1}`, `{"name": "x"}`, `not json`)
+			c.RunOnce(context.Background(), 10)
+			Expect(handled, ToDeepEqual, []string{`{"id": 1}`})
+			Expect(len(reported), ToEqual, 2)
+			Expect(len(srv.MQ.Messages("orders")), ToEqual, 0)
+			Expect(len(srv.MQ.Messages("orders-invalid")), ToEqual, 2)
+			Expect(len(srv.MQ.Messages("orders-dead")), ToEqual, 0)
+		})
+
+		It("moves invalid deliveries to the dead letter queue by default", func() {
+			c := NewConsumer(q, HandlerFunc(func(ctx context.Context, d *Delivery) error { return nil }))
+			c.Validator = ValidatorFunc(func([]byte) error { return errors.New("nope") })
+			c.DeadLetter = &Queue{Settings: q.Settings, Name: "orders-dead"}
+			q.PushString("x")
+			c.RunOnce(context.Background(), 10)
+			Expect(srv.MQ.Messages("orders-dead"), ToDeepEqual, []string{"x"})
+		})
+	})
+}
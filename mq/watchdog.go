@@ -0,0 +1,212 @@
+package mq
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultMessageTimeout is the message_timeout of queues that don't set
+// one.
+const DefaultMessageTimeout = 60 * time.Second
+
+// A Watchdog watches the deliveries a Consumer is handling, so reservations
+// don't time out unnoticed and get delivered again while the first handler
+// is still at work.
+//
+// When handling a delivery takes longer than Soft, the watchdog warns
+// through OnSlow and Logger. Past Hard, it releases the message and cancels
+// the context of its handler. Touching the delivery restarts both
+// deadlines.
+//
+//	c := mq.NewConsumer(q, h)
+//	c.Watchdog = &mq.Watchdog{Hard: 50 * time.Second, Logger: slog.Default()}
+type Watchdog struct {
+	// Soft is how long handling a delivery may take before it is reported,
+	// by default three quarters of the consumer's reservation timeout.
+	Soft time.Duration
+	// Hard is how long handling may take before the message is released,
+	// no limit if 0. It should be shorter than the reservation timeout.
+	Hard time.Duration
+	// OnSlow is called for deliveries past Soft.
+	OnSlow func(Reservation)
+	// OnRelease is called for deliveries past Hard, with the error
+	// releasing the message failed with, if any.
+	OnRelease func(Reservation, error)
+	// Logger, if set, gets a warning for deliveries past Soft and an error
+	// for those past Hard.
+	Logger *slog.Logger
+
+	mu   sync.Mutex
+	held map[*Delivery]*hold
+
+	slow, released int64
+}
+
+// A Reservation is a message held by a handler.
+type Reservation struct {
+	Queue         string `json:"queue"`
+	MessageId     string `json:"message_id"`
+	ReservationId string `json:"reservation_id"`
+	// Since is when handling started or the delivery was last touched.
+	Since time.Time `json:"since"`
+}
+
+type hold struct {
+	r          Reservation
+	timeout    time.Duration
+	soft, hard *time.Timer
+	cancel     context.CancelFunc
+}
+
+// WatchdogStats count what a Watchdog saw.
+type WatchdogStats struct {
+	// Held is the number of deliveries being handled.
+	Held int
+	// Slow and Released count the deliveries that went past Soft and Hard.
+	Slow, Released int64
+}
+
+// Stats returns the watchdog's counts, e.g. to export as metrics.
+func (w *Watchdog) Stats() WatchdogStats {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return WatchdogStats{Held: len(w.held), Slow: atomic.LoadInt64(&w.slow), Released: atomic.LoadInt64(&w.released)}
+}
+
+// Held returns the reservations being handled.
+func (w *Watchdog) Held() []Reservation {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	rs := make([]Reservation, 0, len(w.held))
+	for _, h := range w.held {
+		rs = append(rs, h.r)
+	}
+	return rs
+}
+
+// track starts watching d, reserved for timeout, until the returned done is
+// called. The returned context is canceled when d is released for being
+// past Hard. A nil watchdog watches nothing.
+func (w *Watchdog) track(ctx context.Context, d *Delivery, timeout time.Duration) (context.Context, func()) {
+	if w == nil {
+		return ctx, func() {}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	h := &hold{timeout: timeout, cancel: cancel}
+
+	w.mu.Lock()
+	if w.held == nil {
+		w.held = map[*Delivery]*hold{}
+	}
+	w.held[d] = h
+	w.start(d, h)
+	w.mu.Unlock()
+
+	return ctx, func() {
+		w.mu.Lock()
+		if w.held[d] == h {
+			delete(w.held, d)
+			h.stop()
+		}
+		w.mu.Unlock()
+		cancel()
+	}
+}
+
+// touched restarts the deadlines of d.
+func (w *Watchdog) touched(d *Delivery) {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if h, ok := w.held[d]; ok {
+		h.stop()
+		w.start(d, h)
+	}
+}
+
+// start arms h's timers, w.mu must be held.
+func (w *Watchdog) start(d *Delivery, h *hold) {
+	h.r = Reservation{Queue: d.q.Name, MessageId: d.Id, ReservationId: d.ReservationId, Since: time.Now()}
+	r := h.r
+	h.soft = time.AfterFunc(w.soft(h.timeout), func() { w.warn(r) })
+	if w.Hard > 0 {
+		h.hard = time.AfterFunc(w.Hard, func() { w.release(d, h, r) })
+	}
+}
+
+func (h *hold) stop() {
+	h.soft.Stop()
+	if h.hard != nil {
+		h.hard.Stop()
+	}
+}
+
+func (w *Watchdog) warn(r Reservation) {
+	atomic.AddInt64(&w.slow, 1)
+	if w.Logger != nil {
+		w.Logger.Warn("mq: slow delivery", "queue", r.Queue, "message_id", r.MessageId, "held", time.Since(r.Since).Round(time.Millisecond))
+	}
+	if w.OnSlow != nil {
+		w.OnSlow(r)
+	}
+}
+
+func (w *Watchdog) release(d *Delivery, h *hold, r Reservation) {
+	w.mu.Lock()
+	current := w.held[d] == h && h.r == r
+	w.mu.Unlock()
+	if !current {
+		return // done or touched meanwhile
+	}
+
+	err := d.Nack(0)
+	if err == ErrSettled {
+		return // the handler settled it meanwhile
+	}
+	atomic.AddInt64(&w.released, 1)
+	h.cancel()
+	if w.Logger != nil {
+		w.Logger.Error("mq: released stuck delivery", "queue", r.Queue, "message_id", r.MessageId, "held", time.Since(r.Since).Round(time.Millisecond), "err", err)
+	}
+	if w.OnRelease != nil {
+		w.OnRelease(r, err)
+	}
+}
+
+func (w *Watchdog) soft(timeout time.Duration) time.Duration {
+	if w.Soft > 0 {
+		return w.Soft
+	}
+	return timeout * 3 / 4
+}
+
+// Touch extends the message's reservation by the queue's message_timeout,
+// restarting the deadlines of the consumer's Watchdog.
+func (d *Delivery) Touch() error {
+	return d.TouchFor(0)
+}
+
+// TouchFor extends the message's reservation by timeout seconds,
+// restarting the deadlines of the consumer's Watchdog.
+func (d *Delivery) TouchFor(timeout int) error {
+	if err := d.Message.TouchFor(timeout); err != nil {
+		return err
+	}
+	for _, p := range d.parts {
+		if err := p.Message.TouchFor(timeout); err != nil {
+			return err
+		}
+	}
+	if d.consumer != nil {
+		d.consumer.Watchdog.touched(d)
+		if d.held {
+			d.consumer.hold(d) // the reservation id changed
+		}
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package mq
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iron-io/iron_go3/irontest"
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestWatchdog(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("watchdogs", func() {
+		srv := irontest.NewServer()
+		defer srv.Close()
+		q := Queue{Settings: srv.Settings("iron_mq"), Name: "slow"}
+
+		var mu sync.Mutex
+		var slow, released []Reservation
+		w := &Watchdog{
+			Soft:      50 * time.Millisecond,
+			Hard:      150 * time.Millisecond,
+			OnSlow:    func(r Reservation) { mu.Lock(); slow = append(slow, r); mu.Unlock() },
+			OnRelease: func(r Reservation, err error) { mu.Lock(); released = append(released, r); mu.Unlock() },
+		}
+
+		run := func(h HandlerFunc) {
+			c := NewConsumer(q, h)
+			c.Watchdog = w
+			c.RunOnce(context.Background(), 1)
+		}
+
+		It("leaves fast deliveries alone", func() {
+			q.PushString("fast")
+			run(func(ctx context.Context, d *Delivery) error { return nil })
+			Expect(w.Stats(), ToEqual, WatchdogStats{})
+		})
+
+		It("reports slow deliveries", func() {
+			id, _ := q.PushString("slow")
+			run(func(ctx context.Context, d *Delivery) error {
+				time.Sleep(100 * time.Millisecond)
+				Expect(len(w.Held()), ToEqual, 1)
+				return nil
+			})
+			Expect(w.Stats(), ToEqual, WatchdogStats{Slow: 1})
+			mu.Lock()
+			Expect(slow[0].MessageId, ToEqual, id)
+			mu.Unlock()
+			Expect(len(srv.MQ.Messages("slow")), ToEqual, 0)
+		})
+
+		It("releases stuck deliveries", func() {
+			q.PushString("stuck")
+			var canceled bool
+			run(func(ctx context.Context, d *Delivery) error {
+				select {
+				case <-ctx.Done():
+					canceled = true
+				case <-time.After(time.Second):
+				}
+				return d.Ack()
+			})
+			Expect(canceled, ToBeTrue)
+			Expect(w.Stats(), ToEqual, WatchdogStats{Slow: 2, Released: 1})
+			mu.Lock()
+			Expect(len(released), ToEqual, 1)
+			mu.Unlock()
+			msgs, _ := q.ReserveWith(ReserveOptions{})
+			Expect(len(msgs), ToEqual, 1)
+			msgs[0].Delete()
+		})
+
+		It("restarts the deadlines when touched", func() {
+			q.PushString("touched")
+			run(func(ctx context.Context, d *Delivery) error {
+				for i := 0; i < 12; i++ {
+					time.Sleep(15 * time.Millisecond)
+					d.Touch()
+				}
+				return ctx.Err()
+			})
+			Expect(w.Stats(), ToEqual, WatchdogStats{Slow: 2, Released: 1})
+			Expect(len(srv.MQ.Messages("slow")), ToEqual, 0)
+		})
+	})
+}
@@ -0,0 +1,177 @@
+// Package statsd exports the metrics of api clients, mq consumers and
+// workers to a StatsD server, or a Datadog agent with DogStatsD tags, for
+// environments without a scrape endpoint.
+//
+//	e, err := statsd.Dial("127.0.0.1:8125")
+//	e.Tags = []string{"env:prod"}
+//	c := iron.New(nil, iron.WithMetrics(e))
+//	consumer.Metrics = e
+//	w.Metrics = e
+//
+// Requests are counted as iron.requests and timed as iron.request.duration,
+// tagged with method, host, queue if any, and status, the status code or
+// "error". Retries add to iron.request.retries. Handled deliveries are
+// iron.mq.deliveries and iron.mq.delivery.duration, tagged with queue and
+// outcome, and the ages of their messages, in seconds, go to the histogram
+// iron.mq.message.age, tagged with queue. Finished tasks are
+// iron.worker.tasks and iron.worker.task.duration, tagged with code_name
+// and status.
+package statsd
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iron-io/iron_go3/api"
+	"github.com/iron-io/iron_go3/mq"
+	"github.com/iron-io/iron_go3/worker"
+)
+
+// An Exporter sends metrics to a StatsD server, one datagram per metric.
+// It implements api.Metrics, mq.Metrics and worker.Metrics. It is safe for
+// concurrent use.
+type Exporter struct {
+	// Prefix is prepended to the metric names.
+	Prefix string
+	// Tags are added to every metric, as "key:value".
+	Tags []string
+	// Plain leaves the tags out, for servers that don't understand
+	// DogStatsD tags.
+	Plain bool
+	// OnError, if set, is told about failed writes, which are otherwise
+	// dropped like UDP drops datagrams.
+	OnError func(error)
+
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// Dial returns an Exporter sending to the StatsD server at addr over UDP.
+func Dial(addr string) (*Exporter, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("statsd: %w", err)
+	}
+	return New(conn), nil
+}
+
+// New returns an Exporter writing each metric to w, e.g. a unix socket.
+func New(w io.Writer) *Exporter {
+	return &Exporter{w: w}
+}
+
+// Close closes the connection, if it can be closed.
+func (e *Exporter) Close() error {
+	if c, ok := e.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// Count adds n to the counter name.
+func (e *Exporter) Count(name string, n int64, tags ...string) {
+	e.send(name, strconv.FormatInt(n, 10), "c", tags)
+}
+
+// Gauge sets the gauge name to v.
+func (e *Exporter) Gauge(name string, v float64, tags ...string) {
+	e.send(name, strconv.FormatFloat(v, 'f', -1, 64), "g", tags)
+}
+
+// Histogram records v in the histogram name.
+func (e *Exporter) Histogram(name string, v float64, tags ...string) {
+	e.send(name, strconv.FormatFloat(v, 'f', -1, 64), "h", tags)
+}
+
+// Timing records d in the timer name, in milliseconds.
+func (e *Exporter) Timing(name string, d time.Duration, tags ...string) {
+	e.send(name, strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', -1, 64), "ms", tags)
+}
+
+// ObserveRequest implements api.Metrics.
+func (e *Exporter) ObserveRequest(s api.RequestStats) {
+	tags := []string{"method:" + s.Method, "host:" + s.Host}
+	if q := queueOf(s.Path); q != "" {
+		tags = append(tags, "queue:"+q)
+	}
+	status := "error"
+	if s.StatusCode != 0 {
+		status = strconv.Itoa(s.StatusCode)
+	}
+	tags = append(tags, "status:"+status)
+	e.Count("iron.requests", 1, tags...)
+	e.Timing("iron.request.duration", s.Duration, tags...)
+	if s.Tries > 1 {
+		e.Count("iron.request.retries", int64(s.Tries-1), tags...)
+	}
+}
+
+// ObserveDelivery implements mq.Metrics.
+func (e *Exporter) ObserveDelivery(s mq.DeliveryStats) {
+	outcome := s.Outcome
+	if outcome == "" {
+		outcome = "unsettled"
+	}
+	tags := []string{"queue:" + s.Queue, "outcome:" + outcome}
+	e.Count("iron.mq.deliveries", 1, tags...)
+	e.Timing("iron.mq.delivery.duration", s.Duration, tags...)
+	if s.Age > 0 {
+		e.Histogram("iron.mq.message.age", s.Age.Seconds(), "queue:"+s.Queue)
+	}
+}
+
+// ObserveTask implements worker.Metrics.
+func (e *Exporter) ObserveTask(info worker.TaskInfo) {
+	tags := []string{"code_name:" + info.CodeName, "status:" + string(info.Status)}
+	e.Count("iron.worker.tasks", 1, tags...)
+	e.Timing("iron.worker.task.duration", time.Duration(info.Duration)*time.Millisecond, tags...)
+}
+
+func (e *Exporter) send(name, value, kind string, tags []string) {
+	var b strings.Builder
+	b.WriteString(sanitize(e.Prefix+name, ":|,#@\n"))
+	b.WriteString(":" + value + "|" + kind)
+	if !e.Plain && len(e.Tags)+len(tags) > 0 {
+		b.WriteString("|#")
+		for i, t := range append(e.Tags[:len(e.Tags):len(e.Tags)], tags...) {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(sanitize(t, "|,#@\n"))
+		}
+	}
+
+	e.mu.Lock()
+	_, err := io.WriteString(e.w, b.String())
+	e.mu.Unlock()
+	if err != nil && e.OnError != nil {
+		e.OnError(fmt.Errorf("statsd: %w", err))
+	}
+}
+
+// sanitize replaces the reserved characters of s with underscores.
+func sanitize(s, reserved string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(reserved, r) {
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+// queueOf returns the queue name in the path of an IronMQ request, e.g.
+// /3/projects/p/queues/orders/messages, or "".
+func queueOf(path string) string {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	for i := 0; i < len(parts)-1; i++ {
+		if parts[i] == "queues" {
+			return parts[i+1]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,154 @@
+package statsd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iron-io/iron_go3/api"
+	"github.com/iron-io/iron_go3/irontest"
+	"github.com/iron-io/iron_go3/mq"
+	"github.com/iron-io/iron_go3/worker"
+	. "github.com/jeffh/go.bdd"
+)
+
+// datagrams records what an Exporter writes, one metric per write.
+type datagrams struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (d *datagrams) Write(p []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.lines = append(d.lines, string(p))
+	return len(p), nil
+}
+
+// named returns the metrics called name.
+func (d *datagrams) named(name string) []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var found []string
+	for _, l := range d.lines {
+		if strings.HasPrefix(l, name+":") {
+			found = append(found, l)
+		}
+	}
+	return found
+}
+
+func TestExporter(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("formatting", func() {
+		It("writes DogStatsD tags", func() {
+			var d datagrams
+			e := New(&d)
+			e.Prefix = "app."
+			e.Tags = []string{"env:prod"}
+			e.Count("jobs", 3, "queue:a|b")
+			e.Gauge("depth", 1.5)
+			e.Timing("took", 1500*time.Microsecond, "x:y")
+			Expect(d.lines, ToDeepEqual, []string{
+				"app.jobs:3|c|#env:prod,queue:a_b",
+				"app.depth:1.5|g|#env:prod",
+				"app.took:1.5|ms|#env:prod,x:y",
+			})
+		})
+
+		It("leaves tags out for plain StatsD", func() {
+			var d datagrams
+			e := New(&d)
+			e.Plain = true
+			e.Count("a:b", 1, "k:v")
+			Expect(d.lines, ToDeepEqual, []string{"a_b:1|c"})
+		})
+
+		It("sends datagrams", func() {
+			conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+			Expect(err, ToBeNil)
+			defer conn.Close()
+			e, err := Dial(conn.LocalAddr().String())
+			Expect(err, ToBeNil)
+			defer e.Close()
+			e.Count("hits", 1)
+			buf := make([]byte, 512)
+			conn.SetReadDeadline(time.Now().Add(time.Second))
+			n, _, err := conn.ReadFrom(buf)
+			Expect(err, ToBeNil)
+			Expect(string(buf[:n]), ToEqual, "hits:1|c")
+		})
+
+		It("reports failed writes", func() {
+			var failed error
+			e := New(failingWriter{})
+			e.OnError = func(err error) { failed = err }
+			e.Count("hits", 1)
+			Expect(failed.Error(), ToEqual, "statsd: refused")
+		})
+	})
+
+	Describe("observing", func() {
+		srv := irontest.NewServer()
+		defer srv.Close()
+		var d datagrams
+		e := New(&d)
+		client := &api.Client{Metrics: e}
+		q := mq.Queue{Settings: srv.Settings("iron_mq"), Name: "orders", Client: client}
+
+		It("tags requests with their queue and status", func() {
+			q.PushString("hello")
+			Expect(d.named("iron.requests"), ToDeepEqual, []string{
+				fmt.Sprintf("iron.requests:1|c|#method:POST,host:%s:%d,queue:orders,status:200", q.Settings.Host, q.Settings.Port),
+			})
+			Expect(len(d.named("iron.request.duration")), ToEqual, 1)
+		})
+
+		It("tags deliveries with their outcome", func() {
+			q.PushString("fails")
+			c := mq.NewConsumer(q, mq.HandlerFunc(func(ctx context.Context, d *mq.Delivery) error {
+				if string(d.Body) == "fails" {
+					return errors.New("no")
+				}
+				return nil
+			}))
+			c.Metrics = e
+			c.RunOnce(context.Background(), 10)
+			deliveries := d.named("iron.mq.deliveries")
+			Expect(len(deliveries), ToEqual, 2)
+			Expect(strings.Join(deliveries, " "), ToEqual, strings.Join([]string{
+				"iron.mq.deliveries:1|c|#queue:orders,outcome:acked",
+				"iron.mq.deliveries:1|c|#queue:orders,outcome:nacked",
+			}, " "))
+		})
+
+		It("records the age of enveloped messages", func() {
+			q.Clear()
+			p := mq.NewProducer(q, "p1")
+			p.Push([]byte("hi"), nil)
+			c := mq.NewConsumer(q, mq.HandlerFunc(func(ctx context.Context, d *mq.Delivery) error { return nil }))
+			c.Metrics = e
+			c.RunOnce(context.Background(), 10)
+			ages := d.named("iron.mq.message.age")
+			Expect(len(ages), ToEqual, 1)
+			Expect(strings.HasSuffix(ages[0], "|h|#queue:orders"), ToBeTrue)
+		})
+
+		It("tags tasks with their code name and status", func() {
+			e.ObserveTask(worker.TaskInfo{CodeName: "resize", Status: worker.StatusComplete, Duration: 2000})
+			Expect(d.named("iron.worker.task.duration"), ToDeepEqual, []string{
+				"iron.worker.task.duration:2000|ms|#code_name:resize,status:complete",
+			})
+		})
+	})
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errors.New("refused") }
@@ -0,0 +1,192 @@
+package worker
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// A Budget tracks the compute time of finished tasks per code name, and
+// what it costs. Set it as a Worker's Budget to record the tasks waited for
+// with WaitForTask, and wrap callback handlers with Callback to record the
+// tasks reporting there. Each task is counted once however often it is
+// recorded within DedupWindow.
+//
+//	b := worker.NewBudget(0.05, 100) // $0.05 per compute hour, $100 budget
+//	b.Thresholds = []float64{0.8, 1}
+//	b.OnThreshold = func(a worker.BudgetAlert) { log.Printf("spent %.0f%% of the budget", a.Threshold*100) }
+//	w.Budget = b
+//	http.Handle("/done", worker.CallbackHandler(b.Callback(onDone)))
+type Budget struct {
+	// HourlyRate is the cost of an hour of compute.
+	HourlyRate float64
+	// Limit is the budget, in the currency of HourlyRate.
+	Limit float64
+	// Thresholds are the fractions of Limit at which OnThreshold is called,
+	// once each, [1] by default.
+	Thresholds []float64
+	// OnThreshold, if set, is called when the cost crosses a threshold.
+	OnThreshold func(BudgetAlert)
+	// Since is when tracking started, the base of Projected.
+	Since time.Time
+	// DedupWindow is how long the ids of recorded tasks are kept to count
+	// them once, 24 hours by default. It should outlast the time a task
+	// may be recorded again in, e.g. callback retries.
+	DedupWindow time.Duration
+
+	mu    sync.Mutex
+	codes map[string]*BudgetUsage
+	seen  map[string]time.Time // when recorded
+}
+
+// BudgetUsage is the compute a Budget recorded for a code package.
+type BudgetUsage struct {
+	CodeName string
+	Tasks    int
+	Compute  time.Duration
+	Cost     float64
+}
+
+// A BudgetAlert tells that the cost crossed a threshold of the budget.
+type BudgetAlert struct {
+	// Threshold is the fraction of the Limit crossed.
+	Threshold float64
+	Cost      float64
+	Limit     float64
+	// CodeName is the code package of the task that crossed it.
+	CodeName string
+}
+
+// NewBudget returns a Budget costing hourlyRate per compute hour, with a
+// limit, starting now.
+func NewBudget(hourlyRate, limit float64) *Budget {
+	return &Budget{HourlyRate: hourlyRate, Limit: limit, Since: time.Now()}
+}
+
+// Record adds the duration of the finished task info. Unfinished tasks are
+// ignored.
+func (b *Budget) Record(info TaskInfo) {
+	if !info.Status.IsTerminal() {
+		return
+	}
+	b.add(info.Id, info.CodeName, time.Duration(info.Duration)*time.Millisecond)
+}
+
+// RecordCallback adds the duration of the task that reported tc.
+func (b *Budget) RecordCallback(tc TaskCompleted) {
+	b.add(tc.TaskId, tc.CodeName, time.Duration(tc.Duration)*time.Millisecond)
+}
+
+// Callback returns fn, recording the tasks it is called for first, to pass
+// to CallbackHandler.
+func (b *Budget) Callback(fn func(TaskCompleted) error) func(TaskCompleted) error {
+	return func(tc TaskCompleted) error {
+		b.RecordCallback(tc)
+		return fn(tc)
+	}
+}
+
+// Usage returns the compute recorded per code package, by code name.
+func (b *Budget) Usage() []BudgetUsage {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	usage := make([]BudgetUsage, 0, len(b.codes))
+	for _, u := range b.codes {
+		usage = append(usage, *u)
+	}
+	sort.Slice(usage, func(i, j int) bool { return usage[i].CodeName < usage[j].CodeName })
+	return usage
+}
+
+// Compute returns the compute time recorded for all code packages.
+func (b *Budget) Compute() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var total time.Duration
+	for _, u := range b.codes {
+		total += u.Compute
+	}
+	return total
+}
+
+// Cost returns the cost of the compute recorded.
+func (b *Budget) Cost() float64 {
+	return b.cost(b.Compute())
+}
+
+// Projected returns the cost over period at the rate spent since Since,
+// e.g. for a month.
+func (b *Budget) Projected(period time.Duration) float64 {
+	elapsed := time.Since(b.Since)
+	if b.Since.IsZero() || elapsed <= 0 {
+		return 0
+	}
+	return b.Cost() * float64(period) / float64(elapsed)
+}
+
+func (b *Budget) add(id, codeName string, d time.Duration) {
+	b.mu.Lock()
+	now := time.Now()
+	if at, ok := b.seen[id]; ok && now.Sub(at) < b.dedupWindow() {
+		b.mu.Unlock()
+		return
+	}
+	if b.codes == nil {
+		b.codes, b.seen = map[string]*BudgetUsage{}, map[string]time.Time{}
+	}
+	b.see(id, now)
+	u, ok := b.codes[codeName]
+	if !ok {
+		u = &BudgetUsage{CodeName: codeName}
+		b.codes[codeName] = u
+	}
+	var before time.Duration
+	for _, u := range b.codes {
+		before += u.Compute
+	}
+	u.Tasks++
+	u.Compute += d
+	u.Cost = b.cost(u.Compute)
+	from, to := b.cost(before), b.cost(before+d)
+	b.mu.Unlock()
+
+	if b.OnThreshold == nil || b.Limit <= 0 {
+		return
+	}
+	for _, t := range b.thresholds() {
+		if limit := t * b.Limit; from < limit && to >= limit {
+			b.OnThreshold(BudgetAlert{Threshold: t, Cost: to, Limit: b.Limit, CodeName: codeName})
+		}
+	}
+}
+
+// see remembers the task id, dropping the ids older than DedupWindow once
+// the map doubled since the last sweep.
+func (b *Budget) see(id string, now time.Time) {
+	b.seen[id] = now
+	if len(b.seen) >= 1024 && len(b.seen)&(len(b.seen)-1) == 0 {
+		for id, at := range b.seen {
+			if now.Sub(at) >= b.dedupWindow() {
+				delete(b.seen, id)
+			}
+		}
+	}
+}
+
+func (b *Budget) dedupWindow() time.Duration {
+	if b.DedupWindow > 0 {
+		return b.DedupWindow
+	}
+	return 24 * time.Hour
+}
+
+func (b *Budget) cost(d time.Duration) float64 {
+	return d.Hours() * b.HourlyRate
+}
+
+func (b *Budget) thresholds() []float64 {
+	if len(b.Thresholds) > 0 {
+		return b.Thresholds
+	}
+	return []float64{1}
+}
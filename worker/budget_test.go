@@ -0,0 +1,87 @@
+package worker
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/iron-io/iron_go3/irontest"
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestBudget(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("budgets", func() {
+		It("adds up compute per code package once per task", func() {
+			b := NewBudget(2, 100)
+			b.Record(TaskInfo{Id: "1", CodeName: "resize", Status: StatusComplete, Duration: 30 * 60 * 1000})
+			b.Record(TaskInfo{Id: "1", CodeName: "resize", Status: StatusComplete, Duration: 30 * 60 * 1000})
+			b.RecordCallback(TaskCompleted{TaskId: "2", CodeName: "report", Status: StatusError, Duration: 15 * 60 * 1000})
+			b.Record(TaskInfo{Id: "3", CodeName: "resize", Status: StatusRunning, Duration: 1000})
+
+			Expect(b.Compute(), ToEqual, 45*time.Minute)
+			Expect(b.Cost(), ToEqual, 1.5)
+			Expect(b.Usage(), ToDeepEqual, []BudgetUsage{
+				{CodeName: "report", Tasks: 1, Compute: 15 * time.Minute, Cost: 0.5},
+				{CodeName: "resize", Tasks: 1, Compute: 30 * time.Minute, Cost: 1},
+			})
+		})
+
+		It("forgets tasks outside its dedup window", func() {
+			b := NewBudget(1, 100)
+			b.DedupWindow = 10 * time.Millisecond
+			for i := 0; i < 1000; i++ {
+				b.Record(TaskInfo{Id: fmt.Sprint(i), CodeName: "resize", Status: StatusComplete, Duration: 1000})
+			}
+			time.Sleep(20 * time.Millisecond)
+			for i := 0; i < 24; i++ {
+				b.Record(TaskInfo{Id: fmt.Sprint("new", i), CodeName: "resize", Status: StatusComplete, Duration: 1000})
+			}
+			Expect(len(b.seen), ToEqual, 24)
+
+			b.Record(TaskInfo{Id: "new0", CodeName: "resize", Status: StatusComplete, Duration: 1000})
+			Expect(b.Usage()[0].Tasks, ToEqual, 1024)
+			time.Sleep(20 * time.Millisecond)
+			b.Record(TaskInfo{Id: "new0", CodeName: "resize", Status: StatusComplete, Duration: 1000})
+			Expect(b.Usage()[0].Tasks, ToEqual, 1025)
+		})
+
+		It("projects the cost over a period", func() {
+			b := NewBudget(1, 100)
+			b.Since = time.Now().Add(-24 * time.Hour)
+			b.Record(TaskInfo{Id: "1", CodeName: "resize", Status: StatusComplete, Duration: 60 * 60 * 1000})
+			projected := b.Projected(30 * 24 * time.Hour)
+			Expect(projected > 29.9 && projected <= 30, ToBeTrue)
+		})
+
+		It("alerts once per threshold crossed", func() {
+			b := NewBudget(1, 2)
+			b.Thresholds = []float64{0.5, 1}
+			var alerts []BudgetAlert
+			b.OnThreshold = func(a BudgetAlert) { alerts = append(alerts, a) }
+			b.Record(TaskInfo{Id: "1", CodeName: "a", Status: StatusComplete, Duration: 30 * 60 * 1000})
+			Expect(len(alerts), ToEqual, 0)
+			b.Record(TaskInfo{Id: "2", CodeName: "b", Status: StatusComplete, Duration: 90 * 60 * 1000})
+			Expect(alerts, ToDeepEqual, []BudgetAlert{
+				{Threshold: 0.5, Cost: 2, Limit: 2, CodeName: "b"},
+				{Threshold: 1, Cost: 2, Limit: 2, CodeName: "b"},
+			})
+			b.Record(TaskInfo{Id: "3", CodeName: "b", Status: StatusComplete, Duration: 60 * 1000})
+			Expect(len(alerts), ToEqual, 2)
+		})
+
+		It("records the tasks a worker waits for", func() {
+			srv := irontest.NewServer()
+			defer srv.Close()
+			w := &Worker{Settings: srv.Settings("iron_worker"), Budget: NewBudget(1, 0)}
+			ids, err := w.TaskQueue(Task{CodeName: "resize"})
+			Expect(err, ToBeNil)
+			Expect(srv.Worker.Finish(ids[0], irontest.StatusComplete, "", ""), ToBeNil)
+
+			info := <-w.WaitForTask(ids[0])
+			Expect(info.Id, ToEqual, ids[0])
+			Expect(w.Budget.Usage()[0].Tasks, ToEqual, 1)
+		})
+	})
+}
@@ -0,0 +1,79 @@
+package worker
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/iron-io/iron_go3/api"
+)
+
+// TaskCompleted is the body IronWorker POSTs to a task's Callback URL once the
+// task has finished running.
+type TaskCompleted struct {
+	TaskId    string     `json:"task_id"`
+	CodeName  string     `json:"code_name"`
+	ProjectId string     `json:"project_id"`
+	Status    TaskStatus `json:"status"`
+	Msg       string     `json:"msg,omitempty"`
+	Duration  int        `json:"duration"`
+	StartTime time.Time  `json:"start_time"`
+	EndTime   time.Time  `json:"end_time"`
+}
+
+// CallbackHandler returns an http.Handler that decodes task completion
+// callbacks and passes them to fn. If fn returns an error the handler responds
+// with a 500 so the callback will be retried. To refuse callbacks that don't
+// come from Iron, see CallbackOptions.Secret.
+func CallbackHandler(fn func(TaskCompleted) error) http.Handler {
+	return CallbackHandlerWith(CallbackOptions{}, fn)
+}
+
+// CallbackOptions are the options of CallbackHandlerWith.
+type CallbackOptions struct {
+	// Secret, if set, refuses callbacks to URLs that weren't signed with it
+	// by api.SignURL. Sign the tasks' Callback URLs for longer than the
+	// tasks may wait in the queue and run.
+	Secret []byte
+}
+
+// callbackTTL is how long the callback URLs signed by the package are
+// valid: tasks run for up to a day, after waiting in the queue.
+const callbackTTL = 7 * 24 * time.Hour
+
+// CallbackHandlerWith is CallbackHandler with options.
+func CallbackHandlerWith(opts CallbackOptions, fn func(TaskCompleted) error) http.Handler {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			w.Header().Set("Allow", "POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var event struct {
+			TaskCompleted
+			Id string `json:"id"` // some deliveries use the plain task info shape
+		}
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			http.Error(w, "invalid callback body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if event.TaskId == "" {
+			event.TaskId = event.Id
+		}
+		if event.TaskId == "" {
+			http.Error(w, "callback body is missing task_id", http.StatusBadRequest)
+			return
+		}
+
+		if err := fn(event.TaskCompleted); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	if opts.Secret != nil {
+		return api.RequireSignatureWith(opts.Secret, api.SignatureOptions{AcceptURL: true}, h)
+	}
+	return h
+}
@@ -0,0 +1,68 @@
+package worker
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/iron-io/iron_go3/api"
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestCallbackHandler(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("task completion callbacks", func() {
+		post := func(h http.Handler, body string) *httptest.ResponseRecorder {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest("POST", "/callback", strings.NewReader(body)))
+			return rec
+		}
+
+		It("decodes the completed task", func() {
+			var got TaskCompleted
+			h := CallbackHandler(func(e TaskCompleted) error { got = e; return nil })
+
+			rec := post(h, `{"task_id":"abc","code_name":"GoFun","status":"complete","duration":42}`)
+			Expect(rec.Code, ToEqual, http.StatusOK)
+			Expect(got.TaskId, ToEqual, "abc")
+			Expect(got.CodeName, ToEqual, "GoFun")
+			Expect(got.Status, ToEqual, StatusComplete)
+			Expect(got.Duration, ToEqual, 42)
+		})
+
+		It("accepts the task info shape", func() {
+			var got TaskCompleted
+			h := CallbackHandler(func(e TaskCompleted) error { got = e; return nil })
+
+			rec := post(h, `{"id":"abc","status":"error"}`)
+			Expect(rec.Code, ToEqual, http.StatusOK)
+			Expect(got.TaskId, ToEqual, "abc")
+		})
+
+		It("rejects bodies without a task id", func() {
+			h := CallbackHandler(func(TaskCompleted) error { return nil })
+			Expect(post(h, `{"status":"complete"}`).Code, ToEqual, http.StatusBadRequest)
+			Expect(post(h, `not json`).Code, ToEqual, http.StatusBadRequest)
+		})
+
+		It("asks for a retry when the callback fails", func() {
+			h := CallbackHandler(func(TaskCompleted) error { return errors.New("try later") })
+			Expect(post(h, `{"task_id":"abc"}`).Code, ToEqual, http.StatusInternalServerError)
+		})
+
+		It("refuses callbacks to URLs not signed with its secret", func() {
+			secret := []byte("secret")
+			h := CallbackHandlerWith(CallbackOptions{Secret: secret}, func(TaskCompleted) error { return nil })
+			Expect(post(h, `{"task_id":"abc"}`).Code, ToEqual, http.StatusUnauthorized)
+
+			signed, err := api.SignURL("/callback", secret, callbackTTL)
+			Expect(err, ToBeNil)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest("POST", signed, strings.NewReader(`{"task_id":"abc"}`)))
+			Expect(rec.Code, ToEqual, http.StatusOK)
+		})
+	})
+}
@@ -0,0 +1,36 @@
+package worker
+
+// ClusterStats tells how busy a cluster is.
+type ClusterStats struct {
+	// Machines is the number of machines running in the cluster.
+	Machines     int `json:"machines"`
+	RunningTasks int `json:"running_tasks"`
+	QueuedTasks  int `json:"queued_tasks"`
+}
+
+// ClusterList lists the clusters the token has access to. Tasks and code
+// packages name the cluster they run on with their Cluster and
+// DefaultCluster fields.
+func (w *Worker) ClusterList() ([]Cluster, error) {
+	var out struct {
+		Clusters []Cluster `json:"clusters"`
+	}
+	err := w.clusters().Req("GET", nil, &out)
+	return out.Clusters, err
+}
+
+// ClusterInfo gets a cluster.
+func (w *Worker) ClusterInfo(id string) (Cluster, error) {
+	var out struct {
+		C Cluster `json:"cluster"`
+	}
+	err := w.clusters(id).Req("GET", nil, &out)
+	return out.C, err
+}
+
+// ClusterStats gets the machine and task counts of a cluster.
+func (w *Worker) ClusterStats(id string) (ClusterStats, error) {
+	var stats ClusterStats
+	err := w.clusters(id, "stats").Req("GET", nil, &stats)
+	return stats, err
+}
@@ -0,0 +1,49 @@
+package worker
+
+import (
+	"testing"
+
+	"github.com/iron-io/iron_go3/irontest"
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestClusters(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("clusters", func() {
+		srv := irontest.NewServer()
+		defer srv.Close()
+		w := &Worker{Settings: srv.Settings("iron_worker")}
+
+		c, err := w.ClusterCreate(Cluster{Name: "dedicated", Memory: 4096})
+		Expect(err, ToBeNil)
+
+		It("lists and gets clusters", func() {
+			clusters, err := w.ClusterList()
+			Expect(err, ToBeNil)
+			Expect(len(clusters), ToEqual, 1)
+			Expect(clusters[0].Name, ToEqual, "dedicated")
+
+			info, err := w.ClusterInfo(c.Id)
+			Expect(err, ToBeNil)
+			Expect(info, ToDeepEqual, c)
+		})
+
+		It("counts the machines and tasks of clusters", func() {
+			Expect(srv.Worker.SetMachines(c.Id, 3), ToBeNil)
+			_, err := w.CodePackageUpload(Code{Name: "crunch", Image: "acme/crunch", DefaultCluster: c.Id})
+			Expect(err, ToBeNil)
+			ids, err := w.TaskQueue(Task{CodeName: "crunch"}, Task{CodeName: "crunch"}, Task{CodeName: "crunch", Cluster: "default"})
+			Expect(err, ToBeNil)
+			Expect(srv.Worker.Start(ids[0]), ToBeNil)
+
+			stats, err := w.ClusterStats(c.Id)
+			Expect(err, ToBeNil)
+			Expect(stats, ToEqual, ClusterStats{Machines: 3, RunningTasks: 1, QueuedTasks: 1})
+
+			info, err := w.TaskInfo(ids[2])
+			Expect(err, ToBeNil)
+			Expect(info.Cluster, ToEqual, "default")
+		})
+	})
+}
@@ -0,0 +1,114 @@
+package worker
+
+import (
+	"errors"
+)
+
+// ErrCodeNotFound is returned when looking up a code package by a name that
+// isn't registered in the project.
+var ErrCodeNotFound = errors.New("code package not found")
+
+// CodePackageByName finds the code package with the given name.
+func (w *Worker) CodePackageByName(name string) (CodeInfo, error) {
+	for code, err := range w.AllCodePackages() {
+		if err != nil {
+			return CodeInfo{}, err
+		}
+		if code.Name == name {
+			return code, nil
+		}
+	}
+	return CodeInfo{}, ErrCodeNotFound
+}
+
+// CodeUpdateOptions are the settings CodeUpdate can change, nil fields are
+// left as they are.
+type CodeUpdateOptions struct {
+	DefaultPriority *int
+	DefaultCluster  *string
+	MaxConcurrency  *int
+	Retries         *int
+	RetriesDelay    *int // seconds
+	Config          *string
+	// EnvVars replaces all of the package's env vars when non-nil.
+	EnvVars map[string]string
+}
+
+// CodeUpdate changes the config of a registered code package without
+// uploading its code again. The update creates a new revision of the package
+// that runs the same code, and the updated info is returned.
+func (w *Worker) CodeUpdate(codeName string, opts CodeUpdateOptions) (CodeInfo, error) {
+	info, err := w.CodePackageByName(codeName)
+	if err != nil {
+		return CodeInfo{}, err
+	}
+	// the listing doesn't carry the package's config
+	info, err = w.CodePackageInfo(info.Id)
+	if err != nil {
+		return CodeInfo{}, err
+	}
+
+	code := Code{
+		Name:            info.Name,
+		Image:           info.Image,
+		Command:         info.Command,
+		Stack:           info.Stack,
+		Config:          info.Config,
+		MaxConcurrency:  info.MaxConcurrency,
+		Retries:         info.Retries,
+		RetriesDelay:    info.RetriesDelay,
+		DefaultPriority: info.DefaultPriority,
+		DefaultCluster:  info.DefaultCluster,
+		EnvVars:         info.EnvVars,
+	}
+	if info.Runtime != nil {
+		code.Runtime = *info.Runtime
+	}
+	if opts.DefaultPriority != nil {
+		code.DefaultPriority = *opts.DefaultPriority
+	}
+	if opts.DefaultCluster != nil {
+		code.DefaultCluster = *opts.DefaultCluster
+	}
+	if opts.MaxConcurrency != nil {
+		code.MaxConcurrency = *opts.MaxConcurrency
+	}
+	if opts.Retries != nil {
+		code.Retries = opts.Retries
+	}
+	if opts.RetriesDelay != nil {
+		code.RetriesDelay = opts.RetriesDelay
+	}
+	if opts.Config != nil {
+		code.Config = *opts.Config
+	}
+	if opts.EnvVars != nil {
+		code.EnvVars = opts.EnvVars
+	}
+
+	if _, err := w.CodePackageUpload(code); err != nil {
+		return CodeInfo{}, err
+	}
+	return w.CodePackageInfo(info.Id)
+}
+
+// PauseCode stops the tasks of the code package codeName from running until
+// ResumeCode. Its queued tasks, and the ones queued meanwhile, wait in the
+// queue. Running tasks aren't affected, cancel them to stop them.
+func (w *Worker) PauseCode(codeName string) error {
+	info, err := w.CodePackageByName(codeName)
+	if err != nil {
+		return err
+	}
+	return w.codes(info.Id, "pause_task_queue").Req("POST", nil, nil)
+}
+
+// ResumeCode lets the queued tasks of the code package codeName, paused by
+// PauseCode, run again.
+func (w *Worker) ResumeCode(codeName string) error {
+	info, err := w.CodePackageByName(codeName)
+	if err != nil {
+		return err
+	}
+	return w.codes(info.Id, "resume_task_queue").Req("POST", nil, nil)
+}
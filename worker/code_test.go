@@ -0,0 +1,231 @@
+package worker
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iron-io/iron_go3/api"
+	"github.com/iron-io/iron_go3/irontest"
+	. "github.com/jeffh/go.bdd"
+)
+
+// fakeCodes keeps code packages by id, each upload of a known name creates a
+// new revision of it.
+type fakeCodes struct {
+	mu    sync.Mutex
+	codes map[string]*CodeInfo
+}
+
+func (f *fakeCodes) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	path := strings.TrimPrefix(r.URL.Path, "/2/projects/project/codes")
+	switch {
+	case r.Method == "GET" && path == "":
+		var out struct {
+			Codes []CodeInfo `json:"codes"`
+		}
+		for _, c := range f.codes {
+			out.Codes = append(out.Codes, CodeInfo{Id: c.Id, Name: c.Name, Rev: c.Rev})
+		}
+		json.NewEncoder(w).Encode(out)
+	case r.Method == "GET":
+		c, ok := f.codes[strings.TrimPrefix(path, "/")]
+		if !ok {
+			http.Error(w, `{"msg":"Not found"}`, http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(c)
+	case r.Method == "POST" && path == "":
+		var in Code
+		if err := json.Unmarshal([]byte(r.FormValue("data")), &in); err != nil {
+			http.Error(w, `{"msg":"Bad data"}`, http.StatusBadRequest)
+			return
+		}
+		c := &CodeInfo{Id: in.Name + "-id", Name: in.Name}
+		if old, ok := f.codes[c.Id]; ok {
+			c.Rev = old.Rev
+		}
+		c.Rev++
+		c.Image, c.Command, c.Stack, c.Config = in.Image, in.Command, in.Stack, in.Config
+		c.MaxConcurrency, c.Retries, c.RetriesDelay = in.MaxConcurrency, in.Retries, in.RetriesDelay
+		c.DefaultPriority, c.EnvVars = in.DefaultPriority, in.EnvVars
+		f.codes[c.Id] = c
+		json.NewEncoder(w).Encode(map[string]string{"id": c.Id})
+	default:
+		http.Error(w, `{"msg":"Not found"}`, http.StatusNotFound)
+	}
+}
+
+func TestCodeUpdate(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("updating code packages", func() {
+		fake := &fakeCodes{codes: map[string]*CodeInfo{}}
+		w, stop := testWorker(fake)
+		defer stop()
+
+		retries := 3
+		_, err := w.CodePackageUpload(Code{
+			Name:           "hello",
+			Image:          "iron/hello",
+			Config:         "a=1",
+			MaxConcurrency: 5,
+			Retries:        &retries,
+			EnvVars:        map[string]string{"A": "1"},
+		})
+		Expect(err, ToBeNil)
+
+		It("changes only the given settings in a new revision", func() {
+			prio, config := 2, "a=2"
+			info, err := w.CodeUpdate("hello", CodeUpdateOptions{DefaultPriority: &prio, Config: &config})
+			Expect(err, ToBeNil)
+			Expect(info.Rev, ToEqual, 2)
+			Expect(info.Config, ToEqual, "a=2")
+			Expect(info.DefaultPriority, ToEqual, 2)
+			Expect(info.Image, ToEqual, "iron/hello")
+			Expect(info.MaxConcurrency, ToEqual, 5)
+			Expect(*info.Retries, ToEqual, 3)
+			Expect(info.EnvVars, ToDeepEqual, map[string]string{"A": "1"})
+		})
+
+		It("replaces all env vars", func() {
+			info, err := w.CodeUpdate("hello", CodeUpdateOptions{EnvVars: map[string]string{"B": "2"}})
+			Expect(err, ToBeNil)
+			Expect(info.EnvVars, ToDeepEqual, map[string]string{"B": "2"})
+			Expect(info.Config, ToEqual, "a=2")
+		})
+
+		It("fails for unknown code packages", func() {
+			_, err := w.CodeUpdate("missing", CodeUpdateOptions{})
+			Expect(errors.Is(err, ErrCodeNotFound), ToBeTrue)
+		})
+	})
+}
+
+func TestPauseCode(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("pausing code packages", func() {
+		srv := irontest.NewServer()
+		defer srv.Close()
+		w := &Worker{Settings: srv.Settings("iron_worker")}
+		srv.Worker.Handle("hello", func(payload string) (string, error) { return "hello", nil })
+		_, err := w.CodePackageUpload(Code{Name: "hello", Image: "iron/hello"})
+		Expect(err, ToBeNil)
+
+		It("keeps the tasks queued until resumed", func() {
+			Expect(w.PauseCode("hello"), ToBeNil)
+			ids, err := w.TaskQueue(Task{CodeName: "hello"})
+			Expect(err, ToBeNil)
+			time.Sleep(20 * time.Millisecond)
+			info, err := w.TaskInfo(ids[0])
+			Expect(err, ToBeNil)
+			Expect(info.Status, ToEqual, StatusQueued)
+
+			Expect(w.ResumeCode("hello"), ToBeNil)
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			info, err = w.waitTask(ctx, ids[0])
+			Expect(err, ToBeNil)
+			Expect(info.Status, ToEqual, StatusComplete)
+		})
+
+		It("fails for unknown code packages", func() {
+			Expect(errors.Is(w.PauseCode("missing"), ErrCodeNotFound), ToBeTrue)
+		})
+	})
+}
+
+func TestCodeStats(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("code stats", func() {
+		srv := irontest.NewServer()
+		defer srv.Close()
+		w := &Worker{Settings: srv.Settings("iron_worker")}
+		_, err := w.CodePackageUpload(Code{Name: "resize", Image: "iron/resize", MaxConcurrency: 2})
+		Expect(err, ToBeNil)
+
+		It("counts queued, running and failed tasks", func() {
+			ids, err := w.TaskQueue(Task{CodeName: "resize"}, Task{CodeName: "resize"}, Task{CodeName: "resize"},
+				Task{CodeName: "resize"}, Task{CodeName: "resize"})
+			Expect(err, ToBeNil)
+			Expect(srv.Worker.Start(ids[0]), ToBeNil)
+			Expect(srv.Worker.Start(ids[1]), ToBeNil)
+			Expect(srv.Worker.Finish(ids[2], irontest.StatusError, "boom", ""), ToBeNil)
+			Expect(srv.Worker.Finish(ids[3], irontest.StatusComplete, "", ""), ToBeNil)
+
+			stats, err := w.CodeStats("resize")
+			Expect(err, ToBeNil)
+			Expect(stats, ToEqual, CodeStats{CodeName: "resize", Queued: 1, Running: 2, MaxConcurrency: 2, RecentTasks: 2, RecentErrors: 1})
+			Expect(stats.Saturated(), ToBeTrue)
+			Expect(stats.ErrorRate(), ToEqual, 0.5)
+		})
+	})
+}
+
+func TestDownloads(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("downloads", func() {
+		srv := irontest.NewServer()
+		defer srv.Close()
+		w := &Worker{Settings: srv.Settings("iron_worker")}
+
+		zipName := filepath.Join(t.TempDir(), "worker.zip")
+		f, _ := os.Create(zipName)
+		zw := zip.NewWriter(f)
+		fw, _ := zw.Create("worker.sh")
+		fw.Write([]byte(strings.Repeat("echo hello\n", 1000)))
+		zw.Close()
+		f.Close()
+		code, err := w.CodePackageZipUpload(zipName, Code{Name: "hello", Image: "iron/hello"})
+		Expect(err, ToBeNil)
+
+		It("reports the progress of code packages", func() {
+			var buf bytes.Buffer
+			var reports []api.Progress
+			err := w.CodePackageZipDownload(code.Id, &buf, func(p api.Progress) { reports = append(reports, p) })
+			Expect(err, ToBeNil)
+			uploaded, _ := srv.Worker.Code("hello")
+			Expect(buf.Bytes(), ToDeepEqual, uploaded.Zip)
+			last := reports[len(reports)-1]
+			Expect(last, ToEqual, api.Progress{Bytes: int64(buf.Len()), Total: int64(buf.Len()), Done: true})
+		})
+
+		It("reports the progress of uploads", func() {
+			var last api.Progress
+			_, err := w.CodePackageZipUploadProgress(zipName, Code{Name: "hello", Image: "iron/hello"}, func(p api.Progress) { last = p })
+			Expect(err, ToBeNil)
+			Expect(last.Done, ToBeTrue)
+			Expect(last.Total, ToEqual, int64(-1))
+			Expect(last.Bytes > 0, ToBeTrue)
+		})
+
+		It("streams task logs", func() {
+			ids, err := w.TaskQueue(Task{CodeName: "hello"})
+			Expect(err, ToBeNil)
+			Expect(srv.Worker.Finish(ids[0], irontest.StatusComplete, "", "hello\n"), ToBeNil)
+			var buf bytes.Buffer
+			var done bool
+			Expect(w.TaskLogTo(ids[0], &buf, func(p api.Progress) { done = p.Done }), ToBeNil)
+			Expect(buf.String(), ToEqual, "hello\n")
+			Expect(done, ToBeTrue)
+
+			log, err := w.TaskLog(ids[0])
+			Expect(err, ToBeNil)
+			Expect(string(log), ToEqual, "hello\n")
+		})
+	})
+}
@@ -0,0 +1,241 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrCronIrregular is returned by Schedule.SetCron for cron expressions that
+// don't fire at a fixed interval, which IronWorker schedules can't express.
+var ErrCronIrregular = errors.New("cron expression does not run at a fixed interval")
+
+// Cron is a parsed standard 5 field cron expression:
+//
+//	minute hour day-of-month month day-of-week
+//
+// Fields accept *, numbers, ranges (1-5), steps (*/15, 0-30/10), lists (1,15)
+// and month or weekday names (JAN, MON). The @yearly, @monthly, @weekly,
+// @daily and @hourly shorthands are accepted as well. Times are computed in
+// UTC, like IronWorker schedules.
+type Cron struct {
+	expr                          string
+	minute, hour, dom, month, dow uint64
+	domStar, dowStar              bool
+}
+
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var (
+	cronMonths = []string{"", "JAN", "FEB", "MAR", "APR", "MAY", "JUN", "JUL", "AUG", "SEP", "OCT", "NOV", "DEC"}
+	cronDays   = []string{"SUN", "MON", "TUE", "WED", "THU", "FRI", "SAT"}
+)
+
+// ParseCron parses a cron expression, see Cron.
+func ParseCron(expr string) (*Cron, error) {
+	spec := strings.TrimSpace(expr)
+	if m, ok := cronMacros[strings.ToLower(spec)]; ok {
+		spec = m
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression %q: expected 5 fields, got %d", expr, len(fields))
+	}
+
+	c := &Cron{expr: expr}
+	var err error
+	if c.minute, err = parseCronField(fields[0], 0, 59, nil); err != nil {
+		return nil, fmt.Errorf("cron expression %q: minute: %w", expr, err)
+	}
+	if c.hour, err = parseCronField(fields[1], 0, 23, nil); err != nil {
+		return nil, fmt.Errorf("cron expression %q: hour: %w", expr, err)
+	}
+	if c.dom, err = parseCronField(fields[2], 1, 31, nil); err != nil {
+		return nil, fmt.Errorf("cron expression %q: day of month: %w", expr, err)
+	}
+	if c.month, err = parseCronField(fields[3], 1, 12, cronMonths); err != nil {
+		return nil, fmt.Errorf("cron expression %q: month: %w", expr, err)
+	}
+	// 7 is an alias for sunday
+	if c.dow, err = parseCronField(fields[4], 0, 7, cronDays); err != nil {
+		return nil, fmt.Errorf("cron expression %q: day of week: %w", expr, err)
+	}
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	c.domStar = strings.HasPrefix(fields[2], "*")
+	c.dowStar = strings.HasPrefix(fields[4], "*")
+	return c, nil
+}
+
+func parseCronField(field string, min, max int, names []string) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			rng = part[:i]
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step in %q", part)
+			}
+		}
+
+		lo, hi := min, max
+		if rng != "*" {
+			bounds := strings.SplitN(rng, "-", 2)
+			var err error
+			if lo, err = cronValue(bounds[0], names); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if len(bounds) == 2 {
+				if hi, err = cronValue(bounds[1], names); err != nil {
+					return 0, err
+				}
+			} else if step > 1 {
+				hi = max // 5/15 means 5-max/15
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("%q is out of range %d-%d", part, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func cronValue(s string, names []string) (int, error) {
+	for i, name := range names {
+		if name != "" && strings.EqualFold(s, name) {
+			return i, nil
+		}
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+	return v, nil
+}
+
+func (c *Cron) String() string { return c.expr }
+
+func (c *Cron) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domStar || c.dowStar {
+		return dom && dow
+	}
+	return dom || dow // both restricted, either one will do
+}
+
+// Next returns the first time after t the expression fires, or the zero time
+// if it never does (e.g. "0 0 30 2 *").
+func (c *Cron) Next(t time.Time) time.Time {
+	t = t.UTC().Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if c.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !c.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if c.hour&(1<<uint(t.Hour())) == 0 {
+			t = t.Truncate(time.Hour).Add(time.Hour)
+			continue
+		}
+		if c.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// NextRuns returns the next n times the expression fires from now, handy for
+// validating an expression or showing a dry run.
+func (c *Cron) NextRuns(n int) []time.Time {
+	return c.NextRunsAfter(time.Now(), n)
+}
+
+// NextRunsAfter is NextRuns starting from t.
+func (c *Cron) NextRunsAfter(t time.Time, n int) []time.Time {
+	runs := make([]time.Time, 0, n)
+	for len(runs) < n {
+		t = c.Next(t)
+		if t.IsZero() {
+			break
+		}
+		runs = append(runs, t)
+	}
+	return runs
+}
+
+// Every returns the interval between runs if the expression fires at a fixed
+// interval, e.g. 15 minutes for "*/15 * * * *" or a week for "0 9 * * MON".
+func (c *Cron) Every() (time.Duration, bool) {
+	// restricted days of month are calendar based: no step divides the
+	// length of every month, so */2 runs on the 31st and again on the 1st
+	if c.dom != cronAll(1, 31) || c.month != cronAll(1, 12) {
+		return 0, false
+	}
+
+	// every pattern left repeats at least weekly, check two full weeks
+	start := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC).Add(-time.Minute)
+	end := start.AddDate(0, 0, 14)
+	first := c.Next(start)
+	if first.IsZero() {
+		return 0, false
+	}
+	var every time.Duration
+	for prev, t := first, c.Next(first); t.Before(end); prev, t = t, c.Next(t) {
+		if every == 0 {
+			every = t.Sub(prev)
+		} else if t.Sub(prev) != every {
+			return 0, false
+		}
+	}
+	return every, every > 0
+}
+
+func cronAll(min, max int) uint64 {
+	var bits uint64
+	for v := min; v <= max; v++ {
+		bits |= 1 << uint(v)
+	}
+	return bits
+}
+
+// SetCron sets RunEvery and StartAt so the schedule runs like the given cron
+// expression. Expressions that don't fire at a fixed interval return
+// ErrCronIrregular.
+func (s *Schedule) SetCron(expr string) error {
+	c, err := ParseCron(expr)
+	if err != nil {
+		return err
+	}
+	every, ok := c.Every()
+	if !ok {
+		return ErrCronIrregular
+	}
+	runEvery := int(every.Seconds())
+	startAt := c.Next(time.Now())
+	s.RunEvery = &runEvery
+	s.StartAt = &startAt
+	return nil
+}
@@ -0,0 +1,136 @@
+package worker
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestCron(t *testing.T) {
+	defer PrintSpecReport()
+
+	at := func(s string) time.Time {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			panic(err)
+		}
+		return t
+	}
+
+	Describe("cron expressions", func() {
+		It("computes the next runs", func() {
+			c, err := ParseCron("*/20 9-10 * * MON-FRI")
+			Expect(err, ToBeNil)
+
+			runs := c.NextRunsAfter(at("2016-01-01T10:30:00Z"), 4) // a friday
+			Expect(runs, ToDeepEqual, []time.Time{
+				at("2016-01-01T10:40:00Z"),
+				at("2016-01-04T09:00:00Z"),
+				at("2016-01-04T09:20:00Z"),
+				at("2016-01-04T09:40:00Z"),
+			})
+		})
+
+		It("ors restricted day of month and day of week", func() {
+			c, err := ParseCron("0 0 13 * 5")
+			Expect(err, ToBeNil)
+			Expect(c.Next(at("2016-05-01T00:00:00Z")), ToEqual, at("2016-05-06T00:00:00Z"))
+			Expect(c.Next(at("2016-05-10T00:00:00Z")), ToEqual, at("2016-05-13T00:00:00Z"))
+		})
+
+		It("understands macros and sunday as 7", func() {
+			c, err := ParseCron("@weekly")
+			Expect(err, ToBeNil)
+			Expect(c.Next(at("2016-01-01T00:00:00Z")), ToEqual, at("2016-01-03T00:00:00Z"))
+
+			c, err = ParseCron("0 0 * * 7")
+			Expect(err, ToBeNil)
+			Expect(c.Next(at("2016-01-01T00:00:00Z")), ToEqual, at("2016-01-03T00:00:00Z"))
+		})
+
+		It("rejects invalid expressions", func() {
+			for _, expr := range []string{"* * * *", "60 * * * *", "*/0 * * * *", "* * 0 * *", "a * * * *", "5-1 * * * *"} {
+				_, err := ParseCron(expr)
+				Expect(err, ToNotBeNil)
+			}
+		})
+
+		It("finds fixed intervals", func() {
+			for expr, every := range map[string]time.Duration{
+				"*/15 * * * *": 15 * time.Minute,
+				"@hourly":      time.Hour,
+				"30 2 * * *":   24 * time.Hour,
+				"0 9 * * MON":  7 * 24 * time.Hour,
+				"0 */6 * * *":  6 * time.Hour,
+			} {
+				c, err := ParseCron(expr)
+				Expect(err, ToBeNil)
+				d, ok := c.Every()
+				Expect(ok, ToBeTrue)
+				Expect(d, ToEqual, every)
+			}
+
+			for _, expr := range []string{"*/7 * * * *", "@monthly", "0 9 * * MON-FRI", "0 0 * 6 *"} {
+				c, _ := ParseCron(expr)
+				_, ok := c.Every()
+				Expect(ok, ToEqual, false)
+			}
+		})
+
+		It("doesn't take day of month steps and lists for fixed intervals", func() {
+			for _, tc := range []struct {
+				expr  string
+				every time.Duration
+			}{
+				{"0 0 * * *", 24 * time.Hour},
+				{"0 0 */1 * *", 24 * time.Hour},
+				{"0 0 */2 * *", 0},
+				{"0 0 */3 * *", 0},
+				{"0 0 1,15 * *", 0},
+			} {
+				c, err := ParseCron(tc.expr)
+				Expect(err, ToBeNil)
+				d, ok := c.Every()
+				Expect(ok, ToEqual, tc.every > 0)
+				Expect(d, ToEqual, tc.every)
+			}
+
+			c, err := ParseCron("0 0 */2 * *")
+			Expect(err, ToBeNil)
+			Expect(c.NextRunsAfter(at("2016-01-30T00:00:00Z"), 2), ToDeepEqual, []time.Time{
+				at("2016-01-31T00:00:00Z"),
+				at("2016-02-01T00:00:00Z"),
+			})
+		})
+
+		It("combines day of month steps and ranges with days of week like cron", func() {
+			c, err := ParseCron("0 0 */2 * MON")
+			Expect(err, ToBeNil)
+			Expect(c.NextRunsAfter(at("2016-01-01T00:00:00Z"), 4), ToDeepEqual, []time.Time{
+				at("2016-01-11T00:00:00Z"),
+				at("2016-01-25T00:00:00Z"),
+				at("2016-02-01T00:00:00Z"),
+				at("2016-02-15T00:00:00Z"),
+			})
+
+			c, err = ParseCron("0 0 1-31 * MON")
+			Expect(err, ToBeNil)
+			Expect(c.NextRunsAfter(at("2016-01-01T00:00:00Z"), 3), ToDeepEqual, []time.Time{
+				at("2016-01-02T00:00:00Z"),
+				at("2016-01-03T00:00:00Z"),
+				at("2016-01-04T00:00:00Z"),
+			})
+		})
+
+		It("translates to a schedule", func() {
+			var s Schedule
+			Expect(s.SetCron("*/10 * * * *"), ToBeNil)
+			Expect(*s.RunEvery, ToEqual, 600)
+			Expect(s.StartAt.Minute()%10, ToEqual, 0)
+			Expect(s.StartAt.After(time.Now()), ToBeTrue)
+
+			Expect(s.SetCron("@monthly"), ToEqual, ErrCronIrregular)
+		})
+	})
+}
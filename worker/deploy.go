@@ -0,0 +1,236 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// The slots of a Deploy, the code package names it deploys to are the
+// alias followed by "-" and the slot.
+const (
+	SlotBlue  = "blue"
+	SlotGreen = "green"
+)
+
+// ErrSmokeFailed is returned by Deploy when the smoke task didn't pass.
+var ErrSmokeFailed = errors.New("smoke task failed")
+
+// An AliasStore maps the aliases producers queue tasks with to the code
+// packages they run, see QueueAlias. Setting an alias must be atomic, so
+// producers see either code package. MemoryAliases and the cache package's
+// AliasStore implement it.
+type AliasStore interface {
+	// Get returns the code name alias points at, "" if it isn't set.
+	Get(alias string) (string, error)
+	// Set points alias at codeName.
+	Set(alias, codeName string) error
+}
+
+// MemoryAliases is an AliasStore for a single process. The zero value is
+// ready to use.
+type MemoryAliases struct {
+	mu      sync.Mutex
+	aliases map[string]string
+}
+
+func (m *MemoryAliases) Get(alias string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.aliases[alias], nil
+}
+
+func (m *MemoryAliases) Set(alias, codeName string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.aliases == nil {
+		m.aliases = map[string]string{}
+	}
+	m.aliases[alias] = codeName
+	return nil
+}
+
+// QueueAlias queues tasks to the code package alias points at in aliases,
+// their CodeName is ignored.
+func (w *Worker) QueueAlias(aliases AliasStore, alias string, tasks ...Task) ([]string, error) {
+	codeName, err := aliases.Get(alias)
+	if err != nil {
+		return nil, fmt.Errorf("resolving alias %s: %w", alias, err)
+	}
+	if codeName == "" {
+		return nil, fmt.Errorf("alias %s is not set", alias)
+	}
+	aliased := make([]Task, len(tasks))
+	for i, t := range tasks {
+		t.CodeName = codeName
+		aliased[i] = t
+	}
+	return w.TaskQueue(aliased...)
+}
+
+// A Deploy does blue/green deploys of a code package that producers queue
+// tasks to by Alias. The package has two slots, one live, the alias
+// pointing at it, and one staging. Deploy uploads new code to the staging
+// slot, runs Smoke against it, and only if it passes points the alias at
+// it. The code that was live stays in the other slot, the standby, so
+// Rollback can point the alias back at it. A deploy that fails after its
+// upload leaves no standby, its code having replaced the standby's.
+//
+//	d := w.NewDeploy("resize", aliases)
+//	d.Smoke = &worker.Task{Payload: `{"url": "https://example.com/test.jpg"}`}
+//	res, err := d.Deploy(ctx, "", worker.Code{Image: "acme/resize:1.4"})
+//
+//	ids, err := w.QueueAlias(aliases, "resize", worker.Task{Payload: payload})
+type Deploy struct {
+	Worker  *Worker
+	Aliases AliasStore
+	Alias   string
+	// Smoke is the task run against the staged code, its CodeName is set
+	// to the staging slot's. Without it, staged code goes live untested.
+	Smoke *Task
+	// SmokeTimeout is how long the smoke task may take, 5 minutes by
+	// default. It is cancelled if it takes longer.
+	SmokeTimeout time.Duration
+	// Verify, if set, checks the completed smoke task further, e.g. its
+	// result, and fails the deploy by returning an error.
+	Verify func(TaskInfo) error
+}
+
+// DeployResult tells what a Deploy did.
+type DeployResult struct {
+	// Staged is the code name the code was uploaded to. Live and Previous
+	// are the code names the alias points at after and before the deploy.
+	Staged   string
+	Live     string
+	Previous string
+	// Smoke is the smoke task, if any.
+	Smoke TaskInfo
+}
+
+// NewDeploy returns a Deploy of the code package producers queue to by
+// alias in aliases.
+func (w *Worker) NewDeploy(alias string, aliases AliasStore) *Deploy {
+	return &Deploy{Worker: w, Aliases: aliases, Alias: alias}
+}
+
+// Slot returns the code name of the slot, SlotBlue or SlotGreen.
+func (d *Deploy) Slot(slot string) string {
+	return d.Alias + "-" + slot
+}
+
+// Live returns the code name the alias points at, "" before the first
+// deploy.
+func (d *Deploy) Live() (string, error) {
+	return d.Aliases.Get(d.Alias)
+}
+
+// Deploy uploads code, with the zip at zipName unless it is empty, to the
+// staging slot, the code's Name being set to the slot's, and runs the smoke
+// task on it. If the smoke task completed, and Verify accepted it, the
+// alias is pointed at the staging slot, which becomes live. Otherwise the
+// alias is left alone and an error wrapping ErrSmokeFailed is returned.
+func (d *Deploy) Deploy(ctx context.Context, zipName string, code Code) (DeployResult, error) {
+	var res DeployResult
+	live, err := d.Live()
+	if err != nil {
+		return res, fmt.Errorf("resolving alias %s: %w", d.Alias, err)
+	}
+	res.Previous, res.Live = live, live
+	res.Staged = d.Slot(SlotBlue)
+	if live == res.Staged {
+		res.Staged = d.Slot(SlotGreen)
+	}
+
+	// the upload replaces the standby's code
+	if err := d.Aliases.Set(d.standbyAlias(), ""); err != nil {
+		return res, fmt.Errorf("clearing standby of %s: %w", d.Alias, err)
+	}
+	code.Name = res.Staged
+	if _, err := d.Worker.CodePackageZipUpload(zipName, code); err != nil {
+		return res, fmt.Errorf("uploading %s: %w", res.Staged, err)
+	}
+	if d.Smoke != nil {
+		if res.Smoke, err = d.smoke(ctx, res.Staged); err != nil {
+			return res, err
+		}
+	}
+	if err := d.Aliases.Set(d.Alias, res.Staged); err != nil {
+		return res, fmt.Errorf("pointing alias %s at %s: %w", d.Alias, res.Staged, err)
+	}
+	res.Live = res.Staged
+	if live != "" {
+		if err := d.Aliases.Set(d.standbyAlias(), live); err != nil {
+			return res, fmt.Errorf("setting standby of %s: %w", d.Alias, err)
+		}
+	}
+	return res, nil
+}
+
+// Rollback points the alias back at the standby, the code live before the
+// last Deploy, and returns its code name. The code rolled back from becomes
+// the standby. It fails if there is no standby, before the second deploy or
+// after a failed one.
+func (d *Deploy) Rollback() (string, error) {
+	live, err := d.Live()
+	if err != nil {
+		return "", fmt.Errorf("resolving alias %s: %w", d.Alias, err)
+	}
+	standby, err := d.Aliases.Get(d.standbyAlias())
+	if err != nil {
+		return "", fmt.Errorf("resolving standby of %s: %w", d.Alias, err)
+	}
+	if standby == "" {
+		return "", fmt.Errorf("%s has no standby code to roll back to", d.Alias)
+	}
+	if err := d.Aliases.Set(d.Alias, standby); err != nil {
+		return "", fmt.Errorf("pointing alias %s at %s: %w", d.Alias, standby, err)
+	}
+	if err := d.Aliases.Set(d.standbyAlias(), live); err != nil {
+		return standby, fmt.Errorf("setting standby of %s: %w", d.Alias, err)
+	}
+	return standby, nil
+}
+
+// standbyAlias is the alias of the slot Rollback goes back to.
+func (d *Deploy) standbyAlias() string {
+	return d.Alias + ".standby"
+}
+
+// smoke runs the smoke task against codeName.
+func (d *Deploy) smoke(ctx context.Context, codeName string) (TaskInfo, error) {
+	t := *d.Smoke
+	t.CodeName = codeName
+	ids, err := d.Worker.TaskQueue(t)
+	if err != nil {
+		return TaskInfo{}, fmt.Errorf("queueing smoke task: %w", err)
+	}
+	if len(ids) == 0 {
+		return TaskInfo{}, errors.New("queueing smoke task: no task id returned")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, d.smokeTimeout())
+	defer cancel()
+	info, err := d.Worker.waitTask(ctx, ids[0])
+	if err != nil {
+		d.Worker.TaskCancel(ids[0])
+		return info, fmt.Errorf("%w: waiting for task %s: %w", ErrSmokeFailed, ids[0], err)
+	}
+	if info.Status != StatusComplete {
+		return info, fmt.Errorf("%w: task %s finished with status %s: %s", ErrSmokeFailed, info.Id, info.Status, info.Msg)
+	}
+	if d.Verify != nil {
+		if err := d.Verify(info); err != nil {
+			return info, fmt.Errorf("%w: task %s: %w", ErrSmokeFailed, info.Id, err)
+		}
+	}
+	return info, nil
+}
+
+func (d *Deploy) smokeTimeout() time.Duration {
+	if d.SmokeTimeout > 0 {
+		return d.SmokeTimeout
+	}
+	return 5 * time.Minute
+}
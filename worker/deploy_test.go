@@ -0,0 +1,90 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/iron-io/iron_go3/irontest"
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestDeploy(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("blue/green deploys", func() {
+		srv := irontest.NewServer()
+		defer srv.Close()
+		w := &Worker{Settings: srv.Settings("iron_worker")}
+		images := map[string]string{}
+		for _, slot := range []string{"resize-blue", "resize-green"} {
+			slot := slot
+			srv.Worker.Handle(slot, func(payload string) (string, error) {
+				code, _ := srv.Worker.Code(slot)
+				if code.Image == "acme/resize:broken" {
+					return "", errors.New("broken")
+				}
+				images[slot] = code.Image
+				return "ok", nil
+			})
+		}
+
+		aliases := &MemoryAliases{}
+		d := w.NewDeploy("resize", aliases)
+		d.Smoke = &Task{Payload: "test.jpg"}
+		d.SmokeTimeout = time.Second
+		ctx := context.Background()
+
+		It("makes the smoke-tested code live", func() {
+			res, err := d.Deploy(ctx, "", Code{Image: "acme/resize:1"})
+			Expect(err, ToBeNil)
+			Expect(res.Previous, ToEqual, "")
+			Expect(res.Live, ToEqual, "resize-blue")
+			Expect(res.Smoke.Status, ToEqual, StatusComplete)
+
+			res, err = d.Deploy(ctx, "", Code{Image: "acme/resize:2"})
+			Expect(err, ToBeNil)
+			Expect(res.Previous, ToEqual, "resize-blue")
+			Expect(res.Live, ToEqual, "resize-green")
+			Expect(images["resize-green"], ToEqual, "acme/resize:2")
+
+			ids, err := w.QueueAlias(aliases, "resize", Task{Payload: "cat.jpg"})
+			Expect(err, ToBeNil)
+			info, err := w.TaskInfo(ids[0])
+			Expect(err, ToBeNil)
+			Expect(info.CodeName, ToEqual, "resize-green")
+		})
+
+		It("rolls back to the standby slot", func() {
+			previous, err := d.Rollback()
+			Expect(err, ToBeNil)
+			Expect(previous, ToEqual, "resize-blue")
+			live, _ := d.Live()
+			Expect(live, ToEqual, "resize-blue")
+
+			previous, err = d.Rollback()
+			Expect(err, ToBeNil)
+			Expect(previous, ToEqual, "resize-green")
+		})
+
+		It("keeps the live code when the smoke task fails", func() {
+			res, err := d.Deploy(ctx, "", Code{Image: "acme/resize:broken"})
+			Expect(errors.Is(err, ErrSmokeFailed), ToBeTrue)
+			Expect(res.Staged, ToEqual, "resize-blue")
+			live, _ := d.Live()
+			Expect(live, ToEqual, "resize-green")
+
+			// the failed deploy replaced the standby's code
+			_, err = d.Rollback()
+			Expect(err != nil, ToBeTrue)
+		})
+
+		It("can't roll back before a deploy", func() {
+			_, err := w.NewDeploy("thumbs", &MemoryAliases{}).Rollback()
+			Expect(err != nil, ToBeTrue)
+			_, err = w.QueueAlias(&MemoryAliases{}, "thumbs", Task{})
+			Expect(err != nil, ToBeTrue)
+		})
+	})
+}
@@ -0,0 +1,180 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// GroupMode decides how TaskGroup.WaitAll reacts to a failed task.
+type GroupMode int
+
+const (
+	// CollectAll waits for every task to finish and reports all failures.
+	CollectAll GroupMode = iota
+	// FailFast stops waiting at the first failure and cancels the tasks that
+	// haven't finished yet.
+	FailFast
+)
+
+// A TaskGroup queues a batch of tasks and waits for all of them to finish.
+// See Worker.NewTaskGroup.
+type TaskGroup struct {
+	Worker *Worker
+	Mode   GroupMode
+	// PollConcurrency is the number of task info requests in flight while
+	// waiting, defaults to 10.
+	PollConcurrency int
+
+	mu  sync.Mutex
+	ids []string
+}
+
+// GroupResult holds the final info of every task in a group, in the order
+// they were queued. Tasks that were still pending when WaitAll returned early
+// are left out.
+type GroupResult struct {
+	Tasks  []TaskInfo
+	Failed []TaskInfo
+}
+
+// GroupError is returned by WaitAll when one or more tasks in the group failed.
+type GroupError struct {
+	Failed []TaskInfo
+}
+
+func (e *GroupError) Error() string {
+	if len(e.Failed) == 1 {
+		return fmt.Sprintf("task %s finished with status %s", e.Failed[0].Id, e.Failed[0].Status)
+	}
+	return fmt.Sprintf("%d tasks in group failed, first was %s with status %s",
+		len(e.Failed), e.Failed[0].Id, e.Failed[0].Status)
+}
+
+func (w *Worker) NewTaskGroup(mode GroupMode) *TaskGroup {
+	return &TaskGroup{Worker: w, Mode: mode}
+}
+
+// Queue queues tasks as part of the group. It may be called more than once
+// before WaitAll.
+func (g *TaskGroup) Queue(tasks ...Task) ([]string, error) {
+	ids, err := g.Worker.TaskQueue(tasks...)
+	if err != nil {
+		return nil, err
+	}
+	g.mu.Lock()
+	g.ids = append(g.ids, ids...)
+	g.mu.Unlock()
+	return ids, nil
+}
+
+// TaskIds returns the ids of all tasks queued by the group so far.
+func (g *TaskGroup) TaskIds() []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]string(nil), g.ids...)
+}
+
+// WaitAll blocks until every task in the group has finished, ctx is done or,
+// in FailFast mode, a task fails. A *GroupError is returned if any task
+// failed.
+func (g *TaskGroup) WaitAll(ctx context.Context) (GroupResult, error) {
+	ids := g.TaskIds()
+	done := make(map[string]TaskInfo, len(ids))
+	pending := append([]string(nil), ids...)
+	retryDelay := 100 * time.Millisecond
+
+	for len(pending) > 0 {
+		infos, err := g.poll(ctx, pending)
+		if err != nil {
+			return g.result(ids, done), err
+		}
+
+		var failed bool
+		next := pending[:0]
+		for i, id := range pending {
+			if !infos[i].Status.IsTerminal() {
+				next = append(next, id)
+				continue
+			}
+			done[id] = infos[i]
+			failed = failed || infos[i].Status.IsFailure()
+		}
+		pending = next
+
+		if failed && g.Mode == FailFast {
+			for _, id := range pending {
+				g.Worker.TaskCancel(id) // best effort, it may have just finished
+			}
+			res := g.result(ids, done)
+			return res, &GroupError{Failed: res.Failed}
+		}
+		if len(pending) == 0 {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return g.result(ids, done), ctx.Err()
+		case <-time.After(retryDelay):
+		}
+		retryDelay = sleepBetweenRetries(retryDelay)
+	}
+
+	res := g.result(ids, done)
+	if len(res.Failed) > 0 {
+		return res, &GroupError{Failed: res.Failed}
+	}
+	return res, nil
+}
+
+// poll fetches the info for each id, returned in the same order.
+func (g *TaskGroup) poll(ctx context.Context, ids []string) ([]TaskInfo, error) {
+	n := g.PollConcurrency
+	if n < 1 {
+		n = 10
+	}
+
+	infos := make([]TaskInfo, len(ids))
+	errs := make([]error, len(ids))
+	sem := make(chan struct{}, n)
+	var wg sync.WaitGroup
+	for i, id := range ids {
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, id string) {
+			defer func() { <-sem; wg.Done() }()
+			infos[i], errs[i] = g.Worker.TaskInfo(id)
+		}(i, id)
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return infos, nil
+}
+
+func (g *TaskGroup) result(ids []string, done map[string]TaskInfo) GroupResult {
+	var res GroupResult
+	for _, id := range ids {
+		info, ok := done[id]
+		if !ok {
+			continue
+		}
+		res.Tasks = append(res.Tasks, info)
+		if info.Status.IsFailure() {
+			res.Failed = append(res.Failed, info)
+		}
+	}
+	return res
+}
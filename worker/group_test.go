@@ -0,0 +1,147 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/iron-io/iron_go3/config"
+	. "github.com/jeffh/go.bdd"
+)
+
+// testWorker returns a Worker talking to a local server backed by h.
+func testWorker(h http.Handler) (*Worker, func()) {
+	srv := httptest.NewServer(h)
+	host, port, _ := net.SplitHostPort(strings.TrimPrefix(srv.URL, "http://"))
+	p, _ := strconv.Atoi(port)
+	w := &Worker{Settings: config.Settings{
+		Token:      "token",
+		ProjectId:  "project",
+		Host:       host,
+		Port:       uint16(p),
+		Scheme:     "http",
+		ApiVersion: "2",
+	}}
+	return w, srv.Close
+}
+
+// fakeTasks queues tasks whose status is decided by the code name, they run
+// for the given number of polls before reaching it.
+type fakeTasks struct {
+	mu     sync.Mutex
+	polls  int
+	status map[string]TaskStatus
+	left   map[string]int
+	cancel []string
+}
+
+func (f *fakeTasks) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	path := strings.TrimPrefix(r.URL.Path, "/2/projects/project/tasks")
+	switch {
+	case r.Method == "POST" && path == "":
+		var in struct {
+			Tasks []Task `json:"tasks"`
+		}
+		json.NewDecoder(r.Body).Decode(&in)
+		var out struct {
+			Tasks []map[string]string `json:"tasks"`
+		}
+		for _, t := range in.Tasks {
+			id := fmt.Sprint(len(f.status))
+			f.status[id] = TaskStatus(t.CodeName)
+			f.left[id] = t.Priority
+			out.Tasks = append(out.Tasks, map[string]string{"id": id})
+		}
+		json.NewEncoder(w).Encode(out)
+	case r.Method == "POST" && strings.HasSuffix(path, "/cancel"):
+		f.cancel = append(f.cancel, strings.Split(path, "/")[1])
+		w.Write([]byte(`{}`))
+	case r.Method == "GET":
+		id := strings.TrimPrefix(path, "/")
+		f.polls++
+		status := StatusRunning
+		if f.left[id]--; f.left[id] < 0 {
+			status = f.status[id]
+		}
+		json.NewEncoder(w).Encode(TaskInfo{Id: id, Status: status})
+	}
+}
+
+func TestTaskGroup(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("task groups", func() {
+		newFake := func() *fakeTasks {
+			return &fakeTasks{status: map[string]TaskStatus{}, left: map[string]int{}}
+		}
+
+		It("waits for every task", func() {
+			f := newFake()
+			w, done := testWorker(f)
+			defer done()
+
+			g := w.NewTaskGroup(CollectAll)
+			_, err := g.Queue(Task{CodeName: "complete", Priority: 2}, Task{CodeName: "complete"})
+			Expect(err, ToBeNil)
+
+			res, err := g.WaitAll(context.Background())
+			Expect(err, ToBeNil)
+			Expect(len(res.Tasks), ToEqual, 2)
+			Expect(res.Tasks[0].Id, ToEqual, "0")
+			Expect(res.Tasks[1].Status, ToEqual, StatusComplete)
+		})
+
+		It("collects every failure", func() {
+			f := newFake()
+			w, done := testWorker(f)
+			defer done()
+
+			g := w.NewTaskGroup(CollectAll)
+			g.Queue(Task{CodeName: "error"}, Task{CodeName: "complete", Priority: 1}, Task{CodeName: "timeout", Priority: 2})
+
+			res, err := g.WaitAll(context.Background())
+			gerr, ok := err.(*GroupError)
+			Expect(ok, ToBeTrue)
+			Expect(len(gerr.Failed), ToEqual, 2)
+			Expect(len(res.Tasks), ToEqual, 3)
+		})
+
+		It("cancels the rest when failing fast", func() {
+			f := newFake()
+			w, done := testWorker(f)
+			defer done()
+
+			g := w.NewTaskGroup(FailFast)
+			g.Queue(Task{CodeName: "error"}, Task{CodeName: "complete", Priority: 5})
+
+			res, err := g.WaitAll(context.Background())
+			_, ok := err.(*GroupError)
+			Expect(ok, ToBeTrue)
+			Expect(len(res.Tasks), ToEqual, 1)
+			Expect(f.cancel, ToDeepEqual, []string{"1"})
+		})
+
+		It("stops when the context is done", func() {
+			f := newFake()
+			w, done := testWorker(f)
+			defer done()
+
+			g := w.NewTaskGroup(CollectAll)
+			g.Queue(Task{CodeName: "complete", Priority: 1000})
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			_, err := g.WaitAll(ctx)
+			Expect(err, ToEqual, context.Canceled)
+		})
+	})
+}
@@ -0,0 +1,102 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// A Heartbeat periodically reports the progress of the running task, so
+// long jobs show they are alive in the HUD and in TaskInfo.
+//
+// IronWorker has no call to extend the timeout of a running task, the limit
+// has to be raised with Task.Timeout when queueing it.
+type Heartbeat struct {
+	w        *Worker
+	taskId   string
+	interval time.Duration
+
+	mu      sync.Mutex
+	percent int
+	msg     string
+	err     error
+	stop    context.CancelFunc
+	done    chan struct{}
+}
+
+// DefaultHeartbeatInterval is how often a Heartbeat started without an
+// interval reports.
+const DefaultHeartbeatInterval = 30 * time.Second
+
+// StartHeartbeat reports progress for the current task (see ParseFlags)
+// every interval, DefaultHeartbeatInterval if it isn't positive, until ctx
+// is done or Stop is called. When not running as a task, nothing is
+// reported.
+func StartHeartbeat(ctx context.Context, w *Worker, interval time.Duration) *Heartbeat {
+	if interval <= 0 {
+		interval = DefaultHeartbeatInterval
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	h := &Heartbeat{w: w, taskId: IronTaskId(), interval: interval, stop: cancel, done: make(chan struct{})}
+	go h.run(ctx)
+	return h
+}
+
+func (h *Heartbeat) run(ctx context.Context) {
+	defer close(h.done)
+	if h.taskId == "" {
+		<-ctx.Done()
+		return
+	}
+
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			h.beat()
+		}
+	}
+}
+
+func (h *Heartbeat) beat() {
+	h.mu.Lock()
+	percent, msg := h.percent, h.msg
+	h.mu.Unlock()
+
+	err := h.w.TaskProgress(h.taskId, percent, msg)
+
+	h.mu.Lock()
+	h.err = err
+	h.mu.Unlock()
+}
+
+// Progress sets the percentage and message sent with the next beats and
+// reports them right away.
+func (h *Heartbeat) Progress(percent int, msg string) error {
+	h.mu.Lock()
+	h.percent, h.msg = clamp(percent, 0, 100), msg
+	h.mu.Unlock()
+
+	if h.taskId == "" {
+		return nil
+	}
+	h.beat()
+	return h.Err()
+}
+
+// Err returns the error of the last report, if any. Failed reports don't
+// stop the heartbeat.
+func (h *Heartbeat) Err() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.err
+}
+
+// Stop stops the heartbeat and waits for a report in flight to finish.
+func (h *Heartbeat) Stop() {
+	h.stop()
+	<-h.done
+}
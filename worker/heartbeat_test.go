@@ -0,0 +1,68 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/iron-io/iron_go3/irontest"
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestHeartbeat(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("task heartbeats", func() {
+		srv := irontest.NewServer()
+		defer srv.Close()
+		w := &Worker{Settings: srv.Settings("iron_worker")}
+		_, err := w.CodePackageUpload(Code{Name: "beating", Image: "iron/hello"})
+		Expect(err, ToBeNil)
+		ids, err := w.TaskQueue(Task{CodeName: "beating"})
+		Expect(err, ToBeNil)
+
+		defer func(id string) { TaskId = id }(TaskId)
+		TaskId = ids[0]
+
+		It("reports progress right away and on every beat", func() {
+			h := StartHeartbeat(context.Background(), w, 20*time.Millisecond)
+			defer h.Stop()
+			Expect(h.Progress(150, "almost"), ToBeNil)
+			task, _ := srv.Worker.Task(ids[0])
+			Expect(task.Percent, ToEqual, 100)
+			Expect(task.Msg, ToEqual, "almost")
+
+			before := srv.Requests()
+			time.Sleep(110 * time.Millisecond)
+			Expect(srv.Requests()-before >= 3, ToBeTrue)
+		})
+
+		It("stops beating when stopped", func() {
+			h := StartHeartbeat(context.Background(), w, 10*time.Millisecond)
+			time.Sleep(30 * time.Millisecond)
+			h.Stop()
+			stopped := srv.Requests()
+			time.Sleep(50 * time.Millisecond)
+			Expect(srv.Requests(), ToEqual, stopped)
+			Expect(h.Err(), ToBeNil)
+		})
+
+		It("defaults invalid intervals", func() {
+			for _, interval := range []time.Duration{0, -time.Second} {
+				h := StartHeartbeat(context.Background(), w, interval)
+				Expect(h.interval, ToEqual, DefaultHeartbeatInterval)
+				h.Stop()
+			}
+		})
+
+		It("reports nothing when not running as a task", func() {
+			TaskId = ""
+			before := srv.Requests()
+			h := StartHeartbeat(context.Background(), w, 10*time.Millisecond)
+			Expect(h.Progress(10, "ignored"), ToBeNil)
+			time.Sleep(30 * time.Millisecond)
+			h.Stop()
+			Expect(srv.Requests(), ToEqual, before)
+		})
+	})
+}
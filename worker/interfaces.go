@@ -0,0 +1,17 @@
+package worker
+
+// API is the task and schedule operations of a Worker, for code that
+// queues tasks to depend on, so it can be tested with a fake, e.g. one of
+// the mocks package.
+type API interface {
+	TaskQueue(tasks ...Task) ([]string, error)
+	TaskInfo(taskId string) (TaskInfo, error)
+	TaskLog(taskId string) ([]byte, error)
+	TaskResult(taskId string, out interface{}) error
+	TaskCancel(taskId string) error
+	FilteredTaskList(params TaskListParams) ([]TaskInfo, error)
+	Schedule(schedules ...Schedule) ([]string, error)
+	ScheduleInfo(scheduleId string) (ScheduleInfo, error)
+	ScheduleCancel(scheduleId string) error
+	CodePackageByName(name string) (CodeInfo, error)
+}
@@ -0,0 +1,40 @@
+package worker
+
+import (
+	"iter"
+
+	"github.com/iron-io/iron_go3/api"
+)
+
+// maxPerPage is the largest page of the listing APIs.
+const maxPerPage = 100
+
+// AllCodePackages returns an iterator over the project's code packages,
+// fetching pages as needed.
+func (w *Worker) AllCodePackages() iter.Seq2[CodeInfo, error] {
+	return api.Pages(maxPerPage, func(page int) ([]CodeInfo, error) {
+		return w.CodePackageList(page, maxPerPage)
+	})
+}
+
+// AllTasks returns an iterator over the tasks matching params, fetching
+// pages as needed. The Page and PerPage of params are ignored.
+func (w *Worker) AllTasks(params TaskListParams) iter.Seq2[TaskInfo, error] {
+	return api.Pages(maxPerPage, func(page int) ([]TaskInfo, error) {
+		params.Page, params.PerPage = page, maxPerPage
+		return w.FilteredTaskList(params)
+	})
+}
+
+// AllSchedules returns an iterator over the project's schedules, fetching
+// pages as needed.
+func (w *Worker) AllSchedules() iter.Seq2[ScheduleInfo, error] {
+	return api.Pages(maxPerPage, func(page int) ([]ScheduleInfo, error) {
+		out := map[string][]ScheduleInfo{}
+		err := w.schedules().
+			QueryAdd("page", "%d", page).
+			QueryAdd("per_page", "%d", maxPerPage).
+			Req("GET", nil, &out)
+		return out["schedules"], err
+	})
+}
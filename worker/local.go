@@ -0,0 +1,140 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// LocalResult is the outcome of a task run by RunLocal.
+type LocalResult struct {
+	TaskId   string
+	Status   TaskStatus // StatusComplete, StatusError, StatusTimeout or StatusCancelled
+	ExitCode int
+	Stdout   []byte
+	Stderr   []byte
+	Duration time.Duration
+}
+
+// A LocalRunner runs a worker on this machine with the same contract the
+// platform uses: the -d, -id, -payload and -config flags (and the TASK_DIR,
+// TASK_ID, PAYLOAD_FILE and CONFIG_FILE env vars) that ParseFlags reads.
+// The zero value is ready to use.
+type LocalRunner struct {
+	// Command to run inside the code dir, defaults to ./worker, the binary
+	// GoCodeRunner runs.
+	Command []string
+	// Config is written to the file passed as -config.
+	Config string
+	// TaskId passed as -id, a random local id by default.
+	TaskId string
+	// EnvVars are delivered like Task.EnvVars.
+	EnvVars map[string]string
+	// Timeout kills the task if it runs longer, like Task.Timeout.
+	Timeout time.Duration
+	// Stdout and Stderr, if set, also receive the output as it is written.
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// RunLocal runs the worker in codeDir with payload, see LocalRunner.
+func RunLocal(codeDir, payload string) (LocalResult, error) {
+	var r LocalRunner
+	return r.Run(context.Background(), codeDir, payload)
+}
+
+// Run runs the worker in codeDir with payload. An error is only returned if
+// the worker couldn't be started, a failing worker is reported in the
+// result's Status and ExitCode.
+func (r *LocalRunner) Run(ctx context.Context, codeDir, payload string) (LocalResult, error) {
+	codeDir, err := filepath.Abs(codeDir)
+	if err != nil {
+		return LocalResult{}, err
+	}
+	taskId := r.TaskId
+	if taskId == "" {
+		taskId = "local-" + randomBoundary()[:12]
+	}
+	command := r.Command
+	if len(command) == 0 {
+		command = []string{"./worker"}
+	}
+
+	tmp, err := ioutil.TempDir("", "iron-worker-"+taskId)
+	if err != nil {
+		return LocalResult{}, err
+	}
+	defer os.RemoveAll(tmp)
+
+	payloadFile := filepath.Join(tmp, "payload.json")
+	if err := ioutil.WriteFile(payloadFile, []byte(payload), 0600); err != nil {
+		return LocalResult{}, err
+	}
+	configFile := filepath.Join(tmp, "config.json")
+	if err := ioutil.WriteFile(configFile, []byte(r.Config), 0600); err != nil {
+		return LocalResult{}, err
+	}
+
+	if r.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.Timeout)
+		defer cancel()
+	}
+
+	args := append(append([]string(nil), command[1:]...), "-d", codeDir, "-e", "local", "-id", taskId,
+		"-payload", payloadFile, "-config", configFile)
+	cmd := exec.CommandContext(ctx, command[0], args...)
+	cmd.Dir = codeDir
+	cmd.WaitDelay = time.Second // don't wait on children holding the output open
+	cmd.Env = append(os.Environ(),
+		"TASK_ID="+taskId,
+		"TASK_DIR="+codeDir,
+		"PAYLOAD_FILE="+payloadFile,
+		"CONFIG_FILE="+configFile,
+	)
+	for k, v := range r.EnvVars {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if r.Stdout != nil {
+		cmd.Stdout = io.MultiWriter(&stdout, r.Stdout)
+	}
+	if r.Stderr != nil {
+		cmd.Stderr = io.MultiWriter(&stderr, r.Stderr)
+	}
+
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		return LocalResult{}, fmt.Errorf("starting worker in %s: %w", codeDir, err)
+	}
+	err = cmd.Wait()
+
+	res := LocalResult{
+		TaskId:   taskId,
+		Status:   StatusComplete,
+		Stdout:   stdout.Bytes(),
+		Stderr:   stderr.Bytes(),
+		Duration: time.Since(start),
+	}
+	if err != nil {
+		res.Status = StatusError
+		res.ExitCode = -1
+		if exit, ok := err.(*exec.ExitError); ok {
+			res.ExitCode = exit.ExitCode()
+		}
+		switch ctx.Err() {
+		case context.DeadlineExceeded:
+			res.Status = StatusTimeout
+		case context.Canceled:
+			res.Status = StatusCancelled
+		}
+	}
+	return res, nil
+}
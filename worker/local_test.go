@@ -0,0 +1,54 @@
+package worker
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestRunLocal(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("running workers locally", func() {
+		dir, _ := os.Getwd()
+
+		It("passes the payload, config and id like the platform", func() {
+			r := LocalRunner{
+				Command: []string{"sh", "-c", `cat "$PAYLOAD_FILE"; echo " $DB"; echo "$(cat $CONFIG_FILE) $TASK_ID $5" >&2; exit 3`},
+				Config:  `{"b":2}`,
+				TaskId:  "t1",
+				EnvVars: map[string]string{"DB": "mem"},
+			}
+			res, err := r.Run(context.Background(), dir, `{"a":1}`)
+			Expect(err, ToBeNil)
+			Expect(res.Status, ToEqual, StatusError)
+			Expect(res.ExitCode, ToEqual, 3)
+			Expect(string(res.Stderr), ToEqual, `{"b":2} t1 t1`+"\n")
+
+			Expect(string(res.Stdout), ToEqual, `{"a":1} mem`+"\n")
+		})
+
+		It("times out", func() {
+			r := LocalRunner{Command: []string{"sh", "-c", "sleep 5"}, Timeout: 50 * time.Millisecond}
+			res, err := r.Run(context.Background(), dir, "")
+			Expect(err, ToBeNil)
+			Expect(res.Status, ToEqual, StatusTimeout)
+		})
+
+		It("leaves the command alone", func() {
+			command := append(make([]string, 0, 16), "sh", "-c", "exit 0")
+			r := LocalRunner{Command: command}
+			_, err := r.Run(context.Background(), dir, "")
+			Expect(err, ToBeNil)
+			Expect(command[:cap(command)], ToDeepEqual, append([]string{"sh", "-c", "exit 0"}, make([]string, 13)...))
+		})
+
+		It("fails when the worker can't start", func() {
+			_, err := RunLocal(dir, "")
+			Expect(err, ToNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,15 @@
+package worker
+
+import "log/slog"
+
+// Logger receives the records the package logs while running a task, e.g.
+// from Main and ParseFlags. If nil they go to slog.Default, which writes
+// to the task's log.
+var Logger *slog.Logger
+
+func logger() *slog.Logger {
+	if Logger != nil {
+		return Logger
+	}
+	return slog.Default()
+}
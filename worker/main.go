@@ -0,0 +1,135 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// ErrCancelledByPlatform is the cause of the context of Main's function
+// when the platform stops the task, e.g. because it was cancelled or timed
+// out, see context.Cause.
+var ErrCancelledByPlatform = errors.New("cancelled by platform")
+
+// MainOptions configure MainWith. Zero values take the defaults.
+type MainOptions struct {
+	// GracePeriod is how long the function gets to return once the
+	// platform stopped the task, before Main exits anyway, 10 seconds by
+	// default. It must be shorter than the platform's own grace period
+	// before it kills the task.
+	GracePeriod time.Duration
+	// ReportCancelled writes a PlatformCancellation as the task's result
+	// when the platform stops it, replacing any result written before,
+	// see WriteResult.
+	ReportCancelled bool
+}
+
+// A PlatformCancellation is the result of a task the platform stopped,
+// see MainOptions.ReportCancelled.
+type PlatformCancellation struct {
+	Status string `json:"status"`
+	Signal string `json:"signal"`
+	// Returned tells whether the function returned within the grace
+	// period, and Err what it returned.
+	Returned bool   `json:"returned"`
+	Err      string `json:"error,omitempty"`
+}
+
+// Main runs the task's main function with the default options, see
+// MainWith.
+func Main(fn func(ctx context.Context) error) {
+	MainWith(MainOptions{}, fn)
+}
+
+// MainWith parses the task's flags (see ParseFlags), runs fn, and exits
+// with status 1 if it failed. When the platform stops the task with
+// SIGTERM, or on an interrupt, fn's context is cancelled with
+// ErrCancelledByPlatform as its cause, so it can checkpoint before the
+// task dies, and Main exits once it returns or after GracePeriod.
+//
+//	func main() {
+//		worker.MainWith(worker.MainOptions{GracePeriod: 5 * time.Second}, func(ctx context.Context) error {
+//			for _, item := range items {
+//				if ctx.Err() != nil {
+//					return checkpoint(item)
+//				}
+//				...
+//			}
+//			return nil
+//		})
+//	}
+func MainWith(opts MainOptions, fn func(ctx context.Context) error) {
+	ParseFlags()
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGTERM, os.Interrupt)
+	code := runMain(opts, fn, sigs)
+	signal.Stop(sigs)
+	os.Exit(code)
+}
+
+// runMain runs fn until it returns, or a signal arrives on sigs and the
+// grace period passed, and returns the process's exit status.
+func runMain(opts MainOptions, fn func(ctx context.Context) error, sigs <-chan os.Signal) int {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	done := make(chan error, 1)
+	go func() { done <- fn(ctx) }()
+
+	var sig os.Signal
+	select {
+	case err := <-done:
+		return exitStatus(err)
+	case sig = <-sigs:
+	}
+	logger().Info("worker: stopping", "signal", sig, "grace_period", opts.gracePeriod())
+	cancel(fmt.Errorf("%w: %s", ErrCancelledByPlatform, sig))
+
+	c := PlatformCancellation{Status: ErrCancelledByPlatform.Error(), Signal: sig.String()}
+	status := signalStatus(sig)
+	t := time.NewTimer(opts.gracePeriod())
+	defer t.Stop()
+	select {
+	case err := <-done:
+		c.Returned = true
+		if err != nil {
+			c.Err = err.Error()
+		}
+		status = exitStatus(err)
+	case <-t.C:
+		logger().Warn("worker: still running after the grace period, exiting", "grace_period", opts.gracePeriod())
+	}
+	if opts.ReportCancelled {
+		if err := WriteResult(c); err != nil {
+			logger().Error("worker: could not write the cancellation result", "err", err)
+		}
+	}
+	return status
+}
+
+func exitStatus(err error) int {
+	if err != nil {
+		logger().Error("worker: task failed", "err", err)
+		return 1
+	}
+	return 0
+}
+
+// signalStatus is the exit status of a process killed by sig, as shells
+// report it.
+func signalStatus(sig os.Signal) int {
+	if s, ok := sig.(syscall.Signal); ok {
+		return 128 + int(s)
+	}
+	return 128 + int(syscall.SIGTERM)
+}
+
+func (o MainOptions) gracePeriod() time.Duration {
+	if o.GracePeriod > 0 {
+		return o.GracePeriod
+	}
+	return 10 * time.Second
+}
@@ -0,0 +1,83 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestMainWith(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("the task harness", func() {
+		var out bytes.Buffer
+		ResultWriter = &out
+		defer func() { ResultWriter = os.Stdout }()
+		opts := MainOptions{GracePeriod: 100 * time.Millisecond, ReportCancelled: true}
+
+		It("exits with the function's status", func() {
+			sigs := make(chan os.Signal)
+			Expect(runMain(opts, func(ctx context.Context) error { return nil }, sigs), ToEqual, 0)
+			Expect(runMain(opts, func(ctx context.Context) error { return errors.New("failed") }, sigs), ToEqual, 1)
+			Expect(out.Len(), ToEqual, 0)
+		})
+
+		It("cancels the function when the platform stops the task", func() {
+			sigs := make(chan os.Signal, 1)
+			sigs <- syscall.SIGTERM
+			var cause error
+			status := runMain(opts, func(ctx context.Context) error {
+				<-ctx.Done()
+				cause = context.Cause(ctx)
+				return nil // checkpointed
+			}, sigs)
+			Expect(status, ToEqual, 0)
+			Expect(errors.Is(cause, ErrCancelledByPlatform), ToBeTrue)
+
+			var c PlatformCancellation
+			result, _ := findResult(out.Bytes())
+			Expect(json.Unmarshal(result, &c), ToBeNil)
+			Expect(c, ToEqual, PlatformCancellation{Status: "cancelled by platform", Signal: "terminated", Returned: true})
+		})
+
+		It("exits after the grace period", func() {
+			out.Reset()
+			sigs := make(chan os.Signal, 1)
+			sigs <- syscall.SIGTERM
+			start := time.Now()
+			status := runMain(opts, func(ctx context.Context) error { select {} }, sigs)
+			Expect(status, ToEqual, 143)
+			Expect(time.Since(start) >= opts.GracePeriod, ToBeTrue)
+
+			var c PlatformCancellation
+			result, _ := findResult(out.Bytes())
+			Expect(json.Unmarshal(result, &c), ToBeNil)
+			Expect(c.Returned, ToEqual, false)
+		})
+
+		It("exits with the status of the signal", func() {
+			sigs := make(chan os.Signal, 1)
+			sigs <- os.Interrupt
+			status := runMain(opts, func(ctx context.Context) error { select {} }, sigs)
+			Expect(status, ToEqual, 130)
+		})
+
+		It("logs to the package's logger", func() {
+			var logs bytes.Buffer
+			Logger = slog.New(slog.NewTextHandler(&logs, nil))
+			defer func() { Logger = nil }()
+			sigs := make(chan os.Signal)
+			runMain(opts, func(ctx context.Context) error { return errors.New("failed") }, sigs)
+			Expect(strings.Contains(logs.String(), "level=ERROR msg=\"worker: task failed\" err=failed"), ToBeTrue)
+		})
+	})
+}
@@ -2,6 +2,7 @@ package worker
 
 import (
 	"archive/zip"
+	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
@@ -9,13 +10,12 @@ import (
 	"crypto/sha1"
 	"crypto/x509"
 	"encoding/base64"
-	"encoding/json"
 	"encoding/pem"
 	"fmt"
 	"io"
-	"io/ioutil"
-	"mime/multipart"
 	"time"
+
+	"github.com/iron-io/iron_go3/api"
 )
 
 type Schedule struct {
@@ -49,6 +49,13 @@ type ScheduleInfo struct {
 	StartAt        time.Time `json:"start_at"`
 	Status         string    `json:"status"`
 	UpdatedAt      time.Time `json:"updated_at"`
+	Name           string    `json:"name"`
+	Payload        string    `json:"payload"`
+	Priority       int       `json:"priority"`
+	RunEvery       int       `json:"run_every"` // seconds
+	Timeout        int       `json:"timeout"`   // seconds
+	Cluster        string    `json:"cluster"`
+	Label          string    `json:"label"`
 }
 
 type Task struct {
@@ -59,27 +66,38 @@ type Task struct {
 	Delay    *time.Duration `json:"delay"`
 	Cluster  string         `json:"cluster"`
 	Label    string         `json:"label"`
+	// Callback is a URL that will receive a POST with the task's info once it
+	// finishes. See CallbackHandler for the receiving side.
+	Callback string `json:"callback,omitempty"`
+	// EnvVars are set in the task's environment. They are sent apart from
+	// the payload, which the task gets as is, and show in its TaskInfo like
+	// its other settings, so keep secrets in the code package's env vars or
+	// in a payload encrypted with EncryptPayloads.
+	EnvVars map[string]string `json:"env_vars,omitempty"`
 }
 
 type TaskInfo struct {
-	CodeHistoryId string    `json:"code_history_id"`
-	CodeId        string    `json:"code_id"`
-	CodeName      string    `json:"code_name"`
-	CodeRev       string    `json:"code_rev"`
-	Id            string    `json:"id"`
-	Payload       string    `json:"payload"`
-	ProjectId     string    `json:"project_id"`
-	Status        string    `json:"status"`
-	Msg           string    `json:"msg,omitempty"`
-	ScheduleId    string    `json:"schedule_id"`
-	Duration      int       `json:"duration"`
-	RunTimes      int       `json:"run_times"`
-	Timeout       int       `json:"timeout"`
-	Percent       int       `json:"percent,omitempty"`
-	CreatedAt     time.Time `json:"created_at"`
-	UpdatedAt     time.Time `json:"updated_at"`
-	StartTime     time.Time `json:"start_time"`
-	EndTime       time.Time `json:"end_time"`
+	CodeHistoryId string     `json:"code_history_id"`
+	CodeId        string     `json:"code_id"`
+	CodeName      string     `json:"code_name"`
+	CodeRev       string     `json:"code_rev"`
+	Id            string     `json:"id"`
+	Payload       string     `json:"payload"`
+	ProjectId     string     `json:"project_id"`
+	Status        TaskStatus `json:"status"`
+	Msg           string     `json:"msg,omitempty"`
+	ScheduleId    string     `json:"schedule_id"`
+	Cluster       string     `json:"cluster,omitempty"`
+	Duration      int        `json:"duration"`
+	RunTimes      int        `json:"run_times"`
+	Timeout       int        `json:"timeout"`
+	Percent       int        `json:"percent,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	StartTime     time.Time  `json:"start_time"`
+	EndTime       time.Time  `json:"end_time"`
+	// EnvVars are the env vars the task was queued with, see Task.EnvVars.
+	EnvVars map[string]string `json:"env_vars,omitempty"`
 }
 
 type CodeSource map[string][]byte // map[pathInZip]code
@@ -100,6 +118,7 @@ type Code struct {
 	EnvVars         map[string]string `json:"env_vars"`
 	Source          CodeSource        `json:"-"`
 	DefaultPriority int               `json:"default_priority,omitempty"`
+	DefaultCluster  string            `json:"default_cluster,omitempty"` // for tasks naming none
 }
 
 type CodeInfo struct {
@@ -113,6 +132,18 @@ type CodeInfo struct {
 	CreatedAt       time.Time `json:"created_at"`
 	UpdatedAt       time.Time `json:"updated_at"`
 	LatestChange    time.Time `json:"latest_change"`
+
+	// current config of the package
+	Image           string            `json:"image,omitempty"`
+	Command         string            `json:"command,omitempty"`
+	Stack           string            `json:"stack,omitempty"`
+	Config          string            `json:"config,omitempty"`
+	MaxConcurrency  int               `json:"max_concurrency,omitempty"`
+	Retries         *int              `json:"retries,omitempty"`
+	RetriesDelay    *int              `json:"retries_delay,omitempty"` // seconds
+	DefaultPriority int               `json:"default_priority,omitempty"`
+	DefaultCluster  string            `json:"default_cluster,omitempty"`
+	EnvVars         map[string]string `json:"env_vars,omitempty"`
 }
 
 // CodePackageList lists code packages.
@@ -155,6 +186,12 @@ func (w *Worker) CodePackageDownload(codeId string) (code Code, err error) {
 	return out, err
 }
 
+// CodePackageZipDownload writes the zip of a code package to dst.
+// progress, if not nil, is called as it is downloaded.
+func (w *Worker) CodePackageZipDownload(codeId string, dst io.Writer, progress func(api.Progress)) error {
+	return w.download(w.codes(codeId, "download"), dst, progress)
+}
+
 // CodePackageRevisions lists the revisions of a code pacakge
 func (w *Worker) CodePackageRevisions(codeId string) (code Code, err error) {
 	out := Code{}
@@ -167,24 +204,39 @@ func (w *Worker) CodePackageRevisions(codeId string) (code Code, err error) {
 // zipName is an empty string, then the code package will be uploaded without a
 // zip package (see CodePackageUpload).
 func (w *Worker) CodePackageZipUpload(zipName string, args Code) (*Code, error) {
-	return w.codePackageUpload(zipName, args)
+	return w.codePackageUpload(zipName, args, nil)
+}
+
+// CodePackageZipUploadProgress is like CodePackageZipUpload, calling
+// progress as the package is uploaded. The zip is compressed as it is sent,
+// so the total size isn't known.
+func (w *Worker) CodePackageZipUploadProgress(zipName string, args Code, progress func(api.Progress)) (*Code, error) {
+	return w.codePackageUpload(zipName, args, progress)
 }
 
 // CodePackageUpload uploads a code package without a zip file.
 func (w *Worker) CodePackageUpload(args Code) (*Code, error) {
-	return w.codePackageUpload("", args)
+	return w.codePackageUpload("", args, nil)
 }
 
-func (w *Worker) codePackageUpload(zipName string, args Code) (*Code, error) {
-	b := randomBoundary()
-	r := &streamZipPipe{zipName: zipName, args: args, boundary: b}
-	defer r.Close()
+func (w *Worker) codePackageUpload(zipName string, args Code, progress func(api.Progress)) (*Code, error) {
+	data, err := api.JSONPart("data", args)
+	if err != nil {
+		return nil, err
+	}
+	parts := []api.Part{data}
+	if zipName != "" {
+		parts = append(parts, api.Part{Name: "file", Filename: "worker.zip", Write: func(dst io.Writer) error {
+			return rezip(dst, zipName)
+		}})
+	}
 
+	u := w.codes()
+	if progress != nil {
+		u.OnUpload(progress)
+	}
 	var out Code
-	err := w.codes().
-		SetContentType("multipart/form-data; boundary="+b).
-		Req("POST", r, &out)
-
+	err = u.MultipartReq("POST", parts, &out)
 	return &out, err
 }
 
@@ -197,113 +249,31 @@ func randomBoundary() string {
 	return fmt.Sprintf("%x", buf[:])
 }
 
-// implement seek so that we can retry it. not thread safe,
-// Read and Seek must be called in same thread.
-type streamZipPipe struct {
-	zipName  string
-	args     Code
-	boundary string
-
-	r    io.ReadCloser
-	w    io.WriteCloser
-	once bool
-	err  chan error
-}
-
-// safe to call multiple times, implement io.Closer so http will call this
-func (s *streamZipPipe) Close() error {
-	if s.r != nil {
-		return s.r.Close()
-	}
-	return nil
-}
-
-// only seeks to beginning, ignores parameters
-func (s *streamZipPipe) Seek(offset int64, whence int) (int64, error) {
-	// just restart the whole thing, the last pipe should have errored out and been closed
-	s.r, s.w = io.Pipe()
-	s.err = make(chan error, 1)
-	s.once = true
-	go s.pipe()
-	return 0, nil
-}
-
-func (s *streamZipPipe) Read(b []byte) (int, error) {
-	if !s.once {
-		s.once = true
-		s.r, s.w = io.Pipe()
-		s.err = make(chan error, 1)
-		go s.pipe()
-	}
-
-	select {
-	case err := <-s.err:
-		if err != nil {
-			return 0, err // n should get ignored
-		}
-	default:
-	}
-	return s.r.Read(b)
-}
-
-func (s *streamZipPipe) pipe() {
-	defer s.w.Close()
-
-	mWriter := multipart.NewWriter(s.w)
-	mWriter.SetBoundary(s.boundary)
-	mMetaWriter, err := mWriter.CreateFormField("data")
+// rezip writes the files of the zip at zipName to dst as a new zip.
+func rezip(dst io.Writer, zipName string) error {
+	r, err := zip.OpenReader(zipName)
 	if err != nil {
-		s.err <- err
-		return
-	}
-
-	if err := json.NewEncoder(mMetaWriter).Encode(s.args); err != nil {
-		s.err <- err
-		return
+		return err
 	}
+	defer r.Close()
 
-	if s.zipName != "" {
-		r, err := zip.OpenReader(s.zipName)
+	zWriter := zip.NewWriter(dst)
+	for _, f := range r.File {
+		fWriter, err := zWriter.Create(f.Name)
 		if err != nil {
-			s.err <- err
-			return
+			return err
 		}
-		defer r.Close()
-
-		mFileWriter, err := mWriter.CreateFormFile("file", "worker.zip")
+		rc, err := f.Open()
 		if err != nil {
-			s.err <- err
-			return
-		}
-		zWriter := zip.NewWriter(mFileWriter)
-
-		for _, f := range r.File {
-			fWriter, err := zWriter.Create(f.Name)
-			if err != nil {
-				s.err <- err
-				return
-			}
-			rc, err := f.Open()
-			if err != nil {
-				s.err <- err
-				return
-			}
-			_, err = io.Copy(fWriter, rc)
-			rc.Close()
-			if err != nil {
-				s.err <- err
-				return
-			}
+			return err
 		}
-
-		if err := zWriter.Close(); err != nil {
-			s.err <- err
-			return
+		_, err = io.Copy(fWriter, rc)
+		rc.Close()
+		if err != nil {
+			return err
 		}
 	}
-	if err := mWriter.Close(); err != nil {
-		s.err <- err
-	}
+	return zWriter.Close()
 }
 
 func (w *Worker) TaskList() (tasks []TaskInfo, err error) {
@@ -312,7 +282,7 @@ func (w *Worker) TaskList() (tasks []TaskInfo, err error) {
 	if err != nil {
 		return
 	}
-	return out["tasks"], nil
+	return unwrapTaskInfos(out["tasks"]), nil
 }
 
 type TaskListParams struct {
@@ -322,7 +292,7 @@ type TaskListParams struct {
 	PerPage  int
 	FromTime time.Time
 	ToTime   time.Time
-	Statuses []string
+	Statuses []TaskStatus
 }
 
 func (w *Worker) FilteredTaskList(params TaskListParams) (tasks []TaskInfo, err error) {
@@ -352,7 +322,7 @@ func (w *Worker) FilteredTaskList(params TaskListParams) (tasks []TaskInfo, err
 	}
 
 	for _, status := range params.Statuses {
-		url.QueryAdd(status, "%d", true)
+		url.QueryAdd(string(status), "%t", true)
 	}
 
 	err = url.Req("GET", nil, &out)
@@ -361,7 +331,7 @@ func (w *Worker) FilteredTaskList(params TaskListParams) (tasks []TaskInfo, err
 		return
 	}
 
-	return out["tasks"], nil
+	return unwrapTaskInfos(out["tasks"]), nil
 }
 
 // TaskQueue queues a task
@@ -382,6 +352,12 @@ func (w *Worker) TaskQueue(tasks ...Task) (taskIds []string, err error) {
 		if task.Delay != nil {
 			thisTask["delay"] = int64((*task.Delay).Seconds())
 		}
+		if task.Callback != "" {
+			thisTask["callback"] = task.Callback
+		}
+		if len(task.EnvVars) > 0 {
+			thisTask["env_vars"] = task.EnvVars
+		}
 
 		outTasks = append(outTasks, thisTask)
 	}
@@ -411,17 +387,34 @@ func (w *Worker) TaskQueue(tasks ...Task) (taskIds []string, err error) {
 func (w *Worker) TaskInfo(taskId string) (task TaskInfo, err error) {
 	out := TaskInfo{}
 	err = w.tasks(taskId).Req("GET", nil, &out)
+	out.unwrapEnv()
 	return out, err
 }
 
 func (w *Worker) TaskLog(taskId string) (log []byte, err error) {
-	response, err := w.tasks(taskId, "log").Request("GET", nil)
+	var buf bytes.Buffer
+	err = w.TaskLogTo(taskId, &buf, nil)
+	return buf.Bytes(), err
+}
+
+// TaskLogTo writes the log of a task to dst, streaming it rather than
+// holding it in memory, for long logs. progress, if not nil, is called as
+// the log is downloaded.
+func (w *Worker) TaskLogTo(taskId string, dst io.Writer, progress func(api.Progress)) error {
+	return w.download(w.tasks(taskId, "log"), dst, progress)
+}
+
+func (w *Worker) download(u *api.URL, dst io.Writer, progress func(api.Progress)) error {
+	if progress != nil {
+		u.OnDownload(progress)
+	}
+	response, err := u.Request("GET", nil)
 	if err != nil {
-		return
+		return err
 	}
-
-	log, err = ioutil.ReadAll(response.Body)
-	return
+	defer response.Body.Close()
+	_, err = io.Copy(dst, response.Body)
+	return err
 }
 
 // TaskCancel cancels a Task
@@ -441,8 +434,36 @@ func (w *Worker) TaskProgress(taskId string, progress int, msg string) (err erro
 	return
 }
 
-// TaskQueueWebhook queues a Task from a Webhook
-func (w *Worker) TaskQueueWebhook() (err error) { return }
+// WebhookURL returns the URL that queues a task of codeName for every POST
+// it receives, using the request body as the task's payload. The URL carries
+// the project's token, so treat it as a secret.
+func (w *Worker) WebhookURL(codeName string) string {
+	u := w.tasks("webhook").
+		QueryAdd("code_name", "%s", codeName).
+		QueryAdd("oauth", "%s", w.Settings.Token)
+	return u.URL.String()
+}
+
+// TriggerWebhook queues a task of codeName through its webhook, the same way
+// an external system posting to WebhookURL would, and returns the task's id.
+func (w *Worker) TriggerWebhook(codeName string, body []byte) (taskId string, err error) {
+	out := struct {
+		Id  string `json:"id"`
+		Msg string `json:"msg"`
+	}{}
+	err = w.tasks("webhook").
+		QueryAdd("code_name", "%s", codeName).
+		Req("POST", bytes.NewReader(body), &out)
+	return out.Id, err
+}
+
+// TaskQueueWebhook queues a Task from a Webhook.
+//
+// Deprecated: use TriggerWebhook, which also returns the task's id.
+func (w *Worker) TaskQueueWebhook(codeName string, body []byte) (err error) {
+	_, err = w.TriggerWebhook(codeName, body)
+	return
+}
 
 // ScheduleList lists Scheduled Tasks
 func (w *Worker) ScheduleList() (schedules []ScheduleInfo, err error) {
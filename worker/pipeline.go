@@ -0,0 +1,231 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/iron-io/iron_go3/api"
+)
+
+// PipelineStepEnv is the env var telling a pipeline's tasks which step they
+// run, counting from 0.
+const PipelineStepEnv = "IRON_PIPELINE_STEP"
+
+// A Pipeline runs tasks one after the other, each with the result of the
+// previous one as payload, see WriteResult. Steps that write no result pass
+// their own payload on. When a step doesn't complete, e.g. it errors or is
+// cancelled, the pipeline stops and queues OnError, if set, with a
+// PipelineFailure as payload.
+//
+//	p := w.NewPipeline(worker.Task{CodeName: "fetch"}, worker.Task{CodeName: "resize"}, worker.Task{CodeName: "publish"})
+//	p.OnError = &worker.Task{CodeName: "notify-failure"}
+//	res, err := p.Run(ctx, `{"url": "https://example.com/cat.jpg"}`)
+//
+// Run drives the pipeline by polling the tasks. To drive it with callbacks
+// instead, set Callback to the URL Handler is served at and call Start.
+type Pipeline struct {
+	Worker *Worker
+	// Steps are the tasks to run, their Payload is ignored.
+	Steps []Task
+	// OnError, if set, is queued when a step doesn't complete.
+	OnError *Task
+	// Callback is the URL the tasks report to when driven by callbacks.
+	Callback string
+	// Secret, if set, signs Callback, and Handler refuses callbacks to
+	// URLs it didn't sign, see api.SignURL.
+	Secret []byte
+	// OnComplete, if set, is called by Handler with the last step's task
+	// and result once a pipeline driven by callbacks finished.
+	OnComplete func(TaskInfo, json.RawMessage)
+}
+
+// PipelineFailure is the payload of a pipeline's OnError task.
+type PipelineFailure struct {
+	Step     int        `json:"step"`
+	TaskId   string     `json:"task_id"`
+	CodeName string     `json:"code_name"`
+	Status   TaskStatus `json:"status"`
+	Msg      string     `json:"msg,omitempty"`
+	// Payload is the input of the failed step.
+	Payload string `json:"payload"`
+}
+
+// PipelineError is returned by Run when a step didn't complete.
+type PipelineError struct {
+	Step int
+	Task TaskInfo
+}
+
+func (e *PipelineError) Error() string {
+	return fmt.Sprintf("pipeline step %d (%s) finished with status %s", e.Step, e.Task.CodeName, e.Task.Status)
+}
+
+// PipelineResult holds the tasks a pipeline ran and the result of the last.
+type PipelineResult struct {
+	Tasks  []TaskInfo
+	Result json.RawMessage
+}
+
+// NewPipeline returns a Pipeline running steps.
+func (w *Worker) NewPipeline(steps ...Task) *Pipeline {
+	return &Pipeline{Worker: w, Steps: steps}
+}
+
+// Run runs the pipeline with payload as the first step's, polling each
+// task until it finished. A *PipelineError is returned if a step didn't
+// complete.
+func (p *Pipeline) Run(ctx context.Context, payload string) (PipelineResult, error) {
+	var res PipelineResult
+	for i := range p.Steps {
+		id, err := p.queue(i, payload)
+		if err != nil {
+			return res, err
+		}
+		info, err := p.Worker.waitTask(ctx, id)
+		if err != nil {
+			return res, err
+		}
+		res.Tasks = append(res.Tasks, info)
+		if info.Status != StatusComplete {
+			perr := &PipelineError{Step: i, Task: info}
+			if err := p.fail(i, info, payload); err != nil {
+				return res, errors.Join(perr, err)
+			}
+			return res, perr
+		}
+		if payload, res.Result, err = p.next(info.Id, payload); err != nil {
+			return res, err
+		}
+	}
+	return res, nil
+}
+
+// Start queues the first step of a pipeline driven by callbacks and
+// returns its task's id.
+func (p *Pipeline) Start(payload string) (string, error) {
+	if p.Callback == "" {
+		return "", errors.New("pipeline has no Callback to be driven by")
+	}
+	return p.queue(0, payload)
+}
+
+// Handler returns the http.Handler to serve at Callback. It queues the
+// next step of the pipelines whose tasks complete.
+func (p *Pipeline) Handler() http.Handler {
+	return CallbackHandlerWith(CallbackOptions{Secret: p.Secret}, func(tc TaskCompleted) error {
+		info, err := p.Worker.TaskInfo(tc.TaskId)
+		if err != nil {
+			return err
+		}
+		step, err := strconv.Atoi(info.EnvVars[PipelineStepEnv])
+		if err != nil || step < 0 || step >= len(p.Steps) {
+			return fmt.Errorf("task %s is not a step of the pipeline", tc.TaskId)
+		}
+		payload := info.Payload
+
+		if tc.Status != StatusComplete {
+			info.Status, info.Msg = tc.Status, tc.Msg
+			return p.fail(step, info, payload)
+		}
+		next, result, err := p.next(tc.TaskId, payload)
+		if err != nil {
+			return err
+		}
+		if step+1 < len(p.Steps) {
+			_, err = p.queue(step+1, next)
+			return err
+		}
+		if p.OnComplete != nil {
+			p.OnComplete(info, result)
+		}
+		return nil
+	})
+}
+
+// queue queues step i with payload.
+func (p *Pipeline) queue(i int, payload string) (string, error) {
+	t := p.Steps[i]
+	t.Payload = payload
+	t.EnvVars = make(map[string]string, len(p.Steps[i].EnvVars)+1)
+	for k, v := range p.Steps[i].EnvVars {
+		t.EnvVars[k] = v
+	}
+	t.EnvVars[PipelineStepEnv] = strconv.Itoa(i)
+	if p.Callback != "" {
+		t.Callback = p.Callback
+		if p.Secret != nil {
+			signed, err := api.SignURL(p.Callback, p.Secret, callbackTTL)
+			if err != nil {
+				return "", err
+			}
+			t.Callback = signed
+		}
+	}
+	ids, err := p.Worker.TaskQueue(t)
+	if err != nil {
+		return "", fmt.Errorf("queueing pipeline step %d (%s): %w", i, t.CodeName, err)
+	}
+	if len(ids) == 0 {
+		return "", fmt.Errorf("queueing pipeline step %d (%s): no task id returned", i, t.CodeName)
+	}
+	return ids[0], nil
+}
+
+// next returns the payload of the step after the completed task id, which
+// ran with payload, and the task's result.
+func (p *Pipeline) next(id, payload string) (string, json.RawMessage, error) {
+	var result json.RawMessage
+	err := p.Worker.TaskResult(id, &result)
+	if errors.Is(err, ErrNoTaskResult) {
+		return payload, nil, nil
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	return string(result), result, nil
+}
+
+// fail queues OnError, if set, for step i that didn't complete.
+func (p *Pipeline) fail(i int, info TaskInfo, payload string) error {
+	if p.OnError == nil {
+		return nil
+	}
+	failure, err := json.Marshal(PipelineFailure{
+		Step: i, TaskId: info.Id, CodeName: info.CodeName, Status: info.Status, Msg: info.Msg, Payload: payload,
+	})
+	if err != nil {
+		return err
+	}
+	t := *p.OnError
+	t.Payload = string(failure)
+	if _, err := p.Worker.TaskQueue(t); err != nil {
+		return fmt.Errorf("queueing pipeline error handler: %w", err)
+	}
+	return nil
+}
+
+// waitTask polls the task until it finished or ctx is done.
+func (w *Worker) waitTask(ctx context.Context, id string) (TaskInfo, error) {
+	retryDelay := 100 * time.Millisecond
+	for {
+		info, err := w.TaskInfo(id)
+		if err != nil {
+			return info, err
+		}
+		if info.Status.IsTerminal() {
+			w.record(info)
+			return info, nil
+		}
+		select {
+		case <-ctx.Done():
+			return info, ctx.Err()
+		case <-time.After(retryDelay):
+		}
+		retryDelay = sleepBetweenRetries(retryDelay)
+	}
+}
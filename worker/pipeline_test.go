@@ -0,0 +1,129 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/iron-io/iron_go3/api"
+	"github.com/iron-io/iron_go3/irontest"
+	. "github.com/jeffh/go.bdd"
+)
+
+// resultLog returns the log of a task that wrote v with WriteResult.
+func resultLog(v interface{}) string {
+	line, _ := json.Marshal(map[string]interface{}{"iron_task_result": v})
+	return "working\n" + string(line) + "\n"
+}
+
+func TestPipeline(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("pipelines", func() {
+		srv := irontest.NewServer()
+		defer srv.Close()
+		w := &Worker{Settings: srv.Settings("iron_worker")}
+
+		srv.Worker.Handle("double", func(payload string) (string, error) {
+			var n int
+			json.Unmarshal([]byte(payload), &n)
+			return resultLog(2 * n), nil
+		})
+		srv.Worker.Handle("log", func(payload string) (string, error) {
+			return "no result", nil
+		})
+		srv.Worker.Handle("fail", func(payload string) (string, error) {
+			return "", errors.New("boom")
+		})
+		srv.Worker.Handle("on-error", func(payload string) (string, error) { return "", nil })
+
+		It("passes results from step to step", func() {
+			p := w.NewPipeline(Task{CodeName: "double"}, Task{CodeName: "log"}, Task{CodeName: "double"})
+			res, err := p.Run(context.Background(), "3")
+			Expect(err, ToBeNil)
+			Expect(len(res.Tasks), ToEqual, 3)
+			Expect(string(res.Result), ToEqual, "12")
+			Expect(res.Tasks[1].Payload, ToEqual, "6")
+		})
+
+		It("routes failures to the error handler", func() {
+			p := w.NewPipeline(Task{CodeName: "double"}, Task{CodeName: "fail"}, Task{CodeName: "double"})
+			p.OnError = &Task{CodeName: "on-error"}
+			res, err := p.Run(context.Background(), "1")
+			var perr *PipelineError
+			Expect(errors.As(err, &perr), ToBeTrue)
+			Expect(perr.Step, ToEqual, 1)
+			Expect(len(res.Tasks), ToEqual, 2)
+
+			handled := srv.Worker.Tasks("on-error")
+			Expect(len(handled), ToEqual, 1)
+			var f PipelineFailure
+			Expect(json.Unmarshal([]byte(handled[0].Payload), &f), ToBeNil)
+			Expect(f.Step, ToEqual, 1)
+			Expect(f.CodeName, ToEqual, "fail")
+			Expect(f.Status, ToEqual, StatusError)
+			Expect(f.Msg, ToEqual, "boom")
+			Expect(f.Payload, ToEqual, "2")
+			Expect(len(srv.Worker.Tasks("double")) >= 1, ToBeTrue)
+		})
+
+		It("can be driven by callbacks", func() {
+			p := w.NewPipeline(Task{CodeName: "double"}, Task{CodeName: "double"})
+			p.Callback = "https://example.com/pipeline"
+			var result json.RawMessage
+			p.OnComplete = func(info TaskInfo, r json.RawMessage) { result = r }
+			h := p.Handler()
+			complete := func(id string) int {
+				info, err := w.waitTask(context.Background(), id)
+				Expect(err, ToBeNil)
+				body, _ := json.Marshal(TaskCompleted{TaskId: id, CodeName: info.CodeName, Status: info.Status})
+				rec := httptest.NewRecorder()
+				h.ServeHTTP(rec, httptest.NewRequest("POST", "/pipeline", strings.NewReader(string(body))))
+				return rec.Code
+			}
+
+			id, err := p.Start("5")
+			Expect(err, ToBeNil)
+			before := len(srv.Worker.Tasks("double"))
+			Expect(complete(id), ToEqual, http.StatusOK)
+			tasks := srv.Worker.Tasks("double")
+			Expect(len(tasks), ToEqual, before+1)
+			last := tasks[len(tasks)-1]
+			Expect(last.Callback, ToEqual, p.Callback)
+			Expect(last.Payload, ToEqual, "10")
+
+			Expect(complete(last.Id), ToEqual, http.StatusOK)
+			Expect(string(result), ToEqual, "20")
+		})
+
+		It("signs its callbacks", func() {
+			p := w.NewPipeline(Task{CodeName: "double"}, Task{CodeName: "double"})
+			p.Callback = "https://example.com/pipeline"
+			p.Secret = []byte("secret")
+			h := p.Handler()
+
+			id, err := p.Start("5")
+			Expect(err, ToBeNil)
+			info, err := w.waitTask(context.Background(), id)
+			Expect(err, ToBeNil)
+			tasks := srv.Worker.Tasks("double")
+			callback, err := url.Parse(tasks[len(tasks)-1].Callback)
+			Expect(err, ToBeNil)
+			Expect(callback.Query().Get(api.SignatureParam) != "", ToBeTrue)
+
+			body, _ := json.Marshal(TaskCompleted{TaskId: id, CodeName: info.CodeName, Status: info.Status})
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest("POST", "/pipeline", strings.NewReader(string(body))))
+			Expect(rec.Code, ToEqual, http.StatusUnauthorized)
+
+			rec = httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest("POST", callback.RequestURI(), strings.NewReader(string(body))))
+			Expect(rec.Code, ToEqual, http.StatusOK)
+		})
+	})
+}
@@ -1,6 +1,7 @@
 package worker
 
 import (
+	"bytes"
 	"encoding/json"
 	"flag"
 	"io"
@@ -14,9 +15,13 @@ var (
 	payloadFlag string
 	TaskId      string
 	configFlag  string
+
+	unwrappedPayload []byte
 )
 
-// call this to parse flags before using the other methods.
+// call this to parse flags before using the other methods. Env vars of
+// tasks queued by earlier versions of the package, wrapped around the
+// payload, are exported into the environment.
 func ParseFlags() {
 	flag.StringVar(&TaskDir, "d", "", "task dir")
 	flag.StringVar(&envFlag, "e", "", "environment type")
@@ -36,9 +41,15 @@ func ParseFlags() {
 	if os.Getenv("CONFIG_FILE") != "" {
 		configFlag = os.Getenv("CONFIG_FILE")
 	}
+	if err := loadTaskEnv(); err != nil {
+		logger().Error("worker: could not load the task's env vars", "err", err)
+	}
 }
 
 func PayloadReader() (io.ReadCloser, error) {
+	if unwrappedPayload != nil {
+		return ioutil.NopCloser(bytes.NewReader(unwrappedPayload)), nil
+	}
 	return os.Open(payloadFlag)
 }
 
@@ -99,3 +110,68 @@ func IronTaskId() string {
 func IronTaskDir() string {
 	return TaskDir
 }
+
+// unwrapEnv gives the info of a task queued in a taskEnvelope its own
+// payload and env vars back.
+func (info *TaskInfo) unwrapEnv() {
+	env, inner, ok := unwrapTaskEnv([]byte(info.Payload))
+	if !ok {
+		return
+	}
+	info.Payload = string(inner)
+	if info.EnvVars == nil {
+		info.EnvVars = env
+	}
+}
+
+func unwrapTaskInfos(tasks []TaskInfo) []TaskInfo {
+	for i := range tasks {
+		tasks[i].unwrapEnv()
+	}
+	return tasks
+}
+
+// taskEnvelope is how earlier versions of the package carried per-task
+// environment variables, wrapped around the payload. Tasks are queued with
+// their env vars apart from the payload now, see Task.EnvVars, but those
+// queued before are still unwrapped.
+type taskEnvelope struct {
+	Env     map[string]string `json:"_iron_env"`
+	Payload string            `json:"_iron_payload"`
+}
+
+// unwrapTaskEnv returns the original payload and env if payload is a
+// taskEnvelope, ok is false for any other payload.
+func unwrapTaskEnv(payload []byte) (env map[string]string, inner []byte, ok bool) {
+	if !bytes.HasPrefix(bytes.TrimSpace(payload), []byte(`{"_iron_env"`)) {
+		return nil, nil, false
+	}
+	var e taskEnvelope
+	if err := json.Unmarshal(payload, &e); err != nil {
+		return nil, nil, false
+	}
+	return e.Env, []byte(e.Payload), true
+}
+
+// loadTaskEnv exports the env vars of a task queued in a taskEnvelope and
+// keeps the unwrapped payload around for PayloadReader.
+func loadTaskEnv() error {
+	if payloadFlag == "" {
+		return nil
+	}
+	b, err := ioutil.ReadFile(payloadFlag)
+	if err != nil {
+		return nil // surfaced by PayloadReader
+	}
+	env, inner, ok := unwrapTaskEnv(b)
+	if !ok {
+		return nil
+	}
+	for k, v := range env {
+		if err := os.Setenv(k, v); err != nil {
+			return err
+		}
+	}
+	unwrappedPayload = inner
+	return nil
+}
@@ -0,0 +1,54 @@
+package worker
+
+import (
+	"testing"
+
+	"github.com/iron-io/iron_go3/irontest"
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestTaskEnv(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("task env vars", func() {
+		srv := irontest.NewServer()
+		defer srv.Close()
+		w := &Worker{Settings: srv.Settings("iron_worker")}
+
+		It("leaves payloads without env alone", func() {
+			_, _, ok := unwrapTaskEnv([]byte(`{"a":1}`))
+			Expect(ok, ToEqual, false)
+		})
+
+		It("sends env vars apart from the payload", func() {
+			ids, err := w.TaskQueue(Task{CodeName: "hello", Payload: `{"a":1}`, EnvVars: map[string]string{"DB_URL": "postgres://"}})
+			Expect(err, ToBeNil)
+			task, _ := srv.Worker.Task(ids[0])
+			Expect(task.Payload, ToEqual, `{"a":1}`)
+			Expect(task.EnvVars, ToDeepEqual, map[string]string{"DB_URL": "postgres://"})
+
+			info, err := w.TaskInfo(ids[0])
+			Expect(err, ToBeNil)
+			Expect(info.EnvVars, ToDeepEqual, map[string]string{"DB_URL": "postgres://"})
+		})
+
+		It("unwraps the payloads of tasks queued by earlier versions", func() {
+			wrapped := `{"_iron_env":{"DB_URL":"postgres://"},"_iron_payload":"{\"a\":1}"}`
+			env, inner, ok := unwrapTaskEnv([]byte(wrapped))
+			Expect(ok, ToBeTrue)
+			Expect(string(inner), ToEqual, `{"a":1}`)
+			Expect(env, ToDeepEqual, map[string]string{"DB_URL": "postgres://"})
+
+			ids, err := w.TaskQueue(Task{CodeName: "legacy", Payload: wrapped})
+			Expect(err, ToBeNil)
+			info, err := w.TaskInfo(ids[0])
+			Expect(err, ToBeNil)
+			Expect(info.Payload, ToEqual, `{"a":1}`)
+			Expect(info.EnvVars, ToDeepEqual, map[string]string{"DB_URL": "postgres://"})
+
+			tasks, err := w.FilteredTaskList(TaskListParams{CodeName: "legacy"})
+			Expect(err, ToBeNil)
+			Expect(tasks[0].Payload, ToEqual, `{"a":1}`)
+		})
+	})
+}
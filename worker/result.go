@@ -0,0 +1,70 @@
+package worker
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+)
+
+// ErrNoTaskResult is returned by TaskResult when the task's log doesn't
+// contain a result written by WriteResult.
+var ErrNoTaskResult = errors.New("task did not write a result")
+
+// resultEnvelope is written on a line of its own to the task's log.
+type resultEnvelope struct {
+	Result json.RawMessage `json:"iron_task_result"`
+}
+
+// ResultWriter is where WriteResult writes, the task's log by default.
+var ResultWriter io.Writer = os.Stdout
+
+// WriteResult is called from inside a task to record its result, which can
+// then be decoded with Worker.TaskResult. If called more than once, the last
+// result wins.
+func WriteResult(v interface{}) error {
+	result, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	line, err := json.Marshal(resultEnvelope{Result: result})
+	if err != nil {
+		return err
+	}
+	_, err = ResultWriter.Write(append([]byte("\n"), append(line, '\n')...))
+	return err
+}
+
+// TaskResult decodes the result the task wrote with WriteResult into out.
+// ErrNoTaskResult is returned if there is none.
+func (w *Worker) TaskResult(taskId string, out interface{}) error {
+	log, err := w.TaskLog(taskId)
+	if err != nil {
+		return err
+	}
+	result, ok := findResult(log)
+	if !ok {
+		return ErrNoTaskResult
+	}
+	return json.Unmarshal(result, out)
+}
+
+func findResult(log []byte) (json.RawMessage, bool) {
+	var found json.RawMessage
+	prefix := []byte(`{"iron_task_result":`)
+	scanner := bufio.NewScanner(bytes.NewReader(log))
+	scanner.Buffer(nil, len(log)+1)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if !bytes.HasPrefix(line, prefix) {
+			continue
+		}
+		var e resultEnvelope
+		if json.Unmarshal(line, &e) == nil && e.Result != nil {
+			found = e.Result
+		}
+	}
+	return found, found != nil
+}
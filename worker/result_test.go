@@ -0,0 +1,51 @@
+package worker
+
+import (
+	"bytes"
+	"net/http"
+	"os"
+	"testing"
+
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestTaskResult(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("task results", func() {
+		type result struct {
+			Rows int    `json:"rows"`
+			File string `json:"file"`
+		}
+
+		It("reads the last result out of the log", func() {
+			var log bytes.Buffer
+			ResultWriter = &log
+			defer func() { ResultWriter = os.Stdout }()
+			log.WriteString("starting up\nhalf way")
+			Expect(WriteResult(result{Rows: 1}), ToBeNil)
+			log.WriteString("more output\n")
+			Expect(WriteResult(result{Rows: 42, File: "out.csv"}), ToBeNil)
+			log.WriteString("bye\n")
+
+			w, done := testWorker(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				rw.Write(log.Bytes())
+			}))
+			defer done()
+
+			var out result
+			Expect(w.TaskResult("abc", &out), ToBeNil)
+			Expect(out, ToEqual, result{Rows: 42, File: "out.csv"})
+		})
+
+		It("tells when there is no result", func() {
+			w, done := testWorker(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				rw.Write([]byte("Hello world!\n"))
+			}))
+			defer done()
+
+			var out result
+			Expect(w.TaskResult("abc", &out), ToEqual, ErrNoTaskResult)
+		})
+	})
+}
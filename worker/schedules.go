@@ -0,0 +1,278 @@
+package worker
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// A ScheduleFile lists a project's schedules, as written by ScheduleExport
+// and read by ScheduleImport, in JSON or YAML:
+//
+//	schedules:
+//	  - name: nightly-report
+//	    code_name: report
+//	    payload: '{"period": "day"}'
+//	    run_every: 86400
+//	    start_at: 2024-01-01T02:00:00Z
+//
+// Schedules are told apart by name, so their names must be unique.
+type ScheduleFile struct {
+	Schedules []ScheduleDef `json:"schedules"`
+}
+
+// A ScheduleDef describes a schedule in a ScheduleFile. Zero fields are
+// left to the API's defaults.
+type ScheduleDef struct {
+	Name           string     `json:"name"`
+	CodeName       string     `json:"code_name"`
+	Payload        string     `json:"payload,omitempty"`
+	Priority       int        `json:"priority,omitempty"`
+	Cluster        string     `json:"cluster,omitempty"`
+	Label          string     `json:"label,omitempty"`
+	RunEvery       int        `json:"run_every,omitempty"` // seconds
+	RunTimes       int        `json:"run_times,omitempty"`
+	Timeout        int        `json:"timeout,omitempty"` // seconds
+	MaxConcurrency int        `json:"max_concurrency,omitempty"`
+	StartAt        *time.Time `json:"start_at,omitempty"`
+	EndAt          *time.Time `json:"end_at,omitempty"`
+}
+
+// ScheduleImportResult tells what ScheduleImport did, by schedule name.
+type ScheduleImportResult struct {
+	Created   []string
+	Replaced  []string
+	Unchanged []string
+	// Cancelled are the schedules pruned for not being in the file.
+	Cancelled []string
+}
+
+// ScheduleExport writes the project's active schedules to out as a
+// ScheduleFile, in format "json" or "yaml".
+func (w *Worker) ScheduleExport(out io.Writer, format string) error {
+	schedules, err := w.activeSchedules()
+	if err != nil {
+		return err
+	}
+	var file ScheduleFile
+	for _, s := range schedules {
+		file.Schedules = append(file.Schedules, scheduleDef(s))
+	}
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	switch format {
+	case "json":
+		data = append(data, '\n')
+	case "yaml":
+		// encode through JSON so the fields take their JSON names
+		var raw interface{}
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return err
+		}
+		if data, err = yaml.Marshal(raw); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown schedule file format %q, expected json or yaml", format)
+	}
+	_, err = out.Write(data)
+	return err
+}
+
+// ScheduleImport makes the project's schedules match the ScheduleFile at
+// path, in JSON or YAML. Schedules missing from the project are created.
+// Those whose settings differ from the ones set in the file are replaced,
+// the settings left out are the API's defaults: the API can't update
+// schedules, so the new one is created before the old one is cancelled.
+// Importing the same file again changes nothing. With prune, the active
+// schedules that aren't in the file are cancelled.
+func (w *Worker) ScheduleImport(path string, prune bool) (ScheduleImportResult, error) {
+	var res ScheduleImportResult
+	file, err := readScheduleFile(path)
+	if err != nil {
+		return res, err
+	}
+	schedules, err := w.activeSchedules()
+	if err != nil {
+		return res, err
+	}
+	existing := map[string][]ScheduleInfo{}
+	for _, s := range schedules {
+		existing[s.Name] = append(existing[s.Name], s)
+	}
+
+	var create []Schedule
+	var cancel []string
+	for _, def := range file.Schedules {
+		current := existing[def.Name]
+		delete(existing, def.Name)
+		if len(current) == 1 && def.matches(scheduleDef(current[0])) {
+			res.Unchanged = append(res.Unchanged, def.Name)
+			continue
+		}
+		if len(current) == 0 {
+			res.Created = append(res.Created, def.Name)
+		} else {
+			res.Replaced = append(res.Replaced, def.Name)
+		}
+		create = append(create, def.Schedule())
+		for _, s := range current {
+			cancel = append(cancel, s.Id)
+		}
+	}
+	if prune {
+		for _, s := range schedules {
+			if _, ok := existing[s.Name]; ok {
+				res.Cancelled = append(res.Cancelled, s.Name)
+				cancel = append(cancel, s.Id)
+			}
+		}
+	}
+
+	if len(create) > 0 {
+		if _, err := w.Schedule(create...); err != nil {
+			return res, fmt.Errorf("creating schedules: %w", err)
+		}
+	}
+	var errs []error
+	for _, id := range cancel {
+		if err := w.ScheduleCancel(id); err != nil {
+			errs = append(errs, fmt.Errorf("cancelling schedule %s: %w", id, err))
+		}
+	}
+	return res, errors.Join(errs...)
+}
+
+// Schedule returns the Schedule to create for d.
+func (d ScheduleDef) Schedule() Schedule {
+	s := Schedule{
+		CodeName: d.CodeName,
+		Name:     d.Name,
+		Payload:  d.Payload,
+		Cluster:  d.Cluster,
+		Label:    d.Label,
+		StartAt:  d.StartAt,
+		EndAt:    d.EndAt,
+	}
+	if d.Priority != 0 {
+		s.Priority = &d.Priority
+	}
+	if d.RunEvery != 0 {
+		s.RunEvery = &d.RunEvery
+	}
+	if d.RunTimes != 0 {
+		s.RunTimes = &d.RunTimes
+	}
+	if d.MaxConcurrency != 0 {
+		s.MaxConcurrency = &d.MaxConcurrency
+	}
+	if d.Timeout != 0 {
+		timeout := time.Duration(d.Timeout) * time.Second
+		s.Timeout = &timeout
+	}
+	return s
+}
+
+// matches tells if the schedule actual is set up like d. Only the fields
+// set in d are compared, the API fills in defaults for the others.
+func (d ScheduleDef) matches(actual ScheduleDef) bool {
+	same := func(set bool, a, d interface{}) bool { return !set || a == d }
+	sameTime := func(a, d *time.Time) bool { return d == nil || a != nil && a.Equal(*d) }
+	return d.Name == actual.Name && d.CodeName == actual.CodeName &&
+		same(d.Payload != "", actual.Payload, d.Payload) &&
+		same(d.Priority != 0, actual.Priority, d.Priority) &&
+		same(d.Cluster != "", actual.Cluster, d.Cluster) &&
+		same(d.Label != "", actual.Label, d.Label) &&
+		same(d.RunEvery != 0, actual.RunEvery, d.RunEvery) &&
+		same(d.RunTimes != 0, actual.RunTimes, d.RunTimes) &&
+		same(d.Timeout != 0, actual.Timeout, d.Timeout) &&
+		same(d.MaxConcurrency != 0, actual.MaxConcurrency, d.MaxConcurrency) &&
+		sameTime(actual.StartAt, d.StartAt) && sameTime(actual.EndAt, d.EndAt)
+}
+
+// scheduleDef returns the definition of the schedule s.
+func scheduleDef(s ScheduleInfo) ScheduleDef {
+	d := ScheduleDef{
+		Name:           s.Name,
+		CodeName:       s.CodeName,
+		Payload:        s.Payload,
+		Priority:       s.Priority,
+		Cluster:        s.Cluster,
+		Label:          s.Label,
+		RunEvery:       s.RunEvery,
+		RunTimes:       s.RunTimes,
+		Timeout:        s.Timeout,
+		MaxConcurrency: s.MaxConcurrency,
+	}
+	if !s.StartAt.IsZero() {
+		t := s.StartAt.UTC()
+		d.StartAt = &t
+	}
+	if !s.EndAt.IsZero() {
+		t := s.EndAt.UTC()
+		d.EndAt = &t
+	}
+	return d
+}
+
+// activeSchedules lists all the project's schedules that weren't cancelled
+// or finished.
+func (w *Worker) activeSchedules() ([]ScheduleInfo, error) {
+	var active []ScheduleInfo
+	for s, err := range w.AllSchedules() {
+		if err != nil {
+			return nil, err
+		}
+		if s.Status == "scheduled" {
+			active = append(active, s)
+		}
+	}
+	return active, nil
+}
+
+// readScheduleFile reads the ScheduleFile at path and checks its schedules
+// are named uniquely.
+func readScheduleFile(path string) (*ScheduleFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	// decode through JSON so the fields take their JSON names
+	var raw interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	j, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	d := json.NewDecoder(bytes.NewReader(j))
+	d.DisallowUnknownFields()
+	var file ScheduleFile
+	if err := d.Decode(&file); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	seen := map[string]bool{}
+	for i, s := range file.Schedules {
+		switch {
+		case s.Name == "":
+			return nil, fmt.Errorf("%s: schedule %d has no name", path, i)
+		case s.CodeName == "":
+			return nil, fmt.Errorf("%s: schedule %s has no code_name", path, s.Name)
+		case seen[s.Name]:
+			return nil, fmt.Errorf("%s: schedule %s is listed twice", path, s.Name)
+		}
+		seen[s.Name] = true
+	}
+	return &file, nil
+}
@@ -0,0 +1,128 @@
+package worker
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/iron-io/iron_go3/irontest"
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestScheduleFiles(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("schedule files", func() {
+		srv := irontest.NewServer()
+		defer srv.Close()
+		w := &Worker{Settings: srv.Settings("iron_worker")}
+		dir := t.TempDir()
+		write := func(name, content string) string {
+			path := filepath.Join(dir, name)
+			Expect(os.WriteFile(path, []byte(content), 0644), ToBeNil)
+			return path
+		}
+		names := func() []string {
+			schedules, err := w.activeSchedules()
+			Expect(err, ToBeNil)
+			var names []string
+			for _, s := range schedules {
+				names = append(names, s.Name)
+			}
+			sort.Strings(names)
+			return names
+		}
+
+		path := write("schedules.yaml", `
+schedules:
+  - name: nightly-report
+    code_name: report
+    payload: '{"period": "day"}'
+    run_every: 86400
+    timeout: 600
+    start_at: 2024-01-01T02:00:00Z
+  - name: cleanup
+    code_name: cleanup
+    run_every: 3600
+    priority: 1
+`)
+
+		It("creates the schedules of a file once", func() {
+			res, err := w.ScheduleImport(path, false)
+			Expect(err, ToBeNil)
+			Expect(res.Created, ToDeepEqual, []string{"nightly-report", "cleanup"})
+			Expect(names(), ToDeepEqual, []string{"cleanup", "nightly-report"})
+
+			res, err = w.ScheduleImport(path, false)
+			Expect(err, ToBeNil)
+			Expect(len(res.Created), ToEqual, 0)
+			Expect(res.Unchanged, ToDeepEqual, []string{"nightly-report", "cleanup"})
+			Expect(names(), ToDeepEqual, []string{"cleanup", "nightly-report"})
+		})
+
+		It("exports what it imports", func() {
+			var out bytes.Buffer
+			Expect(w.ScheduleExport(&out, "yaml"), ToBeNil)
+			exported := write("exported.yaml", out.String())
+			Expect(strings.Contains(out.String(), "code_name: report"), ToBeTrue)
+
+			res, err := w.ScheduleImport(exported, false)
+			Expect(err, ToBeNil)
+			Expect(len(res.Unchanged), ToEqual, 2)
+
+			out.Reset()
+			Expect(w.ScheduleExport(&out, "json"), ToBeNil)
+			res, err = w.ScheduleImport(write("exported.json", out.String()), false)
+			Expect(err, ToBeNil)
+			Expect(len(res.Unchanged), ToEqual, 2)
+		})
+
+		It("replaces changed schedules and prunes missing ones", func() {
+			changed := write("changed.json", `{"schedules": [{"name": "cleanup", "code_name": "cleanup", "run_every": 600}]}`)
+
+			res, err := w.ScheduleImport(changed, false)
+			Expect(err, ToBeNil)
+			Expect(res.Replaced, ToDeepEqual, []string{"cleanup"})
+			Expect(names(), ToDeepEqual, []string{"cleanup", "nightly-report"})
+
+			res, err = w.ScheduleImport(changed, true)
+			Expect(err, ToBeNil)
+			Expect(res.Unchanged, ToDeepEqual, []string{"cleanup"})
+			Expect(res.Cancelled, ToDeepEqual, []string{"nightly-report"})
+			Expect(names(), ToDeepEqual, []string{"cleanup"})
+		})
+
+		It("leaves alone the settings the API filled in", func() {
+			defaults := write("defaults.yaml", "schedules: [{name: hourly, code_name: hourly, run_every: 3600}]")
+			res, err := w.ScheduleImport(defaults, false)
+			Expect(err, ToBeNil)
+			Expect(res.Created, ToDeepEqual, []string{"hourly"})
+
+			schedules, err := w.activeSchedules()
+			Expect(err, ToBeNil)
+			for _, s := range schedules {
+				if s.Name == "hourly" {
+					Expect(s.StartAt.IsZero(), ToEqual, false)
+					Expect(s.Timeout, ToEqual, 3600)
+				}
+			}
+
+			res, err = w.ScheduleImport(defaults, false)
+			Expect(err, ToBeNil)
+			Expect(res.Unchanged, ToDeepEqual, []string{"hourly"})
+			Expect(len(res.Replaced), ToEqual, 0)
+		})
+
+		It("refuses files with unnamed or duplicate schedules", func() {
+			_, err := w.ScheduleImport(write("unnamed.yaml", "schedules: [{code_name: a}]"), false)
+			Expect(err != nil, ToBeTrue)
+			_, err = w.ScheduleImport(write("twice.yaml", "schedules: [{name: a, code_name: a}, {name: a, code_name: b}]"), false)
+			Expect(err != nil, ToBeTrue)
+			_, err = w.ScheduleImport(write("unknown.yaml", "schedules: [{name: a, code_name: a, every: 5}]"), false)
+			Expect(err != nil, ToBeTrue)
+		})
+	})
+}
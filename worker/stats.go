@@ -0,0 +1,144 @@
+package worker
+
+import (
+	"time"
+)
+
+// Metrics is told about each finished task a Worker waited for.
+type Metrics interface {
+	ObserveTask(TaskInfo)
+}
+
+// CodePackageStats holds the current task counts of a code package by status.
+type CodePackageStats struct {
+	Running   int `json:"running"`
+	Queued    int `json:"queued"`
+	Complete  int `json:"complete"`
+	Error     int `json:"error"`
+	Cancelled int `json:"cancelled"`
+	Killed    int `json:"killed"`
+	Timeout   int `json:"timeout"`
+}
+
+// ErrorRate returns the fraction of finished tasks that failed, see
+// TaskStatus.IsFailure. It is 0 if no tasks have finished.
+func (s CodePackageStats) ErrorRate() float64 {
+	failed := s.Error + s.Killed + s.Timeout
+	finished := failed + s.Complete + s.Cancelled
+	if finished == 0 {
+		return 0
+	}
+	return float64(failed) / float64(finished)
+}
+
+// CodePackageStats gets the task counts of a code package
+func (w *Worker) CodePackageStats(codeId string) (stats CodePackageStats, err error) {
+	err = w.codes(codeId, "stats").Req("GET", nil, &stats)
+	return stats, err
+}
+
+// CodeUsage summarizes the tasks of a code package over a period of time.
+type CodeUsage struct {
+	CodeName string
+	From     time.Time
+	To       time.Time
+	Tasks    int
+	Failed   int
+	// Compute is the total run time of the tasks in the period.
+	Compute time.Duration
+}
+
+// ErrorRate returns the fraction of tasks in the period that failed.
+func (u CodeUsage) ErrorRate() float64 {
+	if u.Tasks == 0 {
+		return 0
+	}
+	return float64(u.Failed) / float64(u.Tasks)
+}
+
+// ComputeHours returns Compute in hours, the unit IronWorker bills in.
+func (u CodeUsage) ComputeHours() float64 {
+	return u.Compute.Hours()
+}
+
+// CodePackageUsage pages through the tasks of codeName created between from
+// and to and adds up their run time and failures. Tasks that haven't
+// finished are not counted.
+func (w *Worker) CodePackageUsage(codeName string, from, to time.Time) (CodeUsage, error) {
+	usage := CodeUsage{CodeName: codeName, From: from, To: to}
+	for t, err := range w.AllTasks(TaskListParams{CodeName: codeName, FromTime: from, ToTime: to}) {
+		if err != nil {
+			return usage, err
+		}
+		if !t.Status.IsTerminal() {
+			continue
+		}
+		usage.Tasks++
+		if t.Status.IsFailure() {
+			usage.Failed++
+		}
+		usage.Compute += time.Duration(t.Duration) * time.Millisecond
+	}
+	return usage, nil
+}
+
+// CodeStatsWindow is how far back CodeStats counts finished tasks.
+const CodeStatsWindow = time.Hour
+
+// CodeStats is a snapshot of the load of a code package, e.g. to drive
+// autoscaling or alerts.
+type CodeStats struct {
+	CodeName string
+	Queued   int
+	Running  int
+	// MaxConcurrency is the most tasks of the package allowed to run at
+	// once, 0 if unlimited.
+	MaxConcurrency int
+	// RecentTasks and RecentErrors count the tasks created in the last
+	// CodeStatsWindow that finished, and those of them that failed.
+	RecentTasks  int
+	RecentErrors int
+}
+
+// Saturated tells whether the package runs as many tasks as it is allowed
+// to, so its queued tasks wait.
+func (s CodeStats) Saturated() bool {
+	return s.MaxConcurrency > 0 && s.Running >= s.MaxConcurrency
+}
+
+// ErrorRate returns the fraction of the recent tasks that failed.
+func (s CodeStats) ErrorRate() float64 {
+	if s.RecentTasks == 0 {
+		return 0
+	}
+	return float64(s.RecentErrors) / float64(s.RecentTasks)
+}
+
+// CodeStats gets the queued and running task counts, the max concurrency
+// and the recent failures of the code package codeName.
+func (w *Worker) CodeStats(codeName string) (CodeStats, error) {
+	stats := CodeStats{CodeName: codeName}
+	info, err := w.CodePackageByName(codeName)
+	if err != nil {
+		return stats, err
+	}
+	// the listing doesn't carry the package's config
+	if info, err = w.CodePackageInfo(info.Id); err != nil {
+		return stats, err
+	}
+	stats.MaxConcurrency = info.MaxConcurrency
+
+	counts, err := w.CodePackageStats(info.Id)
+	if err != nil {
+		return stats, err
+	}
+	stats.Queued, stats.Running = counts.Queued, counts.Running
+
+	now := time.Now()
+	usage, err := w.CodePackageUsage(codeName, now.Add(-CodeStatsWindow), now)
+	if err != nil {
+		return stats, err
+	}
+	stats.RecentTasks, stats.RecentErrors = usage.Tasks, usage.Failed
+	return stats, nil
+}
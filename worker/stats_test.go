@@ -0,0 +1,54 @@
+package worker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/iron-io/iron_go3/irontest"
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestCodePackageStats(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("code package stats", func() {
+		srv := irontest.NewServer()
+		defer srv.Close()
+		w := &Worker{Settings: srv.Settings("iron_worker")}
+		_, err := w.CodePackageUpload(Code{Name: "stats", Image: "iron/hello", MaxConcurrency: 2})
+		Expect(err, ToBeNil)
+		ids := make([]string, 6)
+		for i := range ids {
+			queued, err := w.TaskQueue(Task{CodeName: "stats"})
+			Expect(err, ToBeNil)
+			ids[i] = queued[0]
+		}
+		// two running, three finished of which two failed, one queued
+		Expect(srv.Worker.Start(ids[0]), ToBeNil)
+		Expect(srv.Worker.Start(ids[1]), ToBeNil)
+		Expect(srv.Worker.Finish(ids[2], irontest.StatusComplete, "", ""), ToBeNil)
+		Expect(srv.Worker.Finish(ids[3], irontest.StatusError, "boom", ""), ToBeNil)
+		Expect(srv.Worker.Finish(ids[4], irontest.StatusTimeout, "", ""), ToBeNil)
+		code, _ := srv.Worker.Code("stats")
+
+		It("counts the tasks by status", func() {
+			stats, err := w.CodePackageStats(code.Id)
+			Expect(err, ToBeNil)
+			Expect(stats, ToEqual, CodePackageStats{Running: 2, Queued: 1, Complete: 1, Error: 1, Timeout: 1})
+			Expect(stats.ErrorRate(), ToEqual, 2.0/3)
+			Expect(CodePackageStats{Running: 1, Queued: 1}.ErrorRate(), ToEqual, 0.0)
+		})
+
+		It("adds up the usage of the finished tasks only", func() {
+			now := time.Now()
+			usage, err := w.CodePackageUsage("stats", now.Add(-time.Hour), now.Add(time.Hour))
+			Expect(err, ToBeNil)
+			Expect(usage.Tasks, ToEqual, 3)
+			Expect(usage.Failed, ToEqual, 2)
+			Expect(usage.ErrorRate(), ToEqual, 2.0/3)
+			Expect(usage.Compute >= 0, ToBeTrue)
+			Expect(CodeUsage{}.ErrorRate(), ToEqual, 0.0)
+			Expect(CodeUsage{Compute: 90 * time.Minute}.ComputeHours(), ToEqual, 1.5)
+		})
+	})
+}
@@ -0,0 +1,28 @@
+package worker
+
+// TaskStatus is the state of a task as reported by IronWorker.
+type TaskStatus string
+
+const (
+	StatusQueued    TaskStatus = "queued"
+	StatusRunning   TaskStatus = "running"
+	StatusComplete  TaskStatus = "complete"
+	StatusError     TaskStatus = "error"
+	StatusCancelled TaskStatus = "cancelled"
+	StatusKilled    TaskStatus = "killed"
+	StatusTimeout   TaskStatus = "timeout"
+)
+
+// IsTerminal reports whether a task in this state has stopped and will not
+// change state again.
+func (s TaskStatus) IsTerminal() bool {
+	return s != StatusQueued && s != StatusRunning
+}
+
+// IsFailure reports whether the task finished without completing
+// successfully. Cancelled tasks are not considered failures.
+func (s TaskStatus) IsFailure() bool {
+	return s == StatusError || s == StatusKilled || s == StatusTimeout
+}
+
+func (s TaskStatus) String() string { return string(s) }
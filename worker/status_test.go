@@ -0,0 +1,30 @@
+package worker
+
+import (
+	"testing"
+
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestTaskStatus(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("task statuses", func() {
+		It("knows which states are terminal", func() {
+			Expect(StatusQueued.IsTerminal(), ToEqual, false)
+			Expect(StatusRunning.IsTerminal(), ToEqual, false)
+			for _, s := range []TaskStatus{StatusComplete, StatusError, StatusCancelled, StatusKilled, StatusTimeout} {
+				Expect(s.IsTerminal(), ToBeTrue)
+			}
+		})
+
+		It("knows which states are failures", func() {
+			Expect(StatusComplete.IsFailure(), ToEqual, false)
+			Expect(StatusCancelled.IsFailure(), ToEqual, false)
+			Expect(StatusRunning.IsFailure(), ToEqual, false)
+			Expect(StatusError.IsFailure(), ToBeTrue)
+			Expect(StatusKilled.IsFailure(), ToBeTrue)
+			Expect(StatusTimeout.IsFailure(), ToBeTrue)
+		})
+	})
+}
@@ -0,0 +1,86 @@
+package tasklog
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Handler returns a slog.Handler buffering the records at level and above
+// in s. Attributes of groups are named by their path, e.g. "req.method".
+func (s *Shipper) Handler(level slog.Leveler) slog.Handler {
+	return &handler{s: s, level: level}
+}
+
+type handler struct {
+	s      *Shipper
+	level  slog.Leveler
+	attrs  []slog.Attr // with their group prefixed
+	prefix string
+}
+
+func (h *handler) Enabled(ctx context.Context, level slog.Level) bool {
+	if h.level == nil {
+		return level >= slog.LevelInfo
+	}
+	return level >= h.level.Level()
+}
+
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	rec := Record{Time: r.Time, Level: r.Level.String(), Message: r.Message}
+	if r.NumAttrs() > 0 || len(h.attrs) > 0 {
+		rec.Attrs = map[string]interface{}{}
+	}
+	for _, a := range h.attrs {
+		addAttr(rec.Attrs, "", a)
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		addAttr(rec.Attrs, h.prefix, a)
+		return true
+	})
+	if len(rec.Attrs) == 0 {
+		rec.Attrs = nil
+	}
+	h.s.Log(rec)
+	return nil
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h2 := *h
+	h2.attrs = append([]slog.Attr(nil), h.attrs...)
+	for _, a := range attrs {
+		a.Key = h.prefix + a.Key
+		h2.attrs = append(h2.attrs, a)
+	}
+	return &h2
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.prefix = h.prefix + name + "."
+	return &h2
+}
+
+// addAttr adds a to attrs, flattening groups.
+func addAttr(attrs map[string]interface{}, prefix string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, g := range a.Value.Group() {
+			addAttr(attrs, prefix, g)
+		}
+		return
+	}
+	if err, ok := a.Value.Any().(error); ok {
+		attrs[prefix+a.Key] = err.Error()
+		return
+	}
+	attrs[prefix+a.Key] = a.Value.Any()
+}
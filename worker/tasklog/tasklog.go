@@ -0,0 +1,308 @@
+// Package tasklog ships the logs of worker tasks in batches to a queue or
+// another Sink, so the logs of many short tasks land in a logging pipeline
+// rather than only in each task's log.
+//
+//	worker.ParseFlags()
+//	s := tasklog.New(tasklog.QueueSink{Queue: mq.New("task-logs")})
+//	s.Start()
+//	defer s.Close()
+//
+//	log.SetOutput(io.MultiWriter(os.Stderr, s))  // plain log output
+//	logger := slog.New(s.Handler(slog.LevelInfo)) // structured records
+//
+// Records are shipped every FlushInterval, or as soon as BatchSize of them
+// are buffered, and those left are shipped by Close, which the task must
+// call before exiting.
+package tasklog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/iron-io/iron_go3/api"
+	"github.com/iron-io/iron_go3/mq"
+	"github.com/iron-io/iron_go3/worker"
+)
+
+// A Record is a log record of a task.
+type Record struct {
+	Time    time.Time              `json:"time"`
+	TaskId  string                 `json:"task_id,omitempty"`
+	Level   string                 `json:"level,omitempty"`
+	Message string                 `json:"msg"`
+	Attrs   map[string]interface{} `json:"attrs,omitempty"`
+}
+
+// A Sink stores shipped records.
+type Sink interface {
+	Ship(ctx context.Context, records []Record) error
+}
+
+// SinkFunc adapts a function to a Sink, e.g. to post records to an
+// external logging service.
+type SinkFunc func(ctx context.Context, records []Record) error
+
+func (f SinkFunc) Ship(ctx context.Context, records []Record) error { return f(ctx, records) }
+
+// QueueSink pushes each record to Queue as a JSON message. The messages of
+// records too large for the queue's Limits are cut to fit, and their
+// attributes dropped if that isn't enough.
+type QueueSink struct {
+	Queue mq.Queue
+}
+
+// truncated ends the messages of records cut to fit in a queue message.
+const truncated = " [truncated]"
+
+func (s QueueSink) Ship(ctx context.Context, records []Record) error {
+	limit := s.Queue.Limits.MessageSize
+	if limit <= 0 {
+		limit = mq.MaxMessageSize
+	}
+	bodies := make([]string, len(records))
+	for i, r := range records {
+		b, err := marshalFit(r, limit)
+		if err != nil {
+			return err
+		}
+		bodies[i] = string(b)
+	}
+	for start := 0; start < len(bodies); start += mq.MaxPush {
+		if _, err := s.Queue.PushStrings(bodies[start:min(start+mq.MaxPush, len(bodies))]...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// marshalFit encodes r in at most limit bytes: its attributes are dropped
+// if they don't fit with an empty message, then its message is cut.
+func marshalFit(r Record, limit int) ([]byte, error) {
+	b, err := json.Marshal(r)
+	if err != nil || len(b) <= limit {
+		return b, err
+	}
+	msg, bare := r.Message, r
+	bare.Message = truncated
+	if b, err := json.Marshal(bare); err == nil && len(b) > limit {
+		r.Attrs, r.Message = nil, msg+truncated
+	}
+	for b, err = json.Marshal(r); err == nil && len(b) > limit; b, err = json.Marshal(r) {
+		if msg == "" {
+			return nil, &api.TooLargeError{Limit: "message size", Size: int64(len(b)), Max: int64(limit)}
+		}
+		n := max(len(msg)-(len(b)-limit)-len(truncated), 0)
+		for n > 0 && !utf8.RuneStart(msg[n]) {
+			n--
+		}
+		msg = msg[:n]
+		r.Message = msg + truncated
+	}
+	return b, err
+}
+
+// A Shipper buffers the log records of a task and ships them to Sink in
+// batches. It is safe for concurrent use.
+type Shipper struct {
+	Sink Sink
+	// TaskId is set on the records, the running task's id by default, see
+	// worker.ParseFlags.
+	TaskId string
+	// BatchSize is the most records shipped at once, 100 by default.
+	BatchSize int
+	// FlushInterval is how often buffered records are shipped, a second by
+	// default.
+	FlushInterval time.Duration
+	// MaxBuffered is the most records kept while Sink fails, 10000 by
+	// default. The oldest are dropped first, see Dropped.
+	MaxBuffered int
+	// OnError is told about failed shipments.
+	OnError func(error)
+
+	mu      sync.Mutex
+	buf     []Record
+	partial []byte // an unfinished line of Write
+	dropped int
+
+	flushing sync.Mutex
+	kick     chan struct{}
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// New returns a Shipper to sink for the running task.
+func New(sink Sink) *Shipper {
+	return &Shipper{Sink: sink, TaskId: worker.IronTaskId()}
+}
+
+// Start ships buffered records in the background until Close is called.
+func (s *Shipper) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stop != nil {
+		return
+	}
+	s.kick = make(chan struct{}, 1)
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	go s.run()
+}
+
+func (s *Shipper) run() {
+	defer close(s.done)
+	t := time.NewTicker(s.flushInterval())
+	defer t.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-t.C:
+		case <-s.kick:
+		}
+		if err := s.Flush(context.Background()); err != nil && s.OnError != nil {
+			s.OnError(err)
+		}
+	}
+}
+
+// Close stops shipping in the background, and ships the records left,
+// including an unfinished line of Write.
+func (s *Shipper) Close() error {
+	s.mu.Lock()
+	stop, done := s.stop, s.done
+	if len(s.partial) > 0 {
+		s.add(s.record(string(s.partial)))
+		s.partial = nil
+	}
+	s.mu.Unlock()
+	if stop != nil {
+		select {
+		case <-stop:
+		default:
+			close(stop)
+		}
+		<-done
+	}
+	return s.Flush(context.Background())
+}
+
+// Log buffers r, setting its time and task id if they aren't.
+func (s *Shipper) Log(r Record) {
+	if r.Time.IsZero() {
+		r.Time = time.Now()
+	}
+	if r.TaskId == "" {
+		r.TaskId = s.TaskId
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.add(r)
+}
+
+// Write buffers a record per line of p, so a Shipper can capture the
+// output of the log package, or of a command.
+func (s *Shipper) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data := append(s.partial, p...)
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		if line := bytes.TrimRight(data[:i], "\r"); len(line) > 0 {
+			s.add(s.record(string(line)))
+		}
+		data = data[i+1:]
+	}
+	s.partial = append([]byte(nil), data...)
+	return len(p), nil
+}
+
+func (s *Shipper) record(msg string) Record {
+	return Record{Time: time.Now(), TaskId: s.TaskId, Message: msg}
+}
+
+// add buffers r, s.mu held.
+func (s *Shipper) add(r Record) {
+	s.buf = append(s.buf, r)
+	if over := len(s.buf) - s.maxBuffered(); over > 0 {
+		s.buf = append(s.buf[:0], s.buf[over:]...)
+		s.dropped += over
+	}
+	if len(s.buf) >= s.batchSize() && s.kick != nil {
+		select {
+		case s.kick <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Flush ships the buffered records. Records that fail to ship are kept
+// for the next flush, unless Sink refused them as too large, see api.ErrTooLarge:
+// those would never ship, so they are dropped.
+func (s *Shipper) Flush(ctx context.Context) error {
+	s.flushing.Lock()
+	defer s.flushing.Unlock()
+	for {
+		s.mu.Lock()
+		n := min(len(s.buf), s.batchSize())
+		batch := append([]Record(nil), s.buf[:n]...)
+		s.buf = s.buf[n:]
+		s.mu.Unlock()
+		if n == 0 {
+			return nil
+		}
+		if err := s.Sink.Ship(ctx, batch); err != nil {
+			if errors.Is(err, api.ErrTooLarge) {
+				s.mu.Lock()
+				s.dropped += n
+				s.mu.Unlock()
+				return fmt.Errorf("tasklog: dropping %d records: %w", n, err)
+			}
+			s.mu.Lock()
+			s.buf = append(batch, s.buf...)
+			if over := len(s.buf) - s.maxBuffered(); over > 0 {
+				s.buf = s.buf[over:]
+				s.dropped += over
+			}
+			s.mu.Unlock()
+			return fmt.Errorf("tasklog: shipping %d records: %w", n, err)
+		}
+	}
+}
+
+// Dropped returns how many records were dropped because MaxBuffered were
+// waiting to be shipped, or because Sink refused them as too large.
+func (s *Shipper) Dropped() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dropped
+}
+
+func (s *Shipper) batchSize() int {
+	if s.BatchSize > 0 {
+		return s.BatchSize
+	}
+	return 100
+}
+
+func (s *Shipper) flushInterval() time.Duration {
+	if s.FlushInterval > 0 {
+		return s.FlushInterval
+	}
+	return time.Second
+}
+
+func (s *Shipper) maxBuffered() int {
+	if s.MaxBuffered > 0 {
+		return s.MaxBuffered
+	}
+	return 10000
+}
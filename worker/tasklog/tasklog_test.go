@@ -0,0 +1,159 @@
+package tasklog
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iron-io/iron_go3/api"
+	"github.com/iron-io/iron_go3/irontest"
+	"github.com/iron-io/iron_go3/mq"
+	. "github.com/jeffh/go.bdd"
+)
+
+// memorySink keeps the batches shipped to it, failing while fail is set.
+type memorySink struct {
+	mu      sync.Mutex
+	batches [][]Record
+	fail    bool
+}
+
+func (m *memorySink) Ship(ctx context.Context, records []Record) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.fail {
+		return errors.New("sink down")
+	}
+	m.batches = append(m.batches, records)
+	return nil
+}
+
+func (m *memorySink) messages() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var msgs []string
+	for _, b := range m.batches {
+		for _, r := range b {
+			msgs = append(msgs, r.Message)
+		}
+	}
+	return msgs
+}
+
+func TestShipper(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("shipping task logs", func() {
+		It("ships lines written to it in batches", func() {
+			sink := &memorySink{}
+			s := &Shipper{Sink: sink, TaskId: "task-1", BatchSize: 2, FlushInterval: time.Hour}
+			s.Start()
+			l := log.New(s, "", 0)
+			l.Print("one")
+			l.Print("two")
+			for deadline := time.Now().Add(time.Second); len(sink.messages()) < 2 && time.Now().Before(deadline); {
+				time.Sleep(5 * time.Millisecond)
+			}
+			Expect(sink.messages(), ToDeepEqual, []string{"one", "two"})
+
+			fmt.Fprint(s, "three\nfour")
+			Expect(s.Close(), ToBeNil)
+			Expect(sink.messages(), ToDeepEqual, []string{"one", "two", "three", "four"})
+			Expect(len(sink.batches), ToEqual, 2)
+			Expect(sink.batches[0][0].TaskId, ToEqual, "task-1")
+		})
+
+		It("ships structured records", func() {
+			sink := &memorySink{}
+			s := &Shipper{Sink: sink}
+			logger := slog.New(s.Handler(slog.LevelInfo)).With("job", 7).WithGroup("req")
+			logger.Debug("hidden")
+			logger.Info("done", "method", "GET", "err", errors.New("boom"))
+			Expect(s.Close(), ToBeNil)
+			Expect(len(sink.batches), ToEqual, 1)
+			r := sink.batches[0][0]
+			Expect(r.Level, ToEqual, "INFO")
+			Expect(r.Message, ToEqual, "done")
+			Expect(r.Attrs, ToDeepEqual, map[string]interface{}{"job": int64(7), "req.method": "GET", "req.err": "boom"})
+		})
+
+		It("keeps records while the sink fails", func() {
+			sink := &memorySink{fail: true}
+			s := &Shipper{Sink: sink, MaxBuffered: 2}
+			s.Log(Record{Message: "a"})
+			s.Log(Record{Message: "b"})
+			Expect(s.Flush(context.Background()), ToNotBeNil)
+			s.Log(Record{Message: "c"})
+			Expect(s.Dropped(), ToEqual, 1)
+			sink.fail = false
+			Expect(s.Flush(context.Background()), ToBeNil)
+			Expect(sink.messages(), ToDeepEqual, []string{"b", "c"})
+		})
+
+		It("drops the records the sink refuses as too large", func() {
+			var shipped []string
+			sink := SinkFunc(func(ctx context.Context, records []Record) error {
+				for _, r := range records {
+					if len(r.Message) > 5 {
+						return &api.TooLargeError{Limit: "message size", Size: int64(len(r.Message)), Max: 5}
+					}
+				}
+				for _, r := range records {
+					shipped = append(shipped, r.Message)
+				}
+				return nil
+			})
+			s := &Shipper{Sink: sink, BatchSize: 1}
+			s.Log(Record{Message: "too long"})
+			s.Log(Record{Message: "short"})
+			err := s.Flush(context.Background())
+			Expect(errors.Is(err, api.ErrTooLarge), ToBeTrue)
+			Expect(s.Dropped(), ToEqual, 1)
+			Expect(s.Flush(context.Background()), ToBeNil)
+			Expect(shipped, ToDeepEqual, []string{"short"})
+		})
+
+		It("cuts records to fit in a queue message", func() {
+			srv := irontest.NewServer()
+			defer srv.Close()
+			q := mq.Queue{Settings: srv.Settings("iron_mq"), Name: "small-logs", Limits: mq.Limits{MessageSize: 200}}
+			s := &Shipper{Sink: QueueSink{Queue: q}, TaskId: "task-3"}
+			s.Log(Record{Message: strings.Repeat("é", 300)})
+			s.Log(Record{Message: "big attrs", Attrs: map[string]interface{}{"blob": strings.Repeat("x", 300)}})
+			Expect(s.Close(), ToBeNil)
+			Expect(s.Dropped(), ToEqual, 0)
+
+			bodies := srv.MQ.Messages("small-logs")
+			Expect(len(bodies), ToEqual, 2)
+			var cut, bare Record
+			Expect(len(bodies[0]) <= 200, ToBeTrue)
+			Expect(json.Unmarshal([]byte(bodies[0]), &cut), ToBeNil)
+			Expect(strings.HasSuffix(cut.Message, "é"+truncated), ToBeTrue)
+			Expect(len(bodies[1]) <= 200, ToBeTrue)
+			Expect(json.Unmarshal([]byte(bodies[1]), &bare), ToBeNil)
+			Expect(bare.Message, ToEqual, "big attrs"+truncated)
+			Expect(bare.Attrs == nil, ToBeTrue)
+		})
+
+		It("pushes records to a queue", func() {
+			srv := irontest.NewServer()
+			defer srv.Close()
+			q := mq.Queue{Settings: srv.Settings("iron_mq"), Name: "task-logs"}
+			s := &Shipper{Sink: QueueSink{Queue: q}, TaskId: "task-2"}
+			s.Log(Record{Message: "hello", Time: time.Unix(0, 0).UTC()})
+			Expect(s.Close(), ToBeNil)
+			bodies := srv.MQ.Messages("task-logs")
+			Expect(len(bodies), ToEqual, 1)
+			var r Record
+			Expect(json.Unmarshal([]byte(bodies[0]), &r), ToBeNil)
+			Expect(r, ToDeepEqual, Record{Time: time.Unix(0, 0).UTC(), TaskId: "task-2", Message: "hello"})
+		})
+	})
+}
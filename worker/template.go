@@ -0,0 +1,123 @@
+package worker
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// A TaskTemplate holds what the tasks of a code package queued from many
+// places have in common:
+//
+//	var resize = worker.TaskTemplate{
+//		CodeName: "resize",
+//		Priority: 1,
+//		Payload:  map[string]interface{}{"format": "webp", "sizes": map[string]int{"thumb": 64}},
+//	}
+//
+//	ids, err := w.QueueFromTemplate(resize, map[string]interface{}{"url": url, "sizes": map[string]int{"large": 1024}})
+//
+// Payloads are JSON objects, those passed to Task and QueueFromTemplate are
+// merged over the template's.
+type TaskTemplate struct {
+	CodeName string
+	Priority int
+	Cluster  string
+	Label    string
+	Timeout  *time.Duration
+	Callback string
+	EnvVars  map[string]string
+	// Payload is the base payload, anything encoding to a JSON object.
+	Payload interface{}
+}
+
+// Task returns the task of payload, anything encoding to a JSON object, or
+// nil for the template's payload. Objects are merged key by key, at any
+// depth; other values of payload replace those of the template.
+func (t TaskTemplate) Task(payload interface{}) (Task, error) {
+	base, err := payloadObject(t.Payload)
+	if err != nil {
+		return Task{}, fmt.Errorf("template %s: %w", t.CodeName, err)
+	}
+	over, err := payloadObject(payload)
+	if err != nil {
+		return Task{}, fmt.Errorf("template %s: %w", t.CodeName, err)
+	}
+	merged, err := json.Marshal(mergeJSON(base, over))
+	if err != nil {
+		return Task{}, err
+	}
+
+	task := Task{
+		CodeName: t.CodeName,
+		Payload:  string(merged),
+		Priority: t.Priority,
+		Cluster:  t.Cluster,
+		Label:    t.Label,
+		Timeout:  t.Timeout,
+		Callback: t.Callback,
+	}
+	if len(t.EnvVars) > 0 {
+		task.EnvVars = make(map[string]string, len(t.EnvVars))
+		for k, v := range t.EnvVars {
+			task.EnvVars[k] = v
+		}
+	}
+	return task, nil
+}
+
+// QueueFromTemplate queues a task of t for each payload, see
+// TaskTemplate.Task, or a single one with the template's payload if none is
+// given.
+func (w *Worker) QueueFromTemplate(t TaskTemplate, payloads ...interface{}) ([]string, error) {
+	if len(payloads) == 0 {
+		payloads = []interface{}{nil}
+	}
+	tasks := make([]Task, len(payloads))
+	for i, p := range payloads {
+		task, err := t.Task(p)
+		if err != nil {
+			return nil, err
+		}
+		tasks[i] = task
+	}
+	return w.TaskQueue(tasks...)
+}
+
+// payloadObject decodes the JSON object v encodes to, an empty one for
+// nil. json.RawMessage and []byte are taken as JSON.
+func payloadObject(v interface{}) (map[string]interface{}, error) {
+	var data []byte
+	switch v := v.(type) {
+	case nil:
+		return map[string]interface{}{}, nil
+	case json.RawMessage:
+		data = v
+	case []byte:
+		data = v
+	default:
+		var err error
+		if data, err = json.Marshal(v); err != nil {
+			return nil, err
+		}
+	}
+	var obj map[string]interface{}
+	if err := json.Unmarshal(data, &obj); err != nil || obj == nil {
+		return nil, fmt.Errorf("payload is not a JSON object: %s", data)
+	}
+	return obj, nil
+}
+
+// mergeJSON merges over into base, recursing into the objects both have.
+func mergeJSON(base, over map[string]interface{}) map[string]interface{} {
+	for k, v := range over {
+		b, bok := base[k].(map[string]interface{})
+		o, ook := v.(map[string]interface{})
+		if bok && ook {
+			base[k] = mergeJSON(b, o)
+		} else {
+			base[k] = v
+		}
+	}
+	return base
+}
@@ -0,0 +1,70 @@
+package worker
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/iron-io/iron_go3/irontest"
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestTaskTemplate(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("task templates", func() {
+		timeout := 5 * time.Minute
+		resize := TaskTemplate{
+			CodeName: "resize",
+			Priority: 2,
+			Cluster:  "high-mem",
+			Timeout:  &timeout,
+			Payload:  map[string]interface{}{"format": "webp", "sizes": map[string]int{"thumb": 64, "small": 256}},
+		}
+
+		It("deep-merges payloads over the template's", func() {
+			task, err := resize.Task(map[string]interface{}{"url": "u", "sizes": map[string]int{"small": 320, "large": 1024}})
+			Expect(err, ToBeNil)
+			Expect(task.CodeName, ToEqual, "resize")
+			Expect(task.Priority, ToEqual, 2)
+			Expect(task.Cluster, ToEqual, "high-mem")
+			Expect(*task.Timeout, ToEqual, timeout)
+
+			var payload map[string]interface{}
+			json.Unmarshal([]byte(task.Payload), &payload)
+			Expect(payload, ToDeepEqual, map[string]interface{}{
+				"format": "webp",
+				"url":    "u",
+				"sizes":  map[string]interface{}{"thumb": 64.0, "small": 320.0, "large": 1024.0},
+			})
+		})
+
+		It("doesn't change the template", func() {
+			_, err := resize.Task(json.RawMessage(`{"format": "png"}`))
+			Expect(err, ToBeNil)
+			task, _ := resize.Task(nil)
+			Expect(task.Payload, ToEqual, `{"format":"webp","sizes":{"small":256,"thumb":64}}`)
+		})
+
+		It("refuses payloads that aren't objects", func() {
+			_, err := resize.Task([]int{1, 2})
+			Expect(err, ToNotBeNil)
+			_, err = TaskTemplate{CodeName: "x", Payload: "text"}.Task(nil)
+			Expect(err, ToNotBeNil)
+		})
+
+		It("queues tasks from a template", func() {
+			srv := irontest.NewServer()
+			defer srv.Close()
+			w := &Worker{Settings: srv.Settings("iron_worker")}
+
+			ids, err := w.QueueFromTemplate(resize, map[string]string{"url": "a"}, map[string]string{"url": "b"})
+			Expect(err, ToBeNil)
+			Expect(len(ids), ToEqual, 2)
+			tasks := srv.Worker.Tasks("resize")
+			Expect(len(tasks), ToEqual, 2)
+			Expect(tasks[0].Priority, ToEqual, 2)
+			Expect(tasks[1].Payload, ToEqual, `{"format":"webp","sizes":{"small":256,"thumb":64},"url":"b"}`)
+		})
+	})
+}
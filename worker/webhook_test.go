@@ -0,0 +1,57 @@
+package worker
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/iron-io/iron_go3/irontest"
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestWebhooks(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("task webhooks", func() {
+		srv := irontest.NewServer()
+		defer srv.Close()
+		w := &Worker{Settings: srv.Settings("iron_worker")}
+
+		It("builds the URL of a code package's webhook", func() {
+			u, err := url.Parse(w.WebhookURL("hello world"))
+			Expect(err, ToBeNil)
+			Expect(u.Scheme, ToEqual, "http")
+			Expect(u.Host, ToEqual, strings.TrimPrefix(srv.URL, "http://"))
+			Expect(u.Path, ToEqual, "/2/projects/"+irontest.ProjectId+"/tasks/webhook")
+			Expect(u.Query().Get("code_name"), ToEqual, "hello world")
+			Expect(u.Query().Get("oauth"), ToEqual, irontest.Token)
+		})
+
+		It("queues a task for each post to the URL", func() {
+			res, err := http.Post(w.WebhookURL("hello"), "application/json", strings.NewReader(`{"n":1}`))
+			Expect(err, ToBeNil)
+			res.Body.Close()
+			Expect(res.StatusCode, ToEqual, http.StatusOK)
+			tasks := srv.Worker.Tasks("hello")
+			Expect(len(tasks), ToEqual, 1)
+			Expect(tasks[0].Payload, ToEqual, `{"n":1}`)
+		})
+
+		It("triggers the webhook itself", func() {
+			id, err := w.TriggerWebhook("triggered", []byte(`{"n":2}`))
+			Expect(err, ToBeNil)
+			task, ok := srv.Worker.Task(id)
+			Expect(ok, ToBeTrue)
+			Expect(task.CodeName, ToEqual, "triggered")
+			Expect(task.Payload, ToEqual, `{"n":2}`)
+		})
+
+		It("still queues tasks through TaskQueueWebhook", func() {
+			Expect(w.TaskQueueWebhook("deprecated", []byte(`{"n":3}`)), ToBeNil)
+			tasks := srv.Worker.Tasks("deprecated")
+			Expect(len(tasks), ToEqual, 1)
+			Expect(tasks[0].Payload, ToEqual, `{"n":3}`)
+		})
+	})
+}
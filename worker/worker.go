@@ -10,16 +10,26 @@ import (
 
 type Worker struct {
 	Settings config.Settings
+	// Client makes the requests, api.DefaultClient if nil.
+	Client *api.Client
+	// Budget, if set, records the tasks waited for.
+	Budget *Budget
+	// Metrics, if set, is told about the tasks waited for.
+	Metrics Metrics
 }
 
 func New() *Worker {
 	return &Worker{Settings: config.Config("iron_worker")}
 }
 
-func (w *Worker) codes(s ...string) *api.URL     { return api.Action(w.Settings, "codes", s...) }
-func (w *Worker) tasks(s ...string) *api.URL     { return api.Action(w.Settings, "tasks", s...) }
-func (w *Worker) schedules(s ...string) *api.URL { return api.Action(w.Settings, "schedules", s...) }
-func (w *Worker) clusters(s ...string) *api.URL  { return api.RootAction(w.Settings, "clusters", s...) }
+func (w *Worker) codes(s ...string) *api.URL { return w.Client.Action(w.Settings, "codes", s...) }
+func (w *Worker) tasks(s ...string) *api.URL { return w.Client.Action(w.Settings, "tasks", s...) }
+func (w *Worker) schedules(s ...string) *api.URL {
+	return w.Client.Action(w.Settings, "schedules", s...)
+}
+func (w *Worker) clusters(s ...string) *api.URL {
+	return w.Client.RootAction(w.Settings, "clusters", s...)
+}
 
 // exponential sleep between retries, replace this with your own preferred strategy
 func sleepBetweenRetries(previousDuration time.Duration) time.Duration {
@@ -57,10 +67,11 @@ func (w *Worker) WaitForTask(taskId string) chan TaskInfo {
 				return
 			}
 
-			if info.Status == "queued" || info.Status == "running" {
+			if !info.Status.IsTerminal() {
 				time.Sleep(retryDelay)
 				retryDelay = sleepBetweenRetries(retryDelay)
 			} else {
+				w.record(info)
 				out <- info
 				return
 			}
@@ -70,6 +81,16 @@ func (w *Worker) WaitForTask(taskId string) chan TaskInfo {
 	return out
 }
 
+// record adds the finished task info to the Budget and Metrics, if any.
+func (w *Worker) record(info TaskInfo) {
+	if w.Budget != nil {
+		w.Budget.Record(info)
+	}
+	if w.Metrics != nil {
+		w.Metrics.ObserveTask(info)
+	}
+}
+
 func (w *Worker) WaitForTaskLog(taskId string) chan []byte {
 	out := make(chan []byte)
 
@@ -80,8 +101,7 @@ func (w *Worker) WaitForTaskLog(taskId string) chan []byte {
 		for {
 			log, err := w.TaskLog(taskId)
 			if err != nil {
-				e, ok := err.(api.HTTPResponseError)
-				if ok && e.StatusCode() == 404 {
+				if api.StatusCode(err) == 404 {
 					time.Sleep(retryDelay)
 					retryDelay = sleepBetweenRetries(retryDelay)
 					continue
@@ -67,7 +67,7 @@ func TestEverything(*testing.T) {
 
 			select {
 			case info = <-w.WaitForTask(id):
-				Expect(info.Status, ToEqual, "complete")
+				Expect(info.Status, ToEqual, StatusComplete)
 			case <-time.After(5 * time.Second):
 				panic("info timed out")
 			}
@@ -87,7 +87,7 @@ func TestEverything(*testing.T) {
 			Expect(err, ToBeNil)
 
 			info, err := w.TaskInfo(id)
-			Expect(info.Status, ToEqual, "cancelled")
+			Expect(info.Status, ToEqual, StatusCancelled)
 		})
 
 		It("Queues a lot of tasks and lists them", func() {
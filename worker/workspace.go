@@ -0,0 +1,230 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ErrWorkspaceFull is returned when a Workspace would grow past its
+// MaxSize.
+var ErrWorkspaceFull = errors.New("worker: workspace is full")
+
+// A Workspace is a scratch directory of the running task, for the files it
+// downloads, produces and uploads, removed by Close:
+//
+//	ws, err := worker.NewWorkspace(2 << 30)
+//	if err != nil { ... }
+//	defer ws.Close()
+//	input, err := ws.Download(ctx, payload.Input)
+//	...
+//	err = ws.Upload(ctx, "report.pdf", payload.ReportUploadURL)
+type Workspace struct {
+	// Dir is the workspace's directory.
+	Dir string
+	// MaxSize, if set, is the most bytes the workspace may hold, see Check.
+	MaxSize int64
+	// Client makes the downloads and uploads, http.DefaultClient if nil.
+	Client *http.Client
+
+	closeOnce sync.Once
+}
+
+// An Artifact is a file referenced in a task's payload, e.g.
+// {"url": "https://...", "name": "input.csv"}.
+type Artifact struct {
+	URL string `json:"url"`
+	// Name is the file's name in the workspace, the last element of URL's
+	// path by default.
+	Name string `json:"name,omitempty"`
+}
+
+// NewWorkspace creates a Workspace of at most maxSize bytes, 0 for no
+// limit, in the task's directory (see ParseFlags), or in the temporary
+// directory when not running as a task.
+func NewWorkspace(maxSize int64) (*Workspace, error) {
+	base := IronTaskDir()
+	if base == "" {
+		base = os.TempDir()
+	}
+	prefix := "workspace-"
+	if id := IronTaskId(); id != "" {
+		prefix += id + "-"
+	}
+	dir, err := os.MkdirTemp(base, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("worker: creating workspace: %w", err)
+	}
+	return &Workspace{Dir: dir, MaxSize: maxSize}, nil
+}
+
+// Path returns the path of name in the workspace. Names escaping it, e.g.
+// "../x", are kept inside.
+func (w *Workspace) Path(name string) string {
+	return filepath.Join(w.Dir, filepath.Clean("/"+name))
+}
+
+// Size returns how many bytes the files of the workspace hold.
+func (w *Workspace) Size() (int64, error) {
+	var size int64
+	err := filepath.WalkDir(w.Dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+// Check returns ErrWorkspaceFull if the workspace holds more than MaxSize
+// bytes.
+func (w *Workspace) Check() error {
+	if w.MaxSize <= 0 {
+		return nil
+	}
+	size, err := w.Size()
+	if err != nil {
+		return err
+	}
+	if size > w.MaxSize {
+		return fmt.Errorf("%w: %d bytes of %d", ErrWorkspaceFull, size, w.MaxSize)
+	}
+	return nil
+}
+
+// Download fetches a into the workspace and returns the file's path.
+// Downloads that would grow the workspace past MaxSize fail with
+// ErrWorkspaceFull, and leave no partial file behind.
+func (w *Workspace) Download(ctx context.Context, a Artifact) (string, error) {
+	name := a.Name
+	if name == "" {
+		name = artifactName(a.URL)
+	}
+	path := w.Path(name)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", a.URL, nil)
+	if err != nil {
+		return "", fmt.Errorf("worker: downloading %s: %w", name, err)
+	}
+	resp, err := w.client().Do(req)
+	if err != nil {
+		return "", fmt.Errorf("worker: downloading %s: %w", name, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("worker: downloading %s: %s", name, resp.Status)
+	}
+
+	var body io.Reader = resp.Body
+	room := int64(-1)
+	if w.MaxSize > 0 {
+		size, err := w.Size()
+		if err != nil {
+			return "", err
+		}
+		room = w.MaxSize - size
+		body = io.LimitReader(resp.Body, room+1)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", fmt.Errorf("worker: downloading %s: %w", name, err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return "", fmt.Errorf("worker: downloading %s: %w", name, err)
+	}
+	n, err := io.Copy(f, body)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && room >= 0 && n > room {
+		err = ErrWorkspaceFull
+	}
+	if err != nil {
+		os.Remove(path)
+		return "", fmt.Errorf("worker: downloading %s: %w", name, err)
+	}
+	return path, nil
+}
+
+// DownloadAll downloads artifacts in order and returns their paths.
+func (w *Workspace) DownloadAll(ctx context.Context, artifacts []Artifact) ([]string, error) {
+	paths := make([]string, 0, len(artifacts))
+	for _, a := range artifacts {
+		path, err := w.Download(ctx, a)
+		if err != nil {
+			return paths, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// Upload PUTs the file name of the workspace to url, e.g. a pre-signed
+// object store URL.
+func (w *Workspace) Upload(ctx context.Context, name, url string) error {
+	f, err := os.Open(w.Path(name))
+	if err != nil {
+		return fmt.Errorf("worker: uploading %s: %w", name, err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("worker: uploading %s: %w", name, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "PUT", url, f)
+	if err != nil {
+		return fmt.Errorf("worker: uploading %s: %w", name, err)
+	}
+	req.ContentLength = info.Size()
+	resp, err := w.client().Do(req)
+	if err != nil {
+		return fmt.Errorf("worker: uploading %s: %w", name, err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("worker: uploading %s: %s", name, resp.Status)
+	}
+	return nil
+}
+
+// Close removes the workspace and its files. It is safe to call more than
+// once.
+func (w *Workspace) Close() error {
+	var err error
+	w.closeOnce.Do(func() { err = os.RemoveAll(w.Dir) })
+	return err
+}
+
+func (w *Workspace) client() *http.Client {
+	if w.Client != nil {
+		return w.Client
+	}
+	return http.DefaultClient
+}
+
+// artifactName returns the last element of url's path.
+func artifactName(url string) string {
+	if i := strings.IndexAny(url, "?#"); i >= 0 {
+		url = url[:i]
+	}
+	name := url[strings.LastIndex(url, "/")+1:]
+	if name == "" {
+		return "artifact"
+	}
+	return name
+}
@@ -0,0 +1,77 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	. "github.com/jeffh/go.bdd"
+)
+
+func TestWorkspace(t *testing.T) {
+	defer PrintSpecReport()
+
+	Describe("task workspaces", func() {
+		var uploaded string
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case r.Method == "PUT":
+				b, _ := io.ReadAll(r.Body)
+				uploaded = string(b)
+			case r.URL.Path == "/files/big.bin":
+				w.Write([]byte(strings.Repeat("x", 100)))
+			case r.URL.Path == "/files/input.csv":
+				w.Write([]byte("a,b\n"))
+			default:
+				http.NotFound(w, r)
+			}
+		}))
+		defer srv.Close()
+		ctx := context.Background()
+
+		ws, err := NewWorkspace(64)
+		Expect(err, ToBeNil)
+
+		It("downloads artifacts", func() {
+			paths, err := ws.DownloadAll(ctx, []Artifact{{URL: srv.URL + "/files/input.csv?sig=1"}, {URL: srv.URL + "/files/input.csv", Name: "in/copy.csv"}})
+			Expect(err, ToBeNil)
+			Expect(paths, ToDeepEqual, []string{ws.Path("input.csv"), ws.Path("in/copy.csv")})
+			b, _ := os.ReadFile(paths[1])
+			Expect(string(b), ToEqual, "a,b\n")
+			size, _ := ws.Size()
+			Expect(size, ToEqual, int64(8))
+
+			_, err = ws.Download(ctx, Artifact{URL: srv.URL + "/files/missing"})
+			Expect(err, ToNotBeNil)
+		})
+
+		It("keeps within its size", func() {
+			_, err := ws.Download(ctx, Artifact{URL: srv.URL + "/files/big.bin"})
+			Expect(errors.Is(err, ErrWorkspaceFull), ToBeTrue)
+			_, err = os.Stat(ws.Path("big.bin"))
+			Expect(os.IsNotExist(err), ToBeTrue)
+			Expect(ws.Check(), ToBeNil)
+
+			os.WriteFile(ws.Path("out.txt"), []byte(strings.Repeat("y", 60)), 0o644)
+			Expect(errors.Is(ws.Check(), ErrWorkspaceFull), ToBeTrue)
+			Expect(ws.Path("../../etc/passwd"), ToEqual, ws.Path("etc/passwd"))
+		})
+
+		It("uploads files", func() {
+			Expect(ws.Upload(ctx, "input.csv", srv.URL+"/upload"), ToBeNil)
+			Expect(uploaded, ToEqual, "a,b\n")
+		})
+
+		It("is removed on close", func() {
+			Expect(ws.Close(), ToBeNil)
+			Expect(ws.Close(), ToBeNil)
+			_, err := os.Stat(ws.Dir)
+			Expect(os.IsNotExist(err), ToBeTrue)
+		})
+	})
+}